@@ -3,9 +3,9 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"os/signal"
 	"syscall"
 	"time"
@@ -17,10 +17,15 @@ import (
 	"github.com/shiva/hintro/config"
 	"github.com/shiva/hintro/internal/handler"
 	"github.com/shiva/hintro/internal/middleware"
+	"github.com/shiva/hintro/internal/model"
 	"github.com/shiva/hintro/internal/repository"
 	"github.com/shiva/hintro/internal/service"
+	"github.com/shiva/hintro/pkg/buildinfo"
 	"github.com/shiva/hintro/pkg/cache"
 	"github.com/shiva/hintro/pkg/db"
+	"github.com/shiva/hintro/pkg/geo"
+	"github.com/shiva/hintro/pkg/logctx"
+	"github.com/shiva/hintro/pkg/tracing"
 )
 
 func main() {
@@ -29,8 +34,64 @@ func main() {
 	if err != nil {
 		log.Fatalf("failed to load config: %v", err)
 	}
+	logLevel, err := logctx.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		log.Fatalf("invalid LOG_LEVEL: %v", err)
+	}
+	logctx.SetLevel(logLevel)
+	airport := model.Location{Lat: cfg.Airport.Lat, Lon: cfg.Airport.Lon}
+	if err := airport.Validate(); err != nil {
+		log.Fatalf("invalid AIRPORT_LAT/AIRPORT_LON: %v", err)
+	}
+	if err := model.ValidateLuggageLimit(cfg.Rides.MaxLuggagePerRequest); err != nil {
+		log.Fatalf("invalid RIDE_MAX_LUGGAGE: %v", err)
+	}
+	if err := service.ValidateToleranceLimit(cfg.Rides.MaxToleranceMeters); err != nil {
+		log.Fatalf("invalid RIDE_MAX_TOLERANCE_METERS: %v", err)
+	}
+	if cfg.Rides.ScheduleLeadTime < 0 || cfg.Rides.ScheduleSweepInterval <= 0 {
+		log.Fatalf("invalid RIDE_SCHEDULE_LEAD_TIME/RIDE_SCHEDULE_SWEEP_INTERVAL: lead must not be negative, interval must be positive")
+	}
+	if cfg.Rides.FleetCapacityTTL <= 0 {
+		log.Fatalf("invalid RIDE_FLEET_CAPACITY_TTL: must be positive")
+	}
+	if cfg.Rides.StuckTripAge <= 0 || cfg.Rides.StuckTripSweepInterval <= 0 {
+		log.Fatalf("invalid RIDE_STUCK_TRIP_AGE/RIDE_STUCK_TRIP_SWEEP_INTERVAL: both must be positive")
+	}
+	if cfg.Matching.MaxPassengersPerTrip < 0 {
+		log.Fatalf("invalid MATCH_MAX_PASSENGERS_PER_TRIP: must not be negative")
+	}
+	if cfg.Server.MaxBodyBytes <= 0 {
+		log.Fatalf("invalid SERVER_MAX_BODY_BYTES: must be positive")
+	}
+	if m := cfg.Pricing.MaxSurgeMultiplier; m != 0 && m < 1 {
+		log.Fatalf("invalid PRICING_MAX_SURGE_MULTIPLIER: must be 0 (no cap) or at least 1.0")
+	}
+	if cfg.Pricing.SurgeRoundingStep < 0 {
+		log.Fatalf("invalid PRICING_SURGE_ROUNDING_STEP: must not be negative")
+	}
+	if cfg.Pricing.MinSupply < 0 {
+		log.Fatalf("invalid PRICING_MIN_SUPPLY: must not be negative")
+	}
+	if cfg.Redis.BreakerThreshold > 0 && cfg.Redis.BreakerCooldown <= 0 {
+		log.Fatalf("invalid REDIS_BREAKER_COOLDOWN: must be positive when REDIS_BREAKER_THRESHOLD is set")
+	}
+	if r := cfg.Tracing.SampleRatio; r < 0 || r > 1 {
+		log.Fatalf("invalid TRACING_SAMPLE_RATIO: must be between 0 and 1")
+	}
+
+	// ctx is cancelled on SIGINT/SIGTERM; background workers run under it.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
 
-	ctx := context.Background()
+	// ── Tracing ─────────────────────────────────────────
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		log.Fatalf("failed to set up tracing: %v", err)
+	}
+	if cfg.Tracing.Endpoint != "" {
+		log.Printf("✓ Tracing to %s", cfg.Tracing.Endpoint)
+	}
 
 	// ── Connect to PostgreSQL ───────────────────────────
 	pgPool, err := db.NewPostgresPool(ctx, cfg.Postgres)
@@ -50,40 +111,208 @@ func main() {
 
 	// ── Initialize layers ───────────────────────────────
 	rideRepo := repository.NewRideRepository(pgPool)
-	rideRequestRepo := repository.NewRideRequestRepository(pgPool)
-	bookingRepo := repository.NewBookingRepository(pgPool)
-	pricingRepo := repository.NewPricingRepository(pgPool, redisClient)
+	if cfg.Matching.MaxTripAgeMinutes < 0 {
+		log.Fatalf("invalid MATCH_MAX_TRIP_AGE_MINUTES: must not be negative")
+	}
+	rideRepo.MaxTripAgeMinutes = cfg.Matching.MaxTripAgeMinutes
+	rideRequestRepo := repository.NewRideRequestRepository(pgPool, cfg.Rides.MaxLuggagePerRequest, cfg.Rides.MaxToleranceMeters)
+	rideRequestRepo.LuggageUsesSeats = cfg.Booking.LuggageUsesSeats
+	if cfg.Matching.CabStaleAfter < 0 {
+		log.Fatalf("invalid MATCH_CAB_STALE_AFTER: must not be negative")
+	}
+	bookingRepo := repository.NewBookingRepository(pgPool, airport, cfg.Matching.MaxPassengersPerTrip)
+	bookingRepo.CabStaleAfter = cfg.Matching.CabStaleAfter
+	bookingRepo.LuggageUsesSeats = cfg.Booking.LuggageUsesSeats
+	bookingRepo.ReserveScheduled = cfg.Booking.ReserveScheduledSeats
+	if cfg.Redis.CacheTTL <= 0 {
+		log.Fatalf("invalid REDIS_CACHE_TTL: must be positive")
+	}
+	surgeBreaker := cache.NewBreaker("surge", cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown)
+	pricingRepo := repository.NewPricingRepository(pgPool, cache.Guard(redisClient, surgeBreaker), cache.Namespace(cfg.Redis.KeyPrefix), cfg.Redis.CacheTTL)
+	pricingRepo.CabStaleAfter = cfg.Matching.CabStaleAfter
+	pricingRepo.MinSupply = cfg.Pricing.MinSupply
+	var surgeCounters *repository.SurgeCounters
+	if cfg.Pricing.SurgeCounters {
+		if cfg.Pricing.SurgeCounterReconcileInterval <= 0 {
+			log.Fatalf("invalid PRICING_SURGE_COUNTER_RECONCILE_INTERVAL: must be positive")
+		}
+		surgeCounters = repository.NewSurgeCounters(pgPool, cache.Guard(redisClient, surgeBreaker), cache.Namespace(cfg.Redis.KeyPrefix))
+		surgeCounters.CabStaleAfter = cfg.Matching.CabStaleAfter
+		pricingRepo.Counters = surgeCounters
+	}
+	outboxRepo := repository.NewOutboxRepository(pgPool)
+	cabRepo := repository.NewCabRepository(pgPool)
+	cabRepo.LuggageUsesSeats = cfg.Booking.LuggageUsesSeats
+	if cfg.Redis.CabCapacityTTL < 0 {
+		log.Fatalf("invalid REDIS_CAB_CAPACITY_TTL: must not be negative")
+	}
+	if cfg.Redis.CabCapacityTTL > 0 {
+		capacityBreaker := cache.NewBreaker("cab_capacity", cfg.Redis.BreakerThreshold, cfg.Redis.BreakerCooldown)
+		capacities := repository.NewCabCapacityCache(cache.Guard(redisClient, capacityBreaker), cache.Namespace(cfg.Redis.KeyPrefix), cfg.Redis.CabCapacityTTL)
+		rideRepo.Capacities = capacities
+		cabRepo.Capacities = capacities
+	}
+
+	insertion, err := geo.ParseInsertionStrategy(cfg.Matching.InsertionStrategy)
+	if err != nil {
+		log.Fatalf("invalid MATCH_INSERTION_STRATEGY: %v", err)
+	}
+	matchCfg := service.DefaultMatchConfig()
+	matchCfg.InsertionStrategy = insertion
+	if cfg.Matching.BacktrackPenaltyMinutes < 0 {
+		log.Fatalf("invalid MATCH_BACKTRACK_PENALTY_MINUTES: must not be negative")
+	}
+	matchCfg.BacktrackPenaltyMinutes = cfg.Matching.BacktrackPenaltyMinutes
+	if cfg.Matching.LoadPreferenceMinutes < 0 {
+		log.Fatalf("invalid MATCH_LOAD_PREFERENCE_MINUTES: must not be negative")
+	}
+	matchCfg.LoadPreferenceMinutes = cfg.Matching.LoadPreferenceMinutes
+	matchCfg.Airport = airport
+	if cfg.Matching.NoMatchCooldown < 0 {
+		log.Fatalf("invalid MATCH_NO_MATCH_COOLDOWN: must not be negative")
+	}
+	matchCfg.NoMatchCooldown = cfg.Matching.NoMatchCooldown
+	matchCfg.MaxPassengersPerTrip = cfg.Matching.MaxPassengersPerTrip
+	matchCfg.LuggageUsesSeats = cfg.Booking.LuggageUsesSeats
+	matchCfg.ReserveScheduled = cfg.Booking.ReserveScheduledSeats
+	if cfg.Booking.ReserveHorizon < 0 {
+		log.Fatalf("invalid BOOKING_RESERVE_HORIZON: must not be negative")
+	}
+	matchCfg.ReserveHorizon = cfg.Booking.ReserveHorizon
+	if cfg.Matching.DepartureWindow < 0 {
+		log.Fatalf("invalid MATCH_DEPARTURE_WINDOW: must not be negative")
+	}
+	matchCfg.DepartureWindow = cfg.Matching.DepartureWindow
+	matchCfg.MaxToleranceMeters = cfg.Rides.MaxToleranceMeters
+	if cfg.Matching.SlowThreshold < 0 {
+		log.Fatalf("invalid MATCH_SLOW_THRESHOLD: must not be negative")
+	}
+	matchCfg.SlowMatchThreshold = cfg.Matching.SlowThreshold
+	if cfg.Matching.RadiusExpansionSteps < 0 || cfg.Matching.MaxSearchRadiusM <= 0 {
+		log.Fatalf("invalid MATCH_RADIUS_EXPANSION_STEPS/MATCH_MAX_SEARCH_RADIUS_M: steps must not be negative, max radius must be positive")
+	}
+	matchCfg.RadiusExpansionSteps = cfg.Matching.RadiusExpansionSteps
+	matchCfg.MaxSearchRadiusM = cfg.Matching.MaxSearchRadiusM
+	if cfg.Matching.ClusterRadiusM < 0 {
+		log.Fatalf("invalid MATCH_CLUSTER_RADIUS_M: must not be negative")
+	}
+	matchCfg.ClusterRadiusM = cfg.Matching.ClusterRadiusM
 
-	matchingSvc := service.NewMatchingService(rideRepo)
-	bookingSvc := service.NewBookingService(bookingRepo, matchingSvc)
+	matchingSvc := service.NewMatchingService(rideRepo, matchCfg)
+	matchingSvc.Cooldown = repository.NewMatchCooldownStore(redisClient, cache.Namespace(cfg.Redis.KeyPrefix))
+	matchingSvc.Pending = rideRepo
+	fareCfg := service.DefaultFareConfig()
+	fareCfg.MaxSurgeMultiplier = cfg.Pricing.MaxSurgeMultiplier
+	fareCfg.SurgeRoundingStep = cfg.Pricing.SurgeRoundingStep
+	if cfg.Pricing.PoolDiscountPercent < 0 || cfg.Pricing.PoolDiscountPercent > 100 {
+		log.Fatalf("invalid PRICING_POOL_DISCOUNT_PERCENT: must be between 0 and 100")
+	}
+	fareCfg.PoolDiscountPercent = cfg.Pricing.PoolDiscountPercent
+	pricingRepo.CacheRadiusM = fareCfg.SurgeRadiusM
+	pricingSvc := service.NewPricingService(pricingRepo, fareCfg)
+	bookingSvc := service.NewBookingService(bookingRepo, matchingSvc, pricingSvc, cfg.Booking.Timeout)
 	cancelSvc := service.NewCancelService(bookingRepo, pricingRepo)
-	pricingSvc := service.NewPricingService(pricingRepo, service.DefaultFareConfig())
 
-	matchHandler := handler.NewMatchHandler(matchingSvc)
-	bookingHandler := handler.NewBookingHandler(bookingSvc)
+	matchHandler := handler.NewMatchHandler(matchingSvc, pricingSvc, cfg.Rides.MaxLuggagePerRequest)
+	var bookingTokens *service.BookingTokens
+	if secret := cfg.Booking.TokenSecret; secret != "" {
+		if len(secret) < 32 {
+			log.Fatalf("invalid BOOKING_TOKEN_SECRET: must be at least 32 bytes")
+		}
+		if cfg.Booking.TokenTTL <= 0 {
+			log.Fatalf("invalid BOOKING_TOKEN_TTL: must be positive")
+		}
+		bookingTokens = service.NewBookingTokens([]byte(secret), cfg.Booking.TokenTTL)
+	}
+	bookingHandler := handler.NewBookingHandler(bookingSvc, bookingTokens, rideRequestRepo)
 	cancelHandler := handler.NewCancelHandler(cancelSvc)
-	pricingHandler := handler.NewPricingHandler(pricingSvc)
-	rideHandler := handler.NewRideHandler(rideRequestRepo)
+	pricingHandler := handler.NewPricingHandler(pricingSvc, rideRequestRepo, rideRequestRepo)
+	fleetLimits := service.NewFleetLimits(cabRepo, cfg.Rides.FleetCapacityTTL)
+	rideHandler := handler.NewRideHandler(rideRequestRepo, fleetLimits, cfg.Rides.MaxLuggagePerRequest, cfg.Rides.MaxToleranceMeters, cfg.Rides.ScheduleLeadTime)
+	cabHandler := handler.NewCabHandler(cabRepo)
+	simulateHandler := handler.NewSimulateHandler(rideHandler, matchingSvc, bookingHandler)
+	if surgeCounters != nil {
+		bookingSvc.Counters = surgeCounters
+		cancelSvc.Counters = surgeCounters
+		rideHandler.Counters = surgeCounters
+		cabHandler.Counters = surgeCounters
+	}
 
-	// ── Setup router ────────────────────────────────────
-	router := mux.NewRouter()
+	// ── Background workers ──────────────────────────────
+	workers := service.NewWorkers(ctx)
 
-	// Health check endpoint.
-	router.HandleFunc("/health", healthHandler(pgPool, redisClient)).Methods(http.MethodGet)
+	var publisher service.EventPublisher = service.NewRedisPublisher(redisClient, cfg.Outbox.RedisChannel)
+	if cfg.Outbox.WebhookURL != "" {
+		publisher = service.NewWebhookPublisher(cfg.Outbox.WebhookURL, 5*time.Second)
+	}
+	if cfg.Outbox.RelayInterval <= 0 {
+		log.Fatalf("invalid OUTBOX_RELAY_INTERVAL: must be positive")
+	}
+	if cfg.Outbox.BatchSize <= 0 {
+		log.Fatalf("invalid OUTBOX_BATCH_SIZE: must be positive")
+	}
+	outboxRelay := service.NewOutboxRelay(outboxRepo, publisher, cfg.Outbox.RelayInterval, cfg.Outbox.BatchSize)
+	outboxRelay.DeadLetters = outboxRepo
+	if cfg.Outbox.WebhookURL != "" {
+		if cfg.Outbox.WebhookMaxAttempts < 0 {
+			log.Fatalf("invalid OUTBOX_WEBHOOK_MAX_ATTEMPTS: must not be negative")
+		}
+		outboxRelay.MaxAttempts = cfg.Outbox.WebhookMaxAttempts
+	}
+	workers.Go("outbox relay", outboxRelay)
+	adminHandler := handler.NewAdminHandler(bookingRepo, cancelSvc, rideRequestRepo, outboxRelay)
 
-	// API v1 routes.
-	api := router.PathPrefix("/api/v1").Subrouter()
-	// Ride request CRUD
-	api.HandleFunc("/rides", rideHandler.CreateRide).Methods(http.MethodPost)
-	api.HandleFunc("/rides/{id}", rideHandler.GetRide).Methods(http.MethodGet)
-	// Matching, booking, cancellation
-	api.HandleFunc("/match/{request_id}", matchHandler.MatchRideRequest).Methods(http.MethodPost)
-	api.HandleFunc("/book/{request_id}", bookingHandler.BookRide).Methods(http.MethodPost)
-	api.HandleFunc("/cancel/{request_id}", cancelHandler.CancelRide).Methods(http.MethodPost)
-	api.HandleFunc("/fare/estimate", pricingHandler.EstimateFare).Methods(http.MethodPost)
+	if cfg.Rides.PendingTTL <= 0 || cfg.Rides.ExpirySweepInterval <= 0 {
+		log.Fatalf("invalid RIDE_PENDING_TTL/RIDE_EXPIRY_SWEEP_INTERVAL: both must be positive")
+	}
+	expirySweeper := service.NewExpirySweeper(rideRequestRepo, cfg.Rides.PendingTTL, cfg.Rides.ExpirySweepInterval)
+	scheduleActivator := service.NewScheduleActivator(rideRequestRepo, cfg.Rides.ScheduleLeadTime, cfg.Rides.ScheduleSweepInterval)
+	tripReconciler := service.NewTripReconciler(bookingRepo, cfg.Rides.StuckTripAge, cfg.Rides.StuckTripSweepInterval)
+	if surgeCounters != nil {
+		adminHandler.Counters = surgeCounters
+		expirySweeper.Counters = surgeCounters
+		scheduleActivator.Counters = surgeCounters
+		tripReconciler.Counters = surgeCounters
+	}
+	workers.Go("expiry sweeper", expirySweeper)
+	workers.Go("schedule activator", scheduleActivator)
+	workers.Go("trip reconciler", tripReconciler)
+
+	if surgeCounters != nil {
+		surgeReconciler := service.NewSurgeReconciler(surgeCounters, cfg.Pricing.SurgeCounterReconcileInterval)
+		workers.Go("surge reconciler", surgeReconciler)
+	}
+
+	if cfg.Matching.PoolerEnabled {
+		if cfg.Matching.PoolerInterval <= 0 || cfg.Matching.PoolerMaxBookings <= 0 || cfg.Matching.PoolerScanLimit <= 0 {
+			log.Fatalf("invalid MATCH_POOLER_INTERVAL/MATCH_POOLER_MAX_BOOKINGS/MATCH_POOLER_SCAN_LIMIT: must be positive")
+		}
+		pooler := service.NewPoolingMatcher(rideRepo, matchingSvc, bookingSvc, cfg.Matching.PoolerInterval, cfg.Matching.PoolerMaxBookings, cfg.Matching.PoolerScanLimit)
+		workers.Go("pooler", pooler)
+	}
 
-	// Wrap with CORS so Swagger UI (and other browser clients) can call the API.
-	handler := middleware.CORS(router)
+	// ── Setup router ────────────────────────────────────
+	router := newRouter(apiHandlers{
+		health:     healthHandler(pgPool, redisClient),
+		readyz:     readyzHandler(pgPool, redisClient),
+		rides:      rideHandler,
+		bookings:   bookingHandler,
+		cancels:    cancelHandler,
+		pricing:    pricingHandler,
+		match:      matchHandler,
+		cabs:       cabHandler,
+		admin:      adminHandler,
+		simulate:   simulateHandler,
+		adminToken: cfg.Admin.Token,
+	})
+
+	// net/http/pprof and expvar's /debug/vars, on their own listener (nil
+	// unless SERVER_PPROF_ADDR is set).
+	pprofSrv := handler.NewPprofServer(cfg.Server.PprofAddr)
+
+	// Cap request bodies, then wrap with CORS so Swagger UI (and other
+	// browser clients) can call the API.
+	handler := middleware.CORS(middleware.MaxBodyBytes(cfg.Server.MaxBodyBytes)(router))
 
 	// ── Start HTTP server ───────────────────────────────
 	srv := &http.Server{
@@ -96,16 +325,27 @@ func main() {
 
 	// Start in a goroutine so we can listen for shutdown signals.
 	go func() {
-		log.Printf("🚀 Server listening on %s", cfg.Server.ServerAddr())
+		bi := buildinfo.Get()
+		log.Printf("🚀 Server %s (commit %s) listening on %s", bi.Version, bi.Commit, cfg.Server.ServerAddr())
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("server error: %v", err)
 		}
 	}()
 
+	// Profiling and metrics listen apart from the API, and only when configured.
+	if pprofSrv != nil {
+		go func() {
+			log.Printf("⚠ pprof and /debug/vars listening on %s (SERVER_PPROF_ADDR); do not expose it publicly", cfg.Server.PprofAddr)
+			if err := pprofSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				// Profiling is a side channel: a taken port must not take the API down.
+				log.Printf("⚠ pprof server on %s stopped: %v; the API keeps serving", cfg.Server.PprofAddr, err)
+			}
+		}()
+	}
+
 	// ── Graceful shutdown ───────────────────────────────
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
+	stopSignals() // a second signal kills the process
 	log.Println("⏳ Shutting down server...")
 
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -114,42 +354,147 @@ func main() {
 	if err := srv.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("server forced to shutdown: %v", err)
 	}
+	if pprofSrv != nil {
+		pprofSrv.Close() // profiles in flight are cut off, not drained
+	}
+	// Workers were cancelled with ctx; wait for them to finish their
+	// current batch before the deferred pool and Redis closes run.
+	if err := workers.Stop(shutdownCtx); err != nil {
+		log.Printf("⚠ background workers did not drain: %v", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Printf("⚠ tracing: spans not flushed: %v", err)
+	}
 
 	log.Println("✅ Server gracefully stopped")
 }
 
-// HealthResponse represents the /health endpoint response.
+// HealthResponse represents the /health and /readyz endpoint response.
 type HealthResponse struct {
 	Status   string            `json:"status"`
 	Services map[string]string `json:"services"`
 }
 
-// healthHandler returns an HTTP handler that checks PG and Redis connectivity.
+// apiHandlers are the handlers newRouter mounts.
+type apiHandlers struct {
+	health, readyz http.HandlerFunc
+	rides          *handler.RideHandler
+	bookings       *handler.BookingHandler
+	cancels        *handler.CancelHandler
+	pricing        *handler.PricingHandler
+	match          *handler.MatchHandler
+	cabs           *handler.CabHandler
+	admin          *handler.AdminHandler
+	simulate       *handler.SimulateHandler
+	adminToken     string // ADMIN_TOKEN, required by the /api/v1/admin routes.
+}
+
+// newRouter builds the API router. Profiling (handler.Pprof) is never
+// mounted here; it gets its own listener.
+func newRouter(h apiHandlers) *mux.Router {
+	router := mux.NewRouter()
+	router.NotFoundHandler = http.HandlerFunc(handler.NotFound)
+	router.MethodNotAllowedHandler = http.HandlerFunc(handler.MethodNotAllowed)
+	router.Use(middleware.Tracing) // root span per request, named after the route
+
+	// Health check endpoint.
+	router.HandleFunc("/health", h.health).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", h.readyz).Methods(http.MethodGet)
+
+	// API v1 routes.
+	api := router.PathPrefix("/api/v1").Subrouter()
+	api.HandleFunc("/version", handler.Version).Methods(http.MethodGet)
+	// Ride request CRUD
+	api.HandleFunc("/rides", h.rides.CreateRide).Methods(http.MethodPost)
+	api.HandleFunc("/rides/{id}", h.rides.GetRide).Methods(http.MethodGet)
+	api.HandleFunc("/rides/{id}", h.rides.UpdateRide).Methods(http.MethodPatch)
+	api.HandleFunc("/rides/{id}/status", h.rides.GetRideStatus).Methods(http.MethodGet)
+	api.HandleFunc("/rides/{id}/timeline", h.rides.GetRideTimeline).Methods(http.MethodGet)
+	api.HandleFunc("/rides/{id}/fulfill", h.bookings.Fulfill).Methods(http.MethodPost)
+	api.Handle("/users/{id}/cancel-pending", middleware.RequireAdmin(h.adminToken)(http.HandlerFunc(h.rides.CancelUserPending))).Methods(http.MethodPost)
+	api.HandleFunc("/trips/{id}", h.rides.GetTrip).Methods(http.MethodGet)
+	api.HandleFunc("/trips/{id}/eta", h.rides.GetTripETA).Methods(http.MethodGet)
+	api.HandleFunc("/trips/{id}/availability", h.rides.GetTripAvailability).Methods(http.MethodGet)
+	api.HandleFunc("/trips/{id}/passengers", h.rides.GetTripPassengers).Methods(http.MethodGet)
+	api.HandleFunc("/trips/{id}/surge", h.pricing.GetTripSurge).Methods(http.MethodGet)
+	api.HandleFunc("/trips/{id}/fare", h.pricing.GetTripFare).Methods(http.MethodGet)
+	// Matching, booking, cancellation
+	api.HandleFunc("/match/preview", h.match.PreviewMatch).Methods(http.MethodGet)
+	api.HandleFunc("/match/{request_id}", h.match.MatchRideRequest).Methods(http.MethodPost)
+	api.HandleFunc("/book/verify", h.bookings.VerifyBooking).Methods(http.MethodGet)
+	api.HandleFunc("/book/{request_id}", h.bookings.BookRide).Methods(http.MethodPost)
+	api.HandleFunc("/cancel/{request_id}", h.cancels.CancelRide).Methods(http.MethodPost)
+	api.HandleFunc("/fare/estimate", h.pricing.EstimateFare).Methods(http.MethodPost)
+	api.HandleFunc("/fare/route", h.pricing.EstimateRouteFare).Methods(http.MethodPost)
+	api.HandleFunc("/surge", h.pricing.GetSurge).Methods(http.MethodGet)
+	// Cab management
+	// Operator-only
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(middleware.RequireAdmin(h.adminToken))
+	admin.HandleFunc("/requests/{id}/reassign", h.admin.ReassignRequest).Methods(http.MethodPost)
+	admin.HandleFunc("/trips/{id}/cancel", h.admin.CancelTrip).Methods(http.MethodPost)
+	admin.HandleFunc("/trips/{id}/merge", h.admin.MergeTrip).Methods(http.MethodPost)
+	admin.HandleFunc("/rides/area", h.admin.RidesInArea).Methods(http.MethodGet)
+	admin.HandleFunc("/webhooks/replay/{id}", h.admin.ReplayWebhook).Methods(http.MethodPost)
+	admin.HandleFunc("/simulate", h.simulate.Simulate).Methods(http.MethodPost)
+	admin.HandleFunc("/cabs/locations", h.cabs.UpdateLocations).Methods(http.MethodPost)
+	admin.HandleFunc("/cabs/{id}", h.cabs.DeleteCab).Methods(http.MethodDelete)
+	admin.HandleFunc("/cabs/{id}/capacity", h.cabs.UpdateCapacity).Methods(http.MethodPatch)
+	return router
+}
+
+// healthHandler returns an HTTP handler that checks PG and Redis
+// connectivity.
 func healthHandler(pgPool *pgxpool.Pool, redisClient *redis.Client) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		resp := HealthResponse{
-			Status:   "ok",
-			Services: make(map[string]string),
-		}
+		writeHealth(w, dependencyHealth(r.Context(), pgPool, redisClient))
+	}
+}
 
-		if err := db.HealthCheck(r.Context(), pgPool); err != nil {
+// readyzHandler returns the readiness probe: the /health checks, plus
+// that the database schema is at least db.SchemaVersion, so traffic is
+// not sent to a server running against an out-of-date schema.
+func readyzHandler(pgPool *pgxpool.Pool, redisClient *redis.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := dependencyHealth(r.Context(), pgPool, redisClient)
+		if applied, err := db.CheckSchemaVersion(r.Context(), pgPool, db.SchemaVersion); err != nil {
 			resp.Status = "degraded"
-			resp.Services["postgres"] = "unhealthy: " + err.Error()
+			resp.Services["schema"] = "unhealthy: " + err.Error()
 		} else {
-			resp.Services["postgres"] = "healthy"
+			resp.Services["schema"] = fmt.Sprintf("healthy (version %d)", applied)
 		}
+		writeHealth(w, resp)
+	}
+}
 
-		if err := cache.HealthCheck(r.Context(), redisClient); err != nil {
-			resp.Status = "degraded"
-			resp.Services["redis"] = "unhealthy: " + err.Error()
-		} else {
-			resp.Services["redis"] = "healthy"
-		}
+// dependencyHealth pings PG and Redis.
+func dependencyHealth(ctx context.Context, pgPool *pgxpool.Pool, redisClient *redis.Client) HealthResponse {
+	resp := HealthResponse{
+		Status:   "ok",
+		Services: make(map[string]string),
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		if resp.Status != "ok" {
-			w.WriteHeader(http.StatusServiceUnavailable)
-		}
-		json.NewEncoder(w).Encode(resp)
+	if err := db.HealthCheck(ctx, pgPool); err != nil {
+		resp.Status = "degraded"
+		resp.Services["postgres"] = "unhealthy: " + err.Error()
+	} else {
+		resp.Services["postgres"] = "healthy"
+	}
+
+	if err := cache.HealthCheck(ctx, redisClient); err != nil {
+		resp.Status = "degraded"
+		resp.Services["redis"] = "unhealthy: " + err.Error()
+	} else {
+		resp.Services["redis"] = "healthy"
+	}
+	return resp
+}
+
+// writeHealth writes resp, with 503 unless every check passed.
+func writeHealth(w http.ResponseWriter, resp HealthResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
 	}
+	json.NewEncoder(w).Encode(resp)
 }
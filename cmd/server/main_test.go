@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewRouter_DoesNotServePprof(t *testing.T) {
+	// Importing net/http/pprof put the profiles on http.DefaultServeMux;
+	// the API router must not reach them.
+	if _, pattern := http.DefaultServeMux.Handler(httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil)); pattern == "" {
+		t.Fatal("net/http/pprof did not register on http.DefaultServeMux; this test no longer checks anything")
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	router := newRouter(apiHandlers{health: ok, readyz: ok})
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/vars"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("GET %s on the API router = %d, want 404", path, rec.Code)
+		}
+	}
+}
@@ -12,6 +12,15 @@ type Config struct {
 	Server   ServerConfig
 	Postgres PostgresConfig
 	Redis    RedisConfig
+	Outbox   OutboxConfig
+	Rides    RidesConfig
+	Booking  BookingConfig
+	Matching MatchingConfig
+	Admin    AdminConfig
+	Airport  AirportConfig
+	Log      LogConfig
+	Tracing  TracingConfig
+	Pricing  PricingConfig
 }
 
 // ServerConfig holds HTTP server settings.
@@ -21,6 +30,11 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `mapstructure:"SERVER_READ_TIMEOUT"`
 	WriteTimeout time.Duration `mapstructure:"SERVER_WRITE_TIMEOUT"`
 	IdleTimeout  time.Duration `mapstructure:"SERVER_IDLE_TIMEOUT"`
+	MaxBodyBytes int64         `mapstructure:"SERVER_MAX_BODY_BYTES"` // Larger request bodies get 413
+
+	// PprofAddr, when set, serves net/http/pprof on this separate address
+	// (e.g. 127.0.0.1:6060). Empty (the default) leaves profiling off.
+	PprofAddr string `mapstructure:"SERVER_PPROF_ADDR"`
 }
 
 // PostgresConfig holds PostgreSQL connection settings.
@@ -42,6 +56,199 @@ type RedisConfig struct {
 	Password string `mapstructure:"REDIS_PASSWORD"`
 	DB       int    `mapstructure:"REDIS_DB"`
 	PoolSize int    `mapstructure:"REDIS_POOL_SIZE"`
+
+	// KeyPrefix namespaces every cache key (e.g. "hintro-staging") so
+	// environments sharing an instance do not collide.
+	KeyPrefix string `mapstructure:"REDIS_KEY_PREFIX"`
+	// CacheTTL is how long cached surge demand/supply counts live.
+	CacheTTL time.Duration `mapstructure:"REDIS_CACHE_TTL"`
+	// CabCapacityTTL is how long a cab's cached capacity lives; entries are
+	// also dropped when the cab changes. 0 turns the cache off.
+	CabCapacityTTL time.Duration `mapstructure:"REDIS_CAB_CAPACITY_TTL"`
+
+	// BreakerThreshold consecutive cache failures open the circuit breaker
+	// for BreakerCooldown, during which surge and cab capacity lookups skip
+	// Redis. 0 disables it.
+	BreakerThreshold int           `mapstructure:"REDIS_BREAKER_THRESHOLD"`
+	BreakerCooldown  time.Duration `mapstructure:"REDIS_BREAKER_COOLDOWN"`
+}
+
+// OutboxConfig holds settings for the outbox relay.
+// Events go to WebhookURL when set, otherwise to the Redis channel.
+type OutboxConfig struct {
+	RelayInterval time.Duration `mapstructure:"OUTBOX_RELAY_INTERVAL"`
+	BatchSize     int           `mapstructure:"OUTBOX_BATCH_SIZE"`
+	RedisChannel  string        `mapstructure:"OUTBOX_REDIS_CHANNEL"`
+	WebhookURL    string        `mapstructure:"OUTBOX_WEBHOOK_URL"`
+
+	// WebhookMaxAttempts is how many times in a row the webhook may refuse
+	// an event before it is moved to webhook_deadletter. 0 = retry forever.
+	WebhookMaxAttempts int `mapstructure:"OUTBOX_WEBHOOK_MAX_ATTEMPTS"`
+}
+
+// RidesConfig holds ride request lifecycle settings.
+type RidesConfig struct {
+	PendingTTL          time.Duration `mapstructure:"RIDE_PENDING_TTL"`
+	ExpirySweepInterval time.Duration `mapstructure:"RIDE_EXPIRY_SWEEP_INTERVAL"`
+
+	// MaxLuggagePerRequest caps luggage_count on new requests. It may not
+	// exceed the database CHECK (8).
+	MaxLuggagePerRequest int `mapstructure:"RIDE_MAX_LUGGAGE"`
+
+	// MaxToleranceMeters caps tolerance_meters, and so the radius of every
+	// candidate search. It may not exceed service.MaxToleranceMeters (the
+	// database CHECK).
+	MaxToleranceMeters int `mapstructure:"RIDE_MAX_TOLERANCE_METERS"`
+
+	// A request with scheduled_at further out than ScheduleLeadTime waits
+	// as 'scheduled' and joins the pending pool that long before departure.
+	ScheduleLeadTime      time.Duration `mapstructure:"RIDE_SCHEDULE_LEAD_TIME"`
+	ScheduleSweepInterval time.Duration `mapstructure:"RIDE_SCHEDULE_SWEEP_INTERVAL"`
+
+	// FleetCapacityTTL is how long the largest cab's seat count is cached
+	// for rejecting groups no cab can carry.
+	FleetCapacityTTL time.Duration `mapstructure:"RIDE_FLEET_CAPACITY_TTL"`
+
+	// A planned trip with no matched or confirmed rider for longer than
+	// StuckTripAge is cancelled and its cab freed; checked every
+	// StuckTripSweepInterval.
+	StuckTripAge           time.Duration `mapstructure:"RIDE_STUCK_TRIP_AGE"`
+	StuckTripSweepInterval time.Duration `mapstructure:"RIDE_STUCK_TRIP_SWEEP_INTERVAL"`
+}
+
+// BookingConfig holds booking transaction settings.
+type BookingConfig struct {
+	Timeout time.Duration `mapstructure:"BOOKING_TIMEOUT"`
+
+	// TokenSecret signs booking confirmation tokens (HMAC-SHA256). Empty
+	// turns them off. Tokens expire TokenTTL after the booking.
+	TokenSecret string        `mapstructure:"BOOKING_TOKEN_SECRET"`
+	TokenTTL    time.Duration `mapstructure:"BOOKING_TOKEN_TTL"`
+
+	// LuggageUsesSeats lets bags that overflow a cab's luggage space take
+	// its free seats, one seat per bag, when pooling riders.
+	LuggageUsesSeats bool `mapstructure:"BOOKING_LUGGAGE_USES_SEATS"`
+
+	// ReserveScheduledSeats lets a scheduled ride be booked before its
+	// departure, holding its seats on the trip's cab from booking time.
+	ReserveScheduledSeats bool `mapstructure:"BOOKING_RESERVE_SCHEDULED_SEATS"`
+
+	// ReserveHorizon is how far ahead of departure a scheduled ride may
+	// be booked as a reservation (0 = no limit).
+	ReserveHorizon time.Duration `mapstructure:"BOOKING_RESERVE_HORIZON"`
+}
+
+// MatchingConfig holds ride matching settings.
+type MatchingConfig struct {
+	// InsertionStrategy is "marginal" (default) or "cheapest_total".
+	InsertionStrategy string `mapstructure:"MATCH_INSERTION_STRATEGY"`
+
+	// BacktrackPenaltyMinutes ranks down pickups that send the cab back
+	// against the route (0 = off).
+	BacktrackPenaltyMinutes float64 `mapstructure:"MATCH_BACKTRACK_PENALTY_MINUTES"`
+
+	// LoadPreferenceMinutes ranks up trips already carrying riders, per
+	// booked seat, to fill existing cabs first (0 = off).
+	LoadPreferenceMinutes float64 `mapstructure:"MATCH_LOAD_PREFERENCE_MINUTES"`
+
+	// NoMatchCooldown short-circuits repeat /match calls for a request that
+	// just failed to match (0 = off).
+	NoMatchCooldown time.Duration `mapstructure:"MATCH_NO_MATCH_COOLDOWN"`
+
+	// MaxPassengersPerTrip caps passengers pooled onto one trip regardless
+	// of the cab's seats (0 = off).
+	MaxPassengersPerTrip int `mapstructure:"MATCH_MAX_PASSENGERS_PER_TRIP"`
+
+	// SlowThreshold is how long a /match call may take before it logs a
+	// warning with its phase timings (0 = off).
+	SlowThreshold time.Duration `mapstructure:"MATCH_SLOW_THRESHOLD"`
+
+	// MaxTripAgeMinutes stops planned trips older than this from taking
+	// new passengers (0 = off).
+	MaxTripAgeMinutes int `mapstructure:"MATCH_MAX_TRIP_AGE_MINUTES"`
+
+	// CabStaleAfter treats a cab with no location push for this long as
+	// offline: it is not dispatched and not counted as supply (0 = off).
+	CabStaleAfter time.Duration `mapstructure:"MATCH_CAB_STALE_AFTER"`
+
+	// RadiusExpansionSteps is how many wider searches follow a search
+	// that finds no fitting trip, spaced out to MaxSearchRadiusM (0 = off).
+	RadiusExpansionSteps int `mapstructure:"MATCH_RADIUS_EXPANSION_STEPS"`
+
+	// MaxSearchRadiusM is the widest radius (meters) expansion searches.
+	MaxSearchRadiusM int `mapstructure:"MATCH_MAX_SEARCH_RADIUS_M"`
+
+	// ClusterRadiusM is how close (meters) two pending pickups must be to
+	// be grouped for a new trip, independent of riders' tolerance. 0 uses
+	// the default, service.DefaultClusterRadiusM.
+	ClusterRadiusM int `mapstructure:"MATCH_CLUSTER_RADIUS_M"`
+
+	// DepartureWindow is how far apart two riders' departures may be for
+	// them to share a trip (0 = off).
+	DepartureWindow time.Duration `mapstructure:"MATCH_DEPARTURE_WINDOW"`
+
+	// PoolerEnabled runs the background pooler, which books pending riders
+	// waiting near each other into shared trips (off by default).
+	PoolerEnabled bool `mapstructure:"MATCH_POOLER_ENABLED"`
+
+	// PoolerInterval is how often the pooler scans the pending pool.
+	PoolerInterval time.Duration `mapstructure:"MATCH_POOLER_INTERVAL"`
+
+	// PoolerMaxBookings caps how many requests one pooler run books.
+	PoolerMaxBookings int `mapstructure:"MATCH_POOLER_MAX_BOOKINGS"`
+
+	// PoolerScanLimit is how many pending requests, oldest first, one
+	// pooler run reads.
+	PoolerScanLimit int `mapstructure:"MATCH_POOLER_SCAN_LIMIT"`
+}
+
+// AdminConfig holds settings for the /api/v1/admin endpoints.
+// An empty Token disables them.
+type AdminConfig struct {
+	Token string `mapstructure:"ADMIN_TOKEN"`
+}
+
+// LogConfig holds logging settings.
+type LogConfig struct {
+	Level string `mapstructure:"LOG_LEVEL"` // debug, info, warn or error
+}
+
+// TracingConfig holds OpenTelemetry trace export settings.
+type TracingConfig struct {
+	// Endpoint is the OTLP/HTTP collector URL spans are exported to, e.g.
+	// http://localhost:4318. Empty turns tracing off.
+	Endpoint    string  `mapstructure:"OTEL_EXPORTER_OTLP_ENDPOINT"`
+	ServiceName string  `mapstructure:"OTEL_SERVICE_NAME"`
+	SampleRatio float64 `mapstructure:"TRACING_SAMPLE_RATIO"` // Share of new traces kept, 0–1.
+}
+
+// PricingConfig holds fare settings that operators tune at runtime; the
+// rest of the fare formula lives in service.DefaultFareConfig.
+type PricingConfig struct {
+	// MaxSurgeMultiplier caps surge pricing. 0 means no cap.
+	MaxSurgeMultiplier float64 `mapstructure:"PRICING_MAX_SURGE_MULTIPLIER"`
+	// SurgeRoundingStep rounds surge to a multiple of the step (never
+	// below 1.0). 0 means no rounding.
+	SurgeRoundingStep float64 `mapstructure:"PRICING_SURGE_ROUNDING_STEP"`
+	// MinSupply is the fewest cabs the demand/supply ratio divides by, so
+	// sparse areas with no cabs do not surge on one request. 0 = off.
+	MinSupply int `mapstructure:"PRICING_MIN_SUPPLY"`
+	// PoolDiscountPercent is taken off the fare of a rider joining an
+	// existing trip (0–100). 0 = no discount.
+	PoolDiscountPercent int `mapstructure:"PRICING_POOL_DISCOUNT_PERCENT"`
+	// SurgeCounters answers surge reads from live per-cell Redis counters
+	// instead of counting in PostGIS on a cache miss.
+	SurgeCounters bool `mapstructure:"PRICING_SURGE_COUNTERS"`
+	// SurgeCounterReconcileInterval is how often the counters are
+	// rewritten from the database to correct drift.
+	SurgeCounterReconcileInterval time.Duration `mapstructure:"PRICING_SURGE_COUNTER_RECONCILE_INTERVAL"`
+}
+
+// AirportConfig holds the coordinates of the airport every trip starts or
+// ends at. There is no default: the server refuses to start without them.
+type AirportConfig struct {
+	Lat float64 `mapstructure:"AIRPORT_LAT"`
+	Lon float64 `mapstructure:"AIRPORT_LON"`
 }
 
 // DSN returns the PostgreSQL connection string.
@@ -75,6 +282,8 @@ func Load() (*Config, error) {
 	viper.SetDefault("SERVER_READ_TIMEOUT", "5s")
 	viper.SetDefault("SERVER_WRITE_TIMEOUT", "10s")
 	viper.SetDefault("SERVER_IDLE_TIMEOUT", "120s")
+	viper.SetDefault("SERVER_MAX_BODY_BYTES", 1<<20)
+	viper.SetDefault("SERVER_PPROF_ADDR", "")
 
 	viper.SetDefault("POSTGRES_HOST", "localhost")
 	viper.SetDefault("POSTGRES_PORT", 5432)
@@ -90,6 +299,65 @@ func Load() (*Config, error) {
 	viper.SetDefault("REDIS_PASSWORD", "")
 	viper.SetDefault("REDIS_DB", 0)
 	viper.SetDefault("REDIS_POOL_SIZE", 100)
+	viper.SetDefault("REDIS_KEY_PREFIX", "hintro")
+	viper.SetDefault("REDIS_CACHE_TTL", "30s")
+	viper.SetDefault("REDIS_CAB_CAPACITY_TTL", "1h")
+	viper.SetDefault("REDIS_BREAKER_THRESHOLD", 5)
+	viper.SetDefault("REDIS_BREAKER_COOLDOWN", "10s")
+
+	viper.SetDefault("OUTBOX_RELAY_INTERVAL", "1s")
+	viper.SetDefault("OUTBOX_BATCH_SIZE", 100)
+	viper.SetDefault("OUTBOX_REDIS_CHANNEL", "hintro:events")
+	viper.SetDefault("OUTBOX_WEBHOOK_URL", "")
+	viper.SetDefault("OUTBOX_WEBHOOK_MAX_ATTEMPTS", 5)
+
+	viper.SetDefault("RIDE_PENDING_TTL", "30m")
+	viper.SetDefault("RIDE_EXPIRY_SWEEP_INTERVAL", "1m")
+	viper.SetDefault("RIDE_MAX_LUGGAGE", 8)
+	viper.SetDefault("RIDE_MAX_TOLERANCE_METERS", 7500)
+	viper.SetDefault("RIDE_SCHEDULE_LEAD_TIME", "30m")
+	viper.SetDefault("RIDE_SCHEDULE_SWEEP_INTERVAL", "30s")
+	viper.SetDefault("RIDE_FLEET_CAPACITY_TTL", "1m")
+	viper.SetDefault("RIDE_STUCK_TRIP_AGE", "30m")
+	viper.SetDefault("RIDE_STUCK_TRIP_SWEEP_INTERVAL", "5m")
+
+	viper.SetDefault("BOOKING_TIMEOUT", "5s")
+	viper.SetDefault("BOOKING_TOKEN_SECRET", "")
+	viper.SetDefault("BOOKING_TOKEN_TTL", "24h")
+	viper.SetDefault("BOOKING_LUGGAGE_USES_SEATS", false)
+	viper.SetDefault("BOOKING_RESERVE_SCHEDULED_SEATS", false)
+	viper.SetDefault("BOOKING_RESERVE_HORIZON", "2h")
+
+	viper.SetDefault("MATCH_INSERTION_STRATEGY", "marginal")
+	viper.SetDefault("MATCH_BACKTRACK_PENALTY_MINUTES", 0)
+	viper.SetDefault("MATCH_LOAD_PREFERENCE_MINUTES", 0)
+	viper.SetDefault("MATCH_NO_MATCH_COOLDOWN", "5s")
+	viper.SetDefault("MATCH_MAX_PASSENGERS_PER_TRIP", 0)
+	viper.SetDefault("MATCH_SLOW_THRESHOLD", "50ms")
+	viper.SetDefault("MATCH_MAX_TRIP_AGE_MINUTES", 0)
+	viper.SetDefault("MATCH_CAB_STALE_AFTER", "0s")
+	viper.SetDefault("MATCH_RADIUS_EXPANSION_STEPS", 0)
+	viper.SetDefault("MATCH_MAX_SEARCH_RADIUS_M", 6000)
+	viper.SetDefault("MATCH_CLUSTER_RADIUS_M", 2000)
+	viper.SetDefault("MATCH_DEPARTURE_WINDOW", "30m")
+	viper.SetDefault("MATCH_POOLER_ENABLED", false)
+	viper.SetDefault("MATCH_POOLER_INTERVAL", "30s")
+	viper.SetDefault("MATCH_POOLER_MAX_BOOKINGS", 20)
+	viper.SetDefault("MATCH_POOLER_SCAN_LIMIT", 500)
+
+	viper.SetDefault("ADMIN_TOKEN", "")
+	viper.SetDefault("LOG_LEVEL", "info")
+
+	viper.SetDefault("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	viper.SetDefault("OTEL_SERVICE_NAME", "hintro")
+	viper.SetDefault("TRACING_SAMPLE_RATIO", 1.0)
+
+	viper.SetDefault("PRICING_MAX_SURGE_MULTIPLIER", 0)
+	viper.SetDefault("PRICING_SURGE_ROUNDING_STEP", 0.1)
+	viper.SetDefault("PRICING_MIN_SUPPLY", 0)
+	viper.SetDefault("PRICING_POOL_DISCOUNT_PERCENT", 10)
+	viper.SetDefault("PRICING_SURGE_COUNTERS", false)
+	viper.SetDefault("PRICING_SURGE_COUNTER_RECONCILE_INTERVAL", "1m")
 
 	// Try to read .env file. If it doesn't exist (e.g., inside Docker),
 	// env vars injected by docker-compose env_file are used instead.
@@ -104,6 +372,8 @@ func Load() (*Config, error) {
 		ReadTimeout:  viper.GetDuration("SERVER_READ_TIMEOUT"),
 		WriteTimeout: viper.GetDuration("SERVER_WRITE_TIMEOUT"),
 		IdleTimeout:  viper.GetDuration("SERVER_IDLE_TIMEOUT"),
+		MaxBodyBytes: viper.GetInt64("SERVER_MAX_BODY_BYTES"),
+		PprofAddr:    viper.GetString("SERVER_PPROF_ADDR"),
 	}
 
 	// ── Postgres ────────────────────────────────────────
@@ -125,6 +395,107 @@ func Load() (*Config, error) {
 		Password: viper.GetString("REDIS_PASSWORD"),
 		DB:       viper.GetInt("REDIS_DB"),
 		PoolSize: viper.GetInt("REDIS_POOL_SIZE"),
+
+		KeyPrefix: viper.GetString("REDIS_KEY_PREFIX"),
+		CacheTTL:  viper.GetDuration("REDIS_CACHE_TTL"),
+
+		CabCapacityTTL: viper.GetDuration("REDIS_CAB_CAPACITY_TTL"),
+
+		BreakerThreshold: viper.GetInt("REDIS_BREAKER_THRESHOLD"),
+		BreakerCooldown:  viper.GetDuration("REDIS_BREAKER_COOLDOWN"),
+	}
+
+	// ── Outbox ──────────────────────────────────────────
+	cfg.Outbox = OutboxConfig{
+		RelayInterval: viper.GetDuration("OUTBOX_RELAY_INTERVAL"),
+		BatchSize:     viper.GetInt("OUTBOX_BATCH_SIZE"),
+		RedisChannel:  viper.GetString("OUTBOX_REDIS_CHANNEL"),
+		WebhookURL:    viper.GetString("OUTBOX_WEBHOOK_URL"),
+
+		WebhookMaxAttempts: viper.GetInt("OUTBOX_WEBHOOK_MAX_ATTEMPTS"),
+	}
+
+	// ── Rides ───────────────────────────────────────────
+	cfg.Rides = RidesConfig{
+		PendingTTL:           viper.GetDuration("RIDE_PENDING_TTL"),
+		ExpirySweepInterval:  viper.GetDuration("RIDE_EXPIRY_SWEEP_INTERVAL"),
+		MaxLuggagePerRequest: viper.GetInt("RIDE_MAX_LUGGAGE"),
+		MaxToleranceMeters:   viper.GetInt("RIDE_MAX_TOLERANCE_METERS"),
+
+		ScheduleLeadTime:      viper.GetDuration("RIDE_SCHEDULE_LEAD_TIME"),
+		ScheduleSweepInterval: viper.GetDuration("RIDE_SCHEDULE_SWEEP_INTERVAL"),
+
+		FleetCapacityTTL: viper.GetDuration("RIDE_FLEET_CAPACITY_TTL"),
+
+		StuckTripAge:           viper.GetDuration("RIDE_STUCK_TRIP_AGE"),
+		StuckTripSweepInterval: viper.GetDuration("RIDE_STUCK_TRIP_SWEEP_INTERVAL"),
+	}
+
+	// ── Booking ─────────────────────────────────────────
+	cfg.Booking = BookingConfig{
+		Timeout: viper.GetDuration("BOOKING_TIMEOUT"),
+
+		TokenSecret: viper.GetString("BOOKING_TOKEN_SECRET"),
+		TokenTTL:    viper.GetDuration("BOOKING_TOKEN_TTL"),
+
+		LuggageUsesSeats:      viper.GetBool("BOOKING_LUGGAGE_USES_SEATS"),
+		ReserveScheduledSeats: viper.GetBool("BOOKING_RESERVE_SCHEDULED_SEATS"),
+		ReserveHorizon:        viper.GetDuration("BOOKING_RESERVE_HORIZON"),
+	}
+
+	// ── Matching ────────────────────────────────────────
+	cfg.Matching = MatchingConfig{
+		InsertionStrategy:       viper.GetString("MATCH_INSERTION_STRATEGY"),
+		BacktrackPenaltyMinutes: viper.GetFloat64("MATCH_BACKTRACK_PENALTY_MINUTES"),
+		LoadPreferenceMinutes:   viper.GetFloat64("MATCH_LOAD_PREFERENCE_MINUTES"),
+		NoMatchCooldown:         viper.GetDuration("MATCH_NO_MATCH_COOLDOWN"),
+		MaxPassengersPerTrip:    viper.GetInt("MATCH_MAX_PASSENGERS_PER_TRIP"),
+		SlowThreshold:           viper.GetDuration("MATCH_SLOW_THRESHOLD"),
+		MaxTripAgeMinutes:       viper.GetInt("MATCH_MAX_TRIP_AGE_MINUTES"),
+		CabStaleAfter:           viper.GetDuration("MATCH_CAB_STALE_AFTER"),
+		RadiusExpansionSteps:    viper.GetInt("MATCH_RADIUS_EXPANSION_STEPS"),
+		MaxSearchRadiusM:        viper.GetInt("MATCH_MAX_SEARCH_RADIUS_M"),
+		ClusterRadiusM:          viper.GetInt("MATCH_CLUSTER_RADIUS_M"),
+		DepartureWindow:         viper.GetDuration("MATCH_DEPARTURE_WINDOW"),
+		PoolerEnabled:           viper.GetBool("MATCH_POOLER_ENABLED"),
+		PoolerInterval:          viper.GetDuration("MATCH_POOLER_INTERVAL"),
+		PoolerMaxBookings:       viper.GetInt("MATCH_POOLER_MAX_BOOKINGS"),
+		PoolerScanLimit:         viper.GetInt("MATCH_POOLER_SCAN_LIMIT"),
+	}
+
+	// ── Admin ───────────────────────────────────────────
+	cfg.Admin = AdminConfig{
+		Token: viper.GetString("ADMIN_TOKEN"),
+	}
+
+	// ── Airport ─────────────────────────────────────────
+	cfg.Airport = AirportConfig{
+		Lat: viper.GetFloat64("AIRPORT_LAT"),
+		Lon: viper.GetFloat64("AIRPORT_LON"),
+	}
+
+	// ── Log ─────────────────────────────────────────────
+	cfg.Log = LogConfig{
+		Level: viper.GetString("LOG_LEVEL"),
+	}
+
+	// ── Tracing ─────────────────────────────────────────
+	cfg.Tracing = TracingConfig{
+		Endpoint:    viper.GetString("OTEL_EXPORTER_OTLP_ENDPOINT"),
+		ServiceName: viper.GetString("OTEL_SERVICE_NAME"),
+		SampleRatio: viper.GetFloat64("TRACING_SAMPLE_RATIO"),
+	}
+
+	// ── Pricing ─────────────────────────────────────────
+	cfg.Pricing = PricingConfig{
+		MaxSurgeMultiplier: viper.GetFloat64("PRICING_MAX_SURGE_MULTIPLIER"),
+		SurgeRoundingStep:  viper.GetFloat64("PRICING_SURGE_ROUNDING_STEP"),
+		MinSupply:          viper.GetInt("PRICING_MIN_SUPPLY"),
+
+		PoolDiscountPercent: viper.GetInt("PRICING_POOL_DISCOUNT_PERCENT"),
+
+		SurgeCounters:                 viper.GetBool("PRICING_SURGE_COUNTERS"),
+		SurgeCounterReconcileInterval: viper.GetDuration("PRICING_SURGE_COUNTER_RECONCILE_INTERVAL"),
 	}
 
 	return cfg, nil
@@ -0,0 +1,356 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+// requestReassigner is the part of BookingRepository used by AdminHandler.
+type requestReassigner interface {
+	ReassignRequest(ctx context.Context, requestID, targetTripID int64) (*repository.ReassignResult, error)
+}
+
+// tripCanceller is the part of service.CancelService used by AdminHandler.
+type tripCanceller interface {
+	CancelTrip(ctx context.Context, tripID int64) (*repository.TripCancelResult, error)
+}
+
+// tripMerger is the part of BookingRepository used by AdminHandler.MergeTrip.
+type tripMerger interface {
+	MergeTrips(ctx context.Context, fromTripID, intoTripID int64) (*repository.TripMergeResult, error)
+}
+
+// rideAreaSearcher is the part of RideRequestRepository used by
+// AdminHandler.RidesInArea.
+type rideAreaSearcher interface {
+	RequestsInArea(ctx context.Context, q repository.AreaQuery) (*repository.AreaPage, error)
+}
+
+// webhookReplayer is the part of service.OutboxRelay used by
+// AdminHandler.ReplayWebhook.
+type webhookReplayer interface {
+	Replay(ctx context.Context, id int64) (*model.WebhookDeadLetter, error)
+}
+
+// ReassignBody is the JSON body for POST /api/v1/admin/requests/{id}/reassign.
+type ReassignBody struct {
+	TripID int64 `json:"trip_id"`
+}
+
+// MergeTripBody is the JSON body for POST /api/v1/admin/trips/{id}/merge.
+type MergeTripBody struct {
+	IntoTripID int64 `json:"into_trip_id"`
+}
+
+// AdminHandler handles operator-only HTTP requests. Routes are mounted
+// behind middleware.RequireAdmin.
+type AdminHandler struct {
+	bookingRepo requestReassigner
+	cancelSvc   tripCanceller
+	merger      tripMerger
+	areas       rideAreaSearcher
+	replayer    webhookReplayer
+
+	// Counters, when set, is told about the cabs a reassign or merge
+	// claims and frees.
+	Counters service.SurgeCounter
+}
+
+// NewAdminHandler creates a new admin handler.
+func NewAdminHandler(
+	bookingRepo *repository.BookingRepository,
+	cancelSvc *service.CancelService,
+	rideRequestRepo *repository.RideRequestRepository,
+	outboxRelay *service.OutboxRelay,
+) *AdminHandler {
+	return &AdminHandler{
+		bookingRepo: bookingRepo, cancelSvc: cancelSvc, merger: bookingRepo,
+		areas: rideRequestRepo, replayer: outboxRelay,
+	}
+}
+
+// ReassignRequest handles POST /api/v1/admin/requests/{id}/reassign
+//
+// Moves a matched request onto another planned trip, e.g. to relieve an
+// overbooked cab. Both trips' passenger counts change in one transaction.
+//
+// Request body:
+//
+//	{"trip_id": 7}
+//
+// Response codes:
+//   200  — Reassigned (returns ReassignResult)
+//   400  — Invalid id or malformed JSON
+//   403  — Missing/invalid admin token
+//   404  — Request or target trip not found
+//   409  — Request not matched, or target trip incompatible
+//   422  — trip_id missing, or target trip lacks capacity (or is at the passenger limit)
+//   500  — Unexpected error
+func (h *AdminHandler) ReassignRequest(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid request id")
+		return
+	}
+
+	var body ReassignBody
+	if err := decodeJSON(r, &body); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
+		return
+	}
+	if body.TripID <= 0 {
+		writeFieldError(w, "trip_id", "is required")
+		return
+	}
+
+	result, err := h.bookingRepo.ReassignRequest(r.Context(), requestID, body.TripID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInsufficientCapacity), errors.Is(err, model.ErrPassengerLimit):
+			writeError(w, "target_trip_full", "The target trip does not have enough seats or luggage space.")
+		case errors.Is(err, repository.ErrRequestNotFound):
+			writeError(w, "not_found", "Ride request not found.")
+		case errors.Is(err, repository.ErrTripNotFound):
+			writeError(w, "trip_not_found", "Target trip not found.")
+		case errors.Is(err, repository.ErrReassignNotMatched):
+			writeError(w, "not_matched", "Only a matched ride request can be reassigned.")
+		case errors.Is(err, repository.ErrTripIncompatible):
+			writeError(w, "trip_incompatible", "The target trip is not planned, goes the other direction, or is the current trip.")
+		default:
+			log.Printf("[handler] reassign error: %v", err)
+			writeInternalError(w, err, "Internal server error.")
+		}
+		return
+	}
+
+	service.CountSurge(r.Context(), h.Counters, result.Surge)
+	log.Printf("[admin] Reassigned request #%d: trip #%d → #%d", requestID, result.FromTripID, result.ToTripID)
+	writeJSON(w, http.StatusOK, result)
+}
+
+// CancelTrip handles POST /api/v1/admin/trips/{id}/cancel
+//
+// Cancels a planned or in-progress trip outright: every matched or
+// confirmed rider on it is cancelled, the trip is cancelled and its cab
+// freed, in one transaction. Unlike a rider's own cancel, nobody is left
+// on the trip and no rider goes back to the pending pool.
+//
+// Response codes:
+//   200  — Cancelled (returns TripCancelResult)
+//   400  — Invalid trip id
+//   403  — Missing/invalid admin token
+//   404  — Trip not found
+//   409  — Trip already completed or cancelled
+//   500  — Unexpected error
+func (h *AdminHandler) CancelTrip(w http.ResponseWriter, r *http.Request) {
+	tripID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	result, err := h.cancelSvc.CancelTrip(r.Context(), tripID)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrTripNotFound):
+			writeError(w, "trip_not_found", "Trip not found.")
+		case errors.Is(err, repository.ErrTripNotCancellable):
+			writeError(w, "not_cancellable", "Only a planned or in-progress trip can be cancelled.")
+		default:
+			log.Printf("[handler] cancel trip error: %v", err)
+			writeInternalError(w, err, "Internal server error.")
+		}
+		return
+	}
+
+	log.Printf("[admin] Cancelled trip #%d with %d requests", tripID, len(result.CancelledRequests))
+	writeJSON(w, http.StatusOK, result)
+}
+
+// MergeTrip handles POST /api/v1/admin/trips/{id}/merge
+//
+// Moves every rider of planned trip {id} onto another planned trip going
+// the same way, then cancels trip {id} and frees its cab, in one
+// transaction. Used to pool two nearly-empty trips into one cab.
+//
+// Request body:
+//
+//	{"into_trip_id": 7}
+//
+// Response codes:
+//   200  — Merged (returns TripMergeResult)
+//   400  — Invalid id or malformed JSON
+//   403  — Missing/invalid admin token
+//   404  — Either trip not found
+//   409  — Trips not both planned, opposite directions, the same trip, or cab not bookable
+//   422  — into_trip_id missing, target trip lacks capacity, or a rider's detour would exceed their tolerance
+//   500  — Unexpected error
+func (h *AdminHandler) MergeTrip(w http.ResponseWriter, r *http.Request) {
+	tripID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	var body MergeTripBody
+	if err := decodeJSON(r, &body); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
+		return
+	}
+	if body.IntoTripID <= 0 {
+		writeFieldError(w, "into_trip_id", "is required")
+		return
+	}
+
+	result, err := h.merger.MergeTrips(r.Context(), tripID, body.IntoTripID)
+	if err != nil {
+		switch {
+		case errors.Is(err, model.ErrInsufficientCapacity), errors.Is(err, model.ErrPassengerLimit):
+			writeError(w, "target_trip_full", "The target trip does not have enough seats or luggage space.")
+		case errors.Is(err, repository.ErrMergeDetour):
+			writeError(w, "detour_too_long", "The merged route would exceed a rider's detour tolerance.")
+		case errors.Is(err, repository.ErrTripNotFound):
+			writeError(w, "trip_not_found", "Trip not found.")
+		case errors.Is(err, repository.ErrTripIncompatible):
+			writeError(w, "trip_incompatible", "Both trips must be planned, go the same direction, and differ.")
+		default:
+			log.Printf("[handler] merge trips error: %v", err)
+			writeInternalError(w, err, "Internal server error.")
+		}
+		return
+	}
+
+	service.CountSurge(r.Context(), h.Counters, result.Surge)
+	log.Printf("[admin] Merged trip #%d into #%d (%d requests moved)", tripID, result.ToTripID, len(result.MovedRequests))
+	writeJSON(w, http.StatusOK, result)
+}
+
+// RidesInArea handles GET /api/v1/admin/rides/area
+//
+// Lists the ride requests whose origin lies in a bounding box, oldest
+// first, one page at a time.
+//
+// Query:
+//
+//	bbox=min_lon,min_lat,max_lon,max_lat   required; edges are included
+//	status=pending,matched                 default: pending, scheduled, matched, confirmed
+//	limit=100                              page size (default 100, capped at 500)
+//	offset=0                               requests to skip
+//
+// Response codes:
+//
+//	200  — One page of requests (requests, limit, offset, has_more)
+//	400  — Malformed bbox, limit or offset
+//	403  — Missing/invalid admin token
+//	422  — bbox missing, out of range or inverted, or unknown status
+//	500  — Unexpected error
+func (h *AdminHandler) RidesInArea(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var aq repository.AreaQuery
+	if !parseBBox(w, q.Get("bbox"), &aq) {
+		return
+	}
+	if !parsePaging(w, q, &aq.Limit, &aq.Offset) {
+		return
+	}
+	statuses, ok := parseRequestStatuses(w, q)
+	if !ok {
+		return
+	}
+	aq.Statuses = statuses
+
+	page, err := h.areas.RequestsInArea(r.Context(), aq)
+	if err != nil {
+		log.Printf("[handler] rides in area error: %v", err)
+		writeInternalError(w, err, "failed to search rides")
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// ReplayWebhook handles POST /api/v1/admin/webhooks/replay/{id}
+//
+// Delivers dead letter {id}, an outbox event the webhook refused
+// OUTBOX_WEBHOOK_MAX_ATTEMPTS times in a row, to the webhook again.
+//
+// Response codes:
+//   200  — Delivered (returns the dead letter, now with replayed_at)
+//   400  — Invalid id
+//   403  — Missing/invalid admin token
+//   404  — No such dead letter
+//   409  — Already replayed
+//   502  — The webhook refused it again (attempts and last_error updated)
+//   500  — Unexpected error
+func (h *AdminHandler) ReplayWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid dead letter id")
+		return
+	}
+
+	dl, err := h.replayer.Replay(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrDeadLetterNotFound):
+			writeError(w, "dead_letter_not_found", "Dead letter not found.")
+		case errors.Is(err, service.ErrAlreadyReplayed):
+			writeError(w, "already_replayed", "This dead letter was already delivered by an earlier replay.")
+		case errors.Is(err, service.ErrReplayFailed):
+			log.Printf("[handler] replay webhook: %v", err)
+			writeError(w, "replay_failed", "The webhook refused the event again.")
+		default:
+			log.Printf("[handler] replay webhook error: %v", err)
+			writeInternalError(w, err, "Internal server error.")
+		}
+		return
+	}
+
+	log.Printf("[admin] Replayed dead letter #%d (event #%d)", id, dl.Event.ID)
+	writeJSON(w, http.StatusOK, dl)
+}
+
+// parseBBox reads "min_lon,min_lat,max_lon,max_lat" into aq. Boxes across
+// the antimeridian are not supported. Returns false if a response was
+// written.
+func parseBBox(w http.ResponseWriter, raw string, aq *repository.AreaQuery) bool {
+	if raw == "" {
+		writeFieldError(w, "bbox", "bbox is required (min_lon,min_lat,max_lon,max_lat)")
+		return false
+	}
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		writeError(w, "bad_request", "invalid bbox: want min_lon,min_lat,max_lon,max_lat")
+		return false
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			writeError(w, "bad_request", "invalid bbox: coordinates must be numbers")
+			return false
+		}
+		v[i] = f
+	}
+	aq.MinLon, aq.MinLat, aq.MaxLon, aq.MaxLat = v[0], v[1], v[2], v[3]
+
+	for _, corner := range []model.Location{{Lat: aq.MinLat, Lon: aq.MinLon}, {Lat: aq.MaxLat, Lon: aq.MaxLon}} {
+		if !validateLocation(w, corner, "bbox", "bbox") {
+			return false
+		}
+	}
+	if aq.MinLon > aq.MaxLon || aq.MinLat > aq.MaxLat {
+		writeFieldError(w, "bbox", "min corner must not exceed max corner")
+		return false
+	}
+	return true
+}
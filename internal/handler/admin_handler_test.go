@@ -0,0 +1,398 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+// fakeTrips models trips by seat capacity and seats in use, and which trip
+// each matched request is on. Luggage is left out; CheckCapacity covers it.
+type fakeTrips struct {
+	capacity map[int64]int
+	used     map[int64]int
+	onTrip   map[int64]int64 // request → trip
+	seats    map[int64]int   // request → seats_needed
+}
+
+func (f *fakeTrips) ReassignRequest(_ context.Context, requestID, targetTripID int64) (*repository.ReassignResult, error) {
+	from := f.onTrip[requestID]
+	if err := model.CheckCapacity(f.capacity[targetTripID], 0, f.used[targetTripID], 0, f.seats[requestID], 0); err != nil {
+		return nil, fmt.Errorf("reassign: trip %d: %w", targetTripID, err)
+	}
+	f.used[from] -= f.seats[requestID]
+	f.used[targetTripID] += f.seats[requestID]
+	f.onTrip[requestID] = targetTripID
+	return &repository.ReassignResult{
+		RequestID:      requestID,
+		FromTripID:     from,
+		ToTripID:       targetTripID,
+		RemainingSeats: f.capacity[targetTripID] - f.used[targetTripID],
+	}, nil
+}
+
+func newFakeTrips() *fakeTrips {
+	return &fakeTrips{
+		capacity: map[int64]int{1: 4, 2: 4},
+		used:     map[int64]int{1: 4, 2: 1},
+		onTrip:   map[int64]int64{10: 1, 11: 1},
+		seats:    map[int64]int{10: 2, 11: 2},
+	}
+}
+
+func postReassign(h *AdminHandler, requestID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/requests/"+requestID+"/reassign", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"id": requestID})
+	rec := httptest.NewRecorder()
+	h.ReassignRequest(rec, req)
+	return rec
+}
+
+func TestReassignRequest_MovesPassenger(t *testing.T) {
+	trips := newFakeTrips()
+	h := &AdminHandler{bookingRepo: trips}
+
+	rec := postReassign(h, "10", `{"trip_id": 2}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+
+	var got repository.ReassignResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.FromTripID != 1 || got.ToTripID != 2 || got.RemainingSeats != 1 {
+		t.Errorf("result = %+v, want 1 → 2 with 1 seat left", got)
+	}
+	if trips.used[1] != 2 || trips.used[2] != 3 {
+		t.Errorf("seats used = trip1:%d trip2:%d, want 2 and 3", trips.used[1], trips.used[2])
+	}
+}
+
+func TestReassignRequest_TargetFull(t *testing.T) {
+	trips := newFakeTrips()
+	h := &AdminHandler{bookingRepo: trips}
+
+	// Fill trip 2 with the first move; the second no longer fits.
+	postReassign(h, "10", `{"trip_id": 2}`)
+	rec := postReassign(h, "11", `{"trip_id": 2}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422 (body: %s)", rec.Code, rec.Body.String())
+	}
+	if trips.onTrip[11] != 1 || trips.used[2] != 3 {
+		t.Errorf("request 11 on trip %d, trip 2 uses %d; want unchanged", trips.onTrip[11], trips.used[2])
+	}
+}
+
+func TestReassignRequest_MissingTripID(t *testing.T) {
+	h := &AdminHandler{bookingRepo: newFakeTrips()}
+	assertValidationResponse(t, postReassign(h, "10", `{}`), http.StatusUnprocessableEntity, "trip_id")
+}
+
+// fakeTripCanceller cancels the trips in riders, once each.
+type fakeTripCanceller struct {
+	riders map[int64][]int64 // trip → active request ids
+}
+
+func (f *fakeTripCanceller) CancelTrip(_ context.Context, tripID int64) (*repository.TripCancelResult, error) {
+	riders, ok := f.riders[tripID]
+	if !ok {
+		return nil, fmt.Errorf("cancel trip %d: %w", tripID, repository.ErrTripNotFound)
+	}
+	if riders == nil {
+		return nil, fmt.Errorf("cancel trip %d: %w", tripID, repository.ErrTripNotCancellable)
+	}
+	f.riders[tripID] = nil
+	return &repository.TripCancelResult{TripID: tripID, CabID: 3, CabFreed: true, CancelledRequests: riders}, nil
+}
+
+func postCancelTrip(h *AdminHandler, tripID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/trips/"+tripID+"/cancel", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": tripID})
+	rec := httptest.NewRecorder()
+	h.CancelTrip(rec, req)
+	return rec
+}
+
+func TestCancelTrip_CancelsAllRequests(t *testing.T) {
+	h := &AdminHandler{cancelSvc: &fakeTripCanceller{riders: map[int64][]int64{7: {10, 11, 12}}}}
+
+	rec := postCancelTrip(h, "7")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+	var got repository.TripCancelResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.TripID != 7 || !got.CabFreed || len(got.CancelledRequests) != 3 {
+		t.Errorf("result = %+v, want trip 7 with 3 requests cancelled and the cab freed", got)
+	}
+
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"7", http.StatusConflict, "not_cancellable"}, // already cancelled above
+		{"9", http.StatusNotFound, "trip_not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := postCancelTrip(h, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("trip %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// fakeMerger merges trips by seats in use against a 4-seat cab; trip 5 is
+// off everyone's route.
+type fakeMerger struct {
+	used map[int64]int // trip → seats in use
+}
+
+func (f *fakeMerger) MergeTrips(_ context.Context, fromTripID, intoTripID int64) (*repository.TripMergeResult, error) {
+	if _, ok := f.used[fromTripID]; !ok {
+		return nil, fmt.Errorf("merge trips: trip %d: %w", fromTripID, repository.ErrTripNotFound)
+	}
+	if fromTripID == 5 || intoTripID == 5 {
+		return nil, fmt.Errorf("merge trips: %w", repository.ErrMergeDetour)
+	}
+	if err := model.CheckCapacity(4, 0, f.used[intoTripID], 0, f.used[fromTripID], 0); err != nil {
+		return nil, fmt.Errorf("merge trips: trip %d: %w", intoTripID, err)
+	}
+	f.used[intoTripID] += f.used[fromTripID]
+	delete(f.used, fromTripID)
+	return &repository.TripMergeResult{
+		FromTripID: fromTripID, ToTripID: intoTripID, CabFreed: true,
+		MovedRequests: []int64{20}, RemainingSeats: 4 - f.used[intoTripID],
+	}, nil
+}
+
+func postMergeTrip(h *AdminHandler, tripID, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/trips/"+tripID+"/merge", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"id": tripID})
+	rec := httptest.NewRecorder()
+	h.MergeTrip(rec, req)
+	return rec
+}
+
+func TestMergeTrip_MovesRiders(t *testing.T) {
+	merger := &fakeMerger{used: map[int64]int{1: 1, 2: 2}}
+	h := &AdminHandler{merger: merger}
+
+	rec := postMergeTrip(h, "2", `{"into_trip_id": 1}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+	var got repository.TripMergeResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.FromTripID != 2 || got.ToTripID != 1 || !got.CabFreed || got.RemainingSeats != 1 {
+		t.Errorf("result = %+v, want 2 → 1 with the cab freed and 1 seat left", got)
+	}
+}
+
+func TestMergeTrip_Errors(t *testing.T) {
+	h := &AdminHandler{merger: &fakeMerger{used: map[int64]int{1: 3, 2: 2, 5: 1}}}
+
+	for _, tt := range []struct {
+		id, body string
+		want     int
+		wantCode string
+	}{
+		{"2", `{"into_trip_id": 1}`, http.StatusUnprocessableEntity, "target_trip_full"},
+		{"5", `{"into_trip_id": 1}`, http.StatusUnprocessableEntity, "detour_too_long"},
+		{"9", `{"into_trip_id": 1}`, http.StatusNotFound, "trip_not_found"},
+		{"2", `{}`, http.StatusUnprocessableEntity, "validation_failed"},
+		{"abc", `{"into_trip_id": 1}`, http.StatusBadRequest, "bad_request"},
+	} {
+		rec := postMergeTrip(h, tt.id, tt.body)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("trip %s %s: got %d %q, want %d %q", tt.id, tt.body, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+func TestCancelTrip_DatabaseDownIs503(t *testing.T) {
+	down := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	h := &AdminHandler{cancelSvc: failingCanceller{fmt.Errorf("cancel trip: begin tx: %w", down)}}
+
+	rec := postCancelTrip(h, "7")
+	if body := decodeAPIError(t, rec); rec.Code != http.StatusServiceUnavailable || body.Code != "service_unavailable" {
+		t.Fatalf("got %d %q, want 503 service_unavailable", rec.Code, body.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Errorf("Retry-After = %q, want 5", got)
+	}
+
+	// A query error on a live connection stays a 500.
+	h.cancelSvc = failingCanceller{errors.New("cancel trip 7: update trip: syntax error")}
+	rec = postCancelTrip(h, "7")
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("Retry-After") != "" {
+		t.Errorf("query error: status = %d, Retry-After = %q; want 500 without", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+// failingCanceller fails every CancelTrip with err.
+type failingCanceller struct{ err error }
+
+func (f failingCanceller) CancelTrip(context.Context, int64) (*repository.TripCancelResult, error) {
+	return nil, f.err
+}
+
+// fakeAreas holds ride requests and answers area queries by filtering them
+// on origin, like the PostGIS envelope test.
+type fakeAreas struct {
+	requests []model.RideRequest
+	last     repository.AreaQuery
+}
+
+func (f *fakeAreas) RequestsInArea(_ context.Context, q repository.AreaQuery) (*repository.AreaPage, error) {
+	f.last = q
+	page := &repository.AreaPage{Requests: []model.RideRequest{}, Limit: q.Limit, Offset: q.Offset}
+	for _, rr := range f.requests {
+		o := rr.Origin
+		if o.Lon >= q.MinLon && o.Lon <= q.MaxLon && o.Lat >= q.MinLat && o.Lat <= q.MaxLat {
+			page.Requests = append(page.Requests, rr)
+		}
+	}
+	return page, nil
+}
+
+func getRidesInArea(h *AdminHandler, query string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/rides/area?"+query, nil)
+	rec := httptest.NewRecorder()
+	h.RidesInArea(rec, req)
+	return rec
+}
+
+func TestRidesInArea_InsideAndOutside(t *testing.T) {
+	areas := &fakeAreas{requests: []model.RideRequest{
+		{ID: 1, Origin: model.Location{Lat: 28.60, Lon: 77.20}}, // inside
+		{ID: 2, Origin: model.Location{Lat: 28.70, Lon: 77.10}}, // on the corner
+		{ID: 3, Origin: model.Location{Lat: 19.07, Lon: 72.87}}, // outside
+	}}
+	h := &AdminHandler{areas: areas}
+
+	rec := getRidesInArea(h, "bbox=77.1,28.5,77.3,28.7&status=pending,matched&limit=20&offset=5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+	var page repository.AreaPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	var ids []int64
+	for _, rr := range page.Requests {
+		ids = append(ids, rr.ID)
+	}
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Errorf("requests = %v, want [1 2]", ids)
+	}
+	want := repository.AreaQuery{
+		MinLon: 77.1, MinLat: 28.5, MaxLon: 77.3, MaxLat: 28.7,
+		Statuses: []model.RequestStatus{model.RequestPending, model.RequestMatched},
+		Limit:    20, Offset: 5,
+	}
+	if fmt.Sprint(areas.last) != fmt.Sprint(want) {
+		t.Errorf("query = %+v, want %+v", areas.last, want)
+	}
+}
+
+func TestRidesInArea_BadBBox(t *testing.T) {
+	h := &AdminHandler{areas: &fakeAreas{}}
+	for _, tt := range []struct {
+		query    string
+		wantCode int
+	}{
+		{"", http.StatusUnprocessableEntity},
+		{"bbox=77.1,28.5,77.3", http.StatusBadRequest},
+		{"bbox=77.1,north,77.3,28.7", http.StatusBadRequest},
+		{"bbox=77.3,28.5,77.1,28.7", http.StatusUnprocessableEntity},
+		{"bbox=77.1,28.5,77.3,95", http.StatusUnprocessableEntity},
+	} {
+		rec := getRidesInArea(h, tt.query)
+		if rec.Code != tt.wantCode {
+			t.Errorf("%q: status = %d, want %d (body: %s)", tt.query, rec.Code, tt.wantCode, rec.Body.String())
+			continue
+		}
+		if tt.wantCode == http.StatusUnprocessableEntity {
+			assertValidationResponse(t, rec, tt.wantCode, "bbox")
+		}
+	}
+}
+
+// fakeReplayer replays dead letter 1, has already replayed 2, and finds
+// the webhook still down for 3.
+type fakeReplayer struct{}
+
+func (fakeReplayer) Replay(_ context.Context, id int64) (*model.WebhookDeadLetter, error) {
+	switch id {
+	case 1:
+		now := time.Now()
+		return &model.WebhookDeadLetter{ID: 1, Event: model.OutboxEvent{ID: 40}, Attempts: 4, ReplayedAt: &now}, nil
+	case 2:
+		return nil, fmt.Errorf("outbox: replay 2: %w", service.ErrAlreadyReplayed)
+	case 3:
+		return nil, fmt.Errorf("outbox: replay 3 (event 41): %w: webhook returned 500", service.ErrReplayFailed)
+	}
+	return nil, fmt.Errorf("outbox: dead letter %d: %w", id, repository.ErrDeadLetterNotFound)
+}
+
+func postReplayWebhook(h *AdminHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/webhooks/replay/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.ReplayWebhook(rec, req)
+	return rec
+}
+
+func TestReplayWebhook_Delivered(t *testing.T) {
+	rec := postReplayWebhook(&AdminHandler{replayer: fakeReplayer{}}, "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+	var got model.WebhookDeadLetter
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Event.ID != 40 || got.ReplayedAt == nil {
+		t.Errorf("dead letter = %+v, want event 40 replayed", got)
+	}
+}
+
+func TestReplayWebhook_Errors(t *testing.T) {
+	h := &AdminHandler{replayer: fakeReplayer{}}
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"2", http.StatusConflict, "already_replayed"},
+		{"3", http.StatusBadGateway, "replay_failed"},
+		{"9", http.StatusNotFound, "dead_letter_not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := postReplayWebhook(h, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("dead letter %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
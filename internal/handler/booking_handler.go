@@ -1,90 +1,245 @@
 package handler
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
 	"github.com/shiva/hintro/internal/service"
 )
 
 // BookingHandler handles booking HTTP requests.
 type BookingHandler struct {
-	bookingSvc *service.BookingService
+	bookingSvc rideBooker
+	tokens     *service.BookingTokens // nil: confirmation tokens are off.
+	statuses   bookingStatusReader
 }
 
-// NewBookingHandler creates a new booking handler.
-func NewBookingHandler(bookingSvc *service.BookingService) *BookingHandler {
-	return &BookingHandler{bookingSvc: bookingSvc}
+// bookingStatusReader is the part of RideRequestRepository used by VerifyBooking.
+type bookingStatusReader interface {
+	GetRideStatus(ctx context.Context, id int64) (*repository.RideStatus, error)
 }
 
-// BookRide handles POST /api/v1/book/{request_id}
+// NewBookingHandler creates a new booking handler. tokens signs and checks
+// booking confirmation tokens (nil turns them off); statuses tells
+// VerifyBooking whether a booking still stands.
+func NewBookingHandler(bookingSvc *service.BookingService, tokens *service.BookingTokens, statuses bookingStatusReader) *BookingHandler {
+	return &BookingHandler{bookingSvc: bookingSvc, tokens: tokens, statuses: statuses}
+}
+
+// BookingResponse is a successful booking plus, when tokens are on, the
+// confirmation token the rider shows the driver.
+type BookingResponse struct {
+	*repository.BookingResult
+	ConfirmationToken     string     `json:"confirmation_token,omitempty"`
+	ConfirmationExpiresAt *time.Time `json:"confirmation_expires_at,omitempty"`
+}
+
+// MaxBookingTimeout caps the per-request ?timeout_ms override so a caller
+// cannot hold a cab row lock indefinitely.
+const MaxBookingTimeout = 30 * time.Second
+
+// BookRide handles POST /api/v1/book/{request_id}[?timeout_ms=N][&max_fare_cents=N]
 //
 // Attempts to book a ride for the given request. If a compatible trip exists,
 // the passenger is added to it. Otherwise, a new trip is created.
 //
+// timeout_ms optionally overrides the configured booking timeout (e.g. a
+// batch client may allow longer, an interactive one shorter), up to
+// MaxBookingTimeout.
+//
+// max_fare_cents optionally refuses the booking if the quoted fare (after
+// any pool discount) is above it.
+//
+// Booking a request that is already booked (a double-submit or a retry)
+// returns its existing booking again rather than booking it twice.
+//
 // Response codes:
-//   200  — Booking successful (returns booking details)
-//   400  — Invalid request_id
+//   200  — Booking successful, or already booked (returns booking details)
+//   400  — Invalid request_id, timeout_ms or max_fare_cents
 //   404  — Ride request not found
-//   409  — Request already booked / not in pending state
-//   422  — Cab full (capacity exceeded) or no cab available
-//   408  — Booking timed out (lock contention)
+//   409  — Request cancelled, expired or otherwise not bookable, fare above max_fare_cents,
+//          or trip direction does not match the request
+//   422  — Cab full (capacity exceeded; body has remaining_seats and
+//          remaining_luggage) or no cab available
+//   429  — Booking timed out (lock contention); Retry-After is 1–3 s, jittered
 //   500  — Unexpected error
 func (h *BookingHandler) BookRide(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	requestID, err := strconv.ParseInt(vars["request_id"], 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid request_id: must be an integer",
-		})
+		writeError(w, "bad_request", "invalid request_id: must be an integer")
 		return
 	}
+	h.book(w, r, requestID)
+}
 
-	result, err := h.bookingSvc.BookRide(r.Context(), requestID)
+// Fulfill handles POST /api/v1/rides/{id}/fulfill[?timeout_ms=N][&max_fare_cents=N]
+//
+// Matches and books the ride in one call: it is BookRide under the ride's
+// own path, for clients that would otherwise call /match and then /book.
+// BookRide already joins a compatible trip or starts a new one, so the
+// response's pooled field says which happened. Query parameters, response
+// body and error codes are those of BookRide.
+func (h *BookingHandler) Fulfill(w http.ResponseWriter, r *http.Request) {
+	requestID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrCabFull):
-			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{
-				"error":   "cab_full",
-				"message": "The cab has no remaining capacity. Try again for another cab.",
-			})
-		case errors.Is(err, service.ErrBookingTimeout):
-			writeJSON(w, http.StatusRequestTimeout, map[string]string{
-				"error":   "booking_timeout",
-				"message": "Booking timed out due to high contention. Please retry.",
-			})
-		case errors.Is(err, service.ErrRequestNotPending):
-			writeJSON(w, http.StatusConflict, map[string]string{
-				"error":   "not_pending",
-				"message": "This ride request is not in a bookable state.",
-			})
-		case errors.Is(err, service.ErrCabNotAvailable):
-			writeJSON(w, http.StatusUnprocessableEntity, map[string]string{
-				"error":   "cab_unavailable",
-				"message": "The assigned cab is no longer available.",
-			})
-		case errors.Is(err, service.ErrNoCabNearby):
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error":   "no_cab",
-				"message": "No available cab found near your pickup location.",
-			})
-		case errors.Is(err, service.ErrRequestNotFound):
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error":   "not_found",
-				"message": "Ride request not found.",
-			})
-		default:
-			log.Printf("[handler] booking error: %v", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{
-				"error": "internal_error",
-			})
+		writeError(w, "bad_request", "invalid ride id")
+		return
+	}
+	h.book(w, r, requestID)
+}
+
+// book reads BookRide's query options, books requestID and writes the
+// result or the mapped error.
+func (h *BookingHandler) book(w http.ResponseWriter, r *http.Request, requestID int64) {
+	var opts service.BookingOptions
+	if raw := r.URL.Query().Get("timeout_ms"); raw != "" {
+		ms, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || ms <= 0 || ms > MaxBookingTimeout.Milliseconds() {
+			writeError(w, "bad_request", "invalid timeout_ms: must be a positive integer no greater than 30000")
+			return
+		}
+		opts.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	if raw := r.URL.Query().Get("max_fare_cents"); raw != "" {
+		cents, err := strconv.Atoi(raw)
+		if err != nil || cents <= 0 {
+			writeError(w, "bad_request", "invalid max_fare_cents: must be a positive integer")
+			return
 		}
+		opts.MaxFareCents = cents
+	}
+
+	result, err := h.bookingSvc.BookRide(r.Context(), requestID, opts)
+	if err != nil {
+		writeBookingError(w, err)
 		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	writeJSON(w, http.StatusOK, h.bookingResponse(result))
+}
+
+// bookingResponse attaches a confirmation token to result when tokens are on.
+func (h *BookingHandler) bookingResponse(result *repository.BookingResult) BookingResponse {
+	resp := BookingResponse{BookingResult: result}
+	if h.tokens != nil {
+		token, claims := h.tokens.Issue(result)
+		resp.ConfirmationToken, resp.ConfirmationExpiresAt = token, &claims.ExpiresAt
+	}
+	return resp
+}
+
+// VerifyBooking handles GET /api/v1/book/verify?token=
+//
+// Checks a booking confirmation token for the driver: the signature, the
+// expiry, and that the rider is still booked on the trip it names.
+//
+// Response codes:
+//   200  — Valid: {request_id, trip_id, cab_id, seats, expires_at}
+//   400  — No token given
+//   404  — Confirmation tokens are not enabled
+//   409  — The booking was cancelled or moved to another trip
+//   422  — Token tampered with or malformed (invalid_token), or expired (token_expired)
+//   500  — Unexpected error
+func (h *BookingHandler) VerifyBooking(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		writeError(w, "not_found", "Booking confirmation tokens are not enabled.")
+		return
+	}
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		writeError(w, "bad_request", "token is required")
+		return
+	}
+
+	claims, err := h.tokens.Verify(token)
+	switch {
+	case errors.Is(err, service.ErrTokenExpired):
+		writeError(w, "token_expired", "This booking confirmation has expired.")
+		return
+	case err != nil:
+		writeError(w, "invalid_token", "This booking confirmation is not valid.")
+		return
+	}
+
+	st, err := h.statuses.GetRideStatus(r.Context(), claims.RequestID)
+	if err != nil && !errors.Is(err, repository.ErrRequestNotFound) {
+		log.Printf("[handler] verify booking error: %v", err)
+		writeInternalError(w, err, "failed to load booking")
+		return
+	}
+	if err != nil || !bookingStands(st, claims.TripID) {
+		writeError(w, "booking_not_active", "This booking has been cancelled or moved to another trip.")
+		return
+	}
+	writeJSON(w, http.StatusOK, claims)
+}
+
+// bookingStands reports whether a rider in state st is still booked on tripID.
+func bookingStands(st *repository.RideStatus, tripID int64) bool {
+	booked := st.Status == model.RequestMatched || st.Status == model.RequestConfirmed
+	return booked && st.TripID != nil && *st.TripID == tripID
+}
+
+// writeBookingError maps a BookingService.BookRide error to an HTTP response.
+// cab_full includes remaining_seats and remaining_luggage when known, and
+// suggestions (other trips with luggage or detour relaxed) when there are any.
+func writeBookingError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCabFull):
+		apiErr := APIError{
+			Code:    "cab_full",
+			Message: "The cab has no remaining capacity. Try again for another cab.",
+		}
+		var capErr *model.CapacityError
+		if errors.As(err, &capErr) {
+			apiErr.Message = fmt.Sprintf("The cab has %d seat(s) and %d luggage slot(s) left. Book fewer, or try again for another cab.",
+				capErr.RemainingSeats, capErr.RemainingLuggage)
+			apiErr.Details = map[string]interface{}{
+				"remaining_seats":   capErr.RemainingSeats,
+				"remaining_luggage": capErr.RemainingLuggage,
+			}
+		}
+		var full *service.CabFullError
+		if errors.As(err, &full) {
+			if apiErr.Details == nil {
+				apiErr.Details = map[string]interface{}{}
+			}
+			apiErr.Details["suggestions"] = full.Suggestions
+		}
+		writeAPIError(w, apiErr)
+	case errors.Is(err, service.ErrTripPassengerLimit):
+		writeError(w, "trip_full", "The trip has reached the pooling cap on passengers per trip (MATCH_MAX_PASSENGERS_PER_TRIP), though its cab may have free seats. Try again for another cab.")
+	case errors.Is(err, service.ErrBookingTimeout):
+		w.Header().Set("Retry-After", contentionRetryAfter())
+		writeError(w, "booking_timeout", "Booking timed out due to high contention. Please retry after the Retry-After delay.")
+	case errors.Is(err, service.ErrFareAboveCap):
+		writeError(w, "fare_above_cap", "The current fare is above your max_fare_cents. Nothing was booked.")
+	case errors.Is(err, service.ErrRequestNotPending):
+		writeError(w, "not_pending", "This ride request is not in a bookable state.")
+	case errors.Is(err, service.ErrRequestScheduled):
+		writeError(w, "scheduled", "This ride request is scheduled too far ahead to book yet.")
+	case errors.Is(err, service.ErrDirectionMismatch):
+		writeError(w, "direction_mismatch", "The trip travels in the opposite direction to this ride request.")
+	case errors.Is(err, service.ErrCabNotAccessible):
+		writeError(w, "cab_not_accessible", "This ride request needs a wheelchair-accessible cab and the cab is not equipped.")
+	case errors.Is(err, service.ErrCabNotAvailable):
+		writeError(w, "cab_unavailable", "The assigned cab is no longer available.")
+	case errors.Is(err, service.ErrNoCabNearby):
+		writeError(w, "no_cab", "No available cab found near your pickup location.")
+	case errors.Is(err, service.ErrRequestNotFound):
+		writeError(w, "not_found", "Ride request not found.")
+	default:
+		log.Printf("[handler] booking error: %v", err)
+		writeInternalError(w, err, "Internal server error.")
+	}
 }
@@ -0,0 +1,263 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+func TestBookRide_RejectsInvalidTimeout(t *testing.T) {
+	h := NewBookingHandler(nil, nil, nil)
+
+	for _, q := range []string{"abc", "0", "-5", "30001"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/book/1?timeout_ms="+q, nil)
+		req = mux.SetURLVars(req, map[string]string{"request_id": "1"})
+		rec := httptest.NewRecorder()
+
+		h.BookRide(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("timeout_ms=%s: status = %d, want 400", q, rec.Code)
+		}
+	}
+}
+
+func TestBookRide_RejectsInvalidMaxFare(t *testing.T) {
+	h := NewBookingHandler(nil, nil, nil)
+
+	for _, q := range []string{"abc", "0", "-100"} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/book/1?max_fare_cents="+q, nil)
+		req = mux.SetURLVars(req, map[string]string{"request_id": "1"})
+		rec := httptest.NewRecorder()
+
+		h.BookRide(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("max_fare_cents=%s: status = %d, want 400", q, rec.Code)
+		}
+	}
+}
+
+func fulfill(b *fakeBooker, id string) *httptest.ResponseRecorder {
+	h := &BookingHandler{bookingSvc: b}
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rides/"+id+"/fulfill", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.Fulfill(rec, req)
+	return rec
+}
+
+func TestFulfill_JoinsMatchedTrip(t *testing.T) {
+	b := &fakeBooker{pool: &model.MatchResult{TripID: 7, CabID: 3}}
+	rec := fulfill(b, "42")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got BookingResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.BookingResult == nil || got.TripID != 7 || got.CabID != 3 || !got.Pooled {
+		t.Errorf("result = %+v, want pooled onto trip 7 (cab 3)", got.BookingResult)
+	}
+	if len(b.got) != 1 || b.got[0] != 42 {
+		t.Errorf("booked %v, want request 42 once", b.got)
+	}
+}
+
+func TestFulfill_StartsNewTrip(t *testing.T) {
+	rec := fulfill(&fakeBooker{}, "42")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got BookingResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.BookingResult == nil || got.TripID != 99 || got.Pooled {
+		t.Errorf("result = %+v, want new trip 99, not pooled", got.BookingResult)
+	}
+}
+
+func TestFulfill_MapsBookingErrors(t *testing.T) {
+	tests := []struct {
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{service.ErrRequestNotFound, http.StatusNotFound, "not_found"},
+		{service.ErrRequestNotPending, http.StatusConflict, "not_pending"},
+		{service.ErrNoCabNearby, http.StatusNotFound, "no_cab"},
+		{service.ErrBookingTimeout, http.StatusTooManyRequests, "booking_timeout"},
+	}
+	for _, tt := range tests {
+		rec := fulfill(&fakeBooker{err: tt.err}, "42")
+		if rec.Code != tt.wantStatus {
+			t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.wantStatus)
+			continue
+		}
+		if got := decodeAPIError(t, rec); got.Code != tt.wantCode {
+			t.Errorf("%v: code = %q, want %q", tt.err, got.Code, tt.wantCode)
+		}
+	}
+
+	if rec := fulfill(&fakeBooker{}, "abc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad id: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestWriteBookingError_CabFullReportsRemaining(t *testing.T) {
+	capErr := &model.CapacityError{Limit: "seats", Remaining: 1, Need: 3, RemainingSeats: 1, RemainingLuggage: 2}
+	err := fmt.Errorf("%w: %w", service.ErrCabFull, capErr)
+
+	rec := httptest.NewRecorder()
+	writeBookingError(rec, err)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	body := decodeAPIError(t, rec)
+	if body.Code != "cab_full" || body.Details["remaining_seats"] != 1.0 || body.Details["remaining_luggage"] != 2.0 {
+		t.Errorf("body = %v, want cab_full with 1 seat and 2 bags remaining", body)
+	}
+}
+
+func TestWriteBookingError_CabFullIncludesSuggestions(t *testing.T) {
+	capErr := &model.CapacityError{Limit: "luggage", Remaining: 0, Need: 2, RemainingSeats: 2, RemainingLuggage: 0}
+	bags := 1
+	err := &service.CabFullError{
+		Err:         fmt.Errorf("%w: %w", service.ErrCabFull, capErr),
+		Suggestions: []service.Suggestion{{Kind: service.SuggestFewerLuggage, TripID: 9, CabID: 4, LuggageCount: &bags}},
+	}
+
+	rec := httptest.NewRecorder()
+	writeBookingError(rec, err)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	body := decodeAPIError(t, rec)
+	if body.Code != "cab_full" || body.Details["remaining_seats"] != 2.0 {
+		t.Errorf("body = %v, want cab_full with remaining capacity", body)
+	}
+	suggestions, _ := body.Details["suggestions"].([]interface{})
+	if len(suggestions) != 1 {
+		t.Fatalf("suggestions = %v, want one", body.Details["suggestions"])
+	}
+	sg := suggestions[0].(map[string]interface{})
+	if sg["kind"] != "fewer_luggage" || sg["trip_id"] != 9.0 || sg["luggage_count"] != 1.0 {
+		t.Errorf("suggestion = %v, want fewer_luggage on trip 9 with 1 bag", sg)
+	}
+}
+
+func TestWriteBookingError_CabFullWithoutDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeBookingError(rec, service.ErrCabFull)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422", rec.Code)
+	}
+	if body := decodeAPIError(t, rec); body.Details != nil {
+		t.Errorf("body = %v, want no remaining_seats when capacity is unknown", body)
+	}
+}
+
+func TestWriteBookingError_PassengerLimit(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeBookingError(rec, service.ErrTripPassengerLimit)
+
+	if body := decodeAPIError(t, rec); rec.Code != http.StatusUnprocessableEntity || body.Code != "trip_full" {
+		t.Errorf("got %d %q, want 422 trip_full", rec.Code, body.Code)
+	}
+}
+
+func TestWriteBookingError_TimeoutSendsJitteredRetryAfter(t *testing.T) {
+	lo, hi := int(ContentionRetryAfterMin/time.Second), int(ContentionRetryAfterMax/time.Second)
+	seen := map[int]bool{}
+	for i := 0; i < 200; i++ {
+		rec := httptest.NewRecorder()
+		writeBookingError(rec, service.ErrBookingTimeout)
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", rec.Code)
+		}
+		secs, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+		if err != nil || secs < lo || secs > hi {
+			t.Fatalf("Retry-After = %q, want whole seconds in [%d, %d]", rec.Header().Get("Retry-After"), lo, hi)
+		}
+		seen[secs] = true
+	}
+	if len(seen) < 2 {
+		t.Errorf("Retry-After was always %v over 200 responses, want it jittered", seen)
+	}
+}
+
+func verifyBooking(h *BookingHandler, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/book/verify?token="+url.QueryEscape(token), nil)
+	rec := httptest.NewRecorder()
+	h.VerifyBooking(rec, req)
+	return rec
+}
+
+func TestVerifyBooking(t *testing.T) {
+	tokens := service.NewBookingTokens([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	tripID := int64(9)
+	statuses := fakeStatuses{17: {Status: model.RequestMatched, TripID: &tripID}}
+	h := NewBookingHandler(nil, tokens, statuses)
+
+	resp := h.bookingResponse(&repository.BookingResult{TripID: 9, CabID: 4, RequestID: 17, SeatsBooked: 2})
+	if resp.ConfirmationToken == "" || resp.ConfirmationExpiresAt == nil {
+		t.Fatalf("response = %+v, want a confirmation token and expiry", resp)
+	}
+
+	rec := verifyBooking(h, resp.ConfirmationToken)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("valid token: status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var claims service.BookingClaims
+	if err := json.NewDecoder(rec.Body).Decode(&claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.RequestID != 17 || claims.TripID != 9 || claims.Seats != 2 {
+		t.Errorf("claims = %+v, want request 17 on trip 9 with 2 seats", claims)
+	}
+
+	tampered := resp.ConfirmationToken[:len(resp.ConfirmationToken)-1] + "A"
+	if strings.HasSuffix(resp.ConfirmationToken, "A") {
+		tampered = resp.ConfirmationToken[:len(resp.ConfirmationToken)-1] + "B"
+	}
+	for _, tt := range []struct {
+		name     string
+		h        *BookingHandler
+		token    string
+		wantCode string
+	}{
+		{"tampered", h, tampered, "invalid_token"},
+		{"missing", h, "", "bad_request"},
+		{"disabled", NewBookingHandler(nil, nil, statuses), resp.ConfirmationToken, "not_found"},
+	} {
+		if got := decodeAPIError(t, verifyBooking(tt.h, tt.token)); got.Code != tt.wantCode {
+			t.Errorf("%s: code = %q, want %q", tt.name, got.Code, tt.wantCode)
+		}
+	}
+
+	statuses[17].Status = model.RequestCancelled
+	rec = verifyBooking(h, resp.ConfirmationToken)
+	if got := decodeAPIError(t, rec); rec.Code != http.StatusConflict || got.Code != "booking_not_active" {
+		t.Errorf("cancelled booking: got %d %q, want 409 booking_not_active", rec.Code, got.Code)
+	}
+}
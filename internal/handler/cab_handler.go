@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+// MaxLocationBatch caps the reports accepted by one bulk location call.
+const MaxLocationBatch = 5000
+
+// cabStore is the part of CabRepository used by CabHandler.
+type cabStore interface {
+	DeleteCab(ctx context.Context, cabID int64) ([]repository.SurgeChange, error)
+	UpdateCapacity(ctx context.Context, cabID int64, seats, luggage *int) (*model.Cab, error)
+	UpdateLocations(ctx context.Context, updates []model.CabLocationUpdate) (*repository.LocationBatchResult, error)
+}
+
+// CabHandler handles cab management HTTP requests.
+type CabHandler struct {
+	repo cabStore
+
+	// Counters, when set, is told about available cabs that are deleted or
+	// drive into another surge cell.
+	Counters service.SurgeCounter
+}
+
+// NewCabHandler creates a new cab handler.
+func NewCabHandler(repo *repository.CabRepository) *CabHandler {
+	return &CabHandler{repo: repo}
+}
+
+// DeleteCab handles DELETE /api/v1/admin/cabs/{id}
+//
+// Soft-deletes the cab so it is no longer offered for new trips.
+//
+// Response codes:
+//   200  — Cab deleted
+//   400  — Invalid cab id
+//   404  — Cab not found (or already deleted)
+//   409  — Cab still has a planned or in-progress trip
+//   500  — Unexpected error
+func (h *CabHandler) DeleteCab(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid cab id")
+		return
+	}
+
+	surge, err := h.repo.DeleteCab(r.Context(), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrCabNotFound):
+			writeError(w, "not_found", "Cab not found.")
+		case errors.Is(err, repository.ErrCabHasActiveTrips):
+			writeError(w, "cab_has_active_trips", "The cab has a planned or in-progress trip. Complete or cancel it first.")
+		default:
+			log.Printf("[handler] delete cab error: %v", err)
+			writeInternalError(w, err, "failed to delete cab")
+		}
+		return
+	}
+	service.CountSurge(r.Context(), h.Counters, surge)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status": "deleted",
+		"cab_id": id,
+	})
+}
+
+// UpdateCapacityBody is the JSON body for PATCH /api/v1/admin/cabs/{id}/capacity.
+// Omitted fields keep their current value.
+type UpdateCapacityBody struct {
+	SeatCapacity    *int `json:"seat_capacity"`
+	LuggageCapacity *int `json:"luggage_capacity"`
+}
+
+// UpdateCapacity handles PATCH /api/v1/admin/cabs/{id}/capacity
+//
+// Admin only. Re-seats a cab. Lowering capacity is refused while a planned or
+// in-progress trip on the cab carries more than the new capacity holds.
+//
+// Response codes:
+//   200  — Updated cab
+//   400  — Invalid cab id or malformed body
+//   403  — Missing/invalid admin token
+//   404  — Cab not found (or deleted)
+//   409  — An active trip's load would no longer fit (capacity_below_load)
+//   422  — Capacity out of range, or neither field given
+//   500  — Unexpected error
+func (h *CabHandler) UpdateCapacity(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid cab id")
+		return
+	}
+	var body UpdateCapacityBody
+	if err := decodeJSON(r, &body); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
+		return
+	}
+
+	if body.SeatCapacity == nil && body.LuggageCapacity == nil {
+		writeFieldError(w, "seat_capacity", "seat_capacity or luggage_capacity is required")
+		return
+	}
+	if s := body.SeatCapacity; s != nil && (*s < model.MinSeatsPerCab || *s > model.MaxSeatsPerCab) {
+		writeFieldError(w, "seat_capacity", fmt.Sprintf("must be between %d and %d", model.MinSeatsPerCab, model.MaxSeatsPerCab))
+		return
+	}
+	if l := body.LuggageCapacity; l != nil && (*l < model.MinLuggagePerCab || *l > model.MaxLuggagePerCab) {
+		writeFieldError(w, "luggage_capacity", fmt.Sprintf("must be between %d and %d", model.MinLuggagePerCab, model.MaxLuggagePerCab))
+		return
+	}
+
+	cab, err := h.repo.UpdateCapacity(r.Context(), id, body.SeatCapacity, body.LuggageCapacity)
+	if err != nil {
+		var overfull *repository.CapacityBelowLoadError
+		switch {
+		case errors.Is(err, repository.ErrCabNotFound):
+			writeError(w, "not_found", "Cab not found.")
+		case errors.As(err, &overfull):
+			writeAPIError(w, APIError{
+				Code:    "capacity_below_load",
+				Message: fmt.Sprintf("Trip %d on this cab carries %d seats and %d bags, more than the new capacity holds.", overfull.TripID, overfull.Seats, overfull.Luggage),
+				Details: map[string]interface{}{
+					"trip_id":         overfull.TripID,
+					"current_load":    overfull.Seats,
+					"current_luggage": overfull.Luggage,
+				},
+			})
+		default:
+			log.Printf("[handler] update cab capacity error: %v", err)
+			writeInternalError(w, err, "failed to update cab capacity")
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cab)
+}
+
+// UpdateLocations handles POST /api/v1/admin/cabs/locations
+//
+// Bulk telematics ingestion: one call carries many cabs' positions and is
+// applied in a single database round trip.
+//
+// Request body:
+//
+//	[
+//	  {"cab_id": 1, "lat": 28.7041, "lon": 77.1025, "ts": "2025-01-01T10:00:00Z"},
+//	  {"cab_id": 2, "lat": 28.6500, "lon": 77.1000, "ts": "2025-01-01T10:00:01Z"}
+//	]
+//
+// Reports not newer than the cab's stored fix are skipped and listed in
+// "rejected" (reason "stale"), as are unknown cabs and reports dated more
+// than repository.LocationClockSkew ahead ("future"). That is still a 200:
+// telematics retries are expected to be out of order.
+func (h *CabHandler) UpdateLocations(w http.ResponseWriter, r *http.Request) {
+	var updates []model.CabLocationUpdate
+	if err := decodeJSON(r, &updates); err != nil {
+		writeBodyError(w, err, "invalid JSON body: expected an array of {cab_id, lat, lon, ts}")
+		return
+	}
+
+	if len(updates) == 0 || len(updates) > MaxLocationBatch {
+		writeFieldError(w, "locations", fmt.Sprintf("must contain between 1 and %d reports", MaxLocationBatch))
+		return
+	}
+	for i, u := range updates {
+		prefix := fmt.Sprintf("[%d].", i)
+		if u.CabID <= 0 {
+			writeFieldError(w, prefix+"cab_id", "is required")
+			return
+		}
+		if !validateLocation(w, model.Location{Lat: u.Lat, Lon: u.Lon}, prefix+"lat", prefix+"lon") {
+			return
+		}
+		if u.TS.IsZero() {
+			writeFieldError(w, prefix+"ts", "is required")
+			return
+		}
+	}
+
+	result, err := h.repo.UpdateLocations(r.Context(), updates)
+	if err != nil {
+		log.Printf("[handler] bulk location error: %v", err)
+		writeInternalError(w, err, "failed to update cab locations")
+		return
+	}
+	service.CountSurge(r.Context(), h.Counters, result.Surge)
+
+	writeJSON(w, http.StatusOK, result)
+}
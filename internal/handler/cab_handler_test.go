@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/middleware"
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// fakeCabs models cab rows with their count of non-terminal trips, the
+// timestamp of their stored location fix, and their capacity with the
+// seats and bags booked on their active trip.
+type fakeCabs struct {
+	activeTrips map[int64]int
+	deleted     map[int64]bool
+	fixedAt     map[int64]time.Time
+	capacity    map[int64]model.CabCapacity
+	load        map[int64][2]int
+}
+
+func (f *fakeCabs) UpdateCapacity(_ context.Context, cabID int64, seats, luggage *int) (*model.Cab, error) {
+	next, ok := f.capacity[cabID]
+	if !ok {
+		return nil, fmt.Errorf("update cab %d capacity: %w", cabID, repository.ErrCabNotFound)
+	}
+	if seats != nil {
+		next.Seats = *seats
+	}
+	if luggage != nil {
+		next.Luggage = *luggage
+	}
+	if l := f.load[cabID]; next.Check(l[0], l[1], 0, 0) != nil {
+		return nil, fmt.Errorf("update cab %d capacity: %w", cabID, &repository.CapacityBelowLoadError{TripID: 40, Seats: l[0], Luggage: l[1]})
+	}
+	f.capacity[cabID] = next
+	return &model.Cab{ID: cabID, SeatCapacity: next.Seats, LuggageCapacity: next.Luggage}, nil
+}
+
+func (f *fakeCabs) UpdateLocations(_ context.Context, updates []model.CabLocationUpdate) (*repository.LocationBatchResult, error) {
+	result := &repository.LocationBatchResult{Rejected: []repository.LocationRejection{}}
+	for _, u := range updates {
+		prev, ok := f.fixedAt[u.CabID]
+		switch {
+		case !ok:
+			result.Rejected = append(result.Rejected, repository.LocationRejection{CabID: u.CabID, Reason: repository.LocationRejectUnknownCab})
+		case !u.TS.After(prev):
+			result.Rejected = append(result.Rejected, repository.LocationRejection{CabID: u.CabID, Reason: repository.LocationRejectStale})
+		default:
+			f.fixedAt[u.CabID] = u.TS
+			result.Updated++
+		}
+	}
+	return result, nil
+}
+
+// DeleteCab reports every deleted cab as available at the origin.
+func (f *fakeCabs) DeleteCab(_ context.Context, cabID int64) ([]repository.SurgeChange, error) {
+	n, ok := f.activeTrips[cabID]
+	if !ok || f.deleted[cabID] {
+		return nil, fmt.Errorf("delete cab %d: %w", cabID, repository.ErrCabNotFound)
+	}
+	if n > 0 {
+		return nil, fmt.Errorf("delete cab %d: %d non-terminal trips: %w", cabID, n, repository.ErrCabHasActiveTrips)
+	}
+	f.deleted[cabID] = true
+	return []repository.SurgeChange{{Supply: -1}}, nil
+}
+
+func deleteCab(h *CabHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodDelete, "/api/v1/cabs/"+id, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.DeleteCab(rec, req)
+	return rec
+}
+
+func TestDeleteCab_BlockedByActiveTrip(t *testing.T) {
+	cabs := &fakeCabs{activeTrips: map[int64]int{1: 1}, deleted: map[int64]bool{}}
+	h := &CabHandler{repo: cabs}
+
+	rec := deleteCab(h, "1")
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", rec.Code)
+	}
+	if cabs.deleted[1] {
+		t.Error("cab with active trip was deleted")
+	}
+}
+
+func TestDeleteCab_SoftDeletes(t *testing.T) {
+	cabs := &fakeCabs{activeTrips: map[int64]int{2: 0}, deleted: map[int64]bool{}}
+	counters := &demandCounter{demand: map[model.TripDirection]int{}}
+	h := &CabHandler{repo: cabs, Counters: counters}
+
+	if rec := deleteCab(h, "2"); rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	if !cabs.deleted[2] {
+		t.Error("cab not marked deleted")
+	}
+	if counters.supply != -1 {
+		t.Errorf("supply = %d, want -1 for the deleted cab", counters.supply)
+	}
+
+	// A second delete sees the cab as gone.
+	if rec := deleteCab(h, "2"); rec.Code != http.StatusNotFound {
+		t.Errorf("repeat delete status = %d, want 404", rec.Code)
+	}
+}
+
+func TestDeleteCab_InvalidID(t *testing.T) {
+	h := &CabHandler{repo: &fakeCabs{}}
+	if rec := deleteCab(h, "abc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func postLocations(h *CabHandler, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/cabs/locations", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.UpdateLocations(rec, req)
+	return rec
+}
+
+func TestUpdateLocations_BatchWithStaleReport(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	cabs := &fakeCabs{fixedAt: map[int64]time.Time{1: t0, 2: t0}}
+	h := &CabHandler{repo: cabs}
+
+	rec := postLocations(h, `[
+		{"cab_id": 1, "lat": 28.70, "lon": 77.10, "ts": "2025-01-01T10:00:05Z"},
+		{"cab_id": 2, "lat": 28.65, "lon": 77.10, "ts": "2025-01-01T09:59:00Z"}
+	]`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+
+	var got repository.LocationBatchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.Updated != 1 {
+		t.Errorf("updated = %d, want 1", got.Updated)
+	}
+	if len(got.Rejected) != 1 || got.Rejected[0].CabID != 2 || got.Rejected[0].Reason != repository.LocationRejectStale {
+		t.Errorf("rejected = %+v, want cab 2 stale", got.Rejected)
+	}
+	if !cabs.fixedAt[2].Equal(t0) {
+		t.Errorf("cab 2 fix moved backwards to %s", cabs.fixedAt[2])
+	}
+}
+
+func TestUpdateLocations_Validation(t *testing.T) {
+	h := &CabHandler{repo: &fakeCabs{}}
+
+	tests := []struct {
+		name      string
+		body      string
+		want      int
+		wantField string
+	}{
+		{"not an array", `{"cab_id": 1}`, http.StatusBadRequest, ""},
+		{"empty", `[]`, http.StatusUnprocessableEntity, "locations"},
+		{"missing ts", `[{"cab_id": 1, "lat": 28.70, "lon": 77.10}]`, http.StatusUnprocessableEntity, "[0].ts"},
+		{"bad lat", `[{"cab_id": 1, "lat": 98.70, "lon": 77.10, "ts": "2025-01-01T10:00:00Z"}]`, http.StatusUnprocessableEntity, "[0].lat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertValidationResponse(t, postLocations(h, tt.body), tt.want, tt.wantField)
+		})
+	}
+}
+
+func patchCapacity(h *CabHandler, id, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/admin/cabs/"+id+"/capacity", strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.UpdateCapacity(rec, req)
+	return rec
+}
+
+func TestUpdateCapacity_IncreaseWithActiveTrip(t *testing.T) {
+	cabs := &fakeCabs{
+		capacity: map[int64]model.CabCapacity{1: {Seats: 4, Luggage: 3}},
+		load:     map[int64][2]int{1: {3, 2}},
+	}
+	h := &CabHandler{repo: cabs}
+
+	rec := patchCapacity(h, "1", `{"seat_capacity": 6}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body.String())
+	}
+	var got model.Cab
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.SeatCapacity != 6 || got.LuggageCapacity != 3 {
+		t.Errorf("capacity = %d seats / %d bags, want 6 / 3 (luggage untouched)", got.SeatCapacity, got.LuggageCapacity)
+	}
+}
+
+func TestUpdateCapacity_DecreaseBelowLoadBlocked(t *testing.T) {
+	cabs := &fakeCabs{
+		capacity: map[int64]model.CabCapacity{1: {Seats: 4, Luggage: 3}},
+		load:     map[int64][2]int{1: {3, 2}},
+	}
+	h := &CabHandler{repo: cabs}
+
+	rec := patchCapacity(h, "1", `{"seat_capacity": 2}`)
+	e := decodeAPIError(t, rec)
+	if rec.Code != http.StatusConflict || e.Code != "capacity_below_load" {
+		t.Fatalf("got %d %q, want 409 capacity_below_load", rec.Code, e.Code)
+	}
+	if e.Details["current_load"] != float64(3) || e.Details["trip_id"] != float64(40) {
+		t.Errorf("details = %v, want trip 40 with current_load 3", e.Details)
+	}
+	if cabs.capacity[1].Seats != 4 {
+		t.Errorf("seats = %d after refused change, want 4", cabs.capacity[1].Seats)
+	}
+
+	// Down to exactly the load still fits.
+	if rec := patchCapacity(h, "1", `{"seat_capacity": 3}`); rec.Code != http.StatusOK {
+		t.Errorf("lowering to the load: status = %d, want 200", rec.Code)
+	}
+}
+
+func TestUpdateCapacity_AdminGated(t *testing.T) {
+	cabs := &fakeCabs{capacity: map[int64]model.CabCapacity{1: {Seats: 4, Luggage: 3}}}
+	router := mux.NewRouter()
+	admin := router.PathPrefix("/api/v1/admin").Subrouter()
+	admin.Use(middleware.RequireAdmin("s3cret"))
+	admin.HandleFunc("/cabs/{id}/capacity", (&CabHandler{repo: cabs}).UpdateCapacity).Methods(http.MethodPatch)
+
+	patch := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/admin/cabs/1/capacity", strings.NewReader(`{"seat_capacity": 6}`))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, token := range []string{"", "wrong"} {
+		if rec := patch(token); rec.Code != http.StatusForbidden || cabs.capacity[1].Seats != 4 {
+			t.Errorf("token %q: status = %d, seats = %d; want 403 and 4 seats", token, rec.Code, cabs.capacity[1].Seats)
+		}
+	}
+	if rec := patch("s3cret"); rec.Code != http.StatusOK || cabs.capacity[1].Seats != 6 {
+		t.Errorf("admin token: status = %d, seats = %d; want 200 and 6 seats", rec.Code, cabs.capacity[1].Seats)
+	}
+}
+
+func TestUpdateCapacity_Validation(t *testing.T) {
+	h := &CabHandler{repo: &fakeCabs{capacity: map[int64]model.CabCapacity{1: {Seats: 4, Luggage: 3}}}}
+
+	tests := []struct {
+		name      string
+		body      string
+		want      int
+		wantField string
+	}{
+		{"malformed json", `{"seat_capacity":`, http.StatusBadRequest, ""},
+		{"no fields", `{}`, http.StatusUnprocessableEntity, "seat_capacity"},
+		{"zero seats", `{"seat_capacity": 0}`, http.StatusUnprocessableEntity, "seat_capacity"},
+		{"too many bags", `{"luggage_capacity": 11}`, http.StatusUnprocessableEntity, "luggage_capacity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertValidationResponse(t, patchCapacity(h, "1", tt.body), tt.want, tt.wantField)
+		})
+	}
+
+	if rec := patchCapacity(h, "9", `{"seat_capacity": 5}`); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown cab: status = %d, want 404", rec.Code)
+	}
+}
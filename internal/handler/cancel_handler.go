@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
@@ -8,12 +9,18 @@ import (
 
 	"github.com/gorilla/mux"
 
+	"github.com/shiva/hintro/internal/repository"
 	"github.com/shiva/hintro/internal/service"
 )
 
 // CancelHandler handles ride cancellation HTTP requests.
 type CancelHandler struct {
-	cancelSvc *service.CancelService
+	cancelSvc rideCanceller
+}
+
+// rideCanceller is the part of CancelService used by CancelHandler.
+type rideCanceller interface {
+	CancelRide(ctx context.Context, requestID int64) (*repository.CancelResult, error)
 }
 
 // NewCancelHandler creates a new cancel handler.
@@ -24,46 +31,33 @@ func NewCancelHandler(cancelSvc *service.CancelService) *CancelHandler {
 // CancelRide handles POST /api/v1/cancel/{request_id}
 //
 // Cancels a ride request. Only PENDING and MATCHED requests can be cancelled.
+// Cancelling an already-cancelled request is safe to retry: it returns the
+// original result again with "already_cancelled": true.
 //
 // Response codes:
 //
-//	200 — Cancellation successful
+//	200 — Cancellation successful (or replayed)
 //	400 — Invalid request_id
 //	404 — Ride request not found
-//	409 — Request already cancelled or in non-cancellable state
+//	409 — Request is confirmed, completed, or expired
 func (h *CancelHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	requestID, err := strconv.ParseInt(vars["request_id"], 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid request_id: must be an integer",
-		})
+		writeError(w, "bad_request", "invalid request_id: must be an integer")
 		return
 	}
 
 	result, err := h.cancelSvc.CancelRide(r.Context(), requestID)
 	if err != nil {
 		switch {
-		case errors.Is(err, service.ErrAlreadyCancelled):
-			writeJSON(w, http.StatusConflict, map[string]string{
-				"error":   "already_cancelled",
-				"message": "This ride request is already cancelled.",
-			})
 		case errors.Is(err, service.ErrCannotCancel):
-			writeJSON(w, http.StatusConflict, map[string]string{
-				"error":   "cannot_cancel",
-				"message": "This ride request cannot be cancelled (confirmed or completed).",
-			})
+			writeError(w, "cannot_cancel", "This ride request cannot be cancelled (confirmed, completed, or expired).")
 		case errors.Is(err, service.ErrRequestNotFound):
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error":   "not_found",
-				"message": "Ride request not found.",
-			})
+			writeError(w, "not_found", "Ride request not found.")
 		default:
 			log.Printf("[handler] cancel error: %v", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{
-				"error": "internal_error",
-			})
+			writeInternalError(w, err, "Internal server error.")
 		}
 		return
 	}
@@ -81,6 +75,9 @@ func (h *CancelHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	if result.CabFreed {
 		resp["cab_freed"] = true
 	}
+	if result.AlreadyCancelled {
+		resp["already_cancelled"] = true
+	}
 
 	writeJSON(w, http.StatusOK, resp)
 }
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+// fakeCanceller cancels each request once; later calls replay the first
+// result, as BookingRepository.CancelRide does.
+type fakeCanceller struct {
+	cancelled map[int64]repository.CancelResult
+	err       error
+}
+
+func (f *fakeCanceller) CancelRide(_ context.Context, requestID int64) (*repository.CancelResult, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if prior, ok := f.cancelled[requestID]; ok {
+		prior.AlreadyCancelled = true
+		return &prior, nil
+	}
+	trip := int64(7)
+	res := repository.CancelResult{RequestID: requestID, PreviousTrip: &trip, TripCancelled: true, CabFreed: true}
+	f.cancelled[requestID] = res
+	return &res, nil
+}
+
+func serveCancel(t *testing.T, h *CancelHandler, requestID string) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	router := mux.NewRouter()
+	router.HandleFunc("/cancel/{request_id}", h.CancelRide).Methods(http.MethodPost)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/cancel/"+requestID, nil))
+
+	var body map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response: %v (%s)", err, rec.Body)
+	}
+	return rec, body
+}
+
+func TestCancelRide_DoubleCancelIsIdempotent(t *testing.T) {
+	h := &CancelHandler{cancelSvc: &fakeCanceller{cancelled: map[int64]repository.CancelResult{}}}
+
+	first, firstBody := serveCancel(t, h, "42")
+	if first.Code != http.StatusOK {
+		t.Fatalf("first cancel: status = %d, want 200", first.Code)
+	}
+	if _, ok := firstBody["already_cancelled"]; ok {
+		t.Errorf("first cancel: body = %v, want no already_cancelled", firstBody)
+	}
+
+	second, secondBody := serveCancel(t, h, "42")
+	if second.Code != http.StatusOK {
+		t.Fatalf("second cancel: status = %d, want 200", second.Code)
+	}
+	if secondBody["already_cancelled"] != true {
+		t.Errorf("second cancel: already_cancelled = %v, want true", secondBody["already_cancelled"])
+	}
+	// The replay reports what the original cancellation did.
+	for _, key := range []string{"request_id", "previous_trip_id", "trip_cancelled", "cab_freed"} {
+		if secondBody[key] != firstBody[key] {
+			t.Errorf("second cancel: %s = %v, want %v", key, secondBody[key], firstBody[key])
+		}
+	}
+}
+
+func TestCancelRide_ErrorMapping(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+		wantErr  string
+	}{
+		{"completed ride", service.ErrCannotCancel, http.StatusConflict, "cannot_cancel"},
+		{"unknown request", service.ErrRequestNotFound, http.StatusNotFound, "not_found"},
+		{"database down", fmt.Errorf("cancel: begin tx: connection refused"), http.StatusInternalServerError, "internal_error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &CancelHandler{cancelSvc: &fakeCanceller{err: tt.err}}
+			rec, body := serveCancel(t, h, "42")
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if body["code"] != tt.wantErr {
+				t.Errorf("code = %v, want %q", body["code"], tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// ─── Error Envelope ─────────────────────────────────────────
+
+// APIError is the JSON body of every error response:
+//
+//	{"code": "not_found", "message": "Ride request not found."}
+//
+// Code is stable and machine-readable; Message is for humans and may
+// change. Details carries structured extras, such as the offending field
+// of a validation error or what is left on a full cab.
+type APIError struct {
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// errorStatus maps each error code to its HTTP status, so a code means
+// the same status on every endpoint. writeAPIError answers 500 (and logs)
+// for a code missing from this table.
+var errorStatus = map[string]int{
+	// 400 / 405 / 413 — the request itself
+	"bad_request":        http.StatusBadRequest,
+	"unknown_field":      http.StatusBadRequest,
+	"method_not_allowed": http.StatusMethodNotAllowed,
+	"request_too_large":  http.StatusRequestEntityTooLarge,
+
+	// 404
+	"not_found":             http.StatusNotFound,
+	"trip_not_found":        http.StatusNotFound,
+	"no_match":              http.StatusNotFound,
+	"no_cab":                http.StatusNotFound,
+	"dead_letter_not_found": http.StatusNotFound,
+
+	// 409 — the resource is in the wrong state
+	"already_matched":      http.StatusConflict,
+	"scheduled":            http.StatusConflict,
+	"not_pending":          http.StatusConflict,
+	"not_matched":          http.StatusConflict,
+	"not_cancellable":      http.StatusConflict,
+	"cannot_cancel":        http.StatusConflict,
+	"fare_above_cap":       http.StatusConflict,
+	"direction_mismatch":   http.StatusConflict,
+	"cab_not_accessible":   http.StatusConflict,
+	"trip_incompatible":    http.StatusConflict,
+	"cab_has_active_trips": http.StatusConflict,
+	"trip_not_started":     http.StatusConflict,
+	"cab_location_unknown": http.StatusConflict,
+	"trip_empty":           http.StatusConflict,
+	"booking_not_active":   http.StatusConflict,
+	"capacity_below_load":  http.StatusConflict,
+	"already_replayed":     http.StatusConflict,
+
+	// 422 — well-formed but not acceptable
+	"validation_failed": http.StatusUnprocessableEntity,
+	"cab_full":          http.StatusUnprocessableEntity,
+	"trip_full":         http.StatusUnprocessableEntity,
+	"cab_unavailable":   http.StatusUnprocessableEntity,
+	"target_trip_full":  http.StatusUnprocessableEntity,
+	"group_too_large":   http.StatusUnprocessableEntity,
+	"detour_too_long":   http.StatusUnprocessableEntity,
+	"invalid_token":     http.StatusUnprocessableEntity,
+	"token_expired":     http.StatusUnprocessableEntity,
+
+	// 429 — contention; retry after Retry-After
+	"booking_timeout": http.StatusTooManyRequests,
+
+	// 502 — a downstream refused
+	"replay_failed": http.StatusBadGateway,
+
+	"internal_error":      http.StatusInternalServerError,
+	"service_unavailable": http.StatusServiceUnavailable,
+}
+
+// UnavailableRetryAfter is the Retry-After sent with service_unavailable:
+// long enough for a pool reconnect or a database failover to finish.
+const UnavailableRetryAfter = 5 * time.Second
+
+// ContentionRetryAfterMin and ContentionRetryAfterMax bound the Retry-After
+// sent with booking_timeout. Each response picks a whole number of seconds
+// between them at random, so riders who lost the same lock race come back
+// spread out instead of colliding again.
+const (
+	ContentionRetryAfterMin = 1 * time.Second
+	ContentionRetryAfterMax = 3 * time.Second
+)
+
+// contentionRetryAfter returns a jittered Retry-After value in seconds.
+func contentionRetryAfter() string {
+	lo, hi := int64(ContentionRetryAfterMin/time.Second), int64(ContentionRetryAfterMax/time.Second)
+	return strconv.FormatInt(lo+rand.Int64N(hi-lo+1), 10)
+}
+
+// writeError writes an error envelope with no details.
+func writeError(w http.ResponseWriter, code, message string) {
+	writeAPIError(w, APIError{Code: code, Message: message})
+}
+
+// writeInternalError answers an unexpected err. A lost database connection
+// (repository.IsConnectionError) gets 503 service_unavailable with
+// Retry-After, so clients back off and retry; anything else gets 500
+// internal_error with message.
+func writeInternalError(w http.ResponseWriter, err error, message string) {
+	if repository.IsConnectionError(err) {
+		w.Header().Set("Retry-After", strconv.Itoa(int(UnavailableRetryAfter/time.Second)))
+		writeError(w, "service_unavailable", "The database is temporarily unavailable. Retry shortly.")
+		return
+	}
+	writeError(w, "internal_error", message)
+}
+
+// writeAPIError writes e with the status errorStatus assigns its code.
+func writeAPIError(w http.ResponseWriter, e APIError) {
+	status, ok := errorStatus[e.Code]
+	if !ok {
+		log.Printf("[handler] error code %q has no status; answering 500", e.Code)
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, e)
+}
+
+// NotFound answers requests that match no route.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, "not_found", "No route for "+r.URL.Path+".")
+}
+
+// MethodNotAllowed answers requests to a route that exists under another method.
+func MethodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeError(w, "method_not_allowed", r.Method+" is not allowed on "+r.URL.Path+".")
+}
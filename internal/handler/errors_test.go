@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/service"
+)
+
+// decodeAPIError decodes rec's body as an error envelope and fails unless
+// it has exactly the envelope's keys, a code whose status matches the
+// response, and a message.
+func decodeAPIError(t *testing.T, rec *httptest.ResponseRecorder) APIError {
+	t.Helper()
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+		t.Fatalf("decode body: %v (%s)", err, rec.Body)
+	}
+	for key := range raw {
+		if key != "code" && key != "message" && key != "details" {
+			t.Errorf("error body has key %q outside the envelope: %s", key, rec.Body)
+		}
+	}
+	var e APIError
+	if err := json.Unmarshal(rec.Body.Bytes(), &e); err != nil {
+		t.Fatalf("decode envelope: %v (%s)", err, rec.Body)
+	}
+	if status, ok := errorStatus[e.Code]; !ok || status != rec.Code {
+		t.Errorf("code %q answered %d; errorStatus has %d (known: %v)", e.Code, rec.Code, status, ok)
+	}
+	if e.Message == "" {
+		t.Errorf("code %q has no message", e.Code)
+	}
+	return e
+}
+
+func TestWriteAPIError_StatusFromCode(t *testing.T) {
+	for code, want := range errorStatus {
+		rec := httptest.NewRecorder()
+		writeError(rec, code, "m")
+		if rec.Code != want {
+			t.Errorf("%s: status = %d, want %d", code, rec.Code, want)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: Content-Type = %q", code, ct)
+		}
+	}
+}
+
+func TestWriteAPIError_UnknownCodeIs500(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, "no_such_code", "m")
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+func TestWriteAPIError_OmitsEmptyDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeError(rec, "not_found", "Ride request not found.")
+	if got, want := rec.Body.String(), `{"code":"not_found","message":"Ride request not found."}`+"\n"; got != want {
+		t.Errorf("body = %s, want %s", got, want)
+	}
+}
+
+// TestErrorEnvelope_AcrossHandlers drives one error out of each handler
+// and checks every body is the same envelope.
+func TestErrorEnvelope_AcrossHandlers(t *testing.T) {
+	serve := func(route, target string, h http.HandlerFunc) *httptest.ResponseRecorder {
+		router := mux.NewRouter()
+		router.HandleFunc(route, h)
+		router.NotFoundHandler = http.HandlerFunc(NotFound)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, target, nil))
+		return rec
+	}
+	rides := &RideHandler{trips: newFakeTrip(3), routes: fakeRoutes{}}
+	cancel := &CancelHandler{cancelSvc: &fakeCanceller{err: service.ErrCannotCancel}}
+
+	tests := []struct {
+		name     string
+		rec      *httptest.ResponseRecorder
+		wantCode string
+	}{
+		{"ride: bad id", serve("/rides/{id}", "/rides/abc", rides.GetRide), "bad_request"},
+		{"trip: not found", serve("/trips/{id}", "/trips/8", rides.GetTrip), "not_found"},
+		{"eta: not found", serve("/trips/{id}/eta", "/trips/8/eta", rides.GetTripETA), "not_found"},
+		{"cancel: wrong state", serve("/cancel/{request_id}", "/cancel/4", cancel.CancelRide), "cannot_cancel"},
+		{"booking: bad id", serve("/book/{request_id}", "/book/x", (&BookingHandler{}).BookRide), "bad_request"},
+		{"cab: bad id", serve("/cabs/{id}", "/cabs/x", (&CabHandler{}).DeleteCab), "bad_request"},
+		{"no route", serve("/rides/{id}", "/nowhere", rides.GetRide), "not_found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeAPIError(t, tt.rec); got.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", got.Code, tt.wantCode)
+			}
+		})
+	}
+}
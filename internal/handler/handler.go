@@ -2,74 +2,346 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/mux"
 
+	"github.com/shiva/hintro/internal/model"
 	"github.com/shiva/hintro/internal/service"
 )
 
 // MatchHandler handles ride matching HTTP requests.
 type MatchHandler struct {
-	matcher *service.MatchingService
+	matcher    rideMatcher
+	previewer  matchPreviewer
+	requests   matchRequestReader
+	fares      matchFareQuoter
+	maxLuggage int
 }
 
-// NewMatchHandler creates a new handler wired to the matching service.
-func NewMatchHandler(matcher *service.MatchingService) *MatchHandler {
-	return &MatchHandler{matcher: matcher}
+// matchRequestReader loads the matched request's route for ?with_fare=true
+// (service.MatchStore).
+type matchRequestReader interface {
+	GetRideRequest(ctx context.Context, id int64, forUpdate bool) (*model.RideRequest, error)
+}
+
+// matchFareQuoter is the part of PricingService used by MatchRideRequest.
+type matchFareQuoter interface {
+	QuoteBooking(ctx context.Context, origin, destination model.Location, pooled bool, opts service.FareOptions) (*service.FareEstimate, error)
+}
+
+// matchPreviewer is the part of MatchingService used by PreviewMatch.
+type matchPreviewer interface {
+	PreviewMatch(ctx context.Context, probe model.RideRequest) (*service.MatchPreview, error)
+}
+
+// NewMatchHandler creates a new handler wired to the matching service;
+// pricing quotes the fare for ?with_fare=true. maxLuggage is the
+// configured per-request luggage limit.
+func NewMatchHandler(matcher *service.MatchingService, pricing *service.PricingService, maxLuggage int) *MatchHandler {
+	return &MatchHandler{matcher: matcher, previewer: matcher, requests: matcher.Repo, fares: pricing, maxLuggage: maxLuggage}
+}
+
+// MatchWithFare is the ?with_fare=true response of MatchRideRequest: the
+// match, and the fare the rider would be quoted for joining it.
+type MatchWithFare struct {
+	Match *model.MatchResult    `json:"match"`
+	Fare  *service.FareEstimate `json:"fare"`
 }
 
 // MatchRideRequest handles POST /api/v1/match/{request_id}
 //
 // Attempts to find an existing trip for the given ride request.
 // Returns 200 with match details, or 404 if no compatible trip exists.
+//
+// With ?with_fare=true the 200 body is a MatchWithFare instead: the match
+// under "match" and, under "fare", the pooled fare booking would quote for
+// the request's route right now (surge can still move before booking).
 func (h *MatchHandler) MatchRideRequest(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	requestID, err := strconv.ParseInt(vars["request_id"], 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid request_id: must be an integer",
-		})
+		writeError(w, "bad_request", "invalid request_id: must be an integer")
 		return
 	}
+	withFare := false
+	if raw := r.URL.Query().Get("with_fare"); raw != "" {
+		if withFare, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, "bad_request", "invalid with_fare: must be true or false")
+			return
+		}
+	}
 
 	result, err := h.matcher.MatchRiders(r.Context(), requestID)
 	if err != nil {
-		switch {
-		case errors.Is(err, service.ErrNoMatch):
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error":   "no_match",
-				"message": "No compatible trip found. A new trip should be created.",
-			})
-		case errors.Is(err, service.ErrRequestNotFound):
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error":   "not_found",
-				"message": "Ride request not found.",
-			})
-		case errors.Is(err, service.ErrAlreadyMatched):
-			writeJSON(w, http.StatusConflict, map[string]string{
-				"error":   "already_matched",
-				"message": "This ride request is already matched to a trip.",
-			})
-		default:
-			log.Printf("[handler] match error: %v", err)
-			writeJSON(w, http.StatusInternalServerError, map[string]string{
-				"error": "internal_error",
-			})
+		writeMatchError(w, err)
+		return
+	}
+	if !withFare {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+
+	// Matching only reads, so failing here leaves nothing half done.
+	req, err := h.requests.GetRideRequest(r.Context(), requestID, false)
+	if err != nil {
+		log.Printf("[handler] match fare: load request %d: %v", requestID, err)
+		writeInternalError(w, err, "Internal server error.")
+		return
+	}
+	fare, err := h.fares.QuoteBooking(r.Context(), req.Origin, req.Destination, true, service.FareOptions{Direction: req.Direction})
+	if err != nil {
+		log.Printf("[handler] match fare: quote request %d: %v", requestID, err)
+		writeInternalError(w, err, "Internal server error.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, MatchWithFare{Match: result, Fare: fare})
+}
+
+// writeMatchError maps a MatchingService.MatchRiders error to an HTTP response.
+func writeMatchError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrNoMatch):
+		writeError(w, "no_match", "No compatible trip found. A new trip should be created.")
+	case errors.Is(err, service.ErrRequestNotFound):
+		writeError(w, "not_found", "Ride request not found.")
+	case errors.Is(err, service.ErrAlreadyMatched):
+		writeError(w, "already_matched", "This ride request is already matched to a trip.")
+	case errors.Is(err, service.ErrRequestScheduled):
+		writeError(w, "scheduled", "This ride request is scheduled; matching opens shortly before its scheduled_at.")
+	default:
+		log.Printf("[handler] match error: %v", err)
+		writeInternalError(w, err, "Internal server error.")
+	}
+}
+
+// PreviewMatch handles GET /api/v1/match/preview
+//
+// Reports whether a rider at lat/lon could pool into an existing trip,
+// without creating a ride request. Read-only.
+//
+//	GET /api/v1/match/preview?lat=28.70&lon=77.10&direction=to_airport&seats=1&luggage=1
+//
+// Query: lat, lon, direction (required); seats (default 1), luggage
+// (default 0), dest_lat + dest_lon (optional, sharpens the detour estimate),
+// accessible (default false; true considers only wheelchair-accessible cabs).
+//
+//   200 — {"matchable": bool, "trip_id", "cab_id", "estimated_detour_minutes", "candidates_checked"}
+//   400 — a parameter is not a number
+//   422 — a parameter is out of range
+func (h *MatchHandler) PreviewMatch(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var probe model.RideRequest
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"lat", &probe.Origin.Lat}, {"lon", &probe.Origin.Lon},
+		{"dest_lat", &probe.Destination.Lat}, {"dest_lon", &probe.Destination.Lon},
+	} {
+		if raw := q.Get(p.name); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				writeError(w, "bad_request", "invalid "+p.name+": must be a number")
+				return
+			}
+			*p.dst = v
+		}
+	}
+	probe.SeatsNeeded = 1
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"seats", &probe.SeatsNeeded}, {"luggage", &probe.LuggageCount},
+	} {
+		if raw := q.Get(p.name); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil {
+				writeError(w, "bad_request", "invalid "+p.name+": must be an integer")
+				return
+			}
+			*p.dst = v
 		}
+	}
+
+	if raw := q.Get("accessible"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			writeError(w, "bad_request", "invalid accessible: must be true or false")
+			return
+		}
+		probe.RequiresAccessible = v
+	}
+
+	if !validateLocation(w, probe.Origin, "lat", "lon") {
+		return
+	}
+	if probe.Destination != (model.Location{}) &&
+		!validateLocation(w, probe.Destination, "dest_lat", "dest_lon") {
+		return
+	}
+	probe.Direction = model.TripDirection(q.Get("direction"))
+	if !probe.Direction.Valid() {
+		writeEnumFieldError(w, "direction", "must be 'to_airport' or 'from_airport'", model.TripDirections)
+		return
+	}
+	if probe.SeatsNeeded < 1 {
+		writeFieldError(w, "seats", "must be at least 1")
+		return
+	}
+	if probe.LuggageCount < 0 || probe.LuggageCount > h.maxLuggage {
+		writeFieldError(w, "luggage", fmt.Sprintf("must be between 0 and %d", h.maxLuggage))
 		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	preview, err := h.previewer.PreviewMatch(r.Context(), probe)
+	if err != nil {
+		log.Printf("[handler] match preview error: %v", err)
+		writeInternalError(w, err, "Internal server error.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, preview)
 }
 
-// writeJSON is a helper that writes a JSON response.
+// writeJSON is a helper that writes a JSON response. Errors go through
+// writeError instead (see errors.go).
 func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
+
+// decodeJSON decodes the request body into dst, rejecting fields dst does
+// not declare so a client typo (e.g. "seats_need") is not silently
+// replaced by a default. Report failures with writeBodyError.
+func decodeJSON(r *http.Request, dst interface{}) error {
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	return dec.Decode(dst)
+}
+
+// writeBodyError answers a request whose JSON body failed to decode: 413
+// if it ran past the middleware.MaxBodyBytes limit, 400 naming the field
+// if it carried an unknown one, otherwise 400 with message.
+func writeBodyError(w http.ResponseWriter, err error, message string) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, "request_too_large", fmt.Sprintf("Request body exceeds %d bytes.", tooLarge.Limit))
+		return
+	}
+	// encoding/json has no typed error for this; the message is stable.
+	if quoted, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		field, uerr := strconv.Unquote(quoted)
+		if uerr != nil {
+			field = quoted
+		}
+		writeAPIError(w, APIError{
+			Code:    "unknown_field",
+			Message: fmt.Sprintf("unknown field %q", field),
+			Details: map[string]interface{}{"field": field},
+		})
+		return
+	}
+	writeError(w, "bad_request", message)
+}
+
+// writeFieldError writes a 422 for a semantic validation failure on one
+// request field. Malformed bodies get 400 instead.
+func writeFieldError(w http.ResponseWriter, field, message string) {
+	writeAPIError(w, APIError{
+		Code:    "validation_failed",
+		Message: message,
+		Details: map[string]interface{}{"field": field},
+	})
+}
+
+// writeEnumFieldError writes a 422 for a field outside its enum, listing
+// the accepted values in details.allowed so clients need not hard-code them.
+func writeEnumFieldError[T ~string](w http.ResponseWriter, field, message string, allowed []T) {
+	values := make([]string, len(allowed))
+	for i, v := range allowed {
+		values[i] = string(v)
+	}
+	writeAPIError(w, APIError{
+		Code:    "validation_failed",
+		Message: message,
+		Details: map[string]interface{}{"field": field, "allowed": values},
+	})
+}
+
+// validateLocation runs loc.Validate and, on failure, writes a 422 naming
+// the request field the client sent (latField or lonField). Returns false
+// if a response was written.
+func validateLocation(w http.ResponseWriter, loc model.Location, latField, lonField string) bool {
+	err := loc.Validate()
+	if err == nil {
+		return true
+	}
+	var fe *model.FieldError
+	if !errors.As(err, &fe) {
+		writeFieldError(w, latField, err.Error())
+		return false
+	}
+	field := latField
+	if fe.Field == "lon" {
+		field = lonField
+	}
+	writeFieldError(w, field, fe.Message)
+	return false
+}
+
+// parsePaging reads the optional limit and offset query parameters into
+// limit and offset. Returns false if a response was written.
+func parsePaging(w http.ResponseWriter, q url.Values, limit, offset *int) bool {
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{
+		{"limit", limit}, {"offset", offset},
+	} {
+		if raw := q.Get(p.name); raw != "" {
+			v, err := strconv.Atoi(raw)
+			if err != nil {
+				writeError(w, "bad_request", "invalid "+p.name+": must be an integer")
+				return false
+			}
+			if v < 0 {
+				writeFieldError(w, p.name, "must not be negative")
+				return false
+			}
+			*p.dst = v
+		}
+	}
+	return true
+}
+
+// parseRequestStatuses reads the optional comma-separated status query
+// parameter. Returns false if a response was written.
+func parseRequestStatuses(w http.ResponseWriter, q url.Values) ([]model.RequestStatus, bool) {
+	raw := q.Get("status")
+	if raw == "" {
+		return nil, true
+	}
+	var statuses []model.RequestStatus
+	for _, st := range strings.Split(raw, ",") {
+		status := model.RequestStatus(strings.TrimSpace(st))
+		if !status.Valid() {
+			writeEnumFieldError(w, "status", fmt.Sprintf("unknown request status %q", status), model.RequestStatuses)
+			return nil, false
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, true
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/service"
+)
+
+// fakePreviewer reports a match only for probes near one trip's pickups.
+type fakePreviewer struct {
+	near  model.Location
+	probe model.RideRequest
+}
+
+func (f *fakePreviewer) PreviewMatch(_ context.Context, probe model.RideRequest) (*service.MatchPreview, error) {
+	f.probe = probe
+	if probe.Origin != f.near {
+		return &service.MatchPreview{CandidatesChecked: 0}, nil
+	}
+	return &service.MatchPreview{
+		Matchable: true, TripID: 7, CabID: 3,
+		EstimatedDetourMinutes: 2.5, CandidatesChecked: 1,
+	}, nil
+}
+
+func previewMatch(f *fakePreviewer, query string) *httptest.ResponseRecorder {
+	h := &MatchHandler{previewer: f, maxLuggage: model.MaxLuggagePerRequest}
+	rec := httptest.NewRecorder()
+	h.PreviewMatch(rec, httptest.NewRequest(http.MethodGet, "/api/v1/match/preview?"+query, nil))
+	return rec
+}
+
+func TestPreviewMatch_Matchable(t *testing.T) {
+	f := &fakePreviewer{near: model.Location{Lat: 28.70, Lon: 77.10}}
+	rec := previewMatch(f, "lat=28.70&lon=77.10&direction=to_airport&seats=2&luggage=1")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got service.MatchPreview
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Matchable || got.TripID != 7 || got.EstimatedDetourMinutes != 2.5 {
+		t.Errorf("preview = %+v, want matchable trip 7 with 2.5 min detour", got)
+	}
+	if f.probe.SeatsNeeded != 2 || f.probe.LuggageCount != 1 || f.probe.Direction != model.DirectionToAirport {
+		t.Errorf("probe = %+v, want 2 seats, 1 bag, to_airport", f.probe)
+	}
+}
+
+func TestPreviewMatch_NotMatchable(t *testing.T) {
+	f := &fakePreviewer{near: model.Location{Lat: 28.70, Lon: 77.10}}
+	rec := previewMatch(f, "lat=12.97&lon=77.59&direction=from_airport")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["matchable"] != false {
+		t.Errorf("matchable = %v, want false", got["matchable"])
+	}
+	if _, ok := got["trip_id"]; ok {
+		t.Errorf("trip_id present on a non-matchable preview: %v", got)
+	}
+	if f.probe.SeatsNeeded != 1 || f.probe.LuggageCount != 0 || f.probe.RequiresAccessible {
+		t.Errorf("defaults: seats=%d luggage=%d accessible=%v, want 1, 0, false",
+			f.probe.SeatsNeeded, f.probe.LuggageCount, f.probe.RequiresAccessible)
+	}
+}
+
+func TestPreviewMatch_Accessible(t *testing.T) {
+	f := &fakePreviewer{}
+	previewMatch(f, "lat=28.70&lon=77.10&direction=to_airport&accessible=true")
+	if !f.probe.RequiresAccessible {
+		t.Error("accessible=true did not reach the probe")
+	}
+}
+
+func TestPreviewMatch_Validation(t *testing.T) {
+	tests := []struct {
+		query     string
+		wantCode  int
+		wantField string
+	}{
+		{"lat=abc&lon=77.10&direction=to_airport", http.StatusBadRequest, ""},
+		{"lat=28.70&lon=77.10&direction=to_airport&seats=two", http.StatusBadRequest, ""},
+		{"lat=28.70&lon=77.10&direction=to_airport&accessible=maybe", http.StatusBadRequest, ""},
+		{"lon=77.10&direction=to_airport", http.StatusUnprocessableEntity, "lat"},
+		{"lat=28.70&lon=77.10", http.StatusUnprocessableEntity, "direction"},
+		{"lat=28.70&lon=77.10&direction=to_airport&seats=0", http.StatusUnprocessableEntity, "seats"},
+		{"lat=28.70&lon=77.10&direction=to_airport&luggage=9", http.StatusUnprocessableEntity, "luggage"},
+		{"lat=28.70&lon=77.10&direction=to_airport&dest_lat=95&dest_lon=77", http.StatusUnprocessableEntity, "dest_lat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			assertValidationResponse(t, previewMatch(&fakePreviewer{}, tt.query), tt.wantCode, tt.wantField)
+		})
+	}
+}
+
+// fakeMatchFare serves MatchRideRequest: one matchable request, its stored
+// route, and a flat fare quote.
+type fakeMatchFare struct {
+	req    model.RideRequest
+	pooled bool
+	opts   service.FareOptions
+}
+
+func (f *fakeMatchFare) MatchRiders(_ context.Context, requestID int64) (*model.MatchResult, error) {
+	if requestID != f.req.ID {
+		return nil, service.ErrNoMatch
+	}
+	return &model.MatchResult{TripID: 7, CabID: 3, AddedDetour: 1.5, CurrentLoad: 2}, nil
+}
+
+func (f *fakeMatchFare) GetRideRequest(_ context.Context, id int64, _ bool) (*model.RideRequest, error) {
+	req := f.req
+	return &req, nil
+}
+
+func (f *fakeMatchFare) QuoteBooking(_ context.Context, _, _ model.Location, pooled bool, opts service.FareOptions) (*service.FareEstimate, error) {
+	f.pooled, f.opts = pooled, opts
+	return &service.FareEstimate{TotalFareCents: 24500, SurgeMultiplier: 1.25}, nil
+}
+
+func matchRide(f *fakeMatchFare, path string) *httptest.ResponseRecorder {
+	h := &MatchHandler{matcher: f, requests: f, fares: f, maxLuggage: model.MaxLuggagePerRequest}
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	req = mux.SetURLVars(req, map[string]string{"request_id": "42"})
+	rec := httptest.NewRecorder()
+	h.MatchRideRequest(rec, req)
+	return rec
+}
+
+func TestMatchRideRequest_WithFare(t *testing.T) {
+	f := &fakeMatchFare{req: model.RideRequest{ID: 42, Direction: model.DirectionToAirport}}
+	rec := matchRide(f, "/api/v1/match/42?with_fare=true")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		Match *model.MatchResult    `json:"match"`
+		Fare  *service.FareEstimate `json:"fare"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Match == nil || got.Match.TripID != 7 || got.Match.CurrentLoad != 2 {
+		t.Errorf("match = %+v, want trip 7 with load 2", got.Match)
+	}
+	if got.Fare == nil || got.Fare.TotalFareCents != 24500 || got.Fare.SurgeMultiplier != 1.25 {
+		t.Errorf("fare = %+v, want 24500 cents at 1.25x", got.Fare)
+	}
+	if !f.pooled || f.opts.Direction != model.DirectionToAirport {
+		t.Errorf("quoted pooled=%v direction=%q, want pooled to_airport", f.pooled, f.opts.Direction)
+	}
+}
+
+func TestMatchRideRequest_WithoutFareKeepsFlatBody(t *testing.T) {
+	f := &fakeMatchFare{req: model.RideRequest{ID: 42}}
+	rec := matchRide(f, "/api/v1/match/42")
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["trip_id"] != float64(7) {
+		t.Errorf("trip_id = %v, want 7 at the top level", got["trip_id"])
+	}
+	if _, ok := got["fare"]; ok {
+		t.Errorf("body = %v, want no fare section without with_fare", got)
+	}
+}
+
+func TestMatchRideRequest_InvalidWithFare(t *testing.T) {
+	rec := matchRide(&fakeMatchFare{req: model.RideRequest{ID: 42}}, "/api/v1/match/42?with_fare=maybe")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400; body %s", rec.Code, rec.Body)
+	}
+}
+
+func TestDecodeJSON_RejectsUnknownFields(t *testing.T) {
+	var body CreateRideRequestBody
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(`{"user_id":1,"seats_need":3}`))
+	err := decodeJSON(req, &body)
+	if err == nil {
+		t.Fatal("decodeJSON accepted an unknown field")
+	}
+
+	rec := httptest.NewRecorder()
+	writeBodyError(rec, err, "invalid JSON body")
+	got := decodeAPIError(t, rec)
+	if rec.Code != http.StatusBadRequest || got.Code != "unknown_field" || got.Details["field"] != "seats_need" {
+		t.Errorf("got %d %+v, want 400 unknown_field naming seats_need", rec.Code, got)
+	}
+}
+
+func TestDecodeJSON_AcceptsKnownFields(t *testing.T) {
+	var body CreateRideRequestBody
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(`{"user_id":1,"seats_needed":3}`))
+	if err := decodeJSON(req, &body); err != nil {
+		t.Fatalf("decodeJSON: %v", err)
+	}
+	if body.UserID != 1 || body.SeatsNeeded != 3 {
+		t.Errorf("body = %+v, want user 1 with 3 seats", body)
+	}
+}
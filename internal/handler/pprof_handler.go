@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// Pprof returns net/http/pprof's runtime profiling endpoints under
+// /debug/pprof/, and expvar's metrics at /debug/vars. They expose heap
+// contents, command lines and per-area demand, so they are never mounted
+// on the API router: NewPprofServer serves them on their own listener.
+//
+// Importing net/http/pprof and expvar also registers these handlers on
+// http.DefaultServeMux, which this server never serves.
+func Pprof() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index) // also serves the named profiles (heap, goroutine, …)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	return mux
+}
+
+// NewPprofServer returns a server for Pprof on addr (SERVER_PPROF_ADDR),
+// or nil when addr is empty: profiling is off unless configured. It has
+// no write timeout, since /debug/pprof/profile and /trace stream for as
+// many seconds as asked.
+func NewPprofServer(addr string) *http.Server {
+	if addr == "" {
+		return nil
+	}
+	return &http.Server{
+		Addr:              addr,
+		Handler:           Pprof(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+}
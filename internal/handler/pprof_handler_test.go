@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewPprofServer_DisabledByDefault(t *testing.T) {
+	if srv := NewPprofServer(""); srv != nil {
+		t.Errorf("NewPprofServer(\"\") = %+v, want nil: nothing may listen unless SERVER_PPROF_ADDR is set", srv)
+	}
+}
+
+func TestNewPprofServer_ServesProfilesWhenEnabled(t *testing.T) {
+	srv := NewPprofServer("127.0.0.1:6060")
+	if srv == nil {
+		t.Fatal("NewPprofServer returned nil for a configured address")
+	}
+	ts := httptest.NewServer(srv.Handler)
+	defer ts.Close()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/cmdline", "/debug/pprof/heap?debug=1", "/debug/pprof/goroutine?debug=1", "/debug/vars"} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, resp.StatusCode)
+		}
+	}
+}
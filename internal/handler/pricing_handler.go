@@ -1,11 +1,17 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
 
 	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
 	"github.com/shiva/hintro/internal/service"
 )
 
@@ -15,16 +21,48 @@ type FareRequest struct {
 	OriginLon float64 `json:"origin_lon"`
 	DestLat   float64 `json:"dest_lat"`
 	DestLon   float64 `json:"dest_lon"`
+
+	// MaxFareCents is an optional price ceiling; a total above it is
+	// returned capped with capped=true and would_be_cents set.
+	MaxFareCents int `json:"max_fare_cents,omitempty"`
+
+	// Direction optionally scopes surge demand to rides going the same
+	// way ("to_airport" or "from_airport"); omitted counts both.
+	Direction string `json:"direction,omitempty"`
 }
 
+// RouteFareRequest is the JSON body for POST /api/v1/fare/route.
+type RouteFareRequest struct {
+	Stops []model.Location `json:"stops"`
+}
+
+// MaxRouteStops caps the stops accepted by the route fare endpoint
+// (a pooled itinerary is at most a handful of pickups plus the airport).
+const MaxRouteStops = 10
+
 // PricingHandler handles fare estimation HTTP requests.
 type PricingHandler struct {
 	pricingSvc *service.PricingService
+	trips      tripRouteReader
+	tripSurge  tripSurgeEstimator
+	fares      tripFareReader
 }
 
-// NewPricingHandler creates a new pricing handler.
-func NewPricingHandler(pricingSvc *service.PricingService) *PricingHandler {
-	return &PricingHandler{pricingSvc: pricingSvc}
+// tripSurgeEstimator is the part of service.PricingService used by GetTripSurge.
+type tripSurgeEstimator interface {
+	TripSurge(ctx context.Context, route *repository.TripRoute) (*service.TripSurgeInfo, error)
+}
+
+// tripFareReader is the part of RideRequestRepository used by GetTripFare.
+type tripFareReader interface {
+	GetTripFares(ctx context.Context, tripID int64) (*repository.TripFares, error)
+}
+
+// NewPricingHandler creates a new pricing handler. trips loads the riders
+// of a trip for GetTripSurge; fares loads their booked fares for
+// GetTripFare.
+func NewPricingHandler(pricingSvc *service.PricingService, trips tripRouteReader, fares tripFareReader) *PricingHandler {
+	return &PricingHandler{pricingSvc: pricingSvc, trips: trips, tripSurge: pricingSvc, fares: fares}
 }
 
 // EstimateFare handles POST /api/v1/fare/estimate
@@ -33,38 +71,215 @@ func NewPricingHandler(pricingSvc *service.PricingService) *PricingHandler {
 //
 //	{
 //	  "origin_lat": 28.7041, "origin_lon": 77.1025,
-//	  "dest_lat": 28.5562,   "dest_lon": 77.0889
+//	  "dest_lat": 28.5562,   "dest_lon": 77.0889,
+//	  "max_fare_cents": 40000,           // optional
+//	  "direction": "to_airport"          // optional; scopes surge demand
 //	}
 //
 // Response: FareEstimate with breakdown and surge info.
 func (h *PricingHandler) EstimateFare(w http.ResponseWriter, r *http.Request) {
 	var req FareRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid JSON body",
-		})
-		return
-	}
-
-	// Basic validation.
-	if req.OriginLat == 0 || req.OriginLon == 0 || req.DestLat == 0 || req.DestLon == 0 {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "origin_lat, origin_lon, dest_lat, and dest_lon are all required",
-		})
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
 		return
 	}
 
 	origin := model.Location{Lat: req.OriginLat, Lon: req.OriginLon}
 	dest := model.Location{Lat: req.DestLat, Lon: req.DestLon}
 
-	estimate, err := h.pricingSvc.EstimateFare(r.Context(), origin, dest)
+	// Semantic validation → 422 with the offending field.
+	if !validateLocation(w, origin, "origin_lat", "origin_lon") ||
+		!validateLocation(w, dest, "dest_lat", "dest_lon") {
+		return
+	}
+	if req.MaxFareCents < 0 {
+		writeFieldError(w, "max_fare_cents", "must not be negative")
+		return
+	}
+	direction := model.TripDirection(req.Direction)
+	if direction != "" && !direction.Valid() {
+		writeEnumFieldError(w, "direction", "must be 'to_airport' or 'from_airport'", model.TripDirections)
+		return
+	}
+
+	estimate, err := h.pricingSvc.EstimateFare(r.Context(), origin, dest,
+		service.FareOptions{MaxFareCents: req.MaxFareCents, Direction: direction})
 	if err != nil {
 		log.Printf("[handler] pricing error: %v", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": "failed to estimate fare",
-		})
+		writeInternalError(w, err, "failed to estimate fare")
 		return
 	}
 
 	writeJSON(w, http.StatusOK, estimate)
 }
+
+// EstimateRouteFare handles POST /api/v1/fare/route
+//
+// Prices a multi-stop route (e.g. a proposed pooled itinerary) end to end.
+//
+// Request body:
+//
+//	{
+//	  "stops": [
+//	    {"lat": 28.7041, "lon": 77.1025},
+//	    {"lat": 28.6500, "lon": 77.1000},
+//	    {"lat": 28.5562, "lon": 77.0889}
+//	  ]
+//	}
+//
+// Response: FareEstimate for the whole route (total distance, time, fare).
+func (h *PricingHandler) EstimateRouteFare(w http.ResponseWriter, r *http.Request) {
+	var req RouteFareRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
+		return
+	}
+
+	if len(req.Stops) < 2 || len(req.Stops) > MaxRouteStops {
+		writeFieldError(w, "stops", fmt.Sprintf("must contain between 2 and %d locations", MaxRouteStops))
+		return
+	}
+	for i, stop := range req.Stops {
+		prefix := fmt.Sprintf("stops[%d].", i)
+		if !validateLocation(w, stop, prefix+"lat", prefix+"lon") {
+			return
+		}
+	}
+
+	estimate, err := h.pricingSvc.EstimateRouteFare(r.Context(), req.Stops)
+	if err != nil {
+		log.Printf("[handler] route pricing error: %v", err)
+		writeInternalError(w, err, "failed to estimate route fare")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, estimate)
+}
+
+// GetSurge handles GET /api/v1/surge
+//
+// Returns the current demand, supply and surge multiplier around a point,
+// so clients can show surge before asking for a full fare.
+//
+//	GET /api/v1/surge?lat=28.7041&lon=77.1025&radius=3000&direction=to_airport
+//
+// radius (meters) is optional and clamped to 500–20000; the response
+// carries the radius actually used. direction is optional and counts
+// demand going that way only.
+func (h *PricingHandler) GetSurge(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var loc model.Location
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{
+		{"lat", &loc.Lat}, {"lon", &loc.Lon},
+	} {
+		if raw := q.Get(p.name); raw != "" {
+			v, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				writeError(w, "bad_request", "invalid "+p.name+": must be a number")
+				return
+			}
+			*p.dst = v
+		}
+	}
+	radius := 0
+	if raw := q.Get("radius"); raw != "" {
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			writeError(w, "bad_request", "invalid radius: must be an integer (meters)")
+			return
+		}
+		radius = v
+	}
+
+	if !validateLocation(w, loc, "lat", "lon") {
+		return
+	}
+	direction := model.TripDirection(q.Get("direction"))
+	if direction != "" && !direction.Valid() {
+		writeEnumFieldError(w, "direction", "must be 'to_airport' or 'from_airport'", model.TripDirections)
+		return
+	}
+
+	surge, err := h.pricingSvc.CurrentSurge(r.Context(), loc, radius, direction)
+	if err != nil {
+		log.Printf("[handler] surge query error: %v", err)
+		writeInternalError(w, err, "failed to query surge")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, surge)
+}
+
+// GetTripSurge handles GET /api/v1/trips/{id}/surge
+//
+// Re-estimates surge for an existing trip, for dynamic re-pricing: the
+// current surge around the centroid of its riders' origins, over the
+// configured surge radius (see service.TripSurge).
+//
+//	200 — {"trip_id", "passengers", "lat", "lon", "radius_m", "demand", "supply", "demand_supply_ratio", "surge_multiplier"}
+//	404 — trip not found
+//	409 — trip has no matched passengers
+func (h *PricingHandler) GetTripSurge(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	route, err := h.trips.GetTripRoute(r.Context(), id)
+	if errors.Is(err, repository.ErrTripNotFound) {
+		writeError(w, "trip_not_found", "trip not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] get trip route error: %v", err)
+		writeInternalError(w, err, "failed to load trip")
+		return
+	}
+
+	surge, err := h.tripSurge.TripSurge(r.Context(), route)
+	if errors.Is(err, service.ErrTripHasNoPassengers) {
+		writeError(w, "trip_empty", "The trip has no matched passengers to price.")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] trip surge error: %v", err)
+		writeInternalError(w, err, "failed to query surge")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, surge)
+}
+
+// GetTripFare handles GET /api/v1/trips/{id}/fare
+//
+// Driver view of a trip's fare: the total its matched and confirmed riders
+// were booked at, each rider's share, and the surge applied (see
+// service.TripFare). A trip with no riders totals 0.
+//
+//	200 — {"trip_id", "status", "passengers", "total_fare_cents", "pool_discount_cents", "surge_multiplier", "shares"}
+//	404 — trip not found
+func (h *PricingHandler) GetTripFare(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	fares, err := h.fares.GetTripFares(r.Context(), id)
+	if errors.Is(err, repository.ErrTripNotFound) {
+		writeError(w, "trip_not_found", "trip not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] get trip fares error: %v", err)
+		writeInternalError(w, err, "failed to load trip fares")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, service.TripFare(fares))
+}
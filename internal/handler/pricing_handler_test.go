@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+func TestEstimateRouteFare_Validation(t *testing.T) {
+	h := NewPricingHandler(nil, nil, nil)
+
+	tests := []struct {
+		name      string
+		body      string
+		want      int
+		wantField string
+	}{
+		{"malformed json", `{"stops":`, http.StatusBadRequest, ""},
+		{"single stop", `{"stops":[{"lat":28.70,"lon":77.10}]}`, http.StatusUnprocessableEntity, "stops"},
+		{"missing coordinate", `{"stops":[{"lat":28.70,"lon":77.10},{"lat":28.55}]}`, http.StatusUnprocessableEntity, "stops[1].lon"},
+		{"out of range", `{"stops":[{"lat":28.70,"lon":77.10},{"lat":128.55,"lon":77.08}]}`, http.StatusUnprocessableEntity, "stops[1].lat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/fare/route", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.EstimateRouteFare(rec, req)
+			assertValidationResponse(t, rec, tt.want, tt.wantField)
+		})
+	}
+}
+
+func TestEstimateFare_Validation(t *testing.T) {
+	h := NewPricingHandler(nil, nil, nil)
+
+	tests := []struct {
+		name      string
+		body      string
+		want      int
+		wantField string
+	}{
+		{"malformed json", `{"origin_lat":`, http.StatusBadRequest, ""},
+		{"unknown field", `{"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,"max_fare":900}`, http.StatusBadRequest, ""},
+		{"missing origin", `{"dest_lat":28.55,"dest_lon":77.08}`, http.StatusUnprocessableEntity, "origin_lat"},
+		{"dest lon out of range", `{"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":277.08}`, http.StatusUnprocessableEntity, "dest_lon"},
+		{"negative cap", `{"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,"max_fare_cents":-1}`, http.StatusUnprocessableEntity, "max_fare_cents"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/fare/estimate", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.EstimateFare(rec, req)
+			assertValidationResponse(t, rec, tt.want, tt.wantField)
+		})
+	}
+}
+
+func TestGetSurge_Validation(t *testing.T) {
+	h := NewPricingHandler(nil, nil, nil)
+
+	tests := []struct {
+		query     string
+		want      int
+		wantField string
+	}{
+		{"lat=abc&lon=77.10", http.StatusBadRequest, ""},
+		{"lat=28.70&lon=77.10&radius=wide", http.StatusBadRequest, ""},
+		{"lon=77.10", http.StatusUnprocessableEntity, "lat"},
+		{"lat=28.70&lon=190", http.StatusUnprocessableEntity, "lon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.GetSurge(rec, httptest.NewRequest(http.MethodGet, "/api/v1/surge?"+tt.query, nil))
+			assertValidationResponse(t, rec, tt.want, tt.wantField)
+		})
+	}
+}
+
+// fakeTripSurge prices a trip at the multiplier recorded for its id.
+type fakeTripSurge map[int64]float64
+
+func (f fakeTripSurge) TripSurge(_ context.Context, route *repository.TripRoute) (*service.TripSurgeInfo, error) {
+	if len(route.Riders) == 0 {
+		return nil, fmt.Errorf("trip %d surge: %w", route.TripID, service.ErrTripHasNoPassengers)
+	}
+	return &service.TripSurgeInfo{
+		TripID:     route.TripID,
+		Passengers: len(route.Riders),
+		SurgeInfo:  service.SurgeInfo{SurgeMultiplier: f[route.TripID]},
+	}, nil
+}
+
+func getTripSurge(h *PricingHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trips/"+id+"/surge", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.GetTripSurge(rec, req)
+	return rec
+}
+
+func TestGetTripSurge(t *testing.T) {
+	riders := []model.RideRequest{{ID: 11, Origin: model.Location{Lat: 28.7041, Lon: 77.1025}}}
+	h := &PricingHandler{
+		trips: fakeRoutes{
+			1: {TripID: 1, Status: model.TripInProgress, Riders: riders},
+			2: {TripID: 2, Status: model.TripInProgress, Riders: riders},
+			3: {TripID: 3, Status: model.TripPlanned},
+		},
+		tripSurge: fakeTripSurge{1: service.SurgeMultiplierNone, 2: service.SurgeMultiplierHigh},
+	}
+
+	for id, want := range map[string]float64{"1": service.SurgeMultiplierNone, "2": service.SurgeMultiplierHigh} {
+		rec := getTripSurge(h, id)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("trip %s: status = %d, want 200; body %s", id, rec.Code, rec.Body)
+		}
+		var got service.TripSurgeInfo
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.SurgeMultiplier != want || got.Passengers != 1 {
+			t.Errorf("trip %s: body = %+v, want multiplier %.1f for 1 passenger", id, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"3", http.StatusConflict, "trip_empty"},
+		{"9", http.StatusNotFound, "trip_not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := getTripSurge(h, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("trip %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// fakeFares serves TripFares by trip id.
+type fakeFares map[int64]*repository.TripFares
+
+func (f fakeFares) GetTripFares(_ context.Context, tripID int64) (*repository.TripFares, error) {
+	fares, ok := f[tripID]
+	if !ok {
+		return nil, fmt.Errorf("get trip %d fares: %w", tripID, repository.ErrTripNotFound)
+	}
+	return fares, nil
+}
+
+func getTripFare(h *PricingHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trips/"+id+"/fare", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.GetTripFare(rec, req)
+	return rec
+}
+
+func TestGetTripFare(t *testing.T) {
+	fare := func(cents int) *int { return &cents }
+	surge := 1.5
+	h := &PricingHandler{fares: fakeFares{
+		1: {TripID: 1, Status: model.TripInProgress, Riders: []model.RideRequest{
+			{ID: 11, SeatsNeeded: 1, FareCents: fare(30000), SurgeMultiplier: &surge},
+			{ID: 12, SeatsNeeded: 1, FareCents: fare(20000), SurgeMultiplier: &surge},
+		}},
+		2: {TripID: 2, Status: model.TripPlanned, Riders: []model.RideRequest{}},
+	}}
+
+	for id, want := range map[string]struct{ passengers, total int }{"1": {2, 50000}, "2": {0, 0}} {
+		rec := getTripFare(h, id)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("trip %s: status = %d, want 200; body %s", id, rec.Code, rec.Body)
+		}
+		var got service.TripFareBreakdown
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if got.Passengers != want.passengers || got.TotalFareCents != want.total || len(got.Shares) != want.passengers {
+			t.Errorf("trip %s: body = %+v, want %d passengers totalling %d", id, got, want.passengers, want.total)
+		}
+	}
+
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"9", http.StatusNotFound, "trip_not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := getTripFare(h, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("trip %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// assertValidationResponse checks the status and, for 422s, the field named
+// in the structured error.
+func assertValidationResponse(t *testing.T, rec *httptest.ResponseRecorder, want int, wantField string) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d (body: %s)", rec.Code, want, rec.Body.String())
+	}
+	body := decodeAPIError(t, rec)
+	if want != http.StatusUnprocessableEntity {
+		return
+	}
+	if body.Code != "validation_failed" || body.Details["field"] != wantField {
+		t.Errorf("body = %+v, want validation_failed on %q", body, wantField)
+	}
+}
@@ -1,16 +1,22 @@
 package handler
 
 import (
-	"encoding/json"
+	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 
 	"github.com/shiva/hintro/internal/model"
 	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+	"github.com/shiva/hintro/pkg/geo"
 )
 
 // ─── Request/Response DTOs ──────────────────────────────────
@@ -26,23 +32,139 @@ type CreateRideRequestBody struct {
 	SeatsNeeded     int     `json:"seats_needed"`
 	LuggageCount    int     `json:"luggage_count"`
 	ToleranceMeters int     `json:"tolerance_meters"`
+
+	// RequiresAccessible limits matching and booking to wheelchair-accessible cabs.
+	RequiresAccessible bool `json:"requires_accessible"`
+
+	// ScheduledAt is an optional future departure (RFC 3339). Rides further
+	// out than the schedule lead time wait outside the matching pool.
+	ScheduledAt *time.Time `json:"scheduled_at,omitempty"`
+
+	// DeadlineAt is an optional latest drop-off time (RFC 3339), accepted on
+	// from_airport rides only.
+	DeadlineAt *time.Time `json:"deadline_at,omitempty"`
+
+	// ClientRequestID is an optional UUID chosen by the client. Retrying a
+	// create with the same one returns the first request instead of a
+	// duplicate.
+	ClientRequestID *string `json:"client_request_id,omitempty"`
+}
+
+// UpdateRideRequestBody is the JSON body for PATCH /api/v1/rides/{id}.
+// Omitted fields are left unchanged.
+type UpdateRideRequestBody struct {
+	SeatsNeeded     *int `json:"seats_needed"`
+	LuggageCount    *int `json:"luggage_count"`
+	ToleranceMeters *int `json:"tolerance_meters"`
+}
+
+// CreateRideResponse is the created (or updated) ride request. When tolerance_meters
+// was above the system maximum it is stored clamped, and the response
+// says so: tolerance_meters is the effective value and
+// requested_tolerance_meters the one sent.
+type CreateRideResponse struct {
+	*model.RideRequest
+	ToleranceClamped         bool `json:"tolerance_clamped,omitempty"`
+	RequestedToleranceMeters int  `json:"requested_tolerance_meters,omitempty"`
 }
 
 // ─── RideHandler ────────────────────────────────────────────
 
 // RideHandler handles ride request CRUD and cancellation.
 type RideHandler struct {
-	repo *repository.RideRequestRepository
+	repo       *repository.RideRequestRepository
+	creator    rideCreator
+	trips      tripReader
+	routes     tripRouteReader
+	seats      tripAvailabilityReader
+	manifests  tripManifestReader
+	statuses   rideStatusReader
+	timelines  rideTimelineReader
+	updater    rideUpdater
+	canceller  rideRequestCanceller
+	pending    userPendingCanceller
+	fleet      groupSizeChecker
+	maxLuggage int
+
+	// maxTolerance is RIDE_MAX_TOLERANCE_METERS; tolerance_meters above it
+	// is clamped (0 = service.MaxToleranceMeters).
+	maxTolerance int
+
+	// scheduleLead is how long before scheduled_at a ride enters matching.
+	scheduleLead time.Duration
+
+	// Counters, when set, has every new pending request added to demand,
+	// and the ones CancelUserPending cancels taken away.
+	Counters service.SurgeCounter
+}
+
+// rideCreator is the part of RideRequestRepository used by CreateRide.
+type rideCreator interface {
+	CreateRideRequest(ctx context.Context, req *model.RideRequest) (*model.RideRequest, bool, error)
+}
+
+// tripReader is the part of RideRequestRepository used by GetTrip.
+type tripReader interface {
+	GetTripByID(ctx context.Context, tripID int64, q repository.PassengerQuery) (*model.Trip, *repository.PassengerPage, error)
+}
+
+// tripRouteReader is the part of RideRequestRepository used by GetTripETA.
+type tripRouteReader interface {
+	GetTripRoute(ctx context.Context, tripID int64) (*repository.TripRoute, error)
+}
+
+// tripAvailabilityReader is the part of RideRequestRepository used by GetTripAvailability.
+type tripAvailabilityReader interface {
+	GetTripAvailability(ctx context.Context, tripID int64) (*repository.TripAvailability, error)
+}
+
+// tripManifestReader is the part of RideRequestRepository used by GetTripPassengers.
+type tripManifestReader interface {
+	GetTripManifest(ctx context.Context, tripID int64) (*repository.TripManifest, error)
+}
+
+// rideStatusReader is the part of RideRequestRepository used by GetRideStatus.
+type rideStatusReader interface {
+	GetRideStatus(ctx context.Context, id int64) (*repository.RideStatus, error)
+}
+
+// rideTimelineReader is the part of RideRequestRepository used by GetRideTimeline.
+type rideTimelineReader interface {
+	GetRideTimeline(ctx context.Context, id int64) (*repository.RideTimeline, error)
+}
+
+// rideUpdater is the part of RideRequestRepository used by UpdateRide.
+type rideUpdater interface {
+	UpdatePendingRequest(ctx context.Context, id int64, upd repository.RideRequestUpdate) (*model.RideRequest, error)
+}
+
+// rideRequestCanceller is the part of RideRequestRepository used by CancelRide.
+type rideRequestCanceller interface {
+	CancelRideRequest(ctx context.Context, requestID int64) error
 }
 
-// NewRideHandler creates a new ride handler.
-func NewRideHandler(repo *repository.RideRequestRepository) *RideHandler {
-	return &RideHandler{repo: repo}
+// userPendingCanceller is the part of RideRequestRepository used by CancelUserPending.
+type userPendingCanceller interface {
+	CancelUserPending(ctx context.Context, userID int64) ([]int64, []repository.SurgeChange, error)
+}
+
+// groupSizeChecker is the part of service.FleetLimits used by CreateRide.
+type groupSizeChecker interface {
+	CheckGroupSize(ctx context.Context, seatsNeeded int) error
+}
+
+// NewRideHandler creates a new ride handler. fleet rejects groups larger
+// than any cab; maxLuggage is the configured per-request luggage limit;
+// maxTolerance is RIDE_MAX_TOLERANCE_METERS; scheduleLead is
+// RIDE_SCHEDULE_LEAD_TIME.
+func NewRideHandler(repo *repository.RideRequestRepository, fleet groupSizeChecker, maxLuggage, maxTolerance int, scheduleLead time.Duration) *RideHandler {
+	return &RideHandler{repo: repo, creator: repo, trips: repo, routes: repo, seats: repo, manifests: repo, statuses: repo, timelines: repo, updater: repo, canceller: repo, pending: repo, fleet: fleet, maxLuggage: maxLuggage, maxTolerance: maxTolerance, scheduleLead: scheduleLead}
 }
 
 // CreateRide handles POST /api/v1/rides
 //
-// Creates a new pending ride request.
+// Creates a new ride request: pending, or scheduled if scheduled_at is
+// further out than the schedule lead time.
 //
 //	Request body:
 //	{
@@ -51,66 +173,284 @@ func NewRideHandler(repo *repository.RideRequestRepository) *RideHandler {
 //	  "dest_lat": 28.5562, "dest_lon": 77.0889,
 //	  "direction": "to_airport",
 //	  "seats_needed": 1, "luggage_count": 1,
-//	  "tolerance_meters": 2000
+//	  "tolerance_meters": 2000,                (clamped to RIDE_MAX_TOLERANCE_METERS)
+//	  "requires_accessible": false,            (optional)
+//	  "scheduled_at": "2025-01-01T06:00:00Z",  (optional; not past, ≤ service.MaxScheduleAhead)
+//	  "client_request_id": "<uuid>"            (optional; dedupes retries)
 //	}
+//
+// Answers 201 with the new request, or 200 with the existing one when the
+// user already created a request under the same client_request_id (the
+// retried body is not compared).
 func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 	var body CreateRideRequestBody
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid JSON body",
-		})
+	if err := decodeJSON(r, &body); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
 		return
 	}
-
-	// Validation
-	if body.UserID <= 0 {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "user_id is required"})
+	req, clamped, ok := h.rideFromBody(w, r, &body)
+	if !ok {
 		return
 	}
-	if body.OriginLat == 0 || body.OriginLon == 0 || body.DestLat == 0 || body.DestLon == 0 {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "origin and destination coordinates are required"})
+
+	created, isNew, err := h.createRide(r.Context(), req)
+	if err != nil {
+		log.Printf("[handler] create ride error: %v", err)
+		writeInternalError(w, err, "failed to create ride request")
 		return
 	}
-	if body.Direction != "to_airport" && body.Direction != "from_airport" {
-		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "direction must be 'to_airport' or 'from_airport'"})
+	if !isNew {
+		writeJSON(w, http.StatusOK, CreateRideResponse{RideRequest: created})
 		return
 	}
+
+	resp := CreateRideResponse{RideRequest: created}
+	if clamped {
+		resp.ToleranceClamped = true
+		resp.RequestedToleranceMeters = body.ToleranceMeters
+	}
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// createRide stores req and, when it is a new pending request, adds it to
+// the surge demand counters. A scheduled request is not demand yet; the
+// reconciler counts it once it activates.
+func (h *RideHandler) createRide(ctx context.Context, req *model.RideRequest) (*model.RideRequest, bool, error) {
+	created, isNew, err := h.creator.CreateRideRequest(ctx, req)
+	if err == nil && isNew && h.Counters != nil && created.Status != model.RequestScheduled {
+		h.Counters.AddDemand(ctx, created.Origin, created.Direction, 1)
+	}
+	return created, isNew, err
+}
+
+// minRideDistanceMeters is the shortest origin→destination a ride may
+// cover. Closer pairs are the same point up to GPS noise (or a client
+// sending the pickup twice); they can't be routed or priced, so create
+// rejects them instead of booking a zero-length seat.
+const minRideDistanceMeters = 10
+
+// rideFromBody validates a CreateRide body, filling in defaults, and
+// builds the ride request to store. clamped reports that tolerance_meters
+// was lowered to the configured cap (RIDE_MAX_TOLERANCE_METERS). On a
+// validation failure it writes the 422 and returns ok=false.
+func (h *RideHandler) rideFromBody(w http.ResponseWriter, r *http.Request, body *CreateRideRequestBody) (req *model.RideRequest, clamped bool, ok bool) {
+	// Validation (semantic failures → 422 with the offending field)
+	if body.UserID <= 0 {
+		writeFieldError(w, "user_id", "is required")
+		return nil, false, false
+	}
+	origin := model.Location{Lat: body.OriginLat, Lon: body.OriginLon}
+	dest := model.Location{Lat: body.DestLat, Lon: body.DestLon}
+	if !validateLocation(w, origin, "origin_lat", "origin_lon") ||
+		!validateLocation(w, dest, "dest_lat", "dest_lon") {
+		return nil, false, false
+	}
+	if geo.HaversineM(origin, dest) < minRideDistanceMeters {
+		writeFieldError(w, "dest_lat", fmt.Sprintf("must be at least %d m from the origin", minRideDistanceMeters))
+		return nil, false, false
+	}
+	if !model.TripDirection(body.Direction).Valid() {
+		writeEnumFieldError(w, "direction", "must be 'to_airport' or 'from_airport'", model.TripDirections)
+		return nil, false, false
+	}
 	if body.SeatsNeeded <= 0 {
 		body.SeatsNeeded = 1
 	}
 	if body.LuggageCount < 0 {
 		body.LuggageCount = 0
 	}
-	if body.LuggageCount > model.MaxLuggagePerRequest {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "luggage_count must be between 0 and 8",
-		})
-		return
+	if body.LuggageCount > h.maxLuggage {
+		writeFieldError(w, "luggage_count", fmt.Sprintf("must be between 0 and %d", h.maxLuggage))
+		return nil, false, false
 	}
 	if body.ToleranceMeters <= 0 {
-		body.ToleranceMeters = 2000 // Default 2km
+		// Default 2km, or the cap if it is lower: a default is not clamped.
+		body.ToleranceMeters, _ = service.ClampTolerance(2000, h.maxTolerance)
+	}
+	now := time.Now()
+	switch err := service.ValidateScheduledAt(body.ScheduledAt, now); {
+	case errors.Is(err, service.ErrScheduledInPast):
+		writeFieldError(w, "scheduled_at", "must not be in the past")
+		return nil, false, false
+	case errors.Is(err, service.ErrScheduledTooFar):
+		writeFieldError(w, "scheduled_at", fmt.Sprintf("must be at most %d days ahead", int(service.MaxScheduleAhead.Hours()/24)))
+		return nil, false, false
+	}
+	switch err := service.ValidateDeadlineAt(model.TripDirection(body.Direction), body.DeadlineAt, body.ScheduledAt, now); {
+	case errors.Is(err, service.ErrDeadlineNotFromAirport):
+		writeFieldError(w, "deadline_at", "is only accepted on from_airport rides")
+		return nil, false, false
+	case errors.Is(err, service.ErrDeadlineInPast):
+		writeFieldError(w, "deadline_at", "must be after departure")
+		return nil, false, false
+	}
+	if body.ClientRequestID != nil {
+		id, ok := normalizeUUID(*body.ClientRequestID)
+		if !ok {
+			writeFieldError(w, "client_request_id", "must be a UUID")
+			return nil, false, false
+		}
+		body.ClientRequestID = &id
 	}
+	// A tolerance beyond the cap would only widen the spatial scan (and
+	// beyond the hard detour ceiling can never be used); store the
+	// effective value instead of a misleading one.
+	tolerance, clamped := service.ClampTolerance(body.ToleranceMeters, h.maxTolerance)
 
-	req := &model.RideRequest{
-		UserID:          body.UserID,
-		Origin:          model.Location{Lat: body.OriginLat, Lon: body.OriginLon},
-		Destination:     model.Location{Lat: body.DestLat, Lon: body.DestLon},
-		Direction:       model.TripDirection(body.Direction),
-		SeatsNeeded:     body.SeatsNeeded,
-		LuggageCount:    body.LuggageCount,
-		ToleranceMeters: body.ToleranceMeters,
+	// A group bigger than every cab would sit unmatched until it expired.
+	if !h.checkGroupSize(w, r, body.SeatsNeeded) {
+		return nil, false, false
 	}
 
-	created, err := h.repo.CreateRideRequest(r.Context(), req)
+	req = &model.RideRequest{
+		UserID:             body.UserID,
+		Origin:             origin,
+		Destination:        dest,
+		Direction:          model.TripDirection(body.Direction),
+		SeatsNeeded:        body.SeatsNeeded,
+		LuggageCount:       body.LuggageCount,
+		ToleranceMeters:    tolerance,
+		ScheduledAt:        utcTime(body.ScheduledAt),
+		DeadlineAt:         utcTime(body.DeadlineAt),
+		Status:             service.InitialStatus(body.ScheduledAt, now, h.scheduleLead),
+		RequiresAccessible: body.RequiresAccessible,
+		ClientRequestID:    body.ClientRequestID,
+	}
+
+	return req, clamped, true
+}
+
+// utcTime returns t in UTC, or nil. Clients may send any RFC 3339 offset;
+// storing UTC means the request is echoed back with a Z suffix, like
+// every timestamp the database serves.
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// normalizeUUID reports whether s is a UUID in the canonical 8-4-4-4-12
+// hex form and returns it lower-cased, as Postgres prints it.
+func normalizeUUID(s string) (string, bool) {
+	if len(s) != 36 {
+		return "", false
+	}
+	for i, c := range s {
+		switch {
+		case i == 8 || i == 13 || i == 18 || i == 23:
+			if c != '-' {
+				return "", false
+			}
+		case '0' <= c && c <= '9', 'a' <= c && c <= 'f', 'A' <= c && c <= 'F':
+		default:
+			return "", false
+		}
+	}
+	return strings.ToLower(s), true
+}
+
+// checkGroupSize writes a 422 group_too_large if no cab in the fleet can
+// carry seatsNeeded. Returns false if a response was written.
+func (h *RideHandler) checkGroupSize(w http.ResponseWriter, r *http.Request, seatsNeeded int) bool {
+	var tooLarge *service.GroupTooLargeError
+	if err := h.fleet.CheckGroupSize(r.Context(), seatsNeeded); !errors.As(err, &tooLarge) {
+		return true
+	}
+	writeAPIError(w, APIError{
+		Code: "group_too_large",
+		Message: fmt.Sprintf("No cab can carry %d passengers; the largest has %d seats. Split the group into several ride requests.",
+			tooLarge.SeatsNeeded, tooLarge.MaxSeats),
+		Details: map[string]interface{}{
+			"field":        "seats_needed",
+			"seats_needed": tooLarge.SeatsNeeded,
+			"max_seats":    tooLarge.MaxSeats,
+		},
+	})
+	return false
+}
+
+// UpdateRide handles PATCH /api/v1/rides/{id}
+//
+// Changes seats_needed, luggage_count and/or tolerance_meters on a request
+// that has not been matched yet. The values are validated as on create
+// (tolerance is clamped the same way); the row is locked while updating,
+// so the change cannot slip in under a concurrent booking.
+//
+//	Request body (at least one field):
+//	{"seats_needed": 2, "luggage_count": 1, "tolerance_meters": 3000}
+//
+// Response codes:
+//
+//	200  — Updated (returns the ride request)
+//	400  — Invalid id or malformed JSON
+//	404  — Ride request not found
+//	409  — Request no longer pending: matched, cancelled or expired (not_pending)
+//	422  — A field is out of range, or no cab can carry the group
+//	500  — Unexpected error
+func (h *RideHandler) UpdateRide(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		log.Printf("[handler] create ride error: %v", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": "failed to create ride request",
-		})
+		writeError(w, "bad_request", "invalid ride id")
 		return
 	}
 
-	writeJSON(w, http.StatusCreated, created)
+	var body UpdateRideRequestBody
+	if err := decodeJSON(r, &body); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
+		return
+	}
+
+	if body.SeatsNeeded == nil && body.LuggageCount == nil && body.ToleranceMeters == nil {
+		writeError(w, "validation_failed", "nothing to update: send seats_needed, luggage_count or tolerance_meters")
+		return
+	}
+	upd := repository.RideRequestUpdate{SeatsNeeded: body.SeatsNeeded, LuggageCount: body.LuggageCount}
+	if body.SeatsNeeded != nil {
+		if *body.SeatsNeeded <= 0 {
+			writeFieldError(w, "seats_needed", "must be at least 1")
+			return
+		}
+		if !h.checkGroupSize(w, r, *body.SeatsNeeded) {
+			return
+		}
+	}
+	if body.LuggageCount != nil && (*body.LuggageCount < 0 || *body.LuggageCount > h.maxLuggage) {
+		writeFieldError(w, "luggage_count", fmt.Sprintf("must be between 0 and %d", h.maxLuggage))
+		return
+	}
+	clamped := false
+	if body.ToleranceMeters != nil {
+		if *body.ToleranceMeters <= 0 {
+			writeFieldError(w, "tolerance_meters", "must be positive")
+			return
+		}
+		var tolerance int
+		tolerance, clamped = service.ClampTolerance(*body.ToleranceMeters, h.maxTolerance)
+		upd.ToleranceMeters = &tolerance
+	}
+
+	updated, err := h.updater.UpdatePendingRequest(r.Context(), id, upd)
+	switch {
+	case errors.Is(err, repository.ErrRequestNotFound):
+		writeError(w, "not_found", "ride request not found")
+		return
+	case errors.Is(err, repository.ErrRequestNotEditable):
+		writeError(w, "not_pending", "This ride request is no longer pending (matched, cancelled or expired) and can no longer be changed.")
+		return
+	case err != nil:
+		log.Printf("[handler] update ride error: %v", err)
+		writeInternalError(w, err, "failed to update ride request")
+		return
+	}
+
+	resp := CreateRideResponse{RideRequest: updated}
+	if clamped {
+		resp.ToleranceClamped = true
+		resp.RequestedToleranceMeters = *body.ToleranceMeters
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // GetRide handles GET /api/v1/rides/{id}
@@ -119,59 +459,113 @@ func (h *RideHandler) CreateRide(w http.ResponseWriter, r *http.Request) {
 func (h *RideHandler) GetRide(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid ride id",
-		})
+		writeError(w, "bad_request", "invalid ride id")
 		return
 	}
 
 	rideReq, err := h.repo.GetRideRequestByID(r.Context(), id)
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{
-			"error": "ride request not found",
-		})
+		writeError(w, "not_found", "ride request not found")
 		return
 	}
 
 	writeJSON(w, http.StatusOK, rideReq)
 }
 
+// GetRideStatus handles GET /api/v1/rides/{id}/status
+//
+// A lightweight poll: returns only {status, trip_id, updated_at} with an
+// ETag. A request whose If-None-Match still matches gets 304 and no body.
+func (h *RideHandler) GetRideStatus(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid ride id")
+		return
+	}
+
+	st, err := h.statuses.GetRideStatus(r.Context(), id)
+	if errors.Is(err, repository.ErrRequestNotFound) {
+		writeError(w, "not_found", "ride request not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] ride status error: %v", err)
+		writeInternalError(w, err, "failed to load ride status")
+		return
+	}
+
+	etag := rideStatusETag(st)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// GetRideTimeline handles GET /api/v1/rides/{id}/timeline
+//
+// Returns every event recorded for the ride (created, matched, booked,
+// cancelled, completed, ...) with its timestamp, oldest first.
+func (h *RideHandler) GetRideTimeline(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid ride id")
+		return
+	}
+
+	tl, err := h.timelines.GetRideTimeline(r.Context(), id)
+	if errors.Is(err, repository.ErrRequestNotFound) {
+		writeError(w, "not_found", "ride request not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] ride timeline error: %v", err)
+		writeInternalError(w, err, "failed to load ride timeline")
+		return
+	}
+	writeJSON(w, http.StatusOK, tl)
+}
+
+// rideStatusETag is a strong validator over every field GetRideStatus
+// returns, so any status or trip change produces a new tag.
+func rideStatusETag(st *repository.RideStatus) string {
+	var tripID int64
+	if st.TripID != nil {
+		tripID = *st.TripID
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s|%d|%d", st.Status, tripID, st.UpdatedAt.UnixNano())
+	return fmt.Sprintf(`"%x"`, h.Sum64())
+}
+
+// etagMatches reports whether an If-None-Match header value names etag.
+// Weak comparison, as RFC 9110 requires for If-None-Match.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
 // CancelRide handles POST /api/v1/rides/{id}/cancel
 //
 // Cancels a pending or matched ride request, releasing the seat
-// back to the cab atomically (pessimistic locking).
+// back to the cab atomically (pessimistic locking). Retrying on an
+// already-cancelled request also returns 200.
 func (h *RideHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid ride id",
-		})
+		writeError(w, "bad_request", "invalid ride id")
 		return
 	}
 
-	err = h.repo.CancelRideRequest(r.Context(), id)
-	if err != nil {
-		errMsg := err.Error()
-		// Not found
-		if errors.Is(err, errors.New("no rows")) || containsAny(errMsg, "no rows", "lock request") {
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error":   "not_found",
-				"message": "Ride request not found.",
-			})
-			return
-		}
-		// Already completed/cancelled
-		if containsAny(errMsg, "cannot cancel") {
-			writeJSON(w, http.StatusConflict, map[string]string{
-				"error":   "not_cancellable",
-				"message": "Ride request is not in a cancellable state.",
-			})
-			return
-		}
-		log.Printf("[handler] cancel ride error: %v", err)
-		writeJSON(w, http.StatusInternalServerError, map[string]string{
-			"error": "failed to cancel ride request",
-		})
+	if err := h.canceller.CancelRideRequest(r.Context(), id); err != nil {
+		writeCancelRideError(w, err)
 		return
 	}
 
@@ -181,32 +575,210 @@ func (h *RideHandler) CancelRide(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// CancelUserPending handles POST /api/v1/users/{id}/cancel-pending
+//
+// Cancels all of a user's PENDING requests in one transaction, e.g. when
+// they close the app. Matched, confirmed and scheduled requests are left
+// alone; cancel those one at a time. Mounted behind middleware.RequireAdmin
+// (there is no per-user auth to prove ownership with yet).
+//
+// Response codes:
+//
+//	200 — {"user_id", "cancelled", "request_ids"}; cancelled may be 0
+//	400 — Invalid user id
+//	403 — Missing/invalid admin token
+//	404 — User not found
+func (h *RideHandler) CancelUserPending(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid user id")
+		return
+	}
+
+	ids, surge, err := h.pending.CancelUserPending(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, repository.ErrUserNotFound) {
+			writeError(w, "not_found", "User not found.")
+			return
+		}
+		log.Printf("[handler] cancel user pending error: %v", err)
+		writeInternalError(w, err, "failed to cancel pending requests")
+		return
+	}
+	service.CountSurge(r.Context(), h.Counters, surge)
+	if ids == nil {
+		ids = []int64{}
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"user_id":     userID,
+		"cancelled":   len(ids),
+		"request_ids": ids,
+	})
+}
+
+// writeCancelRideError maps a CancelRideRequest error to an HTTP response.
+func writeCancelRideError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, repository.ErrRequestNotFound):
+		writeError(w, "not_found", "Ride request not found.")
+	case containsAny(err.Error(), "cannot cancel"):
+		// Already completed/cancelled
+		writeError(w, "not_cancellable", "Ride request is not in a cancellable state.")
+	default:
+		log.Printf("[handler] cancel ride error: %v", err)
+		writeInternalError(w, err, "failed to cancel ride request")
+	}
+}
+
 // GetTrip handles GET /api/v1/trips/{id}
 //
-// Returns trip details with its passenger list.
+// Returns trip details with one page of its passenger list (oldest first).
+//
+// Query (all optional):
+//
+//	status=matched,confirmed   only passengers in these request statuses
+//	limit=50                   page size (default 50, capped at 200)
+//	offset=0                   passengers to skip
 func (h *RideHandler) GetTrip(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
 	if err != nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "invalid trip id",
-		})
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	q := r.URL.Query()
+	var pq repository.PassengerQuery
+	if !parsePaging(w, q, &pq.Limit, &pq.Offset) {
 		return
 	}
+	statuses, ok := parseRequestStatuses(w, q)
+	if !ok {
+		return
+	}
+	pq.Statuses = statuses
 
-	trip, passengers, err := h.repo.GetTripByID(r.Context(), id)
+	trip, page, err := h.trips.GetTripByID(r.Context(), id, pq)
+	if errors.Is(err, repository.ErrTripNotFound) {
+		writeError(w, "not_found", "trip not found")
+		return
+	}
 	if err != nil {
-		writeJSON(w, http.StatusNotFound, map[string]string{
-			"error": "trip not found",
-		})
+		log.Printf("[handler] get trip error: %v", err)
+		writeInternalError(w, err, "failed to load trip")
 		return
 	}
 
 	writeJSON(w, http.StatusOK, map[string]interface{}{
 		"trip":       trip,
-		"passengers": passengers,
+		"passengers": page.Passengers,
+		"limit":      page.Limit,
+		"offset":     page.Offset,
+		"has_more":   page.HasMore,
+	})
+}
+
+// GetTripETA handles GET /api/v1/trips/{id}/eta
+//
+// Returns each rider's estimated pickup and drop-off time (minutes from
+// now) on an in-progress trip, measured from the cab's last reported
+// position along the planned route. Riders already aboard have no pickup
+// ETA (see service.TripETAs).
+//
+//	200 — {"trip_id", "etas": [{"request_id", "pickup_eta_minutes", "dropoff_eta_minutes"}]}
+//	404 — trip not found
+//	409 — trip not in progress, or its cab has no known location
+func (h *RideHandler) GetTripETA(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	route, err := h.routes.GetTripRoute(r.Context(), id)
+	if errors.Is(err, repository.ErrTripNotFound) {
+		writeError(w, "not_found", "trip not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] get trip route error: %v", err)
+		writeInternalError(w, err, "failed to load trip")
+		return
+	}
+
+	etas, err := service.TripETAs(route)
+	switch {
+	case errors.Is(err, service.ErrTripNotStarted):
+		writeError(w, "trip_not_started", "ETAs are only available once the trip is in progress.")
+		return
+	case errors.Is(err, service.ErrNoCabLocation):
+		writeError(w, "cab_location_unknown", "The trip's cab has not reported its location yet.")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"trip_id": id,
+		"etas":    etas,
 	})
 }
 
+// GetTripAvailability handles GET /api/v1/trips/{id}/availability
+//
+// Returns the trip's cab capacity, the seats and luggage its riders take
+// up, and what is left. Only a planned trip can take more riders; any
+// other status reports nothing remaining.
+//
+//	200 — repository.TripAvailability
+//	404 — trip not found
+func (h *RideHandler) GetTripAvailability(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	avail, err := h.seats.GetTripAvailability(r.Context(), id)
+	if errors.Is(err, repository.ErrTripNotFound) {
+		writeError(w, "not_found", "trip not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] trip availability error: %v", err)
+		writeInternalError(w, err, "failed to load trip availability")
+		return
+	}
+	writeJSON(w, http.StatusOK, avail)
+}
+
+// GetTripPassengers handles GET /api/v1/trips/{id}/passengers
+//
+// Returns the driver's manifest: the trip's matched, confirmed and
+// scheduled riders in the order the route reaches them, with each rider's
+// seats, luggage and pickup and drop-off coordinates. Cancelled and
+// completed riders are left out.
+//
+//	200 — repository.TripManifest
+//	404 — trip not found
+func (h *RideHandler) GetTripPassengers(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, "bad_request", "invalid trip id")
+		return
+	}
+
+	manifest, err := h.manifests.GetTripManifest(r.Context(), id)
+	if errors.Is(err, repository.ErrTripNotFound) {
+		writeError(w, "not_found", "trip not found")
+		return
+	}
+	if err != nil {
+		log.Printf("[handler] trip passengers error: %v", err)
+		writeInternalError(w, err, "failed to load trip passengers")
+		return
+	}
+	writeJSON(w, http.StatusOK, manifest)
+}
+
 // containsAny checks if s contains any of the substrings.
 func containsAny(s string, subs ...string) bool {
 	for _, sub := range subs {
@@ -0,0 +1,1163 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/shiva/hintro/internal/middleware"
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+func TestWriteCancelRideError_NotFoundIs404(t *testing.T) {
+	// The repository wraps the sentinel with request context, as CancelRideRequest does.
+	err := fmt.Errorf("cancel: request %d: %w", 999, repository.ErrRequestNotFound)
+
+	rec := httptest.NewRecorder()
+	writeCancelRideError(rec, err)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+// missingRide is a rideRequestCanceller for a request id the database does not have.
+type missingRide struct{}
+
+func (missingRide) CancelRideRequest(_ context.Context, id int64) error {
+	return fmt.Errorf("cancel: request %d: %w", id, repository.ErrRequestNotFound)
+}
+
+func TestCancelRide_MissingRequestIs404(t *testing.T) {
+	h := &RideHandler{canceller: missingRide{}}
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/rides/999/cancel", nil), map[string]string{"id": "999"})
+
+	rec := httptest.NewRecorder()
+	h.CancelRide(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestWriteCancelRideError_NotCancellableIs409(t *testing.T) {
+	err := fmt.Errorf("cancel: request %d has status '%s', cannot cancel", 7, "completed")
+
+	rec := httptest.NewRecorder()
+	writeCancelRideError(rec, err)
+
+	if rec.Code != http.StatusConflict {
+		t.Errorf("status = %d, want 409", rec.Code)
+	}
+}
+
+func TestWriteCancelRideError_OtherErrorsAre500(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeCancelRideError(rec, fmt.Errorf("cancel: begin tx: connection refused"))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want 500", rec.Code)
+	}
+}
+
+func TestCreateRide_Validation(t *testing.T) {
+	h := NewRideHandler(nil, nil, model.MaxLuggagePerRequest, service.MaxToleranceMeters, 30*time.Minute)
+
+	tests := []struct {
+		name      string
+		body      string
+		want      int
+		wantField string
+	}{
+		{"malformed json", `{"user_id":1,`, http.StatusBadRequest, ""},
+		{"unknown field", `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,"direction":"to_airport","seats_need":3}`, http.StatusBadRequest, ""},
+		{"missing user", `{"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,"direction":"to_airport"}`, http.StatusUnprocessableEntity, "user_id"},
+		{"origin lat out of range", `{"user_id":1,"origin_lat":98.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,"direction":"to_airport"}`, http.StatusUnprocessableEntity, "origin_lat"},
+		{"bad direction", `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,"direction":"sideways"}`, http.StatusUnprocessableEntity, "direction"},
+		{"identical origin and destination", `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.70,"dest_lon":77.10,"direction":"to_airport"}`, http.StatusUnprocessableEntity, "dest_lat"},
+		{"destination 3m from origin", `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.70003,"dest_lon":77.10,"direction":"to_airport"}`, http.StatusUnprocessableEntity, "dest_lat"},
+		// 22 m apart is a real ride: validation moves on to the direction.
+		{"destination 22m from origin", `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.7002,"dest_lon":77.10,"direction":"sideways"}`, http.StatusUnprocessableEntity, "direction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.CreateRide(rec, req)
+			assertValidationResponse(t, rec, tt.want, tt.wantField)
+		})
+	}
+}
+
+func TestCreateRide_BadDirectionListsAllowed(t *testing.T) {
+	h := NewRideHandler(nil, nil, model.MaxLuggagePerRequest, service.MaxToleranceMeters, 30*time.Minute)
+	body := `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,"direction":"sideways"}`
+	rec := httptest.NewRecorder()
+	h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+
+	got := decodeAPIError(t, rec)
+	allowed, _ := got.Details["allowed"].([]interface{})
+	if len(allowed) != 2 || allowed[0] != "to_airport" || allowed[1] != "from_airport" {
+		t.Errorf("details = %v, want allowed [to_airport from_airport]", got.Details)
+	}
+}
+
+func TestCreateRide_ScheduledAtBounds(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		offset     time.Duration
+		want       int
+		wantStatus model.RequestStatus
+	}{
+		{"past", -time.Hour, http.StatusUnprocessableEntity, ""},
+		{"near future", 10 * time.Minute, http.StatusCreated, model.RequestPending},
+		{"later today", 5 * time.Hour, http.StatusCreated, model.RequestScheduled},
+		{"far future", service.MaxScheduleAhead + 24*time.Hour, http.StatusUnprocessableEntity, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			creator := &echoCreator{}
+			h := &RideHandler{creator: creator, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest, scheduleLead: 30 * time.Minute}
+			body := fmt.Sprintf(`{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,`+
+				`"direction":"to_airport","scheduled_at":%q}`, time.Now().Add(tt.offset).Format(time.RFC3339))
+
+			rec := httptest.NewRecorder()
+			h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+			if tt.want != http.StatusCreated {
+				assertValidationResponse(t, rec, tt.want, "scheduled_at")
+				if creator.got != nil {
+					t.Error("ride was created despite an out-of-range scheduled_at")
+				}
+				return
+			}
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.want, rec.Body)
+			}
+			if creator.got.Status != tt.wantStatus {
+				t.Errorf("stored status = %s, want %s", creator.got.Status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestCreateRide_DeadlineAt(t *testing.T) {
+	deadline := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	for _, tt := range []struct {
+		name      string
+		direction string
+		deadline  time.Time
+		want      int
+	}{
+		{"from airport", "from_airport", deadline, http.StatusCreated},
+		{"to airport", "to_airport", deadline, http.StatusUnprocessableEntity},
+		{"past", "from_airport", time.Now().Add(-time.Hour), http.StatusUnprocessableEntity},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			creator := &echoCreator{}
+			h := &RideHandler{creator: creator, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest, scheduleLead: 30 * time.Minute}
+			body := fmt.Sprintf(`{"user_id":1,"origin_lat":28.55,"origin_lon":77.08,"dest_lat":28.70,"dest_lon":77.10,`+
+				`"direction":%q,"deadline_at":%q}`, tt.direction, tt.deadline.Format(time.RFC3339))
+
+			rec := httptest.NewRecorder()
+			h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+			if tt.want != http.StatusCreated {
+				assertValidationResponse(t, rec, tt.want, "deadline_at")
+				return
+			}
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d (body: %s)", rec.Code, tt.want, rec.Body)
+			}
+			if creator.got.DeadlineAt == nil || !creator.got.DeadlineAt.Equal(deadline) {
+				t.Errorf("stored deadline_at = %v, want %s", creator.got.DeadlineAt, deadline)
+			}
+		})
+	}
+}
+
+func TestCreateRide_TimestampsServedInUTC(t *testing.T) {
+	creator := &echoCreator{}
+	h := &RideHandler{creator: creator, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest, scheduleLead: 30 * time.Minute}
+	ist := time.FixedZone("IST", 5*3600+1800)
+	departure := time.Now().Add(2 * time.Hour).Truncate(time.Second).In(ist)
+	body := fmt.Sprintf(`{"user_id":1,"origin_lat":28.55,"origin_lon":77.08,"dest_lat":28.70,"dest_lon":77.10,`+
+		`"direction":"from_airport","scheduled_at":%q,"deadline_at":%q}`,
+		departure.Format(time.RFC3339), departure.Add(time.Hour).Format(time.RFC3339))
+
+	rec := httptest.NewRecorder()
+	h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("status = %d, want 201 (body: %s)", rec.Code, rec.Body)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for field, want := range map[string]time.Time{"scheduled_at": departure, "deadline_at": departure.Add(time.Hour)} {
+		raw, _ := got[field].(string)
+		if want := want.UTC().Format(time.RFC3339); raw != want {
+			t.Errorf("%s = %q, want %q (RFC 3339 in UTC)", field, raw, want)
+		}
+	}
+	if loc := creator.got.ScheduledAt.Location(); loc != time.UTC {
+		t.Errorf("stored scheduled_at in %s, want UTC", loc)
+	}
+}
+
+func TestCreateRide_CustomLuggageLimit(t *testing.T) {
+	h := NewRideHandler(nil, nil, 2, service.MaxToleranceMeters, 30*time.Minute)
+	body := `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,` +
+		`"direction":"to_airport","luggage_count":3}`
+
+	rec := httptest.NewRecorder()
+	h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+
+	assertValidationResponse(t, rec, http.StatusUnprocessableEntity, "luggage_count")
+	if !strings.Contains(rec.Body.String(), "between 0 and 2") {
+		t.Errorf("body = %s, want the configured limit in the message", rec.Body)
+	}
+}
+
+// fixedFleet is a groupSizeChecker whose largest cab has maxSeats seats.
+type fixedFleet int
+
+func (f fixedFleet) CheckGroupSize(_ context.Context, seatsNeeded int) error {
+	if seatsNeeded > int(f) {
+		return &service.GroupTooLargeError{SeatsNeeded: seatsNeeded, MaxSeats: int(f)}
+	}
+	return nil
+}
+
+func TestCreateRide_GroupLargerThanAnyCab(t *testing.T) {
+	h := NewRideHandler(nil, fixedFleet(4), model.MaxLuggagePerRequest, service.MaxToleranceMeters, 30*time.Minute)
+	body := `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,` +
+		`"direction":"to_airport","seats_needed":6}`
+
+	rec := httptest.NewRecorder()
+	h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422 (body: %s)", rec.Code, rec.Body)
+	}
+	got := decodeAPIError(t, rec)
+	if got.Code != "group_too_large" || got.Details["max_seats"] != 4.0 || got.Details["seats_needed"] != 6.0 {
+		t.Errorf("body = %+v, want group_too_large with 6 needed, 4 max", got)
+	}
+}
+
+// echoCreator stores nothing; it returns the request it was given with an id.
+type echoCreator struct{ got *model.RideRequest }
+
+func (f *echoCreator) CreateRideRequest(_ context.Context, req *model.RideRequest) (*model.RideRequest, bool, error) {
+	f.got = req
+	created := *req
+	created.ID = 1
+	return &created, true, nil
+}
+
+// dedupeCreator keeps created requests and, like the unique index on
+// (user_id, client_request_id), returns the earlier one on a repeat ID.
+type dedupeCreator struct {
+	requests []*model.RideRequest
+}
+
+func (f *dedupeCreator) CreateRideRequest(_ context.Context, req *model.RideRequest) (*model.RideRequest, bool, error) {
+	for _, rr := range f.requests {
+		if req.ClientRequestID != nil && rr.ClientRequestID != nil &&
+			rr.UserID == req.UserID && *rr.ClientRequestID == *req.ClientRequestID {
+			return rr, false, nil
+		}
+	}
+	created := *req
+	created.ID = int64(len(f.requests) + 1)
+	f.requests = append(f.requests, &created)
+	return &created, true, nil
+}
+
+func TestCreateRide_ClientRequestIDDedupesRetries(t *testing.T) {
+	creator := &dedupeCreator{}
+	h := &RideHandler{creator: creator, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest}
+	post := func(userID int, clientID string) (int, model.RideRequest) {
+		t.Helper()
+		body := fmt.Sprintf(`{"user_id":%d,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,`+
+			`"direction":"to_airport","client_request_id":%q}`, userID, clientID)
+		rec := httptest.NewRecorder()
+		h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+		var got model.RideRequest
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return rec.Code, got
+	}
+
+	const id = "6F9619FF-8B86-D011-B42D-00C04FC964FF"
+	code, first := post(1, id)
+	if code != http.StatusCreated || first.ID != 1 {
+		t.Fatalf("first create: %d with id %d, want 201 with id 1", code, first.ID)
+	}
+	if first.ClientRequestID == nil || *first.ClientRequestID != strings.ToLower(id) {
+		t.Errorf("client_request_id = %v, want it lower-cased", first.ClientRequestID)
+	}
+
+	code, retry := post(1, strings.ToLower(id))
+	if code != http.StatusOK || retry.ID != first.ID {
+		t.Errorf("retry: %d with id %d, want 200 with id %d", code, retry.ID, first.ID)
+	}
+	if code, other := post(2, id); code != http.StatusCreated || other.ID == first.ID {
+		t.Errorf("other user: %d with id %d, want 201 and a new request", code, other.ID)
+	}
+	if len(creator.requests) != 2 {
+		t.Errorf("stored %d requests, want 2", len(creator.requests))
+	}
+}
+
+func TestCreateRide_RejectsMalformedClientRequestID(t *testing.T) {
+	h := &RideHandler{creator: &dedupeCreator{}, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest}
+	for _, bad := range []string{"", "retry-1", "6f9619ff8b86d011b42d00c04fc964ff", "6f9619ff-8b86-d011-b42d-00c04fc964fg"} {
+		body := `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,` +
+			`"direction":"to_airport","client_request_id":"` + bad + `"}`
+		rec := httptest.NewRecorder()
+		h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+		assertValidationResponse(t, rec, http.StatusUnprocessableEntity, "client_request_id")
+	}
+}
+
+// demandCounter sums the demand deltas it is given per direction, and the
+// supply deltas.
+type demandCounter struct {
+	demand map[model.TripDirection]int
+	supply int
+}
+
+func (c *demandCounter) AddDemand(_ context.Context, _ model.Location, dir model.TripDirection, delta int) {
+	c.demand[dir] += delta
+}
+
+func (c *demandCounter) AddSupply(_ context.Context, _ model.Location, delta int) {
+	c.supply += delta
+}
+
+func TestCreateRide_NewPendingRequestBumpsDemand(t *testing.T) {
+	counters := &demandCounter{demand: map[model.TripDirection]int{}}
+	h := &RideHandler{creator: &dedupeCreator{}, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest,
+		scheduleLead: 30 * time.Minute, Counters: counters}
+	post := func(extra string) int {
+		t.Helper()
+		body := `{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,` +
+			`"direction":"to_airport"` + extra + `}`
+		rec := httptest.NewRecorder()
+		h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+		return rec.Code
+	}
+
+	const retried = `,"client_request_id":"6f9619ff-8b86-d011-b42d-00c04fc964ff"`
+	if code := post(retried); code != http.StatusCreated {
+		t.Fatalf("create: %d, want 201", code)
+	}
+	if counters.demand[model.DirectionToAirport] != 1 {
+		t.Errorf("to_airport demand = %d, want 1 after a create", counters.demand[model.DirectionToAirport])
+	}
+
+	// A retry returns the stored request and a scheduled ride is not demand
+	// yet: neither counts.
+	if code := post(retried); code != http.StatusOK {
+		t.Fatalf("retry: %d, want 200", code)
+	}
+	later := time.Now().Add(5 * time.Hour).Format(time.RFC3339)
+	if code := post(`,"scheduled_at":"` + later + `"`); code != http.StatusCreated {
+		t.Fatalf("scheduled create: %d, want 201", code)
+	}
+	if counters.demand[model.DirectionToAirport] != 1 {
+		t.Errorf("to_airport demand = %d, want still 1", counters.demand[model.DirectionToAirport])
+	}
+}
+
+func TestCreateRide_ClampsOverlargeTolerance(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		tolerance     int
+		maxTolerance  int
+		wantEffective int
+		wantClamped   bool
+	}{
+		{"within max", 3000, 0, 3000, false},
+		{"over max", 50000, 0, service.MaxToleranceMeters, true},
+		{"within configured cap", 2500, 3000, 2500, false},
+		{"over configured cap", 4000, 3000, 3000, true},
+		{"default under a lower cap", 0, 1500, 1500, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			creator := &echoCreator{}
+			h := &RideHandler{creator: creator, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest, maxTolerance: tt.maxTolerance}
+			body := fmt.Sprintf(`{"user_id":1,"origin_lat":28.70,"origin_lon":77.10,"dest_lat":28.55,"dest_lon":77.08,`+
+				`"direction":"to_airport","tolerance_meters":%d}`, tt.tolerance)
+
+			rec := httptest.NewRecorder()
+			h.CreateRide(rec, httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body)))
+			if rec.Code != http.StatusCreated {
+				t.Fatalf("status = %d, want 201 (body: %s)", rec.Code, rec.Body)
+			}
+			if creator.got.ToleranceMeters != tt.wantEffective {
+				t.Errorf("stored tolerance = %d, want %d", creator.got.ToleranceMeters, tt.wantEffective)
+			}
+
+			var got struct {
+				ToleranceMeters          int  `json:"tolerance_meters"`
+				ToleranceClamped         bool `json:"tolerance_clamped"`
+				RequestedToleranceMeters int  `json:"requested_tolerance_meters"`
+			}
+			if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			wantRequested := 0
+			if tt.wantClamped {
+				wantRequested = tt.tolerance
+			}
+			if got.ToleranceMeters != tt.wantEffective || got.ToleranceClamped != tt.wantClamped || got.RequestedToleranceMeters != wantRequested {
+				t.Errorf("body = %+v, want effective %d, clamped %v, requested %d",
+					got, tt.wantEffective, tt.wantClamped, wantRequested)
+			}
+		})
+	}
+}
+
+func TestCreateRide_OversizedBodyIs413(t *testing.T) {
+	h := middleware.MaxBodyBytes(1024)(http.HandlerFunc(NewRideHandler(nil, nil, model.MaxLuggagePerRequest, service.MaxToleranceMeters, 30*time.Minute).CreateRide))
+	body := `{"user_id":1,"direction":"` + strings.Repeat("x", 4096) + `"}`
+
+	for _, declared := range []bool{true, false} {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/rides", strings.NewReader(body))
+		if !declared {
+			req.ContentLength = -1 // chunked: only the reader can catch it
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		var got APIError
+		_ = json.NewDecoder(rec.Body).Decode(&got)
+		if rec.Code != http.StatusRequestEntityTooLarge || got.Code != "request_too_large" {
+			t.Errorf("declared length %v: got %d %v, want 413 request_too_large", declared, rec.Code, got)
+		}
+	}
+}
+
+// fakeTrip is one trip with many historical passenger rows; it pages like
+// RideRequestRepository.GetTripByID.
+type fakeTrip struct {
+	passengers []model.RideRequest
+	gotQuery   repository.PassengerQuery
+}
+
+func newFakeTrip(n int) *fakeTrip {
+	f := &fakeTrip{}
+	for i := 0; i < n; i++ {
+		status := model.RequestMatched
+		if i%3 == 0 {
+			status = model.RequestCancelled
+		}
+		f.passengers = append(f.passengers, model.RideRequest{ID: int64(i + 1), Status: status})
+	}
+	return f
+}
+
+func (f *fakeTrip) GetTripByID(_ context.Context, tripID int64, q repository.PassengerQuery) (*model.Trip, *repository.PassengerPage, error) {
+	f.gotQuery = q
+	if tripID != 7 {
+		return nil, nil, fmt.Errorf("get trip %d: %w", tripID, repository.ErrTripNotFound)
+	}
+	limit := q.Limit
+	if limit <= 0 {
+		limit = repository.DefaultPassengerPageSize
+	}
+	limit = min(limit, repository.MaxPassengerPageSize)
+
+	var filtered []model.RideRequest
+	for _, p := range f.passengers {
+		if len(q.Statuses) == 0 || slices.Contains(q.Statuses, p.Status) {
+			filtered = append(filtered, p)
+		}
+	}
+	rest := filtered[min(q.Offset, len(filtered)):]
+	page := &repository.PassengerPage{Limit: limit, Offset: q.Offset, Passengers: rest[:min(limit, len(rest))]}
+	page.HasMore = len(rest) > limit
+	return &model.Trip{ID: tripID}, page, nil
+}
+
+type tripResponse struct {
+	Passengers []model.RideRequest `json:"passengers"`
+	Limit      int                 `json:"limit"`
+	HasMore    bool                `json:"has_more"`
+}
+
+func getTrip(f *fakeTrip, id, query string) *httptest.ResponseRecorder {
+	h := &RideHandler{trips: f}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trips/"+id+"?"+query, nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.GetTrip(rec, req)
+	return rec
+}
+
+func TestGetTrip_ManyPassengersReturnsBoundedPage(t *testing.T) {
+	rec := getTrip(newFakeTrip(500), "7", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got tripResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Passengers) != repository.DefaultPassengerPageSize || !got.HasMore {
+		t.Errorf("got %d passengers (has_more=%v), want a default page of %d with more",
+			len(got.Passengers), got.HasMore, repository.DefaultPassengerPageSize)
+	}
+}
+
+func TestGetTrip_StatusFilterAndLimit(t *testing.T) {
+	f := newFakeTrip(500)
+	rec := getTrip(f, "7", "status=matched,confirmed&limit=1000&offset=10")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got tripResponse
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Passengers) != repository.MaxPassengerPageSize {
+		t.Errorf("got %d passengers, want capped page of %d", len(got.Passengers), repository.MaxPassengerPageSize)
+	}
+	for _, p := range got.Passengers {
+		if p.Status != model.RequestMatched {
+			t.Fatalf("passenger %d has status %s, want only matched", p.ID, p.Status)
+		}
+	}
+	if len(f.gotQuery.Statuses) != 2 || f.gotQuery.Offset != 10 {
+		t.Errorf("query = %+v, want 2 statuses and offset 10", f.gotQuery)
+	}
+}
+
+func TestGetTrip_Errors(t *testing.T) {
+	tests := []struct {
+		id, query string
+		want      int
+		wantField string
+	}{
+		{"abc", "", http.StatusBadRequest, ""},
+		{"7", "limit=ten", http.StatusBadRequest, ""},
+		{"7", "offset=-1", http.StatusUnprocessableEntity, "offset"},
+		{"7", "status=matched,lost", http.StatusUnprocessableEntity, "status"},
+		{"8", "", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id+"?"+tt.query, func(t *testing.T) {
+			assertValidationResponse(t, getTrip(newFakeTrip(3), tt.id, tt.query), tt.want, tt.wantField)
+		})
+	}
+}
+
+// fakeRoutes serves one TripRoute per trip id.
+type fakeRoutes map[int64]*repository.TripRoute
+
+func (f fakeRoutes) GetTripRoute(_ context.Context, tripID int64) (*repository.TripRoute, error) {
+	route, ok := f[tripID]
+	if !ok {
+		return nil, fmt.Errorf("get trip %d route: %w", tripID, repository.ErrTripNotFound)
+	}
+	return route, nil
+}
+
+func getTripETA(f fakeRoutes, id string) *httptest.ResponseRecorder {
+	h := &RideHandler{routes: f}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trips/"+id+"/eta", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.GetTripETA(rec, req)
+	return rec
+}
+
+func TestGetTripETA(t *testing.T) {
+	cab := model.Location{Lat: 28.7200, Lon: 77.1000}
+	airport := model.Location{Lat: 28.5562, Lon: 77.0889}
+	riders := []model.RideRequest{
+		{ID: 11, Origin: model.Location{Lat: 28.7041, Lon: 77.1025}, Destination: airport},
+		{ID: 12, Origin: model.Location{Lat: 28.6500, Lon: 77.1200}, Destination: airport},
+	}
+	f := fakeRoutes{
+		1: {
+			TripID: 1, Status: model.TripInProgress, Direction: model.DirectionToAirport, CabLocation: &cab, Riders: riders,
+			Path: []model.Location{riders[0].Origin, riders[1].Origin, airport},
+		},
+		2: {TripID: 2, Status: model.TripPlanned, CabLocation: &cab, Riders: riders},
+		3: {TripID: 3, Status: model.TripInProgress, Riders: riders},
+	}
+
+	rec := getTripETA(f, "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		TripID int64 `json:"trip_id"`
+		ETAs   []struct {
+			RequestID int64   `json:"request_id"`
+			Pickup    float64 `json:"pickup_eta_minutes"`
+			Dropoff   float64 `json:"dropoff_eta_minutes"`
+		} `json:"etas"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.TripID != 1 || len(got.ETAs) != 2 || got.ETAs[0].RequestID != 11 || got.ETAs[1].RequestID != 12 {
+		t.Fatalf("body = %+v, want ETAs for riders 11 and 12 of trip 1", got)
+	}
+	if got.ETAs[0].Pickup >= got.ETAs[1].Pickup || got.ETAs[1].Pickup >= got.ETAs[0].Dropoff {
+		t.Errorf("etas = %+v, want pickups in order and before drop-offs", got.ETAs)
+	}
+
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"2", http.StatusConflict, "trip_not_started"},
+		{"3", http.StatusConflict, "cab_location_unknown"},
+		{"9", http.StatusNotFound, "not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := getTripETA(f, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("trip %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// fakeAvailability serves one TripAvailability per trip id.
+type fakeAvailability map[int64]*repository.TripAvailability
+
+func (f fakeAvailability) GetTripAvailability(_ context.Context, tripID int64) (*repository.TripAvailability, error) {
+	a, ok := f[tripID]
+	if !ok {
+		return nil, fmt.Errorf("get trip %d availability: %w", tripID, repository.ErrTripNotFound)
+	}
+	return a, nil
+}
+
+func getTripAvailability(f fakeAvailability, id string) *httptest.ResponseRecorder {
+	h := &RideHandler{seats: f}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trips/"+id+"/availability", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.GetTripAvailability(rec, req)
+	return rec
+}
+
+func TestGetTripAvailability_PartiallyBooked(t *testing.T) {
+	f := fakeAvailability{3: {
+		TripID: 3, Status: model.TripPlanned, Bookable: true,
+		SeatCapacity: 4, LuggageCapacity: 3, UsedSeats: 3, UsedLuggage: 1,
+		RemainingSeats: 1, RemainingLuggage: 2,
+	}}
+
+	rec := getTripAvailability(f, "3")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["remaining_seats"] != 1.0 || body["remaining_luggage"] != 2.0 || body["bookable"] != true {
+		t.Errorf("body = %v, want 1 seat and 2 bags left on a bookable trip", body)
+	}
+	if _, ok := body["flex_capacity"]; ok {
+		t.Errorf("flex_capacity present for a fixed-capacity cab: %v", body)
+	}
+}
+
+func TestGetTripAvailability_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"9", http.StatusNotFound, "not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := getTripAvailability(fakeAvailability{}, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("trip %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// fakeManifests serves one TripManifest per trip id.
+type fakeManifests map[int64]*repository.TripManifest
+
+func (f fakeManifests) GetTripManifest(_ context.Context, tripID int64) (*repository.TripManifest, error) {
+	m, ok := f[tripID]
+	if !ok {
+		return nil, fmt.Errorf("get trip %d manifest: %w", tripID, repository.ErrTripNotFound)
+	}
+	return m, nil
+}
+
+func getTripPassengers(f fakeManifests, id string) *httptest.ResponseRecorder {
+	h := &RideHandler{manifests: f}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/trips/"+id+"/passengers", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.GetTripPassengers(rec, req)
+	return rec
+}
+
+func TestGetTripPassengers_MultiPassengerManifest(t *testing.T) {
+	f := fakeManifests{3: {
+		TripID: 3, Direction: model.DirectionToAirport, Status: model.TripPlanned,
+		TotalSeats: 3, TotalLuggage: 2,
+		Passengers: []repository.ManifestPassenger{
+			{Stop: 1, RequestID: 8, UserID: 80, Status: model.RequestConfirmed, SeatsNeeded: 2, LuggageCount: 1, Pickup: model.Location{Lat: 28.70, Lon: 77.10}},
+			{Stop: 2, RequestID: 5, UserID: 50, Status: model.RequestMatched, SeatsNeeded: 1, LuggageCount: 1, Pickup: model.Location{Lat: 28.65, Lon: 77.12}},
+		},
+	}}
+
+	rec := getTripPassengers(f, "3")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var body repository.TripManifest
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Passengers) != 2 || body.Passengers[0].RequestID != 8 || body.Passengers[1].RequestID != 5 {
+		t.Fatalf("passengers = %+v, want requests 8 then 5", body.Passengers)
+	}
+	if p := body.Passengers[0]; p.Stop != 1 || p.SeatsNeeded != 2 || p.Pickup.Lat != 28.70 {
+		t.Errorf("first passenger = %+v, want stop 1 with 2 seats picked up at 28.70", p)
+	}
+	if body.TotalSeats != 3 || body.TotalLuggage != 2 {
+		t.Errorf("totals = %d seats, %d bags; want 3 and 2", body.TotalSeats, body.TotalLuggage)
+	}
+}
+
+func TestGetTripPassengers_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"9", http.StatusNotFound, "not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := getTripPassengers(fakeManifests{}, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("trip %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// fakeStatuses serves one RideStatus per request id.
+type fakeStatuses map[int64]*repository.RideStatus
+
+func (f fakeStatuses) GetRideStatus(_ context.Context, id int64) (*repository.RideStatus, error) {
+	st, ok := f[id]
+	if !ok {
+		return nil, fmt.Errorf("get ride %d status: %w", id, repository.ErrRequestNotFound)
+	}
+	return st, nil
+}
+
+func getRideStatus(f fakeStatuses, id, ifNoneMatch string) *httptest.ResponseRecorder {
+	h := &RideHandler{statuses: f}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/rides/"+id+"/status", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	rec := httptest.NewRecorder()
+	h.GetRideStatus(rec, req)
+	return rec
+}
+
+func TestGetRideStatus_UnchangedPollIs304(t *testing.T) {
+	f := fakeStatuses{5: {Status: model.RequestPending, UpdatedAt: time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)}}
+
+	first := getRideStatus(f, "5", "")
+	if first.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", first.Code, first.Body)
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(first.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != 2 || body["status"] != "pending" || body["updated_at"] == nil {
+		t.Errorf("body = %v, want only status/updated_at (no trip_id while pending)", body)
+	}
+	etag := first.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("no ETag on 200 response")
+	}
+
+	for _, inm := range []string{etag, "W/" + etag, `"other", ` + etag, "*"} {
+		rec := getRideStatus(f, "5", inm)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status = %d, want 304", inm, rec.Code)
+		}
+		if rec.Body.Len() != 0 {
+			t.Errorf("If-None-Match %s: body = %q, want empty", inm, rec.Body)
+		}
+		if rec.Header().Get("ETag") != etag {
+			t.Errorf("If-None-Match %s: ETag = %q, want %q", inm, rec.Header().Get("ETag"), etag)
+		}
+	}
+}
+
+func TestGetRideStatus_StatusChangeBustsETag(t *testing.T) {
+	st := &repository.RideStatus{Status: model.RequestPending, UpdatedAt: time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)}
+	f := fakeStatuses{5: st}
+	etag := getRideStatus(f, "5", "").Header().Get("ETag")
+
+	tripID := int64(9)
+	st.Status, st.TripID = model.RequestMatched, &tripID
+	st.UpdatedAt = st.UpdatedAt.Add(time.Second)
+
+	rec := getRideStatus(f, "5", etag)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 after the ride changed", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got == etag {
+		t.Errorf("ETag unchanged (%s) after status change", got)
+	}
+	var body repository.RideStatus
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Status != model.RequestMatched || body.TripID == nil || *body.TripID != 9 {
+		t.Errorf("body = %+v, want matched on trip 9", body)
+	}
+}
+
+func TestGetRideStatus_TripIDOnlyOnceMatched(t *testing.T) {
+	tripID := int64(12)
+	f := fakeStatuses{
+		5: {Status: model.RequestPending},
+		6: {Status: model.RequestMatched, TripID: &tripID},
+	}
+
+	for id, want := range map[string]any{"5": nil, "6": 12.0} {
+		rec := getRideStatus(f, id, "")
+		var body map[string]any
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		got, present := body["trip_id"]
+		if want == nil && present {
+			t.Errorf("ride %s: trip_id = %v, want absent while unmatched", id, got)
+		}
+		if want != nil && got != want {
+			t.Errorf("ride %s: trip_id = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestCreateRideResponse_PendingOmitsTripID(t *testing.T) {
+	resp := CreateRideResponse{RideRequest: &model.RideRequest{ID: 3, Status: model.RequestPending}}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	if err := json.Unmarshal(b, &body); err != nil {
+		t.Fatal(err)
+	}
+	if _, present := body["trip_id"]; present {
+		t.Errorf("new pending request serialized with trip_id: %s", b)
+	}
+}
+
+func TestGetRideStatus_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"9", http.StatusNotFound, "not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := getRideStatus(fakeStatuses{}, tt.id, "")
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("ride %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// fakeTimelines serves one RideTimeline per request id.
+type fakeTimelines map[int64]*repository.RideTimeline
+
+func (f fakeTimelines) GetRideTimeline(_ context.Context, id int64) (*repository.RideTimeline, error) {
+	tl, ok := f[id]
+	if !ok {
+		return nil, fmt.Errorf("get ride %d timeline: %w", id, repository.ErrRequestNotFound)
+	}
+	return tl, nil
+}
+
+func getRideTimeline(f fakeTimelines, id string) *httptest.ResponseRecorder {
+	h := &RideHandler{timelines: f}
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/rides/"+id+"/timeline", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.GetRideTimeline(rec, req)
+	return rec
+}
+
+func TestGetRideTimeline_ReturnsEventsInOrder(t *testing.T) {
+	start := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	f := fakeTimelines{5: {RideID: 5, Events: []repository.TimelineEvent{
+		{ID: 1, Type: model.EventRideCreated, At: start, Payload: json.RawMessage(`{}`)},
+		{ID: 4, Type: model.EventRideMatched, At: start.Add(time.Minute), Payload: json.RawMessage(`{"trip_id":9}`)},
+		{ID: 7, Type: model.EventRideCompleted, At: start.Add(time.Hour), Payload: json.RawMessage(`{}`)},
+	}}}
+
+	rec := getRideTimeline(f, "5")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var body repository.RideTimeline
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.RideID != 5 || len(body.Events) != 3 {
+		t.Fatalf("body = %+v, want ride 5 with 3 events", body)
+	}
+	for i, want := range []model.EventType{model.EventRideCreated, model.EventRideMatched, model.EventRideCompleted} {
+		if body.Events[i].Type != want {
+			t.Errorf("event %d = %s, want %s", i, body.Events[i].Type, want)
+		}
+	}
+	if !body.Events[1].At.Equal(start.Add(time.Minute)) {
+		t.Errorf("matched at %v, want %v", body.Events[1].At, start.Add(time.Minute))
+	}
+}
+
+func TestGetRideTimeline_Errors(t *testing.T) {
+	for _, tt := range []struct {
+		id       string
+		want     int
+		wantCode string
+	}{
+		{"9", http.StatusNotFound, "not_found"},
+		{"abc", http.StatusBadRequest, "bad_request"},
+	} {
+		rec := getRideTimeline(fakeTimelines{}, tt.id)
+		if body := decodeAPIError(t, rec); rec.Code != tt.want || body.Code != tt.wantCode {
+			t.Errorf("ride %s: got %d %q, want %d %q", tt.id, rec.Code, body.Code, tt.want, tt.wantCode)
+		}
+	}
+}
+
+// fakeUpdater holds ride requests by id and applies updates like the
+// repository: only to pending or scheduled requests.
+type fakeUpdater map[int64]*model.RideRequest
+
+func (f fakeUpdater) UpdatePendingRequest(_ context.Context, id int64, upd repository.RideRequestUpdate) (*model.RideRequest, error) {
+	rr, ok := f[id]
+	if !ok {
+		return nil, fmt.Errorf("update ride request %d: %w", id, repository.ErrRequestNotFound)
+	}
+	if rr.Status != model.RequestPending && rr.Status != model.RequestScheduled {
+		return nil, fmt.Errorf("update ride request %d: %w", id, repository.ErrRequestNotEditable)
+	}
+	if upd.SeatsNeeded != nil {
+		rr.SeatsNeeded = *upd.SeatsNeeded
+	}
+	if upd.LuggageCount != nil {
+		rr.LuggageCount = *upd.LuggageCount
+	}
+	if upd.ToleranceMeters != nil {
+		rr.ToleranceMeters = *upd.ToleranceMeters
+	}
+	return rr, nil
+}
+
+func patchRide(f fakeUpdater, id, body string) *httptest.ResponseRecorder {
+	h := &RideHandler{updater: f, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest}
+	req := httptest.NewRequest(http.MethodPatch, "/api/v1/rides/"+id, strings.NewReader(body))
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.UpdateRide(rec, req)
+	return rec
+}
+
+func TestUpdateRide_PendingRequest(t *testing.T) {
+	f := fakeUpdater{5: {ID: 5, Status: model.RequestPending, SeatsNeeded: 1, LuggageCount: 2, ToleranceMeters: 2000}}
+
+	rec := patchRide(f, "5", `{"luggage_count":1,"tolerance_meters":50000}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200; body %s", rec.Code, rec.Body)
+	}
+	var got struct {
+		SeatsNeeded              int  `json:"seats_needed"`
+		LuggageCount             int  `json:"luggage_count"`
+		ToleranceMeters          int  `json:"tolerance_meters"`
+		ToleranceClamped         bool `json:"tolerance_clamped"`
+		RequestedToleranceMeters int  `json:"requested_tolerance_meters"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.SeatsNeeded != 1 || got.LuggageCount != 1 {
+		t.Errorf("seats/luggage = %d/%d, want seats untouched (1) and luggage 1", got.SeatsNeeded, got.LuggageCount)
+	}
+	if got.ToleranceMeters != service.MaxToleranceMeters || !got.ToleranceClamped || got.RequestedToleranceMeters != 50000 {
+		t.Errorf("tolerance = %+v, want clamped to %d from 50000", got, service.MaxToleranceMeters)
+	}
+}
+
+func TestUpdateRide_MatchedRequestRejected(t *testing.T) {
+	tripID := int64(9)
+	f := fakeUpdater{5: {ID: 5, Status: model.RequestMatched, TripID: &tripID, SeatsNeeded: 1, LuggageCount: 2}}
+
+	rec := patchRide(f, "5", `{"luggage_count":1}`)
+	if body := decodeAPIError(t, rec); rec.Code != http.StatusConflict || body.Code != "not_pending" {
+		t.Errorf("got %d %q, want 409 not_pending", rec.Code, body.Code)
+	}
+	if f[5].LuggageCount != 2 {
+		t.Errorf("luggage = %d, want the matched request unchanged", f[5].LuggageCount)
+	}
+}
+
+func TestUpdateRide_Validation(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		id, body  string
+		want      int
+		wantField string
+	}{
+		{"empty body", "5", `{}`, http.StatusUnprocessableEntity, ""},
+		{"unknown field", "5", `{"luggage":1}`, http.StatusBadRequest, ""},
+		{"zero seats", "5", `{"seats_needed":0}`, http.StatusUnprocessableEntity, "seats_needed"},
+		{"group larger than any cab", "5", `{"seats_needed":6}`, http.StatusUnprocessableEntity, "seats_needed"},
+		{"too much luggage", "5", `{"luggage_count":99}`, http.StatusUnprocessableEntity, "luggage_count"},
+		{"negative tolerance", "5", `{"tolerance_meters":-1}`, http.StatusUnprocessableEntity, "tolerance_meters"},
+		{"unknown ride", "9", `{"luggage_count":1}`, http.StatusNotFound, ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			f := fakeUpdater{5: {ID: 5, Status: model.RequestPending, SeatsNeeded: 1}}
+			rec := patchRide(f, tt.id, tt.body)
+			if rec.Code != tt.want {
+				t.Fatalf("status = %d, want %d; body %s", rec.Code, tt.want, rec.Body)
+			}
+			if tt.wantField != "" {
+				if body := decodeAPIError(t, rec); body.Details["field"] != tt.wantField {
+					t.Errorf("field = %v, want %s", body.Details["field"], tt.wantField)
+				}
+			}
+		})
+	}
+}
+
+// fakePending holds each user's requests by ID and status; all of them go
+// to the airport.
+type fakePending map[int64]map[int64]model.RequestStatus
+
+func (f fakePending) CancelUserPending(_ context.Context, userID int64) ([]int64, []repository.SurgeChange, error) {
+	requests, ok := f[userID]
+	if !ok {
+		return nil, nil, fmt.Errorf("cancel user %d pending: %w", userID, repository.ErrUserNotFound)
+	}
+	var (
+		ids   []int64
+		surge []repository.SurgeChange
+	)
+	for id, status := range requests {
+		if status == model.RequestPending {
+			requests[id] = model.RequestCancelled
+			ids = append(ids, id)
+			surge = append(surge, repository.SurgeChange{Direction: model.DirectionToAirport, Demand: -1})
+		}
+	}
+	slices.Sort(ids)
+	return ids, surge, nil
+}
+
+func cancelUserPending(h *RideHandler, id string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/users/"+id+"/cancel-pending", nil)
+	req = mux.SetURLVars(req, map[string]string{"id": id})
+	rec := httptest.NewRecorder()
+	h.CancelUserPending(rec, req)
+	return rec
+}
+
+func TestCancelUserPending_MultiplePending(t *testing.T) {
+	pending := fakePending{1: {
+		10: model.RequestPending, 11: model.RequestPending, 12: model.RequestPending,
+		13: model.RequestMatched, 14: model.RequestScheduled,
+	}}
+	counters := &demandCounter{demand: map[model.TripDirection]int{model.DirectionToAirport: 3}}
+	h := &RideHandler{pending: pending, Counters: counters}
+
+	rec := cancelUserPending(h, "1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body: %s)", rec.Code, rec.Body)
+	}
+	var got struct {
+		UserID     int64   `json:"user_id"`
+		Cancelled  int     `json:"cancelled"`
+		RequestIDs []int64 `json:"request_ids"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.UserID != 1 || got.Cancelled != 3 || fmt.Sprint(got.RequestIDs) != "[10 11 12]" {
+		t.Errorf("got %+v, want user 1 with requests [10 11 12] cancelled", got)
+	}
+	if pending[1][13] != model.RequestMatched || pending[1][14] != model.RequestScheduled {
+		t.Errorf("non-pending requests changed: %v", pending[1])
+	}
+	if got := counters.demand[model.DirectionToAirport]; got != 0 {
+		t.Errorf("to_airport demand = %d, want 0 once the pending requests are cancelled", got)
+	}
+
+	// A second call finds nothing pending and says so with an empty list.
+	rec = cancelUserPending(h, "1")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"request_ids":[]`) {
+		t.Errorf("repeat: %d %s, want 200 with no request_ids", rec.Code, rec.Body)
+	}
+}
+
+func TestCancelUserPending_Errors(t *testing.T) {
+	h := &RideHandler{pending: fakePending{}}
+	if rec := cancelUserPending(h, "abc"); rec.Code != http.StatusBadRequest {
+		t.Errorf("bad id: status = %d, want 400", rec.Code)
+	}
+	if rec := cancelUserPending(h, "9"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestCancelUserPending_AdminGated(t *testing.T) {
+	pending := fakePending{1: {10: model.RequestPending}}
+	gated := middleware.RequireAdmin("s3cret")(http.HandlerFunc((&RideHandler{pending: pending}).CancelUserPending))
+
+	req := mux.SetURLVars(httptest.NewRequest(http.MethodPost, "/api/v1/users/1/cancel-pending", nil), map[string]string{"id": "1"})
+	rec := httptest.NewRecorder()
+	gated.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden || pending[1][10] != model.RequestPending {
+		t.Errorf("without token: status = %d, request %s; want 403 and still pending", rec.Code, pending[1][10])
+	}
+
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	gated.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || pending[1][10] != model.RequestCancelled {
+		t.Errorf("with token: status = %d, request %s; want 200 and cancelled", rec.Code, pending[1][10])
+	}
+}
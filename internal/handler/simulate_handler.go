@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+// rideMatcher is the part of service.MatchingService used by SimulateHandler
+// and MatchHandler.MatchRideRequest.
+type rideMatcher interface {
+	MatchRiders(ctx context.Context, requestID int64) (*model.MatchResult, error)
+}
+
+// rideBooker is the part of service.BookingService used by BookingHandler.
+type rideBooker interface {
+	BookRide(ctx context.Context, requestID int64, opts service.BookingOptions) (*repository.BookingResult, error)
+}
+
+// Simulation step names, in the order they run.
+const (
+	stepCreate = "create"
+	stepMatch  = "match"
+	stepBook   = "book"
+)
+
+// SimulateStep is the outcome of one step of a simulated booking. Status
+// is what the step's own endpoint would have answered; Error is its error
+// envelope when the step did not succeed.
+type SimulateStep struct {
+	Step   string      `json:"step"`
+	OK     bool        `json:"ok"`
+	Status int         `json:"status"`
+	Result interface{} `json:"result,omitempty"`
+	Error  *APIError   `json:"error,omitempty"`
+}
+
+// SimulateResponse is the body of POST /api/v1/admin/simulate: the steps
+// that ran, plus the ids they produced.
+type SimulateResponse struct {
+	RequestID int64          `json:"request_id,omitempty"`
+	TripID    int64          `json:"trip_id,omitempty"`
+	Booked    bool           `json:"booked"`
+	Steps     []SimulateStep `json:"steps"`
+}
+
+// SimulateHandler runs the create → match → book flow in one call, for QA.
+// Routes are mounted behind middleware.RequireAdmin.
+type SimulateHandler struct {
+	rides    *RideHandler
+	matcher  rideMatcher
+	bookings *BookingHandler
+}
+
+// NewSimulateHandler creates a simulate handler. rides validates and
+// stores the ride request exactly as POST /api/v1/rides does; bookings
+// books it and issues the confirmation token as POST /api/v1/book does.
+func NewSimulateHandler(rides *RideHandler, matcher *service.MatchingService, bookings *BookingHandler) *SimulateHandler {
+	return &SimulateHandler{rides: rides, matcher: matcher, bookings: bookings}
+}
+
+// Simulate handles POST /api/v1/admin/simulate
+//
+// Takes a POST /api/v1/rides body and runs the whole booking flow on it:
+// creates the ride request, asks the matcher for an existing trip, then
+// books (pooling into the matched trip or starting a new one). Nothing is
+// faked — the request, trip and booking are real rows.
+//
+// A failed create stops the run; so does a failed match, unless it only
+// found no trip (booking then creates one). Each step reports the status
+// and body its own endpoint would have given.
+//
+// Response codes:
+//
+//	200  — The flow ran (check booked and each step's ok)
+//	400  — Malformed JSON
+//	403  — Missing/invalid admin token
+//	422  — The ride body failed validation (as for POST /api/v1/rides)
+func (h *SimulateHandler) Simulate(w http.ResponseWriter, r *http.Request) {
+	var body CreateRideRequestBody
+	if err := decodeJSON(r, &body); err != nil {
+		writeBodyError(w, err, "invalid JSON body")
+		return
+	}
+	req, clamped, ok := h.rides.rideFromBody(w, r, &body)
+	if !ok {
+		return
+	}
+	ctx := r.Context()
+	var resp SimulateResponse
+
+	// ── Step 1: Create the ride request ─────────────────
+	created, isNew, err := h.rides.createRide(ctx, req)
+	if err != nil {
+		resp.Steps = append(resp.Steps, failedStep(stepCreate, func(w http.ResponseWriter) {
+			writeInternalError(w, err, "failed to create ride request")
+		}))
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	status := http.StatusCreated
+	if !isNew {
+		status = http.StatusOK
+	}
+	ride := CreateRideResponse{RideRequest: created}
+	if isNew && clamped {
+		ride.ToleranceClamped = true
+		ride.RequestedToleranceMeters = body.ToleranceMeters
+	}
+	resp.RequestID = created.ID
+	resp.Steps = append(resp.Steps, SimulateStep{Step: stepCreate, OK: true, Status: status, Result: ride})
+
+	// ── Step 2: Look for an existing trip ───────────────
+	match, err := h.matcher.MatchRiders(ctx, created.ID)
+	if err != nil {
+		resp.Steps = append(resp.Steps, failedStep(stepMatch, func(w http.ResponseWriter) { writeMatchError(w, err) }))
+		if !errors.Is(err, service.ErrNoMatch) {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+	} else {
+		resp.Steps = append(resp.Steps, SimulateStep{Step: stepMatch, OK: true, Status: http.StatusOK, Result: match})
+	}
+
+	// ── Step 3: Book ────────────────────────────────────
+	booking, err := h.bookings.bookingSvc.BookRide(ctx, created.ID, service.BookingOptions{})
+	if err != nil {
+		resp.Steps = append(resp.Steps, failedStep(stepBook, func(w http.ResponseWriter) { writeBookingError(w, err) }))
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	resp.TripID = booking.TripID
+	resp.Booked = true
+	resp.Steps = append(resp.Steps, SimulateStep{Step: stepBook, OK: true, Status: http.StatusOK, Result: h.bookings.bookingResponse(booking)})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// failedStep records the error response write would have sent for step,
+// so the simulation reports the same code and status as the real endpoint.
+func failedStep(step string, write func(w http.ResponseWriter)) SimulateStep {
+	rec := &stepRecorder{header: http.Header{}, status: http.StatusOK}
+	write(rec)
+	s := SimulateStep{Step: step, Status: rec.status}
+	var apiErr APIError
+	if err := json.Unmarshal(rec.body.Bytes(), &apiErr); err == nil {
+		s.Error = &apiErr
+	}
+	return s
+}
+
+// stepRecorder is an http.ResponseWriter that keeps what was written.
+type stepRecorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *stepRecorder) Header() http.Header         { return r.header }
+func (r *stepRecorder) Write(b []byte) (int, error) { return r.body.Write(b) }
+func (r *stepRecorder) WriteHeader(status int)      { r.status = status }
@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/internal/service"
+)
+
+// fakeMatcher answers MatchRiders with a fixed result or error.
+type fakeMatcher struct {
+	result *model.MatchResult
+	err    error
+	got    []int64
+}
+
+func (f *fakeMatcher) MatchRiders(_ context.Context, requestID int64) (*model.MatchResult, error) {
+	f.got = append(f.got, requestID)
+	return f.result, f.err
+}
+
+// fakeBooker books onto the trip pool (if set) or a new trip 99, like
+// BookingService.BookRide re-running the match.
+type fakeBooker struct {
+	pool *model.MatchResult
+	err  error
+	got  []int64
+}
+
+func (f *fakeBooker) BookRide(_ context.Context, requestID int64, _ service.BookingOptions) (*repository.BookingResult, error) {
+	f.got = append(f.got, requestID)
+	if f.err != nil {
+		return nil, f.err
+	}
+	res := &repository.BookingResult{TripID: 99, CabID: 9, RequestID: requestID, SeatsBooked: 1, RemainingSeats: 3}
+	if f.pool != nil {
+		res.TripID, res.CabID, res.Pooled = f.pool.TripID, f.pool.CabID, true
+	}
+	return res, nil
+}
+
+// simulateResult decodes a SimulateResponse keeping each step's result raw.
+type simulateResult struct {
+	RequestID int64 `json:"request_id"`
+	TripID    int64 `json:"trip_id"`
+	Booked    bool  `json:"booked"`
+	Steps     []struct {
+		Step   string          `json:"step"`
+		OK     bool            `json:"ok"`
+		Status int             `json:"status"`
+		Result json.RawMessage `json:"result"`
+		Error  *APIError       `json:"error"`
+	} `json:"steps"`
+}
+
+const simulateBody = `{"user_id": 3, "origin_lat": 28.70, "origin_lon": 77.10,
+	"dest_lat": 28.55, "dest_lon": 77.08, "direction": "to_airport"}`
+
+func runSimulate(t *testing.T, h *SimulateHandler, body string) (*httptest.ResponseRecorder, simulateResult) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.Simulate(rec, httptest.NewRequest(http.MethodPost, "/api/v1/admin/simulate", strings.NewReader(body)))
+	var got simulateResult
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode: %v (body %s)", err, rec.Body.String())
+		}
+	}
+	return rec, got
+}
+
+func newSimulateHandler(matcher *fakeMatcher, booker *fakeBooker) *SimulateHandler {
+	rides := &RideHandler{creator: &echoCreator{}, fleet: fixedFleet(4), maxLuggage: model.MaxLuggagePerRequest}
+	return &SimulateHandler{rides: rides, matcher: matcher, bookings: &BookingHandler{bookingSvc: booker}}
+}
+
+func stepNames(res simulateResult) string {
+	var names []string
+	for _, s := range res.Steps {
+		names = append(names, s.Step)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSimulate_MatchedFlowIsCoherent(t *testing.T) {
+	match := &model.MatchResult{TripID: 7, CabID: 4}
+	matcher := &fakeMatcher{result: match}
+	booker := &fakeBooker{pool: match}
+	rec, res := runSimulate(t, newSimulateHandler(matcher, booker), simulateBody)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (body %s)", rec.Code, rec.Body.String())
+	}
+	if got := stepNames(res); got != "create,match,book" {
+		t.Fatalf("steps = %s, want create,match,book", got)
+	}
+	wantStatus := []int{http.StatusCreated, http.StatusOK, http.StatusOK}
+	for i, s := range res.Steps {
+		if !s.OK || s.Status != wantStatus[i] || s.Error != nil {
+			t.Errorf("step %s = ok %v status %d error %v, want ok with %d", s.Step, s.OK, s.Status, s.Error, wantStatus[i])
+		}
+	}
+
+	var created model.RideRequest
+	var matched model.MatchResult
+	var booked repository.BookingResult
+	for i, dst := range []interface{}{&created, &matched, &booked} {
+		if err := json.Unmarshal(res.Steps[i].Result, dst); err != nil {
+			t.Fatalf("step %s result: %v", res.Steps[i].Step, err)
+		}
+	}
+	if created.ID != res.RequestID || booked.RequestID != res.RequestID {
+		t.Errorf("request ids: created %d, booked %d, response %d; want all equal", created.ID, booked.RequestID, res.RequestID)
+	}
+	if len(matcher.got) != 1 || matcher.got[0] != created.ID || len(booker.got) != 1 || booker.got[0] != created.ID {
+		t.Errorf("matched %v, booked %v; want the created request #%d once each", matcher.got, booker.got, created.ID)
+	}
+	if !res.Booked || res.TripID != matched.TripID || booked.TripID != matched.TripID || !booked.Pooled {
+		t.Errorf("booked %v on trip %d (step %d, pooled %v), want pooled onto matched trip %d",
+			res.Booked, res.TripID, booked.TripID, booked.Pooled, matched.TripID)
+	}
+}
+
+func TestSimulate_NoMatchStillBooksNewTrip(t *testing.T) {
+	rec, res := runSimulate(t, newSimulateHandler(&fakeMatcher{err: service.ErrNoMatch}, &fakeBooker{}), simulateBody)
+
+	if rec.Code != http.StatusOK || stepNames(res) != "create,match,book" {
+		t.Fatalf("status %d steps %s, want 200 create,match,book", rec.Code, stepNames(res))
+	}
+	m := res.Steps[1]
+	if m.OK || m.Status != http.StatusNotFound || m.Error == nil || m.Error.Code != "no_match" {
+		t.Errorf("match step = %+v, want 404 no_match", m)
+	}
+	if !res.Booked || res.TripID != 99 {
+		t.Errorf("booked %v on trip %d, want booked on new trip 99", res.Booked, res.TripID)
+	}
+}
+
+func TestSimulate_BookStepCarriesConfirmationToken(t *testing.T) {
+	h := newSimulateHandler(&fakeMatcher{err: service.ErrNoMatch}, &fakeBooker{})
+	h.bookings.tokens = service.NewBookingTokens([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	_, res := runSimulate(t, h, simulateBody)
+
+	if len(res.Steps) != 3 || !res.Booked {
+		t.Fatalf("steps %s, booked %v; want a booking", stepNames(res), res.Booked)
+	}
+	var booked BookingResponse
+	if err := json.Unmarshal(res.Steps[2].Result, &booked); err != nil {
+		t.Fatalf("book step result: %v", err)
+	}
+	if booked.BookingResult == nil || booked.RequestID != res.RequestID {
+		t.Fatalf("book step = %s, want the booking of request #%d", res.Steps[2].Result, res.RequestID)
+	}
+	claims, err := h.bookings.tokens.Verify(booked.ConfirmationToken)
+	if err != nil {
+		t.Fatalf("confirmation_token %q: %v", booked.ConfirmationToken, err)
+	}
+	if claims.RequestID != res.RequestID || claims.TripID != res.TripID {
+		t.Errorf("token claims = %+v, want request #%d on trip %d", claims, res.RequestID, res.TripID)
+	}
+}
+
+func TestSimulate_StopsAtFailedStep(t *testing.T) {
+	t.Run("match error other than no_match", func(t *testing.T) {
+		booker := &fakeBooker{}
+		_, res := runSimulate(t, newSimulateHandler(&fakeMatcher{err: service.ErrRequestScheduled}, booker), simulateBody)
+
+		if stepNames(res) != "create,match" || len(booker.got) != 0 {
+			t.Fatalf("steps %s, booked %v; want create,match and no booking", stepNames(res), booker.got)
+		}
+		if m := res.Steps[1]; m.Status != http.StatusConflict || m.Error == nil || m.Error.Code != "scheduled" {
+			t.Errorf("match step = %+v, want 409 scheduled", m)
+		}
+		if res.Booked || res.RequestID != 1 {
+			t.Errorf("booked %v request %d, want not booked, request 1", res.Booked, res.RequestID)
+		}
+	})
+
+	t.Run("booking error", func(t *testing.T) {
+		_, res := runSimulate(t, newSimulateHandler(&fakeMatcher{err: service.ErrNoMatch}, &fakeBooker{err: service.ErrNoCabNearby}), simulateBody)
+
+		if stepNames(res) != "create,match,book" {
+			t.Fatalf("steps = %s, want create,match,book", stepNames(res))
+		}
+		if b := res.Steps[2]; b.OK || b.Status != http.StatusNotFound || b.Error == nil || b.Error.Code != "no_cab" {
+			t.Errorf("book step = %+v, want 404 no_cab", b)
+		}
+		if res.Booked || res.TripID != 0 {
+			t.Errorf("booked %v trip %d, want not booked", res.Booked, res.TripID)
+		}
+	})
+}
+
+func TestSimulate_InvalidBodyCreatesNothing(t *testing.T) {
+	matcher := &fakeMatcher{}
+	rec, _ := runSimulate(t, newSimulateHandler(matcher, &fakeBooker{}),
+		`{"user_id": 3, "origin_lat": 28.70, "origin_lon": 77.10, "dest_lat": 28.55, "dest_lon": 77.08, "direction": "sideways"}`)
+
+	assertValidationResponse(t, rec, http.StatusUnprocessableEntity, "direction")
+	if len(matcher.got) != 0 {
+		t.Errorf("matcher called with %v, want no calls", matcher.got)
+	}
+}
@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/shiva/hintro/pkg/buildinfo"
+)
+
+// Version handles GET /api/v1/version
+//
+// Reports the running build so a deploy can be verified:
+//
+//	{"version": "v1.4.0", "commit": "9f2c…", "build_time": "2025-01-01T06:00:00Z", "go_version": "go1.22.5"}
+func Version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, buildinfo.Get())
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shiva/hintro/pkg/buildinfo"
+)
+
+func getVersion(t *testing.T) buildinfo.Info {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	Version(rec, httptest.NewRequest(http.MethodGet, "/api/v1/version", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var got buildinfo.Info
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// setBuildInfo stands in for -ldflags "-X ..." for the rest of the test.
+func setBuildInfo(t *testing.T, version, commit, buildTime string) {
+	oldV, oldC, oldT := buildinfo.Version, buildinfo.Commit, buildinfo.BuildTime
+	t.Cleanup(func() { buildinfo.Version, buildinfo.Commit, buildinfo.BuildTime = oldV, oldC, oldT })
+	buildinfo.Version, buildinfo.Commit, buildinfo.BuildTime = version, commit, buildTime
+}
+
+func TestVersion_ReturnsInjectedValues(t *testing.T) {
+	setBuildInfo(t, "v1.4.0", "9f2c1e7", "2025-01-01T06:00:00Z")
+
+	got := getVersion(t)
+	if got.Version != "v1.4.0" || got.Commit != "9f2c1e7" || got.BuildTime != "2025-01-01T06:00:00Z" {
+		t.Errorf("got %+v, want the injected build info", got)
+	}
+	if got.GoVersion == "" {
+		t.Error("go_version is empty")
+	}
+}
+
+func TestVersion_DevDefaults(t *testing.T) {
+	setBuildInfo(t, "", "", "")
+
+	got := getVersion(t)
+	// Test binaries carry no VCS stamp, so nothing is left blank.
+	if got.Version != "dev" || got.Commit == "" || got.BuildTime == "" {
+		t.Errorf("got %+v, want dev with non-empty commit and build time", got)
+	}
+}
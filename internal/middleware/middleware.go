@@ -5,9 +5,22 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/shiva/hintro/pkg/tracing"
 )
 
 // responseWriter wraps http.ResponseWriter to capture the status code.
@@ -40,6 +53,41 @@ func RequestLogger(next http.Handler) http.Handler {
 	})
 }
 
+// Tracing starts the root span of each request's trace, continuing the
+// caller's trace when the request carries a traceparent header. Register
+// it on the router (router.Use) so the span is named after the matched
+// route template, e.g. "POST /api/v1/book/{request_id}", rather than the
+// raw path. 5xx responses mark the span as failed.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Path
+		if cur := mux.CurrentRoute(r); cur != nil {
+			if tmpl, err := cur.GetPathTemplate(); err == nil {
+				route = tmpl
+			}
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracing.Tracer().Start(ctx, r.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.HTTPRoute(route),
+				semconv.URLPath(r.URL.Path),
+			),
+		)
+		defer span.End()
+
+		rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rw.statusCode))
+		if rw.statusCode >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rw.statusCode))
+		}
+	})
+}
+
 // Recoverer catches panics in handlers and returns a 500 response
 // instead of crashing the entire server.
 func Recoverer(next http.Handler) http.Handler {
@@ -47,7 +95,7 @@ func Recoverer(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				log.Printf("[http] PANIC: %s %s → %v", r.Method, r.URL.Path, err)
-				http.Error(w, `{"error":"internal_server_error"}`, http.StatusInternalServerError)
+				writeError(w, http.StatusInternalServerError, "internal_error", "Internal server error.")
 			}
 		}()
 		next.ServeHTTP(w, r)
@@ -58,7 +106,7 @@ func Recoverer(next http.Handler) http.Handler {
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
 		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept, Authorization")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
@@ -67,3 +115,46 @@ func CORS(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// MaxBodyBytes caps request bodies at limit bytes. A declared
+// Content-Length over the limit is rejected with 413 before the handler
+// runs; otherwise the body is wrapped in http.MaxBytesReader, so reading
+// past the limit fails with *http.MaxBytesError (handlers map that to 413).
+func MaxBodyBytes(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > limit {
+				w.Header().Set("Connection", "close")
+				writeError(w, http.StatusRequestEntityTooLarge, "request_too_large",
+					fmt.Sprintf("Request body exceeds %d bytes.", limit))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin rejects requests that do not carry "Authorization: Bearer
+// <token>". With an empty token every request is rejected, so admin routes
+// are off unless ADMIN_TOKEN is configured.
+func RequireAdmin(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				writeError(w, http.StatusForbidden, "forbidden", "Admin token required.")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeError writes the same {"code", "message"} envelope as the handler
+// package's APIError, for responses middleware sends on its own.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"code": code, "message": message})
+}
@@ -0,0 +1,136 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInsufficientCapacity is returned by CheckCapacity when a request does
+// not fit in a cab's remaining seats or luggage slots.
+var ErrInsufficientCapacity = errors.New("insufficient capacity")
+
+// ErrNotAccessible is returned by CabCapacity.CheckAccessible when a rider
+// who needs an accessible cab is offered one that is not equipped.
+var ErrNotAccessible = errors.New("cab is not wheelchair accessible")
+
+// ErrPassengerLimit is returned by CheckPassengerLimit when joining a trip
+// would take it past the MaxPassengersPerTrip pooling cap
+// (MATCH_MAX_PASSENGERS_PER_TRIP). The cab may still have free seats.
+var ErrPassengerLimit = errors.New("trip is at the MaxPassengersPerTrip pool cap")
+
+// CapacityError is the error returned by CabCapacity.Check. It names the
+// limit that ran out and carries what is still free on the cab, so callers
+// can suggest a smaller booking. It wraps ErrInsufficientCapacity.
+type CapacityError struct {
+	Limit     string // "seats", "luggage slots", or "flex units".
+	Remaining int    // Left on Limit.
+	Need      int    // Asked of Limit.
+
+	RemainingSeats   int // CabCapacity.Remaining for the cab as loaded.
+	RemainingLuggage int
+}
+
+func (e *CapacityError) Error() string {
+	return fmt.Sprintf("%d %s remaining, need %d: %v", e.Remaining, e.Limit, e.Need, ErrInsufficientCapacity)
+}
+
+func (e *CapacityError) Unwrap() error { return ErrInsufficientCapacity }
+
+// CabCapacity is what a cab can carry.
+//
+// Fixed cabs (Flex == nil) have independent seat and luggage limits.
+// Flex cabs (folding seats) additionally share Flex units between the two:
+// each seat and each bag takes one unit, so seats + luggage ≤ Flex on top
+// of the per-dimension limits.
+//
+// With LuggageUsesSeats, bags that do not fit in the luggage space ride on
+// free seats instead, one seat per bag.
+type CabCapacity struct {
+	Seats            int
+	Luggage          int
+	Flex             *int
+	Accessible       bool // Equipped for wheelchairs.
+	LuggageUsesSeats bool // Overflow bags take a seat each (BOOKING_LUGGAGE_USES_SEATS).
+}
+
+// seatOverflow moves bags beyond the luggage space onto seats when
+// LuggageUsesSeats is set: first those already aboard, then the new ones.
+func (c CabCapacity) seatOverflow(usedSeats, usedLuggage, needSeats, needLuggage int) (int, int, int, int) {
+	if !c.LuggageUsesSeats {
+		return usedSeats, usedLuggage, needSeats, needLuggage
+	}
+	usedOver := max(usedLuggage-c.Luggage, 0)
+	needOver := max(usedLuggage+needLuggage-c.Luggage, 0) - usedOver
+	return usedSeats + usedOver, usedLuggage - usedOver, needSeats + needOver, needLuggage - needOver
+}
+
+// Check reports whether needSeats/needLuggage fit on top of the current
+// load. Failures are *CapacityError, which wraps ErrInsufficientCapacity.
+func (c CabCapacity) Check(usedSeats, usedLuggage, needSeats, needLuggage int) error {
+	usedSeats, usedLuggage, needSeats, needLuggage = c.seatOverflow(usedSeats, usedLuggage, needSeats, needLuggage)
+	fail := func(limit string, remaining, need int) error {
+		seats, luggage := c.Remaining(usedSeats, usedLuggage)
+		return &CapacityError{
+			Limit: limit, Remaining: remaining, Need: need,
+			RemainingSeats: seats, RemainingLuggage: luggage,
+		}
+	}
+	if remaining := c.Seats - usedSeats; needSeats > remaining {
+		return fail("seats", remaining, needSeats)
+	}
+	if remaining := c.Luggage - usedLuggage; needLuggage > remaining {
+		return fail("luggage slots", remaining, needLuggage)
+	}
+	if c.Flex != nil {
+		remaining := *c.Flex - usedSeats - usedLuggage
+		if need := needSeats + needLuggage; need > remaining {
+			return fail("flex units", remaining, need)
+		}
+	}
+	return nil
+}
+
+// CheckAccessible returns ErrNotAccessible if the rider requires an
+// accessible cab and this one is not.
+func (c CabCapacity) CheckAccessible(required bool) error {
+	if required && !c.Accessible {
+		return ErrNotAccessible
+	}
+	return nil
+}
+
+// CheckPassengerLimit returns ErrPassengerLimit if adding needSeats
+// passengers to a trip already carrying used would exceed max. It bounds
+// pooling independently of the cab's seats, so it only applies to a trip
+// that already has riders: a group larger than max still gets a trip of
+// its own. max ≤ 0 disables the limit.
+func CheckPassengerLimit(used, needSeats, max int) error {
+	if max <= 0 || used == 0 || used+needSeats <= max {
+		return nil
+	}
+	return fmt.Errorf("%d passengers aboard, %d more would exceed %d: %w", used, needSeats, max, ErrPassengerLimit)
+}
+
+// Remaining returns how many more seats and bags fit, each on its own.
+// On a flex cab they share units, so both cannot be used in full at once;
+// with LuggageUsesSeats the luggage figure counts free seats too.
+func (c CabCapacity) Remaining(usedSeats, usedLuggage int) (seats, luggage int) {
+	usedSeats, usedLuggage, _, _ = c.seatOverflow(usedSeats, usedLuggage, 0, 0)
+	seats = c.Seats - usedSeats
+	luggage = c.Luggage - usedLuggage
+	if c.LuggageUsesSeats {
+		luggage += max(seats, 0)
+	}
+	if c.Flex != nil {
+		free := *c.Flex - usedSeats - usedLuggage
+		seats = min(seats, free)
+		luggage = min(luggage, free)
+	}
+	return max(seats, 0), max(luggage, 0)
+}
+
+// CheckCapacity is CabCapacity.Check for a fixed-capacity cab.
+func CheckCapacity(seatCapacity, luggageCapacity, usedSeats, usedLuggage, needSeats, needLuggage int) error {
+	return CabCapacity{Seats: seatCapacity, Luggage: luggageCapacity}.
+		Check(usedSeats, usedLuggage, needSeats, needLuggage)
+}
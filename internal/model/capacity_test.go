@@ -0,0 +1,172 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckCapacity(t *testing.T) {
+	tests := []struct {
+		name          string
+		used, usedLug int
+		need, needLug int
+		wantErr       bool
+	}{
+		{"fits", 2, 1, 2, 2, false},
+		{"exactly full", 3, 3, 1, 0, false},
+		{"seats short", 3, 0, 2, 0, true},
+		{"luggage short", 0, 3, 1, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCapacity(4, 3, tt.used, tt.usedLug, tt.need, tt.needLug)
+			if got := errors.Is(err, ErrInsufficientCapacity); got != tt.wantErr {
+				t.Errorf("CheckCapacity() = %v, want insufficient=%v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCabCapacity_FlexFillsWithMix(t *testing.T) {
+	// 4 seats, 4 bag slots, but only 6 units shared between them.
+	flex := 6
+	cab := CabCapacity{Seats: 4, Luggage: 4, Flex: &flex}
+
+	// Rider 1: 2 seats + 2 bags → 4 units used.
+	if err := cab.Check(0, 0, 2, 2); err != nil {
+		t.Fatalf("rider 1: %v", err)
+	}
+	// Rider 2: 1 seat + 1 bag → 6 units used, cab full.
+	if err := cab.Check(2, 2, 1, 1); err != nil {
+		t.Fatalf("rider 2: %v", err)
+	}
+	if seats, luggage := cab.Remaining(3, 3); seats != 0 || luggage != 0 {
+		t.Errorf("Remaining(3, 3) = %d seats, %d bags; want 0, 0", seats, luggage)
+	}
+	// Rider 3: one more seat fits the seat limit (3 < 4) but not the units.
+	err := cab.Check(3, 3, 1, 0)
+	if !errors.Is(err, ErrInsufficientCapacity) {
+		t.Fatalf("rider 3: err = %v, want ErrInsufficientCapacity", err)
+	}
+	if want := "0 flex units remaining, need 1: insufficient capacity"; err.Error() != want {
+		t.Errorf("rider 3: err = %q, want %q", err, want)
+	}
+}
+
+func TestCabCapacity_FlexTradesSeatsForLuggage(t *testing.T) {
+	flex := 6
+	cab := CabCapacity{Seats: 4, Luggage: 4, Flex: &flex}
+
+	// A luggage-heavy group: 2 seats + 4 bags uses every unit.
+	if err := cab.Check(0, 0, 2, 4); err != nil {
+		t.Fatalf("2 seats + 4 bags: %v", err)
+	}
+	// The per-dimension limits still hold: 5 bags never fit.
+	if err := cab.Check(0, 0, 1, 5); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("5 bags: err = %v, want ErrInsufficientCapacity", err)
+	}
+	if seats, luggage := cab.Remaining(1, 1); seats != 3 || luggage != 3 {
+		t.Errorf("Remaining(1, 1) = %d seats, %d bags; want 3, 3", seats, luggage)
+	}
+}
+
+func TestCabCapacity_FixedIgnoresCombinedTotal(t *testing.T) {
+	cab := CabCapacity{Seats: 4, Luggage: 4}
+	if err := cab.Check(0, 0, 4, 4); err != nil {
+		t.Errorf("fixed cab 4 seats + 4 bags: %v", err)
+	}
+	if seats, luggage := cab.Remaining(1, 2); seats != 3 || luggage != 2 {
+		t.Errorf("Remaining(1, 2) = %d seats, %d bags; want 3, 2", seats, luggage)
+	}
+}
+
+func TestCabCapacity_CheckReportsRemaining(t *testing.T) {
+	// 4 seats, 3 bags; 3 seats and 1 bag taken; asking for 2 seats.
+	err := CabCapacity{Seats: 4, Luggage: 3}.Check(3, 1, 2, 0)
+
+	var capErr *CapacityError
+	if !errors.As(err, &capErr) {
+		t.Fatalf("err = %v, want *CapacityError", err)
+	}
+	if !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("err = %v, want it to wrap ErrInsufficientCapacity", err)
+	}
+	if capErr.Limit != "seats" || capErr.Remaining != 1 || capErr.Need != 2 {
+		t.Errorf("limit = %s %d/%d, want seats 1/2", capErr.Limit, capErr.Remaining, capErr.Need)
+	}
+	if capErr.RemainingSeats != 1 || capErr.RemainingLuggage != 2 {
+		t.Errorf("remaining = %d seats, %d bags; want 1, 2", capErr.RemainingSeats, capErr.RemainingLuggage)
+	}
+}
+
+func TestCabCapacity_LuggageUsesSeats(t *testing.T) {
+	// 4 seats, 2 bag slots; 2 seats and 2 bags taken; 1 rider with 2 bags.
+	tight := CabCapacity{Seats: 4, Luggage: 2}
+
+	if err := tight.Check(2, 2, 1, 2); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("disabled: err = %v, want luggage to run out", err)
+	}
+
+	tight.LuggageUsesSeats = true
+	// The two extra bags would take the last two seats, leaving none for
+	// the rider.
+	if err := tight.Check(2, 2, 1, 2); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("rider + 2 bags: err = %v, want seats to run out", err)
+	}
+	// One extra bag takes one seat, the rider the other.
+	if err := tight.Check(2, 2, 1, 1); err != nil {
+		t.Fatalf("rider + 1 bag: %v", err)
+	}
+	// Bags already on seats count against them: 2 riders, 3 bags aboard
+	// leaves one seat.
+	if err := tight.Check(2, 3, 2, 0); !errors.Is(err, ErrInsufficientCapacity) {
+		t.Errorf("2 riders after overflow: err = %v, want seats to run out", err)
+	}
+	if err := tight.Check(2, 3, 1, 0); err != nil {
+		t.Errorf("1 rider after overflow: %v", err)
+	}
+	if seats, luggage := tight.Remaining(2, 3); seats != 1 || luggage != 1 {
+		t.Errorf("Remaining = %d seats, %d bags; want 1, 1", seats, luggage)
+	}
+}
+
+func TestCabCapacity_CheckAccessible(t *testing.T) {
+	tests := []struct {
+		accessible, required bool
+		wantErr              bool
+	}{
+		{accessible: false, required: false},
+		{accessible: true, required: false},
+		{accessible: true, required: true},
+		{accessible: false, required: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		err := CabCapacity{Seats: 4, Accessible: tt.accessible}.CheckAccessible(tt.required)
+		if tt.wantErr != errors.Is(err, ErrNotAccessible) || (!tt.wantErr && err != nil) {
+			t.Errorf("accessible=%v required=%v: err = %v, want error %v", tt.accessible, tt.required, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCheckPassengerLimit(t *testing.T) {
+	tests := []struct {
+		name            string
+		used, need, max int
+		wantErr         bool
+	}{
+		{"off", 5, 1, 0, false},
+		{"below ceiling", 2, 1, 4, false},
+		{"reaches ceiling", 3, 1, 4, false},
+		{"at ceiling", 4, 1, 4, true},
+		{"group overshoots", 3, 2, 4, true},
+		{"large group on empty trip", 0, 6, 4, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckPassengerLimit(tt.used, tt.need, tt.max)
+			if got := errors.Is(err, ErrPassengerLimit); got != tt.wantErr {
+				t.Errorf("CheckPassengerLimit(%d, %d, %d) = %v, want limit=%v", tt.used, tt.need, tt.max, err, tt.wantErr)
+			}
+		})
+	}
+}
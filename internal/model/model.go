@@ -2,7 +2,12 @@
 // These structs map to the PostgreSQL schema defined in migrations/001_create_schema.up.sql.
 package model
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
 
 // ─── Enums ──────────────────────────────────────────────────
 
@@ -23,6 +28,12 @@ const (
 	CabOffline   CabStatus = "offline"
 )
 
+// CabStatuses lists every CabStatus.
+var CabStatuses = []CabStatus{CabAvailable, CabEnRoute, CabOnTrip, CabOffline}
+
+// Valid reports whether s is a known cab status.
+func (s CabStatus) Valid() bool { return slices.Contains(CabStatuses, s) }
+
 type RequestStatus string
 
 const (
@@ -31,8 +42,19 @@ const (
 	RequestConfirmed RequestStatus = "confirmed"
 	RequestCancelled RequestStatus = "cancelled"
 	RequestCompleted RequestStatus = "completed"
+	RequestExpired   RequestStatus = "expired"   // Never matched within the pending TTL.
+	RequestScheduled RequestStatus = "scheduled" // Waiting for its matching window before scheduled_at.
 )
 
+// RequestStatuses lists every RequestStatus.
+var RequestStatuses = []RequestStatus{
+	RequestPending, RequestMatched, RequestConfirmed, RequestCancelled,
+	RequestCompleted, RequestExpired, RequestScheduled,
+}
+
+// Valid reports whether s is a known ride request status.
+func (s RequestStatus) Valid() bool { return slices.Contains(RequestStatuses, s) }
+
 type TripStatus string
 
 const (
@@ -49,15 +71,57 @@ const (
 	DirectionFromAirport TripDirection = "from_airport"
 )
 
+// TripDirections lists every TripDirection.
+var TripDirections = []TripDirection{DirectionToAirport, DirectionFromAirport}
+
+// Valid reports whether d is a known trip direction.
+func (d TripDirection) Valid() bool { return slices.Contains(TripDirections, d) }
+
+// EventType identifies a domain event written to the outbox.
+type EventType string
+
+const (
+	EventRideCreated    EventType = "ride_created"
+	EventRideMatched    EventType = "ride_matched"
+	EventRideBooked     EventType = "ride_booked"
+	EventRideCancelled  EventType = "ride_cancelled"
+	EventRideCompleted  EventType = "ride_completed"
+	EventRideExpired    EventType = "ride_expired"
+	EventRideReassigned EventType = "ride_reassigned"
+	EventRideActivated  EventType = "ride_activated" // Scheduled request entered the pending pool (or its reserved trip).
+	EventTripCancelled  EventType = "trip_cancelled" // An operator cancelled the whole trip.
+	EventRideUpdated    EventType = "ride_updated"   // Rider changed seats, luggage or tolerance before matching.
+	EventTripMerged     EventType = "trip_merged"    // An operator moved the trip's riders onto another trip.
+)
+
+// Aggregate types for outbox events.
+const (
+	AggregateRideRequest = "ride_request"
+	AggregateTrip        = "trip"
+)
+
 // ─── Capacity Constraints (matches DB CHECK constraints) ───────
 
 const (
 	MinLuggagePerRequest = 0
-	MaxLuggagePerRequest = 8
+	MaxLuggagePerRequest = 8 // Ceiling; the enforced limit is configurable (RIDE_MAX_LUGGAGE).
 	MinLuggagePerCab     = 0
 	MaxLuggagePerCab     = 10
+	MinSeatsPerCab       = 1
+	MaxSeatsPerCab       = 8
 )
 
+// ValidateLuggageLimit checks a configured per-request luggage maximum
+// against the ride_requests CHECK (luggage_count BETWEEN 0 AND 8): a
+// larger limit would accept requests the database then rejects.
+func ValidateLuggageLimit(max int) error {
+	if max < MinLuggagePerRequest || max > MaxLuggagePerRequest {
+		return fmt.Errorf("luggage limit %d outside database constraint %d–%d",
+			max, MinLuggagePerRequest, MaxLuggagePerRequest)
+	}
+	return nil
+}
+
 // ─── Location ───────────────────────────────────────────────
 
 // Location represents a WGS-84 geographic point (EPSG:4326).
@@ -66,6 +130,36 @@ type Location struct {
 	Lon float64 `json:"lon"`
 }
 
+// Validate checks that both coordinates are set and within WGS-84 range.
+// A zero coordinate is treated as missing (nothing we serve sits on the
+// equator or the prime meridian). Failures are *FieldError with Field
+// "lat" or "lon".
+func (l Location) Validate() error {
+	switch {
+	case l.Lat == 0:
+		return &FieldError{Field: "lat", Message: "is required"}
+	case l.Lon == 0:
+		return &FieldError{Field: "lon", Message: "is required"}
+	case l.Lat < -90 || l.Lat > 90:
+		return &FieldError{Field: "lat", Message: "must be between -90 and 90"}
+	case l.Lon < -180 || l.Lon > 180:
+		return &FieldError{Field: "lon", Message: "must be between -180 and 180"}
+	}
+	return nil
+}
+
+// FieldError is a semantic validation failure on a single input field.
+// Handlers report it as 422 with the field name; 400 is reserved for
+// bodies that could not be parsed at all.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + " " + e.Message
+}
+
 // ─── Domain Models ──────────────────────────────────────────
 
 // User maps to the `users` table.
@@ -81,18 +175,30 @@ type User struct {
 
 // Cab maps to the `cabs` table.
 // LuggageCapacity is the number of luggage slots (0–10). Enforced in matching and booking.
+// FlexCapacity, when set, caps seats + luggage together (see CabCapacity).
 type Cab struct {
 	ID              int64     `json:"id"`
 	DriverID        int64     `json:"driver_id"`
 	LicensePlate    string    `json:"license_plate"`
 	SeatCapacity    int       `json:"seat_capacity"`
-	LuggageCapacity int       `json:"luggage_capacity"` // Slots available; CHECK (0–10)
+	LuggageCapacity int       `json:"luggage_capacity"`        // Slots available; CHECK (0–10)
+	FlexCapacity    *int      `json:"flex_capacity,omitempty"` // Shared units; nil = fixed capacity.
+	Accessible      bool      `json:"accessible"`              // Wheelchair accessible.
 	CurrentLocation *Location `json:"current_location,omitempty"`
 	Status          CabStatus `json:"status"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
 
+// CabLocationUpdate is one telematics position report for a cab.
+// TS is when the device took the fix, not when the server received it.
+type CabLocationUpdate struct {
+	CabID int64     `json:"cab_id"`
+	Lat   float64   `json:"lat"`
+	Lon   float64   `json:"lon"`
+	TS    time.Time `json:"ts"`
+}
+
 // RideRequest maps to the `ride_requests` table.
 // LuggageCount is the number of bags (0–8). Must fit within cab's LuggageCapacity.
 type RideRequest struct {
@@ -102,13 +208,41 @@ type RideRequest struct {
 	Destination     Location      `json:"destination"`
 	Direction       TripDirection `json:"direction"`
 	SeatsNeeded     int           `json:"seats_needed"`
-	LuggageCount    int           `json:"luggage_count"` // Bags; CHECK (0–8); enforced in matching/booking
+	LuggageCount    int           `json:"luggage_count"` // Bags; CHECK (0–8), configurable lower; enforced in matching/booking
 	ToleranceMeters int           `json:"tolerance_meters"`
 	Status          RequestStatus `json:"status"`
-	TripID          *int64        `json:"trip_id,omitempty"`
+	TripID          *int64        `json:"trip_id,omitempty"` // Nil unless on a trip; never points at 0 (see TripRef)
 	ScheduledAt     *time.Time    `json:"scheduled_at,omitempty"`
 	CreatedAt       time.Time     `json:"created_at"`
 	UpdatedAt       time.Time     `json:"updated_at"`
+
+	// RequiresAccessible restricts the rider to wheelchair-accessible cabs.
+	RequiresAccessible bool `json:"requires_accessible"`
+
+	// DeadlineAt is the latest acceptable drop-off (from_airport only).
+	// Matching skips trips that would drop this rider, or anyone already
+	// on the trip, after their deadline.
+	DeadlineAt *time.Time `json:"deadline_at,omitempty"`
+
+	// ClientRequestID is the caller's own UUID for the request, unique per
+	// user; a retried create with the same one returns this request.
+	ClientRequestID *string `json:"client_request_id,omitempty"`
+
+	// Fare snapshot taken at booking time; nil until booked.
+	FareCents         *int     `json:"fare_cents,omitempty"`
+	PoolDiscountCents *int     `json:"pool_discount_cents,omitempty"`
+	SurgeMultiplier   *float64 `json:"surge_multiplier,omitempty"`
+}
+
+// TripRef returns id as a RideRequest.TripID: nil when id is nil or points
+// at an id no trip has (trip ids start at 1). A request without a trip must
+// serialize with trip_id absent; "trip_id": 0 reads to clients as a real
+// trip. Code that fills TripID from a scan or an int64 goes through here.
+func TripRef(id *int64) *int64 {
+	if id == nil || *id <= 0 {
+		return nil
+	}
+	return id
 }
 
 // Trip maps to the `trips` table.
@@ -127,25 +261,79 @@ type Trip struct {
 	UpdatedAt      time.Time     `json:"updated_at"`
 }
 
+// OutboxEvent maps to the `outbox` table.
+// Rows are written in the same transaction as the state change they describe.
+type OutboxEvent struct {
+	ID            int64           `json:"id"`
+	EventType     EventType       `json:"event_type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   int64           `json:"aggregate_id"`
+	Payload       json.RawMessage `json:"payload"`
+	CreatedAt     time.Time       `json:"created_at"`
+	SentAt        *time.Time      `json:"sent_at,omitempty"`
+	Attempts      int             `json:"-"` // Failed publishes so far; not part of the event.
+}
+
+// WebhookDeadLetter maps to the `webhook_deadletter` table: an outbox
+// event the webhook kept refusing, parked for an operator to replay.
+type WebhookDeadLetter struct {
+	ID         int64       `json:"id"`
+	Event      OutboxEvent `json:"event"`
+	Attempts   int         `json:"attempts"`   // Failed deliveries, plus any replays.
+	LastError  string      `json:"last_error"` // Why the latest failed delivery failed.
+	CreatedAt  time.Time   `json:"created_at"`
+	ReplayedAt *time.Time  `json:"replayed_at,omitempty"`
+}
+
 // ─── Matching–specific DTOs ─────────────────────────────────
 
 // CandidateTrip is a denormalized view used by the matching engine.
 // It combines Trip + Cab capacity + current load from a single DB query.
 type CandidateTrip struct {
-	TripID          int64      `json:"trip_id"`
-	CabID           int64      `json:"cab_id"`
+	TripID          int64 `json:"trip_id"`
+	CabID           int64 `json:"cab_id"`
 	Direction       TripDirection
 	SeatCapacity    int
 	LuggageCapacity int
+	FlexCapacity    *int       // Shared seat+luggage units; nil = fixed capacity.
+	Accessible      bool       // Cab is wheelchair accessible.
 	CurrentLoad     int        // Sum of seats_needed across matched passengers.
 	CurrentLuggage  int        // Sum of luggage_count across matched passengers.
 	Route           []Location // Ordered stops.
+	Pickups         []Pickup   // Riders' pickups, for rechecking their tolerance after an insertion.
+	Dropoffs        []Dropoff  // Riders' drop-offs; loaded for from_airport requests only.
 	DistanceToReq   float64    // Distance from the trip centroid to the new request (meters).
+
+	// DepartsFrom and DepartsUntil bound when the trip's riders leave: a
+	// reservation's scheduled_at, or now for everyone else.
+	DepartsFrom  time.Time
+	DepartsUntil time.Time
+}
+
+// Pickup is one rider's pickup on a trip and the detour they accept.
+type Pickup struct {
+	Origin          Location
+	ToleranceMeters int
+}
+
+// Dropoff is one rider's drop-off on a from_airport trip.
+type Dropoff struct {
+	Destination Location
+	DeadlineAt  *time.Time // Nil when the rider gave no deadline.
+}
+
+// Capacity returns the cab's capacity model for this trip.
+func (ct *CandidateTrip) Capacity() CabCapacity {
+	return CabCapacity{Seats: ct.SeatCapacity, Luggage: ct.LuggageCapacity, Flex: ct.FlexCapacity, Accessible: ct.Accessible}
 }
 
-// MatchResult is returned by the matching service.
+// MatchResult is returned by the matching service. The added detour is
+// given in both units: AddedDetour in minutes, AddedDetourMeters the same
+// detour as distance at geo.AverageSpeedKmph (not a separately routed length).
 type MatchResult struct {
-	TripID     int64   `json:"trip_id"`
-	CabID      int64   `json:"cab_id"`
-	AddedDetour float64 `json:"added_detour_minutes"`
+	TripID            int64   `json:"trip_id"`
+	CabID             int64   `json:"cab_id"`
+	AddedDetour       float64 `json:"added_detour_minutes"`
+	AddedDetourMeters float64 `json:"added_detour_meters"`
+	CurrentLoad       int     `json:"current_load"` // Seats already booked on the trip, before this rider.
 }
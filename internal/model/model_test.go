@@ -0,0 +1,118 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestLocationValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		loc       Location
+		wantField string // "" = valid
+	}{
+		{"valid", Location{Lat: 28.7041, Lon: 77.1025}, ""},
+		{"missing lat", Location{Lon: 77.1025}, "lat"},
+		{"missing lon", Location{Lat: 28.7041}, "lon"},
+		{"lat out of range", Location{Lat: 128.7, Lon: 77.1}, "lat"},
+		{"lon out of range", Location{Lat: 28.7, Lon: -277.1}, "lon"},
+		{"bounds inclusive", Location{Lat: -90, Lon: 180}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.loc.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Errorf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			var fe *FieldError
+			if !errors.As(err, &fe) {
+				t.Fatalf("Validate() = %v, want *FieldError", err)
+			}
+			if fe.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", fe.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestValidateLuggageLimit(t *testing.T) {
+	for _, max := range []int{0, 2, MaxLuggagePerRequest} {
+		if err := ValidateLuggageLimit(max); err != nil {
+			t.Errorf("ValidateLuggageLimit(%d) = %v, want nil", max, err)
+		}
+	}
+	// Above the database CHECK, or negative.
+	for _, max := range []int{-1, MaxLuggagePerRequest + 1} {
+		if err := ValidateLuggageLimit(max); err == nil {
+			t.Errorf("ValidateLuggageLimit(%d) = nil, want error", max)
+		}
+	}
+}
+
+func TestEnumValid(t *testing.T) {
+	for _, d := range TripDirections {
+		if !d.Valid() {
+			t.Errorf("TripDirection(%q).Valid() = false", d)
+		}
+	}
+	for _, s := range RequestStatuses {
+		if !s.Valid() {
+			t.Errorf("RequestStatus(%q).Valid() = false", s)
+		}
+	}
+	for _, s := range CabStatuses {
+		if !s.Valid() {
+			t.Errorf("CabStatus(%q).Valid() = false", s)
+		}
+	}
+
+	for _, bad := range []string{"", "sideways", "TO_AIRPORT", "planned"} {
+		if TripDirection(bad).Valid() || RequestStatus(bad).Valid() || CabStatus(bad).Valid() {
+			t.Errorf("%q accepted by a Valid()", bad)
+		}
+	}
+}
+
+func TestTripRef(t *testing.T) {
+	zero, negative, seven := int64(0), int64(-1), int64(7)
+	if TripRef(nil) != nil || TripRef(&zero) != nil || TripRef(&negative) != nil {
+		t.Error("TripRef kept a nil, zero or negative trip id, want nil")
+	}
+	if got := TripRef(&seven); got == nil || *got != 7 {
+		t.Errorf("TripRef(&7) = %v, want 7", got)
+	}
+}
+
+func TestRideRequestJSON_TripID(t *testing.T) {
+	zero, seven := int64(0), int64(7)
+	tests := []struct {
+		name    string
+		req     RideRequest
+		want    float64 // trip_id in the JSON
+		present bool
+	}{
+		{"matched", RideRequest{Status: RequestMatched, TripID: TripRef(&seven)}, 7, true},
+		{"unmatched", RideRequest{Status: RequestPending}, 0, false},
+		{"zero id through TripRef", RideRequest{Status: RequestPending, TripID: TripRef(&zero)}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := json.Marshal(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body map[string]any
+			if err := json.Unmarshal(b, &body); err != nil {
+				t.Fatal(err)
+			}
+			got, present := body["trip_id"]
+			if present != tt.present || (present && got != tt.want) {
+				t.Errorf("trip_id = %v (present %v), want %v (present %v) in %s", got, present, tt.want, tt.present, b)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+//go:build integration
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestBookRide_FareSnapshotSurvivesSurgeChange(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	origin := model.Location{Lat: 10.0050, Lon: 70.0000}
+	tripID := seedCandidateTrip(t, ctx, tx, "FARE-1", origin)
+
+	var requestID, cabID int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO ride_requests (user_id, origin, destination, direction, status)
+		SELECT user_id, origin, destination, direction, 'pending' FROM ride_requests WHERE trip_id = $1
+		RETURNING id, (SELECT cab_id FROM trips WHERE id = $1)
+	`, tripID).Scan(&requestID, &cabID); err != nil {
+		t.Fatalf("seed pending request: %v", err)
+	}
+
+	repo := NewBookingRepository(nil, model.Location{Lat: 28.5562, Lon: 77.0889}, 0)
+	fare := FareSnapshot{FareCents: 1080, PoolDiscountCents: 120, SurgeMultiplier: 1.5, Pooled: true}
+	if _, err := repo.bookRide(ctx, tx, requestID, cabID, tripID, fare); err != nil {
+		t.Fatalf("bookRide: %v", err)
+	}
+
+	// Surge moves after the booking: more riders waiting at the pickup.
+	before, err := queryDemandSupplyFromDB(ctx, tx, origin, 1000, model.DirectionToAirport, 0)
+	if err != nil {
+		t.Fatalf("demand before: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO ride_requests (user_id, origin, destination, direction, status)
+			SELECT user_id, origin, destination, direction, 'pending' FROM ride_requests WHERE id = $1
+		`, requestID); err != nil {
+			t.Fatalf("seed demand: %v", err)
+		}
+	}
+	after, err := queryDemandSupplyFromDB(ctx, tx, origin, 1000, model.DirectionToAirport, 0)
+	if err != nil {
+		t.Fatalf("demand after: %v", err)
+	}
+	if after.Demand != before.Demand+5 {
+		t.Fatalf("demand = %d, want %d", after.Demand, before.Demand+5)
+	}
+
+	var (
+		gotFare, gotDiscount int
+		gotSurge             float64
+	)
+	if err := tx.QueryRow(ctx, `
+		SELECT fare_cents, pool_discount_cents, surge_multiplier::float8
+		FROM ride_requests WHERE id = $1
+	`, requestID).Scan(&gotFare, &gotDiscount, &gotSurge); err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if gotFare != fare.FareCents || gotDiscount != fare.PoolDiscountCents || gotSurge != fare.SurgeMultiplier {
+		t.Errorf("stored fare %d, discount %d, surge %.2f; want %d, %d, %.2f (as booked)",
+			gotFare, gotDiscount, gotSurge, fare.FareCents, fare.PoolDiscountCents, fare.SurgeMultiplier)
+	}
+}
@@ -6,6 +6,8 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -18,22 +20,79 @@ import (
 // BookingRepository handles transactional booking with row-level locking.
 type BookingRepository struct {
 	pool *pgxpool.Pool
+
+	// airport anchors the trip route persisted on each booking.
+	airport model.Location
+
+	// maxPassengers is the pool size ceiling per trip (0 = none).
+	maxPassengers int
+
+	// CabStaleAfter, when positive, keeps cabs whose last location push is
+	// older than this out of FindAvailableCabNear: a cab that stopped
+	// reporting is treated as offline.
+	CabStaleAfter time.Duration
+
+	// LuggageUsesSeats lets BookRide, ReassignRequest and MergeTrips put
+	// bags that do not fit in a cab's luggage space on its free seats, one
+	// seat per bag (model.CabCapacity).
+	LuggageUsesSeats bool
+
+	// ReserveScheduled lets BookRide book a SCHEDULED request ahead of its
+	// departure. The request keeps its 'scheduled' status but takes a
+	// trip_id, and its seats and bags count against the cab from then on
+	// (tripLoad), so live riders cannot take them. ActivateDueScheduled
+	// turns the reservation into a 'matched' booking.
+	ReserveScheduled bool
 }
 
-// NewBookingRepository creates a new booking repository.
-func NewBookingRepository(pool *pgxpool.Pool) *BookingRepository {
-	return &BookingRepository{pool: pool}
+// NewBookingRepository creates a new booking repository. airport is the
+// configured airport, the fixed end of every stored trip route;
+// maxPassengers is MATCH_MAX_PASSENGERS_PER_TRIP.
+func NewBookingRepository(pool *pgxpool.Pool, airport model.Location, maxPassengers int) *BookingRepository {
+	return &BookingRepository{pool: pool, airport: airport, maxPassengers: maxPassengers}
 }
 
 // BookingResult contains the outcome of a successful booking transaction.
 type BookingResult struct {
-	TripID            int64  `json:"trip_id"`
-	CabID             int64  `json:"cab_id"`
-	RequestID         int64  `json:"request_id"`
-	SeatsBooked       int    `json:"seats_booked"`
-	RemainingSeats    int    `json:"remaining_seats"`
-	LuggageBooked     int    `json:"luggage_booked"`
-	RemainingLuggage  int    `json:"remaining_luggage"`
+	TripID            int64   `json:"trip_id"`
+	CabID             int64   `json:"cab_id"`
+	RequestID         int64   `json:"request_id"`
+	SeatsBooked       int     `json:"seats_booked"`
+	RemainingSeats    int     `json:"remaining_seats"`
+	LuggageBooked     int     `json:"luggage_booked"`
+	RemainingLuggage  int     `json:"remaining_luggage"`
+	Pooled            bool    `json:"pooled"`                        // True if the request joined an existing trip.
+	FareCents         int     `json:"fare_cents"`                    // Fare quoted at booking time (after pool discount).
+	PoolDiscountCents int     `json:"pool_discount_cents,omitempty"` // Discount applied for pooling.
+	SurgeMultiplier   float64 `json:"surge_multiplier"`              // Surge in effect when the fare was quoted.
+
+	// Surge is what the booking changed in surge supply beyond the
+	// request itself: the trip's cab, if it was still 'available'.
+	Surge []SurgeChange `json:"-"`
+
+	// Replayed marks an earlier booking of the request returned again (see
+	// BookingService.BookRide's double-submit handling), not a new one.
+	Replayed bool `json:"-"`
+}
+
+// ErrDirectionMismatch is returned by BookRide when the trip runs the
+// other way from the ride request (e.g. a to_airport rider on a
+// from_airport trip). Matching never proposes such a trip; this guards
+// direct or buggy callers.
+var ErrDirectionMismatch = errors.New("trip direction does not match request")
+
+// ErrRequestNotPending is returned by BookRide when the ride request was
+// booked, cancelled or expired before the booking transaction locked it,
+// e.g. by a concurrent double-submit of the same request.
+var ErrRequestNotPending = errors.New("ride request is not pending")
+
+// FareSnapshot is the price the rider agreed to, stored on the ride request
+// at booking time so disputes can be settled against it.
+type FareSnapshot struct {
+	FareCents         int
+	PoolDiscountCents int
+	SurgeMultiplier   float64
+	Pooled            bool // Quoted for joining an existing trip.
 }
 
 // ─── The Core Transactional Booking ─────────────────────────
@@ -42,27 +101,31 @@ type BookingResult struct {
 //
 // Concurrency strategy: PESSIMISTIC LOCKING
 //
-//   Scenario: Two users try to book the last seat at the exact same millisecond.
+//	Scenario: Two users try to book the last seat at the exact same millisecond.
 //
-//   Timeline:
-//     T1: BEGIN → SELECT cab FOR UPDATE → (cab row LOCKED)
-//     T2: BEGIN → SELECT cab FOR UPDATE → (BLOCKS, waiting for T1's lock)
-//     T1: seats OK → UPDATE cab → INSERT/UPDATE → COMMIT → (lock released)
-//     T2: (unblocked) → re-reads cab → seats FULL → ROLLBACK → returns error
+//	Timeline:
+//	  T1: BEGIN → SELECT cab FOR UPDATE → (cab row LOCKED)
+//	  T2: BEGIN → SELECT cab FOR UPDATE → (BLOCKS, waiting for T1's lock)
+//	  T1: seats OK → UPDATE cab → INSERT/UPDATE → COMMIT → (lock released)
+//	  T2: (unblocked) → re-reads cab → seats FULL → ROLLBACK → returns error
 //
 // The SELECT ... FOR UPDATE on the cab row ensures only ONE transaction can
 // read-and-modify the cab at a time. The second transaction will BLOCK until
 // the first commits or rolls back, then re-read the updated row.
 //
 // Timeout handling:
-//   - The context carries a 5-second deadline for the entire transaction.
-//   - If the lock wait exceeds this, pgx returns a context.DeadlineExceeded
-//     error, which the service layer translates to ErrBookingTimeout.
+//   - The context carries the booking deadline for the entire transaction
+//     (BookingService.timeout, or a per-call override).
+//   - lock_timeout is set to the time remaining on that deadline, so
+//     Postgres gives up on the lock wait no later than the context does.
+//   - Either way (context.DeadlineExceeded or a lock timeout error), the
+//     service layer translates the failure to ErrBookingTimeout.
 func (r *BookingRepository) BookRide(
 	ctx context.Context,
 	requestID int64,
 	cabID int64,
 	tripID int64,
+	fare FareSnapshot,
 ) (*BookingResult, error) {
 
 	// ── Wrap the entire booking in a transaction ────────
@@ -75,47 +138,78 @@ func (r *BookingRepository) BookRide(
 	// Defer rollback — no-op if tx was already committed.
 	defer tx.Rollback(ctx)
 
+	if err := setLockTimeout(ctx, tx); err != nil {
+		return nil, fmt.Errorf("booking: %w", err)
+	}
+
+	result, err := r.bookRide(ctx, tx, requestID, cabID, tripID, fare)
+	if err != nil {
+		return nil, err
+	}
+
+	// ── Step 5: COMMIT ──────────────────────────────────
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("booking: commit: %w", err)
+	}
+	return result, nil
+}
+
+// bookRide runs steps 1–4 of BookRide inside tx, leaving the commit to
+// the caller.
+func (r *BookingRepository) bookRide(
+	ctx context.Context,
+	tx pgx.Tx,
+	requestID int64,
+	cabID int64,
+	tripID int64,
+	fare FareSnapshot,
+) (*BookingResult, error) {
+
 	// ── Step 1: LOCK the cab row ────────────────────────
 	// SELECT ... FOR UPDATE acquires an exclusive row-level lock.
 	// Any concurrent transaction hitting the same cab will BLOCK here
 	// until this transaction completes.
 	var (
-		seatCapacity    int
-		luggageCapacity int
-		cabStatus       model.CabStatus
+		capacity  model.CabCapacity
+		cabStatus model.CabStatus
 	)
-	err = tx.QueryRow(ctx, `
-		SELECT seat_capacity, luggage_capacity, status
+	err := tx.QueryRow(ctx, `
+		SELECT seat_capacity, luggage_capacity, flex_capacity, accessible, status
 		FROM cabs
 		WHERE id = $1
 		FOR UPDATE
-	`, cabID).Scan(&seatCapacity, &luggageCapacity, &cabStatus)
+	`, cabID).Scan(&capacity.Seats, &capacity.Luggage, &capacity.Flex, &capacity.Accessible, &cabStatus)
 	if err != nil {
 		return nil, fmt.Errorf("booking: lock cab %d: %w", cabID, err)
 	}
+	capacity.LuggageUsesSeats = r.LuggageUsesSeats
 
 	// ── Step 2: LOCK the ride request row ───────────────
 	var (
-		reqSeats   int
-		reqLuggage int
-		reqStatus  model.RequestStatus
-		reqTripID  *int64
+		reqSeats      int
+		reqLuggage    int
+		reqStatus     model.RequestStatus
+		reqTripID     *int64
+		reqDirection  model.TripDirection
+		reqAccessible bool
 	)
 	err = tx.QueryRow(ctx, `
-		SELECT seats_needed, luggage_count, status, trip_id
+		SELECT seats_needed, luggage_count, status, trip_id, direction, requires_accessible
 		FROM ride_requests
 		WHERE id = $1
 		FOR UPDATE
-	`, requestID).Scan(&reqSeats, &reqLuggage, &reqStatus, &reqTripID)
+	`, requestID).Scan(&reqSeats, &reqLuggage, &reqStatus, &reqTripID, &reqDirection, &reqAccessible)
 	if err != nil {
 		return nil, fmt.Errorf("booking: lock request %d: %w", requestID, err)
 	}
 
 	// ── Step 3: Validate business rules ─────────────────
 
-	// 3a: Request must be in 'pending' state.
-	if reqStatus != model.RequestPending {
-		return nil, fmt.Errorf("booking: request %d status is '%s', expected 'pending'", requestID, reqStatus)
+	// 3a: Request must be in 'pending' state, or an unreserved
+	// 'scheduled' one when reservations are on.
+	reserving := r.ReserveScheduled && reqStatus == model.RequestScheduled && reqTripID == nil
+	if reqStatus != model.RequestPending && !reserving {
+		return nil, fmt.Errorf("booking: request %d status is '%s', expected 'pending': %w", requestID, reqStatus, ErrRequestNotPending)
 	}
 
 	// 3b: Cab must be available or en_route.
@@ -123,42 +217,55 @@ func (r *BookingRepository) BookRide(
 		return nil, fmt.Errorf("booking: cab %d status is '%s', not bookable", cabID, cabStatus)
 	}
 
-	// 3c: Calculate current load on this trip.
-	var currentSeats, currentLuggage int
-	err = tx.QueryRow(ctx, `
-		SELECT COALESCE(SUM(seats_needed), 0)::int,
-		       COALESCE(SUM(luggage_count), 0)::int
-		FROM ride_requests
-		WHERE trip_id = $1
-		  AND status IN ('matched', 'confirmed')
-	`, tripID).Scan(&currentSeats, &currentLuggage)
+	// 3c: Trip must run the same way as the request (no cross-pooling).
+	var tripDirection model.TripDirection
+	err = tx.QueryRow(ctx, `SELECT direction FROM trips WHERE id = $1`, tripID).Scan(&tripDirection)
 	if err != nil {
-		return nil, fmt.Errorf("booking: query trip %d load: %w", tripID, err)
+		return nil, fmt.Errorf("booking: read trip %d: %w", tripID, err)
+	}
+	if err := checkTripDirection(requestID, tripID, reqDirection, tripDirection); err != nil {
+		return nil, err
+	}
+
+	// 3d: A rider who needs an accessible cab only rides in one.
+	if err := capacity.CheckAccessible(reqAccessible); err != nil {
+		return nil, fmt.Errorf("booking: cab %d: %w", cabID, err)
 	}
 
-	// 3d: CHECK CAPACITY — the critical constraint.
-	remainingSeats := seatCapacity - currentSeats
-	remainingLuggage := luggageCapacity - currentLuggage
+	// 3e: Calculate current load on this trip.
+	currentSeats, currentLuggage, err := tripLoad(ctx, tx, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("booking: query trip %d load: %w", tripID, err)
+	}
 
-	if reqSeats > remainingSeats {
+	// 3f: CHECK CAPACITY — the critical constraint.
+	// Flex cabs also cap seats + luggage together, and LuggageUsesSeats
+	// spills extra bags onto seats (model.CabCapacity).
+	if err := capacity.Check(currentSeats, currentLuggage, reqSeats, reqLuggage); err != nil {
 		// This is the "last seat taken" scenario.
 		// Transaction rolls back automatically via defer.
-		return nil, fmt.Errorf("booking: cab %d has %d seats remaining, need %d",
-			cabID, remainingSeats, reqSeats)
+		return nil, fmt.Errorf("booking: cab %d has %w", cabID, err)
 	}
-	if reqLuggage > remainingLuggage {
-		return nil, fmt.Errorf("booking: cab %d has %d luggage slots remaining, need %d",
-			cabID, remainingLuggage, reqLuggage)
+
+	// 3g: Pool size ceiling, independent of the cab's seats.
+	if err := model.CheckPassengerLimit(currentSeats, reqSeats, r.maxPassengers); err != nil {
+		return nil, fmt.Errorf("booking: trip %d: %w", tripID, err)
 	}
 
 	// ── Step 4: UPDATE — all constraints passed ─────────
 
-	// 4a: Mark ride request as 'matched' and assign to trip.
+	// 4a: Mark ride request as 'matched' (a reservation stays 'scheduled'),
+	// assign to trip, snapshot the fare.
+	newStatus := model.RequestMatched
+	if reserving {
+		newStatus = model.RequestScheduled
+	}
 	_, err = tx.Exec(ctx, `
 		UPDATE ride_requests
-		SET status = 'matched', trip_id = $2
+		SET status = $6, trip_id = $2,
+		    fare_cents = $3, pool_discount_cents = $4, surge_multiplier = $5
 		WHERE id = $1
-	`, requestID, tripID)
+	`, requestID, tripID, fare.FareCents, fare.PoolDiscountCents, fare.SurgeMultiplier, newStatus)
 	if err != nil {
 		return nil, fmt.Errorf("booking: update request %d: %w", requestID, err)
 	}
@@ -173,84 +280,284 @@ func (r *BookingRepository) BookRide(
 		return nil, fmt.Errorf("booking: update trip %d: %w", tripID, err)
 	}
 
-	// 4c: Update cab status to 'en_route' if not already.
-	_, err = tx.Exec(ctx, `
-		UPDATE cabs
-		SET status = 'en_route'
-		WHERE id = $1 AND status = 'available'
-	`, cabID)
+	// 4c: Re-plan and store the trip's route with the new rider on it.
+	if err := updateTripRoute(ctx, tx, tripID, tripDirection, r.airport); err != nil {
+		return nil, fmt.Errorf("booking: %w", err)
+	}
+
+	// 4d: Update cab status to 'en_route' if not already.
+	surge, err := claimCab(ctx, tx, cabID)
 	if err != nil {
 		return nil, fmt.Errorf("booking: update cab %d status: %w", cabID, err)
 	}
 
-	// ── Step 5: COMMIT ──────────────────────────────────
-	if err := tx.Commit(ctx); err != nil {
-		return nil, fmt.Errorf("booking: commit: %w", err)
+	// 4e: Record the event in the same transaction. It carries the whole
+	// result, so PriorBooking can replay it to a repeat submit.
+	remainingSeats, remainingLuggage := capacity.Remaining(currentSeats+reqSeats, currentLuggage+reqLuggage)
+	err = insertOutboxEvent(ctx, tx, model.EventRideBooked, model.AggregateRideRequest, requestID, map[string]any{
+		"trip_id": tripID, "cab_id": cabID, "seats": reqSeats, "luggage": reqLuggage,
+		"remaining_seats": remainingSeats, "remaining_luggage": remainingLuggage, "pooled": fare.Pooled,
+		"fare_cents": fare.FareCents, "pool_discount_cents": fare.PoolDiscountCents,
+		"surge_multiplier": fare.SurgeMultiplier, "reserved": reserving,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("booking: %w", err)
 	}
 
 	return &BookingResult{
-		TripID:           tripID,
-		CabID:            cabID,
-		RequestID:        requestID,
-		SeatsBooked:      reqSeats,
-		RemainingSeats:   remainingSeats - reqSeats,
-		LuggageBooked:    reqLuggage,
-		RemainingLuggage: remainingLuggage - reqLuggage,
+		TripID:            tripID,
+		CabID:             cabID,
+		RequestID:         requestID,
+		SeatsBooked:       reqSeats,
+		RemainingSeats:    remainingSeats,
+		LuggageBooked:     reqLuggage,
+		RemainingLuggage:  remainingLuggage,
+		Pooled:            fare.Pooled,
+		FareCents:         fare.FareCents,
+		PoolDiscountCents: fare.PoolDiscountCents,
+		SurgeMultiplier:   fare.SurgeMultiplier,
+		Surge:             surge,
+	}, nil
+}
+
+// PriorBooking returns the booking that holds requestID on its trip,
+// rebuilt from the latest ride_booked outbox event, so a repeat submit of
+// a booked request gets the same result as the call that booked it. It
+// returns an error wrapping ErrRequestNotPending if the request is not
+// booked (pending, cancelled, expired, …), or has since been moved to
+// another trip than the event names.
+func (r *BookingRepository) PriorBooking(ctx context.Context, requestID int64) (*BookingResult, error) {
+	var (
+		status  model.RequestStatus
+		tripID  *int64
+		payload []byte
+	)
+	err := r.pool.QueryRow(ctx, `
+		SELECT rr.status, rr.trip_id, o.payload
+		FROM ride_requests rr
+		LEFT JOIN LATERAL (
+			SELECT payload
+			FROM outbox
+			WHERE event_type = $2 AND aggregate_type = $3 AND aggregate_id = rr.id
+			ORDER BY id DESC
+			LIMIT 1
+		) o ON true
+		WHERE rr.id = $1
+	`, requestID, model.EventRideBooked, model.AggregateRideRequest).Scan(&status, &tripID, &payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("booking: request %d not found: %w", requestID, ErrRequestNotPending)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("booking: load prior booking of request %d: %w", requestID, err)
+	}
+	booked := status == model.RequestMatched || (status == model.RequestScheduled && tripID != nil)
+	if !booked || payload == nil {
+		return nil, fmt.Errorf("booking: request %d status is '%s', not booked: %w", requestID, status, ErrRequestNotPending)
+	}
+	return bookingResultFromEvent(requestID, *tripID, payload)
+}
+
+// bookingResultFromEvent decodes a ride_booked payload into the
+// BookingResult it was written with. The event must name tripID, the
+// request's current trip.
+func bookingResultFromEvent(requestID, tripID int64, payload []byte) (*BookingResult, error) {
+	var ev struct {
+		TripID            int64   `json:"trip_id"`
+		CabID             int64   `json:"cab_id"`
+		Seats             int     `json:"seats"`
+		Luggage           int     `json:"luggage"`
+		RemainingSeats    int     `json:"remaining_seats"`
+		RemainingLuggage  int     `json:"remaining_luggage"`
+		Pooled            bool    `json:"pooled"`
+		FareCents         int     `json:"fare_cents"`
+		PoolDiscountCents int     `json:"pool_discount_cents"`
+		SurgeMultiplier   float64 `json:"surge_multiplier"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, fmt.Errorf("booking: decode prior booking of request %d: %w", requestID, err)
+	}
+	if ev.TripID != tripID {
+		return nil, fmt.Errorf("booking: request %d moved from trip %d to %d since it was booked: %w",
+			requestID, ev.TripID, tripID, ErrRequestNotPending)
+	}
+	return &BookingResult{
+		TripID:            ev.TripID,
+		CabID:             ev.CabID,
+		RequestID:         requestID,
+		SeatsBooked:       ev.Seats,
+		RemainingSeats:    ev.RemainingSeats,
+		LuggageBooked:     ev.Luggage,
+		RemainingLuggage:  ev.RemainingLuggage,
+		Pooled:            ev.Pooled,
+		FareCents:         ev.FareCents,
+		PoolDiscountCents: ev.PoolDiscountCents,
+		SurgeMultiplier:   ev.SurgeMultiplier,
 	}, nil
 }
 
+// checkTripDirection returns ErrDirectionMismatch unless the trip and the
+// request travel in the same direction.
+func checkTripDirection(requestID, tripID int64, requestDir, tripDir model.TripDirection) error {
+	if requestDir != tripDir {
+		return fmt.Errorf("booking: request %d is %s but trip %d is %s: %w",
+			requestID, requestDir, tripID, tripDir, ErrDirectionMismatch)
+	}
+	return nil
+}
+
 // ─── Helper: Create a new trip for unmatched requests ───────
 
-// CreateTrip inserts a new trip and returns its ID.
+// ErrNoCabNearby is returned by FindAndCreateTrip when no available cab
+// within the radius fits the rider (or every one is being claimed by a
+// concurrent call).
+var ErrNoCabNearby = errors.New("no available cab nearby")
+
+// FindAndCreateTrip claims the closest available cab within radiusMeters
+// that fits the rider (the FindAvailableCabNear filters) and creates a
+// planned trip on it, in one transaction, returning the trip ID and cab.
 // Used when the matching service found no existing trip to join.
-func (r *BookingRepository) CreateTrip(
+//
+// Concurrency: the cab is locked FOR UPDATE SKIP LOCKED and set 'en_route'
+// before commit, so two concurrent calls never put trips on the same cab:
+// one skips the cab the other holds, and once committed the cab is no
+// longer 'available' to anyone. DeleteEmptyTrip hands the cab back if the
+// booking the trip was made for fails.
+func (r *BookingRepository) FindAndCreateTrip(
 	ctx context.Context,
-	cabID int64,
+	location model.Location,
+	radiusMeters int,
+	minSeatsNeeded int,
+	minLuggageNeeded int,
+	requiresAccessible bool,
 	direction model.TripDirection,
-) (int64, error) {
-
-	// Use a transaction with cab locking to prevent double-assignment.
-	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+) (int64, *model.Cab, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
-		return 0, fmt.Errorf("create trip: begin tx: %w", err)
+		return 0, nil, fmt.Errorf("create trip: begin tx: %w", err)
 	}
 	defer tx.Rollback(ctx)
 
-	// Lock the cab.
-	var cabStatus model.CabStatus
-	err = tx.QueryRow(ctx, `
-		SELECT status FROM cabs WHERE id = $1 FOR UPDATE
-	`, cabID).Scan(&cabStatus)
+	tripID, cab, err := findAndCreateTrip(ctx, tx, location, radiusMeters, minSeatsNeeded, minLuggageNeeded, requiresAccessible, direction, r.CabStaleAfter)
 	if err != nil {
-		return 0, fmt.Errorf("create trip: lock cab %d: %w", cabID, err)
+		return 0, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, fmt.Errorf("create trip: commit: %w", err)
 	}
+	return tripID, cab, nil
+}
 
-	if cabStatus != model.CabAvailable {
-		return 0, fmt.Errorf("create trip: cab %d is '%s', not available", cabID, cabStatus)
+// findAndCreateTrip is FindAndCreateTrip inside tx, skipping cabs not seen
+// within staleAfter (≤ 0 = no limit).
+func findAndCreateTrip(
+	ctx context.Context,
+	tx pgx.Tx,
+	location model.Location,
+	radiusMeters int,
+	minSeatsNeeded int,
+	minLuggageNeeded int,
+	requiresAccessible bool,
+	direction model.TripDirection,
+	staleAfter time.Duration,
+) (int64, *model.Cab, error) {
+	// ── Step 1: Find and LOCK the cab ───────────────────
+	cab, err := queryAvailableCabNear(ctx, tx, location, radiusMeters, minSeatsNeeded, minLuggageNeeded, requiresAccessible, staleAfter, "FOR UPDATE SKIP LOCKED")
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil, fmt.Errorf("create trip: %w", ErrNoCabNearby)
+	}
+	if err != nil {
+		return 0, nil, fmt.Errorf("create trip: %w", err)
 	}
 
-	// Insert the trip.
+	// ── Step 2: Insert the trip, take the cab ───────────
 	var tripID int64
 	err = tx.QueryRow(ctx, `
 		INSERT INTO trips (cab_id, direction, total_fare_cents, passenger_count, status)
 		VALUES ($1, $2, 0, 0, 'planned')
 		RETURNING id
-	`, cabID, direction).Scan(&tripID)
+	`, cab.ID, direction).Scan(&tripID)
+	if err != nil {
+		return 0, nil, fmt.Errorf("create trip: insert: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE cabs SET status = 'en_route' WHERE id = $1`, cab.ID); err != nil {
+		return 0, nil, fmt.Errorf("create trip: claim cab %d: %w", cab.ID, err)
+	}
+	cab.Status = model.CabEnRoute
+	return tripID, cab, nil
+}
+
+// DeleteEmptyTrip removes a planned trip that no rider was ever booked
+// onto, undoing FindAndCreateTrip when the booking it was made for fails,
+// and sets its cab back to 'available'. It reports whether the trip was
+// deleted; a trip that has since gained a rider (or was started or
+// cancelled) is left alone.
+func (r *BookingRepository) DeleteEmptyTrip(ctx context.Context, tripID int64) (bool, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
 	if err != nil {
-		return 0, fmt.Errorf("create trip: insert: %w", err)
+		return false, fmt.Errorf("delete empty trip %d: begin tx: %w", tripID, err)
 	}
+	defer tx.Rollback(ctx)
 
+	deleted, err := deleteEmptyTrip(ctx, tx, tripID)
+	if err != nil || !deleted {
+		return false, err
+	}
 	if err := tx.Commit(ctx); err != nil {
-		return 0, fmt.Errorf("create trip: commit: %w", err)
+		return false, fmt.Errorf("delete empty trip %d: commit: %w", tripID, err)
+	}
+	return true, nil
+}
+
+// deleteEmptyTrip is DeleteEmptyTrip inside tx. Locks the cab before the
+// trip, the order BookRide and CancelTrip use. The cab is only freed if
+// it has no other live trip.
+func deleteEmptyTrip(ctx context.Context, tx pgx.Tx, tripID int64) (bool, error) {
+	var cabID int64
+	err := tx.QueryRow(ctx, `SELECT cab_id FROM trips WHERE id = $1`, tripID).Scan(&cabID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("delete empty trip %d: lookup: %w", tripID, err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT id FROM cabs WHERE id = $1 FOR UPDATE`, cabID); err != nil {
+		return false, fmt.Errorf("delete empty trip %d: lock cab %d: %w", tripID, cabID, err)
 	}
 
-	return tripID, nil
+	var id int64
+	err = tx.QueryRow(ctx, `
+		DELETE FROM trips t
+		WHERE t.id = $1 AND t.status = 'planned' AND t.passenger_count = 0
+		  AND NOT EXISTS (SELECT 1 FROM ride_requests r WHERE r.trip_id = t.id)
+		RETURNING t.id
+	`, tripID).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("delete empty trip %d: %w", tripID, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE cabs c SET status = 'available'
+		WHERE c.id = $1 AND c.status = 'en_route'
+		  AND NOT EXISTS (
+		      SELECT 1 FROM trips t
+		      WHERE t.cab_id = c.id AND t.status IN ('planned', 'in_progress')
+		  )
+	`, cabID)
+	if err != nil {
+		return false, fmt.Errorf("delete empty trip %d: free cab %d: %w", tripID, cabID, err)
+	}
+	return true, nil
 }
 
 // ─── Helper: Find an available cab near a location ──────────
 
 // FindAvailableCabNear returns the closest available cab within radiusMeters
-// that has at least minSeatsNeeded and minLuggageNeeded capacity.
+// that has at least minSeatsNeeded and minLuggageNeeded capacity and, if
+// requiresAccessible, is accessible. Cabs not seen within CabStaleAfter
+// are skipped.
 // Used when creating a new trip — ensures the cab can fit the requesting passenger.
 // Uses GIST index on cabs(current_location) for spatial lookup.
 func (r *BookingRepository) FindAvailableCabNear(
@@ -259,17 +566,57 @@ func (r *BookingRepository) FindAvailableCabNear(
 	radiusMeters int,
 	minSeatsNeeded int,
 	minLuggageNeeded int,
+	requiresAccessible bool,
+) (*model.Cab, error) {
+	return findAvailableCabNear(ctx, r.pool, location, radiusMeters, minSeatsNeeded, minLuggageNeeded, requiresAccessible, r.CabStaleAfter)
+}
+
+// findAvailableCabNear is FindAvailableCabNear on db, skipping cabs not
+// seen within staleAfter (≤ 0 = no limit).
+func findAvailableCabNear(
+	ctx context.Context,
+	db rowQuerier,
+	location model.Location,
+	radiusMeters int,
+	minSeatsNeeded int,
+	minLuggageNeeded int,
+	requiresAccessible bool,
+	staleAfter time.Duration,
+) (*model.Cab, error) {
+	cab, err := queryAvailableCabNear(ctx, db, location, radiusMeters, minSeatsNeeded, minLuggageNeeded, requiresAccessible, staleAfter, "")
+	if err != nil {
+		return nil, fmt.Errorf("find available cab: %w", err)
+	}
+	return cab, nil
+}
+
+// queryAvailableCabNear runs the closest-available-cab query with
+// lockClause (e.g. "FOR UPDATE SKIP LOCKED", or "" for none) appended.
+func queryAvailableCabNear(
+	ctx context.Context,
+	db rowQuerier,
+	location model.Location,
+	radiusMeters int,
+	minSeatsNeeded int,
+	minLuggageNeeded int,
+	requiresAccessible bool,
+	staleAfter time.Duration,
+	lockClause string,
 ) (*model.Cab, error) {
 
 	query := `
-		SELECT id, driver_id, license_plate, seat_capacity, luggage_capacity,
+		SELECT id, driver_id, license_plate, seat_capacity, luggage_capacity, flex_capacity, accessible,
 		       ST_Y(current_location) AS lat, ST_X(current_location) AS lon,
 		       status
 		FROM cabs
 		WHERE status = 'available'
+		  AND deleted_at IS NULL
 		  AND current_location IS NOT NULL
 		  AND seat_capacity >= $4
 		  AND luggage_capacity >= $5
+		  AND (flex_capacity IS NULL OR flex_capacity >= $4 + $5)
+		  AND (NOT $6::boolean OR accessible)
+		  AND ` + fmt.Sprintf(cabSeenWithin, "$7") + `
 		  AND ST_DWithin(
 		        current_location::geography,
 		        ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
@@ -280,19 +627,19 @@ func (r *BookingRepository) FindAvailableCabNear(
 		    ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography
 		) ASC
 		LIMIT 1
-	`
+		` + lockClause
 
 	cab := &model.Cab{}
 	var loc model.Location
 
-	err := r.pool.QueryRow(ctx, query, location.Lon, location.Lat, radiusMeters, minSeatsNeeded, minLuggageNeeded).Scan(
+	err := db.QueryRow(ctx, query, location.Lon, location.Lat, radiusMeters, minSeatsNeeded, minLuggageNeeded, requiresAccessible, staleAfter.Seconds()).Scan(
 		&cab.ID, &cab.DriverID, &cab.LicensePlate,
-		&cab.SeatCapacity, &cab.LuggageCapacity,
+		&cab.SeatCapacity, &cab.LuggageCapacity, &cab.FlexCapacity, &cab.Accessible,
 		&loc.Lat, &loc.Lon,
 		&cab.Status,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("find available cab: %w", err)
+		return nil, err
 	}
 
 	cab.CurrentLocation = &loc
@@ -303,21 +650,33 @@ func (r *BookingRepository) FindAvailableCabNear(
 
 // CancelResult contains the outcome of a successful cancellation.
 type CancelResult struct {
-	RequestID      int64   `json:"request_id"`
-	PreviousTrip   *int64  `json:"previous_trip_id,omitempty"`
-	TripCancelled  bool    `json:"trip_cancelled,omitempty"` // True if the whole trip was cancelled (last passenger).
-	CabFreed       bool    `json:"cab_freed,omitempty"`      // True if cab was set back to available.
-	OriginLat      float64 `json:"-"`                         // For surge cache invalidation (not in JSON response).
-	OriginLon      float64 `json:"-"`
+	RequestID        int64   `json:"request_id"`
+	PreviousTrip     *int64  `json:"previous_trip_id,omitempty"`
+	TripCancelled    bool    `json:"trip_cancelled,omitempty"`    // True if the whole trip was cancelled (last passenger).
+	CabFreed         bool    `json:"cab_freed,omitempty"`         // True if cab was set back to available.
+	AlreadyCancelled bool    `json:"already_cancelled,omitempty"` // Replay: the request was cancelled by an earlier call.
+	OriginLat        float64 `json:"-"`                           // For surge cache invalidation (not in JSON response).
+	OriginLon        float64 `json:"-"`
+
+	// For the surge counters (not in JSON response): the request's status
+	// and direction before the cancel, and where the freed cab is, if this
+	// cancel moved it back to 'available' and its location is known.
+	PreviousStatus model.RequestStatus `json:"-"`
+	Direction      model.TripDirection `json:"-"`
+	CabLocation    *model.Location     `json:"-"`
 }
 
 // CancelRide cancels a ride request. Uses pessimistic locking for concurrency safety.
 //
 // State transitions:
 //   - PENDING  → CANCELLED: Simple status update. No trip/cab impact.
-//   - MATCHED  → CANCELLED: Decrement trip passenger_count, clear trip_id. If trip has
-//                 0 passengers left, cancel the trip and set cab back to available.
-//   - CONFIRMED, COMPLETED, CANCELLED: Not cancellable (terminal states).
+//   - MATCHED  → CANCELLED: Decrement trip passenger_count, clear trip_id and
+//     re-plan the trip's route without the rider. If trip has 0 passengers
+//     left, cancel the trip and set cab back to available.
+//   - CANCELLED: Idempotent replay. Nothing is written; the result of the
+//     original cancellation is rebuilt from its outbox event and
+//     returned with AlreadyCancelled set.
+//   - CONFIRMED, COMPLETED, EXPIRED: Not cancellable (terminal states).
 //
 // Concurrency: Same as BookRide — SELECT ... FOR UPDATE on request and cab/trip.
 func (r *BookingRepository) CancelRide(
@@ -334,48 +693,58 @@ func (r *BookingRepository) CancelRide(
 	}
 	defer tx.Rollback(ctx)
 
+	if err := setLockTimeout(txCtx, tx); err != nil {
+		return nil, fmt.Errorf("cancel: %w", err)
+	}
+
 	// ── Step 1: LOCK the ride request ────────────────────
 	var (
-		reqStatus model.RequestStatus
-		reqTripID *int64
-		reqSeats  int
+		reqStatus  model.RequestStatus
+		reqTripID  *int64
+		reqSeats   int
 		reqLuggage int
-		originLon float64
-		originLat float64
+		originLon  float64
+		originLat  float64
+		direction  model.TripDirection
 	)
 	err = tx.QueryRow(ctx, `
 		SELECT status, trip_id, seats_needed, luggage_count,
-		       ST_X(origin) AS origin_lon, ST_Y(origin) AS origin_lat
+		       ST_X(origin) AS origin_lon, ST_Y(origin) AS origin_lat, direction::text
 		FROM ride_requests
 		WHERE id = $1
 		FOR UPDATE
-	`, requestID).Scan(&reqStatus, &reqTripID, &reqSeats, &reqLuggage, &originLon, &originLat)
+	`, requestID).Scan(&reqStatus, &reqTripID, &reqSeats, &reqLuggage, &originLon, &originLat, &direction)
 	if err != nil {
 		return nil, fmt.Errorf("cancel: lock request %d: %w", requestID, err)
 	}
 
-	// ── Step 2: Validate — only PENDING or MATCHED can be cancelled ─
+	// ── Step 2: Validate — only SCHEDULED, PENDING or MATCHED can be cancelled ─
 	switch reqStatus {
 	case model.RequestCancelled:
-		return nil, fmt.Errorf("cancel: request %d is already cancelled", requestID)
+		return priorCancelResult(txCtx, tx, requestID)
 	case model.RequestCompleted:
 		return nil, fmt.Errorf("cancel: request %d is completed, cannot cancel", requestID)
 	case model.RequestConfirmed:
 		return nil, fmt.Errorf("cancel: request %d is confirmed, cannot cancel", requestID)
-	case model.RequestPending, model.RequestMatched:
+	case model.RequestExpired:
+		return nil, fmt.Errorf("cancel: request %d is expired, cannot cancel", requestID)
+	case model.RequestScheduled, model.RequestPending, model.RequestMatched:
 		// OK to cancel
 	default:
 		return nil, fmt.Errorf("cancel: request %d has unknown status '%s'", requestID, reqStatus)
 	}
 
 	result := &CancelResult{
-		RequestID: requestID,
-		OriginLat: originLat,
-		OriginLon: originLon,
+		RequestID:      requestID,
+		OriginLat:      originLat,
+		OriginLon:      originLon,
+		PreviousStatus: reqStatus,
+		Direction:      direction,
 	}
 
-	// ── Step 3a: PENDING — simple status update ───────────
-	if reqStatus == model.RequestPending {
+	// ── Step 3a: SCHEDULED/PENDING — simple status update ─
+	// A reserved SCHEDULED request holds a trip and is released like MATCHED.
+	if reqTripID == nil && (reqStatus == model.RequestScheduled || reqStatus == model.RequestPending) {
 		_, err = tx.Exec(ctx, `
 			UPDATE ride_requests
 			SET status = 'cancelled', trip_id = NULL
@@ -384,13 +753,18 @@ func (r *BookingRepository) CancelRide(
 		if err != nil {
 			return nil, fmt.Errorf("cancel: update request %d: %w", requestID, err)
 		}
+		err = insertOutboxEvent(ctx, tx, model.EventRideCancelled, model.AggregateRideRequest, requestID,
+			map[string]any{"previous_status": reqStatus})
+		if err != nil {
+			return nil, fmt.Errorf("cancel: %w", err)
+		}
 		if err := tx.Commit(ctx); err != nil {
 			return nil, fmt.Errorf("cancel: commit: %w", err)
 		}
 		return result, nil
 	}
 
-	// ── Step 3b: MATCHED — update request, decrement trip, possibly cancel trip/cab ─
+	// ── Step 3b: MATCHED (or reserved) — update request, decrement trip, possibly cancel trip/cab ─
 	tripID := *reqTripID
 
 	// Update request: set cancelled, clear trip_id.
@@ -414,13 +788,16 @@ func (r *BookingRepository) CancelRide(
 	if err != nil {
 		return nil, fmt.Errorf("cancel: update trip %d: %w", tripID, err)
 	}
+	if err := updateTripRoute(ctx, tx, tripID, direction, r.airport); err != nil {
+		return nil, fmt.Errorf("cancel: %w", err)
+	}
 
-	// Count remaining matched passengers on this trip.
+	// Count remaining matched (or reserved) passengers on this trip.
 	var remainingPassengers int
 	err = tx.QueryRow(ctx, `
 		SELECT COUNT(*)::int
 		FROM ride_requests
-		WHERE trip_id = $1 AND status = 'matched'
+		WHERE trip_id = $1 AND status IN ('matched', 'scheduled')
 	`, tripID).Scan(&remainingPassengers)
 	if err != nil {
 		return nil, fmt.Errorf("cancel: count remaining passengers: %w", err)
@@ -443,15 +820,28 @@ func (r *BookingRepository) CancelRide(
 			return nil, fmt.Errorf("cancel: get cab for trip %d: %w", tripID, err)
 		}
 
-		_, err = tx.Exec(ctx, `
+		var cabLon, cabLat *float64
+		err = tx.QueryRow(ctx, `
 			UPDATE cabs
 			SET status = 'available'
 			WHERE id = $1 AND status = 'en_route'
-		`, cabID)
-		if err != nil {
+			RETURNING ST_X(current_location), ST_Y(current_location)
+		`, cabID).Scan(&cabLon, &cabLat)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 			return nil, fmt.Errorf("cancel: free cab %d: %w", cabID, err)
 		}
 		result.CabFreed = true
+		if cabLon != nil && cabLat != nil {
+			result.CabLocation = &model.Location{Lat: *cabLat, Lon: *cabLon}
+		}
+	}
+
+	err = insertOutboxEvent(ctx, tx, model.EventRideCancelled, model.AggregateRideRequest, requestID, map[string]any{
+		"previous_status": reqStatus, "previous_trip_id": tripID,
+		"trip_cancelled": result.TripCancelled, "cab_freed": result.CabFreed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cancel: %w", err)
 	}
 
 	if err := tx.Commit(ctx); err != nil {
@@ -460,8 +850,802 @@ func (r *BookingRepository) CancelRide(
 	return result, nil
 }
 
+// priorCancelResult rebuilds the result of the cancellation that moved
+// requestID to 'cancelled', from the latest ride_cancelled outbox event.
+// A request cancelled without an event (e.g. by hand) replays as a bare
+// result: nothing is known about its trip.
+func priorCancelResult(ctx context.Context, tx pgx.Tx, requestID int64) (*CancelResult, error) {
+	var payload []byte
+	err := tx.QueryRow(ctx, `
+		SELECT payload
+		FROM outbox
+		WHERE event_type = $1 AND aggregate_type = $2 AND aggregate_id = $3
+		ORDER BY id DESC
+		LIMIT 1
+	`, model.EventRideCancelled, model.AggregateRideRequest, requestID).Scan(&payload)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &CancelResult{RequestID: requestID, AlreadyCancelled: true}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cancel: load prior cancellation of request %d: %w", requestID, err)
+	}
+	return cancelResultFromEvent(requestID, payload)
+}
+
+// cancelResultFromEvent decodes a ride_cancelled payload into a replayed
+// CancelResult.
+func cancelResultFromEvent(requestID int64, payload []byte) (*CancelResult, error) {
+	var ev struct {
+		PreviousTripID *int64 `json:"previous_trip_id"`
+		TripCancelled  bool   `json:"trip_cancelled"`
+		CabFreed       bool   `json:"cab_freed"`
+	}
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return nil, fmt.Errorf("cancel: decode prior cancellation of request %d: %w", requestID, err)
+	}
+	return &CancelResult{
+		RequestID:        requestID,
+		PreviousTrip:     ev.PreviousTripID,
+		TripCancelled:    ev.TripCancelled,
+		CabFreed:         ev.CabFreed,
+		AlreadyCancelled: true,
+	}, nil
+}
+
+// ─── Admin: Reassign a matched request ──────────────────────
+
+var (
+	// ErrReassignNotMatched is returned when the request is not 'matched'.
+	ErrReassignNotMatched = errors.New("request is not matched to a trip")
+
+	// ErrTripNotFound is returned when the target trip does not exist.
+	ErrTripNotFound = errors.New("trip not found")
+
+	// ErrTripIncompatible is returned when the target trip cannot take the
+	// request: not 'planned', wrong direction, cab not bookable, or it is
+	// the request's current trip.
+	ErrTripIncompatible = errors.New("target trip is not compatible")
+)
+
+// ReassignResult describes a completed reassignment.
+type ReassignResult struct {
+	RequestID         int64 `json:"request_id"`
+	FromTripID        int64 `json:"from_trip_id"`
+	ToTripID          int64 `json:"to_trip_id"`
+	ToCabID           int64 `json:"to_cab_id"`
+	FromTripCancelled bool  `json:"from_trip_cancelled,omitempty"` // Source trip was left empty.
+	RemainingSeats    int   `json:"remaining_seats"`               // On the target trip, after the move.
+
+	// Surge is the supply the move changed: the target cab claimed, the
+	// source cab freed.
+	Surge []SurgeChange `json:"-"`
+}
+
+// ReassignRequest moves a MATCHED request from its current trip to
+// targetTripID in one transaction, adjusting both trips' passenger counts
+// and re-planning both routes.
+// If the source trip is left empty it is cancelled and its cab freed, as
+// in CancelRide.
+//
+// Concurrency: both cab rows are locked FOR UPDATE in ascending ID order
+// (so two opposite reassigns cannot deadlock), then the request row —
+// the same cab → request order BookRide uses. Capacity on the target is
+// checked under those locks; a full target returns an error wrapping
+// model.ErrInsufficientCapacity, or model.ErrPassengerLimit when it is at
+// the pool size ceiling.
+func (r *BookingRepository) ReassignRequest(
+	ctx context.Context,
+	requestID int64,
+	targetTripID int64,
+) (*ReassignResult, error) {
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("reassign: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setLockTimeout(ctx, tx); err != nil {
+		return nil, fmt.Errorf("reassign: %w", err)
+	}
+
+	result, err := r.reassignRequest(ctx, tx, requestID, targetTripID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("reassign: commit: %w", err)
+	}
+	return result, nil
+}
+
+// reassignRequest does ReassignRequest's work inside tx, leaving the
+// commit to the caller.
+func (r *BookingRepository) reassignRequest(
+	ctx context.Context,
+	tx pgx.Tx,
+	requestID int64,
+	targetTripID int64,
+) (*ReassignResult, error) {
+
+	// ── Step 1: Resolve both trips' cabs (unlocked read) ─
+	var sourceTripID, sourceCabID int64
+	err := tx.QueryRow(ctx, `
+		SELECT rr.trip_id, t.cab_id
+		FROM ride_requests rr
+		JOIN trips t ON t.id = rr.trip_id
+		WHERE rr.id = $1 AND rr.status = 'matched'
+	`, requestID).Scan(&sourceTripID, &sourceCabID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Distinguish "no such request" from "not matched".
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM ride_requests WHERE id = $1)`, requestID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("reassign: lookup request %d: %w", requestID, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("reassign: request %d: %w", requestID, ErrRequestNotFound)
+		}
+		return nil, fmt.Errorf("reassign: request %d: %w", requestID, ErrReassignNotMatched)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reassign: lookup request %d: %w", requestID, err)
+	}
+	if sourceTripID == targetTripID {
+		return nil, fmt.Errorf("reassign: request %d is already on trip %d: %w", requestID, targetTripID, ErrTripIncompatible)
+	}
+
+	var targetCabID int64
+	err = tx.QueryRow(ctx, `SELECT cab_id FROM trips WHERE id = $1`, targetTripID).Scan(&targetCabID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("reassign: trip %d: %w", targetTripID, ErrTripNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reassign: lookup trip %d: %w", targetTripID, err)
+	}
+
+	// ── Step 2: LOCK both cabs, lowest ID first ──────────
+	var (
+		capacity        model.CabCapacity
+		targetCabStatus model.CabStatus
+	)
+	_, err = tx.Exec(ctx, `
+		SELECT id FROM cabs WHERE id IN ($1, $2) ORDER BY id FOR UPDATE
+	`, sourceCabID, targetCabID)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: lock cabs: %w", err)
+	}
+	err = tx.QueryRow(ctx, `
+		SELECT seat_capacity, luggage_capacity, flex_capacity, accessible, status FROM cabs WHERE id = $1
+	`, targetCabID).Scan(&capacity.Seats, &capacity.Luggage, &capacity.Flex, &capacity.Accessible, &targetCabStatus)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: read cab %d: %w", targetCabID, err)
+	}
+	capacity.LuggageUsesSeats = r.LuggageUsesSeats
+
+	// ── Step 3: LOCK the request and re-validate ─────────
+	var (
+		reqStatus     model.RequestStatus
+		reqTripID     *int64
+		reqDirection  model.TripDirection
+		reqSeats      int
+		reqLuggage    int
+		reqAccessible bool
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT status, trip_id, direction, seats_needed, luggage_count, requires_accessible
+		FROM ride_requests
+		WHERE id = $1
+		FOR UPDATE
+	`, requestID).Scan(&reqStatus, &reqTripID, &reqDirection, &reqSeats, &reqLuggage, &reqAccessible)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: lock request %d: %w", requestID, err)
+	}
+	// Someone cancelled or moved it between Step 1 and the lock.
+	if reqStatus != model.RequestMatched || reqTripID == nil || *reqTripID != sourceTripID {
+		return nil, fmt.Errorf("reassign: request %d changed concurrently: %w", requestID, ErrReassignNotMatched)
+	}
+
+	var (
+		targetStatus    model.TripStatus
+		targetDirection model.TripDirection
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT status, direction FROM trips WHERE id = $1
+	`, targetTripID).Scan(&targetStatus, &targetDirection)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: read trip %d: %w", targetTripID, err)
+	}
+	if targetStatus != model.TripPlanned || targetDirection != reqDirection {
+		return nil, fmt.Errorf("reassign: trip %d is '%s' %s, request is %s: %w",
+			targetTripID, targetStatus, targetDirection, reqDirection, ErrTripIncompatible)
+	}
+	if targetCabStatus != model.CabAvailable && targetCabStatus != model.CabEnRoute {
+		return nil, fmt.Errorf("reassign: cab %d is '%s': %w", targetCabID, targetCabStatus, ErrTripIncompatible)
+	}
+	if err := capacity.CheckAccessible(reqAccessible); err != nil {
+		return nil, fmt.Errorf("reassign: cab %d: %v: %w", targetCabID, err, ErrTripIncompatible)
+	}
+
+	// ── Step 4: CHECK target capacity ────────────────────
+	usedSeats, usedLuggage, err := tripLoad(ctx, tx, targetTripID)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: query trip %d load: %w", targetTripID, err)
+	}
+	if err := capacity.Check(usedSeats, usedLuggage, reqSeats, reqLuggage); err != nil {
+		return nil, fmt.Errorf("reassign: trip %d: %w", targetTripID, err)
+	}
+	if err := model.CheckPassengerLimit(usedSeats, reqSeats, r.maxPassengers); err != nil {
+		return nil, fmt.Errorf("reassign: trip %d: %w", targetTripID, err)
+	}
+
+	// ── Step 5: Move the request, adjust both trips ──────
+	if _, err = tx.Exec(ctx, `UPDATE ride_requests SET trip_id = $2 WHERE id = $1`, requestID, targetTripID); err != nil {
+		return nil, fmt.Errorf("reassign: update request %d: %w", requestID, err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE trips
+		SET passenger_count = CASE WHEN id = $1 THEN GREATEST(0, passenger_count - $3)
+		                           ELSE passenger_count + $3 END
+		WHERE id IN ($1, $2)
+	`, sourceTripID, targetTripID, reqSeats)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: update trips: %w", err)
+	}
+	for _, id := range []int64{sourceTripID, targetTripID} {
+		if err := updateTripRoute(ctx, tx, id, reqDirection, r.airport); err != nil {
+			return nil, fmt.Errorf("reassign: %w", err)
+		}
+	}
+	claimed, err := claimCab(ctx, tx, targetCabID)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: update cab %d status: %w", targetCabID, err)
+	}
+
+	remainingSeats, _ := capacity.Remaining(usedSeats+reqSeats, usedLuggage+reqLuggage)
+	result := &ReassignResult{
+		RequestID:      requestID,
+		FromTripID:     sourceTripID,
+		ToTripID:       targetTripID,
+		ToCabID:        targetCabID,
+		RemainingSeats: remainingSeats,
+		Surge:          claimed,
+	}
+
+	// Source trip left empty → cancel it and free its cab. A reserved
+	// (scheduled) rider still holds the trip.
+	var remaining int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM ride_requests
+		WHERE trip_id = $1 AND status IN ('matched', 'confirmed', 'scheduled')
+	`, sourceTripID).Scan(&remaining)
+	if err != nil {
+		return nil, fmt.Errorf("reassign: count trip %d passengers: %w", sourceTripID, err)
+	}
+	if remaining == 0 {
+		if _, err = tx.Exec(ctx, `UPDATE trips SET status = 'cancelled' WHERE id = $1`, sourceTripID); err != nil {
+			return nil, fmt.Errorf("reassign: cancel trip %d: %w", sourceTripID, err)
+		}
+		_, freed, err := freeCab(ctx, tx, sourceCabID, model.CabEnRoute)
+		if err != nil {
+			return nil, fmt.Errorf("reassign: free cab %d: %w", sourceCabID, err)
+		}
+		result.Surge = append(result.Surge, freed...)
+		result.FromTripCancelled = true
+	}
+
+	err = insertOutboxEvent(ctx, tx, model.EventRideReassigned, model.AggregateRideRequest, requestID, map[string]any{
+		"from_trip_id": sourceTripID, "to_trip_id": targetTripID, "to_cab_id": targetCabID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reassign: %w", err)
+	}
+	return result, nil
+}
+
+// ─── Admin: Cancel a whole trip ─────────────────────────────
+
+// ErrTripNotCancellable is returned by CancelTrip for a trip that is
+// already completed or cancelled.
+var ErrTripNotCancellable = errors.New("trip is not cancellable")
+
+// TripCancelResult describes a trip cancelled by CancelTrip.
+type TripCancelResult struct {
+	TripID            int64            `json:"trip_id"`
+	CabID             int64            `json:"cab_id"`
+	CabFreed          bool             `json:"cab_freed"`
+	CancelledRequests []int64          `json:"cancelled_request_ids"`
+	Origins           []model.Location `json:"-"` // Of the cancelled requests, for surge cache invalidation.
+	Surge             []SurgeChange    `json:"-"` // The freed cab, for the surge counters.
+}
+
+// CancelTrip cancels a planned or in-progress trip outright: every matched
+// or confirmed request on it is cancelled (not returned to the pending
+// pool), the trip is cancelled and its cab set back to available, all in
+// one transaction. Each request gets a ride_cancelled event, so a later
+// CancelRide on it replays as already cancelled, and the trip a
+// trip_cancelled event.
+//
+// Returns an error wrapping ErrTripNotFound or ErrTripNotCancellable.
+//
+// Concurrency: the cab row is locked first, then the trip and its request
+// rows — the cab → request order BookRide uses.
+func (r *BookingRepository) CancelTrip(ctx context.Context, tripID int64) (*TripCancelResult, error) {
+	txCtx, cancel := context.WithTimeout(ctx, DefaultBookingTimeout)
+	defer cancel()
+
+	tx, err := r.pool.BeginTx(txCtx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("cancel trip: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setLockTimeout(txCtx, tx); err != nil {
+		return nil, fmt.Errorf("cancel trip: %w", err)
+	}
+
+	result, err := cancelTrip(txCtx, tx, tripID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("cancel trip %d: commit: %w", tripID, err)
+	}
+	return result, nil
+}
+
+// cancelTrip does CancelTrip's work inside the caller's transaction.
+func cancelTrip(ctx context.Context, tx pgx.Tx, tripID int64) (*TripCancelResult, error) {
+	// ── Step 1: LOCK the cab, then the trip ─────────────
+	result := &TripCancelResult{TripID: tripID, CancelledRequests: []int64{}}
+	err := tx.QueryRow(ctx, `SELECT cab_id FROM trips WHERE id = $1`, tripID).Scan(&result.CabID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("cancel trip %d: %w", tripID, ErrTripNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cancel trip %d: lookup: %w", tripID, err)
+	}
+	if _, err = tx.Exec(ctx, `SELECT id FROM cabs WHERE id = $1 FOR UPDATE`, result.CabID); err != nil {
+		return nil, fmt.Errorf("cancel trip %d: lock cab %d: %w", tripID, result.CabID, err)
+	}
+
+	var (
+		status    model.TripStatus
+		direction model.TripDirection
+	)
+	err = tx.QueryRow(ctx, `SELECT status, direction FROM trips WHERE id = $1 FOR UPDATE`, tripID).Scan(&status, &direction)
+	if err != nil {
+		return nil, fmt.Errorf("cancel trip %d: lock trip: %w", tripID, err)
+	}
+	if status != model.TripPlanned && status != model.TripInProgress {
+		return nil, fmt.Errorf("cancel trip %d: trip is '%s': %w", tripID, status, ErrTripNotCancellable)
+	}
+
+	// ── Step 2: Cancel every rider on the trip ──────────
+	rows, err := tx.Query(ctx, `
+		UPDATE ride_requests rr
+		SET status = 'cancelled', trip_id = NULL
+		FROM (
+			SELECT id, status FROM ride_requests
+			WHERE trip_id = $1 AND status IN ('matched', 'confirmed', 'scheduled')
+			ORDER BY id
+			FOR UPDATE
+		) prev
+		WHERE rr.id = prev.id
+		RETURNING rr.id, prev.status, ST_Y(rr.origin), ST_X(rr.origin)
+	`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("cancel trip %d: cancel requests: %w", tripID, err)
+	}
+	type cancelled struct {
+		id     int64
+		status model.RequestStatus
+	}
+	var riders []cancelled
+	for rows.Next() {
+		var (
+			c      cancelled
+			origin model.Location
+		)
+		if err := rows.Scan(&c.id, &c.status, &origin.Lat, &origin.Lon); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("cancel trip %d: scan request: %w", tripID, err)
+		}
+		riders = append(riders, c)
+		result.CancelledRequests = append(result.CancelledRequests, c.id)
+		result.Origins = append(result.Origins, origin)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cancel trip %d: cancel requests: %w", tripID, err)
+	}
+
+	// ── Step 3: Cancel the trip, free the cab ───────────
+	_, err = tx.Exec(ctx, `
+		UPDATE trips SET status = 'cancelled', passenger_count = 0 WHERE id = $1
+	`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("cancel trip %d: update trip: %w", tripID, err)
+	}
+	// No rider is left, so this clears the route; the airport is not needed.
+	if err := updateTripRoute(ctx, tx, tripID, direction, model.Location{}); err != nil {
+		return nil, fmt.Errorf("cancel trip %d: %w", tripID, err)
+	}
+	result.CabFreed, result.Surge, err = freeCab(ctx, tx, result.CabID, model.CabEnRoute, model.CabOnTrip)
+	if err != nil {
+		return nil, fmt.Errorf("cancel trip %d: free cab %d: %w", tripID, result.CabID, err)
+	}
+
+	// ── Step 4: Outbox events ───────────────────────────
+	for _, c := range riders {
+		err = insertOutboxEvent(ctx, tx, model.EventRideCancelled, model.AggregateRideRequest, c.id, map[string]any{
+			"previous_status": c.status, "previous_trip_id": tripID,
+			"trip_cancelled": true, "cab_freed": result.CabFreed, "cancelled_by": "admin",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("cancel trip %d: %w", tripID, err)
+		}
+	}
+	err = insertOutboxEvent(ctx, tx, model.EventTripCancelled, model.AggregateTrip, tripID, map[string]any{
+		"previous_status": status, "cab_id": result.CabID,
+		"cancelled_request_ids": result.CancelledRequests, "cab_freed": result.CabFreed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cancel trip %d: %w", tripID, err)
+	}
+
+	return result, nil
+}
+
+// ─── Admin: Merge two planned trips ─────────────────────────
+
+// ErrMergeDetour is returned by MergeTrips when the merged route would
+// lengthen some rider's ride by more than their tolerance_meters allows.
+var ErrMergeDetour = errors.New("merged route exceeds a rider's detour tolerance")
+
+// TripMergeResult describes a completed merge.
+type TripMergeResult struct {
+	FromTripID     int64   `json:"from_trip_id"` // Cancelled; its riders now ride on ToTripID.
+	ToTripID       int64   `json:"to_trip_id"`
+	ToCabID        int64   `json:"to_cab_id"`
+	FreedCabID     int64   `json:"freed_cab_id"`
+	CabFreed       bool    `json:"cab_freed"`
+	MovedRequests  []int64 `json:"moved_request_ids"`
+	RemainingSeats int     `json:"remaining_seats"` // On the target trip, after the merge.
+
+	// Surge is the supply the merge changed: the target cab claimed, the
+	// source cab freed.
+	Surge []SurgeChange `json:"-"`
+}
+
+// MergeTrips moves every rider of the planned trip fromTripID onto the
+// planned trip intoTripID, then cancels fromTripID and frees its cab, in
+// one transaction. Two nearly-empty trips heading the same way become one.
+//
+// The merge is refused unless the target cab takes every moved rider
+// (seats, luggage, accessibility and the pool size ceiling — an error
+// wrapping model.ErrInsufficientCapacity or model.ErrPassengerLimit) and
+// no rider on either trip would ride longer than their tolerance allows
+// on the merged route (ErrMergeDetour). Other refusals wrap
+// ErrTripNotFound or ErrTripIncompatible.
+//
+// Concurrency: both cab rows are locked FOR UPDATE in ascending ID order,
+// then both trips, then their riders — the cab → request order BookRide
+// uses, so a merge cannot deadlock with a booking or a reassign.
+func (r *BookingRepository) MergeTrips(ctx context.Context, fromTripID, intoTripID int64) (*TripMergeResult, error) {
+	txCtx, cancel := context.WithTimeout(ctx, DefaultBookingTimeout)
+	defer cancel()
+
+	tx, err := r.pool.BeginTx(txCtx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setLockTimeout(txCtx, tx); err != nil {
+		return nil, fmt.Errorf("merge trips: %w", err)
+	}
+
+	result, err := mergeTrips(txCtx, tx, fromTripID, intoTripID, r.airport, r.maxPassengers, r.LuggageUsesSeats)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("merge trip %d into %d: commit: %w", fromTripID, intoTripID, err)
+	}
+	return result, nil
+}
+
+// mergeTrips does MergeTrips' work inside the caller's transaction.
+func mergeTrips(
+	ctx context.Context,
+	tx pgx.Tx,
+	fromTripID, intoTripID int64,
+	airport model.Location,
+	maxPassengers int,
+	luggageUsesSeats bool,
+) (*TripMergeResult, error) {
+	if fromTripID == intoTripID {
+		return nil, fmt.Errorf("merge trip %d into itself: %w", fromTripID, ErrTripIncompatible)
+	}
+	result := &TripMergeResult{FromTripID: fromTripID, ToTripID: intoTripID, MovedRequests: []int64{}}
+
+	// ── Step 1: Resolve both trips' cabs (unlocked read) ─
+	for _, t := range []struct {
+		id    int64
+		cabID *int64
+	}{{fromTripID, &result.FreedCabID}, {intoTripID, &result.ToCabID}} {
+		err := tx.QueryRow(ctx, `SELECT cab_id FROM trips WHERE id = $1`, t.id).Scan(t.cabID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, fmt.Errorf("merge trips: trip %d: %w", t.id, ErrTripNotFound)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("merge trips: lookup trip %d: %w", t.id, err)
+		}
+	}
+
+	// ── Step 2: LOCK both cabs, lowest ID first ──────────
+	_, err := tx.Exec(ctx, `
+		SELECT id FROM cabs WHERE id IN ($1, $2) ORDER BY id FOR UPDATE
+	`, result.FreedCabID, result.ToCabID)
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: lock cabs: %w", err)
+	}
+	var (
+		capacity  model.CabCapacity
+		cabStatus model.CabStatus
+	)
+	err = tx.QueryRow(ctx, `
+		SELECT seat_capacity, luggage_capacity, flex_capacity, accessible, status FROM cabs WHERE id = $1
+	`, result.ToCabID).Scan(&capacity.Seats, &capacity.Luggage, &capacity.Flex, &capacity.Accessible, &cabStatus)
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: read cab %d: %w", result.ToCabID, err)
+	}
+	capacity.LuggageUsesSeats = luggageUsesSeats
+	if cabStatus != model.CabAvailable && cabStatus != model.CabEnRoute {
+		return nil, fmt.Errorf("merge trips: cab %d is '%s': %w", result.ToCabID, cabStatus, ErrTripIncompatible)
+	}
+
+	// ── Step 3: LOCK both trips and re-validate ──────────
+	rows, err := tx.Query(ctx, `
+		SELECT id, status, direction FROM trips WHERE id IN ($1, $2) ORDER BY id FOR UPDATE
+	`, fromTripID, intoTripID)
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: lock trips: %w", err)
+	}
+	directions := make(map[int64]model.TripDirection, 2)
+	for rows.Next() {
+		var (
+			id        int64
+			status    model.TripStatus
+			direction model.TripDirection
+		)
+		if err := rows.Scan(&id, &status, &direction); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("merge trips: scan trip: %w", err)
+		}
+		if status != model.TripPlanned {
+			rows.Close()
+			return nil, fmt.Errorf("merge trips: trip %d is '%s': %w", id, status, ErrTripIncompatible)
+		}
+		directions[id] = direction
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("merge trips: lock trips: %w", err)
+	}
+	direction := directions[intoTripID]
+	if directions[fromTripID] != direction {
+		return nil, fmt.Errorf("merge trips: trip %d is %s, trip %d is %s: %w",
+			fromTripID, directions[fromTripID], intoTripID, direction, ErrTripIncompatible)
+	}
+
+	// ── Step 4: LOCK both trips' riders ─────────────────
+	rows, err = tx.Query(ctx, `
+		SELECT id, trip_id, status, seats_needed, luggage_count, tolerance_meters, requires_accessible,
+		       ST_Y(origin), ST_X(origin), ST_Y(destination), ST_X(destination)
+		FROM ride_requests
+		WHERE trip_id IN ($1, $2)
+		  AND status IN ('matched', 'confirmed', 'scheduled')
+		ORDER BY created_at ASC, id ASC
+		FOR UPDATE
+	`, fromTripID, intoTripID)
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: lock riders: %w", err)
+	}
+	riders, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.RideRequest, error) {
+		var rr model.RideRequest
+		err := row.Scan(&rr.ID, &rr.TripID, &rr.Status, &rr.SeatsNeeded, &rr.LuggageCount,
+			&rr.ToleranceMeters, &rr.RequiresAccessible,
+			&rr.Origin.Lat, &rr.Origin.Lon, &rr.Destination.Lat, &rr.Destination.Lon)
+		return rr, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: lock riders: %w", err)
+	}
+
+	var into, from []model.RideRequest
+	var usedSeats, usedLuggage, movedSeats, movedLuggage int
+	for _, rr := range riders {
+		if *rr.TripID == intoTripID {
+			into = append(into, rr)
+			usedSeats += rr.SeatsNeeded
+			usedLuggage += rr.LuggageCount
+			continue
+		}
+		// Like ReassignRequest, only riders not yet picked up can move;
+		// a scheduled rider's reservation moves with the trip.
+		if rr.Status != model.RequestMatched && rr.Status != model.RequestScheduled {
+			return nil, fmt.Errorf("merge trips: request %d on trip %d is '%s': %w",
+				rr.ID, fromTripID, rr.Status, ErrTripIncompatible)
+		}
+		if err := capacity.CheckAccessible(rr.RequiresAccessible); err != nil {
+			return nil, fmt.Errorf("merge trips: cab %d: %v: %w", result.ToCabID, err, ErrTripIncompatible)
+		}
+		from = append(from, rr)
+		movedSeats += rr.SeatsNeeded
+		movedLuggage += rr.LuggageCount
+	}
+
+	// ── Step 5: CHECK capacity and detours ──────────────
+	if err := capacity.Check(usedSeats, usedLuggage, movedSeats, movedLuggage); err != nil {
+		return nil, fmt.Errorf("merge trips: trip %d: %w", intoTripID, err)
+	}
+	if err := model.CheckPassengerLimit(usedSeats, movedSeats, maxPassengers); err != nil {
+		return nil, fmt.Errorf("merge trips: trip %d: %w", intoTripID, err)
+	}
+	if err := checkMergeDetours(direction, into, from, airport); err != nil {
+		return nil, fmt.Errorf("merge trip %d into %d: %w", fromTripID, intoTripID, err)
+	}
+
+	// ── Step 6: Move the riders, cancel the empty trip ──
+	for _, rr := range from {
+		result.MovedRequests = append(result.MovedRequests, rr.ID)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE ride_requests SET trip_id = $2 WHERE id = ANY($1)
+	`, result.MovedRequests, intoTripID)
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: move requests: %w", err)
+	}
+	_, err = tx.Exec(ctx, `
+		UPDATE trips
+		SET passenger_count = CASE WHEN id = $1 THEN 0 ELSE passenger_count + $3 END,
+		    status          = CASE WHEN id = $1 THEN 'cancelled' ELSE status END
+		WHERE id IN ($1, $2)
+	`, fromTripID, intoTripID, movedSeats)
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: update trips: %w", err)
+	}
+	if result.Surge, err = claimCab(ctx, tx, result.ToCabID); err != nil {
+		return nil, fmt.Errorf("merge trips: update cab %d status: %w", result.ToCabID, err)
+	}
+	var freed []SurgeChange
+	result.CabFreed, freed, err = freeCab(ctx, tx, result.FreedCabID, model.CabEnRoute)
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: free cab %d: %w", result.FreedCabID, err)
+	}
+	result.Surge = append(result.Surge, freed...)
+	if err := updateTripRoute(ctx, tx, intoTripID, direction, airport); err != nil {
+		return nil, fmt.Errorf("merge trips: %w", err)
+	}
+	result.RemainingSeats, _ = capacity.Remaining(usedSeats+movedSeats, usedLuggage+movedLuggage)
+
+	// ── Step 7: Outbox events ───────────────────────────
+	for _, id := range result.MovedRequests {
+		err = insertOutboxEvent(ctx, tx, model.EventRideReassigned, model.AggregateRideRequest, id, map[string]any{
+			"from_trip_id": fromTripID, "to_trip_id": intoTripID, "to_cab_id": result.ToCabID, "merged": true,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("merge trips: %w", err)
+		}
+	}
+	err = insertOutboxEvent(ctx, tx, model.EventTripMerged, model.AggregateTrip, fromTripID, map[string]any{
+		"into_trip_id": intoTripID, "cab_id": result.FreedCabID,
+		"moved_request_ids": result.MovedRequests, "cab_freed": result.CabFreed,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("merge trips: %w", err)
+	}
+
+	return result, nil
+}
+
 // ─── Timeout helper ─────────────────────────────────────────
 
 // DefaultBookingTimeout is the maximum duration for a complete booking
-// transaction, including lock wait time.
+// transaction, including lock wait time, when none is configured.
 const DefaultBookingTimeout = 5 * time.Second
+
+// lockTimeoutMillis returns the lock_timeout matching ctx's deadline, in
+// milliseconds. ok is false when ctx has no deadline (leave the server
+// default in place). An already-expired deadline maps to 1ms rather than 0,
+// because 0 disables lock_timeout entirely.
+func lockTimeoutMillis(ctx context.Context) (ms int64, ok bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	ms = time.Until(deadline).Milliseconds()
+	if ms < 1 {
+		ms = 1
+	}
+	return ms, true
+}
+
+// rowQuerier is the QueryRow half of pgx.Tx and *pgxpool.Pool.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// rowsQuerier is the Query half of pgx.Tx and *pgxpool.Pool.
+type rowsQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+}
+
+// tripLoad sums the seats and luggage of the riders a trip is carrying
+// (matched or confirmed) or holding for a scheduled rider (a 'scheduled'
+// request only has a trip_id once BookRide reserved it).
+func tripLoad(ctx context.Context, q rowQuerier, tripID int64) (seats, luggage int, err error) {
+	err = q.QueryRow(ctx, `
+		SELECT COALESCE(SUM(seats_needed), 0)::int,
+		       COALESCE(SUM(luggage_count), 0)::int
+		FROM ride_requests
+		WHERE trip_id = $1
+		  AND status IN ('matched', 'confirmed', 'scheduled')
+	`, tripID).Scan(&seats, &luggage)
+	return seats, luggage, err
+}
+
+// claimCab moves cabID from 'available' to 'en_route' and returns the
+// supply it takes from the surge counters (none if the cab was not
+// available, or has no location).
+func claimCab(ctx context.Context, tx pgx.Tx, cabID int64) ([]SurgeChange, error) {
+	var lon, lat *float64
+	err := tx.QueryRow(ctx, `
+		UPDATE cabs SET status = 'en_route'
+		WHERE id = $1 AND status = 'available'
+		RETURNING ST_X(current_location), ST_Y(current_location)
+	`, cabID).Scan(&lon, &lat)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cabSupplyChange(lon, lat, -1), nil
+}
+
+// freeCab moves cabID back to 'available' if it is in one of from, and
+// reports whether it did, with the supply it adds to the surge counters.
+func freeCab(ctx context.Context, tx pgx.Tx, cabID int64, from ...model.CabStatus) (bool, []SurgeChange, error) {
+	statuses := make([]string, len(from))
+	for i, st := range from {
+		statuses[i] = string(st)
+	}
+	var lon, lat *float64
+	err := tx.QueryRow(ctx, `
+		UPDATE cabs SET status = 'available'
+		WHERE id = $1 AND status::text = ANY($2)
+		RETURNING ST_X(current_location), ST_Y(current_location)
+	`, cabID, statuses).Scan(&lon, &lat)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	return true, cabSupplyChange(lon, lat, 1), nil
+}
+
+// setLockTimeout scopes lock_timeout to the current transaction so the lock
+// wait can never outlive the context deadline.
+func setLockTimeout(ctx context.Context, tx pgx.Tx) error {
+	ms, ok := lockTimeoutMillis(ctx)
+	if !ok {
+		return nil
+	}
+	if _, err := tx.Exec(ctx, `SELECT set_config('lock_timeout', $1, true)`, fmt.Sprintf("%dms", ms)); err != nil {
+		return fmt.Errorf("set lock_timeout: %w", err)
+	}
+	return nil
+}
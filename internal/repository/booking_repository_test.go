@@ -0,0 +1,107 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestLockTimeoutMillis_MatchesDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	ms, ok := lockTimeoutMillis(ctx)
+	if !ok {
+		t.Fatal("lockTimeoutMillis: want ok for context with deadline")
+	}
+	// Never longer than the deadline, and not meaningfully shorter.
+	if ms > 2000 || ms < 1900 {
+		t.Errorf("lock_timeout = %dms, want ~2000ms", ms)
+	}
+}
+
+func TestLockTimeoutMillis_NoDeadline(t *testing.T) {
+	if _, ok := lockTimeoutMillis(context.Background()); ok {
+		t.Error("lockTimeoutMillis: want !ok without deadline")
+	}
+}
+
+func TestLockTimeoutMillis_ExpiredDeadlineIsNotZero(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	// 0 would disable lock_timeout, so an expired deadline must stay positive.
+	if ms, _ := lockTimeoutMillis(ctx); ms != 1 {
+		t.Errorf("lock_timeout = %dms, want 1ms", ms)
+	}
+}
+
+func TestCheckTripDirection_ToAirportRequestOnFromAirportTrip(t *testing.T) {
+	err := checkTripDirection(42, 7, model.DirectionToAirport, model.DirectionFromAirport)
+	if !errors.Is(err, ErrDirectionMismatch) {
+		t.Fatalf("err = %v, want ErrDirectionMismatch", err)
+	}
+	want := "booking: request 42 is to_airport but trip 7 is from_airport: trip direction does not match request"
+	if err.Error() != want {
+		t.Errorf("err = %q, want %q", err, want)
+	}
+}
+
+func TestCheckTripDirection_SameDirection(t *testing.T) {
+	if err := checkTripDirection(42, 7, model.DirectionToAirport, model.DirectionToAirport); err != nil {
+		t.Errorf("err = %v, want nil", err)
+	}
+}
+
+func TestCancelResultFromEvent_MatchedCancellation(t *testing.T) {
+	payload := []byte(`{"previous_status":"matched","previous_trip_id":7,"trip_cancelled":true,"cab_freed":true}`)
+
+	res, err := cancelResultFromEvent(42, payload)
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if !res.AlreadyCancelled || res.RequestID != 42 {
+		t.Errorf("result = %+v, want replay of request 42", res)
+	}
+	if res.PreviousTrip == nil || *res.PreviousTrip != 7 || !res.TripCancelled || !res.CabFreed {
+		t.Errorf("result = %+v, want prior trip 7 cancelled with cab freed", res)
+	}
+}
+
+func TestCancelResultFromEvent_PendingCancellation(t *testing.T) {
+	res, err := cancelResultFromEvent(42, []byte(`{"previous_status":"pending"}`))
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if res.PreviousTrip != nil || res.TripCancelled || res.CabFreed {
+		t.Errorf("result = %+v, want no trip impact", res)
+	}
+}
+
+func TestBookingResultFromEvent_ReplaysBooking(t *testing.T) {
+	payload := []byte(`{"trip_id":7,"cab_id":3,"seats":2,"luggage":1,"remaining_seats":1,"remaining_luggage":2,` +
+		`"pooled":true,"fare_cents":4950,"pool_discount_cents":550,"surge_multiplier":1.2,"reserved":false}`)
+
+	res, err := bookingResultFromEvent(42, 7, payload)
+	if err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	want := BookingResult{
+		TripID: 7, CabID: 3, RequestID: 42, SeatsBooked: 2, RemainingSeats: 1, LuggageBooked: 1, RemainingLuggage: 2,
+		Pooled: true, FareCents: 4950, PoolDiscountCents: 550, SurgeMultiplier: 1.2,
+	}
+	if !reflect.DeepEqual(*res, want) {
+		t.Errorf("result = %+v, want %+v", *res, want)
+	}
+}
+
+func TestBookingResultFromEvent_MovedSinceBooking(t *testing.T) {
+	_, err := bookingResultFromEvent(42, 8, []byte(`{"trip_id":7,"cab_id":3}`))
+	if !errors.Is(err, ErrRequestNotPending) {
+		t.Errorf("err = %v, want ErrRequestNotPending for a request now on trip 8", err)
+	}
+}
@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/cache"
+)
+
+// CabCapacityCache keeps each cab's static capacity (seats, luggage, flex
+// units, accessibility) in Redis, so candidate search need not join cabs.
+// Entries are dropped by Invalidate whenever a cab changes and otherwise
+// expire after the TTL.
+//
+// Each entry is tagged with the cab's generation, a Redis counter that
+// Invalidate bumps. A fill reads the generation before loading from
+// Postgres, and an entry whose generation is no longer current is a miss,
+// so a fill that read the cab just before a change committed cannot
+// leave the old capacity cached once Invalidate has run.
+type CabCapacityCache struct {
+	redis capacityCache
+	keys  cache.Namespace
+	ttl   time.Duration
+}
+
+// capacityCache is the part of *redis.Client the capacity cache uses.
+type capacityCache interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+}
+
+// cachedCapacity is a cache entry: a capacity and the generation of the
+// cab it was loaded at.
+type cachedCapacity struct {
+	model.CabCapacity
+	Gen int64 `json:"gen"`
+}
+
+// capacityLoader reads the capacity of the given cabs from Postgres. Cabs
+// that no longer exist (or are soft-deleted) are left out of the map.
+type capacityLoader func(ctx context.Context, cabIDs []int64) (map[int64]model.CabCapacity, error)
+
+// NewCabCapacityCache creates a cache writing keys under the namespace that
+// live for ttl. redis is normally a *redis.Client behind a cache.Guarded
+// circuit breaker; any cache error falls back to Postgres.
+func NewCabCapacityCache(redis capacityCache, keys cache.Namespace, ttl time.Duration) *CabCapacityCache {
+	return &CabCapacityCache{redis: redis, keys: keys, ttl: ttl}
+}
+
+func (c *CabCapacityCache) key(cabID int64) string {
+	return c.keys.Key("cab", "capacity", strconv.FormatInt(cabID, 10))
+}
+
+// genKey holds cabID's generation. It has no TTL: one small counter per
+// cab that has ever changed.
+func (c *CabCapacityCache) genKey(cabID int64) string {
+	return c.keys.Key("cab", "capacity_gen", strconv.FormatInt(cabID, 10))
+}
+
+// gen returns cabID's current generation, 0 if it never changed. It
+// reports false if Redis could not say.
+func (c *CabCapacityCache) gen(ctx context.Context, cabID int64) (int64, bool) {
+	g, err := c.redis.Get(ctx, c.genKey(cabID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, true
+	}
+	return g, err == nil
+}
+
+// get returns the cached capacity of cabID. Misses, Redis errors,
+// undecodable entries and entries from an older generation all report
+// false.
+func (c *CabCapacityCache) get(ctx context.Context, cabID int64) (model.CabCapacity, bool) {
+	raw, err := c.redis.Get(ctx, c.key(cabID)).Bytes()
+	if err != nil {
+		return model.CabCapacity{}, false
+	}
+	var entry cachedCapacity
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return model.CabCapacity{}, false
+	}
+	if current, ok := c.gen(ctx, cabID); !ok || current != entry.Gen {
+		return model.CabCapacity{}, false
+	}
+	return entry.CabCapacity, true
+}
+
+// put caches cc for cabID as loaded at generation gen (fire-and-forget).
+func (c *CabCapacityCache) put(ctx context.Context, cabID int64, cc model.CabCapacity, gen int64) {
+	raw, err := json.Marshal(cachedCapacity{CabCapacity: cc, Gen: gen})
+	if err != nil {
+		return
+	}
+	_ = c.redis.Set(ctx, c.key(cabID), string(raw), c.ttl).Err()
+}
+
+// Invalidate bumps cabID's generation and drops its cached capacity. Call
+// it after any change to the cab row's capacity, accessibility or
+// deleted_at has committed.
+func (c *CabCapacityCache) Invalidate(ctx context.Context, cabID int64) {
+	_ = c.redis.IncrBy(ctx, c.genKey(cabID), 1).Err()
+	_ = c.redis.Del(ctx, c.key(cabID)).Err()
+}
+
+// fill sets each candidate's cab capacity from the cache, loading every
+// miss with a single call to load and caching the result. Candidates whose
+// cab load does not return are dropped.
+func (c *CabCapacityCache) fill(ctx context.Context, candidates []model.CandidateTrip, load capacityLoader) ([]model.CandidateTrip, error) {
+	caps := make(map[int64]model.CabCapacity, len(candidates))
+	seen := make(map[int64]bool, len(candidates))
+	var missing []int64
+	for _, ct := range candidates {
+		if seen[ct.CabID] {
+			continue
+		}
+		seen[ct.CabID] = true
+		if cc, ok := c.get(ctx, ct.CabID); ok {
+			caps[ct.CabID] = cc
+		} else {
+			missing = append(missing, ct.CabID)
+		}
+	}
+
+	if len(missing) > 0 {
+		// Generations are read before the load: a change committing after
+		// this point bumps them, and what is cached below goes stale.
+		gens := make(map[int64]int64, len(missing))
+		for _, id := range missing {
+			if g, ok := c.gen(ctx, id); ok {
+				gens[id] = g
+			}
+		}
+		loaded, err := load(ctx, missing)
+		if err != nil {
+			return nil, fmt.Errorf("load cab capacity: %w", err)
+		}
+		for id, cc := range loaded {
+			caps[id] = cc
+			if g, ok := gens[id]; ok {
+				c.put(ctx, id, cc, g)
+			}
+		}
+	}
+
+	filled := candidates[:0]
+	for _, ct := range candidates {
+		cc, ok := caps[ct.CabID]
+		if !ok {
+			continue
+		}
+		ct.SeatCapacity, ct.LuggageCapacity, ct.FlexCapacity, ct.Accessible = cc.Seats, cc.Luggage, cc.Flex, cc.Accessible
+		filled = append(filled, ct)
+	}
+	return filled, nil
+}
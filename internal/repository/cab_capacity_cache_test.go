@@ -0,0 +1,148 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// countingLoader serves capacities from a map and records each call.
+type countingLoader struct {
+	caps  map[int64]model.CabCapacity
+	calls [][]int64
+}
+
+func (l *countingLoader) load(_ context.Context, cabIDs []int64) (map[int64]model.CabCapacity, error) {
+	l.calls = append(l.calls, cabIDs)
+	out := make(map[int64]model.CabCapacity)
+	for _, id := range cabIDs {
+		if cc, ok := l.caps[id]; ok {
+			out[id] = cc
+		}
+	}
+	return out, nil
+}
+
+func TestCabCapacityCache_HitSkipsLoad(t *testing.T) {
+	flex := 5
+	rdb := newFakeRedis()
+	c := NewCabCapacityCache(rdb, "staging", time.Hour)
+	l := &countingLoader{caps: map[int64]model.CabCapacity{
+		7: {Seats: 4, Luggage: 3, Flex: &flex, Accessible: true},
+	}}
+	candidates := func() []model.CandidateTrip {
+		return []model.CandidateTrip{{TripID: 1, CabID: 7, CurrentLoad: 2}}
+	}
+
+	got, err := c.fill(context.Background(), candidates(), l.load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.calls) != 1 || !reflect.DeepEqual(l.calls[0], []int64{7}) {
+		t.Fatalf("load calls = %v, want one for cab 7", l.calls)
+	}
+	if rdb.ttls["staging:cab:capacity:7"] != time.Hour {
+		t.Errorf("cached keys = %v, want staging:cab:capacity:7 for 1h", rdb.ttls)
+	}
+
+	got, err = c.fill(context.Background(), candidates(), l.load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.calls) != 1 {
+		t.Errorf("load calls = %d after a cache hit, want 1", len(l.calls))
+	}
+	ct := got[0]
+	if ct.SeatCapacity != 4 || ct.LuggageCapacity != 3 || ct.FlexCapacity == nil || *ct.FlexCapacity != 5 || !ct.Accessible {
+		t.Errorf("candidate = %+v, want seats 4, luggage 3, flex 5, accessible", ct)
+	}
+	if ct.CurrentLoad != 2 {
+		t.Errorf("current load = %d, want 2 (untouched)", ct.CurrentLoad)
+	}
+}
+
+func TestCabCapacityCache_InvalidateReloads(t *testing.T) {
+	rdb := newFakeRedis()
+	c := NewCabCapacityCache(rdb, "", time.Hour)
+	l := &countingLoader{caps: map[int64]model.CabCapacity{7: {Seats: 4, Luggage: 3}}}
+	ctx := context.Background()
+
+	if _, err := c.fill(ctx, []model.CandidateTrip{{CabID: 7}}, l.load); err != nil {
+		t.Fatal(err)
+	}
+	l.caps[7] = model.CabCapacity{Seats: 6, Luggage: 4}
+	c.Invalidate(ctx, 7)
+
+	got, err := c.fill(ctx, []model.CandidateTrip{{CabID: 7}}, l.load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.calls) != 2 {
+		t.Errorf("load calls = %d, want 2 (miss, then miss after invalidation)", len(l.calls))
+	}
+	if got[0].SeatCapacity != 6 || got[0].LuggageCapacity != 4 {
+		t.Errorf("candidate = %+v, want the updated 6 seats / 4 luggage", got[0])
+	}
+}
+
+func TestCabCapacityCache_MissesLoadedTogether(t *testing.T) {
+	c := NewCabCapacityCache(newFakeRedis(), "", time.Hour)
+	// Cab 9 is gone (soft-deleted), so its candidate is dropped.
+	l := &countingLoader{caps: map[int64]model.CabCapacity{7: {Seats: 4}, 8: {Seats: 6}}}
+
+	got, err := c.fill(context.Background(), []model.CandidateTrip{
+		{TripID: 1, CabID: 7}, {TripID: 2, CabID: 9}, {TripID: 3, CabID: 8}, {TripID: 4, CabID: 7},
+	}, l.load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.calls) != 1 || !reflect.DeepEqual(l.calls[0], []int64{7, 9, 8}) {
+		t.Errorf("load calls = %v, want one call for cabs 7, 9, 8", l.calls)
+	}
+	var trips []int64
+	for _, ct := range got {
+		trips = append(trips, ct.TripID)
+	}
+	if !reflect.DeepEqual(trips, []int64{1, 3, 4}) {
+		t.Errorf("trips = %v, want [1 3 4] in order", trips)
+	}
+}
+
+func TestCabCapacityCache_LoadError(t *testing.T) {
+	c := NewCabCapacityCache(newFakeRedis(), "", time.Hour)
+	boom := errors.New("db down")
+	_, err := c.fill(context.Background(), []model.CandidateTrip{{CabID: 7}},
+		func(context.Context, []int64) (map[int64]model.CabCapacity, error) { return nil, boom })
+	if !errors.Is(err, boom) {
+		t.Errorf("err = %v, want wrapped load error", err)
+	}
+}
+
+func TestCabCapacityCache_FillRacingAChangeDoesNotStick(t *testing.T) {
+	rdb := newFakeRedis()
+	c := NewCabCapacityCache(rdb, "", time.Hour)
+	ctx := context.Background()
+
+	// The fill reads cab 7 just before a capacity change commits; the
+	// change's Invalidate runs before the fill caches what it read.
+	stale := func(ctx context.Context, cabIDs []int64) (map[int64]model.CabCapacity, error) {
+		c.Invalidate(ctx, 7)
+		return map[int64]model.CabCapacity{7: {Seats: 4}}, nil
+	}
+	if _, err := c.fill(ctx, []model.CandidateTrip{{CabID: 7}}, stale); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &countingLoader{caps: map[int64]model.CabCapacity{7: {Seats: 6}}}
+	got, err := c.fill(ctx, []model.CandidateTrip{{CabID: 7}}, l.load)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.calls) != 1 || got[0].SeatCapacity != 6 {
+		t.Errorf("load calls = %v, candidate = %+v; want the stale entry missed and 6 seats reloaded", l.calls, got[0])
+	}
+}
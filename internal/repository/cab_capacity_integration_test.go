@@ -0,0 +1,97 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// capacityTrip seeds a planned trip with two riders carrying one bag each
+// on a 4-seat, 3-bag cab, and returns the trip and cab ids.
+func capacityTrip(t *testing.T, ctx context.Context, tx pgx.Tx, plate string) (tripID, cabID int64) {
+	t.Helper()
+	tripID = seedCandidateTrip(t, ctx, tx, plate,
+		model.Location{Lat: 10.0000, Lon: 70.0000},
+		model.Location{Lat: 10.0010, Lon: 70.0010})
+	if _, err := tx.Exec(ctx, `UPDATE ride_requests SET luggage_count = 1 WHERE trip_id = $1`, tripID); err != nil {
+		t.Fatalf("seed bags: %v", err)
+	}
+	if err := tx.QueryRow(ctx, `SELECT cab_id FROM trips WHERE id = $1`, tripID).Scan(&cabID); err != nil {
+		t.Fatalf("read cab: %v", err)
+	}
+	return tripID, cabID
+}
+
+func TestUpdateCapacity_RefusesBelowTripLoad(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID, cabID := capacityTrip(t, ctx, tx, "CAPACITY-LOW")
+
+	one := 1
+	_, err := updateCapacity(ctx, tx, cabID, &one, nil, false)
+	var below *CapacityBelowLoadError
+	if !errors.As(err, &below) || !errors.Is(err, ErrCapacityBelowLoad) {
+		t.Fatalf("err = %v, want *CapacityBelowLoadError", err)
+	}
+	if below.TripID != tripID || below.Seats != 2 || below.Luggage != 2 {
+		t.Errorf("load = %+v, want trip %d with 2 seats and 2 bags", below, tripID)
+	}
+
+	var seats int
+	if err := tx.QueryRow(ctx, `SELECT seat_capacity FROM cabs WHERE id = $1`, cabID).Scan(&seats); err != nil {
+		t.Fatalf("read cab: %v", err)
+	}
+	if seats != 4 {
+		t.Errorf("seat_capacity = %d after refusal, want 4", seats)
+	}
+}
+
+func TestUpdateCapacity_LowersToWhatTripsFit(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	_, cabID := capacityTrip(t, ctx, tx, "CAPACITY-FIT")
+
+	two := 2
+	cab, err := updateCapacity(ctx, tx, cabID, &two, &two, false)
+	if err != nil {
+		t.Fatalf("updateCapacity: %v", err)
+	}
+	if cab.SeatCapacity != 2 || cab.LuggageCapacity != 2 {
+		t.Errorf("cab capacity %d seats, %d bags; want 2 and 2", cab.SeatCapacity, cab.LuggageCapacity)
+	}
+}
+
+func TestUpdateCapacity_IgnoresFinishedTrips(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID, cabID := capacityTrip(t, ctx, tx, "CAPACITY-DONE")
+	if _, err := tx.Exec(ctx, `UPDATE trips SET status = 'completed' WHERE id = $1`, tripID); err != nil {
+		t.Fatalf("complete trip: %v", err)
+	}
+
+	one := 1
+	if _, err := updateCapacity(ctx, tx, cabID, &one, nil, false); err != nil {
+		t.Errorf("updateCapacity with only a completed trip: %v", err)
+	}
+}
+
+func TestUpdateCapacity_LuggageUsesSeats(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	_, cabID := capacityTrip(t, ctx, tx, "CAPACITY-BAGS")
+
+	// One bag space for two bags: the second only fits on a free seat.
+	one := 1
+	if _, err := updateCapacity(ctx, tx, cabID, nil, &one, false); !errors.Is(err, ErrCapacityBelowLoad) {
+		t.Fatalf("without bags on seats: err = %v, want ErrCapacityBelowLoad", err)
+	}
+	cab, err := updateCapacity(ctx, tx, cabID, nil, &one, true)
+	if err != nil {
+		t.Fatalf("with bags on seats: %v", err)
+	}
+	if cab.LuggageCapacity != 1 {
+		t.Errorf("luggage_capacity = %d, want 1", cab.LuggageCapacity)
+	}
+}
@@ -0,0 +1,400 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+var (
+	// ErrCabNotFound is returned when the cab does not exist or is already deleted.
+	ErrCabNotFound = errors.New("cab not found")
+
+	// ErrCabHasActiveTrips is returned when deleting a cab that still has a
+	// planned or in-progress trip.
+	ErrCabHasActiveTrips = errors.New("cab has active trips")
+
+	// ErrCapacityBelowLoad is returned when a capacity change would leave
+	// a planned or in-progress trip on the cab carrying more than fits.
+	ErrCapacityBelowLoad = errors.New("capacity below an active trip's load")
+)
+
+// CapacityBelowLoadError names the trip a capacity change would overfill
+// and what it is carrying. It wraps ErrCapacityBelowLoad.
+type CapacityBelowLoadError struct {
+	TripID  int64
+	Seats   int // Seats booked on the trip.
+	Luggage int // Bags booked on the trip.
+}
+
+func (e *CapacityBelowLoadError) Error() string {
+	return fmt.Sprintf("trip %d carries %d seats and %d bags: %v", e.TripID, e.Seats, e.Luggage, ErrCapacityBelowLoad)
+}
+
+func (e *CapacityBelowLoadError) Unwrap() error { return ErrCapacityBelowLoad }
+
+// cabSeenWithin matches a cab whose last location push (last_seen_at) is
+// at most %[1]s seconds old; a non-positive window matches every cab.
+// Dispatch and supply counts use it to treat silent cabs as offline.
+const cabSeenWithin = `(%[1]s::float8 <= 0 OR last_seen_at >= NOW() - make_interval(secs => %[1]s))`
+
+// CabRepository handles cab lifecycle operations.
+type CabRepository struct {
+	pool *pgxpool.Pool
+
+	// Capacities, when set, has its entry for a cab dropped whenever the
+	// cab row changes.
+	Capacities *CabCapacityCache
+
+	// LuggageUsesSeats lets UpdateCapacity count overflow bags against
+	// free seats, as BookingRepository.LuggageUsesSeats does when booking.
+	LuggageUsesSeats bool
+}
+
+// NewCabRepository creates a new cab repository.
+func NewCabRepository(pool *pgxpool.Pool) *CabRepository {
+	return &CabRepository{pool: pool}
+}
+
+// DeleteCab soft-deletes a cab: it stamps deleted_at and sets the cab
+// 'offline', so it drops out of FindAvailableCabNear, supply counts and
+// candidate-trip search. Trips keep their cab_id for history.
+//
+// Refuses with ErrCabHasActiveTrips while any trip on the cab is 'planned'
+// or 'in_progress' — deleting it would strand those passengers.
+//
+// Returns the supply the cab takes from the surge counters: one available
+// cab at its last location, or nothing if it was busy or never reported.
+//
+// Concurrency: the cab row is locked FOR UPDATE, the same lock BookRide and
+// FindAndCreateTrip take, so no trip can be created on the cab mid-delete.
+func (r *CabRepository) DeleteCab(ctx context.Context, cabID int64) ([]SurgeChange, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("delete cab: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	surge, err := deleteCab(ctx, tx, cabID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("delete cab %d: commit: %w", cabID, err)
+	}
+	if r.Capacities != nil {
+		r.Capacities.Invalidate(ctx, cabID)
+	}
+	return surge, nil
+}
+
+// deleteCab is DeleteCab inside an open transaction.
+func deleteCab(ctx context.Context, tx pgx.Tx, cabID int64) ([]SurgeChange, error) {
+	// ── Step 1: LOCK the cab ─────────────────────────────
+	var (
+		status   model.CabStatus
+		lon, lat *float64
+	)
+	err := tx.QueryRow(ctx, `
+		SELECT status, ST_X(current_location), ST_Y(current_location)
+		FROM cabs WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, cabID).Scan(&status, &lon, &lat)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("delete cab %d: %w", cabID, ErrCabNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("delete cab %d: lock: %w", cabID, err)
+	}
+
+	// ── Step 2: Refuse if any trip is still live ─────────
+	var activeTrips int
+	err = tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM trips
+		WHERE cab_id = $1 AND status IN ('planned', 'in_progress')
+	`, cabID).Scan(&activeTrips)
+	if err != nil {
+		return nil, fmt.Errorf("delete cab %d: count trips: %w", cabID, err)
+	}
+	if activeTrips > 0 {
+		return nil, fmt.Errorf("delete cab %d: %d non-terminal trips: %w", cabID, activeTrips, ErrCabHasActiveTrips)
+	}
+
+	// ── Step 3: Soft delete ──────────────────────────────
+	_, err = tx.Exec(ctx, `
+		UPDATE cabs
+		SET deleted_at = NOW(), status = 'offline', updated_at = NOW()
+		WHERE id = $1
+	`, cabID)
+	if err != nil {
+		return nil, fmt.Errorf("delete cab %d: update: %w", cabID, err)
+	}
+
+	if status != model.CabAvailable {
+		return nil, nil
+	}
+	return cabSupplyChange(lon, lat, -1), nil
+}
+
+// UpdateCapacity changes a cab's seat and/or luggage capacity (nil leaves
+// that one as it is) and returns the updated cab.
+//
+// Refuses with *CapacityBelowLoadError if any planned or in-progress trip
+// on the cab is carrying more than the new capacity holds (flex units
+// included). Raising capacity, or lowering it to what trips still fit,
+// always succeeds.
+//
+// Concurrency: the cab row is locked FOR UPDATE, the lock BookRide takes
+// before adding a rider, so no booking can raise a trip's load between
+// the check and the update.
+func (r *CabRepository) UpdateCapacity(ctx context.Context, cabID int64, seats, luggage *int) (*model.Cab, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("update cab capacity: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	cab, err := updateCapacity(ctx, tx, cabID, seats, luggage, r.LuggageUsesSeats)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("update cab %d capacity: commit: %w", cabID, err)
+	}
+	if r.Capacities != nil {
+		r.Capacities.Invalidate(ctx, cabID)
+	}
+	return cab, nil
+}
+
+// updateCapacity is UpdateCapacity inside an open transaction.
+func updateCapacity(ctx context.Context, tx pgx.Tx, cabID int64, seats, luggage *int, luggageUsesSeats bool) (*model.Cab, error) {
+	// ── Step 1: LOCK the cab ─────────────────────────────
+	var next model.CabCapacity
+	err := tx.QueryRow(ctx, `
+		SELECT seat_capacity, luggage_capacity, flex_capacity
+		FROM cabs WHERE id = $1 AND deleted_at IS NULL FOR UPDATE
+	`, cabID).Scan(&next.Seats, &next.Luggage, &next.Flex)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("update cab %d capacity: %w", cabID, ErrCabNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update cab %d capacity: lock: %w", cabID, err)
+	}
+	next.LuggageUsesSeats = luggageUsesSeats
+	if seats != nil {
+		next.Seats = *seats
+	}
+	if luggage != nil {
+		next.Luggage = *luggage
+	}
+
+	// ── Step 2: Every live trip must still fit ───────────
+	rows, err := tx.Query(ctx, `
+		SELECT t.id,
+		       COALESCE(SUM(rr.seats_needed), 0)::int,
+		       COALESCE(SUM(rr.luggage_count), 0)::int
+		FROM trips t
+		LEFT JOIN ride_requests rr
+		       ON rr.trip_id = t.id AND rr.status IN ('matched', 'confirmed', 'scheduled')
+		WHERE t.cab_id = $1 AND t.status IN ('planned', 'in_progress')
+		GROUP BY t.id
+		ORDER BY t.id
+	`, cabID)
+	if err != nil {
+		return nil, fmt.Errorf("update cab %d capacity: trip loads: %w", cabID, err)
+	}
+	var overfull *CapacityBelowLoadError
+	for rows.Next() {
+		var load CapacityBelowLoadError
+		if err := rows.Scan(&load.TripID, &load.Seats, &load.Luggage); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("update cab %d capacity: scan trip load: %w", cabID, err)
+		}
+		if overfull == nil && next.Check(load.Seats, load.Luggage, 0, 0) != nil {
+			overfull = &load
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("update cab %d capacity: trip loads: %w", cabID, err)
+	}
+	if overfull != nil {
+		return nil, fmt.Errorf("update cab %d capacity: %w", cabID, overfull)
+	}
+
+	// ── Step 3: Update ───────────────────────────────────
+	var cab model.Cab
+	err = tx.QueryRow(ctx, `
+		UPDATE cabs
+		SET seat_capacity = $2, luggage_capacity = $3, updated_at = NOW()
+		WHERE id = $1
+		RETURNING id, driver_id, license_plate, seat_capacity, luggage_capacity,
+		          flex_capacity, accessible, status, created_at, updated_at
+	`, cabID, next.Seats, next.Luggage).Scan(
+		&cab.ID, &cab.DriverID, &cab.LicensePlate, &cab.SeatCapacity, &cab.LuggageCapacity,
+		&cab.FlexCapacity, &cab.Accessible, &cab.Status, &cab.CreatedAt, &cab.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("update cab %d capacity: update: %w", cabID, err)
+	}
+	return &cab, nil
+}
+
+// ─── Bulk Location Ingestion ────────────────────────────────
+
+// Rejection reasons for LocationBatchResult.Rejected.
+const (
+	LocationRejectStale      = "stale"       // ts not newer than the stored fix.
+	LocationRejectUnknownCab = "unknown_cab" // No such cab, or soft-deleted.
+	LocationRejectFuture     = "future"      // ts more than LocationClockSkew ahead of the server.
+)
+
+// LocationClockSkew is how far ahead of the server clock a report's ts may
+// be. A report from further ahead is rejected: stored, it would make every
+// genuine report until then look stale.
+const LocationClockSkew = 2 * time.Minute
+
+// LocationRejection is one report UpdateLocations did not apply.
+type LocationRejection struct {
+	CabID  int64  `json:"cab_id"`
+	Reason string `json:"reason"`
+}
+
+// LocationBatchResult summarises a bulk location update.
+type LocationBatchResult struct {
+	Updated  int                 `json:"updated"`
+	Rejected []LocationRejection `json:"rejected"`
+
+	// Surge moves available cabs that drove into another surge cell
+	// between cells of the supply counters.
+	Surge []SurgeChange `json:"-"`
+}
+
+// UpdateLocations applies a batch of telematics reports in one round trip
+// (pgx.Batch). A report only overwrites current_location when its TS is
+// strictly newer than location_updated_at, so late or duplicate packets
+// never move a cab backwards. Every report for a known cab stamps
+// last_seen_at, stale or not: the cab is still talking to us.
+//
+// Reports for the same cab within the batch are collapsed to the newest
+// first (see newestPerCab); the older ones, and reports dated more than
+// LocationClockSkew ahead, are rejected without touching the database.
+// The rest are applied in cab ID order, so two overlapping batches lock
+// their cab rows in the same order and cannot deadlock.
+//
+// Only cell changes of available cabs are reported to the surge counters;
+// a cab going quiet (MATCH_CAB_STALE_AFTER) or coming back is time-driven
+// and left to SurgeCounters.Reconcile.
+func (r *CabRepository) UpdateLocations(ctx context.Context, updates []model.CabLocationUpdate) (*LocationBatchResult, error) {
+	latest, result := newestPerCab(updates, time.Now().Add(LocationClockSkew))
+	if len(latest) == 0 {
+		return result, nil
+	}
+
+	// For each report: did the cab exist, and did the newer-than check pass?
+	const query = `
+		WITH target AS (
+			SELECT id, (location_updated_at IS NULL OR location_updated_at < $4) AS newer,
+			       status = 'available' AS available,
+			       ST_X(current_location) AS old_lon, ST_Y(current_location) AS old_lat
+			FROM cabs
+			WHERE id = $1 AND deleted_at IS NULL
+		), upd AS (
+			UPDATE cabs c
+			SET current_location    = CASE WHEN t.newer THEN ST_SetSRID(ST_MakePoint($2, $3), 4326) ELSE c.current_location END,
+			    location_updated_at = CASE WHEN t.newer THEN $4 ELSE c.location_updated_at END,
+			    updated_at          = CASE WHEN t.newer THEN NOW() ELSE c.updated_at END,
+			    last_seen_at        = NOW()
+			FROM target t
+			WHERE c.id = t.id
+			RETURNING t.newer
+		)
+		SELECT EXISTS (SELECT 1 FROM target), COALESCE((SELECT newer FROM upd), false),
+		       COALESCE((SELECT available FROM target), false),
+		       (SELECT old_lon FROM target), (SELECT old_lat FROM target)
+	`
+
+	batch := &pgx.Batch{}
+	for _, u := range latest {
+		batch.Queue(query, u.CabID, u.Lon, u.Lat, u.TS) // ST_MakePoint takes (lon, lat)
+	}
+
+	br := r.pool.SendBatch(ctx, batch)
+	defer br.Close()
+
+	for _, u := range latest {
+		var (
+			found, updated, available bool
+			oldLon, oldLat            *float64
+		)
+		if err := br.QueryRow().Scan(&found, &updated, &available, &oldLon, &oldLat); err != nil {
+			return nil, fmt.Errorf("update locations: cab %d: %w", u.CabID, err)
+		}
+		switch {
+		case updated:
+			result.Updated++
+			if available {
+				result.Surge = append(result.Surge, cabMoveChange(oldLon, oldLat, model.Location{Lat: u.Lat, Lon: u.Lon})...)
+			}
+		case !found:
+			result.Rejected = append(result.Rejected, LocationRejection{CabID: u.CabID, Reason: LocationRejectUnknownCab})
+		default:
+			result.Rejected = append(result.Rejected, LocationRejection{CabID: u.CabID, Reason: LocationRejectStale})
+		}
+	}
+	return result, nil
+}
+
+// newestPerCab keeps the newest report per cab, sorted by cab ID, and
+// rejects every other report for that cab as stale. Reports dated after
+// notAfter are rejected as future before that.
+func newestPerCab(updates []model.CabLocationUpdate, notAfter time.Time) ([]model.CabLocationUpdate, *LocationBatchResult) {
+	result := &LocationBatchResult{Rejected: []LocationRejection{}}
+	index := make(map[int64]int, len(updates))
+	latest := make([]model.CabLocationUpdate, 0, len(updates))
+
+	for _, u := range updates {
+		if u.TS.After(notAfter) {
+			result.Rejected = append(result.Rejected, LocationRejection{CabID: u.CabID, Reason: LocationRejectFuture})
+			continue
+		}
+		i, seen := index[u.CabID]
+		if !seen {
+			index[u.CabID] = len(latest)
+			latest = append(latest, u)
+			continue
+		}
+		if u.TS.After(latest[i].TS) {
+			latest[i] = u
+		}
+		result.Rejected = append(result.Rejected, LocationRejection{CabID: u.CabID, Reason: LocationRejectStale})
+	}
+	slices.SortFunc(latest, func(a, b model.CabLocationUpdate) int { return cmp.Compare(a.CabID, b.CabID) })
+	return latest, result
+}
+
+// MaxSeatCapacity returns the most seats any non-deleted cab can offer one
+// trip (on a flex cab, at most its flex units), or 0 if there are no cabs.
+// Offline cabs count: they can come back online.
+func (r *CabRepository) MaxSeatCapacity(ctx context.Context) (int, error) {
+	var seats int
+	err := r.pool.QueryRow(ctx, `
+		SELECT COALESCE(MAX(LEAST(seat_capacity, COALESCE(flex_capacity, seat_capacity))), 0)
+		FROM cabs
+		WHERE deleted_at IS NULL
+	`).Scan(&seats)
+	if err != nil {
+		return 0, fmt.Errorf("max seat capacity: %w", err)
+	}
+	return seats, nil
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestNewestPerCab_CollapsesDuplicates(t *testing.T) {
+	t0 := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	updates := []model.CabLocationUpdate{
+		{CabID: 2, Lat: 28.60, Lon: 77.00, TS: t0},
+		{CabID: 1, Lat: 28.70, Lon: 77.10, TS: t0},
+		{CabID: 1, Lat: 28.71, Lon: 77.11, TS: t0.Add(5 * time.Second)}, // newer → wins
+		{CabID: 1, Lat: 28.69, Lon: 77.09, TS: t0.Add(-time.Minute)},    // older → stale
+	}
+
+	latest, result := newestPerCab(updates, t0.Add(time.Hour))
+
+	if len(latest) != 2 {
+		t.Fatalf("kept %d reports, want 2", len(latest))
+	}
+	if latest[0].CabID != 1 || !latest[0].TS.Equal(t0.Add(5*time.Second)) || latest[0].Lat != 28.71 {
+		t.Errorf("cab 1 kept %+v, want the newest report", latest[0])
+	}
+	if latest[1].CabID != 2 {
+		t.Errorf("second kept report is cab %d, want 2 (cab ID order)", latest[1].CabID)
+	}
+	if len(result.Rejected) != 2 {
+		t.Fatalf("rejected %d, want 2", len(result.Rejected))
+	}
+	for _, rej := range result.Rejected {
+		if rej.CabID != 1 || rej.Reason != LocationRejectStale {
+			t.Errorf("rejection %+v, want cab 1 stale", rej)
+		}
+	}
+}
+
+func TestNewestPerCab_RejectsFutureReports(t *testing.T) {
+	now := time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC)
+	updates := []model.CabLocationUpdate{
+		{CabID: 1, Lat: 28.70, Lon: 77.10, TS: now.Add(-time.Second)},
+		{CabID: 1, Lat: 28.71, Lon: 77.11, TS: now.Add(24 * time.Hour)}, // clock far ahead
+		{CabID: 2, Lat: 28.60, Lon: 77.00, TS: now.Add(LocationClockSkew)},
+	}
+
+	latest, result := newestPerCab(updates, now.Add(LocationClockSkew))
+
+	if len(latest) != 2 || latest[0].Lat != 28.70 || latest[1].CabID != 2 {
+		t.Errorf("kept %+v, want cab 1's current report and cab 2's report within the skew", latest)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0] != (LocationRejection{CabID: 1, Reason: LocationRejectFuture}) {
+		t.Errorf("rejected %+v, want only cab 1's future report", result.Rejected)
+	}
+}
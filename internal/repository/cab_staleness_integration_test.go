@@ -0,0 +1,88 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// staleProbe is far from any seed data.
+var staleProbe = model.Location{Lat: -20.0, Lon: 30.0}
+
+// seedAvailableCab creates an available cab metersNorth of staleProbe whose
+// last location push was silentFor ago, and returns its id.
+func seedAvailableCab(t *testing.T, ctx context.Context, tx pgx.Tx, plate string, metersNorth float64, silentFor time.Duration) int64 {
+	t.Helper()
+	var userID, cabID int64
+	err := tx.QueryRow(ctx, `
+		INSERT INTO users (name, email, phone, role)
+		VALUES ($1, $1 || '@test.invalid', '+82' || lpad((abs(hashtext($1)) % 1000000000)::text, 10, '0'), 'driver')
+		RETURNING id
+	`, plate).Scan(&userID)
+	if err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	lat := staleProbe.Lat + metersNorth/111_320
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO cabs (driver_id, license_plate, status, current_location, last_seen_at)
+		VALUES ($1, $2, 'available', ST_SetSRID(ST_MakePoint($3, $4), 4326), NOW() - make_interval(secs => $5))
+		RETURNING id
+	`, userID, plate, staleProbe.Lon, lat, silentFor.Seconds()).Scan(&cabID); err != nil {
+		t.Fatalf("seed cab: %v", err)
+	}
+	return cabID
+}
+
+func TestFindAvailableCabNear_SkipsStaleCab(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	stale := seedAvailableCab(t, ctx, tx, "STALE-CAB", 100, 10*time.Minute)
+	fresh := seedAvailableCab(t, ctx, tx, "FRESH-CAB", 500, 30*time.Second)
+
+	cab, err := findAvailableCabNear(ctx, tx, staleProbe, 2000, 1, 0, false, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("find: %v", err)
+	}
+	if cab.ID != fresh {
+		t.Errorf("got cab %d, want fresh cab %d over the closer stale cab %d", cab.ID, fresh, stale)
+	}
+
+	// With no window the closer, silent cab still wins.
+	cab, err = findAvailableCabNear(ctx, tx, staleProbe, 2000, 1, 0, false, 0)
+	if err != nil {
+		t.Fatalf("find without window: %v", err)
+	}
+	if cab.ID != stale {
+		t.Errorf("got cab %d without a window, want closest cab %d", cab.ID, stale)
+	}
+}
+
+func TestFindAvailableCabNear_OnlyStaleCabsFindsNone(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	seedAvailableCab(t, ctx, tx, "SILENT-CAB", 100, time.Hour)
+
+	if cab, err := findAvailableCabNear(ctx, tx, staleProbe, 2000, 1, 0, false, 5*time.Minute); err == nil {
+		t.Errorf("got cab %d, want none (only cab is stale)", cab.ID)
+	}
+}
+
+func TestQueryDemandSupply_StaleCabsNotSupply(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	seedAvailableCab(t, ctx, tx, "STALE-SUPPLY", 100, 10*time.Minute)
+	seedAvailableCab(t, ctx, tx, "FRESH-SUPPLY", 200, 30*time.Second)
+
+	for staleAfter, want := range map[time.Duration]int{0: 2, 5 * time.Minute: 1} {
+		ds, err := queryDemandSupplyFromDB(ctx, tx, staleProbe, 1000, "", staleAfter)
+		if err != nil {
+			t.Fatalf("stale after %v: %v", staleAfter, err)
+		}
+		if ds.Supply != want {
+			t.Errorf("stale after %v: supply = %d, want %d", staleAfter, ds.Supply, want)
+		}
+	}
+}
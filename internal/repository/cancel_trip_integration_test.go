@@ -0,0 +1,96 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestCancelTrip_CancelsEveryRiderAndFreesCab(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	origins := []model.Location{
+		{Lat: 10.0050, Lon: 70.0000},
+		{Lat: 10.0060, Lon: 70.0010},
+		{Lat: 10.2000, Lon: 70.2000},
+	}
+	tripID := seedCandidateTrip(t, ctx, tx, "CANCEL-1", origins...)
+
+	result, err := cancelTrip(ctx, tx, tripID)
+	if err != nil {
+		t.Fatalf("cancelTrip: %v", err)
+	}
+	if len(result.CancelledRequests) != 3 || len(result.Origins) != 3 || !result.CabFreed {
+		t.Errorf("result = %+v, want 3 cancelled requests with origins and the cab freed", result)
+	}
+
+	var active, cancelled int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE trip_id = $1)::int,
+		       COUNT(*) FILTER (WHERE id = ANY($2) AND status = 'cancelled' AND trip_id IS NULL)::int
+		FROM ride_requests
+	`, tripID, result.CancelledRequests).Scan(&active, &cancelled); err != nil {
+		t.Fatalf("read requests: %v", err)
+	}
+	if active != 0 || cancelled != 3 {
+		t.Errorf("requests: %d still on trip, %d cancelled; want 0 and 3", active, cancelled)
+	}
+
+	var tripStatus model.TripStatus
+	var cabStatus model.CabStatus
+	var passengers int
+	if err := tx.QueryRow(ctx, `
+		SELECT t.status, t.passenger_count, c.status FROM trips t JOIN cabs c ON c.id = t.cab_id WHERE t.id = $1
+	`, tripID).Scan(&tripStatus, &passengers, &cabStatus); err != nil {
+		t.Fatalf("read trip: %v", err)
+	}
+	if tripStatus != model.TripCancelled || passengers != 0 || cabStatus != model.CabAvailable {
+		t.Errorf("trip %s with %d passengers, cab %s; want cancelled, 0, available", tripStatus, passengers, cabStatus)
+	}
+
+	var events int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM outbox
+		WHERE (event_type = $1 AND aggregate_id = ANY($2)) OR (event_type = $3 AND aggregate_id = $4)
+	`, model.EventRideCancelled, result.CancelledRequests, model.EventTripCancelled, tripID).Scan(&events); err != nil {
+		t.Fatalf("read outbox: %v", err)
+	}
+	if events != 4 {
+		t.Errorf("outbox events = %d, want 3 ride_cancelled + 1 trip_cancelled", events)
+	}
+
+	// A second cancel finds the trip already cancelled.
+	if _, err := cancelTrip(ctx, tx, tripID); !errors.Is(err, ErrTripNotCancellable) {
+		t.Errorf("second cancel: err = %v, want ErrTripNotCancellable", err)
+	}
+}
+
+func TestCancelTrip_NotFound(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	if _, err := cancelTrip(ctx, tx, -1); !errors.Is(err, ErrTripNotFound) {
+		t.Errorf("err = %v, want ErrTripNotFound", err)
+	}
+}
+
+func TestCancelTrip_ClearsRoute(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "CANCEL-ROUTE", model.Location{Lat: 10.0050, Lon: 70.0000})
+	if err := updateTripRoute(ctx, tx, tripID, model.DirectionToAirport, mergeAirport); err != nil {
+		t.Fatalf("updateTripRoute: %v", err)
+	}
+
+	if _, err := cancelTrip(ctx, tx, tripID); err != nil {
+		t.Fatalf("cancelTrip: %v", err)
+	}
+	var routed bool
+	if err := tx.QueryRow(ctx, `
+		SELECT route_path IS NOT NULL OR total_distance_m IS NOT NULL FROM trips WHERE id = $1
+	`, tripID).Scan(&routed); err != nil {
+		t.Fatalf("read route: %v", err)
+	}
+	if routed {
+		t.Error("cancelled trip kept its route")
+	}
+}
@@ -0,0 +1,73 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestCancelUserPending_CancelsOnlyPending(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	// The seeded user already has a matched request on the trip.
+	tripID := seedCandidateTrip(t, ctx, tx, "CANCEL-ALL", model.Location{Lat: 10.0050, Lon: 70.0000})
+	var userID int64
+	if err := tx.QueryRow(ctx, `SELECT user_id FROM ride_requests WHERE trip_id = $1`, tripID).Scan(&userID); err != nil {
+		t.Fatalf("find user: %v", err)
+	}
+	var pending []int64
+	for _, status := range []string{"pending", "pending", "pending", "scheduled"} {
+		var id int64
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO ride_requests (user_id, origin, destination, direction, status, scheduled_at)
+			SELECT user_id, origin, destination, direction, $2,
+			       CASE WHEN $2 = 'scheduled' THEN NOW() + INTERVAL '1 day' END
+			FROM ride_requests WHERE trip_id = $1
+			RETURNING id
+		`, tripID, status).Scan(&id); err != nil {
+			t.Fatalf("seed %s request: %v", status, err)
+		}
+		if status == "pending" {
+			pending = append(pending, id)
+		}
+	}
+
+	ids, surge, err := cancelUserPending(ctx, tx, userID)
+	if err != nil {
+		t.Fatalf("cancelUserPending: %v", err)
+	}
+	if len(ids) != 3 || ids[0] != pending[0] || ids[2] != pending[2] {
+		t.Errorf("cancelled %v, want the pending requests %v", ids, pending)
+	}
+	if len(surge) != 3 || surge[0].Demand != -1 {
+		t.Errorf("surge changes %+v, want one demand -1 per cancelled request", surge)
+	}
+
+	var cancelled, matched, scheduled, events int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE status = 'cancelled')::int,
+		       COUNT(*) FILTER (WHERE status = 'matched')::int,
+		       COUNT(*) FILTER (WHERE status = 'scheduled')::int,
+		       (SELECT COUNT(*)::int FROM outbox WHERE event_type = $2 AND aggregate_id = ANY($3))
+		FROM ride_requests WHERE user_id = $1
+	`, userID, model.EventRideCancelled, ids).Scan(&cancelled, &matched, &scheduled, &events); err != nil {
+		t.Fatalf("read requests: %v", err)
+	}
+	if cancelled != 3 || matched != 1 || scheduled != 1 || events != 3 {
+		t.Errorf("%d cancelled, %d matched, %d scheduled, %d events; want 3, 1, 1, 3", cancelled, matched, scheduled, events)
+	}
+
+	// Nothing left to cancel: a repeat is an empty success.
+	if ids, _, err := cancelUserPending(ctx, tx, userID); err != nil || len(ids) != 0 {
+		t.Errorf("repeat: cancelled %v (err %v), want none", ids, err)
+	}
+}
+
+func TestCancelUserPending_UnknownUser(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	if _, _, err := cancelUserPending(ctx, tx, -1); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("err = %v, want ErrUserNotFound", err)
+	}
+}
@@ -0,0 +1,58 @@
+//go:build integration
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestCreateRideRequest_ClientRequestIDReturnsExisting(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "CLIENT-ID-1", soloOrigin)
+	var userID int64
+	if err := tx.QueryRow(ctx, `SELECT user_id FROM ride_requests WHERE trip_id = $1 LIMIT 1`, tripID).Scan(&userID); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	clientID := "0b3c4a52-7c1e-4d55-9a0e-3f7d1c2b9e41"
+	newReq := func() *model.RideRequest {
+		return &model.RideRequest{
+			UserID: userID, Origin: soloOrigin, Destination: centroidProbe,
+			Direction: model.DirectionToAirport, SeatsNeeded: 1, ToleranceMeters: 2000,
+			ClientRequestID: &clientID,
+		}
+	}
+
+	first, created, err := createRideRequest(ctx, tx, newReq())
+	if err != nil || !created {
+		t.Fatalf("first create: created %v, err %v; want created", created, err)
+	}
+	retry, created, err := createRideRequest(ctx, tx, newReq())
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if created || retry.ID != first.ID || retry.ClientRequestID == nil || *retry.ClientRequestID != clientID {
+		t.Errorf("retry = %+v (created %v), want request %d returned as existing", retry, created, first.ID)
+	}
+
+	var events int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*) FROM outbox WHERE aggregate_type = 'ride_request' AND aggregate_id = $1
+	`, first.ID).Scan(&events); err != nil {
+		t.Fatalf("count events: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("%d ride_created events, want 1", events)
+	}
+
+	// Without a client ID nothing is deduplicated.
+	plain := newReq()
+	plain.ClientRequestID = nil
+	for i := 0; i < 2; i++ {
+		if _, created, err := createRideRequest(ctx, tx, plain); err != nil || !created {
+			t.Fatalf("untagged create %d: created %v, err %v", i, created, err)
+		}
+	}
+}
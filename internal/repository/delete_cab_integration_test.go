@@ -0,0 +1,58 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeleteCab_RefusesWhileTripActive(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "DELETE-BUSY", soloOrigin)
+	var cabID int64
+	if err := tx.QueryRow(ctx, `SELECT cab_id FROM trips WHERE id=$1`, tripID).Scan(&cabID); err != nil {
+		t.Fatalf("read cab: %v", err)
+	}
+
+	if _, err := deleteCab(ctx, tx, cabID); !errors.Is(err, ErrCabHasActiveTrips) {
+		t.Fatalf("deleteCab with planned trip = %v, want ErrCabHasActiveTrips", err)
+	}
+	var deleted bool
+	if err := tx.QueryRow(ctx, `SELECT deleted_at IS NOT NULL FROM cabs WHERE id=$1`, cabID).Scan(&deleted); err != nil {
+		t.Fatalf("read cab: %v", err)
+	}
+	if deleted {
+		t.Error("refused delete still set deleted_at")
+	}
+}
+
+func TestDeleteCab_SoftDeletesIdleCab(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	cabID := seedAvailableCab(t, ctx, tx, "DELETE-IDLE", 0, 0)
+
+	changes, err := deleteCab(ctx, tx, cabID)
+	if err != nil {
+		t.Fatalf("deleteCab: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Supply != -1 {
+		t.Errorf("surge changes = %+v, want one supply -1", changes)
+	}
+
+	var (
+		deleted bool
+		status  string
+	)
+	if err := tx.QueryRow(ctx, `
+		SELECT deleted_at IS NOT NULL, status FROM cabs WHERE id=$1
+	`, cabID).Scan(&deleted, &status); err != nil {
+		t.Fatalf("read cab: %v", err)
+	}
+	if !deleted || status != "offline" {
+		t.Errorf("cab deleted=%v status=%q, want soft-deleted and offline", deleted, status)
+	}
+
+	if _, err := deleteCab(ctx, tx, cabID); !errors.Is(err, ErrCabNotFound) {
+		t.Errorf("second deleteCab = %v, want ErrCabNotFound", err)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build integration
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestDeleteEmptyTrip_RemovesOnlyRiderlessPlannedTrips(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	empty := seedCandidateTrip(t, ctx, tx, "EMPTY-TRIP")
+	booked := seedCandidateTrip(t, ctx, tx, "BOOKED-TRIP", model.Location{Lat: 10.0000, Lon: 70.0000})
+
+	deleted, err := deleteEmptyTrip(ctx, tx, empty)
+	if err != nil || !deleted {
+		t.Fatalf("delete empty trip: deleted %v, err %v; want deleted", deleted, err)
+	}
+	deleted, err = deleteEmptyTrip(ctx, tx, booked)
+	if err != nil || deleted {
+		t.Fatalf("delete booked trip: deleted %v, err %v; want kept", deleted, err)
+	}
+
+	var left int
+	if err := tx.QueryRow(ctx, `SELECT COUNT(*)::int FROM trips WHERE id = ANY($1)`,
+		[]int64{empty, booked}).Scan(&left); err != nil {
+		t.Fatalf("count trips: %v", err)
+	}
+	if left != 1 {
+		t.Errorf("%d of the two trips left, want only the booked one", left)
+	}
+
+	var emptyCab, bookedCab model.CabStatus
+	if err := tx.QueryRow(ctx, `
+		SELECT (SELECT status FROM cabs WHERE license_plate = 'EMPTY-TRIP'),
+		       (SELECT status FROM cabs WHERE license_plate = 'BOOKED-TRIP')
+	`).Scan(&emptyCab, &bookedCab); err != nil {
+		t.Fatalf("read cabs: %v", err)
+	}
+	if emptyCab != model.CabAvailable || bookedCab != model.CabEnRoute {
+		t.Errorf("cabs = %s (empty trip's), %s (booked trip's); want available, en_route", emptyCab, bookedCab)
+	}
+
+	// Deleting again is a no-op, not an error.
+	if deleted, err := deleteEmptyTrip(ctx, tx, empty); err != nil || deleted {
+		t.Errorf("second delete: deleted %v, err %v; want no-op", deleted, err)
+	}
+}
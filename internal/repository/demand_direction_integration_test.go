@@ -0,0 +1,43 @@
+//go:build integration
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestQueryDemandSupply_ScopedByDirection(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	probe := model.Location{Lat: -10.0, Lon: 20.0} // Far from any seed data.
+
+	var userID int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO users (name, email, phone, role)
+		VALUES ('demand-dir', 'demand-dir@test.invalid', '+810000000177', 'passenger')
+		RETURNING id
+	`).Scan(&userID); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	for dir, n := range map[model.TripDirection]int{model.DirectionToAirport: 3, model.DirectionFromAirport: 1} {
+		for i := 0; i < n; i++ {
+			if _, err := tx.Exec(ctx, `
+				INSERT INTO ride_requests (user_id, origin, destination, direction, status)
+				VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326), ST_SetSRID(ST_MakePoint(77.0889, 28.5562), 4326), $4, 'pending')
+			`, userID, probe.Lon, probe.Lat, dir); err != nil {
+				t.Fatalf("seed request: %v", err)
+			}
+		}
+	}
+
+	for dir, want := range map[model.TripDirection]int{"": 4, model.DirectionToAirport: 3, model.DirectionFromAirport: 1} {
+		ds, err := queryDemandSupplyFromDB(ctx, tx, probe, 1000, dir, 0)
+		if err != nil {
+			t.Fatalf("direction %q: %v", dir, err)
+		}
+		if ds.Demand != want {
+			t.Errorf("direction %q: demand = %d, want %d", dir, ds.Demand, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+//go:build integration
+
+package repository
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestExpireStalePending_ExpiresOnlyStalePending(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "EXPIRE-1", soloOrigin)
+	var userID int64
+	if err := tx.QueryRow(ctx, `SELECT user_id FROM ride_requests WHERE trip_id = $1 LIMIT 1`, tripID).Scan(&userID); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+
+	// seed creates a request in status, created age ago, scheduled for
+	// scheduledIn from now when non-nil.
+	seed := func(status model.RequestStatus, age time.Duration, scheduledIn *time.Duration) int64 {
+		t.Helper()
+		req, _, err := createRideRequest(ctx, tx, &model.RideRequest{
+			UserID: userID, Origin: soloOrigin, Destination: centroidProbe,
+			Direction: model.DirectionToAirport, SeatsNeeded: 1, ToleranceMeters: 2000,
+		})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		var scheduledSecs *float64
+		if scheduledIn != nil {
+			secs := scheduledIn.Seconds()
+			scheduledSecs = &secs
+		}
+		if _, err := tx.Exec(ctx, `
+			UPDATE ride_requests
+			SET status = $2,
+			    created_at = NOW() - make_interval(secs => $3),
+			    scheduled_at = NOW() + make_interval(secs => $4)
+			WHERE id = $1
+		`, req.ID, status, age.Seconds(), scheduledSecs); err != nil {
+			t.Fatalf("age request %d: %v", req.ID, err)
+		}
+		return req.ID
+	}
+	ttl := 10 * time.Minute
+	recentlyActivated := -time.Minute
+
+	stale := seed(model.RequestPending, time.Hour, nil)
+	fresh := seed(model.RequestPending, time.Minute, nil)
+	activated := seed(model.RequestPending, 2*time.Hour, &recentlyActivated)
+	matched := seed(model.RequestMatched, time.Hour, nil)
+
+	ids, surge, err := expireStalePending(ctx, tx, ttl)
+	if err != nil {
+		t.Fatalf("expireStalePending: %v", err)
+	}
+	if len(surge) != len(ids) {
+		t.Errorf("%d surge changes for %d expired requests, want one each", len(surge), len(ids))
+	}
+	for id, want := range map[int64]bool{stale: true, fresh: false, activated: false, matched: false} {
+		if got := slices.Contains(ids, id); got != want {
+			t.Errorf("request %d expired = %v, want %v", id, got, want)
+		}
+	}
+
+	var status model.RequestStatus
+	var events int
+	if err := tx.QueryRow(ctx, `
+		SELECT r.status,
+		       (SELECT COUNT(*) FROM outbox WHERE event_type = $2 AND aggregate_id = r.id)::int
+		FROM ride_requests r WHERE r.id = $1
+	`, stale, model.EventRideExpired).Scan(&status, &events); err != nil {
+		t.Fatalf("read stale request: %v", err)
+	}
+	if status != model.RequestExpired || events != 1 {
+		t.Errorf("stale request: status %s with %d ride_expired events; want expired with 1", status, events)
+	}
+}
@@ -0,0 +1,53 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestFindAndCreateTrip_ClaimsEachCabOnce(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	near := seedAvailableCab(t, ctx, tx, "CLAIM-NEAR", 100, 0)
+	far := seedAvailableCab(t, ctx, tx, "CLAIM-FAR", 500, 0)
+
+	claim := func() (int64, *model.Cab, error) {
+		return findAndCreateTrip(ctx, tx, staleProbe, 2000, 1, 0, false, model.DirectionToAirport, 0)
+	}
+	firstTrip, first, err := claim()
+	if err != nil {
+		t.Fatalf("first claim: %v", err)
+	}
+	_, second, err := claim()
+	if err != nil {
+		t.Fatalf("second claim: %v", err)
+	}
+	if first.ID != near || second.ID != far {
+		t.Errorf("claimed cabs %d then %d, want nearest %d then %d", first.ID, second.ID, near, far)
+	}
+	if _, _, err := claim(); !errors.Is(err, ErrNoCabNearby) {
+		t.Errorf("third claim: err = %v, want ErrNoCabNearby", err)
+	}
+
+	var status model.CabStatus
+	var cabID int64
+	if err := tx.QueryRow(ctx, `
+		SELECT c.status, t.cab_id FROM trips t JOIN cabs c ON c.id = t.cab_id WHERE t.id = $1
+	`, firstTrip).Scan(&status, &cabID); err != nil {
+		t.Fatalf("read trip: %v", err)
+	}
+	if cabID != near || status != model.CabEnRoute {
+		t.Errorf("trip %d on cab %d (%s), want cab %d en_route", firstTrip, cabID, status, near)
+	}
+
+	// Discarding the trip hands the cab back.
+	if deleted, err := deleteEmptyTrip(ctx, tx, firstTrip); err != nil || !deleted {
+		t.Fatalf("discard: deleted %v, err %v", deleted, err)
+	}
+	if _, again, err := claim(); err != nil || again.ID != near {
+		t.Errorf("claim after discard: cab %v, err %v; want cab %d", again, err, near)
+	}
+}
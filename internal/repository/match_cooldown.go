@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/pkg/cache"
+)
+
+// MatchCooldownStore records, in Redis, ride requests whose last match
+// attempt found nothing. Each entry expires on its own after the cooldown.
+type MatchCooldownStore struct {
+	redis cooldownCache
+	keys  cache.Namespace
+}
+
+// cooldownCache is the part of *redis.Client the cooldown store uses.
+type cooldownCache interface {
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Exists(ctx context.Context, keys ...string) *redis.IntCmd
+}
+
+// NewMatchCooldownStore creates a store writing keys under the namespace.
+func NewMatchCooldownStore(redis *redis.Client, keys cache.Namespace) *MatchCooldownStore {
+	return &MatchCooldownStore{redis: redis, keys: keys}
+}
+
+func (s *MatchCooldownStore) key(requestID int64) string {
+	return s.keys.Key("match", "cooldown", strconv.FormatInt(requestID, 10))
+}
+
+// Active reports whether requestID is still in cooldown.
+func (s *MatchCooldownStore) Active(ctx context.Context, requestID int64) (bool, error) {
+	n, err := s.redis.Exists(ctx, s.key(requestID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("match cooldown: check request %d: %w", requestID, err)
+	}
+	return n > 0, nil
+}
+
+// Start puts requestID in cooldown for ttl.
+func (s *MatchCooldownStore) Start(ctx context.Context, requestID int64, ttl time.Duration) error {
+	if err := s.redis.Set(ctx, s.key(requestID), 1, ttl).Err(); err != nil {
+		return fmt.Errorf("match cooldown: start request %d: %w", requestID, err)
+	}
+	return nil
+}
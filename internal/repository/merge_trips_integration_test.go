@@ -0,0 +1,92 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+var mergeAirport = model.Location{Lat: 28.5562, Lon: 77.0889}
+
+func TestMergeTrips_MovesRidersAndFreesCab(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	into := seedCandidateTrip(t, ctx, tx, "MERGE-INTO", model.Location{Lat: 10.0000, Lon: 70.0000})
+	from := seedCandidateTrip(t, ctx, tx, "MERGE-FROM", model.Location{Lat: 10.0010, Lon: 70.0010})
+
+	result, err := mergeTrips(ctx, tx, from, into, mergeAirport, 0, false)
+	if err != nil {
+		t.Fatalf("mergeTrips: %v", err)
+	}
+	if len(result.MovedRequests) != 1 || !result.CabFreed || result.RemainingSeats != 2 {
+		t.Errorf("result = %+v, want 1 request moved, the cab freed and 2 seats left", result)
+	}
+
+	var onInto, intoPassengers int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int, (SELECT passenger_count FROM trips WHERE id = $1)
+		FROM ride_requests WHERE trip_id = $1 AND status = 'matched'
+	`, into).Scan(&onInto, &intoPassengers); err != nil {
+		t.Fatalf("read target trip: %v", err)
+	}
+	if onInto != 2 || intoPassengers != 2 {
+		t.Errorf("target trip: %d riders, passenger_count %d; want 2 and 2", onInto, intoPassengers)
+	}
+
+	var fromStatus model.TripStatus
+	var cabStatus model.CabStatus
+	if err := tx.QueryRow(ctx, `
+		SELECT t.status, c.status FROM trips t JOIN cabs c ON c.id = t.cab_id WHERE t.id = $1
+	`, from).Scan(&fromStatus, &cabStatus); err != nil {
+		t.Fatalf("read source trip: %v", err)
+	}
+	if fromStatus != model.TripCancelled || cabStatus != model.CabAvailable {
+		t.Errorf("source trip %s, cab %s; want cancelled and available", fromStatus, cabStatus)
+	}
+
+	var events int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM outbox
+		WHERE (event_type = $1 AND aggregate_id = ANY($2)) OR (event_type = $3 AND aggregate_id = $4)
+	`, model.EventRideReassigned, result.MovedRequests, model.EventTripMerged, from).Scan(&events); err != nil {
+		t.Fatalf("read outbox: %v", err)
+	}
+	if events != 2 {
+		t.Errorf("outbox events = %d, want 1 ride_reassigned + 1 trip_merged", events)
+	}
+
+	// The emptied trip is cancelled, so merging it again is refused.
+	if _, err := mergeTrips(ctx, tx, from, into, mergeAirport, 0, false); !errors.Is(err, ErrTripIncompatible) {
+		t.Errorf("second merge: err = %v, want ErrTripIncompatible", err)
+	}
+}
+
+func TestMergeTrips_OverCapacity(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	into := seedCandidateTrip(t, ctx, tx, "MERGE-FULL",
+		model.Location{Lat: 10.0000, Lon: 70.0000},
+		model.Location{Lat: 10.0005, Lon: 70.0005},
+		model.Location{Lat: 10.0010, Lon: 70.0010})
+	from := seedCandidateTrip(t, ctx, tx, "MERGE-PAIR",
+		model.Location{Lat: 10.0015, Lon: 70.0015},
+		model.Location{Lat: 10.0020, Lon: 70.0020})
+
+	// 3 + 2 riders do not fit the target's 4 seats.
+	if _, err := mergeTrips(ctx, tx, from, into, mergeAirport, 0, false); !errors.Is(err, model.ErrInsufficientCapacity) {
+		t.Fatalf("err = %v, want ErrInsufficientCapacity", err)
+	}
+
+	var onFrom int
+	var fromStatus model.TripStatus
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int, (SELECT status FROM trips WHERE id = $1)
+		FROM ride_requests WHERE trip_id = $1 AND status = 'matched'
+	`, from).Scan(&onFrom, &fromStatus); err != nil {
+		t.Fatalf("read source trip: %v", err)
+	}
+	if onFrom != 2 || fromStatus != model.TripPlanned {
+		t.Errorf("source trip %s with %d riders, want planned with 2", fromStatus, onFrom)
+	}
+}
@@ -0,0 +1,213 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// The outbox row is written in the transaction of the state change it
+// describes: a savepoint stands in for that transaction here.
+func TestOutbox_CommitsAndRollsBackWithStateChange(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "OUTBOX-1", soloOrigin)
+	var userID int64
+	if err := tx.QueryRow(ctx, `SELECT user_id FROM ride_requests WHERE trip_id = $1 LIMIT 1`, tripID).Scan(&userID); err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	newReq := func() *model.RideRequest {
+		return &model.RideRequest{
+			UserID: userID, Origin: soloOrigin, Destination: centroidProbe,
+			Direction: model.DirectionToAirport, SeatsNeeded: 1, ToleranceMeters: 2000,
+		}
+	}
+	count := func(requestID int64) (requests, events int) {
+		t.Helper()
+		if err := tx.QueryRow(ctx, `
+			SELECT (SELECT COUNT(*) FROM ride_requests WHERE id = $1)::int,
+			       (SELECT COUNT(*) FROM outbox WHERE event_type = $2 AND aggregate_id = $1)::int
+		`, requestID, model.EventRideCreated).Scan(&requests, &events); err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		return requests, events
+	}
+
+	rolledBack, err := tx.Begin(ctx)
+	if err != nil {
+		t.Fatalf("savepoint: %v", err)
+	}
+	lost, _, err := createRideRequest(ctx, rolledBack, newReq())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := rolledBack.Rollback(ctx); err != nil {
+		t.Fatalf("rollback: %v", err)
+	}
+	if requests, events := count(lost.ID); requests != 0 || events != 0 {
+		t.Errorf("after rollback: %d requests, %d ride_created events; want neither", requests, events)
+	}
+
+	committed, err := tx.Begin(ctx)
+	if err != nil {
+		t.Fatalf("savepoint: %v", err)
+	}
+	kept, _, err := createRideRequest(ctx, committed, newReq())
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := committed.Commit(ctx); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if requests, events := count(kept.ID); requests != 1 || events != 1 {
+		t.Errorf("after commit: %d requests, %d ride_created events; want one of each", requests, events)
+	}
+}
+
+func TestClaimUnsent_LocksBatchOldestFirst(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	if _, err := tx.Exec(ctx, `UPDATE outbox SET sent_at = NOW() WHERE sent_at IS NULL`); err != nil {
+		t.Fatalf("clear backlog: %v", err)
+	}
+	var ids []int64
+	for i := int64(1); i <= 3; i++ {
+		var id int64
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+			VALUES ($1, $2, $3, '{}') RETURNING id
+		`, model.EventRideCreated, model.AggregateRideRequest, -i).Scan(&id); err != nil {
+			t.Fatalf("seed event: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	events, err := claimUnsent(ctx, tx, 2, time.Minute)
+	if err != nil {
+		t.Fatalf("claimUnsent: %v", err)
+	}
+	if len(events) != 2 || events[0].ID != ids[0] || events[1].ID != ids[1] {
+		t.Fatalf("claimed %+v, want events %d and %d", events, ids[0], ids[1])
+	}
+
+	if err := deadLetter(ctx, tx, ParkedEvent{Event: events[1], Attempts: 3, LastError: "502"}); err != nil {
+		t.Fatalf("deadLetter: %v", err)
+	}
+	if err := markSent(ctx, tx, []int64{ids[0], ids[1]}); err != nil {
+		t.Fatalf("markSent: %v", err)
+	}
+	events, err = claimUnsent(ctx, tx, 2, time.Minute)
+	if err != nil {
+		t.Fatalf("claimUnsent: %v", err)
+	}
+	if len(events) != 1 || events[0].ID != ids[2] {
+		t.Errorf("after marking sent: claimed %+v, want only event %d", events, ids[2])
+	}
+}
+
+func TestClaimUnsent_SkipsLiveClaims(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	if _, err := tx.Exec(ctx, `UPDATE outbox SET sent_at = NOW() WHERE sent_at IS NULL`); err != nil {
+		t.Fatalf("clear backlog: %v", err)
+	}
+	var id int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+		VALUES ($1, $2, -1, '{}') RETURNING id
+	`, model.EventRideCreated, model.AggregateRideRequest).Scan(&id); err != nil {
+		t.Fatalf("seed event: %v", err)
+	}
+
+	if events, err := claimUnsent(ctx, tx, 10, time.Minute); err != nil || len(events) != 1 {
+		t.Fatalf("first claim = %+v, %v; want the event", events, err)
+	}
+	// Another relay's pass while the first is publishing.
+	if events, err := claimUnsent(ctx, tx, 10, time.Minute); err != nil || len(events) != 0 {
+		t.Errorf("second claim = %+v, %v; want nothing while claimed", events, err)
+	}
+
+	// A pass that did not get to it hands it back straight away.
+	if err := releaseClaims(ctx, tx, []int64{id}); err != nil {
+		t.Fatalf("releaseClaims: %v", err)
+	}
+	if events, err := claimUnsent(ctx, tx, 10, time.Minute); err != nil || len(events) != 1 {
+		t.Fatalf("after release = %+v, %v; want the event again", events, err)
+	}
+
+	// A relay that died mid-batch: its claim runs out.
+	if _, err := tx.Exec(ctx, `UPDATE outbox SET claimed_until = NOW() - INTERVAL '1 second' WHERE id = $1`, id); err != nil {
+		t.Fatalf("expire claim: %v", err)
+	}
+	if events, err := claimUnsent(ctx, tx, 10, time.Minute); err != nil || len(events) != 1 {
+		t.Errorf("after claim ran out = %+v, %v; want the event again", events, err)
+	}
+}
+
+func TestDeadLetter_ParkClaimAndRelease(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	if _, err := tx.Exec(ctx, `UPDATE outbox SET sent_at = NOW() WHERE sent_at IS NULL`); err != nil {
+		t.Fatalf("clear backlog: %v", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+		VALUES ($1, $2, -1, '{"trip_id": 7}')
+	`, model.EventRideCreated, model.AggregateRideRequest); err != nil {
+		t.Fatalf("seed event: %v", err)
+	}
+	events, err := claimUnsent(ctx, tx, 1, time.Minute)
+	if err != nil || len(events) != 1 {
+		t.Fatalf("claimUnsent = %+v, %v; want the event", events, err)
+	}
+	event := events[0]
+
+	// Failed publishes are counted on the outbox row.
+	if err := recordAttempt(ctx, tx, ParkedEvent{Event: event, Attempts: 2, LastError: "502"}); err != nil {
+		t.Fatalf("recordAttempt: %v", err)
+	}
+	if err := releaseClaims(ctx, tx, []int64{event.ID}); err != nil {
+		t.Fatalf("releaseClaims: %v", err)
+	}
+	events, err = claimUnsent(ctx, tx, 1, time.Minute)
+	if err != nil || len(events) != 1 || events[0].Attempts != 2 {
+		t.Fatalf("reclaimed %+v, %v; want the event with 2 attempts", events, err)
+	}
+
+	if err := deadLetter(ctx, tx, ParkedEvent{Event: event, Attempts: 3, LastError: "webhook returned 502"}); err != nil {
+		t.Fatalf("deadLetter: %v", err)
+	}
+	var id int64
+	if err := tx.QueryRow(ctx, `SELECT id FROM webhook_deadletter WHERE event_id = $1`, event.ID).Scan(&id); err != nil {
+		t.Fatalf("find dead letter: %v", err)
+	}
+	dl, err := getDeadLetter(ctx, tx, id)
+	if err != nil {
+		t.Fatalf("getDeadLetter: %v", err)
+	}
+	if dl.Event.ID != event.ID || dl.Event.EventType != model.EventRideCreated || dl.Attempts != 3 ||
+		dl.LastError != "webhook returned 502" || dl.ReplayedAt != nil {
+		t.Errorf("dead letter = %+v, want event %d parked after 3 attempts, not replayed", dl, event.ID)
+	}
+
+	// Only the first of two replays gets the claim.
+	if dl, claimed, err := claimReplay(ctx, tx, id); err != nil || !claimed || dl.ReplayedAt == nil {
+		t.Fatalf("first claim = %+v, %v, %v; want claimed and stamped", dl, claimed, err)
+	}
+	if _, claimed, err := claimReplay(ctx, tx, id); err != nil || claimed {
+		t.Errorf("second claim = %v, %v; want not claimed", claimed, err)
+	}
+
+	// A failed replay releases the claim.
+	if err := recordReplayFailure(ctx, tx, id, "webhook returned 500"); err != nil {
+		t.Fatalf("recordReplayFailure: %v", err)
+	}
+	dl, claimed, err := claimReplay(ctx, tx, id)
+	if err != nil || !claimed || dl.Attempts != 4 || dl.LastError != "webhook returned 500" {
+		t.Errorf("after failed replay: %+v, %v, %v; want claimable again with 4 attempts", dl, claimed, err)
+	}
+
+	if _, _, err := claimReplay(ctx, tx, -1); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Errorf("claim missing dead letter: err = %v, want ErrDeadLetterNotFound", err)
+	}
+}
@@ -0,0 +1,365 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// OutboxRepository reads and acknowledges events in the `outbox` table,
+// and keeps the webhook_deadletter table of events the webhook refused.
+//
+// Events are WRITTEN by the other repositories via insertOutboxEvent, inside
+// the transaction that performs the state change. This repository is only
+// used by the relay that publishes them.
+type OutboxRepository struct {
+	pool *pgxpool.Pool
+
+	// ClaimTTL is how long RelayUnsent's claim on a batch keeps other
+	// relays off it; ≤ 0 uses DefaultOutboxClaimTTL. It should outlast
+	// publishing a whole batch.
+	ClaimTTL time.Duration
+}
+
+// NewOutboxRepository creates a new outbox repository.
+func NewOutboxRepository(pool *pgxpool.Pool) *OutboxRepository {
+	return &OutboxRepository{pool: pool}
+}
+
+// insertOutboxEvent writes an event row using the caller's transaction, so the
+// event commits (or rolls back) together with the state change it describes.
+func insertOutboxEvent(
+	ctx context.Context,
+	tx pgx.Tx,
+	eventType model.EventType,
+	aggregateType string,
+	aggregateID int64,
+	payload any,
+) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("outbox: marshal %s payload: %w", eventType, err)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO outbox (event_type, aggregate_type, aggregate_id, payload)
+		VALUES ($1, $2, $3, $4)
+	`, eventType, aggregateType, aggregateID, body)
+	if err != nil {
+		return fmt.Errorf("outbox: insert %s for %s %d: %w", eventType, aggregateType, aggregateID, err)
+	}
+	return nil
+}
+
+// ParkedEvent is an event that failed to publish, with the number of
+// failed attempts so far and the last error.
+type ParkedEvent struct {
+	Event     model.OutboxEvent
+	Attempts  int
+	LastError string
+}
+
+// RelayOutcome is what one relay pass did with the events it was handed.
+type RelayOutcome struct {
+	Sent   []int64       // Published.
+	Failed []ParkedEvent // Not published; attempts recorded on the row for the next pass.
+	Parked []ParkedEvent // Gave up: moved to webhook_deadletter.
+}
+
+// DefaultOutboxClaimTTL is how long a relay's claim on a batch lasts when
+// OutboxRepository.ClaimTTL is unset.
+const DefaultOutboxClaimTTL = 10 * time.Minute
+
+// outboxSettleTimeout bounds recording a relay pass's outcome, which runs
+// even if the pass's context has ended: the events are already published.
+const outboxSettleTimeout = 5 * time.Second
+
+// RelayUnsent runs one relay pass. It claims up to `limit` unsent events,
+// oldest first, and commits the claim, so the events are not locked while
+// relay publishes them and a relay on another replica takes the next ones
+// rather than publishing them again. The events relay reports sent or
+// parked are then stamped sent, and the parked ones dead-lettered, in a
+// second transaction; the rest are released for the next pass. Returns
+// how many events were published.
+//
+// If the process dies between the two, the claim runs out after ClaimTTL
+// and the batch is relayed again: delivery is at-least-once.
+func (r *OutboxRepository) RelayUnsent(
+	ctx context.Context,
+	limit int,
+	relay func(events []model.OutboxEvent) RelayOutcome,
+) (int, error) {
+	ttl := r.ClaimTTL
+	if ttl <= 0 {
+		ttl = DefaultOutboxClaimTTL
+	}
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("outbox: relay: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	events, err := claimUnsent(ctx, tx, limit, ttl)
+	if err != nil {
+		return 0, err
+	}
+	if len(events) == 0 {
+		return 0, nil
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("outbox: relay: commit claim: %w", err)
+	}
+
+	out := relay(events)
+
+	settleCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), outboxSettleTimeout)
+	defer cancel()
+	if err := r.settle(settleCtx, events, out); err != nil {
+		return 0, err
+	}
+	return len(out.Sent), nil
+}
+
+// settle records what relay did with a claimed batch: parked events are
+// dead-lettered, sent and parked ones stamped sent, failed ones get their
+// attempts and error recorded, and the claim on all but the sent and
+// parked is released.
+func (r *OutboxRepository) settle(ctx context.Context, events []model.OutboxEvent, out RelayOutcome) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("outbox: relay: begin settle tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	done := append([]int64{}, out.Sent...)
+	for _, p := range out.Parked {
+		if err := deadLetter(ctx, tx, p); err != nil {
+			return err
+		}
+		done = append(done, p.Event.ID)
+	}
+	if err := markSent(ctx, tx, done); err != nil {
+		return err
+	}
+	for _, f := range out.Failed {
+		if err := recordAttempt(ctx, tx, f); err != nil {
+			return err
+		}
+	}
+	var left []int64
+	for _, e := range events {
+		if !slices.Contains(done, e.ID) {
+			left = append(left, e.ID)
+		}
+	}
+	if err := releaseClaims(ctx, tx, left); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("outbox: relay: commit: %w", err)
+	}
+	return nil
+}
+
+// claimUnsent claims and returns up to `limit` unsent events that no other
+// relay holds a live claim on, oldest first, stamping claimed_until ttl
+// ahead. Rows another transaction is claiming right now are skipped. Uses
+// the partial index idx_outbox_unsent.
+func claimUnsent(ctx context.Context, tx pgx.Tx, limit int, ttl time.Duration) ([]model.OutboxEvent, error) {
+	rows, err := tx.Query(ctx, `
+		UPDATE outbox
+		SET claimed_until = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE sent_at IS NULL
+			  AND (claimed_until IS NULL OR claimed_until < NOW())
+			ORDER BY id ASC
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_type, aggregate_id, payload, created_at, sent_at, attempts
+	`, limit, ttl.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("outbox: claim unsent: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.OutboxEvent
+	for rows.Next() {
+		var e model.OutboxEvent
+		if err := rows.Scan(
+			&e.ID, &e.EventType, &e.AggregateType, &e.AggregateID,
+			&e.Payload, &e.CreatedAt, &e.SentAt, &e.Attempts,
+		); err != nil {
+			return nil, fmt.Errorf("outbox: scan event: %w", err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("outbox: claim unsent: %w", err)
+	}
+	// RETURNING does not keep the subquery's order.
+	slices.SortFunc(events, func(a, b model.OutboxEvent) int { return cmp.Compare(a.ID, b.ID) })
+	return events, nil
+}
+
+// releaseClaims drops the claim on events a relay pass did not get to, so
+// the next pass retries them without waiting for the claim to run out.
+func releaseClaims(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE outbox SET claimed_until = NULL WHERE id = ANY($1) AND sent_at IS NULL
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("outbox: release %d claims: %w", len(ids), err)
+	}
+	return nil
+}
+
+// recordAttempt stores a failed publish's attempt count and error on the
+// event's outbox row.
+func recordAttempt(ctx context.Context, tx pgx.Tx, f ParkedEvent) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE outbox SET attempts = $2, last_error = $3 WHERE id = $1
+	`, f.Event.ID, f.Attempts, f.LastError)
+	if err != nil {
+		return fmt.Errorf("outbox: record attempt on event %d: %w", f.Event.ID, err)
+	}
+	return nil
+}
+
+// markSent stamps sent_at on the given events.
+func markSent(ctx context.Context, tx pgx.Tx, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(ctx, `
+		UPDATE outbox
+		SET sent_at = NOW()
+		WHERE id = ANY($1) AND sent_at IS NULL
+	`, ids)
+	if err != nil {
+		return fmt.Errorf("outbox: mark %d events sent: %w", len(ids), err)
+	}
+	return nil
+}
+
+// ─── Webhook Dead Letters ───────────────────────────────────
+
+// ErrDeadLetterNotFound is returned when no dead letter has the given id.
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// deadLetter parks p in webhook_deadletter. The caller stamps its outbox
+// row sent in the same transaction, so the relay moves past it.
+func deadLetter(ctx context.Context, tx pgx.Tx, p ParkedEvent) error {
+	_, err := tx.Exec(ctx, `
+		INSERT INTO webhook_deadletter (event_id, attempts, last_error)
+		VALUES ($1, $2, $3)
+	`, p.Event.ID, p.Attempts, p.LastError)
+	if err != nil {
+		return fmt.Errorf("outbox: dead-letter event %d: %w", p.Event.ID, err)
+	}
+	return nil
+}
+
+// GetDeadLetter loads dead letter id with its outbox event. Returns an
+// error wrapping ErrDeadLetterNotFound if there is none.
+func (r *OutboxRepository) GetDeadLetter(ctx context.Context, id int64) (*model.WebhookDeadLetter, error) {
+	return getDeadLetter(ctx, r.pool, id)
+}
+
+func getDeadLetter(ctx context.Context, q rowQuerier, id int64) (*model.WebhookDeadLetter, error) {
+	dl := &model.WebhookDeadLetter{ID: id}
+	err := q.QueryRow(ctx, `
+		SELECT d.attempts, d.last_error, d.created_at, d.replayed_at,
+		       o.id, o.event_type, o.aggregate_type, o.aggregate_id, o.payload, o.created_at
+		FROM webhook_deadletter d
+		JOIN outbox o ON o.id = d.event_id
+		WHERE d.id = $1
+	`, id).Scan(
+		&dl.Attempts, &dl.LastError, &dl.CreatedAt, &dl.ReplayedAt,
+		&dl.Event.ID, &dl.Event.EventType, &dl.Event.AggregateType, &dl.Event.AggregateID,
+		&dl.Event.Payload, &dl.Event.CreatedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("outbox: dead letter %d: %w", id, ErrDeadLetterNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("outbox: dead letter %d: %w", id, err)
+	}
+	return dl, nil
+}
+
+// ClaimReplay stamps dead letter id replayed before it is published
+// again, so two replays of the same dead letter cannot both deliver it.
+// It returns the dead letter and whether this call claimed it; false
+// means an earlier replay already did. Returns an error wrapping
+// ErrDeadLetterNotFound if there is none.
+func (r *OutboxRepository) ClaimReplay(ctx context.Context, id int64) (*model.WebhookDeadLetter, bool, error) {
+	return claimReplay(ctx, r.pool, id)
+}
+
+func claimReplay(ctx context.Context, q rowQuerier, id int64) (*model.WebhookDeadLetter, bool, error) {
+	var claimed int64
+	err := q.QueryRow(ctx, `
+		UPDATE webhook_deadletter
+		SET replayed_at = NOW()
+		WHERE id = $1 AND replayed_at IS NULL
+		RETURNING id
+	`, id).Scan(&claimed)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, false, fmt.Errorf("outbox: claim dead letter %d: %w", id, err)
+	}
+	dl, err := getDeadLetter(ctx, q, id)
+	if err != nil {
+		return nil, false, err
+	}
+	return dl, claimed == id, nil
+}
+
+// MarkReplayed counts the successful delivery of dead letter id, which
+// ClaimReplay has already stamped replayed.
+func (r *OutboxRepository) MarkReplayed(ctx context.Context, id int64) error {
+	_, err := r.pool.Exec(ctx, `
+		UPDATE webhook_deadletter SET attempts = attempts + 1 WHERE id = $1
+	`, id)
+	if err != nil {
+		return fmt.Errorf("outbox: mark dead letter %d replayed: %w", id, err)
+	}
+	return nil
+}
+
+// RecordReplayFailure records a failed replay of dead letter id and
+// releases ClaimReplay's claim, so it can be replayed again.
+func (r *OutboxRepository) RecordReplayFailure(ctx context.Context, id int64, lastErr string) error {
+	return recordReplayFailure(ctx, r.pool, id, lastErr)
+}
+
+func recordReplayFailure(ctx context.Context, q rowQuerier, id int64, lastErr string) error {
+	var attempts int
+	err := q.QueryRow(ctx, `
+		UPDATE webhook_deadletter
+		SET attempts = attempts + 1, last_error = $2, replayed_at = NULL
+		WHERE id = $1
+		RETURNING attempts
+	`, id, lastErr).Scan(&attempts)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("outbox: record dead letter %d failure: %w", id, ErrDeadLetterNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("outbox: record dead letter %d failure: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+//go:build integration
+
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestPendingRequests_CarryAccessibilityAndDeadline(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "PENDING-FIELDS", soloOrigin)
+
+	// deadline_at is only allowed on from_airport requests.
+	deadline := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	var accessibleID, plainID int64
+	for _, seed := range []struct {
+		id         *int64
+		accessible bool
+		deadline   *time.Time
+	}{
+		{&accessibleID, true, &deadline},
+		{&plainID, false, nil},
+	} {
+		if err := tx.QueryRow(ctx, `
+			INSERT INTO ride_requests (user_id, origin, destination, direction, status, requires_accessible, deadline_at)
+			SELECT user_id, origin, destination, 'from_airport', 'pending', $2, $3 FROM ride_requests WHERE trip_id = $1
+			RETURNING id
+		`, tripID, seed.accessible, seed.deadline).Scan(seed.id); err != nil {
+			t.Fatalf("seed pending request: %v", err)
+		}
+	}
+
+	check := func(name string, reqs []model.RideRequest) {
+		t.Helper()
+		byID := map[int64]model.RideRequest{}
+		for _, rr := range reqs {
+			byID[rr.ID] = rr
+		}
+		a, ok := byID[accessibleID]
+		if !ok || !a.RequiresAccessible || a.DeadlineAt == nil || !a.DeadlineAt.Equal(deadline) {
+			t.Errorf("%s: accessible request = %+v, want requires_accessible and deadline %s", name, a, deadline)
+		}
+		if p, ok := byID[plainID]; !ok || p.RequiresAccessible || p.DeadlineAt != nil {
+			t.Errorf("%s: plain request = %+v, want neither set", name, p)
+		}
+	}
+
+	rows, err := tx.Query(ctx, listPendingRequestsSQL, 1000)
+	if err != nil {
+		t.Fatalf("list pending: %v", err)
+	}
+	listed, err := scanPendingRequests(rows)
+	if err != nil {
+		t.Fatalf("scan listed: %v", err)
+	}
+	check("list", listed)
+
+	rows, err = tx.Query(ctx, findPendingRequestsNearbySQL,
+		soloOrigin.Lon, soloOrigin.Lat, model.DirectionFromAirport, 100, int64(0), 1000)
+	if err != nil {
+		t.Fatalf("find pending nearby: %v", err)
+	}
+	nearby, err := scanPendingRequests(rows)
+	if err != nil {
+		t.Fatalf("scan nearby: %v", err)
+	}
+	check("nearby", nearby)
+}
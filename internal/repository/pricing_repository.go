@@ -9,33 +9,74 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/cache"
 )
 
 // PricingRepository provides demand/supply data for surge pricing.
 type PricingRepository struct {
-	pool  *pgxpool.Pool
-	redis *redis.Client
+	db       rowQuerier
+	redis    surgeCache
+	keys     cache.Namespace
+	cacheTTL time.Duration
+
+	// CabStaleAfter, when positive, leaves cabs whose last location push is
+	// older than this out of supply counts.
+	CabStaleAfter time.Duration
+
+	// MinSupply floors the supply the ratio divides by (Supply itself is
+	// reported as counted), so one request in an area with no cabs nearby
+	// reads as demand/MinSupply instead of spiking to max surge. ≤ 1 = off.
+	MinSupply int
+
+	// Counters, when set, answers GetDemandSupply from the live per-cell
+	// counters instead of the TTL cache and PostGIS.
+	Counters *SurgeCounters
+
+	// CacheRadiusM is the one radius whose counts are cached: the fare
+	// path's surge radius. The cache key is the cell, not the radius, so
+	// counts for any other radius (GET /surge?radius_m=) always come from
+	// PostGIS rather than overwrite the fare path's entry. 0 = no caching.
+	CacheRadiusM int
+}
+
+// surgeCache is the part of *redis.Client the surge cache uses.
+type surgeCache interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
 }
 
-// NewPricingRepository creates a new pricing repository.
-func NewPricingRepository(pool *pgxpool.Pool, redis *redis.Client) *PricingRepository {
-	return &PricingRepository{pool: pool, redis: redis}
+// NewPricingRepository creates a new pricing repository. Cache keys are
+// written under keys (REDIS_KEY_PREFIX) and live for cacheTTL. redis is
+// normally a *redis.Client behind a cache.Guarded circuit breaker; any
+// cache error, including an open breaker, falls back to PostGIS.
+func NewPricingRepository(pool *pgxpool.Pool, redis surgeCache, keys cache.Namespace, cacheTTL time.Duration) *PricingRepository {
+	return &PricingRepository{db: pool, redis: redis, keys: keys, cacheTTL: cacheTTL}
 }
 
 // DemandSupply holds the counts for a geographic area.
 type DemandSupply struct {
 	Demand int     `json:"demand"` // PENDING ride requests in the area.
 	Supply int     `json:"supply"` // AVAILABLE cabs in the area.
-	Ratio  float64 `json:"ratio"`  // Demand / Supply (0 if supply is 0).
+	Ratio  float64 `json:"ratio"`  // Demand / Supply; see setRatio.
 }
 
-// ─── Redis-backed fast path ─────────────────────────────────
+// setRatio computes Ratio from the counts, dividing by at least minSupply
+// cabs. With no supply and no floor the ratio is the demand itself, as if
+// one cab were there.
+func (ds *DemandSupply) setRatio(minSupply int) {
+	supply := max(ds.Supply, minSupply)
+	switch {
+	case supply > 0:
+		ds.Ratio = float64(ds.Demand) / float64(supply)
+	case ds.Demand > 0:
+		ds.Ratio = float64(ds.Demand) // Infinite demand, treat as demand value.
+	default:
+		ds.Ratio = 0
+	}
+}
 
-const (
-	redisDemandKeyPrefix = "surge:demand:"
-	redisSupplyKeyPrefix = "surge:supply:"
-	redisCacheTTL        = 30 * time.Second // Cache for 30s to avoid DB hammering.
-)
+// ─── Redis-backed fast path ─────────────────────────────────
 
 // geohashKey returns a truncated geohash string for Redis bucketing.
 // We use PostgreSQL's ST_GeoHash with precision 5 (~4.9km × 4.9km cells).
@@ -44,66 +85,128 @@ func geohashKey(loc model.Location) string {
 	return fmt.Sprintf("%.2f:%.2f", loc.Lat, loc.Lon)
 }
 
+// surgeKeys returns the namespaced demand and supply cache keys for the
+// cell containing loc. Demand is counted per direction (direction "" is
+// both combined) so it gets one key per scope; supply is shared.
+func (r *PricingRepository) surgeKeys(loc model.Location, direction model.TripDirection) (demandKey, supplyKey string) {
+	cell := geohashKey(loc)
+	demandKey = r.keys.Key("surge", "demand", cell)
+	if direction != "" {
+		demandKey = r.keys.Key("surge", "demand", string(direction), cell)
+	}
+	return demandKey, r.keys.Key("surge", "supply", cell)
+}
+
+// cellKeys returns every surge cache key for the cell containing loc:
+// the combined and per-direction demand keys, then the supply key.
+func (r *PricingRepository) cellKeys(loc model.Location) []string {
+	demandKey, supplyKey := r.surgeKeys(loc, "")
+	keys := []string{demandKey}
+	for _, d := range model.TripDirections {
+		scoped, _ := r.surgeKeys(loc, d)
+		keys = append(keys, scoped)
+	}
+	return append(keys, supplyKey)
+}
+
 // GetDemandSupply returns the demand/supply ratio for the area around a location.
 //
 // Strategy:
-//  1. Try Redis cache first (fast path, <1ms).
+//  1. Try Redis cache first (fast path, <1ms), unless ctx is nearly out
+//     of time (cache.MinCallBudget) or radiusMeters is not CacheRadiusM.
 //  2. On cache miss, query PostGIS (slow path, ~5ms), then cache in Redis.
 //
 // The counts are scoped to a radius around the given location, not a strict
-// geohash cell, for more accurate surge detection.
+// geohash cell, for more accurate surge detection. A non-empty direction
+// counts only the pending requests going that way, since to- and
+// from-airport demand rise and fall at different times; supply is the
+// same for both. Ratio divides by at least MinSupply cabs.
+//
+// With Counters set, the counts are read from the live counters of the
+// cells covering the radius instead (one Redis round trip), falling back
+// to the steps above if they can't be read or haven't been reconciled yet.
 func (r *PricingRepository) GetDemandSupply(
 	ctx context.Context,
 	location model.Location,
 	radiusMeters int,
+	direction model.TripDirection,
 ) (*DemandSupply, error) {
 
-	cacheKey := geohashKey(location)
+	// ── Live counters ───────────────────────────────────
+	if r.Counters != nil {
+		if ds, err := r.Counters.Read(ctx, location, radiusMeters, direction); err == nil {
+			ds.setRatio(r.MinSupply)
+			return ds, nil
+		}
+	}
 
 	// ── Fast path: Redis cache ──────────────────────────
-	demandKey := redisDemandKeyPrefix + cacheKey
-	supplyKey := redisSupplyKeyPrefix + cacheKey
-
-	demandVal, errD := r.redis.Get(ctx, demandKey).Int()
-	supplyVal, errS := r.redis.Get(ctx, supplyKey).Int()
-
-	if errD == nil && errS == nil {
-		// Cache hit — compute ratio and return.
-		ds := &DemandSupply{
-			Demand: demandVal,
-			Supply: supplyVal,
+	cacheable := radiusMeters == r.CacheRadiusM && r.CacheRadiusM > 0
+	if cacheable {
+		if ds, ok := r.cachedDemandSupply(ctx, location, direction); ok {
+			return ds, nil
 		}
-		if ds.Supply > 0 {
-			ds.Ratio = float64(ds.Demand) / float64(ds.Supply)
-		} else if ds.Demand > 0 {
-			ds.Ratio = float64(ds.Demand) // Infinite demand, treat as demand value.
-		}
-		return ds, nil
 	}
 
 	// ── Slow path: PostGIS query ────────────────────────
-	ds, err := r.queryDemandSupplyFromDB(ctx, location, radiusMeters)
+	ds, err := queryDemandSupplyFromDB(ctx, r.db, location, radiusMeters, direction, r.CabStaleAfter)
 	if err != nil {
 		return nil, err
 	}
+	ds.setRatio(r.MinSupply)
 
-	// Cache the result in Redis (fire-and-forget, don't block on errors).
-	_ = r.redis.Set(ctx, demandKey, ds.Demand, redisCacheTTL).Err()
-	_ = r.redis.Set(ctx, supplyKey, ds.Supply, redisCacheTTL).Err()
-
+	if cacheable {
+		r.cacheDemandSupply(ctx, location, direction, ds)
+	}
 	return ds, nil
 }
 
+// cachedDemandSupply reads the cached counts for location's cell. Any
+// error is a miss: redis.Nil, an open breaker, or a context too close to
+// its deadline to spend time on Redis (cache.ErrDeadlineTooClose).
+func (r *PricingRepository) cachedDemandSupply(ctx context.Context, location model.Location, direction model.TripDirection) (*DemandSupply, bool) {
+	demandKey, supplyKey := r.surgeKeys(location, direction)
+
+	demandVal, err := r.redis.Get(ctx, demandKey).Int()
+	if err != nil {
+		return nil, false
+	}
+	supplyVal, err := r.redis.Get(ctx, supplyKey).Int()
+	if err != nil {
+		return nil, false
+	}
+
+	ds := &DemandSupply{
+		Demand: demandVal,
+		Supply: supplyVal,
+	}
+	ds.setRatio(r.MinSupply)
+	return ds, true
+}
+
+// cacheDemandSupply stores ds for location's cell for the configured TTL
+// (fire-and-forget, don't block on errors).
+func (r *PricingRepository) cacheDemandSupply(ctx context.Context, location model.Location, direction model.TripDirection, ds *DemandSupply) {
+	demandKey, supplyKey := r.surgeKeys(location, direction)
+	_ = r.redis.Set(ctx, demandKey, ds.Demand, r.cacheTTL).Err()
+	_ = r.redis.Set(ctx, supplyKey, ds.Supply, r.cacheTTL).Err()
+}
+
 // queryDemandSupplyFromDB queries PostGIS for demand/supply in a radius.
 //
-// Demand = count of PENDING ride_requests whose origin is within radius.
-// Supply = count of AVAILABLE cabs whose current_location is within radius.
+// Demand = count of PENDING ride_requests whose origin is within radius
+// (and going in direction, unless it is empty).
+// Supply = count of AVAILABLE cabs whose current_location is within radius
+// (and that were seen within staleAfter, unless it is ≤ 0).
 //
 // Both queries use GIST indexes for O(log N) performance.
-func (r *PricingRepository) queryDemandSupplyFromDB(
+func queryDemandSupplyFromDB(
 	ctx context.Context,
+	db rowQuerier,
 	location model.Location,
 	radiusMeters int,
+	direction model.TripDirection,
+	staleAfter time.Duration,
 ) (*DemandSupply, error) {
 
 	// Single query with two subqueries for efficiency.
@@ -112,6 +215,7 @@ func (r *PricingRepository) queryDemandSupplyFromDB(
 			(SELECT COUNT(*)
 			 FROM ride_requests
 			 WHERE status = 'pending'
+			   AND ($4::text = '' OR direction::text = $4)
 			   AND ST_DWithin(
 			         origin::geography,
 			         ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
@@ -121,7 +225,9 @@ func (r *PricingRepository) queryDemandSupplyFromDB(
 			(SELECT COUNT(*)
 			 FROM cabs
 			 WHERE status = 'available'
+			   AND deleted_at IS NULL
 			   AND current_location IS NOT NULL
+			   AND ` + fmt.Sprintf(cabSeenWithin, "$5") + `
 			   AND ST_DWithin(
 			         current_location::geography,
 			         ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
@@ -131,27 +237,41 @@ func (r *PricingRepository) queryDemandSupplyFromDB(
 	`
 
 	ds := &DemandSupply{}
-	err := r.pool.QueryRow(ctx, query,
+	err := db.QueryRow(ctx, query,
 		location.Lon, location.Lat,
 		radiusMeters,
+		string(direction),
+		staleAfter.Seconds(),
 	).Scan(&ds.Demand, &ds.Supply)
 	if err != nil {
 		return nil, fmt.Errorf("query demand/supply: %w", err)
 	}
 
-	if ds.Supply > 0 {
-		ds.Ratio = float64(ds.Demand) / float64(ds.Supply)
-	} else if ds.Demand > 0 {
-		ds.Ratio = float64(ds.Demand)
-	}
-
+	ds.setRatio(0)
 	return ds, nil
 }
 
-// InvalidateSurgeCache clears the cached demand/supply for an area.
-// Call this after a booking or new request to ensure fresh data.
+// InvalidateSurgeCache clears the cached demand/supply for an area, for
+// every direction. Call this after a booking or new request to ensure
+// fresh data.
 func (r *PricingRepository) InvalidateSurgeCache(ctx context.Context, location model.Location) {
-	cacheKey := geohashKey(location)
-	_ = r.redis.Del(ctx, redisDemandKeyPrefix+cacheKey).Err()
-	_ = r.redis.Del(ctx, redisSupplyKeyPrefix+cacheKey).Err()
+	_ = r.redis.Del(ctx, r.cellKeys(location)...).Err()
+}
+
+// InvalidateSurgeCaches clears the cached demand/supply for every distinct
+// cache cell containing one of locations, in a single Del, and returns how
+// many cells that was. Use it after a change touching many riders at once.
+func (r *PricingRepository) InvalidateSurgeCaches(ctx context.Context, locations []model.Location) int {
+	seen := make(map[string]bool, len(locations))
+	var keys []string
+	for _, loc := range locations {
+		if cell := geohashKey(loc); !seen[cell] {
+			seen[cell] = true
+			keys = append(keys, r.cellKeys(loc)...)
+		}
+	}
+	if len(keys) > 0 {
+		_ = r.redis.Del(ctx, keys...).Err()
+	}
+	return len(seen)
 }
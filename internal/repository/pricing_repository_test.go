@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/cache"
+)
+
+// fakeRedis is an in-memory surgeCache that records the TTL of every Set.
+type fakeRedis struct {
+	vals map[string]string
+	ttls map[string]time.Duration
+	sets map[string]map[string]bool
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{vals: map[string]string{}, ttls: map[string]time.Duration{}}
+}
+
+func (f *fakeRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	v, ok := f.vals[key]
+	if !ok {
+		return redis.NewStringResult("", redis.Nil)
+	}
+	return redis.NewStringResult(v, nil)
+}
+
+func (f *fakeRedis) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) *redis.StatusCmd {
+	f.vals[key] = fmt.Sprint(value)
+	f.ttls[key] = ttl
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	for _, k := range keys {
+		delete(f.vals, k)
+	}
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+var surgeProbe = model.Location{Lat: 28.7041, Lon: 77.1025}
+
+func TestSurgeCache_KeysAreNamespacedWithConfiguredTTL(t *testing.T) {
+	rdb := newFakeRedis()
+	r := &PricingRepository{redis: rdb, keys: "staging", cacheTTL: 45 * time.Second, CacheRadiusM: 3000}
+
+	r.cacheDemandSupply(context.Background(), surgeProbe, "", &DemandSupply{Demand: 6, Supply: 3})
+
+	want := map[string]string{
+		"staging:surge:demand:28.70:77.10": "6",
+		"staging:surge:supply:28.70:77.10": "3",
+	}
+	if len(rdb.vals) != len(want) {
+		t.Fatalf("cached keys = %v, want %v", rdb.vals, want)
+	}
+	for k, v := range want {
+		if rdb.vals[k] != v {
+			t.Errorf("%s = %q, want %q", k, rdb.vals[k], v)
+		}
+		if rdb.ttls[k] != 45*time.Second {
+			t.Errorf("%s ttl = %s, want 45s", k, rdb.ttls[k])
+		}
+	}
+
+	// A cache hit is served from the namespaced keys without touching the DB
+	// (r.db is nil).
+	ds, err := r.GetDemandSupply(context.Background(), surgeProbe, 3000, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Demand != 6 || ds.Supply != 3 || ds.Ratio != 2 {
+		t.Errorf("cached demand/supply = %+v, want 6/3 ratio 2", ds)
+	}
+
+	r.InvalidateSurgeCache(context.Background(), surgeProbe)
+	if len(rdb.vals) != 0 {
+		t.Errorf("after invalidate, cached keys = %v, want none", rdb.vals)
+	}
+}
+
+func TestInvalidateSurgeCaches_OncePerDistinctArea(t *testing.T) {
+	rdb := newFakeRedis()
+	r := &PricingRepository{redis: rdb, keys: "prod", cacheTTL: time.Minute}
+	near := model.Location{Lat: 28.7012, Lon: 77.1034} // same cell as surgeProbe
+	far := model.Location{Lat: 28.5562, Lon: 77.0889}
+	untouched := model.Location{Lat: 28.4000, Lon: 77.3000}
+	for _, loc := range []model.Location{surgeProbe, far, untouched} {
+		r.cacheDemandSupply(context.Background(), loc, "", &DemandSupply{Demand: 2, Supply: 1})
+	}
+
+	if n := r.InvalidateSurgeCaches(context.Background(), []model.Location{surgeProbe, near, far}); n != 2 {
+		t.Errorf("areas invalidated = %d, want 2", n)
+	}
+	if len(rdb.vals) != 2 || rdb.vals["prod:surge:demand:28.40:77.30"] != "2" {
+		t.Errorf("cached keys = %v, want only the untouched area left", rdb.vals)
+	}
+	if n := r.InvalidateSurgeCaches(context.Background(), nil); n != 0 {
+		t.Errorf("areas invalidated for no locations = %d, want 0", n)
+	}
+}
+
+func TestSurgeCache_DemandKeyedPerDirection(t *testing.T) {
+	rdb := newFakeRedis()
+	r := &PricingRepository{redis: rdb, keys: "prod", cacheTTL: time.Minute}
+	ctx := context.Background()
+
+	r.cacheDemandSupply(ctx, surgeProbe, "", &DemandSupply{Demand: 8, Supply: 2})
+	r.cacheDemandSupply(ctx, surgeProbe, model.DirectionToAirport, &DemandSupply{Demand: 6, Supply: 2})
+	r.cacheDemandSupply(ctx, surgeProbe, model.DirectionFromAirport, &DemandSupply{Demand: 2, Supply: 2})
+
+	if rdb.vals["prod:surge:demand:to_airport:28.70:77.10"] != "6" || rdb.vals["prod:surge:demand:28.70:77.10"] != "8" {
+		t.Errorf("cached = %v, want combined and to_airport demand under separate keys", rdb.vals)
+	}
+	for dir, want := range map[model.TripDirection]int{"": 8, model.DirectionToAirport: 6, model.DirectionFromAirport: 2} {
+		ds, ok := r.cachedDemandSupply(ctx, surgeProbe, dir)
+		if !ok || ds.Demand != want || ds.Supply != 2 {
+			t.Errorf("direction %q: got %+v (hit %v), want demand %d, supply 2", dir, ds, ok, want)
+		}
+	}
+
+	// Invalidation drops every direction's demand along with supply.
+	r.InvalidateSurgeCache(ctx, surgeProbe)
+	if len(rdb.vals) != 0 {
+		t.Errorf("after invalidate, cached keys = %v, want none", rdb.vals)
+	}
+}
+
+func TestSurgeCache_NamespacesDoNotCollide(t *testing.T) {
+	rdb := newFakeRedis()
+	staging := &PricingRepository{redis: rdb, keys: "staging", cacheTTL: time.Minute}
+	prod := &PricingRepository{redis: rdb, keys: "prod", cacheTTL: time.Minute}
+
+	staging.cacheDemandSupply(context.Background(), surgeProbe, "", &DemandSupply{Demand: 9, Supply: 1})
+	prod.cacheDemandSupply(context.Background(), surgeProbe, "", &DemandSupply{Demand: 1, Supply: 4})
+
+	for k := range rdb.vals {
+		if !strings.HasPrefix(k, "staging:") && !strings.HasPrefix(k, "prod:") {
+			t.Errorf("key %q is not namespaced", k)
+		}
+	}
+	if rdb.vals["staging:surge:demand:28.70:77.10"] != "9" || rdb.vals["prod:surge:demand:28.70:77.10"] != "1" {
+		t.Errorf("cached = %v, want each environment's own counts", rdb.vals)
+	}
+}
+
+func (f *fakeRedis) Exists(ctx context.Context, keys ...string) *redis.IntCmd {
+	var n int64
+	for _, k := range keys {
+		if _, ok := f.vals[k]; ok {
+			n++
+		}
+	}
+	return redis.NewIntResult(n, nil)
+}
+
+func TestMatchCooldownStore_NamespacedKeyAndTTL(t *testing.T) {
+	rdb := newFakeRedis()
+	s := &MatchCooldownStore{redis: rdb, keys: "staging"}
+	ctx := context.Background()
+
+	if active, _ := s.Active(ctx, 42); active {
+		t.Fatal("request 42 in cooldown before any no-match")
+	}
+	if err := s.Start(ctx, 42, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if rdb.ttls["staging:match:cooldown:42"] != 5*time.Second {
+		t.Errorf("ttls = %v, want staging:match:cooldown:42 for 5s", rdb.ttls)
+	}
+	if active, _ := s.Active(ctx, 42); !active {
+		t.Error("request 42 not in cooldown after Start")
+	}
+}
+
+func TestSurgeCache_NearDeadlineGoesToDatabase(t *testing.T) {
+	rdb := newFakeRedis()
+	r := &PricingRepository{redis: cache.Guard(rdb, cache.NewBreaker("test_surge_deadline", 5, time.Second)), cacheTTL: time.Minute}
+	r.cacheDemandSupply(context.Background(), surgeProbe, "", &DemandSupply{Demand: 6, Supply: 3})
+
+	ctx, cancel := context.WithTimeout(context.Background(), cache.MinCallBudget/2)
+	defer cancel()
+	if ds, ok := r.cachedDemandSupply(ctx, surgeProbe, ""); ok {
+		t.Errorf("cached read with %s left = %+v, want a miss so the DB path runs", cache.MinCallBudget/2, ds)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if ds, ok := r.cachedDemandSupply(ctx, surgeProbe, ""); !ok || ds.Demand != 6 || ds.Supply != 3 {
+		t.Errorf("cached read with time to spare = %+v, %v; want the cached 6/3", ds, ok)
+	}
+}
+
+func TestDemandSupplyRatio_MinSupplyFloor(t *testing.T) {
+	tests := []struct {
+		name           string
+		demand, supply int
+		minSupply      int
+		want           float64
+	}{
+		{"no supply, no floor: ratio is demand", 1, 0, 0, 1},
+		{"no supply, floor 3", 1, 0, 3, 1.0 / 3},
+		{"no supply, busy area still surges", 9, 0, 3, 3},
+		{"no supply, no demand", 0, 0, 3, 0},
+		{"supply above floor is used as is", 6, 4, 3, 1.5},
+		{"supply below floor is raised", 6, 1, 3, 2},
+		{"floor of 1 changes nothing", 4, 0, 1, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ds := &DemandSupply{Demand: tt.demand, Supply: tt.supply}
+			ds.setRatio(tt.minSupply)
+			if ds.Ratio != tt.want {
+				t.Errorf("ratio = %v, want %v", ds.Ratio, tt.want)
+			}
+			if ds.Supply != tt.supply {
+				t.Errorf("supply reported as %d, want the counted %d", ds.Supply, tt.supply)
+			}
+		})
+	}
+}
+
+func TestGetDemandSupply_FloorAppliesToCachedCounts(t *testing.T) {
+	rdb := newFakeRedis()
+	r := &PricingRepository{redis: rdb, keys: "test", cacheTTL: time.Minute, MinSupply: 2, CacheRadiusM: 3000}
+	r.cacheDemandSupply(context.Background(), surgeProbe, "", &DemandSupply{Demand: 1, Supply: 0})
+
+	ds, err := r.GetDemandSupply(context.Background(), surgeProbe, 3000, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Supply != 0 || ds.Ratio != 0.5 {
+		t.Errorf("demand/supply = %+v, want supply 0 with ratio 0.5 (1 over the floor of 2)", ds)
+	}
+}
+
+// radiusCounts is a rowQuerier answering the demand/supply query with
+// radius/1000 pending requests and one cab, and counting the queries.
+type radiusCounts struct{ queries int }
+
+func (q *radiusCounts) QueryRow(_ context.Context, _ string, args ...any) pgx.Row {
+	q.queries++
+	return countsRow{demand: args[2].(int) / 1000, supply: 1}
+}
+
+type countsRow struct{ demand, supply int }
+
+func (r countsRow) Scan(dest ...any) error {
+	*dest[0].(*int), *dest[1].(*int) = r.demand, r.supply
+	return nil
+}
+
+func TestGetDemandSupply_OtherRadiusBypassesCache(t *testing.T) {
+	db := &radiusCounts{}
+	r := &PricingRepository{db: db, redis: newFakeRedis(), keys: "test", cacheTTL: time.Minute, CacheRadiusM: 5000}
+	ctx := context.Background()
+
+	if ds, err := r.GetDemandSupply(ctx, surgeProbe, 5000, ""); err != nil || ds.Demand != 5 {
+		t.Fatalf("fare radius = %+v, %v; want demand 5", ds, err)
+	}
+	for i := 0; i < 2; i++ {
+		ds, err := r.GetDemandSupply(ctx, surgeProbe, 1000, "")
+		if err != nil || ds.Demand != 1 {
+			t.Fatalf("1 km radius = %+v, %v; want demand 1, not the cached 5 km counts", ds, err)
+		}
+	}
+	if ds, err := r.GetDemandSupply(ctx, surgeProbe, 5000, ""); err != nil || ds.Demand != 5 {
+		t.Errorf("fare radius after /surge calls = %+v, %v; want the cached demand 5", ds, err)
+	}
+	if db.queries != 3 {
+		t.Errorf("%d database queries, want 3 (one cached fare-radius read)", db.queries)
+	}
+}
@@ -0,0 +1,193 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// onlyRequest returns the id of the single rider on tripID.
+func onlyRequest(t *testing.T, ctx context.Context, tx pgx.Tx, tripID int64) int64 {
+	t.Helper()
+	var id int64
+	if err := tx.QueryRow(ctx, `SELECT id FROM ride_requests WHERE trip_id = $1`, tripID).Scan(&id); err != nil {
+		t.Fatalf("read request on trip %d: %v", tripID, err)
+	}
+	return id
+}
+
+func TestReassignRequest_MovesRiderAndCancelsEmptySource(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	from := seedCandidateTrip(t, ctx, tx, "REASSIGN-FROM", model.Location{Lat: 10.0010, Lon: 70.0010})
+	into := seedCandidateTrip(t, ctx, tx, "REASSIGN-INTO", model.Location{Lat: 10.0000, Lon: 70.0000})
+	requestID := onlyRequest(t, ctx, tx, from)
+
+	repo := NewBookingRepository(nil, mergeAirport, 0)
+	result, err := repo.reassignRequest(ctx, tx, requestID, into)
+	if err != nil {
+		t.Fatalf("reassignRequest: %v", err)
+	}
+	if result.FromTripID != from || result.ToTripID != into || !result.FromTripCancelled || result.RemainingSeats != 2 {
+		t.Errorf("result = %+v, want %d → %d, source cancelled and 2 seats left", result, from, into)
+	}
+
+	var tripID int64
+	var status model.RequestStatus
+	if err := tx.QueryRow(ctx, `
+		SELECT trip_id, status FROM ride_requests WHERE id = $1
+	`, requestID).Scan(&tripID, &status); err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if tripID != into || status != model.RequestMatched {
+		t.Errorf("request on trip %d (%s), want trip %d (matched)", tripID, status, into)
+	}
+
+	var fromCount, intoCount int
+	if err := tx.QueryRow(ctx, `
+		SELECT (SELECT passenger_count FROM trips WHERE id = $1), (SELECT passenger_count FROM trips WHERE id = $2)
+	`, from, into).Scan(&fromCount, &intoCount); err != nil {
+		t.Fatalf("read passenger counts: %v", err)
+	}
+	if fromCount != 0 || intoCount != 2 {
+		t.Errorf("passenger_count source %d, target %d; want 0 and 2", fromCount, intoCount)
+	}
+
+	var fromStatus model.TripStatus
+	var cabStatus model.CabStatus
+	if err := tx.QueryRow(ctx, `
+		SELECT t.status, c.status FROM trips t JOIN cabs c ON c.id = t.cab_id WHERE t.id = $1
+	`, from).Scan(&fromStatus, &cabStatus); err != nil {
+		t.Fatalf("read source trip: %v", err)
+	}
+	if fromStatus != model.TripCancelled || cabStatus != model.CabAvailable {
+		t.Errorf("source trip %s, cab %s; want cancelled and available", fromStatus, cabStatus)
+	}
+
+	var events int
+	if err := tx.QueryRow(ctx, `
+		SELECT COUNT(*)::int FROM outbox WHERE event_type = $1 AND aggregate_id = $2
+	`, model.EventRideReassigned, requestID).Scan(&events); err != nil {
+		t.Fatalf("read outbox: %v", err)
+	}
+	if events != 1 {
+		t.Errorf("ride_reassigned events = %d, want 1", events)
+	}
+
+	// The rider is on the target now, so moving it there again is refused.
+	if _, err := repo.reassignRequest(ctx, tx, requestID, into); !errors.Is(err, ErrTripIncompatible) {
+		t.Errorf("second reassign: err = %v, want ErrTripIncompatible", err)
+	}
+}
+
+func TestReassignRequest_KeepsSourceWithRidersLeft(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	from := seedCandidateTrip(t, ctx, tx, "REASSIGN-PAIR",
+		model.Location{Lat: 10.0010, Lon: 70.0010},
+		model.Location{Lat: 10.0015, Lon: 70.0015})
+	into := seedCandidateTrip(t, ctx, tx, "REASSIGN-SOLO", model.Location{Lat: 10.0000, Lon: 70.0000})
+	var requestID int64
+	if err := tx.QueryRow(ctx, `
+		SELECT id FROM ride_requests WHERE trip_id = $1 ORDER BY id LIMIT 1
+	`, from).Scan(&requestID); err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+
+	result, err := NewBookingRepository(nil, mergeAirport, 0).reassignRequest(ctx, tx, requestID, into)
+	if err != nil {
+		t.Fatalf("reassignRequest: %v", err)
+	}
+	if result.FromTripCancelled {
+		t.Error("source trip cancelled with a rider still on it")
+	}
+	var fromStatus model.TripStatus
+	var fromCount int
+	if err := tx.QueryRow(ctx, `
+		SELECT status, passenger_count FROM trips WHERE id = $1
+	`, from).Scan(&fromStatus, &fromCount); err != nil {
+		t.Fatalf("read source trip: %v", err)
+	}
+	if fromStatus != model.TripPlanned || fromCount != 1 {
+		t.Errorf("source trip %s with %d passengers, want planned with 1", fromStatus, fromCount)
+	}
+}
+
+func TestReassignRequest_ReplansBothRoutes(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	from := seedCandidateTrip(t, ctx, tx, "REASSIGN-RT-FROM",
+		model.Location{Lat: 10.0010, Lon: 70.0010},
+		model.Location{Lat: 10.0200, Lon: 70.0200})
+	into := seedCandidateTrip(t, ctx, tx, "REASSIGN-RT-INTO", model.Location{Lat: 10.0000, Lon: 70.0000})
+	for _, id := range []int64{from, into} {
+		if err := updateTripRoute(ctx, tx, id, model.DirectionToAirport, mergeAirport); err != nil {
+			t.Fatalf("updateTripRoute %d: %v", id, err)
+		}
+	}
+	fromPoints, fromDistance := storedRoute(t, ctx, tx, from)
+	_, intoDistance := storedRoute(t, ctx, tx, into)
+
+	var requestID int64
+	if err := tx.QueryRow(ctx, `
+		SELECT id FROM ride_requests WHERE trip_id = $1 ORDER BY ST_Y(origin) DESC LIMIT 1
+	`, from).Scan(&requestID); err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if _, err := NewBookingRepository(nil, mergeAirport, 0).reassignRequest(ctx, tx, requestID, into); err != nil {
+		t.Fatalf("reassignRequest: %v", err)
+	}
+
+	// The far rider left the source and joined the target.
+	if points, distance := storedRoute(t, ctx, tx, from); points != fromPoints-1 || distance >= fromDistance {
+		t.Errorf("source route %d points, %dm; want %d points and under %dm", points, distance, fromPoints-1, fromDistance)
+	}
+	if points, distance := storedRoute(t, ctx, tx, into); points != 3 || distance <= intoDistance {
+		t.Errorf("target route %d points, %dm; want 3 points and over %dm", points, distance, intoDistance)
+	}
+}
+
+func TestReassignRequest_Refusals(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	from := seedCandidateTrip(t, ctx, tx, "REASSIGN-SRC", model.Location{Lat: 10.0010, Lon: 70.0010})
+	full := seedCandidateTrip(t, ctx, tx, "REASSIGN-FULL",
+		model.Location{Lat: 10.0000, Lon: 70.0000},
+		model.Location{Lat: 10.0002, Lon: 70.0002},
+		model.Location{Lat: 10.0004, Lon: 70.0004},
+		model.Location{Lat: 10.0006, Lon: 70.0006})
+	away := seedCandidateTrip(t, ctx, tx, "REASSIGN-AWAY", model.Location{Lat: 10.0020, Lon: 70.0020})
+	if _, err := tx.Exec(ctx, `UPDATE trips SET direction = 'from_airport' WHERE id = $1`, away); err != nil {
+		t.Fatalf("flip trip direction: %v", err)
+	}
+	requestID := onlyRequest(t, ctx, tx, from)
+	repo := NewBookingRepository(nil, mergeAirport, 0)
+
+	tests := []struct {
+		name   string
+		target int64
+		want   error
+	}{
+		{"full cab", full, model.ErrInsufficientCapacity},
+		{"other direction", away, ErrTripIncompatible},
+		{"missing trip", -1, ErrTripNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := repo.reassignRequest(ctx, tx, requestID, tt.target); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// Every refusal left the rider where it was.
+	var tripID int64
+	if err := tx.QueryRow(ctx, `SELECT trip_id FROM ride_requests WHERE id = $1`, requestID).Scan(&tripID); err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if tripID != from {
+		t.Errorf("request on trip %d after refusals, want %d", tripID, from)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/shiva/hintro/internal/model"
@@ -16,6 +17,15 @@ import (
 // RideRepository provides database access for ride matching operations.
 type RideRepository struct {
 	pool *pgxpool.Pool
+
+	// Capacities, when set, supplies cab capacity to FindNearbyCandidateTrips
+	// from Redis so the candidate query can skip the cabs join.
+	Capacities *CabCapacityCache
+
+	// MaxTripAgeMinutes, when positive, keeps planned trips created longer
+	// ago than this out of FindNearbyCandidateTrips: a trip that has sat
+	// that long without departing should not gather more riders.
+	MaxTripAgeMinutes int
 }
 
 // NewRideRepository creates a new repository backed by the given PG pool.
@@ -36,7 +46,8 @@ func (r *RideRepository) GetRideRequest(ctx context.Context, id int64, forUpdate
 		       ST_Y(origin) AS origin_lat, ST_X(origin) AS origin_lon,
 		       ST_Y(destination) AS dest_lat, ST_X(destination) AS dest_lon,
 		       direction, seats_needed, luggage_count, tolerance_meters,
-		       status, trip_id, scheduled_at, created_at, updated_at
+		       status, trip_id, scheduled_at, created_at, updated_at,
+		       requires_accessible, deadline_at
 		FROM ride_requests
 		WHERE id = $1
 		%s`, lockClause)
@@ -50,83 +61,191 @@ func (r *RideRepository) GetRideRequest(ctx context.Context, id int64, forUpdate
 		&rr.Destination.Lat, &rr.Destination.Lon,
 		&rr.Direction, &rr.SeatsNeeded, &rr.LuggageCount, &rr.ToleranceMeters,
 		&rr.Status, &tripID, &rr.ScheduledAt, &rr.CreatedAt, &rr.UpdatedAt,
+		&rr.RequiresAccessible, &rr.DeadlineAt,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("get ride request %d: %w", id, err)
 	}
 
-	rr.TripID = tripID
+	rr.TripID = model.TripRef(tripID)
 	return rr, nil
 }
 
+// tripDepartureSQL is the earliest and latest departure of trip t's booked
+// riders: a reservation's future scheduled_at, otherwise now. It spans
+// every rider, not only those near the searched pickup.
+const tripDepartureSQL = `
+		SELECT MIN(GREATEST(COALESCE(b.scheduled_at, NOW()), NOW())) AS departs_from,
+		       MAX(GREATEST(COALESCE(b.scheduled_at, NOW()), NOW())) AS departs_until
+		FROM ride_requests b
+		WHERE b.trip_id = t.id AND b.status IN ('matched', 'scheduled')
+	`
+
+// findNearbyCandidateTripsSQL backs FindNearbyCandidateTrips.
+// Args: $1 lon, $2 lat, $3 direction, $4 radius (m), $5 accessible cabs only,
+// $6 max trip age (minutes, ≤ 0 = any age).
+const findNearbyCandidateTripsSQL = `
+	SELECT
+		t.id                AS trip_id,
+		t.cab_id,
+		t.direction,
+		c.seat_capacity,
+		c.luggage_capacity,
+		c.flex_capacity,
+		c.accessible,
+		COALESCE(SUM(rr.seats_needed), 0)::int   AS current_load,
+		COALESCE(SUM(rr.luggage_count), 0)::int   AS current_luggage,
+		COALESCE(
+			ST_Distance(
+				ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
+				ST_Centroid(ST_Collect(rr.origin))::geography
+			),
+			MIN(ST_Distance(
+				rr.origin::geography,
+				ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography
+			))
+		) AS distance_to_req,
+		dep.departs_from,
+		dep.departs_until
+	FROM trips t
+	JOIN cabs c ON c.id = t.cab_id AND c.deleted_at IS NULL
+	JOIN ride_requests rr ON rr.trip_id = t.id AND rr.status IN ('matched', 'scheduled')
+	JOIN LATERAL (` + tripDepartureSQL + `) dep ON true
+	WHERE t.status = 'planned'
+	  AND t.direction = $3
+	  AND (NOT $5::boolean OR c.accessible)
+	  AND ($6::int <= 0 OR t.created_at > NOW() - make_interval(mins => $6::int))
+	  AND ST_DWithin(
+	        rr.origin::geography,
+	        ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
+	        $4
+	      )
+	GROUP BY t.id, t.cab_id, t.direction, c.seat_capacity, c.luggage_capacity, c.flex_capacity, c.accessible,
+	         dep.departs_from, dep.departs_until
+	ORDER BY distance_to_req ASC
+	LIMIT 20
+`
+
+// findNearbyCandidateLoadsSQL is findNearbyCandidateTripsSQL without the
+// cabs join; capacity columns come back zero and are filled from the
+// CabCapacityCache. A cab cannot be deleted while it has a planned trip, so
+// the join's deleted_at filter removes nothing here.
+// Args: $1 lon, $2 lat, $3 direction, $4 radius (m), $5 max trip age
+// (minutes, ≤ 0 = any age).
+const findNearbyCandidateLoadsSQL = `
+	SELECT
+		t.id                AS trip_id,
+		t.cab_id,
+		t.direction,
+		0, 0, NULL::int, false,
+		COALESCE(SUM(rr.seats_needed), 0)::int   AS current_load,
+		COALESCE(SUM(rr.luggage_count), 0)::int   AS current_luggage,
+		COALESCE(
+			ST_Distance(
+				ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
+				ST_Centroid(ST_Collect(rr.origin))::geography
+			),
+			MIN(ST_Distance(
+				rr.origin::geography,
+				ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography
+			))
+		) AS distance_to_req,
+		dep.departs_from,
+		dep.departs_until
+	FROM trips t
+	JOIN ride_requests rr ON rr.trip_id = t.id AND rr.status IN ('matched', 'scheduled')
+	JOIN LATERAL (` + tripDepartureSQL + `) dep ON true
+	WHERE t.status = 'planned'
+	  AND t.direction = $3
+	  AND ($5::int <= 0 OR t.created_at > NOW() - make_interval(mins => $5::int))
+	  AND ST_DWithin(
+	        rr.origin::geography,
+	        ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
+	        $4
+	      )
+	GROUP BY t.id, t.cab_id, t.direction, dep.departs_from, dep.departs_until
+	ORDER BY distance_to_req ASC
+	LIMIT 20
+`
+
 // FindNearbyCandidateTrips finds active trips whose existing passengers have
 // origins within `radiusMeters` of the given point, going in the same direction.
 //
-// This is the KEY spatial query that leverages the GIST index on ride_requests(origin).
+// This is the KEY spatial query that leverages the GIST index on
+// ride_requests(origin::geography) (idx_ride_requests_origin_geog_gist).
 //
 // SQL strategy:
-//  1. Use ST_DWithin on ride_requests.origin to find nearby matched requests.
+//  1. Use ST_DWithin on ride_requests.origin to find nearby matched requests
+//     (and scheduled ones holding a reserved seat on the trip).
 //  2. JOIN through trips → cabs to get capacity info.
 //  3. Aggregate current load (seats + luggage) per trip.
-//  4. Filter to trips that are 'planned' (not yet departed).
+//  4. Filter to trips that are 'planned' (not yet departed), no older
+//     than MaxTripAgeMinutes when that is set, and, when
+//     requiresAccessible is set, whose cab is accessible.
+//  5. Report when the trip's riders depart (DepartsFrom/DepartsUntil), so
+//     matching can keep reservations and live riders apart.
 //
 // The query uses the geography cast (::geography) so radiusMeters is in real meters,
 // not degrees — PostGIS handles the projection automatically.
 //
+// DistanceToReq is measured to the centroid of the trip's pickups. The
+// centroid is taken in plain lon/lat (good enough at city scale), is the
+// passenger's own origin on a one-passenger trip, and is that shared point
+// when every origin coincides. If PostGIS ever returns an empty centroid,
+// the distance falls back to the nearest pickup, so it is never NULL.
+//
+// With Capacities set, cab capacity is read from Redis instead (see
+// findNearbyCandidateLoadsSQL). Accessible-only searches still join cabs:
+// the filter has to run before the LIMIT.
+//
 // Complexity: O(log N) for the GIST index scan + O(K) for the K results.
 func (r *RideRepository) FindNearbyCandidateTrips(
 	ctx context.Context,
 	origin model.Location,
 	direction model.TripDirection,
 	radiusMeters int,
+	requiresAccessible bool,
 ) ([]model.CandidateTrip, error) {
+	if r.Capacities != nil && !requiresAccessible {
+		rows, err := r.pool.Query(ctx, findNearbyCandidateLoadsSQL,
+			origin.Lon, origin.Lat, direction, radiusMeters, r.MaxTripAgeMinutes)
+		if err != nil {
+			return nil, fmt.Errorf("find nearby candidates: %w", err)
+		}
+		candidates, err := scanCandidateTrips(rows)
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		return r.Capacities.fill(ctx, candidates, r.loadCabCapacities)
+	}
 
-	query := `
-		SELECT
-			t.id                AS trip_id,
-			t.cab_id,
-			t.direction,
-			c.seat_capacity,
-			c.luggage_capacity,
-			COALESCE(SUM(rr.seats_needed), 0)::int   AS current_load,
-			COALESCE(SUM(rr.luggage_count), 0)::int   AS current_luggage,
-			ST_Distance(
-				ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
-				ST_Centroid(ST_Collect(rr.origin))::geography
-			) AS distance_to_req
-		FROM trips t
-		JOIN cabs c ON c.id = t.cab_id
-		JOIN ride_requests rr ON rr.trip_id = t.id AND rr.status = 'matched'
-		WHERE t.status = 'planned'
-		  AND t.direction = $3
-		  AND ST_DWithin(
-		        rr.origin::geography,
-		        ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
-		        $4
-		      )
-		GROUP BY t.id, t.cab_id, t.direction, c.seat_capacity, c.luggage_capacity
-		ORDER BY distance_to_req ASC
-		LIMIT 20
-	`
-
-	rows, err := r.pool.Query(ctx, query,
+	rows, err := r.pool.Query(ctx, findNearbyCandidateTripsSQL,
 		origin.Lon, origin.Lat, // ST_MakePoint takes (lon, lat)
 		direction,
 		radiusMeters,
+		requiresAccessible,
+		r.MaxTripAgeMinutes,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("find nearby candidates: %w", err)
 	}
 	defer rows.Close()
 
+	return scanCandidateTrips(rows)
+}
+
+// scanCandidateTrips reads the rows of findNearbyCandidateTripsSQL.
+func scanCandidateTrips(rows pgx.Rows) ([]model.CandidateTrip, error) {
 	var candidates []model.CandidateTrip
 	for rows.Next() {
 		var ct model.CandidateTrip
 		if err := rows.Scan(
 			&ct.TripID, &ct.CabID, &ct.Direction,
-			&ct.SeatCapacity, &ct.LuggageCapacity,
+			&ct.SeatCapacity, &ct.LuggageCapacity, &ct.FlexCapacity, &ct.Accessible,
 			&ct.CurrentLoad, &ct.CurrentLuggage,
 			&ct.DistanceToReq,
+			&ct.DepartsFrom, &ct.DepartsUntil,
 		); err != nil {
 			return nil, fmt.Errorf("scan candidate trip: %w", err)
 		}
@@ -136,11 +255,59 @@ func (r *RideRepository) FindNearbyCandidateTrips(
 	return candidates, rows.Err()
 }
 
+// loadCabCapacities reads the capacity of the given non-deleted cabs.
+func (r *RideRepository) loadCabCapacities(ctx context.Context, cabIDs []int64) (map[int64]model.CabCapacity, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, seat_capacity, luggage_capacity, flex_capacity, accessible
+		FROM cabs
+		WHERE id = ANY($1) AND deleted_at IS NULL
+	`, cabIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	caps := make(map[int64]model.CabCapacity, len(cabIDs))
+	for rows.Next() {
+		var id int64
+		var cc model.CabCapacity
+		if err := rows.Scan(&id, &cc.Seats, &cc.Luggage, &cc.Flex, &cc.Accessible); err != nil {
+			return nil, err
+		}
+		caps[id] = cc
+	}
+	return caps, rows.Err()
+}
+
+// findPendingRequestsNearbySQL backs FindPendingRequestsNearby.
+// Args: $1 lon, $2 lat, $3 direction, $4 radius (m), $5 excluded id, $6 limit.
+const findPendingRequestsNearbySQL = `
+	SELECT id, user_id,
+	       ST_Y(origin) AS origin_lat, ST_X(origin) AS origin_lon,
+	       ST_Y(destination) AS dest_lat, ST_X(destination) AS dest_lon,
+	       direction, seats_needed, luggage_count, tolerance_meters,
+	       status, trip_id, scheduled_at, created_at, updated_at,
+	       requires_accessible, deadline_at
+	FROM ride_requests
+	WHERE status = 'pending'
+	  AND direction = $3
+	  AND id != $5
+	  AND ST_DWithin(
+	        origin::geography,
+	        ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
+	        $4
+	      )
+	ORDER BY created_at ASC
+	LIMIT $6
+`
+
 // FindPendingRequestsNearby returns PENDING ride requests whose origin
 // is within `radiusMeters` of the given point, going in the same direction.
+// radiusMeters is the clustering radius (MATCH_CLUSTER_RADIUS_M), not any
+// rider's tolerance.
 //
-// This directly hits the GIST index `idx_ride_requests_origin_gist` and the
-// composite index `idx_ride_requests_status_direction`.
+// This directly hits the GIST index `idx_ride_requests_origin_geog_gist` and
+// the composite index `idx_ride_requests_status_direction`.
 //
 // Used for initial clustering: "who else is nearby and wants to go the same way?"
 //
@@ -154,26 +321,7 @@ func (r *RideRepository) FindPendingRequestsNearby(
 	limit int,
 ) ([]model.RideRequest, error) {
 
-	query := `
-		SELECT id, user_id,
-		       ST_Y(origin) AS origin_lat, ST_X(origin) AS origin_lon,
-		       ST_Y(destination) AS dest_lat, ST_X(destination) AS dest_lon,
-		       direction, seats_needed, luggage_count, tolerance_meters,
-		       status, trip_id, scheduled_at, created_at, updated_at
-		FROM ride_requests
-		WHERE status = 'pending'
-		  AND direction = $3
-		  AND id != $5
-		  AND ST_DWithin(
-		        origin::geography,
-		        ST_SetSRID(ST_MakePoint($1, $2), 4326)::geography,
-		        $4
-		      )
-		ORDER BY created_at ASC
-		LIMIT $6
-	`
-
-	rows, err := r.pool.Query(ctx, query,
+	rows, err := r.pool.Query(ctx, findPendingRequestsNearbySQL,
 		origin.Lon, origin.Lat,
 		direction,
 		radiusMeters,
@@ -183,6 +331,38 @@ func (r *RideRepository) FindPendingRequestsNearby(
 	if err != nil {
 		return nil, fmt.Errorf("find pending nearby: %w", err)
 	}
+	return scanPendingRequests(rows)
+}
+
+// listPendingRequestsSQL backs ListPendingRequests. Args: $1 limit.
+const listPendingRequestsSQL = `
+	SELECT id, user_id,
+	       ST_Y(origin) AS origin_lat, ST_X(origin) AS origin_lon,
+	       ST_Y(destination) AS dest_lat, ST_X(destination) AS dest_lon,
+	       direction, seats_needed, luggage_count, tolerance_meters,
+	       status, trip_id, scheduled_at, created_at, updated_at,
+	       requires_accessible, deadline_at
+	FROM ride_requests
+	WHERE status = 'pending'
+	ORDER BY created_at ASC, id ASC
+	LIMIT $1
+`
+
+// ListPendingRequests returns up to limit PENDING ride requests, oldest
+// first: the pool the background pooler scans.
+//
+// Uses idx_ride_requests_status_created for the (status, created_at) scan.
+func (r *RideRepository) ListPendingRequests(ctx context.Context, limit int) ([]model.RideRequest, error) {
+	rows, err := r.pool.Query(ctx, listPendingRequestsSQL, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list pending: %w", err)
+	}
+	return scanPendingRequests(rows)
+}
+
+// scanPendingRequests reads the rows of findPendingRequestsNearbySQL or
+// listPendingRequestsSQL and closes them.
+func scanPendingRequests(rows pgx.Rows) ([]model.RideRequest, error) {
 	defer rows.Close()
 
 	var results []model.RideRequest
@@ -195,41 +375,68 @@ func (r *RideRepository) FindPendingRequestsNearby(
 			&rr.Destination.Lat, &rr.Destination.Lon,
 			&rr.Direction, &rr.SeatsNeeded, &rr.LuggageCount, &rr.ToleranceMeters,
 			&rr.Status, &tripID, &rr.ScheduledAt, &rr.CreatedAt, &rr.UpdatedAt,
+			&rr.RequiresAccessible, &rr.DeadlineAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan pending request: %w", err)
 		}
-		rr.TripID = tripID
+		rr.TripID = model.TripRef(tripID)
 		results = append(results, rr)
 	}
 
 	return results, rows.Err()
 }
 
+// statusEvents maps request status transitions to the outbox event they emit.
+var statusEvents = map[model.RequestStatus]model.EventType{
+	model.RequestMatched:   model.EventRideMatched,
+	model.RequestCancelled: model.EventRideCancelled,
+	model.RequestCompleted: model.EventRideCompleted,
+}
+
 // UpdateRequestStatus sets the status and optional trip_id of a ride request.
-// Uses row-level locking (the caller should be inside a transaction).
+// Transitions to matched, cancelled, or completed also write the matching
+// outbox event in the same transaction.
 func (r *RideRepository) UpdateRequestStatus(
 	ctx context.Context,
 	requestID int64,
 	status model.RequestStatus,
 	tripID *int64,
 ) error {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return fmt.Errorf("update request %d status: begin tx: %w", requestID, err)
+	}
+	defer tx.Rollback(ctx)
+
 	query := `
 		UPDATE ride_requests
 		SET status = $2, trip_id = $3
 		WHERE id = $1
 	`
-	_, err := r.pool.Exec(ctx, query, requestID, status, tripID)
+	_, err = tx.Exec(ctx, query, requestID, status, tripID)
 	if err != nil {
 		return fmt.Errorf("update request %d status: %w", requestID, err)
 	}
+
+	if eventType, ok := statusEvents[status]; ok {
+		err = insertOutboxEvent(ctx, tx, eventType, model.AggregateRideRequest, requestID,
+			map[string]any{"status": status, "trip_id": tripID})
+		if err != nil {
+			return fmt.Errorf("update request %d status: %w", requestID, err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("update request %d status: commit: %w", requestID, err)
+	}
 	return nil
 }
 
-// GetTripStops returns the origins of all matched passengers in a trip,
-// ordered by creation time, plus the final destination (for route building).
-func (r *RideRepository) GetTripStops(ctx context.Context, tripID int64) ([]model.Location, error) {
+// GetTripStops returns the pickup and tolerance of all matched passengers
+// in a trip, ordered by creation time (for route building).
+func (r *RideRepository) GetTripStops(ctx context.Context, tripID int64) ([]model.Pickup, error) {
 	query := `
-		SELECT ST_Y(origin) AS lat, ST_X(origin) AS lon
+		SELECT ST_Y(origin) AS lat, ST_X(origin) AS lon, tolerance_meters
 		FROM ride_requests
 		WHERE trip_id = $1 AND status = 'matched'
 		ORDER BY created_at ASC
@@ -240,13 +447,37 @@ func (r *RideRepository) GetTripStops(ctx context.Context, tripID int64) ([]mode
 	}
 	defer rows.Close()
 
-	var stops []model.Location
+	var stops []model.Pickup
 	for rows.Next() {
-		var loc model.Location
-		if err := rows.Scan(&loc.Lat, &loc.Lon); err != nil {
+		var p model.Pickup
+		if err := rows.Scan(&p.Origin.Lat, &p.Origin.Lon, &p.ToleranceMeters); err != nil {
 			return nil, fmt.Errorf("scan stop: %w", err)
 		}
-		stops = append(stops, loc)
+		stops = append(stops, p)
 	}
 	return stops, rows.Err()
 }
+
+// GetTripDropoffs returns the drop-off point and deadline of each matched
+// rider on a trip, in booking order. Matching uses it to project drop-off
+// times on from_airport trips.
+func (r *RideRepository) GetTripDropoffs(ctx context.Context, tripID int64) ([]model.Dropoff, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT ST_Y(destination), ST_X(destination), deadline_at
+		FROM ride_requests
+		WHERE trip_id = $1 AND status = 'matched'
+		ORDER BY created_at ASC
+	`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d drop-offs: %w", tripID, err)
+	}
+	dropoffs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.Dropoff, error) {
+		var d model.Dropoff
+		err := row.Scan(&d.Destination.Lat, &d.Destination.Lon, &d.DeadlineAt)
+		return d, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan trip %d drop-offs: %w", tripID, err)
+	}
+	return dropoffs, nil
+}
@@ -0,0 +1,127 @@
+//go:build integration
+
+package repository
+
+// Integration tests that EXPLAIN the proximity queries and fail if they
+// stop using the spatial index. See integration_test.go for how to run.
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// originGeographyIndex is the GIST index the proximity queries must use.
+const originGeographyIndex = "idx_ride_requests_origin_geog_gist"
+
+// explainSeedSQL spreads 5000 pending requests and 600 matched ones
+// (3 per trip, 200 planned trips) over ~60 km × 55 km around Delhi, so a
+// 2 km radius is selective and the planner has a reason to prefer GIST.
+const explainSeedSQL = `
+	INSERT INTO users (name, email, phone, role)
+	SELECT 'Explain ' || g, 'explain-' || g || '@test.invalid', '+80' || lpad(g::text, 10, '0'), 'driver'
+	FROM generate_series(1, 200) g;
+
+	INSERT INTO cabs (driver_id, license_plate, status)
+	SELECT id, 'EXPLAIN-' || id, 'en_route'
+	FROM users WHERE email LIKE 'explain-%@test.invalid';
+
+	INSERT INTO trips (cab_id, direction, status)
+	SELECT id, 'to_airport', 'planned'
+	FROM cabs WHERE license_plate LIKE 'EXPLAIN-%';
+
+	INSERT INTO ride_requests (user_id, origin, destination, direction, status, trip_id)
+	SELECT c.driver_id,
+	       ST_SetSRID(ST_MakePoint(76.80 + ((t.id * 3 + g) * 7919 % 6000) / 10000.0,
+	                               28.40 + ((t.id * 3 + g) * 104729 % 5000) / 10000.0), 4326),
+	       ST_SetSRID(ST_MakePoint(77.0889, 28.5562), 4326),
+	       'to_airport', 'matched', t.id
+	FROM trips t
+	JOIN cabs c ON c.id = t.cab_id AND c.license_plate LIKE 'EXPLAIN-%'
+	CROSS JOIN generate_series(1, 3) g;
+
+	INSERT INTO ride_requests (user_id, origin, destination, direction, status)
+	SELECT (SELECT min(id) FROM users WHERE email LIKE 'explain-%@test.invalid'),
+	       ST_SetSRID(ST_MakePoint(76.80 + (g * 7919 % 6000) / 10000.0,
+	                               28.40 + (g * 104729 % 5000) / 10000.0), 4326),
+	       ST_SetSRID(ST_MakePoint(77.0889, 28.5562), 4326),
+	       'to_airport', 'pending'
+	FROM generate_series(1, 5000) g;
+
+	ANALYZE users, cabs, trips, ride_requests;
+`
+
+func TestFindNearbyCandidateTrips_UsesOriginGISTIndex(t *testing.T) {
+	plan := explainWithSeed(t, findNearbyCandidateTripsSQL,
+		77.1025, 28.7041, model.DirectionToAirport, 2000, false, 0)
+	assertIndexScan(t, plan, "ride_requests", originGeographyIndex)
+}
+
+func TestFindPendingRequestsNearby_UsesOriginGISTIndex(t *testing.T) {
+	plan := explainWithSeed(t, findPendingRequestsNearbySQL,
+		77.1025, 28.7041, model.DirectionToAirport, 2000, int64(0), 20)
+	assertIndexScan(t, plan, "ride_requests", originGeographyIndex)
+}
+
+// ─── Helpers ────────────────────────────────────────────────
+
+// planNode is one node of EXPLAIN (FORMAT JSON) output.
+type planNode struct {
+	NodeType     string     `json:"Node Type"`
+	RelationName string     `json:"Relation Name"`
+	IndexName    string     `json:"Index Name"`
+	Plans        []planNode `json:"Plans"`
+}
+
+// explainWithSeed seeds the database inside a transaction, EXPLAINs query
+// with args, and rolls everything back. Sequential scans are disabled so
+// a table is only seq-scanned when no index can serve the query — which
+// is exactly the regression these tests look for.
+func explainWithSeed(t *testing.T, query string, args ...any) planNode {
+	t.Helper()
+	ctx, tx := integrationTx(t)
+
+	if _, err := tx.Exec(ctx, explainSeedSQL); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := tx.Exec(ctx, `SET LOCAL enable_seqscan = off`); err != nil {
+		t.Fatalf("disable seqscan: %v", err)
+	}
+
+	var raw []byte
+	if err := tx.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query, args...).Scan(&raw); err != nil {
+		t.Fatalf("explain: %v", err)
+	}
+	var out []struct {
+		Plan planNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil || len(out) == 0 {
+		t.Fatalf("decode plan: %v (%s)", err, raw)
+	}
+	return out[0].Plan
+}
+
+// assertIndexScan fails unless relation is read through index, and never
+// by a sequential scan.
+func assertIndexScan(t *testing.T, plan planNode, relation, index string) {
+	t.Helper()
+	var usedIndex bool
+	var walk func(n planNode)
+	walk = func(n planNode) {
+		if n.RelationName == relation && n.NodeType == "Seq Scan" {
+			t.Errorf("plan seq-scans %s; want %s", relation, index)
+		}
+		if n.IndexName == index {
+			usedIndex = true
+		}
+		for _, child := range n.Plans {
+			walk(child)
+		}
+	}
+	walk(plan)
+	if !usedIndex {
+		out, _ := json.MarshalIndent(plan, "", "  ")
+		t.Errorf("plan does not use %s:\n%s", index, out)
+	}
+}
@@ -0,0 +1,217 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+// Seeded far from any real data so only these trips are in range.
+var (
+	centroidProbe = model.Location{Lat: 10.0000, Lon: 70.0000}
+	soloOrigin    = model.Location{Lat: 10.0050, Lon: 70.0000}
+	sharedOrigin  = model.Location{Lat: 10.0000, Lon: 70.0080}
+)
+
+func TestFindNearbyCandidateTrips_OnePassengerTrip(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "CENTROID-1", soloOrigin)
+
+	ct := candidateByID(t, ctx, tx, tripID)
+	if ct.CurrentLoad != 1 {
+		t.Errorf("current load = %d, want 1", ct.CurrentLoad)
+	}
+	assertDistanceTo(t, ct, soloOrigin)
+}
+
+func TestFindNearbyCandidateTrips_CoincidentOrigins(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "CENTROID-2", sharedOrigin, sharedOrigin, sharedOrigin)
+
+	ct := candidateByID(t, ctx, tx, tripID)
+	if ct.CurrentLoad != 3 {
+		t.Errorf("current load = %d, want 3", ct.CurrentLoad)
+	}
+	assertDistanceTo(t, ct, sharedOrigin)
+}
+
+func TestFindNearbyCandidateTrips_AccessibleOnly(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	standard := seedCandidateTrip(t, ctx, tx, "ACCESS-STD", soloOrigin)
+	equipped := seedCandidateTrip(t, ctx, tx, "ACCESS-WC", sharedOrigin)
+	if _, err := tx.Exec(ctx, `UPDATE cabs SET accessible = TRUE WHERE license_plate = 'ACCESS-WC'`); err != nil {
+		t.Fatalf("mark accessible: %v", err)
+	}
+
+	got := map[int64]bool{}
+	for _, ct := range candidatesNear(t, ctx, tx, true) {
+		got[ct.TripID] = ct.Accessible
+	}
+	if accessible, ok := got[equipped]; !ok || !accessible {
+		t.Errorf("accessible trip %d missing or not flagged: %v", equipped, got)
+	}
+	if _, ok := got[standard]; ok {
+		t.Errorf("standard trip %d returned for an accessible-only search", standard)
+	}
+	if ct := candidateByID(t, ctx, tx, standard); ct.Accessible {
+		t.Errorf("standard trip %d flagged accessible", standard)
+	}
+}
+
+func TestFindNearbyCandidateTrips_ExcludesStaleTrips(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	fresh := seedCandidateTrip(t, ctx, tx, "AGE-FRESH", soloOrigin)
+	stale := seedCandidateTrip(t, ctx, tx, "AGE-STALE", sharedOrigin)
+	if _, err := tx.Exec(ctx, `UPDATE trips SET created_at = NOW() - INTERVAL '3 hours' WHERE id = $1`, stale); err != nil {
+		t.Fatalf("backdate trip: %v", err)
+	}
+
+	for _, tt := range []struct {
+		maxAge    int
+		wantStale bool
+	}{
+		{0, true},
+		{60, false},
+		{240, true},
+	} {
+		got := map[int64]bool{}
+		for _, ct := range candidatesNearMaxAge(t, ctx, tx, false, tt.maxAge) {
+			got[ct.TripID] = true
+		}
+		if !got[fresh] || got[stale] != tt.wantStale {
+			t.Errorf("max age %d: fresh returned %v, stale returned %v; want true, %v",
+				tt.maxAge, got[fresh], got[stale], tt.wantStale)
+		}
+	}
+
+	rows, err := tx.Query(ctx, findNearbyCandidateLoadsSQL,
+		centroidProbe.Lon, centroidProbe.Lat, model.DirectionToAirport, 2000, 60)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	loads, err := scanCandidateTrips(rows)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	for _, ct := range loads {
+		if ct.TripID == stale {
+			t.Errorf("loads query returned stale trip %d", stale)
+		}
+	}
+}
+
+func TestFindNearbyCandidateLoads_MatchesJoinedQuery(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "LOADS-1", soloOrigin, soloOrigin)
+	joined := candidateByID(t, ctx, tx, tripID)
+
+	rows, err := tx.Query(ctx, findNearbyCandidateLoadsSQL,
+		centroidProbe.Lon, centroidProbe.Lat, model.DirectionToAirport, 2000, 0)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	loads, err := scanCandidateTrips(rows)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	for _, ct := range loads {
+		if ct.TripID != tripID {
+			continue
+		}
+		if ct.CabID != joined.CabID || ct.CurrentLoad != joined.CurrentLoad || ct.DistanceToReq != joined.DistanceToReq {
+			t.Errorf("loads row = %+v, want cab/load/distance of %+v", ct, joined)
+		}
+		return
+	}
+	t.Errorf("trip %d missing from %d load rows", tripID, len(loads))
+}
+
+// seedCandidateTrip creates a planned to_airport trip with one matched
+// passenger per origin and returns its id.
+func seedCandidateTrip(t *testing.T, ctx context.Context, tx pgx.Tx, plate string, origins ...model.Location) int64 {
+	t.Helper()
+	var userID, cabID, tripID int64
+	err := tx.QueryRow(ctx, `
+		INSERT INTO users (name, email, phone, role)
+		VALUES ($1, $1 || '@test.invalid', '+81' || lpad((abs(hashtext($1)) % 1000000000)::text, 10, '0'), 'driver')
+		RETURNING id
+	`, plate).Scan(&userID)
+	if err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO cabs (driver_id, license_plate, status) VALUES ($1, $2, 'en_route') RETURNING id
+	`, userID, plate).Scan(&cabID); err != nil {
+		t.Fatalf("seed cab: %v", err)
+	}
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO trips (cab_id, direction, passenger_count, status)
+		VALUES ($1, 'to_airport', $2, 'planned') RETURNING id
+	`, cabID, len(origins)).Scan(&tripID); err != nil {
+		t.Fatalf("seed trip: %v", err)
+	}
+	for _, o := range origins {
+		if _, err := tx.Exec(ctx, `
+			INSERT INTO ride_requests (user_id, origin, destination, direction, status, trip_id)
+			VALUES ($1, ST_SetSRID(ST_MakePoint($2, $3), 4326), ST_SetSRID(ST_MakePoint(77.0889, 28.5562), 4326),
+			        'to_airport', 'matched', $4)
+		`, userID, o.Lon, o.Lat, tripID); err != nil {
+			t.Fatalf("seed request: %v", err)
+		}
+	}
+	return tripID
+}
+
+// candidatesNear runs the candidate query around centroidProbe with no
+// trip age limit.
+func candidatesNear(t *testing.T, ctx context.Context, tx pgx.Tx, accessibleOnly bool) []model.CandidateTrip {
+	t.Helper()
+	return candidatesNearMaxAge(t, ctx, tx, accessibleOnly, 0)
+}
+
+// candidatesNearMaxAge runs the candidate query around centroidProbe,
+// skipping trips older than maxAgeMinutes (≤ 0 = any age).
+func candidatesNearMaxAge(t *testing.T, ctx context.Context, tx pgx.Tx, accessibleOnly bool, maxAgeMinutes int) []model.CandidateTrip {
+	t.Helper()
+	rows, err := tx.Query(ctx, findNearbyCandidateTripsSQL,
+		centroidProbe.Lon, centroidProbe.Lat, model.DirectionToAirport, 2000, accessibleOnly, maxAgeMinutes)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	candidates, err := scanCandidateTrips(rows)
+	if err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	return candidates
+}
+
+// candidateByID runs the candidate query around centroidProbe and returns
+// the row for tripID.
+func candidateByID(t *testing.T, ctx context.Context, tx pgx.Tx, tripID int64) model.CandidateTrip {
+	t.Helper()
+	candidates := candidatesNear(t, ctx, tx, false)
+	for _, ct := range candidates {
+		if ct.TripID == tripID {
+			return ct
+		}
+	}
+	t.Fatalf("trip %d not among %d candidates", tripID, len(candidates))
+	return model.CandidateTrip{}
+}
+
+// assertDistanceTo checks DistanceToReq against the Haversine distance
+// from centroidProbe to want (PostGIS uses the spheroid, so allow 1%).
+func assertDistanceTo(t *testing.T, ct model.CandidateTrip, want model.Location) {
+	t.Helper()
+	expected := geo.HaversineM(centroidProbe, want)
+	if math.IsNaN(ct.DistanceToReq) || math.Abs(ct.DistanceToReq-expected) > expected*0.01 {
+		t.Errorf("distance_to_req = %.1fm, want ≈ %.1fm", ct.DistanceToReq, expected)
+	}
+}
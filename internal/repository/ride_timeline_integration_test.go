@@ -0,0 +1,64 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestRideTimeline_FollowsLifecycle(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "TIMELINE-1",
+		model.Location{Lat: 10.0050, Lon: 70.0000}, model.Location{Lat: 10.0060, Lon: 70.0000})
+
+	var id, other int64
+	if err := tx.QueryRow(ctx, `
+		SELECT MIN(id), MAX(id) FROM ride_requests WHERE trip_id = $1
+	`, tripID).Scan(&id, &other); err != nil {
+		t.Fatalf("find requests: %v", err)
+	}
+	// The seed inserts rows directly; write the events CreateRideRequest and
+	// UpdateRequestStatus would have, plus one for the co-rider.
+	for _, e := range []struct {
+		typ model.EventType
+		id  int64
+	}{
+		{model.EventRideCreated, id},
+		{model.EventRideCreated, other},
+		{model.EventRideMatched, id},
+	} {
+		if err := insertOutboxEvent(ctx, tx, e.typ, model.AggregateRideRequest, e.id, map[string]any{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := cancelTrip(ctx, tx, tripID); err != nil {
+		t.Fatalf("cancelTrip: %v", err)
+	}
+
+	tl, err := rideTimeline(ctx, tx, id)
+	if err != nil {
+		t.Fatalf("rideTimeline: %v", err)
+	}
+	want := []model.EventType{model.EventRideCreated, model.EventRideMatched, model.EventRideCancelled}
+	if tl.RideID != id || len(tl.Events) != len(want) {
+		t.Fatalf("timeline = %+v, want %d events for ride %d", tl, len(want), id)
+	}
+	for i, e := range tl.Events {
+		if e.Type != want[i] {
+			t.Errorf("event %d = %s, want %s", i, e.Type, want[i])
+		}
+		if i > 0 && e.At.Before(tl.Events[i-1].At) {
+			t.Errorf("event %d at %v is before event %d at %v", i, e.At, i-1, tl.Events[i-1].At)
+		}
+	}
+}
+
+func TestRideTimeline_MissingRequest(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	if _, err := rideTimeline(ctx, tx, -1); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("err = %v, want ErrRequestNotFound", err)
+	}
+}
@@ -2,7 +2,11 @@ package repository
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -10,85 +14,337 @@ import (
 	"github.com/shiva/hintro/internal/model"
 )
 
+// ErrRequestNotFound is returned (wrapped) when a ride request ID does not exist.
+var ErrRequestNotFound = errors.New("ride request not found")
+
+// ErrRequestNotEditable is returned (wrapped) by UpdatePendingRequest when
+// the request has already been matched, or has otherwise left the pool.
+var ErrRequestNotEditable = errors.New("ride request can no longer be edited")
+
+// ErrUserNotFound is returned (wrapped) when a user ID does not exist.
+var ErrUserNotFound = errors.New("user not found")
+
 // RideRequestRepository handles CRUD + cancellation for ride requests.
 type RideRequestRepository struct {
-	pool *pgxpool.Pool
+	pool         *pgxpool.Pool
+	maxLuggage   int
+	maxTolerance int
+
+	// LuggageUsesSeats counts free seats as room for overflow bags in
+	// GetTripAvailability, matching BookingRepository.LuggageUsesSeats.
+	LuggageUsesSeats bool
 }
 
-// NewRideRequestRepository creates a new repository.
-func NewRideRequestRepository(pool *pgxpool.Pool) *RideRequestRepository {
-	return &RideRequestRepository{pool: pool}
+// NewRideRequestRepository creates a new repository. maxLuggage caps
+// luggage_count on new requests (see model.ValidateLuggageLimit), and
+// maxTolerance caps tolerance_meters (see service.ValidateToleranceLimit).
+func NewRideRequestRepository(pool *pgxpool.Pool, maxLuggage, maxTolerance int) *RideRequestRepository {
+	return &RideRequestRepository{pool: pool, maxLuggage: maxLuggage, maxTolerance: maxTolerance}
 }
 
-// CreateRideRequest inserts a new pending ride request.
+// checkTolerance rejects a tolerance_meters outside [0, maxTolerance]. The
+// handlers clamp first; this stops any other caller from storing a radius
+// that would turn every candidate search into a city-wide scan.
+func (r *RideRequestRepository) checkTolerance(meters int) error {
+	if meters < 0 || meters > r.maxTolerance {
+		return fmt.Errorf("tolerance_meters must be between 0 and %d, got %d", r.maxTolerance, meters)
+	}
+	return nil
+}
+
+// CreateRideRequest inserts a new ride request. It is 'pending' unless the
+// caller set req.Status to RequestScheduled (see service.InitialStatus).
 // Enforces luggage constraints: LuggageCount must be in [0, 8] (matches DB CHECK).
+// A ride_created outbox event is written in the same transaction.
+//
+// When req.ClientRequestID is set and the same user already has a request
+// with that ID, nothing is written: the earlier request is returned with
+// created false. Concurrent retries race on the unique index, which lets
+// exactly one insert through.
 func (r *RideRequestRepository) CreateRideRequest(
 	ctx context.Context,
 	req *model.RideRequest,
-) (*model.RideRequest, error) {
-	if req.LuggageCount < model.MinLuggagePerRequest || req.LuggageCount > model.MaxLuggagePerRequest {
-		return nil, fmt.Errorf("create ride request: luggage_count must be between %d and %d, got %d",
-			model.MinLuggagePerRequest, model.MaxLuggagePerRequest, req.LuggageCount)
+) (rr *model.RideRequest, created bool, err error) {
+	if req.LuggageCount < model.MinLuggagePerRequest || req.LuggageCount > r.maxLuggage {
+		return nil, false, fmt.Errorf("create ride request: luggage_count must be between %d and %d, got %d",
+			model.MinLuggagePerRequest, r.maxLuggage, req.LuggageCount)
+	}
+	if err := r.checkTolerance(req.ToleranceMeters); err != nil {
+		return nil, false, fmt.Errorf("create ride request: %w", err)
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, false, fmt.Errorf("create ride request: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rr, created, err = createRideRequest(ctx, tx, req)
+	if err != nil || !created {
+		return rr, created, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("create ride request: commit: %w", err)
+	}
+	return rr, true, nil
+}
+
+// createRideRequest inserts req and its outbox event inside tx, or loads
+// the user's earlier request with the same ClientRequestID.
+func createRideRequest(ctx context.Context, tx pgx.Tx, req *model.RideRequest) (*model.RideRequest, bool, error) {
+	if req.Status != model.RequestScheduled {
+		req.Status = model.RequestPending
 	}
+
+	// ON CONFLICT waits for a concurrent insert of the same client ID to
+	// commit and then skips, so the lookup below sees the winner's row.
 	query := `
 		INSERT INTO ride_requests (
 			user_id, origin, destination, direction,
 			seats_needed, luggage_count, tolerance_meters,
-			status, scheduled_at
+			status, scheduled_at, requires_accessible, deadline_at,
+			client_request_id
 		) VALUES (
 			$1,
 			ST_SetSRID(ST_MakePoint($2, $3), 4326),
 			ST_SetSRID(ST_MakePoint($4, $5), 4326),
-			$6, $7, $8, $9, 'pending', $10
+			$6, $7, $8, $9, $10, $11, $12, $13, $14::uuid
 		)
+		ON CONFLICT (user_id, client_request_id) WHERE client_request_id IS NOT NULL DO NOTHING
 		RETURNING id, created_at, updated_at
 	`
-	err := r.pool.QueryRow(ctx, query,
+	err := tx.QueryRow(ctx, query,
 		req.UserID,
 		req.Origin.Lon, req.Origin.Lat,
 		req.Destination.Lon, req.Destination.Lat,
 		req.Direction,
 		req.SeatsNeeded, req.LuggageCount, req.ToleranceMeters,
-		req.ScheduledAt,
+		req.Status, req.ScheduledAt, req.RequiresAccessible, req.DeadlineAt,
+		req.ClientRequestID,
 	).Scan(&req.ID, &req.CreatedAt, &req.UpdatedAt)
-
+	if errors.Is(err, pgx.ErrNoRows) {
+		existing, err := scanRideRequest(tx.QueryRow(ctx,
+			rideRequestSelect+` WHERE user_id = $1 AND client_request_id = $2::uuid`,
+			req.UserID, req.ClientRequestID))
+		if err != nil {
+			return nil, false, fmt.Errorf("create ride request: load existing %s: %w", *req.ClientRequestID, err)
+		}
+		return existing, false, nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("create ride request: %w", err)
+		return nil, false, fmt.Errorf("create ride request: %w", err)
 	}
 
-	req.Status = model.RequestPending
-	return req, nil
+	if err := insertOutboxEvent(ctx, tx, model.EventRideCreated, model.AggregateRideRequest, req.ID, req); err != nil {
+		return nil, false, fmt.Errorf("create ride request: %w", err)
+	}
+	return req, true, nil
 }
 
-// GetRideRequestByID fetches a ride request with full details.
-func (r *RideRequestRepository) GetRideRequestByID(
-	ctx context.Context, id int64,
-) (*model.RideRequest, error) {
-	query := `
+// rideRequestSelect selects every column scanRideRequest reads; callers
+// append the WHERE clause.
+const rideRequestSelect = `
 		SELECT id, user_id,
 		       ST_Y(origin) AS origin_lat, ST_X(origin) AS origin_lon,
 		       ST_Y(destination) AS dest_lat, ST_X(destination) AS dest_lon,
 		       direction, seats_needed, luggage_count, tolerance_meters,
-		       status, trip_id, scheduled_at, created_at, updated_at
-		FROM ride_requests
-		WHERE id = $1
-	`
+		       status, trip_id, scheduled_at, created_at, updated_at,
+		       fare_cents, pool_discount_cents, surge_multiplier, requires_accessible,
+		       deadline_at, client_request_id::text
+		FROM ride_requests`
+
+// scanRideRequest scans one row of rideRequestSelect.
+func scanRideRequest(row pgx.Row) (*model.RideRequest, error) {
 	rr := &model.RideRequest{}
-	var tripID *int64
-	err := r.pool.QueryRow(ctx, query, id).Scan(
+	err := row.Scan(
 		&rr.ID, &rr.UserID,
 		&rr.Origin.Lat, &rr.Origin.Lon,
 		&rr.Destination.Lat, &rr.Destination.Lon,
 		&rr.Direction, &rr.SeatsNeeded, &rr.LuggageCount, &rr.ToleranceMeters,
-		&rr.Status, &tripID, &rr.ScheduledAt, &rr.CreatedAt, &rr.UpdatedAt,
+		&rr.Status, &rr.TripID, &rr.ScheduledAt, &rr.CreatedAt, &rr.UpdatedAt,
+		&rr.FareCents, &rr.PoolDiscountCents, &rr.SurgeMultiplier, &rr.RequiresAccessible,
+		&rr.DeadlineAt, &rr.ClientRequestID,
 	)
+	if err != nil {
+		return nil, err
+	}
+	rr.TripID = model.TripRef(rr.TripID)
+	return rr, nil
+}
+
+// GetRideRequestByID fetches a ride request with full details.
+func (r *RideRequestRepository) GetRideRequestByID(
+	ctx context.Context, id int64,
+) (*model.RideRequest, error) {
+	rr, err := scanRideRequest(r.pool.QueryRow(ctx, rideRequestSelect+` WHERE id = $1`, id))
 	if err != nil {
 		return nil, fmt.Errorf("get ride request %d: %w", id, err)
 	}
-	rr.TripID = tripID
 	return rr, nil
 }
 
+// RideStatus is the slice of a ride request that clients poll for.
+type RideStatus struct {
+	Status    model.RequestStatus `json:"status"`
+	TripID    *int64              `json:"trip_id,omitempty"` // Absent until matched, as on RideRequest.
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// GetRideStatus fetches just the status, trip and last update time of a
+// ride request. Returns an error wrapping ErrRequestNotFound if the
+// request does not exist.
+func (r *RideRequestRepository) GetRideStatus(ctx context.Context, id int64) (*RideStatus, error) {
+	st := &RideStatus{}
+	err := r.pool.QueryRow(ctx, `
+		SELECT status, trip_id, updated_at FROM ride_requests WHERE id = $1
+	`, id).Scan(&st.Status, &st.TripID, &st.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get ride %d status: %w", id, ErrRequestNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get ride %d status: %w", id, err)
+	}
+	st.TripID = model.TripRef(st.TripID)
+	return st, nil
+}
+
+// TimelineEvent is one outbox event recorded against a ride request.
+type TimelineEvent struct {
+	ID      int64           `json:"id"`
+	Type    model.EventType `json:"type"`
+	At      time.Time       `json:"at"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// RideTimeline is every event recorded for a ride request, oldest first.
+type RideTimeline struct {
+	RideID int64           `json:"ride_id"`
+	Events []TimelineEvent `json:"events"`
+}
+
+// GetRideTimeline returns the events the outbox holds for a ride request,
+// in the order they were written, sent or not. Returns an error wrapping
+// ErrRequestNotFound if the request does not exist.
+func (r *RideRequestRepository) GetRideTimeline(ctx context.Context, id int64) (*RideTimeline, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return nil, fmt.Errorf("get ride %d timeline: begin tx: %w", id, err)
+	}
+	defer tx.Rollback(ctx)
+	return rideTimeline(ctx, tx, id)
+}
+
+// rideTimeline reads a request's events inside tx. Events written in one
+// transaction share created_at, so the outbox id breaks the tie.
+func rideTimeline(ctx context.Context, tx pgx.Tx, id int64) (*RideTimeline, error) {
+	var exists bool
+	if err := tx.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM ride_requests WHERE id = $1)
+	`, id).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("get ride %d timeline: %w", id, err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("get ride %d timeline: %w", id, ErrRequestNotFound)
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT id, event_type, created_at, payload
+		FROM outbox
+		WHERE aggregate_type = $1 AND aggregate_id = $2
+		ORDER BY created_at, id
+	`, model.AggregateRideRequest, id)
+	if err != nil {
+		return nil, fmt.Errorf("get ride %d timeline: %w", id, err)
+	}
+	defer rows.Close()
+
+	tl := &RideTimeline{RideID: id, Events: []TimelineEvent{}}
+	for rows.Next() {
+		var e TimelineEvent
+		if err := rows.Scan(&e.ID, &e.Type, &e.At, &e.Payload); err != nil {
+			return nil, fmt.Errorf("get ride %d timeline: scan event: %w", id, err)
+		}
+		tl.Events = append(tl.Events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get ride %d timeline: %w", id, err)
+	}
+	return tl, nil
+}
+
+// RideRequestUpdate is a partial update to a ride request: nil fields are
+// left unchanged.
+type RideRequestUpdate struct {
+	SeatsNeeded     *int `json:"seats_needed,omitempty"`
+	LuggageCount    *int `json:"luggage_count,omitempty"`
+	ToleranceMeters *int `json:"tolerance_meters,omitempty"`
+}
+
+// UpdatePendingRequest applies upd to a request that has not been matched
+// yet ('pending', or 'scheduled' and not yet in the pool) and returns the
+// updated request. The row is locked first, so the update cannot race a
+// booking. Returns an error wrapping ErrRequestNotFound or
+// ErrRequestNotEditable. A ride_updated outbox event is written in the
+// same transaction.
+func (r *RideRequestRepository) UpdatePendingRequest(
+	ctx context.Context, id int64, upd RideRequestUpdate,
+) (*model.RideRequest, error) {
+	if upd.LuggageCount != nil && (*upd.LuggageCount < model.MinLuggagePerRequest || *upd.LuggageCount > r.maxLuggage) {
+		return nil, fmt.Errorf("update ride request: luggage_count must be between %d and %d, got %d",
+			model.MinLuggagePerRequest, r.maxLuggage, *upd.LuggageCount)
+	}
+	if upd.ToleranceMeters != nil {
+		if err := r.checkTolerance(*upd.ToleranceMeters); err != nil {
+			return nil, fmt.Errorf("update ride request: %w", err)
+		}
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("update ride request: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := updatePendingRequest(ctx, tx, id, upd); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("update ride request %d: commit: %w", id, err)
+	}
+	return r.GetRideRequestByID(ctx, id)
+}
+
+// updatePendingRequest is UpdatePendingRequest inside an open transaction.
+func updatePendingRequest(ctx context.Context, tx pgx.Tx, id int64, upd RideRequestUpdate) error {
+	var status model.RequestStatus
+	err := tx.QueryRow(ctx, `SELECT status FROM ride_requests WHERE id = $1 FOR UPDATE`, id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("update ride request %d: %w", id, ErrRequestNotFound)
+	}
+	if err != nil {
+		return fmt.Errorf("update ride request %d: lock: %w", id, err)
+	}
+	if status != model.RequestPending && status != model.RequestScheduled {
+		return fmt.Errorf("update ride request %d: status '%s': %w", id, status, ErrRequestNotEditable)
+	}
+
+	_, err = tx.Exec(ctx, `
+		UPDATE ride_requests
+		SET seats_needed     = COALESCE($2, seats_needed),
+		    luggage_count    = COALESCE($3, luggage_count),
+		    tolerance_meters = COALESCE($4, tolerance_meters)
+		WHERE id = $1
+	`, id, upd.SeatsNeeded, upd.LuggageCount, upd.ToleranceMeters)
+	if err != nil {
+		return fmt.Errorf("update ride request %d: %w", id, err)
+	}
+
+	if err := insertOutboxEvent(ctx, tx, model.EventRideUpdated, model.AggregateRideRequest, id, upd); err != nil {
+		return fmt.Errorf("update ride request %d: %w", id, err)
+	}
+	return nil
+}
+
 // CancelRideRequest cancels a ride and releases the seat back to the cab.
 //
 // Concurrency: Uses SELECT ... FOR UPDATE on both the ride_request and the
@@ -111,21 +367,18 @@ func (r *RideRequestRepository) CancelRideRequest(
 	defer tx.Rollback(ctx)
 
 	// Step 1: Lock the ride request.
-	var status model.RequestStatus
-	var tripID *int64
-	var seatsNeeded int
-	err = tx.QueryRow(ctx, `
-		SELECT status, trip_id, seats_needed
-		FROM ride_requests
-		WHERE id = $1
-		FOR UPDATE
-	`, requestID).Scan(&status, &tripID, &seatsNeeded)
+	status, tripID, seatsNeeded, err := lockCancelRequest(ctx, tx, requestID)
 	if err != nil {
-		return fmt.Errorf("cancel: lock request %d: %w", requestID, err)
+		return err
 	}
 
-	// Can only cancel pending or matched requests.
-	if status != model.RequestPending && status != model.RequestMatched {
+	// Already cancelled: a retried cancel succeeds without writing again.
+	if status == model.RequestCancelled {
+		return nil
+	}
+
+	// Can only cancel scheduled, pending or matched requests.
+	if status != model.RequestScheduled && status != model.RequestPending && status != model.RequestMatched {
 		return fmt.Errorf("cancel: request %d has status '%s', cannot cancel", requestID, status)
 	}
 
@@ -152,6 +405,12 @@ func (r *RideRequestRepository) CancelRideRequest(
 		return fmt.Errorf("cancel: update request %d: %w", requestID, err)
 	}
 
+	err = insertOutboxEvent(ctx, tx, model.EventRideCancelled, model.AggregateRideRequest, requestID,
+		map[string]any{"previous_status": status, "previous_trip_id": tripID})
+	if err != nil {
+		return fmt.Errorf("cancel: %w", err)
+	}
+
 	// Step 4: Commit.
 	if err := tx.Commit(ctx); err != nil {
 		return fmt.Errorf("cancel: commit: %w", err)
@@ -160,43 +419,317 @@ func (r *RideRequestRepository) CancelRideRequest(
 	return nil
 }
 
-// GetTripByID fetches a trip with its passenger list.
+// lockCancelRequest locks requestID for CancelRideRequest and returns its
+// status, trip and seats. A missing request is ErrRequestNotFound.
+func lockCancelRequest(ctx context.Context, db rowQuerier, requestID int64) (model.RequestStatus, *int64, int, error) {
+	var (
+		status      model.RequestStatus
+		tripID      *int64
+		seatsNeeded int
+	)
+	err := db.QueryRow(ctx, `
+		SELECT status, trip_id, seats_needed
+		FROM ride_requests
+		WHERE id = $1
+		FOR UPDATE
+	`, requestID).Scan(&status, &tripID, &seatsNeeded)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, 0, fmt.Errorf("cancel: request %d: %w", requestID, ErrRequestNotFound)
+	}
+	if err != nil {
+		return "", nil, 0, fmt.Errorf("cancel: lock request %d: %w", requestID, err)
+	}
+	return status, tripID, seatsNeeded, nil
+}
+
+// CancelUserPending cancels every PENDING request of userID in one
+// transaction and returns their IDs (empty if there were none), with a
+// ride_cancelled outbox event for each, and the demand they take from the
+// surge counters. Matched, confirmed and scheduled
+// requests are left alone: pending ones hold no seat, so nothing else
+// needs releasing.
+//
+// A request that booking locks and matches first is re-checked by the
+// UPDATE once the lock is released and, no longer pending, is skipped.
+// Uses idx_ride_requests_user_status.
+func (r *RideRequestRepository) CancelUserPending(ctx context.Context, userID int64) ([]int64, []SurgeChange, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, nil, fmt.Errorf("cancel user %d pending: begin tx: %w", userID, err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids, surge, err := cancelUserPending(ctx, tx, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, fmt.Errorf("cancel user %d pending: commit: %w", userID, err)
+	}
+	return ids, surge, nil
+}
+
+// cancelUserPending is CancelUserPending inside an open transaction.
+func cancelUserPending(ctx context.Context, tx pgx.Tx, userID int64) ([]int64, []SurgeChange, error) {
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)`, userID).Scan(&exists); err != nil {
+		return nil, nil, fmt.Errorf("cancel user %d pending: look up user: %w", userID, err)
+	}
+	if !exists {
+		return nil, nil, fmt.Errorf("cancel user %d pending: %w", userID, ErrUserNotFound)
+	}
+
+	rows, err := tx.Query(ctx, `
+		UPDATE ride_requests
+		SET status = 'cancelled'
+		WHERE user_id = $1 AND status = 'pending'
+		RETURNING id, ST_Y(origin), ST_X(origin), direction
+	`, userID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cancel user %d pending: %w", userID, err)
+	}
+	ids, surge, err := collectLeftDemand(rows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cancel user %d pending: collect ids: %w", userID, err)
+	}
+
+	for _, id := range ids {
+		err := insertOutboxEvent(ctx, tx, model.EventRideCancelled, model.AggregateRideRequest, id,
+			map[string]any{"previous_status": model.RequestPending, "bulk": true})
+		if err != nil {
+			return nil, nil, fmt.Errorf("cancel user %d pending: %w", userID, err)
+		}
+	}
+	return ids, surge, nil
+}
+
+// collectLeftDemand reads (id, lat, lon, direction) rows of requests that
+// left 'pending' and returns their sorted IDs and the demand they take
+// from the surge counters.
+func collectLeftDemand(rows pgx.Rows) ([]int64, []SurgeChange, error) {
+	var (
+		ids   []int64
+		surge []SurgeChange
+	)
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			id  int64
+			loc model.Location
+			dir model.TripDirection
+		)
+		if err := rows.Scan(&id, &loc.Lat, &loc.Lon, &dir); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, id)
+		surge = append(surge, demandChange(loc, dir, -1))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	slices.Sort(ids)
+	return ids, surge, nil
+}
+
+// ExpireStalePending moves PENDING requests created more than `ttl` ago to
+// 'expired' and returns how many were expired, with the demand they take
+// from the surge counters. A scheduled request only
+// expires once `ttl` has also passed since its scheduled_at, so activation
+// does not expire it on the next sweep. A ride_expired outbox event is
+// written for each, in the same transaction.
+//
+// Uses idx_ride_requests_status_created for the (status, created_at) scan.
+func (r *RideRequestRepository) ExpireStalePending(ctx context.Context, ttl time.Duration) (int, []SurgeChange, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, nil, fmt.Errorf("expire pending: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	ids, surge, err := expireStalePending(ctx, tx, ttl)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, fmt.Errorf("expire pending: commit: %w", err)
+	}
+	return len(ids), surge, nil
+}
+
+// expireStalePending expires stale pending requests and writes their
+// outbox events inside tx, returning the expired IDs and their demand.
+func expireStalePending(ctx context.Context, tx pgx.Tx, ttl time.Duration) ([]int64, []SurgeChange, error) {
+	rows, err := tx.Query(ctx, `
+		UPDATE ride_requests
+		SET status = 'expired'
+		WHERE status = 'pending'
+		  AND created_at < NOW() - make_interval(secs => $1)
+		  AND (scheduled_at IS NULL OR scheduled_at < NOW() - make_interval(secs => $1))
+		RETURNING id, ST_Y(origin), ST_X(origin), direction
+	`, ttl.Seconds())
+	if err != nil {
+		return nil, nil, fmt.Errorf("expire pending: %w", err)
+	}
+	ids, surge, err := collectLeftDemand(rows)
+	if err != nil {
+		return nil, nil, fmt.Errorf("expire pending: collect ids: %w", err)
+	}
+
+	for _, id := range ids {
+		err := insertOutboxEvent(ctx, tx, model.EventRideExpired, model.AggregateRideRequest, id,
+			map[string]any{"ttl_seconds": ttl.Seconds()})
+		if err != nil {
+			return nil, nil, fmt.Errorf("expire pending: %w", err)
+		}
+	}
+	return ids, surge, nil
+}
+
+// ActivateDueScheduled moves SCHEDULED requests whose scheduled_at is at or
+// before `before` into 'pending', where matching can see them, and returns
+// how many moved, with the demand the newly pending ones add to the surge
+// counters. A request that already reserved a seat on a trip
+// (BookingRepository.ReserveScheduled) goes straight to 'matched' instead.
+// A ride_activated outbox event is written for each, in the same
+// transaction.
+func (r *RideRequestRepository) ActivateDueScheduled(ctx context.Context, before time.Time) (int, []SurgeChange, error) {
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return 0, nil, fmt.Errorf("activate scheduled: begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `
+		UPDATE ride_requests
+		SET status = CASE WHEN trip_id IS NULL THEN 'pending' ELSE 'matched' END
+		WHERE status = 'scheduled'
+		  AND scheduled_at <= $1
+		RETURNING id, scheduled_at, trip_id, ST_Y(origin), ST_X(origin), direction
+	`, before)
+	if err != nil {
+		return 0, nil, fmt.Errorf("activate scheduled: %w", err)
+	}
+	type activated struct {
+		ID          int64
+		ScheduledAt time.Time
+		TripID      *int64
+		Lat, Lon    float64
+		Direction   model.TripDirection
+	}
+	due, err := pgx.CollectRows(rows, pgx.RowToStructByPos[activated])
+	if err != nil {
+		return 0, nil, fmt.Errorf("activate scheduled: collect ids: %w", err)
+	}
+
+	var surge []SurgeChange
+	for _, a := range due {
+		err := insertOutboxEvent(ctx, tx, model.EventRideActivated, model.AggregateRideRequest, a.ID,
+			map[string]any{"scheduled_at": a.ScheduledAt, "trip_id": a.TripID})
+		if err != nil {
+			return 0, nil, fmt.Errorf("activate scheduled: %w", err)
+		}
+		if a.TripID == nil {
+			surge = append(surge, demandChange(model.Location{Lat: a.Lat, Lon: a.Lon}, a.Direction, 1))
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, nil, fmt.Errorf("activate scheduled: commit: %w", err)
+	}
+	return len(due), surge, nil
+}
+
+// Passenger list paging for GetTripByID.
+const (
+	DefaultPassengerPageSize = 50
+	MaxPassengerPageSize     = 200
+)
+
+// PassengerQuery selects a page of a trip's passengers.
+type PassengerQuery struct {
+	// Statuses filters passengers by request status; empty means all.
+	Statuses []model.RequestStatus
+	// Limit ≤ 0 uses DefaultPassengerPageSize; larger than
+	// MaxPassengerPageSize is capped.
+	Limit  int
+	Offset int
+}
+
+// normalized applies the paging defaults and caps.
+func (q PassengerQuery) normalized() PassengerQuery {
+	if q.Limit <= 0 {
+		q.Limit = DefaultPassengerPageSize
+	}
+	q.Limit = min(q.Limit, MaxPassengerPageSize)
+	q.Offset = max(q.Offset, 0)
+	return q
+}
+
+// PassengerPage is one page of a trip's passengers, oldest first.
+type PassengerPage struct {
+	Passengers []model.RideRequest `json:"passengers"`
+	Limit      int                 `json:"limit"`
+	Offset     int                 `json:"offset"`
+	HasMore    bool                `json:"has_more"`
+}
+
+// GetTripByID fetches a trip with one page of its passenger list.
+// A missing trip returns an error wrapping ErrTripNotFound.
 func (r *RideRequestRepository) GetTripByID(
-	ctx context.Context, tripID int64,
-) (*model.Trip, []model.RideRequest, error) {
+	ctx context.Context, tripID int64, q PassengerQuery,
+) (*model.Trip, *PassengerPage, error) {
+	q = q.normalized()
+
 	// Fetch trip.
 	trip := &model.Trip{}
+	var routeJSON []byte
 	err := r.pool.QueryRow(ctx, `
 		SELECT id, cab_id, direction, total_fare_cents, passenger_count,
-		       status, started_at, completed_at, created_at, updated_at
+		       status, started_at, completed_at, created_at, updated_at,
+		       ST_AsGeoJSON(route_path), total_distance_m
 		FROM trips WHERE id = $1
 	`, tripID).Scan(
 		&trip.ID, &trip.CabID, &trip.Direction,
 		&trip.TotalFareCents, &trip.PassengerCount,
 		&trip.Status, &trip.StartedAt, &trip.CompletedAt,
 		&trip.CreatedAt, &trip.UpdatedAt,
+		&routeJSON, &trip.TotalDistanceM,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, fmt.Errorf("get trip %d: %w", tripID, ErrTripNotFound)
+	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("get trip %d: %w", tripID, err)
 	}
+	if trip.RoutePath, err = parseLineStringGeoJSON(routeJSON); err != nil {
+		return nil, nil, fmt.Errorf("get trip %d: %w", tripID, err)
+	}
 
-	// Fetch passengers.
+	// Fetch one page of passengers. One extra row tells us if there is more.
+	statuses := make([]string, len(q.Statuses))
+	for i, st := range q.Statuses {
+		statuses[i] = string(st)
+	}
 	rows, err := r.pool.Query(ctx, `
 		SELECT id, user_id,
 		       ST_Y(origin) AS lat, ST_X(origin) AS lon,
 		       ST_Y(destination) AS dlat, ST_X(destination) AS dlon,
 		       direction, seats_needed, luggage_count, tolerance_meters,
-		       status, trip_id, scheduled_at, created_at, updated_at
+		       status, trip_id, scheduled_at, created_at, updated_at,
+		       fare_cents, pool_discount_cents, surge_multiplier
 		FROM ride_requests
 		WHERE trip_id = $1
-		ORDER BY created_at ASC
-	`, tripID)
+		  AND (cardinality($2::text[]) = 0 OR status::text = ANY($2))
+		ORDER BY created_at ASC, id ASC
+		LIMIT $3 OFFSET $4
+	`, tripID, statuses, q.Limit+1, q.Offset)
 	if err != nil {
 		return nil, nil, fmt.Errorf("get trip %d passengers: %w", tripID, err)
 	}
 	defer rows.Close()
 
-	var passengers []model.RideRequest
+	page := &PassengerPage{Passengers: []model.RideRequest{}, Limit: q.Limit, Offset: q.Offset}
 	for rows.Next() {
 		var rr model.RideRequest
 		var tid *int64
@@ -206,12 +739,313 @@ func (r *RideRequestRepository) GetTripByID(
 			&rr.Destination.Lat, &rr.Destination.Lon,
 			&rr.Direction, &rr.SeatsNeeded, &rr.LuggageCount, &rr.ToleranceMeters,
 			&rr.Status, &tid, &rr.ScheduledAt, &rr.CreatedAt, &rr.UpdatedAt,
+			&rr.FareCents, &rr.PoolDiscountCents, &rr.SurgeMultiplier,
 		); err != nil {
 			return nil, nil, fmt.Errorf("scan passenger: %w", err)
 		}
-		rr.TripID = tid
-		passengers = append(passengers, rr)
+		rr.TripID = model.TripRef(tid)
+		page.Passengers = append(page.Passengers, rr)
+	}
+	if len(page.Passengers) > q.Limit {
+		page.Passengers = page.Passengers[:q.Limit]
+		page.HasMore = true
 	}
 
-	return trip, passengers, rows.Err()
+	return trip, page, rows.Err()
+}
+
+// Area search paging for RequestsInArea.
+const (
+	DefaultAreaPageSize = 100
+	MaxAreaPageSize     = 500
+)
+
+// activeRequestStatuses are the statuses RequestsInArea returns when the
+// query names none: requests still waiting for, or holding, a seat.
+var activeRequestStatuses = []model.RequestStatus{
+	model.RequestPending, model.RequestScheduled, model.RequestMatched, model.RequestConfirmed,
+}
+
+// AreaQuery selects a page of ride requests whose origin lies in a
+// longitude/latitude bounding box (edges included).
+type AreaQuery struct {
+	MinLon, MinLat, MaxLon, MaxLat float64
+	// Statuses filters requests by status; empty means the active ones
+	// (pending, scheduled, matched, confirmed).
+	Statuses []model.RequestStatus
+	// Limit ≤ 0 uses DefaultAreaPageSize; larger than MaxAreaPageSize is
+	// capped.
+	Limit  int
+	Offset int
+}
+
+// normalized applies the status and paging defaults and caps.
+func (q AreaQuery) normalized() AreaQuery {
+	if len(q.Statuses) == 0 {
+		q.Statuses = activeRequestStatuses
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultAreaPageSize
+	}
+	q.Limit = min(q.Limit, MaxAreaPageSize)
+	q.Offset = max(q.Offset, 0)
+	return q
+}
+
+// AreaPage is one page of the requests in an area, oldest first.
+type AreaPage struct {
+	Requests []model.RideRequest `json:"requests"`
+	Limit    int                 `json:"limit"`
+	Offset   int                 `json:"offset"`
+	HasMore  bool                `json:"has_more"`
+}
+
+// RequestsInArea returns one page of the ride requests whose origin falls
+// inside q's bounding box.
+func (r *RideRequestRepository) RequestsInArea(ctx context.Context, q AreaQuery) (*AreaPage, error) {
+	return requestsInArea(ctx, r.pool, q)
+}
+
+// requestsInArea runs the area query on db. The && bounding-box test on
+// the geometry column is served by idx_ride_requests_origin_gist.
+func requestsInArea(ctx context.Context, db rowsQuerier, q AreaQuery) (*AreaPage, error) {
+	q = q.normalized()
+	statuses := make([]string, len(q.Statuses))
+	for i, st := range q.Statuses {
+		statuses[i] = string(st)
+	}
+
+	// One extra row tells us if there is more.
+	rows, err := db.Query(ctx, `
+		SELECT id, user_id,
+		       ST_Y(origin) AS lat, ST_X(origin) AS lon,
+		       ST_Y(destination) AS dlat, ST_X(destination) AS dlon,
+		       direction, seats_needed, luggage_count, tolerance_meters,
+		       status, trip_id, scheduled_at, created_at, updated_at,
+		       fare_cents, pool_discount_cents, surge_multiplier, requires_accessible,
+		       deadline_at
+		FROM ride_requests
+		WHERE origin && ST_MakeEnvelope($1, $2, $3, $4, 4326)
+		  AND status::text = ANY($5)
+		ORDER BY created_at ASC, id ASC
+		LIMIT $6 OFFSET $7
+	`, q.MinLon, q.MinLat, q.MaxLon, q.MaxLat, statuses, q.Limit+1, q.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("requests in area: %w", err)
+	}
+	defer rows.Close()
+
+	page := &AreaPage{Requests: []model.RideRequest{}, Limit: q.Limit, Offset: q.Offset}
+	for rows.Next() {
+		var rr model.RideRequest
+		if err := rows.Scan(
+			&rr.ID, &rr.UserID,
+			&rr.Origin.Lat, &rr.Origin.Lon,
+			&rr.Destination.Lat, &rr.Destination.Lon,
+			&rr.Direction, &rr.SeatsNeeded, &rr.LuggageCount, &rr.ToleranceMeters,
+			&rr.Status, &rr.TripID, &rr.ScheduledAt, &rr.CreatedAt, &rr.UpdatedAt,
+			&rr.FareCents, &rr.PoolDiscountCents, &rr.SurgeMultiplier, &rr.RequiresAccessible,
+			&rr.DeadlineAt,
+		); err != nil {
+			return nil, fmt.Errorf("requests in area: scan: %w", err)
+		}
+		rr.TripID = model.TripRef(rr.TripID)
+		page.Requests = append(page.Requests, rr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("requests in area: %w", err)
+	}
+	if len(page.Requests) > q.Limit {
+		page.Requests = page.Requests[:q.Limit]
+		page.HasMore = true
+	}
+	return page, nil
+}
+
+// TripRoute is what a trip ETA is computed from: the trip's state, where
+// its cab last reported, and the riders still on it in pickup order.
+type TripRoute struct {
+	TripID      int64
+	Status      model.TripStatus
+	Direction   model.TripDirection
+	CabLocation *model.Location     // nil if the cab has never reported a position.
+	Riders      []model.RideRequest // matched, confirmed or reserved (scheduled), oldest first; ID, Origin and Destination set.
+
+	// Path is the planned stop order (trips.route_path): pickups then the
+	// airport, or the airport then drop-offs. Empty with no riders.
+	Path []model.Location
+}
+
+// GetTripRoute loads a trip's TripRoute. Path is the stored route_path,
+// re-planned with planTripRoute if it is missing or no longer visits
+// every rider's stop. Returns an error wrapping ErrTripNotFound if the
+// trip does not exist.
+func (r *RideRequestRepository) GetTripRoute(ctx context.Context, tripID int64) (*TripRoute, error) {
+	route := &TripRoute{TripID: tripID}
+	var cabLat, cabLon *float64
+	var pathJSON []byte
+	err := r.pool.QueryRow(ctx, `
+		SELECT t.status, t.direction, ST_AsGeoJSON(t.route_path),
+		       ST_Y(c.current_location), ST_X(c.current_location)
+		FROM trips t
+		JOIN cabs c ON c.id = t.cab_id
+		WHERE t.id = $1
+	`, tripID).Scan(&route.Status, &route.Direction, &pathJSON, &cabLat, &cabLon)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get trip %d route: %w", tripID, ErrTripNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d route: %w", tripID, err)
+	}
+	if cabLat != nil && cabLon != nil {
+		route.CabLocation = &model.Location{Lat: *cabLat, Lon: *cabLon}
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id,
+		       ST_Y(origin), ST_X(origin),
+		       ST_Y(destination), ST_X(destination)
+		FROM ride_requests
+		WHERE trip_id = $1 AND status IN ('matched', 'confirmed', 'scheduled')
+		ORDER BY created_at ASC, id ASC
+	`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d riders: %w", tripID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rr model.RideRequest
+		if err := rows.Scan(
+			&rr.ID,
+			&rr.Origin.Lat, &rr.Origin.Lon,
+			&rr.Destination.Lat, &rr.Destination.Lon,
+		); err != nil {
+			return nil, fmt.Errorf("scan trip rider: %w", err)
+		}
+		route.Riders = append(route.Riders, rr)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get trip %d riders: %w", tripID, err)
+	}
+
+	if route.Path, err = parseLineStringGeoJSON(pathJSON); err != nil {
+		return nil, fmt.Errorf("get trip %d route: %w", tripID, err)
+	}
+	if !snapRouteStops(route.Direction, route.Path, route.Riders) {
+		route.Path = planTripRoute(route.Direction, route.Riders, model.Location{})
+	}
+	return route, nil
+}
+
+// TripFares is a trip's status and the fares its riders were booked at
+// (see GetTripFares).
+type TripFares struct {
+	TripID int64
+	Status model.TripStatus
+	Riders []model.RideRequest // matched, confirmed or reserved (scheduled), oldest first; ID, UserID, SeatsNeeded and the fare snapshot set.
+}
+
+// GetTripFares loads the fare snapshot of every matched, confirmed or
+// reserved (scheduled) rider on a trip. Riders booked before fares were recorded have nil FareCents.
+// Returns an error wrapping ErrTripNotFound if the trip does not exist.
+func (r *RideRequestRepository) GetTripFares(ctx context.Context, tripID int64) (*TripFares, error) {
+	fares := &TripFares{TripID: tripID, Riders: []model.RideRequest{}}
+	err := r.pool.QueryRow(ctx, `SELECT status FROM trips WHERE id = $1`, tripID).Scan(&fares.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get trip %d fares: %w", tripID, ErrTripNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d fares: %w", tripID, err)
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, user_id, seats_needed, fare_cents, pool_discount_cents, surge_multiplier
+		FROM ride_requests
+		WHERE trip_id = $1 AND status IN ('matched', 'confirmed', 'scheduled')
+		ORDER BY created_at ASC, id ASC
+	`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d fares: %w", tripID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rr model.RideRequest
+		if err := rows.Scan(
+			&rr.ID, &rr.UserID, &rr.SeatsNeeded,
+			&rr.FareCents, &rr.PoolDiscountCents, &rr.SurgeMultiplier,
+		); err != nil {
+			return nil, fmt.Errorf("scan trip fare: %w", err)
+		}
+		fares.Riders = append(fares.Riders, rr)
+	}
+	return fares, rows.Err()
+}
+
+// TripAvailability is how much more a trip can carry right now.
+type TripAvailability struct {
+	TripID          int64            `json:"trip_id"`
+	Status          model.TripStatus `json:"status"`
+	Bookable        bool             `json:"bookable"` // Planned: riders can still be added.
+	SeatCapacity    int              `json:"seat_capacity"`
+	LuggageCapacity int              `json:"luggage_capacity"`
+	FlexCapacity    *int             `json:"flex_capacity,omitempty"`
+	UsedSeats       int              `json:"used_seats"`
+	UsedLuggage     int              `json:"used_luggage"`
+
+	// RemainingSeats and RemainingLuggage are each the most that still
+	// fits on its own (see model.CabCapacity.Remaining); 0 unless Bookable.
+	RemainingSeats   int `json:"remaining_seats"`
+	RemainingLuggage int `json:"remaining_luggage"`
+}
+
+// newTripAvailability works out what is left on a trip from its cab's
+// capacity and current load. Only a planned trip has room to offer.
+func newTripAvailability(tripID int64, status model.TripStatus, capacity model.CabCapacity, usedSeats, usedLuggage int) *TripAvailability {
+	a := &TripAvailability{
+		TripID:          tripID,
+		Status:          status,
+		Bookable:        status == model.TripPlanned,
+		SeatCapacity:    capacity.Seats,
+		LuggageCapacity: capacity.Luggage,
+		FlexCapacity:    capacity.Flex,
+		UsedSeats:       usedSeats,
+		UsedLuggage:     usedLuggage,
+	}
+	if a.Bookable {
+		a.RemainingSeats, a.RemainingLuggage = capacity.Remaining(usedSeats, usedLuggage)
+	}
+	return a
+}
+
+// GetTripAvailability loads a trip's cab capacity and current load (the
+// same load BookRide checks against). Returns an error wrapping
+// ErrTripNotFound if the trip does not exist.
+func (r *RideRequestRepository) GetTripAvailability(ctx context.Context, tripID int64) (*TripAvailability, error) {
+	return tripAvailability(ctx, r.pool, tripID, r.LuggageUsesSeats)
+}
+
+func tripAvailability(ctx context.Context, q rowQuerier, tripID int64, luggageUsesSeats bool) (*TripAvailability, error) {
+	var status model.TripStatus
+	var capacity model.CabCapacity
+	err := q.QueryRow(ctx, `
+		SELECT t.status, c.seat_capacity, c.luggage_capacity, c.flex_capacity
+		FROM trips t
+		JOIN cabs c ON c.id = t.cab_id
+		WHERE t.id = $1
+	`, tripID).Scan(&status, &capacity.Seats, &capacity.Luggage, &capacity.Flex)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get trip %d availability: %w", tripID, ErrTripNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d availability: %w", tripID, err)
+	}
+	capacity.LuggageUsesSeats = luggageUsesSeats
+
+	usedSeats, usedLuggage, err := tripLoad(ctx, q, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d availability: load: %w", tripID, err)
+	}
+	return newTripAvailability(tripID, status, capacity, usedSeats, usedLuggage), nil
 }
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestPassengerQuery_Normalized(t *testing.T) {
+	tests := []struct {
+		name string
+		in   PassengerQuery
+		want PassengerQuery
+	}{
+		{"defaults", PassengerQuery{}, PassengerQuery{Limit: DefaultPassengerPageSize}},
+		{"capped", PassengerQuery{Limit: 10_000, Offset: 400}, PassengerQuery{Limit: MaxPassengerPageSize, Offset: 400}},
+		{"negative offset", PassengerQuery{Limit: 5, Offset: -3}, PassengerQuery{Limit: 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.in.normalized()
+			if got.Limit != tt.want.Limit || got.Offset != tt.want.Offset {
+				t.Errorf("normalized() = limit %d offset %d, want limit %d offset %d",
+					got.Limit, got.Offset, tt.want.Limit, tt.want.Offset)
+			}
+		})
+	}
+}
+
+func TestPassengerQuery_NormalizedKeepsStatuses(t *testing.T) {
+	q := PassengerQuery{Statuses: []model.RequestStatus{model.RequestMatched, model.RequestConfirmed}}
+	if got := q.normalized().Statuses; len(got) != 2 {
+		t.Errorf("statuses = %v, want matched and confirmed", got)
+	}
+}
+
+func TestCreateRideRequest_EnforcesConfiguredLuggageLimit(t *testing.T) {
+	// Rejected before the database is touched, so no pool is needed.
+	repo := NewRideRequestRepository(nil, 2, 7500)
+
+	_, _, err := repo.CreateRideRequest(context.Background(), &model.RideRequest{LuggageCount: 3})
+	if err == nil || !strings.Contains(err.Error(), "between 0 and 2, got 3") {
+		t.Errorf("err = %v, want luggage limit error", err)
+	}
+}
+
+func TestRideRequestRepository_RejectsToleranceAboveCap(t *testing.T) {
+	// Rejected before the database is touched, so no pool is needed.
+	repo := NewRideRequestRepository(nil, 8, 3000)
+
+	for _, meters := range []int{3001, 1_000_000, -1} {
+		_, _, err := repo.CreateRideRequest(context.Background(), &model.RideRequest{ToleranceMeters: meters})
+		if err == nil || !strings.Contains(err.Error(), "tolerance_meters must be between 0 and 3000") {
+			t.Errorf("create with %d: err = %v, want tolerance cap error", meters, err)
+		}
+		_, err = repo.UpdatePendingRequest(context.Background(), 1, RideRequestUpdate{ToleranceMeters: &meters})
+		if err == nil || !strings.Contains(err.Error(), "tolerance_meters must be between 0 and 3000") {
+			t.Errorf("update to %d: err = %v, want tolerance cap error", meters, err)
+		}
+	}
+}
+
+func TestNewTripAvailability(t *testing.T) {
+	flex := 5
+	tests := []struct {
+		name                string
+		status              model.TripStatus
+		capacity            model.CabCapacity
+		usedSeats, usedBags int
+		wantSeats, wantBags int
+		wantBookable        bool
+	}{
+		{"partially booked", model.TripPlanned, model.CabCapacity{Seats: 4, Luggage: 3}, 3, 1, 1, 2, true},
+		{"flex units shared", model.TripPlanned, model.CabCapacity{Seats: 4, Luggage: 3, Flex: &flex}, 2, 1, 2, 2, true},
+		{"full", model.TripPlanned, model.CabCapacity{Seats: 4, Luggage: 3}, 4, 3, 0, 0, true},
+		{"in progress", model.TripInProgress, model.CabCapacity{Seats: 4, Luggage: 3}, 1, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTripAvailability(7, tt.status, tt.capacity, tt.usedSeats, tt.usedBags)
+			if a.RemainingSeats != tt.wantSeats || a.RemainingLuggage != tt.wantBags || a.Bookable != tt.wantBookable {
+				t.Errorf("got %d seats, %d bags, bookable %v; want %d, %d, %v",
+					a.RemainingSeats, a.RemainingLuggage, a.Bookable, tt.wantSeats, tt.wantBags, tt.wantBookable)
+			}
+			if a.UsedSeats != tt.usedSeats || a.SeatCapacity != tt.capacity.Seats {
+				t.Errorf("used %d of %d seats, want %d of %d", a.UsedSeats, a.SeatCapacity, tt.usedSeats, tt.capacity.Seats)
+			}
+		})
+	}
+}
+
+// errRow is a pgx.Row whose Scan fails with err.
+type errRow struct{ err error }
+
+func (r errRow) Scan(...any) error { return r.err }
+
+// errQuerier answers every QueryRow with an errRow.
+type errQuerier struct{ err error }
+
+func (q errQuerier) QueryRow(context.Context, string, ...any) pgx.Row { return errRow{q.err} }
+
+func TestLockCancelRequest_NoRowsIsNotFound(t *testing.T) {
+	_, _, _, err := lockCancelRequest(context.Background(), errQuerier{pgx.ErrNoRows}, 999)
+	if !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("err = %v, want ErrRequestNotFound", err)
+	}
+
+	_, _, _, err = lockCancelRequest(context.Background(), errQuerier{errors.New("connection reset")}, 999)
+	if err == nil || errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("err = %v, want a non-not-found error", err)
+	}
+}
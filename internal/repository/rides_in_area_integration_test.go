@@ -0,0 +1,47 @@
+//go:build integration
+
+package repository
+
+import (
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestRequestsInArea_InsideAndOutside(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	inside := seedCandidateTrip(t, ctx, tx, "AREA-IN", soloOrigin, soloOrigin)
+	outside := seedCandidateTrip(t, ctx, tx, "AREA-OUT", sharedOrigin)
+
+	// A box around soloOrigin that stops short of sharedOrigin's longitude.
+	q := AreaQuery{MinLon: 69.999, MinLat: 10.004, MaxLon: 70.001, MaxLat: 10.006}
+	page, err := requestsInArea(ctx, tx, q)
+	if err != nil {
+		t.Fatalf("requestsInArea: %v", err)
+	}
+	onTrip := map[int64]int{}
+	for _, rr := range page.Requests {
+		onTrip[*rr.TripID]++
+	}
+	if onTrip[inside] != 2 || onTrip[outside] != 0 {
+		t.Errorf("requests per trip = %v, want 2 on trip %d and none on %d", onTrip, inside, outside)
+	}
+
+	q.Statuses = []model.RequestStatus{model.RequestCancelled}
+	if page, err = requestsInArea(ctx, tx, q); err != nil {
+		t.Fatalf("requestsInArea: %v", err)
+	}
+	for _, rr := range page.Requests {
+		if rr.TripID != nil && *rr.TripID == inside {
+			t.Errorf("status filter returned matched request %d", rr.ID)
+		}
+	}
+
+	q.Statuses, q.Limit = nil, 1
+	if page, err = requestsInArea(ctx, tx, q); err != nil {
+		t.Fatalf("requestsInArea: %v", err)
+	}
+	if len(page.Requests) != 1 || !page.HasMore {
+		t.Errorf("limit 1: got %d requests, has_more %v; want 1 and true", len(page.Requests), page.HasMore)
+	}
+}
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ─── Retryable transaction failures ─────────────────────────
+
+// IsSerializationFailure reports whether err means Postgres aborted the
+// transaction to keep concurrent transactions consistent: a serialization
+// failure (40001, raised under Repeatable Read or Serializable isolation)
+// or a deadlock (40P01). The transaction was rolled back in full, so
+// running it again from the start is safe and usually succeeds.
+func IsSerializationFailure(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsSerializationFailure(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, true},
+		{"wrapped", fmt.Errorf("booking: lock cab 3: %w", &pgconn.PgError{Code: "40001"}), true},
+		{"deadlock", &pgconn.PgError{Code: "40P01"}, true},
+
+		{"nil", nil, false},
+		{"lock timeout", &pgconn.PgError{Code: "55P03"}, false},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"caller deadline", fmt.Errorf("book: %w", context.DeadlineExceeded), false},
+		{"plain error", errors.New("could not serialize access"), false},
+	} {
+		if got := IsSerializationFailure(tt.err); got != tt.want {
+			t.Errorf("%s: IsSerializationFailure = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
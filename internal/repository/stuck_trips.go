@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// ─── Stuck trip reconciliation ──────────────────────────────
+
+// MaxStuckTripsPerSweep bounds how many trips one ReconcileStuckTrips call
+// cancels; the rest wait for the next sweep.
+const MaxStuckTripsPerSweep = 100
+
+// stuckTripPredicate matches a planned trip older than $N seconds with no
+// matched, confirmed or reserved (scheduled) rider on it. Such a trip
+// holds its cab but can never depart: a cancel path that missed the trip
+// left it behind.
+const stuckTripPredicate = `
+	t.status = 'planned'
+	AND t.created_at < NOW() - make_interval(secs => %s)
+	AND NOT EXISTS (
+		SELECT 1 FROM ride_requests rr
+		WHERE rr.trip_id = t.id AND rr.status IN ('matched', 'confirmed', 'scheduled')
+	)`
+
+// ReconcileStuckTrips cancels planned trips older than olderThan that have
+// no matched, confirmed or reserved rider, freeing their cabs (see
+// CancelTrip), and returns what it cancelled. Each trip is re-checked and cancelled in its
+// own transaction, so a trip that gains a rider after the scan is left
+// alone and one failure does not undo the others; on error the trips
+// cancelled so far are still returned.
+//
+// Uses idx_trips_status_created for the scan.
+func (r *BookingRepository) ReconcileStuckTrips(ctx context.Context, olderThan time.Duration) ([]TripCancelResult, error) {
+	ids, err := stuckTripIDs(ctx, r.pool, olderThan, MaxStuckTripsPerSweep)
+	if err != nil {
+		return nil, err
+	}
+
+	fixed := []TripCancelResult{}
+	for _, id := range ids {
+		result, err := r.reconcileStuckTrip(ctx, id, olderThan)
+		if err != nil {
+			return fixed, err
+		}
+		if result != nil {
+			fixed = append(fixed, *result)
+		}
+	}
+	return fixed, nil
+}
+
+// reconcileStuckTrip runs reconcileTrip for tripID in its own transaction.
+func (r *BookingRepository) reconcileStuckTrip(ctx context.Context, tripID int64, olderThan time.Duration) (*TripCancelResult, error) {
+	txCtx, cancel := context.WithTimeout(ctx, DefaultBookingTimeout)
+	defer cancel()
+
+	tx, err := r.pool.BeginTx(txCtx, pgx.TxOptions{IsoLevel: pgx.ReadCommitted})
+	if err != nil {
+		return nil, fmt.Errorf("reconcile trip %d: begin tx: %w", tripID, err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := setLockTimeout(txCtx, tx); err != nil {
+		return nil, fmt.Errorf("reconcile trip %d: %w", tripID, err)
+	}
+
+	result, err := reconcileTrip(txCtx, tx, tripID, olderThan)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("reconcile trip %d: commit: %w", tripID, err)
+	}
+	return result, nil
+}
+
+// stuckTripIDs returns up to limit stuck trips, oldest first.
+func stuckTripIDs(ctx context.Context, db rowsQuerier, olderThan time.Duration, limit int) ([]int64, error) {
+	rows, err := db.Query(ctx, `
+		SELECT t.id FROM trips t
+		WHERE `+fmt.Sprintf(stuckTripPredicate, "$1")+`
+		ORDER BY t.created_at, t.id
+		LIMIT $2
+	`, olderThan.Seconds(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("find stuck trips: %w", err)
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int64])
+	if err != nil {
+		return nil, fmt.Errorf("find stuck trips: %w", err)
+	}
+	return ids, nil
+}
+
+// reconcileTrip cancels tripID inside tx if it is still stuck, and returns
+// nil if it no longer is (a rider joined, or it was started or cancelled).
+// Locks the cab and then the trip, the order BookRide and CancelTrip use,
+// before re-checking.
+func reconcileTrip(ctx context.Context, tx pgx.Tx, tripID int64, olderThan time.Duration) (*TripCancelResult, error) {
+	var cabID int64
+	err := tx.QueryRow(ctx, `SELECT cab_id FROM trips WHERE id = $1`, tripID).Scan(&cabID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reconcile trip %d: lookup: %w", tripID, err)
+	}
+	if _, err := tx.Exec(ctx, `SELECT id FROM cabs WHERE id = $1 FOR UPDATE`, cabID); err != nil {
+		return nil, fmt.Errorf("reconcile trip %d: lock cab %d: %w", tripID, cabID, err)
+	}
+
+	var stuck bool
+	err = tx.QueryRow(ctx, `
+		SELECT `+fmt.Sprintf(stuckTripPredicate, "$2")+`
+		FROM trips t WHERE t.id = $1
+		FOR UPDATE
+	`, tripID, olderThan.Seconds()).Scan(&stuck)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reconcile trip %d: lock trip: %w", tripID, err)
+	}
+	if !stuck {
+		return nil, nil
+	}
+	return cancelTrip(ctx, tx, tripID)
+}
@@ -0,0 +1,87 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// ageTrip backdates a trip's created_at by age.
+func ageTrip(t *testing.T, ctx context.Context, tx pgx.Tx, tripID int64, age time.Duration) {
+	t.Helper()
+	if _, err := tx.Exec(ctx, `UPDATE trips SET created_at = NOW() - make_interval(secs => $2) WHERE id = $1`,
+		tripID, age.Seconds()); err != nil {
+		t.Fatalf("age trip: %v", err)
+	}
+}
+
+func TestReconcileTrip_CancelsStuckTripAndFreesCab(t *testing.T) {
+	ctx, tx := integrationTx(t)
+
+	// Stuck: its only rider was cancelled without the trip being cleaned
+	// up, so passenger_count still says 1.
+	stuck := seedCandidateTrip(t, ctx, tx, "STUCK-1", model.Location{Lat: 10.0000, Lon: 70.0000})
+	if _, err := tx.Exec(ctx, `UPDATE ride_requests SET status = 'cancelled' WHERE trip_id = $1`, stuck); err != nil {
+		t.Fatalf("cancel rider: %v", err)
+	}
+	ageTrip(t, ctx, tx, stuck, 2*time.Hour)
+
+	// Not stuck: an old trip with a matched rider, and a fresh empty one.
+	active := seedCandidateTrip(t, ctx, tx, "STUCK-ACTIVE", model.Location{Lat: 10.0010, Lon: 70.0010})
+	ageTrip(t, ctx, tx, active, 2*time.Hour)
+	fresh := seedCandidateTrip(t, ctx, tx, "STUCK-FRESH")
+
+	ids, err := stuckTripIDs(ctx, tx, time.Hour, 1000)
+	if err != nil {
+		t.Fatalf("stuckTripIDs: %v", err)
+	}
+	if !slices.Contains(ids, stuck) || slices.Contains(ids, active) || slices.Contains(ids, fresh) {
+		t.Errorf("stuck trips = %v, want %d and neither %d nor %d", ids, stuck, active, fresh)
+	}
+
+	for _, id := range []int64{stuck, active, fresh} {
+		result, err := reconcileTrip(ctx, tx, id, time.Hour)
+		if err != nil {
+			t.Fatalf("reconcileTrip(%d): %v", id, err)
+		}
+		if (result != nil) != (id == stuck) {
+			t.Errorf("reconcileTrip(%d) = %+v, want a result only for the stuck trip %d", id, result, stuck)
+		}
+		if result != nil && !result.CabFreed {
+			t.Errorf("stuck trip result = %+v, want its cab freed", result)
+		}
+	}
+
+	for _, tc := range []struct {
+		trip       int64
+		tripStatus model.TripStatus
+		cabStatus  model.CabStatus
+	}{
+		{stuck, model.TripCancelled, model.CabAvailable},
+		{active, model.TripPlanned, model.CabEnRoute},
+		{fresh, model.TripPlanned, model.CabEnRoute},
+	} {
+		var tripStatus model.TripStatus
+		var cabStatus model.CabStatus
+		if err := tx.QueryRow(ctx, `
+			SELECT t.status, c.status FROM trips t JOIN cabs c ON c.id = t.cab_id WHERE t.id = $1
+		`, tc.trip).Scan(&tripStatus, &cabStatus); err != nil {
+			t.Fatalf("read trip %d: %v", tc.trip, err)
+		}
+		if tripStatus != tc.tripStatus || cabStatus != tc.cabStatus {
+			t.Errorf("trip %d: %s with cab %s, want %s with cab %s", tc.trip, tripStatus, cabStatus, tc.tripStatus, tc.cabStatus)
+		}
+	}
+
+	// Reconciled once, it is no longer stuck.
+	if result, err := reconcileTrip(ctx, tx, stuck, time.Hour); err != nil || result != nil {
+		t.Errorf("second reconcile = %+v, %v; want nothing to do", result, err)
+	}
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/cache"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+// ErrSurgeCountersNotSynced is returned by SurgeCounters.Read before the
+// first reconciliation has written the counters (or after Redis lost them),
+// when a missing counter can't be told apart from a zero one.
+var ErrSurgeCountersNotSynced = errors.New("surge counters not reconciled yet")
+
+// SurgeCounters keeps live demand and supply counts per surge cell in
+// Redis, so a surge read is a single MGET instead of a PostGIS count.
+//
+// Writers adjust the counters with INCRBY on every change of request or
+// cab status, and as available cabs drive between cells (see
+// SurgeChange); INCRBY is atomic, so concurrent writers never lose an
+// update. What no writer reports — a cab going quiet past CabStaleAfter
+// or coming back, a failed INCRBY — leaves the counters drifting until
+// Reconcile rewrites them from the database.
+type SurgeCounters struct {
+	pool  *pgxpool.Pool
+	redis surgeCounterStore
+	keys  cache.Namespace
+
+	// CabStaleAfter, when positive, leaves cabs whose last location push is
+	// older than this out of reconciled supply, as in PricingRepository.
+	CabStaleAfter time.Duration
+}
+
+// surgeCounterStore is the part of *redis.Client the surge counters use.
+type surgeCounterStore interface {
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	MSet(ctx context.Context, values ...interface{}) *redis.StatusCmd
+	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
+}
+
+// NewSurgeCounters creates counters written under keys (REDIS_KEY_PREFIX).
+// redis is normally a *redis.Client behind a cache.Guarded circuit breaker.
+func NewSurgeCounters(pool *pgxpool.Pool, redis surgeCounterStore, keys cache.Namespace) *SurgeCounters {
+	return &SurgeCounters{pool: pool, redis: redis, keys: keys}
+}
+
+// demandKey returns the demand counter for cell; direction "" is the
+// combined count of both directions.
+func (c *SurgeCounters) demandKey(cell string, direction model.TripDirection) string {
+	if direction != "" {
+		return c.keys.Key("surge", "count", "demand", string(direction), cell)
+	}
+	return c.keys.Key("surge", "count", "demand", cell)
+}
+
+func (c *SurgeCounters) supplyKey(cell string) string {
+	return c.keys.Key("surge", "count", "supply", cell)
+}
+
+// cellsKey is the set of every cell that has had a counter written, so
+// Reconcile can zero the ones the database no longer has anything in.
+func (c *SurgeCounters) cellsKey() string { return c.keys.Key("surge", "count", "cells") }
+
+// syncedKey marks that Reconcile has run against this Redis.
+func (c *SurgeCounters) syncedKey() string { return c.keys.Key("surge", "count", "synced") }
+
+// AddDemand adds delta pending requests going in direction to the cell
+// containing loc. Errors are dropped: Reconcile corrects the count.
+func (c *SurgeCounters) AddDemand(ctx context.Context, loc model.Location, direction model.TripDirection, delta int) {
+	cell := geohashKey(loc)
+	_ = c.redis.SAdd(ctx, c.cellsKey(), cell).Err()
+	_ = c.redis.IncrBy(ctx, c.demandKey(cell, ""), int64(delta)).Err()
+	if direction != "" {
+		_ = c.redis.IncrBy(ctx, c.demandKey(cell, direction), int64(delta)).Err()
+	}
+}
+
+// AddSupply adds delta available cabs to the cell containing loc. Errors
+// are dropped: Reconcile corrects the count.
+func (c *SurgeCounters) AddSupply(ctx context.Context, loc model.Location, delta int) {
+	cell := geohashKey(loc)
+	_ = c.redis.SAdd(ctx, c.cellsKey(), cell).Err()
+	_ = c.redis.IncrBy(ctx, c.supplyKey(cell), int64(delta)).Err()
+}
+
+// SurgeChange is one change a committed transaction made to what the surge
+// counters count at Location: Demand pending requests going Direction,
+// and Supply available cabs. Negative values take away. Repository
+// results carry them so the caller can report them once the transaction
+// has committed.
+type SurgeChange struct {
+	Location  model.Location
+	Direction model.TripDirection
+	Demand    int
+	Supply    int
+}
+
+// demandChange is delta pending requests going direction at loc.
+func demandChange(loc model.Location, direction model.TripDirection, delta int) SurgeChange {
+	return SurgeChange{Location: loc, Direction: direction, Demand: delta}
+}
+
+// cabSupplyChange is a cab entering (delta 1) or leaving (delta -1)
+// 'available' at lon/lat, as scanned from ST_X/ST_Y of its
+// current_location. A cab with no location was never counted, so it
+// yields no change.
+func cabSupplyChange(lon, lat *float64, delta int) []SurgeChange {
+	if lon == nil || lat == nil {
+		return nil
+	}
+	return []SurgeChange{{Location: model.Location{Lat: *lat, Lon: *lon}, Supply: delta}}
+}
+
+// cabMoveChange moves an available cab's supply from its old location
+// (ST_X/ST_Y, nil if it had none) to to. It is nothing while the cab stays
+// in the same cell.
+func cabMoveChange(oldLon, oldLat *float64, to model.Location) []SurgeChange {
+	if oldLon != nil && oldLat != nil && geohashKey(model.Location{Lat: *oldLat, Lon: *oldLon}) == geohashKey(to) {
+		return nil
+	}
+	return append(cabSupplyChange(oldLon, oldLat, -1), SurgeChange{Location: to, Supply: 1})
+}
+
+// Read returns the counted demand (for direction, or both when it is
+// empty) and supply within radiusMeters of loc: the sum over every cell
+// that overlaps the circle (see cellsWithin), so cells straddling its edge
+// count whole. Ratio is left for the caller to set. A counter that raced
+// below zero against Reconcile reads as zero.
+func (c *SurgeCounters) Read(ctx context.Context, loc model.Location, radiusMeters int, direction model.TripDirection) (*DemandSupply, error) {
+	cells := cellsWithin(loc, radiusMeters)
+	keys := make([]string, 0, 1+2*len(cells))
+	keys = append(keys, c.syncedKey())
+	for _, cell := range cells {
+		keys = append(keys, c.demandKey(cell, direction), c.supplyKey(cell))
+	}
+
+	vals, err := c.redis.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("surge counters: read %d cells around %s: %w", len(cells), geohashKey(loc), err)
+	}
+	if len(vals) != len(keys) || vals[0] == nil {
+		return nil, ErrSurgeCountersNotSynced
+	}
+	ds := &DemandSupply{}
+	for i, cell := range cells {
+		demand, err := counterValue(vals[1+2*i])
+		if err != nil {
+			return nil, fmt.Errorf("surge counters: demand %s: %w", cell, err)
+		}
+		supply, err := counterValue(vals[2+2*i])
+		if err != nil {
+			return nil, fmt.Errorf("surge counters: supply %s: %w", cell, err)
+		}
+		ds.Demand += demand
+		ds.Supply += supply
+	}
+	return ds, nil
+}
+
+// surgeCellDeg is the side of a surge cell in degrees: geohashKey rounds
+// to two decimals, so cell (i, j) is centred on (i/100, j/100).
+const surgeCellDeg = 0.01
+
+// metersPerDegreeLat is the length of one degree of latitude.
+const metersPerDegreeLat = 111_320.0
+
+// cellsWithin returns the keys of every surge cell with some point within
+// radiusMeters of loc, starting with loc's own cell. A radius of 0 is just
+// loc's cell.
+func cellsWithin(loc model.Location, radiusMeters int) []string {
+	own := geohashKey(loc)
+	cells := []string{own}
+
+	r := float64(max(radiusMeters, 0))
+	dLat := r / metersPerDegreeLat
+	dLon := dLat / math.Max(math.Cos(loc.Lat*math.Pi/180), 0.01)
+	clamp := func(v, centre float64) float64 {
+		return math.Min(math.Max(v, centre-surgeCellDeg/2), centre+surgeCellDeg/2)
+	}
+	for i := math.Round((loc.Lat - dLat) / surgeCellDeg); i <= math.Round((loc.Lat+dLat)/surgeCellDeg); i++ {
+		for j := math.Round((loc.Lon - dLon) / surgeCellDeg); j <= math.Round((loc.Lon+dLon)/surgeCellDeg); j++ {
+			centre := model.Location{Lat: i * surgeCellDeg, Lon: j * surgeCellDeg}
+			cell := geohashKey(centre)
+			if cell == own {
+				continue
+			}
+			nearest := model.Location{Lat: clamp(loc.Lat, centre.Lat), Lon: clamp(loc.Lon, centre.Lon)}
+			if geo.HaversineM(loc, nearest) <= r {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	return cells
+}
+
+// counterValue parses an MGET result; a missing key is zero.
+func counterValue(v interface{}) (int, error) {
+	if v == nil {
+		return 0, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("unexpected value %v", v)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, err
+	}
+	return max(n, 0), nil
+}
+
+// surgeSnapshot is the database's view of the counters, keyed like Redis.
+type surgeSnapshot struct {
+	cells  map[string]bool
+	counts map[string]int
+}
+
+// count adds one pending request going in direction, or with supply set
+// one available cab, at loc to s.
+func (c *SurgeCounters) count(s *surgeSnapshot, loc model.Location, direction model.TripDirection, supply bool) {
+	cell := geohashKey(loc)
+	s.cells[cell] = true
+	if supply {
+		s.counts[c.supplyKey(cell)]++
+		return
+	}
+	s.counts[c.demandKey(cell, "")]++
+	s.counts[c.demandKey(cell, direction)]++
+}
+
+// Reconcile rewrites every counter from the database — pending requests
+// and available, non-deleted, recently seen cabs — and returns how many
+// cells it wrote. Cells the counters know of but the database has nothing
+// in are set to zero. Increments landing while Reconcile runs may be
+// overwritten; the next run picks them up.
+func (c *SurgeCounters) Reconcile(ctx context.Context) (int, error) {
+	snap, err := c.snapshot(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return c.write(ctx, snap)
+}
+
+// write sets every counter of every cell in snap, or already known to
+// Redis, to its count in snap, and marks the counters synced.
+func (c *SurgeCounters) write(ctx context.Context, snap *surgeSnapshot) (int, error) {
+	known, err := c.redis.SMembers(ctx, c.cellsKey()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("surge counters: list cells: %w", err)
+	}
+	for _, cell := range known {
+		snap.cells[cell] = true
+	}
+
+	values := []interface{}{c.syncedKey(), time.Now().UTC().Format(time.RFC3339)}
+	members := make([]interface{}, 0, len(snap.cells))
+	for cell := range snap.cells {
+		keys := []string{c.demandKey(cell, ""), c.supplyKey(cell)}
+		for _, d := range model.TripDirections {
+			keys = append(keys, c.demandKey(cell, d))
+		}
+		for _, k := range keys {
+			values = append(values, k, snap.counts[k])
+		}
+		members = append(members, cell)
+	}
+	if len(members) > 0 {
+		if err := c.redis.SAdd(ctx, c.cellsKey(), members...).Err(); err != nil {
+			return 0, fmt.Errorf("surge counters: record cells: %w", err)
+		}
+	}
+	if err := c.redis.MSet(ctx, values...).Err(); err != nil {
+		return 0, fmt.Errorf("surge counters: write: %w", err)
+	}
+	return len(members), nil
+}
+
+// snapshot counts pending requests and available cabs per cell. Bucketing
+// happens here rather than in SQL so cells are cut exactly as geohashKey
+// cuts them for the writers.
+func (c *SurgeCounters) snapshot(ctx context.Context) (*surgeSnapshot, error) {
+	snap := &surgeSnapshot{cells: map[string]bool{}, counts: map[string]int{}}
+
+	rows, err := c.pool.Query(ctx, `
+		SELECT ST_Y(origin), ST_X(origin), direction::text
+		FROM ride_requests
+		WHERE status = 'pending'
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("surge counters: query demand: %w", err)
+	}
+	for rows.Next() {
+		var (
+			loc model.Location
+			dir model.TripDirection
+		)
+		if err := rows.Scan(&loc.Lat, &loc.Lon, &dir); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("surge counters: scan demand: %w", err)
+		}
+		c.count(snap, loc, dir, false)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("surge counters: query demand: %w", err)
+	}
+
+	rows, err = c.pool.Query(ctx, `
+		SELECT ST_Y(current_location), ST_X(current_location)
+		FROM cabs
+		WHERE status = 'available'
+		  AND deleted_at IS NULL
+		  AND current_location IS NOT NULL
+		  AND `+fmt.Sprintf(cabSeenWithin, "$1"),
+		c.CabStaleAfter.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("surge counters: query supply: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var loc model.Location
+		if err := rows.Scan(&loc.Lat, &loc.Lon); err != nil {
+			return nil, fmt.Errorf("surge counters: scan supply: %w", err)
+		}
+		c.count(snap, loc, "", true)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("surge counters: query supply: %w", err)
+	}
+	return snap, nil
+}
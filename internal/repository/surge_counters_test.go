@@ -0,0 +1,285 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// ─── fakeRedis: counters and sets ───────────────────────────
+
+func (f *fakeRedis) IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd {
+	n, _ := strconv.ParseInt(f.vals[key], 10, 64)
+	n += value
+	f.vals[key] = strconv.FormatInt(n, 10)
+	return redis.NewIntResult(n, nil)
+}
+
+func (f *fakeRedis) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	vals := make([]interface{}, len(keys))
+	for i, k := range keys {
+		if v, ok := f.vals[k]; ok {
+			vals[i] = v
+		}
+	}
+	return redis.NewSliceResult(vals, nil)
+}
+
+func (f *fakeRedis) MSet(ctx context.Context, values ...interface{}) *redis.StatusCmd {
+	for i := 0; i+1 < len(values); i += 2 {
+		f.vals[fmt.Sprint(values[i])] = fmt.Sprint(values[i+1])
+	}
+	return redis.NewStatusResult("OK", nil)
+}
+
+func (f *fakeRedis) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	if f.sets == nil {
+		f.sets = map[string]map[string]bool{}
+	}
+	if f.sets[key] == nil {
+		f.sets[key] = map[string]bool{}
+	}
+	for _, m := range members {
+		f.sets[key][fmt.Sprint(m)] = true
+	}
+	return redis.NewIntResult(int64(len(members)), nil)
+}
+
+func (f *fakeRedis) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	var members []string
+	for m := range f.sets[key] {
+		members = append(members, m)
+	}
+	return redis.NewStringSliceResult(members, nil)
+}
+
+// lockedRedis serializes a fakeRedis, standing in for Redis running each
+// command atomically.
+type lockedRedis struct {
+	mu sync.Mutex
+	*fakeRedis
+}
+
+func (l *lockedRedis) IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fakeRedis.IncrBy(ctx, key, value)
+}
+
+func (l *lockedRedis) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fakeRedis.SAdd(ctx, key, members...)
+}
+
+// ─── Tests ──────────────────────────────────────────────────
+
+func TestSurgeCounters_ReadBeforeReconcileIsNotSynced(t *testing.T) {
+	c := NewSurgeCounters(nil, newFakeRedis(), "prod")
+	c.AddDemand(context.Background(), surgeProbe, model.DirectionToAirport, 1)
+
+	if _, err := c.Read(context.Background(), surgeProbe, 0, ""); !errors.Is(err, ErrSurgeCountersNotSynced) {
+		t.Errorf("read before reconcile: err = %v, want ErrSurgeCountersNotSynced", err)
+	}
+}
+
+func TestSurgeCounters_AddDemandCountsCombinedAndDirection(t *testing.T) {
+	rdb := newFakeRedis()
+	c := NewSurgeCounters(nil, rdb, "prod")
+	ctx := context.Background()
+	if _, err := c.write(ctx, &surgeSnapshot{cells: map[string]bool{}, counts: map[string]int{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.AddDemand(ctx, surgeProbe, model.DirectionToAirport, 1)
+	c.AddDemand(ctx, surgeProbe, model.DirectionToAirport, 1)
+	c.AddDemand(ctx, surgeProbe, model.DirectionFromAirport, 1)
+	c.AddSupply(ctx, surgeProbe, 2)
+	c.AddDemand(ctx, surgeProbe, model.DirectionFromAirport, -1) // cancelled
+
+	if rdb.vals["prod:surge:count:demand:28.70:77.10"] != "2" || rdb.vals["prod:surge:count:demand:to_airport:28.70:77.10"] != "2" {
+		t.Errorf("counters = %v, want combined and to_airport demand of 2", rdb.vals)
+	}
+	for dir, want := range map[model.TripDirection]int{"": 2, model.DirectionToAirport: 2, model.DirectionFromAirport: 0} {
+		ds, err := c.Read(ctx, surgeProbe, 0, dir)
+		if err != nil {
+			t.Fatalf("direction %q: %v", dir, err)
+		}
+		if ds.Demand != want || ds.Supply != 2 {
+			t.Errorf("direction %q: got %+v, want demand %d, supply 2", dir, ds, want)
+		}
+	}
+}
+
+func TestSurgeCounters_NegativeDriftReadsAsZero(t *testing.T) {
+	c := NewSurgeCounters(nil, newFakeRedis(), "")
+	ctx := context.Background()
+	if _, err := c.write(ctx, &surgeSnapshot{cells: map[string]bool{}, counts: map[string]int{}}); err != nil {
+		t.Fatal(err)
+	}
+
+	c.AddDemand(ctx, surgeProbe, model.DirectionToAirport, -1)
+	c.AddSupply(ctx, surgeProbe, -1)
+
+	ds, err := c.Read(ctx, surgeProbe, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Demand != 0 || ds.Supply != 0 {
+		t.Errorf("got %+v, want counts below zero read as 0", ds)
+	}
+}
+
+func TestSurgeCounters_ConcurrentIncrementsAreNotLost(t *testing.T) {
+	rdb := &lockedRedis{fakeRedis: newFakeRedis()}
+	c := NewSurgeCounters(nil, rdb, "")
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.AddDemand(context.Background(), surgeProbe, model.DirectionToAirport, 1)
+		}()
+	}
+	wg.Wait()
+
+	if got := rdb.vals["surge:count:demand:28.70:77.10"]; got != "50" {
+		t.Errorf("demand after 50 concurrent creates = %s, want 50", got)
+	}
+}
+
+func TestSurgeCounters_WriteCorrectsDriftAndZeroesEmptiedCells(t *testing.T) {
+	rdb := newFakeRedis()
+	c := NewSurgeCounters(nil, rdb, "prod")
+	ctx := context.Background()
+	emptied := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	// Drifted counters: demand over-counted here, a cell whose riders all
+	// expired without a decrement.
+	c.AddDemand(ctx, surgeProbe, model.DirectionToAirport, 5)
+	c.AddDemand(ctx, emptied, model.DirectionFromAirport, 3)
+
+	snap := &surgeSnapshot{cells: map[string]bool{}, counts: map[string]int{}}
+	c.count(snap, surgeProbe, model.DirectionToAirport, false)
+	c.count(snap, surgeProbe, model.DirectionFromAirport, false)
+	c.count(snap, surgeProbe, "", true)
+	cells, err := c.write(ctx, snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cells != 2 {
+		t.Errorf("cells written = %d, want 2 (the probe and the emptied cell)", cells)
+	}
+
+	ds, err := c.Read(ctx, surgeProbe, 0, model.DirectionToAirport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Demand != 1 || ds.Supply != 1 {
+		t.Errorf("probe to_airport after reconcile = %+v, want 1/1", ds)
+	}
+	if ds, _ := c.Read(ctx, surgeProbe, 0, ""); ds.Demand != 2 {
+		t.Errorf("probe combined demand after reconcile = %d, want 2", ds.Demand)
+	}
+	if ds, _ := c.Read(ctx, emptied, 0, ""); ds.Demand != 0 || ds.Supply != 0 {
+		t.Errorf("emptied cell after reconcile = %+v, want 0/0", ds)
+	}
+}
+
+func TestGetDemandSupply_ServedFromCounters(t *testing.T) {
+	counters := NewSurgeCounters(nil, newFakeRedis(), "prod")
+	ctx := context.Background()
+	if _, err := counters.write(ctx, &surgeSnapshot{cells: map[string]bool{}, counts: map[string]int{}}); err != nil {
+		t.Fatal(err)
+	}
+	counters.AddDemand(ctx, surgeProbe, model.DirectionToAirport, 4)
+	counters.AddSupply(ctx, surgeProbe, 1)
+
+	// r.db is nil and the TTL cache is empty: only the counters can answer.
+	r := &PricingRepository{redis: newFakeRedis(), cacheTTL: time.Minute, MinSupply: 2, Counters: counters}
+	ds, err := r.GetDemandSupply(ctx, surgeProbe, 3000, model.DirectionToAirport)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds.Demand != 4 || ds.Supply != 1 || ds.Ratio != 2 {
+		t.Errorf("got %+v, want 4/1 with ratio 2 under MinSupply 2", ds)
+	}
+}
+
+func TestSurgeCounters_ReadSumsCellsWithinRadius(t *testing.T) {
+	c := NewSurgeCounters(nil, newFakeRedis(), "prod")
+	ctx := context.Background()
+	if _, err := c.write(ctx, &surgeSnapshot{cells: map[string]bool{}, counts: map[string]int{}}); err != nil {
+		t.Fatal(err)
+	}
+	north := func(m float64) model.Location {
+		return model.Location{Lat: surgeProbe.Lat + m/metersPerDegreeLat, Lon: surgeProbe.Lon}
+	}
+
+	c.AddDemand(ctx, surgeProbe, model.DirectionToAirport, 1)
+	c.AddDemand(ctx, north(1500), model.DirectionToAirport, 2) // next cell but one
+	c.AddSupply(ctx, north(1500), 1)
+	c.AddDemand(ctx, north(5000), model.DirectionToAirport, 4)
+
+	for _, tt := range []struct {
+		radius                 int
+		wantDemand, wantSupply int
+	}{
+		{0, 1, 0},
+		{1000, 1, 0},
+		{2000, 3, 1},
+		{6000, 7, 1},
+	} {
+		ds, err := c.Read(ctx, surgeProbe, tt.radius, model.DirectionToAirport)
+		if err != nil {
+			t.Fatalf("radius %d: %v", tt.radius, err)
+		}
+		if ds.Demand != tt.wantDemand || ds.Supply != tt.wantSupply {
+			t.Errorf("radius %d: got %+v, want demand %d, supply %d", tt.radius, ds, tt.wantDemand, tt.wantSupply)
+		}
+	}
+}
+
+func TestCellsWithin_CoversOwnCellOnce(t *testing.T) {
+	cells := cellsWithin(surgeProbe, 3000)
+	seen := map[string]bool{}
+	for _, cell := range cells {
+		if seen[cell] {
+			t.Errorf("cell %s listed twice", cell)
+		}
+		seen[cell] = true
+	}
+	if cells[0] != geohashKey(surgeProbe) {
+		t.Errorf("first cell = %s, want the probe's own %s", cells[0], geohashKey(surgeProbe))
+	}
+	// ~3 km is about 2.7 cells of latitude each way: a disc, not a single cell.
+	if len(cells) < 20 {
+		t.Errorf("%d cells within 3 km, want the neighbouring cells too", len(cells))
+	}
+}
+
+func TestCabMoveChange(t *testing.T) {
+	lon, lat := surgeProbe.Lon, surgeProbe.Lat
+	sameCell := model.Location{Lat: lat + 0.0005, Lon: lon}
+	nextCell := model.Location{Lat: lat + 0.02, Lon: lon}
+
+	if got := cabMoveChange(&lon, &lat, sameCell); got != nil {
+		t.Errorf("move within the cell = %+v, want no change", got)
+	}
+	got := cabMoveChange(&lon, &lat, nextCell)
+	if len(got) != 2 || got[0].Supply != -1 || got[0].Location != surgeProbe || got[1].Supply != 1 || got[1].Location != nextCell {
+		t.Errorf("move to the next cell = %+v, want -1 at the probe and +1 at the new fix", got)
+	}
+	if got := cabMoveChange(nil, nil, nextCell); len(got) != 1 || got[0].Supply != 1 {
+		t.Errorf("first fix = %+v, want +1 at the new fix", got)
+	}
+}
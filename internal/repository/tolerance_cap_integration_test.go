@@ -0,0 +1,36 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestRideRequests_ToleranceCheckCapsAt7500(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "TOL-CAP", model.Location{Lat: 10.0050, Lon: 70.0000})
+
+	insert := func(tolerance int) error {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO ride_requests (user_id, origin, destination, direction, tolerance_meters)
+			SELECT user_id, origin, destination, direction, $2
+			FROM ride_requests WHERE trip_id = $1
+			LIMIT 1
+		`, tripID, tolerance)
+		return err
+	}
+
+	if err := insert(7500); err != nil {
+		t.Fatalf("insert at the cap: %v", err)
+	}
+	// The failed insert aborts the transaction, so it goes last.
+	var pgErr *pgconn.PgError
+	if err := insert(7501); !errors.As(err, &pgErr) || pgErr.Code != "23514" {
+		t.Errorf("insert above the cap: err = %v, want a check violation (23514)", err)
+	}
+}
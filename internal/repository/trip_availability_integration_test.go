@@ -0,0 +1,108 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestTripAvailability_PartiallyBooked(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "AVAIL-1", soloOrigin, sharedOrigin)
+	// A cancelled rider no longer takes up a seat.
+	if _, err := tx.Exec(ctx, `
+		UPDATE ride_requests SET status = 'cancelled'
+		WHERE id = (SELECT MAX(id) FROM ride_requests WHERE trip_id = $1)
+	`, tripID); err != nil {
+		t.Fatalf("cancel rider: %v", err)
+	}
+	if _, err := tx.Exec(ctx, `UPDATE ride_requests SET luggage_count = 2 WHERE trip_id = $1 AND status = 'matched'`, tripID); err != nil {
+		t.Fatalf("set luggage: %v", err)
+	}
+
+	a, err := tripAvailability(ctx, tx, tripID, false)
+	if err != nil {
+		t.Fatalf("tripAvailability: %v", err)
+	}
+	if !a.Bookable || a.UsedSeats != 1 || a.UsedLuggage != 2 {
+		t.Errorf("availability = %+v, want bookable with 1 seat and 2 bags used", a)
+	}
+	wantSeats, wantBags := model.CabCapacity{Seats: a.SeatCapacity, Luggage: a.LuggageCapacity}.Remaining(1, 2)
+	if a.RemainingSeats != wantSeats || a.RemainingLuggage != wantBags {
+		t.Errorf("remaining %d seats, %d bags; want %d, %d", a.RemainingSeats, a.RemainingLuggage, wantSeats, wantBags)
+	}
+}
+
+func TestTripAvailability_LuggageUsesSeats(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "AVAIL-3", soloOrigin)
+
+	plain, err := tripAvailability(ctx, tx, tripID, false)
+	if err != nil {
+		t.Fatalf("tripAvailability: %v", err)
+	}
+	spill, err := tripAvailability(ctx, tx, tripID, true)
+	if err != nil {
+		t.Fatalf("tripAvailability: %v", err)
+	}
+	capacity := model.CabCapacity{Seats: spill.SeatCapacity, Luggage: spill.LuggageCapacity, Flex: spill.FlexCapacity, LuggageUsesSeats: true}
+	wantSeats, wantBags := capacity.Remaining(spill.UsedSeats, spill.UsedLuggage)
+	if spill.RemainingSeats != wantSeats || spill.RemainingLuggage != wantBags {
+		t.Errorf("remaining %d seats, %d bags; want %d, %d", spill.RemainingSeats, spill.RemainingLuggage, wantSeats, wantBags)
+	}
+	if spill.RemainingLuggage <= plain.RemainingLuggage {
+		t.Errorf("remaining bags %d with seats for bags, want more than %d without", spill.RemainingLuggage, plain.RemainingLuggage)
+	}
+}
+
+func TestTripAvailability_ScheduledReservationHoldsSeats(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "AVAIL-2", soloOrigin)
+	before, err := tripAvailability(ctx, tx, tripID, false)
+	if err != nil {
+		t.Fatalf("tripAvailability: %v", err)
+	}
+
+	// A scheduled rider BookRide reserved: still 'scheduled', but on the trip.
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO ride_requests (user_id, origin, destination, direction, seats_needed, luggage_count,
+		                           status, scheduled_at, trip_id)
+		SELECT user_id, origin, destination, direction, 2, 1, 'scheduled', NOW() + INTERVAL '3 hours', trip_id
+		FROM ride_requests WHERE trip_id = $1
+	`, tripID); err != nil {
+		t.Fatalf("seed reservation: %v", err)
+	}
+
+	after, err := tripAvailability(ctx, tx, tripID, false)
+	if err != nil {
+		t.Fatalf("tripAvailability: %v", err)
+	}
+	if after.UsedSeats != before.UsedSeats+2 || after.UsedLuggage != before.UsedLuggage+1 {
+		t.Errorf("used %d seats, %d bags; want %d, %d", after.UsedSeats, after.UsedLuggage, before.UsedSeats+2, before.UsedLuggage+1)
+	}
+	if after.RemainingSeats >= before.RemainingSeats {
+		t.Errorf("remaining seats %d, want fewer than %d before the reservation", after.RemainingSeats, before.RemainingSeats)
+	}
+	if ct := candidateByID(t, ctx, tx, tripID); ct.CurrentLoad != after.UsedSeats {
+		t.Errorf("candidate load = %d, want %d: matching must see the reservation too", ct.CurrentLoad, after.UsedSeats)
+	}
+
+	// A live rider who fitted before the reservation no longer does.
+	capacity := model.CabCapacity{Seats: after.SeatCapacity, Luggage: after.LuggageCapacity, Flex: after.FlexCapacity}
+	if err := capacity.Check(before.UsedSeats, before.UsedLuggage, before.RemainingSeats, 0); err != nil {
+		t.Fatalf("live rider should fit an unreserved trip: %v", err)
+	}
+	if err := capacity.Check(after.UsedSeats, after.UsedLuggage, before.RemainingSeats, 0); err == nil {
+		t.Error("live rider fits alongside the reservation, want a capacity error")
+	}
+}
+
+func TestTripAvailability_MissingTrip(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	if _, err := tripAvailability(ctx, tx, -1, false); !errors.Is(err, ErrTripNotFound) {
+		t.Errorf("err = %v, want ErrTripNotFound", err)
+	}
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// ─── Trip Passenger Manifest ────────────────────────────────
+
+// ManifestPassenger is one rider on a trip's manifest.
+type ManifestPassenger struct {
+	Stop               int                 `json:"stop"` // From 1: pickup order to the airport, drop-off order from it.
+	RequestID          int64               `json:"request_id"`
+	UserID             int64               `json:"user_id"`
+	Status             model.RequestStatus `json:"status"`
+	SeatsNeeded        int                 `json:"seats_needed"`
+	LuggageCount       int                 `json:"luggage_count"`
+	RequiresAccessible bool                `json:"requires_accessible,omitempty"`
+	Pickup             model.Location      `json:"pickup"`
+	Dropoff            model.Location      `json:"dropoff"`
+}
+
+// TripManifest is the passenger list a trip's driver works from: every
+// rider holding a seat, in the order the planned route reaches them.
+type TripManifest struct {
+	TripID       int64               `json:"trip_id"`
+	Direction    model.TripDirection `json:"direction"`
+	Status       model.TripStatus    `json:"status"`
+	TotalSeats   int                 `json:"total_seats"`
+	TotalLuggage int                 `json:"total_luggage"`
+	Passengers   []ManifestPassenger `json:"passengers"`
+}
+
+// GetTripManifest loads the manifest of a trip: its matched, confirmed and
+// reserved scheduled riders (the ones updateTripRoute plans for),
+// cancelled and completed riders left out. Returns an error wrapping
+// ErrTripNotFound if the trip does not exist.
+func (r *RideRequestRepository) GetTripManifest(ctx context.Context, tripID int64) (*TripManifest, error) {
+	m := &TripManifest{TripID: tripID, Passengers: []ManifestPassenger{}}
+	err := r.pool.QueryRow(ctx, `SELECT direction, status FROM trips WHERE id = $1`, tripID).Scan(&m.Direction, &m.Status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("get trip %d manifest: %w", tripID, ErrTripNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d manifest: %w", tripID, err)
+	}
+
+	rows, err := r.pool.Query(ctx, `
+		SELECT id, user_id, status, seats_needed, luggage_count, requires_accessible,
+		       ST_Y(origin), ST_X(origin), ST_Y(destination), ST_X(destination)
+		FROM ride_requests
+		WHERE trip_id = $1
+		  AND status IN ('matched', 'confirmed', 'scheduled')
+		ORDER BY created_at ASC, id ASC
+	`, tripID)
+	if err != nil {
+		return nil, fmt.Errorf("get trip %d manifest: %w", tripID, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p ManifestPassenger
+		if err := rows.Scan(
+			&p.RequestID, &p.UserID, &p.Status, &p.SeatsNeeded, &p.LuggageCount, &p.RequiresAccessible,
+			&p.Pickup.Lat, &p.Pickup.Lon, &p.Dropoff.Lat, &p.Dropoff.Lon,
+		); err != nil {
+			return nil, fmt.Errorf("scan manifest passenger: %w", err)
+		}
+		m.Passengers = append(m.Passengers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("get trip %d manifest: %w", tripID, err)
+	}
+
+	orderManifest(m)
+	return m, nil
+}
+
+// orderManifest sorts m's passengers by where planTripRoute puts them —
+// pickups for a to_airport trip, drop-offs for a from_airport one, since
+// everyone boards at the airport — numbers the stops from 1, and totals
+// seats and luggage. Riders sharing a stop share its number and keep
+// booking order.
+func orderManifest(m *TripManifest) {
+	riders := make([]model.RideRequest, len(m.Passengers))
+	for i, p := range m.Passengers {
+		riders[i] = model.RideRequest{Origin: p.Pickup, Destination: p.Dropoff}
+	}
+	route := planTripRoute(m.Direction, riders, model.Location{})
+	position := func(p ManifestPassenger) int {
+		if m.Direction == model.DirectionFromAirport {
+			return slices.Index(route, p.Dropoff)
+		}
+		return slices.Index(route, p.Pickup)
+	}
+	slices.SortStableFunc(m.Passengers, func(a, b ManifestPassenger) int { return position(a) - position(b) })
+
+	m.TotalSeats, m.TotalLuggage = 0, 0
+	stop, last := 0, -1
+	for i := range m.Passengers {
+		p := &m.Passengers[i]
+		if pos := position(*p); pos != last {
+			stop, last = stop+1, pos
+		}
+		p.Stop = stop
+		m.TotalSeats += p.SeatsNeeded
+		m.TotalLuggage += p.LuggageCount
+	}
+}
@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+// ─── Persisted Trip Route ───────────────────────────────────
+
+// updateTripRoute re-plans the trip's route from its matched, confirmed
+// and reserved scheduled riders and stores it in trips.route_path / total_distance_m. It runs
+// inside the caller's transaction, so the stored route always matches the
+// committed passenger list.
+func updateTripRoute(ctx context.Context, tx pgx.Tx, tripID int64, direction model.TripDirection, airport model.Location) error {
+	rows, err := tx.Query(ctx, `
+		SELECT ST_Y(origin), ST_X(origin), ST_Y(destination), ST_X(destination)
+		FROM ride_requests
+		WHERE trip_id = $1
+		  AND status IN ('matched', 'confirmed', 'scheduled')
+		ORDER BY created_at ASC, id ASC
+	`, tripID)
+	if err != nil {
+		return fmt.Errorf("trip %d route: query riders: %w", tripID, err)
+	}
+	riders, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (model.RideRequest, error) {
+		var rr model.RideRequest
+		err := row.Scan(&rr.Origin.Lat, &rr.Origin.Lon, &rr.Destination.Lat, &rr.Destination.Lon)
+		return rr, err
+	})
+	if err != nil {
+		return fmt.Errorf("trip %d route: scan riders: %w", tripID, err)
+	}
+
+	route := planTripRoute(direction, riders, airport)
+	if len(route) < 2 {
+		// A LINESTRING needs two points; with no riders there is no route.
+		_, err = tx.Exec(ctx, `
+			UPDATE trips SET route_path = NULL, total_distance_m = NULL WHERE id = $1
+		`, tripID)
+	} else {
+		_, err = tx.Exec(ctx, `
+			UPDATE trips
+			SET route_path = ST_GeomFromText($2, 4326), total_distance_m = $3
+			WHERE id = $1
+		`, tripID, lineStringWKT(route), int(math.Round(geo.RouteDistanceKm(route)*1000)))
+	}
+	if err != nil {
+		return fmt.Errorf("trip %d route: update: %w", tripID, err)
+	}
+	return nil
+}
+
+// planTripRoute orders a trip's stops with geo.PlanRoute. A to_airport
+// trip collects every pickup and ends at the airport; a from_airport trip
+// starts at the airport and visits every drop-off (planned in reverse, so
+// the airport is the fixed end). airport falls back to the first rider's
+// airport end when unset. Returns nil for no riders.
+func planTripRoute(direction model.TripDirection, riders []model.RideRequest, airport model.Location) []model.Location {
+	if len(riders) == 0 {
+		return nil
+	}
+	stops := make([]model.Location, len(riders))
+	for i, rr := range riders {
+		if direction == model.DirectionFromAirport {
+			stops[i] = rr.Destination
+		} else {
+			stops[i] = rr.Origin
+		}
+	}
+	if airport == (model.Location{}) {
+		airport = riders[0].Destination
+		if direction == model.DirectionFromAirport {
+			airport = riders[0].Origin
+		}
+	}
+
+	route := geo.PlanRoute(stops, airport)
+	if direction == model.DirectionFromAirport {
+		slices.Reverse(route)
+	}
+	return route
+}
+
+// routeStopSnapDeg is how close a stored route point must be to a rider's
+// stop to be that stop: ST_AsGeoJSON rounds to 9 decimal places.
+const routeStopSnapDeg = 1e-7
+
+// snapRouteStops matches route, a stored planTripRoute result, against
+// the riders' pickups (to_airport) or drop-offs (from_airport), replacing
+// each matching point with the rider's exact stop. It reports false if
+// some rider's stop is not on the route. An empty route fits no riders.
+func snapRouteStops(direction model.TripDirection, route []model.Location, riders []model.RideRequest) bool {
+	if len(route) == 0 {
+		return len(riders) == 0
+	}
+	near := func(a, b model.Location) bool {
+		return math.Abs(a.Lat-b.Lat) < routeStopSnapDeg && math.Abs(a.Lon-b.Lon) < routeStopSnapDeg
+	}
+	for _, rr := range riders {
+		stop := rr.Origin
+		if direction == model.DirectionFromAirport {
+			stop = rr.Destination
+		}
+		found := false
+		for i := range route {
+			if near(route[i], stop) {
+				route[i], found = stop, true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// checkMergeDetours plans the merged route for into and from and compares
+// each rider's time in the cab — pickup to airport, or airport to drop-off
+// — with the time on their current trip's route. The increase must stay
+// within the rider's tolerance_meters, converted to minutes at
+// geo.AverageSpeedKmph as matching does.
+func checkMergeDetours(direction model.TripDirection, into, from []model.RideRequest, airport model.Location) error {
+	merged := planTripRoute(direction, append(slices.Clip(into), from...), airport)
+	for _, group := range [][]model.RideRequest{into, from} {
+		current := planTripRoute(direction, group, airport)
+		for _, rr := range group {
+			added := rideMinutes(direction, merged, rr) - rideMinutes(direction, current, rr)
+			allowed := float64(rr.ToleranceMeters) / 1000.0 / geo.AverageSpeedKmph * 60.0
+			if added > allowed {
+				return fmt.Errorf("request %d would ride %.1f min longer, tolerance %.1f min: %w",
+					rr.ID, added, allowed, ErrMergeDetour)
+			}
+		}
+	}
+	return nil
+}
+
+// rideMinutes is rr's time in the cab along route, a planTripRoute result:
+// from its pickup to the airport at the end of a to_airport route, or from
+// the airport at the start of a from_airport route to its drop-off.
+func rideMinutes(direction model.TripDirection, route []model.Location, rr model.RideRequest) float64 {
+	times := geo.CumulativeTimesMinutes(route)
+	if direction == model.DirectionFromAirport {
+		return times[slices.Index(route, rr.Destination)]
+	}
+	return times[len(times)-1] - times[slices.Index(route, rr.Origin)]
+}
+
+// lineStringWKT formats route as WKT for ST_GeomFromText (lon lat order).
+func lineStringWKT(route []model.Location) string {
+	points := make([]string, len(route))
+	for i, loc := range route {
+		points[i] = strconv.FormatFloat(loc.Lon, 'f', -1, 64) + " " + strconv.FormatFloat(loc.Lat, 'f', -1, 64)
+	}
+	return "LINESTRING(" + strings.Join(points, ", ") + ")"
+}
+
+// parseLineStringGeoJSON decodes ST_AsGeoJSON output for a LINESTRING.
+// A nil or empty input (no stored route) yields nil.
+func parseLineStringGeoJSON(raw []byte) ([]model.Location, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var line struct {
+		Coordinates [][2]float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal(raw, &line); err != nil {
+		return nil, fmt.Errorf("decode route_path: %w", err)
+	}
+	route := make([]model.Location, len(line.Coordinates))
+	for i, c := range line.Coordinates {
+		route[i] = model.Location{Lat: c[1], Lon: c[0]}
+	}
+	return route, nil
+}
@@ -0,0 +1,66 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestUpdateTripRoute_SecondPassengerExtendsRoute(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	first := model.Location{Lat: 10.0050, Lon: 70.0000}
+	second := model.Location{Lat: 10.0200, Lon: 70.0100}
+	airport := model.Location{Lat: 9.9000, Lon: 69.9900}
+
+	tripID := seedCandidateTrip(t, ctx, tx, "ROUTE-1", first)
+	if err := updateTripRoute(ctx, tx, tripID, model.DirectionToAirport, airport); err != nil {
+		t.Fatalf("first update: %v", err)
+	}
+	points, distance := storedRoute(t, ctx, tx, tripID)
+	if points != 2 || distance <= 0 {
+		t.Fatalf("one rider: %d points, %dm; want 2 points and a distance", points, distance)
+	}
+
+	// Book a second rider further out, as BookRide does, and re-plan.
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO ride_requests (user_id, origin, destination, direction, status, trip_id)
+		SELECT user_id, ST_SetSRID(ST_MakePoint($2, $3), 4326), destination, direction, 'matched', trip_id
+		FROM ride_requests WHERE trip_id = $1 LIMIT 1
+	`, tripID, second.Lon, second.Lat); err != nil {
+		t.Fatalf("seed second rider: %v", err)
+	}
+	if err := updateTripRoute(ctx, tx, tripID, model.DirectionToAirport, airport); err != nil {
+		t.Fatalf("second update: %v", err)
+	}
+	points2, distance2 := storedRoute(t, ctx, tx, tripID)
+	if points2 != 3 || distance2 <= distance {
+		t.Errorf("two riders: %d points, %dm; want 3 points and more than %dm", points2, distance2, distance)
+	}
+
+	var endLat, endLon float64
+	if err := tx.QueryRow(ctx, `
+		SELECT ST_Y(ST_EndPoint(route_path)), ST_X(ST_EndPoint(route_path)) FROM trips WHERE id = $1
+	`, tripID).Scan(&endLat, &endLon); err != nil {
+		t.Fatalf("read end point: %v", err)
+	}
+	if endLat != airport.Lat || endLon != airport.Lon {
+		t.Errorf("route ends at (%v, %v), want the airport", endLat, endLon)
+	}
+}
+
+// storedRoute returns the number of points in the trip's route_path and
+// its total_distance_m.
+func storedRoute(t *testing.T, ctx context.Context, tx pgx.Tx, tripID int64) (points, distanceM int) {
+	t.Helper()
+	if err := tx.QueryRow(ctx, `
+		SELECT ST_NPoints(route_path), total_distance_m FROM trips WHERE id = $1
+	`, tripID).Scan(&points, &distanceM); err != nil {
+		t.Fatalf("read route: %v", err)
+	}
+	return points, distanceM
+}
@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+var (
+	routeAirport = model.Location{Lat: 28.5562, Lon: 77.0889}
+	routeNear    = model.Location{Lat: 28.60, Lon: 77.09}
+	routeFar     = model.Location{Lat: 28.70, Lon: 77.10}
+)
+
+func TestPlanTripRoute_ToAirportEndsAtAirport(t *testing.T) {
+	riders := []model.RideRequest{
+		{Origin: routeNear, Destination: routeAirport},
+		{Origin: routeFar, Destination: routeAirport},
+	}
+	got := planTripRoute(model.DirectionToAirport, riders, routeAirport)
+	assertRoute(t, got, routeFar, routeNear, routeAirport)
+}
+
+func TestPlanTripRoute_FromAirportStartsAtAirport(t *testing.T) {
+	riders := []model.RideRequest{
+		{Origin: routeAirport, Destination: routeFar},
+		{Origin: routeAirport, Destination: routeNear},
+	}
+	got := planTripRoute(model.DirectionFromAirport, riders, routeAirport)
+	assertRoute(t, got, routeAirport, routeNear, routeFar)
+}
+
+func TestPlanTripRoute_AirportFallsBackToRiders(t *testing.T) {
+	riders := []model.RideRequest{{Origin: routeNear, Destination: routeAirport}}
+	got := planTripRoute(model.DirectionToAirport, riders, model.Location{})
+	assertRoute(t, got, routeNear, routeAirport)
+
+	if got := planTripRoute(model.DirectionToAirport, nil, routeAirport); got != nil {
+		t.Errorf("no riders: route = %v, want nil", got)
+	}
+}
+
+func TestCheckMergeDetours_OnTheWay(t *testing.T) {
+	into := []model.RideRequest{{ID: 1, Origin: routeFar, Destination: routeAirport, ToleranceMeters: 2000}}
+	from := []model.RideRequest{{ID: 2, Origin: routeNear, Destination: routeAirport, ToleranceMeters: 2000}}
+	if err := checkMergeDetours(model.DirectionToAirport, into, from, routeAirport); err != nil {
+		t.Errorf("near pickup on the far rider's way: err = %v, want nil", err)
+	}
+}
+
+func TestCheckMergeDetours_ExceedsTolerance(t *testing.T) {
+	east := model.Location{Lat: 28.60, Lon: 77.25}
+	into := []model.RideRequest{{ID: 1, Origin: routeFar, Destination: routeAirport, ToleranceMeters: 2000}}
+	from := []model.RideRequest{{ID: 2, Origin: east, Destination: routeAirport, ToleranceMeters: 2000}}
+	if err := checkMergeDetours(model.DirectionToAirport, into, from, routeAirport); !errors.Is(err, ErrMergeDetour) {
+		t.Errorf("pickup 15 km off the route: err = %v, want ErrMergeDetour", err)
+	}
+
+	// Drop-offs are checked the same way on a from_airport merge.
+	into = []model.RideRequest{{ID: 1, Origin: routeAirport, Destination: routeNear, ToleranceMeters: 2000}}
+	from = []model.RideRequest{{ID: 2, Origin: routeAirport, Destination: east, ToleranceMeters: 2000}}
+	if err := checkMergeDetours(model.DirectionFromAirport, into, from, routeAirport); !errors.Is(err, ErrMergeDetour) {
+		t.Errorf("from_airport: err = %v, want ErrMergeDetour", err)
+	}
+}
+
+func TestLineStringWKT(t *testing.T) {
+	got := lineStringWKT([]model.Location{routeNear, routeAirport})
+	want := "LINESTRING(77.09 28.6, 77.0889 28.5562)"
+	if got != want {
+		t.Errorf("wkt = %q, want %q", got, want)
+	}
+}
+
+func TestParseLineStringGeoJSON(t *testing.T) {
+	got, err := parseLineStringGeoJSON([]byte(`{"type":"LineString","coordinates":[[77.09,28.6],[77.0889,28.5562]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertRoute(t, got, routeNear, routeAirport)
+
+	if got, err := parseLineStringGeoJSON(nil); got != nil || err != nil {
+		t.Errorf("NULL route_path: got %v, %v; want nil, nil", got, err)
+	}
+}
+
+func TestSnapRouteStops(t *testing.T) {
+	riders := []model.RideRequest{
+		{Origin: routeNear, Destination: routeAirport},
+		{Origin: routeFar, Destination: routeAirport},
+	}
+	// route_path as ST_AsGeoJSON returns it: rounded, not bit-for-bit.
+	stored := []model.Location{
+		{Lat: routeFar.Lat + 1e-9, Lon: routeFar.Lon},
+		{Lat: routeNear.Lat, Lon: routeNear.Lon - 1e-9},
+		routeAirport,
+	}
+	if !snapRouteStops(model.DirectionToAirport, stored, riders) {
+		t.Fatal("stored route visits both pickups, want true")
+	}
+	assertRoute(t, stored, routeFar, routeNear, routeAirport)
+
+	// A route stored before the far rider joined is stale.
+	stale := []model.Location{routeNear, routeAirport}
+	if snapRouteStops(model.DirectionToAirport, stale, riders) {
+		t.Error("route missing a pickup, want false")
+	}
+	if snapRouteStops(model.DirectionToAirport, nil, riders) {
+		t.Error("no stored route, want false")
+	}
+}
+
+func assertRoute(t *testing.T, got []model.Location, want ...model.Location) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("route = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("route = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestOrderManifest_ToAirportInPickupOrder(t *testing.T) {
+	m := &TripManifest{Direction: model.DirectionToAirport, Passengers: []ManifestPassenger{
+		{RequestID: 1, SeatsNeeded: 1, LuggageCount: 2, Pickup: routeNear, Dropoff: routeAirport},
+		{RequestID: 2, SeatsNeeded: 2, LuggageCount: 1, Pickup: routeFar, Dropoff: routeAirport},
+		{RequestID: 3, SeatsNeeded: 1, Pickup: routeNear, Dropoff: routeAirport}, // Boards with 1.
+	}}
+	orderManifest(m)
+
+	// The route runs far → near → airport.
+	want := []struct {
+		id   int64
+		stop int
+	}{{2, 1}, {1, 2}, {3, 2}}
+	for i, w := range want {
+		if got := m.Passengers[i]; got.RequestID != w.id || got.Stop != w.stop {
+			t.Errorf("passenger %d = request %d at stop %d, want request %d at stop %d", i, got.RequestID, got.Stop, w.id, w.stop)
+		}
+	}
+	if m.TotalSeats != 4 || m.TotalLuggage != 3 {
+		t.Errorf("totals = %d seats, %d bags; want 4 and 3", m.TotalSeats, m.TotalLuggage)
+	}
+}
+
+func TestOrderManifest_FromAirportInDropoffOrder(t *testing.T) {
+	m := &TripManifest{Direction: model.DirectionFromAirport, Passengers: []ManifestPassenger{
+		{RequestID: 1, SeatsNeeded: 1, Pickup: routeAirport, Dropoff: routeFar},
+		{RequestID: 2, SeatsNeeded: 1, Pickup: routeAirport, Dropoff: routeNear},
+	}}
+	orderManifest(m)
+
+	if m.Passengers[0].RequestID != 2 || m.Passengers[0].Stop != 1 || m.Passengers[1].RequestID != 1 || m.Passengers[1].Stop != 2 {
+		t.Errorf("manifest = %+v, want the near drop-off (2) first, then the far one (1)", m.Passengers)
+	}
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ─── Connection-level failures ──────────────────────────────
+
+// IsConnectionError reports whether err means the database could not be
+// reached or dropped the connection — refused, reset, EOF mid-query, or
+// the server shutting down — as opposed to the query itself failing.
+// Such errors are transient and not the caller's fault: handlers answer
+// 503 with Retry-After for them instead of 500.
+//
+// A caller's own deadline or cancellation is not a connection error even
+// when it surfaces as a network timeout.
+func IsConnectionError(err error) bool {
+	if err == nil || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// Class 08: connection exception. 57P01–57P03: admin_shutdown,
+		// crash_shutdown, cannot_connect_now (server starting or stopping).
+		return strings.HasPrefix(pgErr.Code, "08") ||
+			pgErr.Code == "57P01" || pgErr.Code == "57P02" || pgErr.Code == "57P03"
+	}
+
+	var connectErr *pgconn.ConnectError
+	var opErr *net.OpError
+	return errors.As(err, &connectErr) ||
+		errors.As(err, &opErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
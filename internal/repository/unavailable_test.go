@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestIsConnectionError(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	for _, tt := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"dial refused", fmt.Errorf("create ride: %w", refused), true},
+		{"connect error", &pgconn.ConnectError{}, true},
+		{"eof mid-query", fmt.Errorf("get trip: %w", io.ErrUnexpectedEOF), true},
+		{"connection reset", fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{"admin shutdown", &pgconn.PgError{Code: "57P01"}, true},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, true},
+
+		{"nil", nil, false},
+		{"no rows", fmt.Errorf("get trip: %w", pgx.ErrNoRows), false},
+		{"unique violation", &pgconn.PgError{Code: "23505"}, false},
+		{"sentinel", fmt.Errorf("cancel: %w", ErrTripNotFound), false},
+		{"caller deadline", fmt.Errorf("book: %w", context.DeadlineExceeded), false},
+		{"plain error", errors.New("boom"), false},
+	} {
+		if got := IsConnectionError(tt.err); got != tt.want {
+			t.Errorf("%s: IsConnectionError = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+//go:build integration
+
+package repository
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestUpdatePendingRequest_ChangesOnlyGivenFields(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "UPDATE-1", model.Location{Lat: 10.0050, Lon: 70.0000})
+
+	var id int64
+	if err := tx.QueryRow(ctx, `
+		INSERT INTO ride_requests (user_id, origin, destination, direction, status, luggage_count, tolerance_meters)
+		SELECT user_id, origin, destination, direction, 'pending', 2, 2000 FROM ride_requests WHERE trip_id = $1
+		RETURNING id
+	`, tripID).Scan(&id); err != nil {
+		t.Fatalf("seed pending request: %v", err)
+	}
+
+	luggage := 1
+	if err := updatePendingRequest(ctx, tx, id, RideRequestUpdate{LuggageCount: &luggage}); err != nil {
+		t.Fatalf("updatePendingRequest: %v", err)
+	}
+	var gotLuggage, gotTolerance, events int
+	if err := tx.QueryRow(ctx, `
+		SELECT luggage_count, tolerance_meters,
+		       (SELECT COUNT(*)::int FROM outbox WHERE event_type = $2 AND aggregate_id = $1)
+		FROM ride_requests WHERE id = $1
+	`, id, model.EventRideUpdated).Scan(&gotLuggage, &gotTolerance, &events); err != nil {
+		t.Fatalf("read request: %v", err)
+	}
+	if gotLuggage != 1 || gotTolerance != 2000 || events != 1 {
+		t.Errorf("luggage %d, tolerance %d, %d events; want 1, 2000 (unchanged), 1", gotLuggage, gotTolerance, events)
+	}
+}
+
+func TestUpdatePendingRequest_MatchedIsNotEditable(t *testing.T) {
+	ctx, tx := integrationTx(t)
+	tripID := seedCandidateTrip(t, ctx, tx, "UPDATE-2", model.Location{Lat: 10.0050, Lon: 70.0000})
+
+	var id int64
+	if err := tx.QueryRow(ctx, `SELECT id FROM ride_requests WHERE trip_id = $1`, tripID).Scan(&id); err != nil {
+		t.Fatalf("find matched request: %v", err)
+	}
+	seats := 2
+	if err := updatePendingRequest(ctx, tx, id, RideRequestUpdate{SeatsNeeded: &seats}); !errors.Is(err, ErrRequestNotEditable) {
+		t.Errorf("err = %v, want ErrRequestNotEditable", err)
+	}
+	if err := updatePendingRequest(ctx, tx, -1, RideRequestUpdate{SeatsNeeded: &seats}); !errors.Is(err, ErrRequestNotFound) {
+		t.Errorf("missing request: err = %v, want ErrRequestNotFound", err)
+	}
+}
@@ -4,16 +4,23 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"strings"
+	"time"
 
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/shiva/hintro/internal/model"
 	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/logctx"
+	"github.com/shiva/hintro/pkg/tracing"
 )
 
 // ─── Booking Errors ─────────────────────────────────────────
 
 var (
-	// ErrCabFull is returned when the cab has no remaining seat or luggage capacity.
+	// ErrCabFull is returned when the cab has no remaining seat or luggage
+	// capacity. Use errors.As for the *model.CapacityError with what is left.
 	ErrCabFull = errors.New("cab is full: no remaining seats or luggage capacity")
 
 	// ErrBookingTimeout is returned when the transaction lock wait exceeds
@@ -28,86 +35,255 @@ var (
 
 	// ErrNoCabNearby is returned when no available cab is found near the pickup.
 	ErrNoCabNearby = errors.New("no available cab found nearby")
+
+	// ErrTripPassengerLimit is returned when the trip already carries the
+	// MaxPassengersPerTrip pool cap, even if seats remain.
+	ErrTripPassengerLimit = errors.New("trip is at the MaxPassengersPerTrip pool cap")
+
+	// ErrFareAboveCap is returned when the quoted fare exceeds the rider's
+	// BookingOptions.MaxFareCents. Nothing is booked.
+	ErrFareAboveCap = errors.New("quoted fare exceeds the rider's max fare")
+
+	// ErrDirectionMismatch is returned when the trip chosen for the request
+	// travels in the opposite direction. Nothing is booked.
+	ErrDirectionMismatch = errors.New("trip direction does not match the ride request")
+
+	// ErrCabNotAccessible is returned when a rider who requires an
+	// accessible cab is booked onto one that is not equipped.
+	ErrCabNotAccessible = errors.New("cab is not wheelchair accessible")
 )
 
+// CabFullError is returned by BookRide instead of a bare ErrCabFull when
+// other trips would take the rider with one constraint relaxed. It wraps
+// the original error, so errors.Is(err, ErrCabFull) and errors.As for a
+// *model.CapacityError still work.
+type CabFullError struct {
+	Err         error
+	Suggestions []Suggestion
+}
+
+func (e *CabFullError) Error() string {
+	return fmt.Sprintf("%v (%d suggestion(s))", e.Err, len(e.Suggestions))
+}
+
+func (e *CabFullError) Unwrap() error { return e.Err }
+
 // ─── BookingService ─────────────────────────────────────────
 
+// maxBookingAttempts bounds how many times BookRide runs the booking
+// transaction when Postgres aborts it as a serialization failure or
+// deadlock (see repository.IsSerializationFailure).
+const maxBookingAttempts = 3
+
+// serializationBackoff is the pause before the first re-run; it grows by
+// the same amount each attempt.
+const serializationBackoff = 10 * time.Millisecond
+
 // BookingService handles ride bookings with strict concurrency control.
 //
 // Concurrency model:
 //   - Uses PostgreSQL SELECT ... FOR UPDATE (pessimistic locking).
 //   - The cab row is locked for the duration of the transaction.
 //   - Concurrent bookings for the same cab will serialize automatically.
-//   - A 5-second context timeout prevents deadlock starvation.
+//   - A context timeout (BOOKING_TIMEOUT, 5s by default) prevents deadlock
+//     starvation; callers may override it per call via BookingOptions.
 type BookingService struct {
-	bookingRepo  *repository.BookingRepository
-	matchingSvc  *MatchingService
+	bookingRepo BookingStore
+	matchingSvc *MatchingService
+	pricingSvc  *PricingService
+	timeout     time.Duration
+
+	// Counters, when set, has a booked pending request taken off demand
+	// and a cab claimed for a new trip taken off supply.
+	Counters SurgeCounter
+}
+
+// BookingStore is the booking persistence BookingService needs
+// (repository.BookingRepository).
+type BookingStore interface {
+	BookRide(ctx context.Context, requestID, cabID, tripID int64, fare repository.FareSnapshot) (*repository.BookingResult, error)
+	FindAndCreateTrip(ctx context.Context, location model.Location, radiusMeters, minSeatsNeeded, minLuggageNeeded int, requiresAccessible bool, direction model.TripDirection) (int64, *model.Cab, error)
+	DeleteEmptyTrip(ctx context.Context, tripID int64) (bool, error)
+	PriorBooking(ctx context.Context, requestID int64) (*repository.BookingResult, error)
+}
+
+// BookingOptions tunes a single BookRide call.
+type BookingOptions struct {
+	// Timeout overrides the service's booking timeout for this call.
+	// Zero uses the configured default.
+	Timeout time.Duration
+
+	// MaxFareCents refuses the booking if the quoted fare is above it.
+	// Zero means no cap.
+	MaxFareCents int
+
+	// MatchOnly books the request only onto an existing trip: with no
+	// match BookRide returns ErrNoMatch instead of claiming a cab for a
+	// new one. The pooler uses it so riders it cannot pool stay pending.
+	MatchOnly bool
 }
 
-// NewBookingService creates a booking service.
+// NewBookingService creates a booking service. A non-positive timeout falls
+// back to repository.DefaultBookingTimeout.
 func NewBookingService(
-	bookingRepo *repository.BookingRepository,
+	bookingRepo BookingStore,
 	matchingSvc *MatchingService,
+	pricingSvc *PricingService,
+	timeout time.Duration,
 ) *BookingService {
+	if timeout <= 0 {
+		timeout = repository.DefaultBookingTimeout
+	}
 	return &BookingService{
-		bookingRepo:  bookingRepo,
-		matchingSvc:  matchingSvc,
+		bookingRepo: bookingRepo,
+		matchingSvc: matchingSvc,
+		pricingSvc:  pricingSvc,
+		timeout:     timeout,
+	}
+}
+
+// timeoutFor returns the transaction timeout for a call: the per-call
+// override when set, otherwise the service default.
+func (s *BookingService) timeoutFor(opts BookingOptions) time.Duration {
+	if opts.Timeout > 0 {
+		return opts.Timeout
 	}
+	return s.timeout
 }
 
 // BookRide is the main booking entry point.
 //
 // Flow:
 //  1. Run the matching algorithm to find a compatible trip.
-//  2. If no match, find a nearby available cab and create a new trip.
-//  3. Execute the booking transaction with pessimistic row locking.
-//  4. Handle race conditions: if the cab fills up between match and book,
-//     return ErrCabFull.
+//  2. Quote the fare (pooled riders get the pool discount); refuse if it is
+//     above the rider's max fare.
+//  3. If no match, claim a nearby available cab and create a new trip on
+//     it in one transaction (see repository.FindAndCreateTrip), or with
+//     BookingOptions.MatchOnly return ErrNoMatch.
+//  4. Execute the booking transaction with pessimistic row locking; the
+//     quoted fare and surge are stored on the ride request in the same tx.
+//  5. Handle race conditions: if the cab fills up between match and book,
+//     return ErrCabFull. A transaction Postgres aborts as a serialization
+//     failure is re-run, up to maxBookingAttempts times in all. A request
+//     a concurrent submit booked first gets that booking back (priorBooking).
 //
 // Concurrency guarantee:
-//   Two users booking the last seat at the same millisecond:
-//     User A: gets the lock → books seat → commits (success)
-//     User B: blocks on lock → re-reads → no seats left → rollback (ErrCabFull)
-func (s *BookingService) BookRide(ctx context.Context, requestID int64) (*repository.BookingResult, error) {
-	log.Printf("[booking] Starting booking for request #%d", requestID)
+//
+//	Two users booking the last seat at the same millisecond:
+//	  User A: gets the lock → books seat → commits (success)
+//	  User B: blocks on lock → re-reads → no seats left → rollback (ErrCabFull)
+func (s *BookingService) BookRide(ctx context.Context, requestID int64, opts BookingOptions) (_ *repository.BookingResult, err error) {
+	ctx, span := tracing.Start(ctx, "BookingService.BookRide", attribute.Int64("ride.request_id", requestID))
+	defer func() { tracing.End(span, err) }()
+	ctx = logctx.WithRequestID(ctx, requestID)
+	logctx.Debugf(ctx, "[booking] Starting booking for request #%d", requestID)
+
+	// Fetch the request for its origin/destination (fare + new-trip search).
+	req, err := s.matchingSvc.Repo.GetRideRequest(ctx, requestID, false)
+	if errors.Is(err, pgx.ErrNoRows) || errors.Is(err, repository.ErrRequestNotFound) {
+		return nil, ErrRequestNotFound
+	}
+	if repository.IsConnectionError(err) {
+		return nil, fmt.Errorf("booking: %w", err)
+	}
+	if err != nil {
+		return nil, s.classifyError(err)
+	}
+	// A double-submitted request that another call already booked must not
+	// go on to match or start a trip of its own; it gets that booking back.
+	// A scheduled request books as a seat reservation when
+	// MatchConfig.ReserveScheduled is on.
+	switch {
+	case req.Status == model.RequestPending, s.matchingSvc.config.reservable(req, s.matchingSvc.now()):
+	case req.Status == model.RequestScheduled && req.TripID == nil:
+		return nil, ErrRequestScheduled
+	default:
+		return s.priorBooking(ctx, requestID)
+	}
 
 	// ── Step 1: Try to match to an existing trip ────────
 	var tripID, cabID int64
+	var cabLocation *model.Location
+	pooled := false
 
-	matchResult, err := s.matchingSvc.MatchRiders(ctx, requestID)
+	// Bypasses the no-match cooldown: a rider booking must get a fresh search.
+	matchResult, err := s.matchingSvc.match(ctx, requestID, nil)
 	if err == nil {
 		// Match found — use this trip.
 		tripID = matchResult.TripID
 		cabID = matchResult.CabID
-		log.Printf("[booking] Matched to existing trip #%d (cab #%d)", tripID, cabID)
-	} else if errors.Is(err, ErrNoMatch) {
-		// No match — create a new trip.
-		log.Printf("[booking] No existing match; creating new trip")
+		pooled = true
+		ctx = logctx.WithTripID(ctx, tripID)
+		logctx.Printf(ctx, "[booking] Matched to existing trip #%d (cab #%d)", tripID, cabID)
+	} else if errors.Is(err, ErrAlreadyMatched) {
+		// Booked by a concurrent submit since the status check above.
+		return s.priorBooking(ctx, requestID)
+	} else if !errors.Is(err, ErrNoMatch) {
+		// Other errors (not found, etc.)
+		return nil, s.classifyError(err)
+	}
+	if !pooled && opts.MatchOnly {
+		return nil, ErrNoMatch
+	}
+
+	// ── Step 2: Quote the fare ──────────────────────────
+	// Quoted before any new trip is created, so a refusal over the rider's
+	// max fare leaves nothing behind.
+	fare, err := s.pricingSvc.QuoteBooking(ctx, req.Origin, req.Destination, pooled,
+		FareOptions{MaxFareCents: opts.MaxFareCents, Direction: req.Direction})
+	if err != nil {
+		return nil, fmt.Errorf("booking: quote fare: %w", err)
+	}
+	if fare.Capped {
+		logctx.Printf(ctx, "[booking] Refusing request #%d: fare %d above cap %d",
+			requestID, fare.WouldBeCents, opts.MaxFareCents)
+		return nil, ErrFareAboveCap
+	}
+
+	// ── Step 3: No match → create a new trip ───────────
+	if !pooled {
+		logctx.Debugf(ctx, "[booking] No existing match; creating new trip")
 
-		newTrip, err := s.createNewTrip(ctx, requestID)
+		newTrip, err := s.createNewTrip(ctx, req)
 		if err != nil {
 			return nil, err
 		}
 		tripID = newTrip.tripID
 		cabID = newTrip.cabID
-		log.Printf("[booking] Created new trip #%d (cab #%d)", tripID, cabID)
-	} else {
-		// Other errors (not found, already matched, etc.)
-		return nil, s.classifyError(err)
+		cabLocation = newTrip.cabLocation
+		ctx = logctx.WithTripID(ctx, tripID)
+		logctx.Printf(ctx, "[booking] Created new trip #%d (cab #%d)", tripID, cabID)
 	}
 
-	// ── Step 2: Execute the booking transaction ─────────
+	// ── Step 4: Execute the booking transaction ─────────
 	// This is where the pessimistic lock kicks in.
-	// Create a deadline context for the transaction.
-	txCtx, cancel := context.WithTimeout(ctx, repository.DefaultBookingTimeout)
+	// Create a deadline context for the transaction; the repository derives
+	// lock_timeout from it so the two always agree.
+	txCtx, cancel := context.WithTimeout(ctx, s.timeoutFor(opts))
 	defer cancel()
 
-	result, err := s.bookingRepo.BookRide(txCtx, requestID, cabID, tripID)
+	var result *repository.BookingResult
+	err = retrySerialization(txCtx, maxBookingAttempts, func() error {
+		var err error
+		result, err = s.bookingRepo.BookRide(txCtx, requestID, cabID, tripID, fareSnapshot(fare, pooled))
+		return err
+	})
 	if err != nil {
-		return nil, s.classifyError(err)
+		if !pooled {
+			// The new trip was made for this rider alone; don't leave it
+			// behind empty (e.g. when a concurrent submit of the same
+			// request booked first).
+			s.discardNewTrip(ctx, tripID)
+		}
+		if errors.Is(err, repository.ErrRequestNotPending) {
+			// The concurrent submit that locked the request first won.
+			return s.priorBooking(ctx, requestID)
+		}
+		return nil, s.withSuggestions(ctx, req, s.classifyError(err))
 	}
+	s.countBooking(ctx, req, cabLocation, result)
 
-	log.Printf("[booking] ✓ Booked request #%d into trip #%d (cab #%d) — %d seats remaining",
+	logctx.Printf(ctx, "[booking] ✓ Booked request #%d into trip #%d (cab #%d) — %d seats remaining",
 		result.RequestID, result.TripID, result.CabID, result.RemainingSeats)
 
 	return result, nil
@@ -115,32 +291,126 @@ func (s *BookingService) BookRide(ctx context.Context, requestID int64) (*reposi
 
 // ─── Private helpers ────────────────────────────────────────
 
-type newTripResult struct {
-	tripID int64
-	cabID  int64
+// fareSnapshot is what BookRide stores on the ride request: the quoted
+// total (after pool discount) and the surge it was computed under.
+func fareSnapshot(fare *FareEstimate, pooled bool) repository.FareSnapshot {
+	return repository.FareSnapshot{
+		FareCents:         fare.TotalFareCents,
+		PoolDiscountCents: fare.PoolDiscountCents,
+		SurgeMultiplier:   fare.SurgeMultiplier,
+		Pooled:            pooled,
+	}
 }
 
-// createNewTrip finds an available cab and creates a new trip for the request.
-func (s *BookingService) createNewTrip(ctx context.Context, requestID int64) (*newTripResult, error) {
-	// Fetch the request to get origin and direction.
-	req, err := s.matchingSvc.Repo.GetRideRequest(ctx, requestID, false)
+// priorBooking answers a BookRide call for a request that is already
+// booked (a double-submit, or a retry after a lost response) with the
+// original booking, so every caller gets the same result; Replayed tells
+// it apart from a new one. A request that is not booked yields
+// ErrRequestNotPending.
+func (s *BookingService) priorBooking(ctx context.Context, requestID int64) (*repository.BookingResult, error) {
+	result, err := s.bookingRepo.PriorBooking(ctx, requestID)
 	if err != nil {
-		return nil, fmt.Errorf("booking: fetch request: %w", err)
+		return nil, s.classifyError(err)
 	}
+	result.Replayed = true
+	logctx.Printf(ctx, "[booking] Request #%d is already booked into trip #%d; returning that booking",
+		requestID, result.TripID)
+	return result, nil
+}
 
-	// Find nearest available cab (within 10km) that can fit this passenger's seats and luggage.
-	cab, err := s.bookingRepo.FindAvailableCabNear(ctx, req.Origin, 10000, req.SeatsNeeded, req.LuggageCount)
-	if err != nil {
+type newTripResult struct {
+	tripID      int64
+	cabID       int64
+	cabLocation *model.Location
+}
+
+// createNewTrip claims the nearest available cab (within 10km) that can
+// fit this passenger's seats and luggage, and is accessible if the rider
+// needs it, and creates a new trip on it in the same transaction.
+func (s *BookingService) createNewTrip(ctx context.Context, req *model.RideRequest) (*newTripResult, error) {
+	tripID, cab, err := s.bookingRepo.FindAndCreateTrip(ctx, req.Origin, 10000,
+		req.SeatsNeeded, req.LuggageCount, req.RequiresAccessible, req.Direction)
+	if errors.Is(err, repository.ErrNoCabNearby) {
 		return nil, ErrNoCabNearby
 	}
-
-	// Create a new trip on this cab.
-	tripID, err := s.bookingRepo.CreateTrip(ctx, cab.ID, req.Direction)
 	if err != nil {
-		return nil, fmt.Errorf("booking: create trip: %w", err)
+		return nil, s.classifyError(fmt.Errorf("booking: %w", err))
 	}
 
-	return &newTripResult{tripID: tripID, cabID: cab.ID}, nil
+	return &newTripResult{tripID: tripID, cabID: cab.ID, cabLocation: cab.CurrentLocation}, nil
+}
+
+// countBooking reports a booking to the surge counters: a pending request
+// leaves demand (a scheduled reservation was never counted), and a cab
+// claimed for a new trip, at claimedCab, or by the booking itself
+// (result.Surge), leaves supply.
+func (s *BookingService) countBooking(ctx context.Context, req *model.RideRequest, claimedCab *model.Location, result *repository.BookingResult) {
+	if s.Counters == nil {
+		return
+	}
+	CountSurge(ctx, s.Counters, result.Surge)
+	if req.Status == model.RequestPending {
+		s.Counters.AddDemand(ctx, req.Origin, req.Direction, -1)
+	}
+	if claimedCab != nil {
+		s.Counters.AddSupply(ctx, *claimedCab, -1)
+	}
+}
+
+// retrySerialization runs fn, and runs it again while it fails with a
+// serialization failure, up to attempts times in all. It waits
+// serializationBackoff × the attempt number between runs, and stops early
+// with ctx's error once ctx is done. fn must be a whole transaction: the
+// failed run was rolled back, so the next one starts from scratch.
+func retrySerialization(ctx context.Context, attempts int, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = fn(); !repository.IsSerializationFailure(err) || attempt == attempts {
+			return err
+		}
+		logctx.Printf(ctx, "[booking] Serialization failure on attempt %d/%d, retrying: %v", attempt, attempts, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(attempt) * serializationBackoff):
+		}
+	}
+	return err
+}
+
+// discardNewTrip deletes a trip createNewTrip made for a booking that then
+// failed. It runs even if ctx is done (the failure may be a timeout), and
+// only logs on error: the booking error is what the caller needs. The surge
+// counters need no update: countBooking only takes the claimed cab off
+// supply once the booking succeeds, so freeing it here nets to zero.
+func (s *BookingService) discardNewTrip(ctx context.Context, tripID int64) {
+	dctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.timeout)
+	defer cancel()
+	deleted, err := s.bookingRepo.DeleteEmptyTrip(dctx, tripID)
+	switch {
+	case err != nil:
+		logctx.Warnf(ctx, "[booking] WARNING: could not discard new trip #%d: %v", tripID, err)
+	case deleted:
+		logctx.Printf(ctx, "[booking] Discarded new trip #%d after the booking failed", tripID)
+	}
+}
+
+// withSuggestions turns an ErrCabFull into a *CabFullError carrying
+// MatchingService.Suggest's alternatives for req. Other errors, and cab-full
+// errors with nothing to suggest, are returned unchanged.
+func (s *BookingService) withSuggestions(ctx context.Context, req *model.RideRequest, err error) error {
+	if !errors.Is(err, ErrCabFull) {
+		return err
+	}
+	suggestions, serr := s.matchingSvc.Suggest(ctx, req)
+	if serr != nil {
+		logctx.Warnf(ctx, "[booking] WARNING: no suggestions for request #%d: %v", req.ID, serr)
+		return err
+	}
+	if len(suggestions) == 0 {
+		return err
+	}
+	return &CabFullError{Err: err, Suggestions: suggestions}
 }
 
 // classifyError maps low-level DB/service errors to user-facing booking errors.
@@ -151,21 +421,35 @@ func (s *BookingService) classifyError(err error) error {
 
 	errMsg := err.Error()
 
-	// Context timeout → lock wait exceeded
-	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+	// Context timeout or Postgres lock_timeout → lock wait exceeded
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) ||
+		strings.Contains(errMsg, "lock timeout") {
 		return ErrBookingTimeout
 	}
 
-	// Capacity errors
-	if strings.Contains(errMsg, "seats remaining") {
+	// Capacity errors (keep the *model.CapacityError so callers can report
+	// what is left)
+	var capErr *model.CapacityError
+	if errors.As(err, &capErr) {
+		return fmt.Errorf("%w: %w", ErrCabFull, capErr)
+	}
+	if errors.Is(err, model.ErrInsufficientCapacity) || strings.Contains(errMsg, "seats remaining") {
 		return ErrCabFull
 	}
 	if strings.Contains(errMsg, "luggage slots remaining") {
 		return ErrCabFull
 	}
 
+	if errors.Is(err, model.ErrPassengerLimit) {
+		return ErrTripPassengerLimit
+	}
+
+	if errors.Is(err, model.ErrNotAccessible) {
+		return ErrCabNotAccessible
+	}
+
 	// Status errors
-	if strings.Contains(errMsg, "expected 'pending'") ||
+	if errors.Is(err, repository.ErrRequestNotPending) || strings.Contains(errMsg, "expected 'pending'") ||
 		errors.Is(err, ErrAlreadyMatched) {
 		return ErrRequestNotPending
 	}
@@ -173,6 +457,10 @@ func (s *BookingService) classifyError(err error) error {
 		return ErrCabNotAvailable
 	}
 
+	if errors.Is(err, repository.ErrDirectionMismatch) {
+		return ErrDirectionMismatch
+	}
+
 	// Request not found
 	if errors.Is(err, ErrRequestNotFound) {
 		return ErrRequestNotFound
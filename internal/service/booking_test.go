@@ -0,0 +1,446 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+func TestBookingTimeout_ConfiguredDefault(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 2*time.Second)
+	if got := svc.timeoutFor(BookingOptions{}); got != 2*time.Second {
+		t.Errorf("timeout = %s, want 2s", got)
+	}
+}
+
+func TestBookingTimeout_PerCallOverride(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 2*time.Second)
+	if got := svc.timeoutFor(BookingOptions{Timeout: 20 * time.Second}); got != 20*time.Second {
+		t.Errorf("timeout = %s, want 20s", got)
+	}
+}
+
+func TestBookingTimeout_UnsetFallsBackToDefault(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	if got := svc.timeoutFor(BookingOptions{}); got != repository.DefaultBookingTimeout {
+		t.Errorf("timeout = %s, want %s", got, repository.DefaultBookingTimeout)
+	}
+}
+
+func TestClassifyError_LockTimeoutIsBookingTimeout(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	err := errors.New("booking: lock cab 3: ERROR: canceling statement due to lock timeout (SQLSTATE 55P03)")
+	if got := svc.classifyError(err); !errors.Is(got, ErrBookingTimeout) {
+		t.Errorf("classifyError = %v, want ErrBookingTimeout", got)
+	}
+}
+
+func TestClassifyError_FlexUnitsExhaustedIsCabFull(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	flex := 4
+	capErr := model.CabCapacity{Seats: 4, Luggage: 4, Flex: &flex}.Check(2, 2, 1, 0)
+	err := fmt.Errorf("booking: cab 3 has %w", capErr)
+	if got := svc.classifyError(err); !errors.Is(got, ErrCabFull) {
+		t.Errorf("classifyError(%v) = %v, want ErrCabFull", err, got)
+	}
+}
+
+func TestClassifyError_CabFullKeepsRemainingCapacity(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	capErr := model.CabCapacity{Seats: 4, Luggage: 3}.Check(3, 2, 2, 1)
+	got := svc.classifyError(fmt.Errorf("booking: cab 3 has %w", capErr))
+
+	if !errors.Is(got, ErrCabFull) {
+		t.Fatalf("classifyError = %v, want ErrCabFull", got)
+	}
+	var remaining *model.CapacityError
+	if !errors.As(got, &remaining) {
+		t.Fatalf("classifyError = %v, want it to carry *model.CapacityError", got)
+	}
+	if remaining.RemainingSeats != 1 || remaining.RemainingLuggage != 1 {
+		t.Errorf("remaining = %d seats, %d bags; want 1, 1", remaining.RemainingSeats, remaining.RemainingLuggage)
+	}
+}
+
+func TestFareSnapshot_MatchesQuoteAtBooking(t *testing.T) {
+	surged := &fakeDemandSupply{ds: repository.DemandSupply{Demand: 5, Supply: 2, Ratio: 2.5}}
+	pricing := &PricingService{repo: surged, config: DefaultFareConfig()}
+	origin := model.Location{Lat: 28.7041, Lon: 77.1025}
+	dest := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	for _, pooled := range []bool{false, true} {
+		quote, err := pricing.QuoteBooking(context.Background(), origin, dest, pooled, FareOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		snap := fareSnapshot(quote, pooled)
+
+		if snap.SurgeMultiplier != SurgeMultiplierHigh || snap.SurgeMultiplier != quote.SurgeMultiplier {
+			t.Errorf("pooled=%v: stored surge %.1f, quote %.1f, want %.1f",
+				pooled, snap.SurgeMultiplier, quote.SurgeMultiplier, SurgeMultiplierHigh)
+		}
+		if snap.FareCents != quote.TotalFareCents || snap.PoolDiscountCents != quote.PoolDiscountCents {
+			t.Errorf("pooled=%v: stored %+v, quote total=%d discount=%d",
+				pooled, snap, quote.TotalFareCents, quote.PoolDiscountCents)
+		}
+		if pooled != (snap.PoolDiscountCents > 0) {
+			t.Errorf("pooled=%v: stored discount %d", pooled, snap.PoolDiscountCents)
+		}
+	}
+}
+
+// fareStore is a MatchStore holding one ride request and the given
+// trips, whose BookRide keeps the fare snapshot it was handed. onBook,
+// if set, runs as the booking transaction starts.
+type fareStore struct {
+	candidateStore
+	fakeBookingStore
+	req    model.RideRequest
+	fare   repository.FareSnapshot
+	onBook func()
+}
+
+func (s *fareStore) GetRideRequest(context.Context, int64, bool) (*model.RideRequest, error) {
+	req := s.req
+	return &req, nil
+}
+
+func (s *fareStore) BookRide(_ context.Context, requestID, cabID, tripID int64, fare repository.FareSnapshot) (*repository.BookingResult, error) {
+	if s.onBook != nil {
+		s.onBook()
+	}
+	s.fare = fare
+	return &repository.BookingResult{
+		RequestID: requestID, CabID: cabID, TripID: tripID,
+		Pooled: fare.Pooled, FareCents: fare.FareCents, SurgeMultiplier: fare.SurgeMultiplier,
+	}, nil
+}
+
+func TestBookRide_PooledRiderPaysDiscountedFare(t *testing.T) {
+	req := model.RideRequest{
+		ID: 42, Status: model.RequestPending, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM,
+		Origin:      model.Location{Lat: 28.69, Lon: 77.10},
+		Destination: model.Location{Lat: 28.5562, Lon: 77.0889},
+	}
+	cfg := DefaultFareConfig()
+	cfg.PoolDiscountPercent = 20
+
+	book := func(trips ...model.CandidateTrip) repository.FareSnapshot {
+		t.Helper()
+		store := &fareStore{candidateStore: candidateStore{candidates: trips}, req: req}
+		pricing := &PricingService{repo: &fakeDemandSupply{ds: repository.DemandSupply{Demand: 1, Supply: 1, Ratio: 1}}, config: cfg}
+		svc := NewBookingService(store, NewMatchingService(store, DefaultMatchConfig()), pricing, 0)
+		if _, err := svc.BookRide(context.Background(), req.ID, BookingOptions{}); err != nil {
+			t.Fatalf("BookRide: %v", err)
+		}
+		return store.fare
+	}
+	solo := book()
+	pooled := book(*plannedTrip())
+
+	if solo.Pooled || solo.PoolDiscountCents != 0 {
+		t.Errorf("solo booking stored %+v, want full fare, not pooled", solo)
+	}
+	if !pooled.Pooled || pooled.FareCents+pooled.PoolDiscountCents != solo.FareCents {
+		t.Errorf("pooled booking stored %+v, want pooled with the solo fare %d split into fare and discount", pooled, solo.FareCents)
+	}
+	if want := int(math.Round(float64(solo.FareCents) * 0.8)); pooled.FareCents != want {
+		t.Errorf("pooled fare = %d, want 20%% off %d = %d", pooled.FareCents, solo.FareCents, want)
+	}
+}
+
+func TestBookRide_SurgeChangeAfterQuoteKeepsQuotedFare(t *testing.T) {
+	req := model.RideRequest{
+		ID: 43, Status: model.RequestPending, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM,
+		Origin:      model.Location{Lat: 28.69, Lon: 77.10},
+		Destination: model.Location{Lat: 28.5562, Lon: 77.0889},
+	}
+	surge := &fakeDemandSupply{ds: repository.DemandSupply{Demand: 5, Supply: 2, Ratio: 2.5}}
+	pricing := &PricingService{repo: surge, config: DefaultFareConfig()}
+	quoted, err := pricing.QuoteBooking(context.Background(), req.Origin, req.Destination, false, FareOptions{Direction: req.Direction})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Demand drains while the booking transaction runs.
+	store := &fareStore{req: req, onBook: func() {
+		surge.ds = repository.DemandSupply{Demand: 1, Supply: 4, Ratio: 0.25}
+	}}
+	svc := NewBookingService(store, NewMatchingService(store, DefaultMatchConfig()), pricing, 0)
+	result, err := svc.BookRide(context.Background(), req.ID, BookingOptions{})
+	if err != nil {
+		t.Fatalf("BookRide: %v", err)
+	}
+
+	if store.fare.SurgeMultiplier != SurgeMultiplierHigh || store.fare.FareCents != quoted.TotalFareCents {
+		t.Errorf("stored %+v, want the quote's surge %.1f and fare %d",
+			store.fare, SurgeMultiplierHigh, quoted.TotalFareCents)
+	}
+	if result.SurgeMultiplier != store.fare.SurgeMultiplier || result.FareCents != store.fare.FareCents {
+		t.Errorf("result surge %.1f fare %d, stored surge %.1f fare %d",
+			result.SurgeMultiplier, result.FareCents, store.fare.SurgeMultiplier, store.fare.FareCents)
+	}
+	requoted, err := pricing.QuoteBooking(context.Background(), req.Origin, req.Destination, false, FareOptions{Direction: req.Direction})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requoted.TotalFareCents >= quoted.TotalFareCents {
+		t.Fatalf("requote %d after demand drained, want below %d", requoted.TotalFareCents, quoted.TotalFareCents)
+	}
+}
+
+func TestClassifyError_DirectionMismatch(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	err := fmt.Errorf("booking: request 2 is to_airport but trip 9 is from_airport: %w", repository.ErrDirectionMismatch)
+	if got := svc.classifyError(err); !errors.Is(got, ErrDirectionMismatch) {
+		t.Errorf("classifyError = %v, want ErrDirectionMismatch", got)
+	}
+}
+
+func TestClassifyError_PassengerLimit(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	err := fmt.Errorf("booking: trip 7: %w", model.CheckPassengerLimit(4, 1, 4))
+	if got := svc.classifyError(err); !errors.Is(got, ErrTripPassengerLimit) {
+		t.Errorf("classifyError = %v, want ErrTripPassengerLimit", got)
+	}
+}
+
+func TestClassifyError_NotAccessible(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	err := fmt.Errorf("booking: cab 3: %w", model.ErrNotAccessible)
+	if got := svc.classifyError(err); !errors.Is(got, ErrCabNotAccessible) {
+		t.Errorf("classifyError = %v, want ErrCabNotAccessible", got)
+	}
+}
+
+func TestClassifyError_RequestNoLongerPending(t *testing.T) {
+	svc := NewBookingService(nil, nil, nil, 0)
+	err := fmt.Errorf("booking: request 5 status is 'matched': %w", repository.ErrRequestNotPending)
+	if got := svc.classifyError(err); !errors.Is(got, ErrRequestNotPending) {
+		t.Errorf("classifyError = %v, want ErrRequestNotPending", got)
+	}
+}
+
+// failingRequestStore is a MatchStore whose GetRideRequest fails with err.
+type failingRequestStore struct {
+	countingStore
+	err error
+}
+
+func (s *failingRequestStore) GetRideRequest(context.Context, int64, bool) (*model.RideRequest, error) {
+	return nil, s.err
+}
+
+func TestBookRide_OnlyMissingRequestIsNotFound(t *testing.T) {
+	for _, tt := range []struct {
+		name         string
+		err          error
+		wantNotFound bool
+	}{
+		{"no rows", fmt.Errorf("get ride request 42: %w", pgx.ErrNoRows), true},
+		{"repository not found", fmt.Errorf("get ride request 42: %w", repository.ErrRequestNotFound), true},
+		{"timeout", fmt.Errorf("get ride request 42: %w", context.DeadlineExceeded), false},
+		{"scan failure", errors.New("get ride request 42: can't scan into dest[12]"), false},
+	} {
+		matching := NewMatchingService(&failingRequestStore{err: tt.err}, DefaultMatchConfig())
+		svc := NewBookingService(fakeBookingStore{}, matching, nil, 0)
+
+		_, err := svc.BookRide(context.Background(), 42, BookingOptions{})
+		if got := errors.Is(err, ErrRequestNotFound); got != tt.wantNotFound {
+			t.Errorf("%s: err = %v, want not found %v", tt.name, err, tt.wantNotFound)
+		}
+	}
+}
+
+// doubleSubmitStore holds one ride request and books it in memory. mu
+// stands in for the request row lock BookRide takes. FindAndCreateTrip
+// waits until every caller has reached it, so concurrent submits all pass
+// BookRide's status check and each start a trip before any of them books.
+type doubleSubmitStore struct {
+	mu       sync.Mutex
+	req      model.RideRequest
+	trips    map[int64]bool
+	nextTrip int64
+	booked   *repository.BookingResult
+	arrived  sync.WaitGroup
+}
+
+func (s *doubleSubmitStore) GetRideRequest(context.Context, int64, bool) (*model.RideRequest, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	req := s.req
+	return &req, nil
+}
+
+func (s *doubleSubmitStore) FindNearbyCandidateTrips(context.Context, model.Location, model.TripDirection, int, bool) ([]model.CandidateTrip, error) {
+	return nil, nil
+}
+
+func (s *doubleSubmitStore) GetTripStops(context.Context, int64) ([]model.Pickup, error) {
+	return nil, nil
+}
+
+func (s *doubleSubmitStore) GetTripDropoffs(context.Context, int64) ([]model.Dropoff, error) {
+	return nil, nil
+}
+
+func (s *doubleSubmitStore) GetDemandSupply(context.Context, model.Location, int, model.TripDirection) (*repository.DemandSupply, error) {
+	return &repository.DemandSupply{Demand: 1, Supply: 1, Ratio: 1}, nil
+}
+
+func (s *doubleSubmitStore) FindAndCreateTrip(context.Context, model.Location, int, int, int, bool, model.TripDirection) (int64, *model.Cab, error) {
+	s.arrived.Done()
+	s.arrived.Wait()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextTrip++
+	s.trips[s.nextTrip] = true
+	return s.nextTrip, &model.Cab{ID: 10 + s.nextTrip}, nil
+}
+
+func (s *doubleSubmitStore) BookRide(_ context.Context, requestID, cabID, tripID int64, fare repository.FareSnapshot) (*repository.BookingResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.req.Status != model.RequestPending {
+		return nil, fmt.Errorf("booking: request %d status is '%s': %w", requestID, s.req.Status, repository.ErrRequestNotPending)
+	}
+	s.req.Status, s.req.TripID = model.RequestMatched, &tripID
+	s.booked = &repository.BookingResult{
+		TripID: tripID, CabID: cabID, RequestID: requestID, SeatsBooked: s.req.SeatsNeeded, RemainingSeats: 3,
+		Pooled: fare.Pooled, FareCents: fare.FareCents, SurgeMultiplier: fare.SurgeMultiplier,
+	}
+	result := *s.booked
+	return &result, nil
+}
+
+func (s *doubleSubmitStore) DeleteEmptyTrip(_ context.Context, tripID int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.req.TripID != nil && *s.req.TripID == tripID {
+		return false, nil
+	}
+	deleted := s.trips[tripID]
+	delete(s.trips, tripID)
+	return deleted, nil
+}
+
+func (s *doubleSubmitStore) PriorBooking(_ context.Context, requestID int64) (*repository.BookingResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.booked == nil {
+		return nil, fmt.Errorf("booking: request %d not booked: %w", requestID, repository.ErrRequestNotPending)
+	}
+	result := *s.booked
+	return &result, nil
+}
+
+func TestBookRide_ConcurrentDoubleSubmitBooksOnce(t *testing.T) {
+	store := &doubleSubmitStore{
+		req: model.RideRequest{
+			ID: 42, Status: model.RequestPending, Direction: model.DirectionToAirport, SeatsNeeded: 1,
+			Origin:      model.Location{Lat: 28.7041, Lon: 77.1025},
+			Destination: model.Location{Lat: 28.5562, Lon: 77.0889},
+		},
+		trips: map[int64]bool{},
+	}
+	const submits = 2
+	store.arrived.Add(submits)
+	matching := NewMatchingService(store, DefaultMatchConfig())
+	pricing := &PricingService{repo: store, config: DefaultFareConfig()}
+	svc := NewBookingService(store, matching, pricing, 0)
+
+	var wg sync.WaitGroup
+	results := make([]*repository.BookingResult, submits)
+	errs := make([]error, submits)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = svc.BookRide(context.Background(), 42, BookingOptions{})
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("submit %d: %v, want the booking", i+1, err)
+		}
+	}
+	if results[0].Replayed == results[1].Replayed {
+		t.Errorf("replayed = %v and %v, want exactly one submit to get the other's booking back",
+			results[0].Replayed, results[1].Replayed)
+	}
+	first, second := *results[0], *results[1]
+	first.Replayed, second.Replayed = false, false
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("submits got different bookings:\n%+v\n%+v", first, second)
+	}
+	if len(store.trips) != 1 || !store.trips[results[0].TripID] {
+		t.Errorf("trips left = %v, want only the booked trip %d", store.trips, results[0].TripID)
+	}
+}
+
+func TestRetrySerialization_FailureThenSuccess(t *testing.T) {
+	calls := 0
+	err := retrySerialization(context.Background(), maxBookingAttempts, func() error {
+		calls++
+		if calls == 1 {
+			return fmt.Errorf("booking: lock cab 3: %w", &pgconn.PgError{Code: "40001"})
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("err = %v after %d calls, want success on the second", err, calls)
+	}
+}
+
+func TestRetrySerialization_Bounded(t *testing.T) {
+	calls := 0
+	err := retrySerialization(context.Background(), 3, func() error {
+		calls++
+		return &pgconn.PgError{Code: "40001"}
+	})
+	if !repository.IsSerializationFailure(err) || calls != 3 {
+		t.Errorf("err = %v after %d calls, want the serialization failure after 3", err, calls)
+	}
+}
+
+func TestRetrySerialization_OtherErrorsNotRetried(t *testing.T) {
+	calls := 0
+	err := retrySerialization(context.Background(), 3, func() error {
+		calls++
+		return repository.ErrRequestNotPending
+	})
+	if !errors.Is(err, repository.ErrRequestNotPending) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want ErrRequestNotPending after 1", err, calls)
+	}
+}
+
+func TestRetrySerialization_StopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := retrySerialization(ctx, 3, func() error {
+		calls++
+		cancel()
+		return &pgconn.PgError{Code: "40P01"}
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("err = %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+	if got := NewBookingService(nil, nil, nil, 0).classifyError(err); !errors.Is(got, ErrBookingTimeout) {
+		t.Errorf("classifyError = %v, want ErrBookingTimeout", got)
+	}
+}
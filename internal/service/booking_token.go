@@ -0,0 +1,97 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shiva/hintro/internal/repository"
+)
+
+var (
+	// ErrTokenInvalid is returned by BookingTokens.Verify for a token that
+	// is malformed or whose signature does not match.
+	ErrTokenInvalid = errors.New("booking token is invalid")
+
+	// ErrTokenExpired is returned by BookingTokens.Verify for a genuine
+	// token past its expiry.
+	ErrTokenExpired = errors.New("booking token has expired")
+)
+
+// BookingClaims is what a booking confirmation token vouches for.
+type BookingClaims struct {
+	RequestID int64     `json:"request_id"`
+	TripID    int64     `json:"trip_id"`
+	CabID     int64     `json:"cab_id"`
+	Seats     int       `json:"seats"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// BookingTokens issues and verifies booking confirmation tokens: the
+// rider shows one to the driver, who checks it with the verify endpoint.
+// A token is base64url(JSON claims) "." base64url(HMAC-SHA256 of the
+// first part), so anyone can read it but only the server can mint one.
+type BookingTokens struct {
+	secret []byte
+	ttl    time.Duration
+	now    func() time.Time
+}
+
+// NewBookingTokens creates tokens signed with secret that expire ttl after
+// the booking.
+func NewBookingTokens(secret []byte, ttl time.Duration) *BookingTokens {
+	return &BookingTokens{secret: secret, ttl: ttl, now: time.Now}
+}
+
+// Issue returns a signed token for a booking and the claims it carries.
+func (t *BookingTokens) Issue(res *repository.BookingResult) (string, BookingClaims) {
+	claims := BookingClaims{
+		RequestID: res.RequestID,
+		TripID:    res.TripID,
+		CabID:     res.CabID,
+		Seats:     res.SeatsBooked,
+		ExpiresAt: t.now().Add(t.ttl).UTC().Truncate(time.Second),
+	}
+	body, _ := json.Marshal(claims) // Plain ints and a time: cannot fail.
+	payload := base64.RawURLEncoding.EncodeToString(body)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(t.sign(payload)), claims
+}
+
+// Verify checks token's signature and expiry and returns its claims.
+// Errors wrap ErrTokenInvalid or ErrTokenExpired.
+func (t *BookingTokens) Verify(token string) (*BookingClaims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, fmt.Errorf("verify booking token: no signature: %w", ErrTokenInvalid)
+	}
+	// Strict: a signature whose unused trailing bits differ is a different
+	// token, not another spelling of this one.
+	got, err := base64.RawURLEncoding.Strict().DecodeString(sig)
+	if err != nil || !hmac.Equal(got, t.sign(payload)) {
+		return nil, fmt.Errorf("verify booking token: bad signature: %w", ErrTokenInvalid)
+	}
+
+	body, err := base64.RawURLEncoding.Strict().DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("verify booking token: decode: %w", ErrTokenInvalid)
+	}
+	var claims BookingClaims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, fmt.Errorf("verify booking token: decode: %w", ErrTokenInvalid)
+	}
+	if !t.now().Before(claims.ExpiresAt) {
+		return nil, fmt.Errorf("verify booking token: expired at %s: %w", claims.ExpiresAt.Format(time.RFC3339), ErrTokenExpired)
+	}
+	return &claims, nil
+}
+
+func (t *BookingTokens) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/repository"
+)
+
+var testBooking = &repository.BookingResult{TripID: 9, CabID: 4, RequestID: 17, SeatsBooked: 2}
+
+func newTestTokens(now time.Time) *BookingTokens {
+	tokens := NewBookingTokens([]byte("0123456789abcdef0123456789abcdef"), time.Hour)
+	tokens.now = func() time.Time { return now }
+	return tokens
+}
+
+func TestBookingToken_ValidVerifies(t *testing.T) {
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	tokens := newTestTokens(now)
+
+	token, issued := tokens.Issue(testBooking)
+	if !issued.ExpiresAt.Equal(now.Add(time.Hour)) {
+		t.Errorf("expires at %s, want %s", issued.ExpiresAt, now.Add(time.Hour))
+	}
+
+	claims, err := tokens.Verify(token)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if *claims != issued {
+		t.Errorf("claims = %+v, want %+v", claims, issued)
+	}
+	if claims.RequestID != 17 || claims.TripID != 9 || claims.CabID != 4 || claims.Seats != 2 {
+		t.Errorf("claims = %+v, want request 17 on trip 9, cab 4, 2 seats", claims)
+	}
+}
+
+func TestBookingToken_TamperedRejected(t *testing.T) {
+	tokens := newTestTokens(time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC))
+	token, _ := tokens.Issue(testBooking)
+	payload, sig, _ := strings.Cut(token, ".")
+
+	body, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(body), `"seats":2`, `"seats":4`, 1)))
+
+	// The last character of a 32-byte signature carries two unused bits;
+	// flipping one leaves the decoded bytes the same under lax decoding.
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	last := strings.IndexByte(alphabet, sig[len(sig)-1])
+	respelled := sig[:len(sig)-1] + string(alphabet[last^1])
+
+	other := NewBookingTokens([]byte("another-secret-another-secret-xx"), time.Hour)
+	otherToken, _ := other.Issue(testBooking)
+
+	for name, bad := range map[string]string{
+		"payload changed":   forged + "." + sig,
+		"signature cut":     payload + "." + sig[:len(sig)-2],
+		"signature respelt": payload + "." + respelled,
+		"no signature":      payload,
+		"other secret":      otherToken,
+		"garbage":           "not-a-token",
+		"signature not b64": payload + ".!!!",
+	} {
+		if _, err := tokens.Verify(bad); !errors.Is(err, ErrTokenInvalid) {
+			t.Errorf("%s: err = %v, want ErrTokenInvalid", name, err)
+		}
+	}
+}
+
+func TestBookingToken_Expired(t *testing.T) {
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	tokens := newTestTokens(now)
+	token, _ := tokens.Issue(testBooking)
+
+	tokens.now = func() time.Time { return now.Add(time.Hour) }
+	if _, err := tokens.Verify(token); !errors.Is(err, ErrTokenExpired) {
+		t.Errorf("err = %v, want ErrTokenExpired", err)
+	}
+}
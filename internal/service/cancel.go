@@ -4,27 +4,40 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/shiva/hintro/internal/model"
 	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/logctx"
 )
 
 // ─── Cancel Errors ─────────────────────────────────────────
 
-var (
-	ErrCannotCancel   = errors.New("ride request cannot be cancelled")
-	ErrAlreadyCancelled = errors.New("ride request is already cancelled")
-)
+var ErrCannotCancel = errors.New("ride request cannot be cancelled")
 
 // ─── CancelService ─────────────────────────────────────────
 
 // CancelService handles ride cancellations with proper state transitions
 // and integration with matching/booking (frees capacity) and pricing (invalidates surge cache).
 type CancelService struct {
-	bookingRepo *repository.BookingRepository
-	pricingRepo *repository.PricingRepository
+	bookingRepo cancelStore
+	pricingRepo surgeCacheInvalidator
+
+	// Counters, when set, has a cancelled pending request taken off demand
+	// and a freed cab added to supply.
+	Counters SurgeCounter
+}
+
+// cancelStore is the part of BookingRepository used by CancelService.
+type cancelStore interface {
+	CancelRide(ctx context.Context, requestID int64) (*repository.CancelResult, error)
+	CancelTrip(ctx context.Context, tripID int64) (*repository.TripCancelResult, error)
+}
+
+// surgeCacheInvalidator is the part of PricingRepository used by CancelService.
+type surgeCacheInvalidator interface {
+	InvalidateSurgeCache(ctx context.Context, location model.Location)
+	InvalidateSurgeCaches(ctx context.Context, locations []model.Location) int
 }
 
 // NewCancelService creates a cancel service.
@@ -46,17 +59,29 @@ func NewCancelService(
 //   - MATCHED  → CANCELLED: Decrement trip passenger_count, clear trip_id.
 //     If last passenger: cancel trip, set cab back to available.
 //     Matching: Trip becomes available for new bookings (or disappears if cancelled).
-//   - CONFIRMED, COMPLETED, CANCELLED: Returns ErrCannotCancel.
+//   - CANCELLED: Replay of an earlier cancel. Returns the original result
+//     with AlreadyCancelled set, so client retries succeed.
+//   - CONFIRMED, COMPLETED, EXPIRED: Returns ErrCannotCancel.
 //
 // Integration:
 //   - Invalidates surge cache for the request's origin area (demand/supply changed).
+//   - Adjusts the surge counters, if set.
 func (s *CancelService) CancelRide(ctx context.Context, requestID int64) (*repository.CancelResult, error) {
-	log.Printf("[cancel] Processing cancellation for request #%d", requestID)
+	ctx = logctx.WithRequestID(ctx, requestID)
+	logctx.Debugf(ctx, "[cancel] Processing cancellation for request #%d", requestID)
 
 	result, err := s.bookingRepo.CancelRide(ctx, requestID)
 	if err != nil {
 		return nil, s.classifyError(err)
 	}
+	if result.PreviousTrip != nil {
+		ctx = logctx.WithTripID(ctx, *result.PreviousTrip)
+	}
+	if result.AlreadyCancelled {
+		// Nothing changed, so the surge cache is still valid.
+		logctx.Printf(ctx, "[cancel] Request #%d already cancelled, replaying prior result", requestID)
+		return result, nil
+	}
 
 	// Invalidate surge cache for the origin area — demand/supply has changed.
 	// PENDING→cancelled: demand decreased. MATCHED→cancelled: supply may have increased (cab freed).
@@ -64,22 +89,60 @@ func (s *CancelService) CancelRide(ctx context.Context, requestID int64) (*repos
 		Lat: result.OriginLat,
 		Lon: result.OriginLon,
 	})
-	log.Printf("[cancel] Invalidated surge cache for origin (%.4f, %.4f)", result.OriginLat, result.OriginLon)
+	logctx.Debugf(ctx, "[cancel] Invalidated surge cache for origin (%.4f, %.4f)", result.OriginLat, result.OriginLon)
+	s.countCancel(ctx, result)
 
-	log.Printf("[cancel] ✓ Cancelled request #%d (trip_cancelled=%v, cab_freed=%v)",
+	logctx.Printf(ctx, "[cancel] ✓ Cancelled request #%d (trip_cancelled=%v, cab_freed=%v)",
 		requestID, result.TripCancelled, result.CabFreed)
 
 	return result, nil
 }
 
+// CancelTrip cancels a whole trip for an operator: every matched or
+// confirmed rider on it is cancelled, the trip is cancelled and its cab
+// freed, in one transaction (see BookingRepository.CancelTrip). Riders are
+// not returned to the pending pool; they must request again.
+//
+// Integration:
+//   - Invalidates the surge cache once for each distinct origin area
+//     among the cancelled riders.
+//   - Adds the freed cab back to the surge counters' supply.
+func (s *CancelService) CancelTrip(ctx context.Context, tripID int64) (*repository.TripCancelResult, error) {
+	ctx = logctx.WithTripID(ctx, tripID)
+
+	result, err := s.bookingRepo.CancelTrip(ctx, tripID)
+	if err != nil {
+		return nil, err
+	}
+
+	areas := s.pricingRepo.InvalidateSurgeCaches(ctx, result.Origins)
+	logctx.Debugf(ctx, "[cancel] Invalidated surge cache for %d origin areas", areas)
+	CountSurge(ctx, s.Counters, result.Surge)
+
+	logctx.Printf(ctx, "[cancel] ✓ Cancelled trip #%d with %d requests (cab #%d freed=%v)",
+		tripID, len(result.CancelledRequests), result.CabID, result.CabFreed)
+	return result, nil
+}
+
+// countCancel reports a cancellation to the surge counters: only a pending
+// request was counted as demand, and a freed cab is supply again.
+func (s *CancelService) countCancel(ctx context.Context, result *repository.CancelResult) {
+	if s.Counters == nil {
+		return
+	}
+	if result.PreviousStatus == model.RequestPending {
+		s.Counters.AddDemand(ctx, model.Location{Lat: result.OriginLat, Lon: result.OriginLon}, result.Direction, -1)
+	}
+	if result.CabLocation != nil {
+		s.Counters.AddSupply(ctx, *result.CabLocation, 1)
+	}
+}
+
 func (s *CancelService) classifyError(err error) error {
 	if err == nil {
 		return nil
 	}
 	errMsg := err.Error()
-	if strings.Contains(errMsg, "already cancelled") {
-		return ErrAlreadyCancelled
-	}
 	if strings.Contains(errMsg, "cannot cancel") || strings.Contains(errMsg, "completed") || strings.Contains(errMsg, "confirmed") {
 		return ErrCannotCancel
 	}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// fakeCancelStore holds the active riders of each trip.
+type fakeCancelStore struct {
+	riders    map[int64]map[int64]model.Location // trip → request → origin
+	cancelled map[int64]bool                     // request → cancelled
+}
+
+func (f *fakeCancelStore) CancelRide(context.Context, int64) (*repository.CancelResult, error) {
+	return nil, errors.New("not used")
+}
+
+func (f *fakeCancelStore) CancelTrip(_ context.Context, tripID int64) (*repository.TripCancelResult, error) {
+	riders, ok := f.riders[tripID]
+	if !ok {
+		return nil, fmt.Errorf("cancel trip %d: %w", tripID, repository.ErrTripNotFound)
+	}
+	result := &repository.TripCancelResult{TripID: tripID, CabFreed: true}
+	for id, origin := range riders {
+		f.cancelled[id] = true
+		result.CancelledRequests = append(result.CancelledRequests, id)
+		result.Origins = append(result.Origins, origin)
+	}
+	delete(f.riders, tripID)
+	return result, nil
+}
+
+// fakeSurgeCache records the locations it was asked to invalidate.
+type fakeSurgeCache struct{ invalidated []model.Location }
+
+func (f *fakeSurgeCache) InvalidateSurgeCache(_ context.Context, loc model.Location) {
+	f.invalidated = append(f.invalidated, loc)
+}
+
+func (f *fakeSurgeCache) InvalidateSurgeCaches(_ context.Context, locs []model.Location) int {
+	f.invalidated = append(f.invalidated, locs...)
+	return len(locs)
+}
+
+func TestCancelTrip_CancelsRidersAndInvalidatesSurge(t *testing.T) {
+	store := &fakeCancelStore{
+		riders: map[int64]map[int64]model.Location{
+			7: {
+				10: {Lat: 28.70, Lon: 77.10},
+				11: {Lat: 28.65, Lon: 77.12},
+			},
+			8: {12: {Lat: 28.60, Lon: 77.00}},
+		},
+		cancelled: map[int64]bool{},
+	}
+	cache := &fakeSurgeCache{}
+	svc := &CancelService{bookingRepo: store, pricingRepo: cache}
+
+	result, err := svc.CancelTrip(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("CancelTrip: %v", err)
+	}
+	if len(result.CancelledRequests) != 2 || !store.cancelled[10] || !store.cancelled[11] {
+		t.Errorf("cancelled = %v, want requests 10 and 11", store.cancelled)
+	}
+	if store.cancelled[12] {
+		t.Error("request 12 on another trip was cancelled")
+	}
+	if len(cache.invalidated) != 2 {
+		t.Fatalf("invalidated = %v, want both riders' origins", cache.invalidated)
+	}
+	for _, want := range []model.Location{{Lat: 28.70, Lon: 77.10}, {Lat: 28.65, Lon: 77.12}} {
+		found := false
+		for _, got := range cache.invalidated {
+			found = found || got == want
+		}
+		if !found {
+			t.Errorf("origin %v not invalidated (got %v)", want, cache.invalidated)
+		}
+	}
+}
+
+func TestCancelTrip_NotFoundLeavesCacheAlone(t *testing.T) {
+	cache := &fakeSurgeCache{}
+	svc := &CancelService{bookingRepo: &fakeCancelStore{cancelled: map[int64]bool{}}, pricingRepo: cache}
+
+	if _, err := svc.CancelTrip(context.Background(), 9); !errors.Is(err, repository.ErrTripNotFound) {
+		t.Fatalf("err = %v, want ErrTripNotFound", err)
+	}
+	if len(cache.invalidated) != 0 {
+		t.Errorf("invalidated = %v, want nothing", cache.invalidated)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// ─── Pickup Clustering ──────────────────────────────────────
+
+// PendingFinder is the part of repository.RideRepository ClusterPending
+// reads.
+type PendingFinder interface {
+	FindPendingRequestsNearby(ctx context.Context, origin model.Location, direction model.TripDirection, radiusMeters int, excludeID int64, limit int) ([]model.RideRequest, error)
+}
+
+// clusterRadius is MatchConfig.ClusterRadiusM, or DefaultClusterRadiusM
+// when unset.
+func (s *MatchingService) clusterRadius() int {
+	if s.config.ClusterRadiusM <= 0 {
+		return DefaultClusterRadiusM
+	}
+	return s.config.ClusterRadiusM
+}
+
+// ClusterPending returns up to MaxCandidates other pending requests going
+// req's way whose pickup is within MatchConfig.ClusterRadiusM of req's,
+// oldest first: the riders req could seed a new trip with. The radius is
+// the same for every rider; req's own tolerance_meters plays no part, as
+// it bounds detour rather than who is nearby. Returns nil when
+// MatchingService.Pending is not set.
+func (s *MatchingService) ClusterPending(ctx context.Context, req *model.RideRequest) ([]model.RideRequest, error) {
+	if s.Pending == nil {
+		return nil, nil
+	}
+	nearby, err := s.Pending.FindPendingRequestsNearby(ctx, req.Origin, req.Direction, s.clusterRadius(), req.ID, MaxCandidates)
+	if err != nil {
+		return nil, fmt.Errorf("cluster pending near request %d: %w", req.ID, err)
+	}
+	return nearby, nil
+}
+
+// CanJoinSeed reports whether req could join the trip seed would start by
+// booking now: the hard constraints a real trip gets, against a trip
+// carrying seed alone. No cab is claimed yet, so seats and bags are left
+// to the booking. No I/O.
+func (s *MatchingService) CanJoinSeed(ctx context.Context, seed, req *model.RideRequest) bool {
+	if seed.Direction != req.Direction {
+		return false
+	}
+	departs := departure(seed.ScheduledAt, s.now())
+	trip := &model.CandidateTrip{
+		Direction:       seed.Direction,
+		SeatCapacity:    seed.SeatsNeeded + req.SeatsNeeded,
+		LuggageCapacity: seed.LuggageCount + req.LuggageCount,
+		Accessible:      seed.RequiresAccessible,
+		CurrentLoad:     seed.SeatsNeeded,
+		CurrentLuggage:  seed.LuggageCount,
+		Route:           s.buildRoute([]model.Location{seed.Origin}, req),
+		DepartsFrom:     departs,
+		DepartsUntil:    departs,
+	}
+	if req.Direction == model.DirectionFromAirport {
+		trip.Dropoffs = []model.Dropoff{{Destination: seed.Destination, DeadlineAt: seed.DeadlineAt}}
+	}
+	_, _, ok := s.scoreCandidate(ctx, trip, req)
+	return ok
+}
@@ -0,0 +1,93 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+// geoPending serves FindPendingRequestsNearby from a fixed set of requests,
+// filtering by distance like the PostGIS query does.
+type geoPending struct {
+	requests []model.RideRequest
+	radii    []int
+}
+
+func (g *geoPending) FindPendingRequestsNearby(_ context.Context, origin model.Location, direction model.TripDirection, radiusMeters int, excludeID int64, limit int) ([]model.RideRequest, error) {
+	g.radii = append(g.radii, radiusMeters)
+	var out []model.RideRequest
+	for _, rr := range g.requests {
+		if rr.ID != excludeID && rr.Direction == direction && geo.HaversineM(origin, rr.Origin) <= float64(radiusMeters) && len(out) < limit {
+			out = append(out, rr)
+		}
+	}
+	return out, nil
+}
+
+// pendingAround has riders 300 m and 1500 m north of pickup, both
+// heading to the airport.
+func pendingAround(pickup model.Location) *geoPending {
+	north := func(m float64) model.Location { return model.Location{Lat: pickup.Lat + m/111_320, Lon: pickup.Lon} }
+	return &geoPending{requests: []model.RideRequest{
+		{ID: 2, Origin: north(300), Direction: model.DirectionToAirport},
+		{ID: 3, Origin: north(1500), Direction: model.DirectionToAirport},
+	}}
+}
+
+func clusterIDs(t *testing.T, svc *MatchingService, req *model.RideRequest) []int64 {
+	t.Helper()
+	nearby, err := svc.ClusterPending(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, len(nearby))
+	for i, rr := range nearby {
+		ids[i] = rr.ID
+	}
+	return ids
+}
+
+func TestClusterPending_UsesClusterRadiusNotTolerance(t *testing.T) {
+	pickup := model.Location{Lat: 28.70, Lon: 77.10}
+
+	tests := []struct {
+		name          string
+		clusterRadius int
+		tolerance     int
+		wantRadius    int
+		want          []int64
+	}{
+		{"tight cluster, generous tolerance", 500, 2000, 500, []int64{2}},
+		{"wide cluster, tight tolerance", 2000, 200, 2000, []int64{2, 3}},
+		{"default cluster radius", 0, 200, DefaultClusterRadiusM, []int64{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultMatchConfig()
+			cfg.ClusterRadiusM = tt.clusterRadius
+			svc := NewMatchingService(nil, cfg)
+			pending := pendingAround(pickup)
+			svc.Pending = pending
+
+			req := &model.RideRequest{ID: 1, Origin: pickup, Direction: model.DirectionToAirport, ToleranceMeters: tt.tolerance}
+			got := clusterIDs(t, svc, req)
+
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("clustered %v, want %v", got, tt.want)
+			}
+			if len(pending.radii) != 1 || pending.radii[0] != tt.wantRadius {
+				t.Errorf("searched %v m, want the cluster radius %d m", pending.radii, tt.wantRadius)
+			}
+		})
+	}
+}
+
+func TestClusterPending_OffWithoutFinder(t *testing.T) {
+	svc := NewMatchingService(nil, DefaultMatchConfig())
+	if got := clusterIDs(t, svc, &model.RideRequest{ID: 1}); len(got) != 0 {
+		t.Errorf("clustered %v without a PendingFinder, want none", got)
+	}
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/geo"
+	"github.com/shiva/hintro/pkg/logctx"
+)
+
+// ─── Drop-off Deadlines ─────────────────────────────────────
+
+var (
+	// ErrDeadlineNotFromAirport is returned for a deadline_at on a
+	// to_airport ride, whose drop-off is the airport for everyone.
+	ErrDeadlineNotFromAirport = errors.New("deadline_at is only accepted on from_airport rides")
+
+	// ErrDeadlineInPast is returned for a deadline_at that is not after
+	// the ride's departure (scheduled_at, or now).
+	ErrDeadlineInPast = errors.New("deadline_at is not after departure")
+)
+
+// ValidateDeadlineAt checks a requested drop-off deadline. A nil
+// deadlineAt is always valid.
+func ValidateDeadlineAt(direction model.TripDirection, deadlineAt, scheduledAt *time.Time, now time.Time) error {
+	if deadlineAt == nil {
+		return nil
+	}
+	if direction != model.DirectionFromAirport {
+		return ErrDeadlineNotFromAirport
+	}
+	if !deadlineAt.After(departure(scheduledAt, now)) {
+		return ErrDeadlineInPast
+	}
+	return nil
+}
+
+// departure is when a ride leaves: its scheduled_at if later than now.
+func departure(scheduledAt *time.Time, now time.Time) time.Time {
+	if scheduledAt != nil && scheduledAt.After(now) {
+		return *scheduledAt
+	}
+	return now
+}
+
+// ProjectDropoffs plans a from_airport route from start through every
+// drop-off (geo.PlanRoute, as the stored trip route is planned) and
+// returns each drop-off's projected arrival, in the order given, for a
+// cab leaving at depart.
+func ProjectDropoffs(start model.Location, dropoffs []model.Dropoff, depart time.Time) []time.Time {
+	stops := make([]model.Location, len(dropoffs))
+	for i, d := range dropoffs {
+		stops[i] = d.Destination
+	}
+	route := geo.PlanRoute(stops, start)
+	slices.Reverse(route) // start is planned as the fixed end; the cab leaves from it.
+	times := geo.CumulativeTimesMinutes(route)
+
+	arrivals := make([]time.Time, len(dropoffs))
+	for i, d := range dropoffs {
+		minutes := times[slices.Index(route, d.Destination)]
+		arrivals[i] = depart.Add(time.Duration(minutes * float64(time.Minute)))
+	}
+	return arrivals
+}
+
+// meetsDeadlines reports whether adding req to a from_airport trip keeps
+// every rider's drop-off, req's and those already on the trip, within
+// their deadline_at. Other directions and trips without deadlines always
+// pass. No I/O.
+func (s *MatchingService) meetsDeadlines(ctx context.Context, ct *model.CandidateTrip, req *model.RideRequest) bool {
+	if req.Direction != model.DirectionFromAirport {
+		return true
+	}
+	dropoffs := append(slices.Clip(ct.Dropoffs), model.Dropoff{Destination: req.Destination, DeadlineAt: req.DeadlineAt})
+	if !slices.ContainsFunc(dropoffs, func(d model.Dropoff) bool { return d.DeadlineAt != nil }) {
+		return true
+	}
+
+	start := s.config.Airport
+	if start == (model.Location{}) {
+		start = req.Origin
+	}
+	arrivals := ProjectDropoffs(start, dropoffs, departure(req.ScheduledAt, s.now()))
+	for i, d := range dropoffs {
+		if d.DeadlineAt != nil && arrivals[i].After(*d.DeadlineAt) {
+			logctx.Debugf(ctx, "[match]   Trip #%d: drop-off %d projected %s, deadline %s",
+				ct.TripID, i, arrivals[i].Format(time.RFC3339), d.DeadlineAt.Format(time.RFC3339))
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestValidateDeadlineAt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { t := now.Add(d); return &t }
+
+	for _, tt := range []struct {
+		name      string
+		direction model.TripDirection
+		deadline  *time.Time
+		scheduled *time.Time
+		want      error
+	}{
+		{"none", model.DirectionToAirport, nil, nil, nil},
+		{"from airport, ahead", model.DirectionFromAirport, at(time.Hour), nil, nil},
+		{"to airport", model.DirectionToAirport, at(time.Hour), nil, ErrDeadlineNotFromAirport},
+		{"in the past", model.DirectionFromAirport, at(-time.Minute), nil, ErrDeadlineInPast},
+		{"before scheduled departure", model.DirectionFromAirport, at(time.Hour), at(2 * time.Hour), ErrDeadlineInPast},
+		{"after scheduled departure", model.DirectionFromAirport, at(3 * time.Hour), at(2 * time.Hour), nil},
+	} {
+		if err := ValidateDeadlineAt(tt.direction, tt.deadline, tt.scheduled, now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// deadlineScenario is a from_airport trip with one rider going north to
+// far (32 min from the airport alone) and a new rider whose drop-off east
+// of the way there pushes far's arrival to about 37 min.
+func deadlineScenario() (*MatchingService, *model.CandidateTrip, *model.RideRequest, time.Time) {
+	airport := model.Location{Lat: 28.5562, Lon: 77.0889}
+	far := model.Location{Lat: 28.70, Lon: 77.10}
+	east := model.Location{Lat: 28.63, Lon: 77.14}
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+
+	cfg := DefaultMatchConfig()
+	cfg.Airport = airport
+	svc := NewMatchingService(nil, cfg)
+	svc.now = func() time.Time { return now }
+
+	trip := &model.CandidateTrip{
+		TripID: 7, CabID: 3, Direction: model.DirectionFromAirport,
+		SeatCapacity: 4, LuggageCapacity: 3, CurrentLoad: 1,
+		Route:    []model.Location{airport},
+		Dropoffs: []model.Dropoff{{Destination: far}},
+	}
+	req := &model.RideRequest{
+		ID: 42, Origin: airport, Destination: east, Direction: model.DirectionFromAirport,
+		SeatsNeeded: 1, ToleranceMeters: 2000,
+	}
+	return svc, trip, req, now
+}
+
+func TestScoreCandidate_DeadlineOnTripBlocksPool(t *testing.T) {
+	svc, trip, req, now := deadlineScenario()
+	ctx := context.Background()
+
+	if _, _, ok := svc.scoreCandidate(ctx, trip, req); !ok {
+		t.Fatal("without deadlines the pool should be valid")
+	}
+
+	// 35 min is enough for far alone, not with the stop east first.
+	deadline := now.Add(35 * time.Minute)
+	trip.Dropoffs[0].DeadlineAt = &deadline
+	if _, _, ok := svc.scoreCandidate(ctx, trip, req); ok {
+		t.Error("pool accepted although it drops the existing rider after their deadline")
+	}
+
+	deadline = now.Add(40 * time.Minute)
+	if _, _, ok := svc.scoreCandidate(ctx, trip, req); !ok {
+		t.Error("pool rejected although every drop-off is within its deadline")
+	}
+}
+
+func TestScoreCandidate_NewRiderDeadline(t *testing.T) {
+	svc, trip, req, now := deadlineScenario()
+
+	// east is about 19 min from the airport.
+	tight, loose := now.Add(15*time.Minute), now.Add(25*time.Minute)
+	req.DeadlineAt = &tight
+	if _, _, ok := svc.scoreCandidate(context.Background(), trip, req); ok {
+		t.Error("pool accepted although the new rider arrives after their deadline")
+	}
+	req.DeadlineAt = &loose
+	if _, _, ok := svc.scoreCandidate(context.Background(), trip, req); !ok {
+		t.Error("pool rejected although the new rider arrives in time")
+	}
+}
+
+func TestProjectDropoffs_OrderedFromStart(t *testing.T) {
+	airport := model.Location{Lat: 28.5562, Lon: 77.0889}
+	near := model.Location{Lat: 28.63, Lon: 77.095}
+	far := model.Location{Lat: 28.70, Lon: 77.10}
+	depart := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+
+	got := ProjectDropoffs(airport, []model.Dropoff{{Destination: far}, {Destination: near}}, depart)
+	if !got[1].Before(got[0]) {
+		t.Fatalf("arrivals = %v, want near dropped before far", got)
+	}
+	if d := got[0].Sub(depart); d < 31*time.Minute || d > 33*time.Minute {
+		t.Errorf("far arrival after %s, want about 32 min at 30 km/h", d)
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"slices"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+// ─── ETA Errors ─────────────────────────────────────────────
+
+var (
+	// ErrTripNotStarted is returned for ETAs on a trip that is not in progress.
+	ErrTripNotStarted = errors.New("trip is not in progress")
+
+	// ErrNoCabLocation is returned when the trip's cab has never reported
+	// a position, so there is nowhere to measure from.
+	ErrNoCabLocation = errors.New("cab location unknown")
+)
+
+// StopETA is one rider's estimated pickup and drop-off time, in minutes
+// from now. PickupMinutes is nil once the rider is aboard.
+type StopETA struct {
+	RequestID      int64    `json:"request_id"`
+	PickupMinutes  *float64 `json:"pickup_eta_minutes,omitempty"`
+	DropoffMinutes float64  `json:"dropoff_eta_minutes"`
+}
+
+// TripETAs estimates each rider's pickup and drop-off on an in-progress
+// trip. The cab follows the trip's planned route (route.Path) from its
+// last reported position, skipping the stops it has already passed (see
+// nextStop; the schema does not record pickups, so this is a geometric
+// guess). Times use geo's constant-speed estimate and are rounded to 0.1
+// minute.
+//
+// A rider whose pickup is passed is aboard and gets no pickup ETA; on a
+// from_airport trip that is everyone, since all board at the airport. A
+// drop-off already passed is due now (0).
+func TripETAs(route *repository.TripRoute) ([]StopETA, error) {
+	if route.Status != model.TripInProgress {
+		return nil, ErrTripNotStarted
+	}
+	if route.CabLocation == nil {
+		return nil, ErrNoCabLocation
+	}
+	if len(route.Path) == 0 {
+		return []StopETA{}, nil
+	}
+
+	// minutes[i] is the time to reach route.Path[i]; passed stops stay 0.
+	next := nextStop(route.Path, *route.CabLocation, route.Direction == model.DirectionFromAirport)
+	ahead := append([]model.Location{*route.CabLocation}, route.Path[next:]...)
+	times := geo.CumulativeTimesMinutes(ahead)
+	minutes := make([]float64, len(route.Path))
+	for i := next; i < len(route.Path); i++ {
+		minutes[i] = math.Round(times[1+i-next]*10) / 10
+	}
+
+	last := len(route.Path) - 1
+	etas := make([]StopETA, len(route.Riders))
+	for i, rr := range route.Riders {
+		eta := StopETA{RequestID: rr.ID}
+		if route.Direction == model.DirectionFromAirport {
+			if dropoff := slices.Index(route.Path, rr.Destination); dropoff >= 0 {
+				eta.DropoffMinutes = minutes[dropoff]
+			}
+		} else {
+			if pickup := slices.Index(route.Path, rr.Origin); pickup >= next {
+				eta.PickupMinutes = &minutes[pickup]
+			}
+			eta.DropoffMinutes = minutes[last]
+		}
+		etas[i] = eta
+	}
+	return etas, nil
+}
+
+// nextStop is the index of the first stop on path the cab at cab has not
+// yet reached. The cab is placed on the leg it is closest to, measured as
+// the detour of going from the leg's start through cab to its end, or
+// still heading for path[0] if it is closer to that than to any leg. With
+// boarded (a from_airport trip, whose path starts at the airport) path[0]
+// is always passed.
+func nextStop(path []model.Location, cab model.Location, boarded bool) int {
+	best, bestDetour := 0, geo.HaversineKm(cab, path[0])
+	if boarded {
+		best, bestDetour = min(1, len(path)-1), math.Inf(1)
+	}
+	for i := 1; i < len(path); i++ {
+		detour := geo.HaversineKm(path[i-1], cab) + geo.HaversineKm(cab, path[i]) - geo.HaversineKm(path[i-1], path[i])
+		if detour < bestDetour {
+			best, bestDetour = i, detour
+		}
+	}
+	return best
+}
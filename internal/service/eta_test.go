@@ -0,0 +1,140 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+var (
+	etaCab     = model.Location{Lat: 28.7200, Lon: 77.1000}
+	etaAirport = model.Location{Lat: 28.5562, Lon: 77.0889}
+)
+
+// twoStopTrip is an in-progress to_airport trip with riders 11 and 12,
+// the cab north of both pickups.
+func twoStopTrip() *repository.TripRoute {
+	first := model.Location{Lat: 28.7041, Lon: 77.1025}
+	second := model.Location{Lat: 28.6500, Lon: 77.1200}
+	return &repository.TripRoute{
+		TripID:      3,
+		Status:      model.TripInProgress,
+		Direction:   model.DirectionToAirport,
+		CabLocation: &etaCab,
+		Riders: []model.RideRequest{
+			{ID: 11, Origin: first, Destination: etaAirport},
+			{ID: 12, Origin: second, Destination: etaAirport},
+		},
+		Path: []model.Location{first, second, etaAirport},
+	}
+}
+
+func TestTripETAs_TwoStopInProgressTrip(t *testing.T) {
+	route := twoStopTrip()
+	etas, err := TripETAs(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(etas) != 2 || etas[0].RequestID != 11 || etas[1].RequestID != 12 {
+		t.Fatalf("etas = %+v, want riders 11 and 12 in order", etas)
+	}
+
+	times := geo.CumulativeTimesMinutes([]model.Location{etaCab, route.Path[0], route.Path[1], etaAirport})
+	for i, w := range []struct{ pickup, dropoff float64 }{{times[1], times[3]}, {times[2], times[3]}} {
+		got := etas[i]
+		if got.PickupMinutes == nil || math.Abs(*got.PickupMinutes-w.pickup) > 0.05 || math.Abs(got.DropoffMinutes-w.dropoff) > 0.05 {
+			t.Errorf("rider %d: got %+v, want pickup ≈ %.1f, drop-off ≈ %.1f", got.RequestID, got, w.pickup, w.dropoff)
+		}
+	}
+	if !(*etas[0].PickupMinutes < *etas[1].PickupMinutes && *etas[1].PickupMinutes < etas[0].DropoffMinutes) {
+		t.Errorf("etas = %+v, want pickups in order and before drop-offs", etas)
+	}
+	// Both riders are dropped at the airport.
+	if etas[0].DropoffMinutes != etas[1].DropoffMinutes {
+		t.Errorf("drop-offs = %.1f and %.1f, want equal at the airport", etas[0].DropoffMinutes, etas[1].DropoffMinutes)
+	}
+}
+
+func TestTripETAs_FollowsPlannedStopOrder(t *testing.T) {
+	// Rider 12 booked first but the route picks up rider 11 first.
+	route := twoStopTrip()
+	route.Riders[0], route.Riders[1] = route.Riders[1], route.Riders[0]
+	etas, err := TripETAs(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byID := map[int64]StopETA{}
+	for _, eta := range etas {
+		byID[eta.RequestID] = eta
+	}
+	if p11, p12 := byID[11].PickupMinutes, byID[12].PickupMinutes; p11 == nil || p12 == nil || *p11 >= *p12 {
+		t.Errorf("etas = %+v, want rider 11 picked up before rider 12 as planned", etas)
+	}
+}
+
+func TestTripETAs_SkipsPassedPickups(t *testing.T) {
+	// The cab is between the two pickups: rider 11 is aboard.
+	route := twoStopTrip()
+	between := model.Location{Lat: 28.6800, Lon: 77.1100}
+	route.CabLocation = &between
+	etas, err := TripETAs(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if etas[0].PickupMinutes != nil {
+		t.Errorf("rider 11 = %+v, want no pickup ETA once aboard", etas[0])
+	}
+	want := geo.CumulativeTimesMinutes([]model.Location{between, route.Path[1], etaAirport})
+	if etas[1].PickupMinutes == nil || math.Abs(*etas[1].PickupMinutes-want[1]) > 0.05 {
+		t.Errorf("rider 12 = %+v, want pickup ≈ %.1f from the cab", etas[1], want[1])
+	}
+	for _, eta := range etas {
+		if math.Abs(eta.DropoffMinutes-want[2]) > 0.05 {
+			t.Errorf("rider %d drop-off = %.1f, want ≈ %.1f", eta.RequestID, eta.DropoffMinutes, want[2])
+		}
+	}
+}
+
+func TestTripETAs_FromAirportRidersAreAboard(t *testing.T) {
+	near := model.Location{Lat: 28.6000, Lon: 77.0900}
+	far := model.Location{Lat: 28.7000, Lon: 77.1000}
+	route := &repository.TripRoute{
+		TripID:      4,
+		Status:      model.TripInProgress,
+		Direction:   model.DirectionFromAirport,
+		CabLocation: &etaAirport,
+		Riders: []model.RideRequest{
+			{ID: 21, Origin: etaAirport, Destination: far},
+			{ID: 22, Origin: etaAirport, Destination: near},
+		},
+		Path: []model.Location{etaAirport, near, far},
+	}
+	etas, err := TripETAs(route)
+	if err != nil {
+		t.Fatal(err)
+	}
+	times := geo.CumulativeTimesMinutes(route.Path)
+	for i, want := range []float64{times[2], times[1]} {
+		if etas[i].PickupMinutes != nil || math.Abs(etas[i].DropoffMinutes-want) > 0.05 {
+			t.Errorf("rider %d = %+v, want no pickup ETA and drop-off ≈ %.1f", etas[i].RequestID, etas[i], want)
+		}
+	}
+}
+
+func TestTripETAs_NotStartedOrNoCabLocation(t *testing.T) {
+	planned := twoStopTrip()
+	planned.Status = model.TripPlanned
+	if _, err := TripETAs(planned); !errors.Is(err, ErrTripNotStarted) {
+		t.Errorf("planned trip: err = %v, want ErrTripNotStarted", err)
+	}
+
+	lost := twoStopTrip()
+	lost.CabLocation = nil
+	if _, err := TripETAs(lost); !errors.Is(err, ErrNoCabLocation) {
+		t.Errorf("no cab location: err = %v, want ErrNoCabLocation", err)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// ─── Request Expiry ─────────────────────────────────────────
+
+// PendingExpirer is the subset of the ride request repository the sweeper needs.
+type PendingExpirer interface {
+	ExpireStalePending(ctx context.Context, ttl time.Duration) (int, []repository.SurgeChange, error)
+}
+
+// ExpirySweeper periodically moves PENDING requests older than the TTL to
+// 'expired', so requests that never match stop inflating surge demand and
+// drop out of the matching pool.
+type ExpirySweeper struct {
+	repo     PendingExpirer
+	ttl      time.Duration
+	interval time.Duration
+
+	// Counters, when set, has expired requests taken out of demand.
+	Counters SurgeCounter
+}
+
+// NewExpirySweeper creates a sweeper that runs every interval.
+func NewExpirySweeper(repo PendingExpirer, ttl, interval time.Duration) *ExpirySweeper {
+	return &ExpirySweeper{repo: repo, ttl: ttl, interval: interval}
+}
+
+// Run sweeps until ctx is cancelled.
+func (s *ExpirySweeper) Run(ctx context.Context) {
+	log.Printf("[expiry] Sweeper started (ttl=%s, interval=%s)", s.ttl, s.interval)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[expiry] Sweeper stopped")
+			return
+		case <-ticker.C:
+			if _, err := s.SweepOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[expiry] WARNING: sweep failed: %v", err)
+			}
+		}
+	}
+}
+
+// SweepOnce expires stale pending requests and returns how many were expired.
+func (s *ExpirySweeper) SweepOnce(ctx context.Context) (int, error) {
+	n, surge, err := s.repo.ExpireStalePending(ctx, s.ttl)
+	if err != nil {
+		return 0, err
+	}
+	CountSurge(ctx, s.Counters, surge)
+	if n > 0 {
+		log.Printf("[expiry] Expired %d pending requests older than %s", n, s.ttl)
+	}
+	return n, nil
+}
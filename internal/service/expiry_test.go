@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// fakePendingPool models ride requests by age; expiring removes them from demand.
+type fakePendingPool struct {
+	ages    map[int64]time.Duration
+	expired map[int64]bool
+	gotTTL  time.Duration
+}
+
+// ExpireStalePending reports every request as going to the airport from
+// the origin.
+func (f *fakePendingPool) ExpireStalePending(_ context.Context, ttl time.Duration) (int, []repository.SurgeChange, error) {
+	f.gotTTL = ttl
+	var surge []repository.SurgeChange
+	for id, age := range f.ages {
+		if !f.expired[id] && age > ttl {
+			f.expired[id] = true
+			surge = append(surge, repository.SurgeChange{Direction: model.DirectionToAirport, Demand: -1})
+		}
+	}
+	return len(surge), surge, nil
+}
+
+func (f *fakePendingPool) demand() int {
+	n := 0
+	for id := range f.ages {
+		if !f.expired[id] {
+			n++
+		}
+	}
+	return n
+}
+
+func TestExpirySweeper_ExpiresOnlyStaleRequests(t *testing.T) {
+	pool := &fakePendingPool{
+		ages: map[int64]time.Duration{
+			1: 2 * time.Hour,
+			2: 5 * time.Minute,
+		},
+		expired: map[int64]bool{},
+	}
+	counters := newFakeSurgeCounter()
+	counters.AddDemand(context.Background(), model.Location{}, model.DirectionToAirport, 2) // both requests
+	sweeper := NewExpirySweeper(pool, 30*time.Minute, time.Minute)
+	sweeper.Counters = counters
+
+	n, err := sweeper.SweepOnce(context.Background())
+	if err != nil {
+		t.Fatalf("SweepOnce: %v", err)
+	}
+	if pool.gotTTL != 30*time.Minute {
+		t.Errorf("ttl passed = %s, want 30m", pool.gotTTL)
+	}
+	if n != 1 || !pool.expired[1] || pool.expired[2] {
+		t.Errorf("expired = %v (n=%d), want only request 1", pool.expired, n)
+	}
+	if got := pool.demand(); got != 1 {
+		t.Errorf("demand after sweep = %d, want 1", got)
+	}
+	if got := counters.demand[model.Location{}][model.DirectionToAirport]; got != 1 {
+		t.Errorf("counted demand after sweep = %d, want 1", got)
+	}
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/shiva/hintro/pkg/logctx"
+)
+
+// ─── Fleet Limits ───────────────────────────────────────────
+
+// ErrGroupTooLarge is returned when a request needs more seats than any
+// cab in the fleet has. Such a request could never be matched or booked.
+var ErrGroupTooLarge = errors.New("no cab can carry this many passengers")
+
+// GroupTooLargeError is the error returned by FleetLimits.CheckGroupSize.
+// It wraps ErrGroupTooLarge.
+type GroupTooLargeError struct {
+	SeatsNeeded int
+	MaxSeats    int // Largest cab in the fleet.
+}
+
+func (e *GroupTooLargeError) Error() string {
+	return fmt.Sprintf("%d seats needed, largest cab has %d: %v", e.SeatsNeeded, e.MaxSeats, ErrGroupTooLarge)
+}
+
+func (e *GroupTooLargeError) Unwrap() error { return ErrGroupTooLarge }
+
+// FleetCapacityReader is the subset of the cab repository FleetLimits needs.
+type FleetCapacityReader interface {
+	MaxSeatCapacity(ctx context.Context) (int, error)
+}
+
+// FleetLimits rejects ride requests no cab in the fleet can carry. The
+// largest cab's seat count is cached for ttl, since the fleet changes far
+// less often than requests arrive.
+type FleetLimits struct {
+	repo FleetCapacityReader
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu        sync.Mutex
+	maxSeats  int
+	fetchedAt time.Time // zero until the first successful read
+}
+
+// NewFleetLimits creates a FleetLimits that re-reads the fleet every ttl.
+func NewFleetLimits(repo FleetCapacityReader, ttl time.Duration) *FleetLimits {
+	return &FleetLimits{repo: repo, ttl: ttl, now: time.Now}
+}
+
+// CheckGroupSize returns a *GroupTooLargeError if seatsNeeded exceeds the
+// largest cab. It fails open: with no cabs, or if the fleet cannot be
+// read, the request is allowed and simply waits for capacity.
+func (f *FleetLimits) CheckGroupSize(ctx context.Context, seatsNeeded int) error {
+	maxSeats, err := f.maxSeatCapacity(ctx)
+	if err != nil {
+		logctx.Warnf(ctx, "[fleet] WARNING: cannot read max seat capacity, skipping group check: %v", err)
+		return nil
+	}
+	if maxSeats > 0 && seatsNeeded > maxSeats {
+		return &GroupTooLargeError{SeatsNeeded: seatsNeeded, MaxSeats: maxSeats}
+	}
+	return nil
+}
+
+// maxSeatCapacity returns the cached largest cab size, refreshing it once
+// ttl has passed. The lock is held across the read so concurrent callers
+// after expiry trigger one query, not one each.
+func (f *FleetLimits) maxSeatCapacity(ctx context.Context) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.fetchedAt.IsZero() && f.now().Sub(f.fetchedAt) < f.ttl {
+		return f.maxSeats, nil
+	}
+	seats, err := f.repo.MaxSeatCapacity(ctx)
+	if err != nil {
+		return 0, err
+	}
+	f.maxSeats, f.fetchedAt = seats, f.now()
+	return seats, nil
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fleetReader serves a fixed max seat count and counts reads.
+type fleetReader struct {
+	seats int
+	err   error
+	reads int
+}
+
+func (f *fleetReader) MaxSeatCapacity(context.Context) (int, error) {
+	f.reads++
+	return f.seats, f.err
+}
+
+func TestCheckGroupSize_RejectsGroupLargerThanAnyCab(t *testing.T) {
+	limits := NewFleetLimits(&fleetReader{seats: 4}, time.Minute)
+
+	err := limits.CheckGroupSize(context.Background(), 6)
+	var tooLarge *GroupTooLargeError
+	if !errors.As(err, &tooLarge) || !errors.Is(err, ErrGroupTooLarge) {
+		t.Fatalf("err = %v, want *GroupTooLargeError wrapping ErrGroupTooLarge", err)
+	}
+	if tooLarge.SeatsNeeded != 6 || tooLarge.MaxSeats != 4 {
+		t.Errorf("error = %+v, want 6 needed, 4 max", tooLarge)
+	}
+	for _, seats := range []int{1, 4} {
+		if err := limits.CheckGroupSize(context.Background(), seats); err != nil {
+			t.Errorf("%d seats: err = %v, want nil", seats, err)
+		}
+	}
+}
+
+func TestCheckGroupSize_CachesFleetForTTL(t *testing.T) {
+	reader := &fleetReader{seats: 4}
+	limits := NewFleetLimits(reader, time.Minute)
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	limits.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		_ = limits.CheckGroupSize(context.Background(), 6)
+	}
+	if reader.reads != 1 {
+		t.Fatalf("reads = %d within TTL, want 1", reader.reads)
+	}
+
+	// A 6-seat van joins; it is seen once the cached value expires.
+	reader.seats = 6
+	now = now.Add(time.Minute)
+	if err := limits.CheckGroupSize(context.Background(), 6); err != nil || reader.reads != 2 {
+		t.Errorf("after TTL: err = %v, reads = %d; want nil and 2", err, reader.reads)
+	}
+}
+
+func TestCheckGroupSize_FailsOpen(t *testing.T) {
+	tests := map[string]*fleetReader{
+		"no cabs":     {seats: 0},
+		"read failed": {err: errors.New("connection refused")},
+	}
+	for name, reader := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := NewFleetLimits(reader, time.Minute).CheckGroupSize(context.Background(), 6); err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestCheckGroupSize_RetriesAfterReadError(t *testing.T) {
+	reader := &fleetReader{err: errors.New("connection refused")}
+	limits := NewFleetLimits(reader, time.Minute)
+	_ = limits.CheckGroupSize(context.Background(), 6)
+
+	reader.seats, reader.err = 4, nil
+	if err := limits.CheckGroupSize(context.Background(), 6); !errors.Is(err, ErrGroupTooLarge) {
+		t.Errorf("err = %v, want ErrGroupTooLarge once the fleet is readable", err)
+	}
+}
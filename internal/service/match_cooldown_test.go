@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+// countingStore is a MatchStore with one pending request and no trips
+// nearby; it counts the database reads matching makes.
+type countingStore struct {
+	requestReads, candidateQueries int
+}
+
+func (c *countingStore) GetRideRequest(_ context.Context, id int64, _ bool) (*model.RideRequest, error) {
+	c.requestReads++
+	return &model.RideRequest{ID: id, Status: model.RequestPending, Direction: model.DirectionToAirport}, nil
+}
+
+func (c *countingStore) FindNearbyCandidateTrips(context.Context, model.Location, model.TripDirection, int, bool) ([]model.CandidateTrip, error) {
+	c.candidateQueries++
+	return nil, nil
+}
+
+func (c *countingStore) GetTripStops(context.Context, int64) ([]model.Pickup, error) {
+	return nil, nil
+}
+
+func (c *countingStore) GetTripDropoffs(context.Context, int64) ([]model.Dropoff, error) {
+	return nil, nil
+}
+
+// memCooldown is an in-memory MatchCooldown that never expires entries.
+type memCooldown map[int64]time.Duration
+
+func (m memCooldown) Active(_ context.Context, id int64) (bool, error) {
+	_, ok := m[id]
+	return ok, nil
+}
+
+func (m memCooldown) Start(_ context.Context, id int64, ttl time.Duration) error {
+	m[id] = ttl
+	return nil
+}
+
+func newCooldownService(cooldown time.Duration) (*MatchingService, *countingStore, memCooldown) {
+	store, mem := &countingStore{}, memCooldown{}
+	cfg := DefaultMatchConfig()
+	cfg.NoMatchCooldown = cooldown
+	svc := NewMatchingService(store, cfg)
+	svc.Cooldown = mem
+	return svc, store, mem
+}
+
+func TestMatchRiders_SecondNoMatchWithinCooldownSkipsQuery(t *testing.T) {
+	svc, store, mem := newCooldownService(5 * time.Second)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if _, err := svc.MatchRiders(ctx, 42); !errors.Is(err, ErrNoMatch) {
+			t.Fatalf("attempt %d: err = %v, want ErrNoMatch", i+1, err)
+		}
+	}
+	if store.candidateQueries != 1 || store.requestReads != 1 {
+		t.Errorf("queries = %d, request reads = %d; want 1 each (second call served from cooldown)",
+			store.candidateQueries, store.requestReads)
+	}
+	if mem[42] != 5*time.Second {
+		t.Errorf("cooldown ttl = %s, want the configured 5s", mem[42])
+	}
+
+	// Other requests are unaffected.
+	if _, err := svc.MatchRiders(ctx, 43); !errors.Is(err, ErrNoMatch) || store.candidateQueries != 2 {
+		t.Errorf("request 43: err = %v after %d queries, want a fresh search", err, store.candidateQueries)
+	}
+}
+
+func TestMatch_BookingBypassesCooldown(t *testing.T) {
+	svc, store, _ := newCooldownService(5 * time.Second)
+	ctx := context.Background()
+
+	_, _ = svc.MatchRiders(ctx, 42)
+	// BookRide matches through match, which ignores the cooldown.
+	if _, err := svc.match(ctx, 42, nil); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("err = %v, want ErrNoMatch", err)
+	}
+	if store.candidateQueries != 2 {
+		t.Errorf("queries = %d, want 2 (booking searches despite cooldown)", store.candidateQueries)
+	}
+}
+
+func TestMatchRiders_CooldownDisabled(t *testing.T) {
+	svc, store, mem := newCooldownService(0)
+	for i := 0; i < 3; i++ {
+		_, _ = svc.MatchRiders(context.Background(), 42)
+	}
+	if store.candidateQueries != 3 || len(mem) != 0 {
+		t.Errorf("queries = %d, cooldowns = %v; want a search every time and none recorded", store.candidateQueries, mem)
+	}
+}
@@ -4,20 +4,31 @@ package service
 import (
 	"context"
 	"errors"
-	"log"
+	"fmt"
 	"math"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/shiva/hintro/internal/model"
 	"github.com/shiva/hintro/internal/repository"
 	"github.com/shiva/hintro/pkg/geo"
+	"github.com/shiva/hintro/pkg/logctx"
+	"github.com/shiva/hintro/pkg/metrics"
+	"github.com/shiva/hintro/pkg/tracing"
 )
 
 // ─── Errors ─────────────────────────────────────────────────
 
 var (
-	ErrNoMatch        = errors.New("no matching trip found; a new trip should be created")
+	ErrNoMatch         = errors.New("no matching trip found; a new trip should be created")
 	ErrRequestNotFound = errors.New("ride request not found")
 	ErrAlreadyMatched  = errors.New("ride request is already matched to a trip")
+
+	// ErrRequestScheduled is returned for a request still waiting for its
+	// matching window (see ScheduleActivator).
+	ErrRequestScheduled = errors.New("ride request is scheduled and not yet open for matching")
 )
 
 // ─── Constants ──────────────────────────────────────────────
@@ -27,14 +38,154 @@ const (
 	// trips/requests (2 km). Matches the default tolerance_meters in schema.
 	DefaultSearchRadiusM = 2000
 
+	// DefaultClusterRadiusM is MatchConfig.ClusterRadiusM when unset, and
+	// the MATCH_CLUSTER_RADIUS_M default.
+	DefaultClusterRadiusM = 2000
+
 	// MaxCandidates caps the number of candidate trips to evaluate.
 	// Keeps the inner loop bounded for latency guarantees.
 	MaxCandidates = 20
 
 	// MaxDetourMinutes is the hard ceiling for any single passenger's detour.
 	MaxDetourMinutes = 15.0
+
+	// BacktrackAngleDegrees is how far a pickup leg may turn away from the
+	// route's overall bearing before MatchConfig.BacktrackPenaltyMinutes
+	// applies: beyond 90° the cab is heading back the way it came.
+	BacktrackAngleDegrees = 90.0
 )
 
+// MaxToleranceMeters is the largest useful tolerance_meters: the distance
+// covered in MaxDetourMinutes at geo.AverageSpeedKmph (7.5 km). A larger
+// tolerance would never be reached, since the hard ceiling rejects first.
+// The ride_requests CHECK constraint (migration 015) holds the same limit;
+// RIDE_MAX_TOLERANCE_METERS may set a lower one.
+const MaxToleranceMeters = int(MaxDetourMinutes / 60.0 * geo.AverageSpeedKmph * 1000)
+
+// ToleranceMinutes converts a rider's tolerance_meters to the detour, in
+// minutes, it allows.
+func ToleranceMinutes(meters int) float64 {
+	return float64(meters) / 1000.0 / geo.AverageSpeedKmph * 60.0
+}
+
+// ClampTolerance caps meters at limit (RIDE_MAX_TOLERANCE_METERS),
+// reporting whether it was lowered. A limit ≤ 0 or above
+// MaxToleranceMeters caps at MaxToleranceMeters.
+func ClampTolerance(meters, limit int) (int, bool) {
+	if limit <= 0 || limit > MaxToleranceMeters {
+		limit = MaxToleranceMeters
+	}
+	if meters > limit {
+		return limit, true
+	}
+	return meters, false
+}
+
+// ValidateToleranceLimit checks a configured tolerance cap
+// (RIDE_MAX_TOLERANCE_METERS) lies in [1, MaxToleranceMeters].
+func ValidateToleranceLimit(limit int) error {
+	if limit < 1 || limit > MaxToleranceMeters {
+		return fmt.Errorf("tolerance limit %d out of range [1, %d]", limit, MaxToleranceMeters)
+	}
+	return nil
+}
+
+// ─── Match Configuration ────────────────────────────────────
+
+// MatchConfig holds the tunable parts of the matching algorithm.
+type MatchConfig struct {
+	// InsertionStrategy decides where a new pickup goes in a candidate
+	// trip's route, and therefore the detour it is scored by.
+	InsertionStrategy geo.InsertionStrategy
+
+	// Airport is appended as the final stop of every to_airport route.
+	// Zero falls back to the request's own destination.
+	Airport model.Location
+
+	// BacktrackPenaltyMinutes is added to a candidate's score, scaled from
+	// 0 at BacktrackAngleDegrees to the full value at 180°, when the new
+	// pickup's leg points back against the route. It only ranks candidates:
+	// tolerance checks and the reported detour use real minutes. 0 = off.
+	BacktrackPenaltyMinutes float64
+
+	// LoadPreferenceMinutes is taken off a candidate's score for each seat
+	// already booked on the trip, so a compatible cab that is carrying
+	// riders wins over an emptier one unless its detour is longer by more
+	// than that ("fill existing cabs first"). Like the backtrack penalty it
+	// only ranks candidates. 0 = off.
+	LoadPreferenceMinutes float64
+
+	// NoMatchCooldown is how long MatchRiders keeps answering no_match for
+	// a request without searching again after it found nothing, so clients
+	// polling /match do not re-run the spatial query every time. Needs
+	// MatchingService.Cooldown; 0 = off. Booking always searches.
+	NoMatchCooldown time.Duration
+
+	// MaxPassengersPerTrip caps how many passengers matching pools onto
+	// one trip, whatever the cab's seats (model.CheckPassengerLimit).
+	// Booking enforces the same limit. 0 = off.
+	MaxPassengersPerTrip int
+
+	// LuggageUsesSeats matches riders whose bags only fit by taking free
+	// seats (model.CabCapacity). Set it together with
+	// BookingRepository.LuggageUsesSeats so booking accepts those matches.
+	LuggageUsesSeats bool
+
+	// MaxToleranceMeters is the configured tolerance_meters cap
+	// (RIDE_MAX_TOLERANCE_METERS): the longer_detour suggestion never asks
+	// for more. 0 = MaxToleranceMeters.
+	MaxToleranceMeters int
+
+	// ReserveScheduled lets a SCHEDULED request be matched and booked
+	// ahead of its departure, reserving its seats on the trip so later
+	// live riders cannot take them. Set it together with
+	// BookingRepository.ReserveScheduled. Off: scheduled requests get
+	// ErrRequestScheduled until the activator opens them.
+	ReserveScheduled bool
+
+	// ReserveHorizon is how far ahead of its scheduled_at a ride may be
+	// booked as a reservation, bounding how early a reservation that
+	// starts its own trip claims a cab. Further out it gets
+	// ErrRequestScheduled. 0 = no limit.
+	ReserveHorizon time.Duration
+
+	// DepartureWindow is how far apart riders' departures (a reservation's
+	// scheduled_at, otherwise now) may be for them to share a trip, so a
+	// reservation is not pooled into a trip leaving now and a live rider
+	// does not join a trip held for a later departure. 0 = off.
+	DepartureWindow time.Duration
+
+	// SlowMatchThreshold is how long a MatchRiders call may take before it
+	// logs a warning with its phase timings (metrics.MatchLatencyMs keeps
+	// the full distribution). 0 = off.
+	SlowMatchThreshold time.Duration
+
+	// RadiusExpansionSteps is how many wider searches findBestTrip makes
+	// after the rider's own radius finds no fitting trip, spaced evenly
+	// out to MaxSearchRadiusM (1 = a single retry at the maximum). Each
+	// step is another candidate query, so it trades latency for match
+	// rate. The rider's tolerance still bounds the detour. 0 = off.
+	RadiusExpansionSteps int
+
+	// MaxSearchRadiusM is the widest radius expansion searches. A rider
+	// whose own radius is already this wide is not expanded.
+	MaxSearchRadiusM int
+
+	// ClusterRadiusM is how far apart two pending pickups may be for
+	// ClusterPending to group them ("who counts as nearby"). It is
+	// deliberately not a rider's tolerance_meters, which bounds how far
+	// that rider will detour. ≤ 0 means DefaultClusterRadiusM.
+	ClusterRadiusM int
+}
+
+// DefaultMatchConfig returns the configuration matching has always used:
+// marginal (cheapest single insertion) placement and no fixed airport.
+func DefaultMatchConfig() MatchConfig {
+	return MatchConfig{
+		InsertionStrategy: geo.InsertionMarginal,
+	}
+}
+
 // ─── MatchingService ────────────────────────────────────────
 
 // MatchingService implements the Greedy Heuristic ride matching algorithm.
@@ -42,7 +193,9 @@ const (
 // Algorithm overview (for airport pooling — Many-to-One / One-to-Many):
 //
 //  1. FETCH: Use PostGIS ST_DWithin (GIST index) to find nearby planned trips.
-//  2. FILTER: Hard constraint check — seats + luggage capacity.
+//  2. FILTER: Hard constraint check — seats + luggage capacity
+//     (model.CabCapacity; flex cabs share units between the two), plus the
+//     optional MaxPassengersPerTrip pool size ceiling.
 //  3. SCORE: For each candidate, simulate inserting the new pickup into the
 //     route and calculate the added detour (using Haversine estimation).
 //  4. SELECT: Pick the trip with the LEAST added detour that doesn't violate
@@ -54,149 +207,499 @@ const (
 //	With GIST index on origin, the DB fetch is O(log N).
 //	Total per request: O(log N + C × S) — well under 1ms for typical inputs.
 type MatchingService struct {
-	Repo *repository.RideRepository
+	Repo   MatchStore
+	config MatchConfig
+
+	// Cooldown remembers recent no-match results (MatchConfig.NoMatchCooldown).
+	// Nil disables the cooldown.
+	Cooldown MatchCooldown
+
+	// Pending finds pending requests for ClusterPending. Nil disables
+	// clustering.
+	Pending PendingFinder
+
+	now func() time.Time // Projected drop-off times count from here.
+}
+
+// MatchStore is the part of repository.RideRepository matching reads.
+type MatchStore interface {
+	GetRideRequest(ctx context.Context, id int64, forUpdate bool) (*model.RideRequest, error)
+	FindNearbyCandidateTrips(ctx context.Context, origin model.Location, direction model.TripDirection, radiusMeters int, requiresAccessible bool) ([]model.CandidateTrip, error)
+	GetTripStops(ctx context.Context, tripID int64) ([]model.Pickup, error)
+	GetTripDropoffs(ctx context.Context, tripID int64) ([]model.Dropoff, error)
+}
+
+// MatchCooldown records which requests recently failed to match
+// (see repository.MatchCooldownStore).
+type MatchCooldown interface {
+	Active(ctx context.Context, requestID int64) (bool, error)
+	Start(ctx context.Context, requestID int64, ttl time.Duration) error
 }
 
 // NewMatchingService creates a matching service backed by the given repository.
-func NewMatchingService(repo *repository.RideRepository) *MatchingService {
-	return &MatchingService{Repo: repo}
+func NewMatchingService(repo MatchStore, config MatchConfig) *MatchingService {
+	return &MatchingService{Repo: repo, config: config, now: time.Now}
 }
 
 // MatchRiders attempts to find an existing trip for the given ride request.
 //
 // Returns a MatchResult if a compatible trip is found, or ErrNoMatch if the
-// request should seed a new trip.
+// request should seed a new trip. After a no-match the request is in
+// cooldown for MatchConfig.NoMatchCooldown: further calls return ErrNoMatch
+// straight away, without reading the request or searching.
 //
 // This function is safe to call concurrently — all mutable state lives in
 // PostgreSQL with row-level locking.
 func (s *MatchingService) MatchRiders(ctx context.Context, requestID int64) (*model.MatchResult, error) {
+	ctx = logctx.WithRequestID(ctx, requestID)
+
+	if s.inCooldown(ctx, requestID) {
+		logctx.Debugf(ctx, "[match] Request #%d in no-match cooldown; skipping search", requestID)
+		return nil, ErrNoMatch
+	}
+
+	start := time.Now()
+	var phases matchPhases
+	result, err := s.match(ctx, requestID, &phases)
+	s.recordLatency(ctx, requestID, time.Since(start), phases)
+	if errors.Is(err, ErrNoMatch) {
+		s.startCooldown(ctx, requestID)
+	}
+	return result, err
+}
+
+// matchPhases receives how long findBestTrip spent in each phase.
+type matchPhases struct {
+	searched     bool // False if the call failed before the candidate fetch.
+	fetch, score time.Duration
+}
+
+// recordLatency observes a MatchRiders call in metrics.MatchLatencyMs and
+// warns when it ran over MatchConfig.SlowMatchThreshold.
+func (s *MatchingService) recordLatency(ctx context.Context, requestID int64, total time.Duration, phases matchPhases) {
+	metrics.MatchTotalMs.Observe(millis(total))
+	if phases.searched {
+		metrics.MatchFetchMs.Observe(millis(phases.fetch))
+		metrics.MatchScoreMs.Observe(millis(phases.score))
+	}
+	if s.config.SlowMatchThreshold > 0 && total > s.config.SlowMatchThreshold {
+		logctx.Warnf(ctx, "[match] WARNING: request #%d took %s (fetch %s, score %s), over the %s threshold",
+			requestID, total, phases.fetch, phases.score, s.config.SlowMatchThreshold)
+	}
+}
+
+// millis converts d to fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// inCooldown reports whether requestID recently failed to match. Cooldown
+// store errors fail open: the search runs.
+func (s *MatchingService) inCooldown(ctx context.Context, requestID int64) bool {
+	if s.Cooldown == nil || s.config.NoMatchCooldown <= 0 {
+		return false
+	}
+	active, err := s.Cooldown.Active(ctx, requestID)
+	if err != nil {
+		logctx.Warnf(ctx, "[match] WARNING: cooldown lookup failed: %v", err)
+		return false
+	}
+	return active
+}
+
+// startCooldown puts requestID into no-match cooldown.
+func (s *MatchingService) startCooldown(ctx context.Context, requestID int64) {
+	if s.Cooldown == nil || s.config.NoMatchCooldown <= 0 {
+		return
+	}
+	if err := s.Cooldown.Start(ctx, requestID, s.config.NoMatchCooldown); err != nil {
+		logctx.Warnf(ctx, "[match] WARNING: cooldown not recorded: %v", err)
+	}
+}
+
+// match runs the matching algorithm for requestID, ignoring any cooldown.
+// BookRide uses it directly: a booking must always search. phases, if
+// non-nil, receives the phase timings.
+func (s *MatchingService) match(ctx context.Context, requestID int64, phases *matchPhases) (_ *model.MatchResult, err error) {
+	ctx, span := tracing.Start(ctx, "MatchingService.Match", attribute.Int64("ride.request_id", requestID))
+	defer func() { tracing.End(span, err) }()
+	ctx = logctx.WithRequestID(ctx, requestID)
+
 	// ── Step 0: Fetch the ride request ──────────────────
 	req, err := s.Repo.GetRideRequest(ctx, requestID, false)
+	if repository.IsConnectionError(err) {
+		return nil, fmt.Errorf("match: %w", err)
+	}
 	if err != nil {
 		return nil, ErrRequestNotFound
 	}
 
-	if req.Status != model.RequestPending {
+	switch {
+	case req.Status == model.RequestPending, s.config.reservable(req, s.now()):
+	case req.Status == model.RequestScheduled && req.TripID == nil:
+		return nil, ErrRequestScheduled
+	default:
 		return nil, ErrAlreadyMatched
 	}
 
-	log.Printf("[match] Processing request #%d: origin=(%.4f,%.4f) dir=%s seats=%d luggage=%d",
+	logctx.Debugf(ctx, "[match] Processing request #%d: origin=(%.4f,%.4f) dir=%s seats=%d luggage=%d",
 		req.ID, req.Origin.Lat, req.Origin.Lon, req.Direction, req.SeatsNeeded, req.LuggageCount)
 
-	// ── Step 1: FETCH nearby candidate trips (PostGIS) ──
-	// Uses GIST index on ride_requests(origin) via ST_DWithin.
-	searchRadius := req.ToleranceMeters
-	if searchRadius <= 0 {
-		searchRadius = DefaultSearchRadiusM
+	bestMatch, _, err := s.findBestTrip(ctx, req, phases)
+	if errors.Is(err, ErrNoMatch) {
+		recordNoMatch(ctx, req)
 	}
+	return bestMatch, err
+}
+
+// recordNoMatch counts a failed match against the pickup's area
+// (metrics.NoMatchByArea) so regions that never pool show up.
+func recordNoMatch(ctx context.Context, req *model.RideRequest) {
+	area := geo.Geohash(req.Origin, geo.NoMatchGeohashPrecision)
+	metrics.NoMatchByArea.Add(area, 1)
+	logctx.Printf(ctx, "[match] ✗ No match for request #%d in area %s (%s, %d seats, %d bags)",
+		req.ID, area, req.Direction, req.SeatsNeeded, req.LuggageCount)
+}
 
-	candidates, err := s.Repo.FindNearbyCandidateTrips(ctx, req.Origin, req.Direction, searchRadius)
+// MatchPreview is the result of PreviewMatch.
+type MatchPreview struct {
+	Matchable              bool    `json:"matchable"`
+	TripID                 int64   `json:"trip_id,omitempty"`
+	CabID                  int64   `json:"cab_id,omitempty"`
+	EstimatedDetourMinutes float64 `json:"estimated_detour_minutes"`
+	CandidatesChecked      int     `json:"candidates_checked"`
+}
+
+// PreviewMatch runs the matching algorithm for a hypothetical rider
+// without an existing ride request. It only reads: nothing is locked,
+// booked or written.
+//
+// probe needs Origin, Direction, SeatsNeeded and LuggageCount. Destination
+// is optional: to_airport routes end at the configured airport anyway, and
+// other routes without it are estimated over the trip's pickups only.
+// ToleranceMeters ≤ 0 uses DefaultSearchRadiusM.
+func (s *MatchingService) PreviewMatch(ctx context.Context, probe model.RideRequest) (*MatchPreview, error) {
+	if probe.ToleranceMeters <= 0 {
+		probe.ToleranceMeters = DefaultSearchRadiusM
+	}
+
+	logctx.Debugf(ctx, "[match] Preview: origin=(%.4f,%.4f) dir=%s seats=%d luggage=%d",
+		probe.Origin.Lat, probe.Origin.Lon, probe.Direction, probe.SeatsNeeded, probe.LuggageCount)
+
+	best, checked, err := s.findBestTrip(ctx, &probe, nil)
+	if errors.Is(err, ErrNoMatch) {
+		return &MatchPreview{CandidatesChecked: checked}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
+	return &MatchPreview{
+		Matchable:              true,
+		TripID:                 best.TripID,
+		CabID:                  best.CabID,
+		EstimatedDetourMinutes: math.Round(best.AddedDetour*10) / 10,
+		CandidatesChecked:      checked,
+	}, nil
+}
 
-	log.Printf("[match] Found %d candidate trips within %dm", len(candidates), searchRadius)
+// findBestTrip runs steps 1–4 of the algorithm for req. Returns the best
+// match (or ErrNoMatch) and how many candidate trips were considered.
+// If the rider's own radius yields nothing it retries at each of the
+// wider searchRadii. phases, if non-nil, receives the fetch and scoring
+// times summed over every search.
+func (s *MatchingService) findBestTrip(ctx context.Context, req *model.RideRequest, phases *matchPhases) (*model.MatchResult, int, error) {
+	fetched := 0
+	for i, radius := range s.searchRadii(req) {
+		if i > 0 {
+			logctx.Debugf(ctx, "[match] No fit; expanding search to %dm", radius)
+		}
+		best, n, err := s.searchAt(ctx, req, radius, phases)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Wider searches fetch the inner trips again, so the last
+		// count already includes every trip considered.
+		fetched = n
+		if best != nil {
+			logctx.Printf(ctx, "[match] ✓ Best match: trip #%d with %.2f min detour", best.TripID, best.AddedDetour)
+			return best, fetched, nil
+		}
+	}
+	return nil, fetched, ErrNoMatch
+}
 
-	if len(candidates) == 0 {
-		return nil, ErrNoMatch
+// searchAt fetches the candidate trips within radius of req's pickup and
+// returns the best fit, or nil, and how many trips were fetched.
+func (s *MatchingService) searchAt(ctx context.Context, req *model.RideRequest, radius int, phases *matchPhases) (*model.MatchResult, int, error) {
+	start := time.Now()
+	candidates, fetched, err := s.loadCandidates(ctx, req, radius)
+	fetchDone := time.Now()
+	if phases != nil {
+		phases.searched = true
+		phases.fetch += fetchDone.Sub(start)
+	}
+	if err != nil || fetched == 0 {
+		return nil, 0, err
 	}
 
-	// ── Step 2 + 3: FILTER & SCORE ──────────────────────
-	// Greedy: evaluate each candidate, keep the best.
-	bestScore := math.MaxFloat64
-	var bestMatch *model.MatchResult
+	best := s.pickBest(ctx, candidates, req)
+	if phases != nil {
+		phases.score += time.Since(fetchDone)
+	}
+	return best, fetched, nil
+}
 
-	for i := range candidates {
-		ct := &candidates[i]
+// searchRadius is how far from req's pickup candidate trips are fetched:
+// its tolerance, or DefaultSearchRadiusM when unset.
+func searchRadius(req *model.RideRequest) int {
+	if req.ToleranceMeters <= 0 {
+		return DefaultSearchRadiusM
+	}
+	return req.ToleranceMeters
+}
+
+// searchRadii lists the radii findBestTrip tries in order: req's own
+// searchRadius, then MatchConfig.RadiusExpansionSteps evenly spaced steps
+// out to MatchConfig.MaxSearchRadiusM. With 2 steps from 2000 m to
+// 6000 m that is 2000, 4000, 6000.
+func (s *MatchingService) searchRadii(req *model.RideRequest) []int {
+	first := searchRadius(req)
+	steps, widest := s.config.RadiusExpansionSteps, s.config.MaxSearchRadiusM
+	if steps <= 0 || widest <= first {
+		return []int{first}
+	}
+	radii := make([]int, 0, steps+1)
+	radii = append(radii, first)
+	for i := 1; i <= steps; i++ {
+		radii = append(radii, first+(widest-first)*i/steps)
+	}
+	return radii
+}
 
+// loadCandidates is step 1: fetch the candidate trips within radius of
+// req's pickup and load each one's Route. Trips whose stops fail to load
+// are dropped; fetched counts them anyway.
+func (s *MatchingService) loadCandidates(ctx context.Context, req *model.RideRequest, radius int) (candidates []model.CandidateTrip, fetched int, err error) {
+	// ── Step 1: FETCH nearby candidate trips (PostGIS) ──
+	// Uses GIST index on ride_requests(origin) via ST_DWithin.
+	all, err := s.Repo.FindNearbyCandidateTrips(ctx, req.Origin, req.Direction, radius, req.RequiresAccessible)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	logctx.Debugf(ctx, "[match] Found %d candidate trips within %dm", len(all), radius)
+
+	candidates = all[:0]
+	for _, ct := range all {
 		// --- Load route for detour calculation (origins + destination) ---
-		stops, err := s.Repo.GetTripStops(ctx, ct.TripID)
+		pickups, err := s.Repo.GetTripStops(ctx, ct.TripID)
 		if err != nil {
-			log.Printf("[match]   Trip #%d: SKIP failed to get stops: %v", ct.TripID, err)
+			logctx.Debugf(ctx, "[match]   Trip #%d: SKIP failed to get stops: %v", ct.TripID, err)
 			continue
 		}
-		if len(stops) > 0 {
-			ct.Route = append(stops, req.Destination)
+		if len(pickups) > 0 {
+			stops := make([]model.Location, len(pickups))
+			for i, p := range pickups {
+				stops[i] = p.Origin
+			}
+			ct.Route = s.buildRoute(stops, req)
+			ct.Pickups = pickups
 		}
-
-		// --- Hard Constraint: Seat capacity ---
-		if ct.CurrentLoad+req.SeatsNeeded > ct.SeatCapacity {
-			log.Printf("[match]   Trip #%d: SKIP seats (%d+%d > %d)",
-				ct.TripID, ct.CurrentLoad, req.SeatsNeeded, ct.SeatCapacity)
-			continue
+		if req.Direction == model.DirectionFromAirport {
+			if ct.Dropoffs, err = s.Repo.GetTripDropoffs(ctx, ct.TripID); err != nil {
+				logctx.Debugf(ctx, "[match]   Trip #%d: SKIP failed to get drop-offs: %v", ct.TripID, err)
+				continue
+			}
 		}
+		candidates = append(candidates, ct)
+	}
+	return candidates, len(all), nil
+}
 
-		// --- Hard Constraint: Luggage capacity ---
-		if ct.CurrentLuggage+req.LuggageCount > ct.LuggageCapacity {
-			log.Printf("[match]   Trip #%d: SKIP luggage (%d+%d > %d)",
-				ct.TripID, ct.CurrentLuggage, req.LuggageCount, ct.LuggageCapacity)
-			continue
-		}
+// pickBest runs steps 2–4 over loaded candidates: filter by the hard
+// constraints, score, and return the best, or nil if none fit. No I/O.
+func (s *MatchingService) pickBest(ctx context.Context, candidates []model.CandidateTrip, req *model.RideRequest) *model.MatchResult {
+	// ── Step 2 + 3: FILTER & SCORE ──────────────────────
+	// Greedy: evaluate each candidate, keep the best. Ties go to the
+	// lower trip ID so the winner does not depend on query row order.
+	bestScore := math.MaxFloat64
+	var bestMatch *model.MatchResult
 
-		// --- Detour Calculation ---
-		detour, valid := s.calculateDetour(ctx, ct, req)
-		if !valid {
-			log.Printf("[match]   Trip #%d: SKIP detour exceeds tolerance", ct.TripID)
+	for i := range candidates {
+		ct := &candidates[i]
+
+		detour, penalty, ok := s.scoreCandidate(ctx, ct, req)
+		if !ok {
 			continue
 		}
+		score := detour + penalty - s.loadPreference(ct)
 
-		log.Printf("[match]   Trip #%d: detour=%.2f min (current best=%.2f)",
-			ct.TripID, detour, bestScore)
+		logctx.Debugf(ctx, "[match]   Trip #%d: detour=%.2f min backtrack_penalty=%.2f load=%d score=%.2f (current best=%.2f)",
+			ct.TripID, detour, penalty, ct.CurrentLoad, score, bestScore)
 
-		// --- Greedy selection: lowest detour wins ---
-		if detour < bestScore {
-			bestScore = detour
+		// --- Greedy selection: lowest score wins ---
+		if bestMatch == nil || betterCandidate(score, ct.TripID, bestScore, bestMatch.TripID) {
+			bestScore = score
 			bestMatch = &model.MatchResult{
-				TripID:      ct.TripID,
-				CabID:       ct.CabID,
-				AddedDetour: detour,
+				TripID:            ct.TripID,
+				CabID:             ct.CabID,
+				AddedDetour:       detour,
+				AddedDetourMeters: geo.DistanceForMinutesM(detour),
+				CurrentLoad:       ct.CurrentLoad,
 			}
 		}
 	}
+	return bestMatch
+}
 
-	if bestMatch != nil {
-		log.Printf("[match] ✓ Best match: trip #%d with %.2f min detour", bestMatch.TripID, bestMatch.AddedDetour)
-		return bestMatch, nil
+// loadPreference is the score bonus MatchConfig.LoadPreferenceMinutes
+// gives ct for the seats already booked on it.
+func (s *MatchingService) loadPreference(ct *model.CandidateTrip) float64 {
+	return s.config.LoadPreferenceMinutes * float64(ct.CurrentLoad)
+}
+
+// scoreTieEpsilon is how close (in minutes) two scores must be to count
+// as a tie; float rounding in the route maths can separate equal detours.
+const scoreTieEpsilon = 1e-9
+
+// betterCandidate reports whether a candidate scoring score on tripID beats
+// the current best. Lower score wins; on a tie the lower trip ID wins.
+func betterCandidate(score float64, tripID int64, bestScore float64, bestTripID int64) bool {
+	if math.Abs(score-bestScore) <= scoreTieEpsilon {
+		return tripID < bestTripID
 	}
+	return score < bestScore
+}
 
-	return nil, ErrNoMatch
+// buildRoute returns a trip's pickups followed by its final stop: the
+// configured airport for to_airport trips, otherwise req.Destination (if set).
+func (s *MatchingService) buildRoute(stops []model.Location, req *model.RideRequest) []model.Location {
+	final := req.Destination
+	if req.Direction == model.DirectionToAirport && s.config.Airport != (model.Location{}) {
+		final = s.config.Airport
+	}
+	if final == (model.Location{}) {
+		return stops
+	}
+	return append(stops, final)
+}
+
+// scoreCandidate applies the hard constraints to one candidate trip (with
+// its Route loaded) and returns the added detour in minutes plus any
+// backtrack penalty; candidates are ranked by their sum. ok is false when
+// the request cannot join the trip. No I/O.
+func (s *MatchingService) scoreCandidate(ctx context.Context, ct *model.CandidateTrip, req *model.RideRequest) (detour, penalty float64, ok bool) {
+	// --- Hard Constraint: Wheelchair accessibility ---
+	if err := ct.Capacity().CheckAccessible(req.RequiresAccessible); err != nil {
+		logctx.Debugf(ctx, "[match]   Trip #%d: SKIP not accessible", ct.TripID)
+		return 0, 0, false
+	}
+
+	// --- Hard Constraint: Departure times ---
+	if !s.departsTogether(ct, req) {
+		logctx.Debugf(ctx, "[match]   Trip #%d: SKIP departs outside the departure window", ct.TripID)
+		return 0, 0, false
+	}
+
+	// --- Hard Constraint: Pool size ceiling ---
+	if err := model.CheckPassengerLimit(ct.CurrentLoad, req.SeatsNeeded, s.config.MaxPassengersPerTrip); err != nil {
+		logctx.Debugf(ctx, "[match]   Trip #%d: SKIP passenger limit (%v)", ct.TripID, err)
+		return 0, 0, false
+	}
+
+	// --- Hard Constraint: Seat + luggage capacity (combined on flex cabs) ---
+	capacity := ct.Capacity()
+	capacity.LuggageUsesSeats = s.config.LuggageUsesSeats
+	if err := capacity.Check(ct.CurrentLoad, ct.CurrentLuggage, req.SeatsNeeded, req.LuggageCount); err != nil {
+		logctx.Debugf(ctx, "[match]   Trip #%d: SKIP capacity (%v)", ct.TripID, err)
+		return 0, 0, false
+	}
+
+	// --- Detour Calculation ---
+	detour, penalty, valid := s.calculateDetour(ctx, ct, req)
+	if !valid {
+		logctx.Debugf(ctx, "[match]   Trip #%d: SKIP detour exceeds tolerance", ct.TripID)
+		return 0, 0, false
+	}
+
+	// --- Hard Constraint: Drop-off deadlines (from_airport) ---
+	if !s.meetsDeadlines(ctx, ct, req) {
+		logctx.Debugf(ctx, "[match]   Trip #%d: SKIP misses a drop-off deadline", ct.TripID)
+		return 0, 0, false
+	}
+	return detour, penalty, true
 }
 
 // calculateDetour checks if adding the new rider to the trip violates any
-// passenger's tolerance, and returns the added time in minutes.
+// passenger's tolerance, and returns the added time in minutes and the
+// backtrack penalty for the chosen insertion.
 //
 // Strategy:
 //  1. Fetch the current trip route (ordered stops + destination).
-//  2. Use FindBestInsertionIndex to find optimal pickup position.
+//  2. Place the pickup with the configured geo.InsertionStrategy.
 //  3. Check if the added time exceeds the new rider's tolerance.
 //  4. Check if the added time exceeds the global MaxDetourMinutes.
+//  5. Check every rider already on the trip: their time from pickup to
+//     the end of the route may grow by no more than their own tolerance
+//     (the cheapest-total strategy can reorder their pickups).
+//  6. Penalise a pickup leg that turns back against the route.
 //
 // Complexity: O(S²) where S = stops (≤ 6), so effectively O(1).
 func (s *MatchingService) calculateDetour(
 	ctx context.Context,
 	trip *model.CandidateTrip,
 	req *model.RideRequest,
-) (float64, bool) {
+) (float64, float64, bool) {
 	// If the trip has no existing route, the detour is zero
 	// (this is the first pickup being added).
 	if len(trip.Route) < 2 {
-		return 0, true
+		return 0, 0, true
 	}
 
 	// Find the best spot to insert the new passenger's origin.
-	_, addedMinutes := geo.FindBestInsertionIndex(trip.Route, req.Origin)
+	idx, addedMinutes, planned := geo.PlanInsertion(s.config.InsertionStrategy, trip.Route, req.Origin)
 
 	// Check 1: Does this exceed the NEW rider's tolerance?
 	// Convert tolerance from meters to approximate minutes.
-	toleranceMinutes := float64(req.ToleranceMeters) / 1000.0 / geo.AverageSpeedKmph * 60.0
-	if addedMinutes > toleranceMinutes {
-		return 0, false
+	if addedMinutes > ToleranceMinutes(req.ToleranceMeters) {
+		return 0, 0, false
 	}
 
 	// Check 2: Does it exceed the hard detour ceiling?
 	if addedMinutes > MaxDetourMinutes {
-		return 0, false
+		return 0, 0, false
+	}
+
+	// Check 3: Does it push any existing rider past their tolerance?
+	for _, p := range trip.Pickups {
+		if riderMinutesAdded(trip.Route, planned, p.Origin) > ToleranceMinutes(p.ToleranceMeters) {
+			return 0, 0, false
+		}
+	}
+
+	return addedMinutes, s.backtrackPenalty(planned, idx), true
+}
+
+// riderMinutesAdded is how much longer a rider picked up at origin rides,
+// from their pickup to the end of the route, on planned than on route.
+// A pickup missing from either route adds nothing.
+func riderMinutesAdded(route, planned []model.Location, origin model.Location) float64 {
+	was, now := slices.Index(route, origin), slices.Index(planned, origin)
+	if was < 0 || now < 0 {
+		return 0
 	}
+	return geo.RouteTimeMinutes(planned[now:]) - geo.RouteTimeMinutes(route[was:])
+}
 
-	return addedMinutes, true
+// backtrackPenalty scores how sharply the leg through the new pickup at
+// idx turns back against the route (see MatchConfig.BacktrackPenaltyMinutes).
+func (s *MatchingService) backtrackPenalty(route []model.Location, idx int) float64 {
+	if s.config.BacktrackPenaltyMinutes <= 0 {
+		return 0
+	}
+	over := geo.InsertionDivergenceDegrees(route, idx) - BacktrackAngleDegrees
+	if over <= 0 {
+		return 0
+	}
+	return s.config.BacktrackPenaltyMinutes * over / (180.0 - BacktrackAngleDegrees)
 }
@@ -0,0 +1,580 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/geo"
+	"github.com/shiva/hintro/pkg/metrics"
+)
+
+// plannedTrip is a 4-seat, 3-bag trip with one rider picked up north of
+// the airport.
+func plannedTrip() *model.CandidateTrip {
+	return &model.CandidateTrip{
+		TripID: 7, CabID: 3,
+		SeatCapacity: 4, LuggageCapacity: 3,
+		CurrentLoad: 1, CurrentLuggage: 1,
+		Route: []model.Location{
+			{Lat: 28.70, Lon: 77.10},
+			{Lat: 28.5562, Lon: 77.0889}, // airport
+		},
+	}
+}
+
+func TestScoreCandidate_Matchable(t *testing.T) {
+	svc := NewMatchingService(nil, DefaultMatchConfig())
+	req := &model.RideRequest{
+		Origin:          model.Location{Lat: 28.69, Lon: 77.10}, // on the way
+		SeatsNeeded:     1,
+		LuggageCount:    1,
+		ToleranceMeters: DefaultSearchRadiusM,
+	}
+
+	detour, _, ok := svc.scoreCandidate(context.Background(), plannedTrip(), req)
+	if !ok {
+		t.Fatal("scoreCandidate rejected a rider on the trip's way")
+	}
+	if detour < 0 || detour > 1 {
+		t.Errorf("detour = %.2f min, want under a minute", detour)
+	}
+}
+
+func TestScoreCandidate_PassengerCeiling(t *testing.T) {
+	cfg := DefaultMatchConfig()
+	cfg.MaxPassengersPerTrip = 3
+	svc := NewMatchingService(nil, cfg)
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.69, Lon: 77.10}, SeatsNeeded: 1,
+		ToleranceMeters: DefaultSearchRadiusM,
+	}
+
+	// 6-seat cab with 3 aboard: seats remain, but the trip is at the ceiling.
+	atCeiling := plannedTrip()
+	atCeiling.SeatCapacity, atCeiling.CurrentLoad = 6, 3
+	if _, _, ok := svc.scoreCandidate(context.Background(), atCeiling, req); ok {
+		t.Error("scoreCandidate accepted a trip at the passenger ceiling")
+	}
+
+	belowCeiling := plannedTrip()
+	belowCeiling.SeatCapacity, belowCeiling.CurrentLoad = 6, 2
+	if _, _, ok := svc.scoreCandidate(context.Background(), belowCeiling, req); !ok {
+		t.Error("scoreCandidate rejected a trip below the passenger ceiling")
+	}
+}
+
+func TestScoreCandidate_LuggageUsesSeats(t *testing.T) {
+	// 1 rider and 1 bag aboard a 4-seat, 3-bag cab; 1 rider with 4 bags
+	// only fits if two bags take seats.
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.69, Lon: 77.10}, SeatsNeeded: 1, LuggageCount: 4,
+		ToleranceMeters: DefaultSearchRadiusM,
+	}
+
+	if _, _, ok := NewMatchingService(nil, DefaultMatchConfig()).scoreCandidate(context.Background(), plannedTrip(), req); ok {
+		t.Error("scoreCandidate put bags on seats by default")
+	}
+
+	cfg := DefaultMatchConfig()
+	cfg.LuggageUsesSeats = true
+	if _, _, ok := NewMatchingService(nil, cfg).scoreCandidate(context.Background(), plannedTrip(), req); !ok {
+		t.Error("scoreCandidate rejected overflow bags with seats free")
+	}
+}
+
+func TestScoreCandidate_NotMatchable(t *testing.T) {
+	svc := NewMatchingService(nil, DefaultMatchConfig())
+	tests := map[string]*model.RideRequest{
+		"too much luggage": {
+			Origin: model.Location{Lat: 28.69, Lon: 77.10}, SeatsNeeded: 1, LuggageCount: 3,
+			ToleranceMeters: DefaultSearchRadiusM,
+		},
+		"detour beyond tolerance": {
+			Origin: model.Location{Lat: 28.75, Lon: 77.25}, SeatsNeeded: 1,
+			ToleranceMeters: DefaultSearchRadiusM,
+		},
+	}
+	for name, req := range tests {
+		t.Run(name, func(t *testing.T) {
+			if detour, _, ok := svc.scoreCandidate(context.Background(), plannedTrip(), req); ok {
+				t.Errorf("scoreCandidate accepted with detour %.2f min", detour)
+			}
+		})
+	}
+}
+
+func TestRecordNoMatch_IncrementsAreaCounter(t *testing.T) {
+	req := &model.RideRequest{ID: 11, Origin: model.Location{Lat: 12.9716, Lon: 77.5946}, SeatsNeeded: 1}
+	area := geo.Geohash(req.Origin, geo.NoMatchGeohashPrecision)
+	before := metrics.Count(metrics.NoMatchByArea, area)
+
+	recordNoMatch(context.Background(), req)
+	recordNoMatch(context.Background(), req)
+
+	if got := metrics.Count(metrics.NoMatchByArea, area); got != before+2 {
+		t.Errorf("no-match count for %s = %d, want %d", area, got, before+2)
+	}
+}
+
+func TestBuildRoute_ToAirportEndsAtConfiguredAirport(t *testing.T) {
+	airport := model.Location{Lat: 28.5562, Lon: 77.0889}
+	cfg := DefaultMatchConfig()
+	cfg.Airport = airport
+	svc := NewMatchingService(nil, cfg)
+
+	stops := []model.Location{{Lat: 28.70, Lon: 77.10}, {Lat: 28.69, Lon: 77.11}}
+	for name, req := range map[string]*model.RideRequest{
+		"no destination":    {Direction: model.DirectionToAirport},
+		"other destination": {Direction: model.DirectionToAirport, Destination: model.Location{Lat: 28.60, Lon: 77.20}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			route := svc.buildRoute(append([]model.Location(nil), stops...), req)
+			if len(route) != len(stops)+1 {
+				t.Fatalf("route has %d stops, want %d", len(route), len(stops)+1)
+			}
+			if last := route[len(route)-1]; last != airport {
+				t.Errorf("route ends at %+v, want airport %+v", last, airport)
+			}
+		})
+	}
+}
+
+func TestBuildRoute_FromAirportEndsAtDestination(t *testing.T) {
+	cfg := DefaultMatchConfig()
+	cfg.Airport = model.Location{Lat: 28.5562, Lon: 77.0889}
+	svc := NewMatchingService(nil, cfg)
+
+	dest := model.Location{Lat: 28.70, Lon: 77.10}
+	req := &model.RideRequest{Direction: model.DirectionFromAirport, Destination: dest}
+	route := svc.buildRoute([]model.Location{cfg.Airport}, req)
+	if last := route[len(route)-1]; last != dest {
+		t.Errorf("route ends at %+v, want destination %+v", last, dest)
+	}
+}
+
+// backtrackScenario returns two trips a rider at X could join: "back"
+// (west→east sweep, X reached by heading north-east, away from the airport)
+// and "fwd" (X picked up first, then on toward the airport).
+func backtrackScenario() (back, fwd *model.CandidateTrip, req *model.RideRequest) {
+	airport := model.Location{Lat: 28.5562, Lon: 77.0889}
+	back = &model.CandidateTrip{
+		TripID: 1, SeatCapacity: 4, LuggageCapacity: 4,
+		Route: []model.Location{{Lat: 28.70, Lon: 77.00}, {Lat: 28.70, Lon: 77.18}, airport},
+	}
+	fwd = &model.CandidateTrip{
+		TripID: 2, SeatCapacity: 4, LuggageCapacity: 4,
+		Route: []model.Location{{Lat: 28.75, Lon: 77.095}, airport},
+	}
+	req = &model.RideRequest{Origin: model.Location{Lat: 28.75, Lon: 77.05}, SeatsNeeded: 1, ToleranceMeters: 6000}
+	return back, fwd, req
+}
+
+// bestOf ranks candidates the way findBestTrip does.
+func bestOf(t *testing.T, svc *MatchingService, req *model.RideRequest, cts ...*model.CandidateTrip) int64 {
+	t.Helper()
+	best, bestScore := int64(0), 0.0
+	for _, ct := range cts {
+		detour, penalty, ok := svc.scoreCandidate(context.Background(), ct, req)
+		if !ok {
+			t.Fatalf("trip %d not matchable", ct.TripID)
+		}
+		if score := detour + penalty; best == 0 || score < bestScore {
+			best, bestScore = ct.TripID, score
+		}
+	}
+	return best
+}
+
+func TestBacktrackPenalty_ForwardCandidateWins(t *testing.T) {
+	back, fwd, req := backtrackScenario()
+
+	// Without the penalty the backtracking trip has the smaller detour.
+	if got := bestOf(t, NewMatchingService(nil, DefaultMatchConfig()), req, back, fwd); got != back.TripID {
+		t.Fatalf("no penalty: best = trip %d, want backtracking trip %d", got, back.TripID)
+	}
+
+	cfg := DefaultMatchConfig()
+	cfg.BacktrackPenaltyMinutes = 10
+	svc := NewMatchingService(nil, cfg)
+	if got := bestOf(t, svc, req, back, fwd); got != fwd.TripID {
+		t.Errorf("with penalty: best = trip %d, want forward trip %d", got, fwd.TripID)
+	}
+
+	// The penalty ranks only; the detour reported for the trip is unchanged.
+	detour, penalty, _ := svc.scoreCandidate(context.Background(), back, req)
+	plain, _, _ := NewMatchingService(nil, DefaultMatchConfig()).scoreCandidate(context.Background(), back, req)
+	if detour != plain || penalty <= 0 {
+		t.Errorf("backtracking trip: detour %.2f (want %.2f), penalty %.2f (want > 0)", detour, plain, penalty)
+	}
+}
+
+func TestBacktrackPenalty_NoneForForwardPickup(t *testing.T) {
+	cfg := DefaultMatchConfig()
+	cfg.BacktrackPenaltyMinutes = 10
+	svc := NewMatchingService(nil, cfg)
+
+	_, fwd, req := backtrackScenario()
+	if _, penalty, _ := svc.scoreCandidate(context.Background(), fwd, req); penalty != 0 {
+		t.Errorf("forward pickup penalty = %.2f, want 0", penalty)
+	}
+}
+
+// candidateStore returns fixed candidates regardless of the accessibility
+// filter, so the service's own check is what keeps them apart.
+type candidateStore struct {
+	candidates []model.CandidateTrip
+}
+
+func (c candidateStore) GetRideRequest(context.Context, int64, bool) (*model.RideRequest, error) {
+	return nil, ErrRequestNotFound
+}
+
+func (c candidateStore) FindNearbyCandidateTrips(context.Context, model.Location, model.TripDirection, int, bool) ([]model.CandidateTrip, error) {
+	return append([]model.CandidateTrip(nil), c.candidates...), nil
+}
+
+func (c candidateStore) GetTripStops(context.Context, int64) ([]model.Pickup, error) {
+	return []model.Pickup{{Origin: model.Location{Lat: 28.70, Lon: 77.10}, ToleranceMeters: MaxToleranceMeters}}, nil
+}
+
+func (c candidateStore) GetTripDropoffs(context.Context, int64) ([]model.Dropoff, error) {
+	return nil, nil
+}
+
+func TestFindBestTrip_AccessibleRiderOnlyMatchesEquippedCab(t *testing.T) {
+	standard, equipped := *plannedTrip(), *plannedTrip()
+	equipped.TripID, equipped.CabID, equipped.Accessible = 8, 4, true
+	svc := NewMatchingService(candidateStore{[]model.CandidateTrip{standard, equipped}}, DefaultMatchConfig())
+
+	tests := []struct {
+		name       string
+		accessible bool
+		wantTrip   int64
+	}{
+		{"requires accessible", true, equipped.TripID},
+		{"no requirement takes first best", false, standard.TripID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &model.RideRequest{
+				Origin: model.Location{Lat: 28.69, Lon: 77.10}, Direction: model.DirectionToAirport,
+				SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM, RequiresAccessible: tt.accessible,
+			}
+			match, _, err := svc.findBestTrip(context.Background(), req, nil)
+			if err != nil {
+				t.Fatalf("findBestTrip: %v", err)
+			}
+			if match.TripID != tt.wantTrip {
+				t.Errorf("matched trip %d, want %d", match.TripID, tt.wantTrip)
+			}
+		})
+	}
+}
+
+func TestFindBestTrip_AccessibleRiderNoEquippedCab(t *testing.T) {
+	svc := NewMatchingService(candidateStore{[]model.CandidateTrip{*plannedTrip()}}, DefaultMatchConfig())
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.69, Lon: 77.10}, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM, RequiresAccessible: true,
+	}
+	if _, _, err := svc.findBestTrip(context.Background(), req, nil); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("err = %v, want ErrNoMatch", err)
+	}
+}
+
+func TestFindBestTrip_EqualDetourPicksLowerTripID(t *testing.T) {
+	first, second := *plannedTrip(), *plannedTrip()
+	first.TripID, first.CabID = 12, 5
+	second.TripID, second.CabID = 9, 4
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.69, Lon: 77.10}, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM,
+	}
+
+	// Same stops → same detour; whatever order the rows arrive in, trip 9 wins.
+	for _, order := range [][]model.CandidateTrip{{first, second}, {second, first}} {
+		svc := NewMatchingService(candidateStore{order}, DefaultMatchConfig())
+		match, _, err := svc.findBestTrip(context.Background(), req, nil)
+		if err != nil {
+			t.Fatalf("findBestTrip: %v", err)
+		}
+		if match.TripID != 9 || match.CabID != 4 {
+			t.Errorf("rows %d,%d: matched trip %d (cab %d), want trip 9 (cab 4)",
+				order[0].TripID, order[1].TripID, match.TripID, match.CabID)
+		}
+	}
+}
+
+func TestFindBestTrip_DetourMetersMatchMinutesAtAverageSpeed(t *testing.T) {
+	svc := NewMatchingService(candidateStore{[]model.CandidateTrip{*plannedTrip()}}, DefaultMatchConfig())
+	// A pickup ~1 km east of the straight run to the airport.
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.65, Lon: 77.105}, Destination: model.Location{Lat: 28.5562, Lon: 77.0889},
+		Direction: model.DirectionToAirport, SeatsNeeded: 1, ToleranceMeters: MaxToleranceMeters,
+	}
+	match, _, err := svc.findBestTrip(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("findBestTrip: %v", err)
+	}
+	if match.AddedDetour <= 0 {
+		t.Fatalf("added detour = %.3f min, want a positive detour to compare", match.AddedDetour)
+	}
+	want := match.AddedDetour / 60 * geo.AverageSpeedKmph * 1000
+	if math.Abs(match.AddedDetourMeters-want) > 1e-6 {
+		t.Errorf("added detour = %.3f m for %.3f min, want %.3f m at %.0f km/h",
+			match.AddedDetourMeters, match.AddedDetour, want, geo.AverageSpeedKmph)
+	}
+}
+
+func TestFindBestTrip_LoadPreferenceFillsExistingCabFirst(t *testing.T) {
+	// Both trips can take the rider; the empty one has the shorter detour.
+	empty, loaded := *plannedTrip(), *plannedTrip()
+	empty.TripID, empty.CabID, empty.CurrentLoad, empty.CurrentLuggage = 5, 2, 0, 0
+	loaded.TripID, loaded.CabID, loaded.CurrentLoad = 6, 3, 2
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.65, Lon: 77.105}, Destination: model.Location{Lat: 28.5562, Lon: 77.0889},
+		Direction: model.DirectionToAirport, SeatsNeeded: 1, ToleranceMeters: MaxToleranceMeters,
+	}
+	stops := map[int64][]model.Location{
+		empty.TripID:  {{Lat: 28.66, Lon: 77.1067}}, // On the pickup's straight line to the airport.
+		loaded.TripID: {{Lat: 28.70, Lon: 77.10}},
+	}
+
+	tests := []struct {
+		name     string
+		weight   float64
+		wantTrip int64
+	}{
+		{"off: shortest detour wins", 0, empty.TripID},
+		{"on: loaded trip wins", 5, loaded.TripID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultMatchConfig()
+			cfg.LoadPreferenceMinutes = tt.weight
+			svc := NewMatchingService(stopStore{candidateStore{[]model.CandidateTrip{empty, loaded}}, stops}, cfg)
+
+			match, _, err := svc.findBestTrip(context.Background(), req, nil)
+			if err != nil {
+				t.Fatalf("findBestTrip: %v", err)
+			}
+			if match.TripID != tt.wantTrip {
+				t.Errorf("matched trip %d (detour %.2f min), want %d", match.TripID, match.AddedDetour, tt.wantTrip)
+			}
+			want := map[int64]int{empty.TripID: 0, loaded.TripID: 2}[match.TripID]
+			if match.CurrentLoad != want {
+				t.Errorf("current_load = %d, want %d", match.CurrentLoad, want)
+			}
+		})
+	}
+}
+
+// stopStore is a candidateStore with per-trip pickup stops.
+type stopStore struct {
+	candidateStore
+	stops map[int64][]model.Location
+}
+
+func (s stopStore) GetTripStops(_ context.Context, tripID int64) ([]model.Pickup, error) {
+	var pickups []model.Pickup
+	for _, stop := range s.stops[tripID] {
+		pickups = append(pickups, model.Pickup{Origin: stop, ToleranceMeters: MaxToleranceMeters})
+	}
+	return pickups, nil
+}
+
+func TestBetterCandidate(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		score     float64
+		tripID    int64
+		bestScore float64
+		bestTrip  int64
+		want      bool
+	}{
+		{"lower score", 1.0, 20, 2.0, 10, true},
+		{"higher score", 3.0, 5, 2.0, 10, false},
+		{"tie, lower id", 2.0, 5, 2.0, 10, true},
+		{"tie, higher id", 2.0, 20, 2.0, 10, false},
+		{"tie within epsilon", 2.0 + scoreTieEpsilon/2, 5, 2.0, 10, true},
+	} {
+		if got := betterCandidate(tt.score, tt.tripID, tt.bestScore, tt.bestTrip); got != tt.want {
+			t.Errorf("%s: betterCandidate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClampTolerance(t *testing.T) {
+	if got := ToleranceMinutes(MaxToleranceMeters); got != MaxDetourMinutes {
+		t.Fatalf("ToleranceMinutes(MaxToleranceMeters) = %.2f, want MaxDetourMinutes %.2f", got, MaxDetourMinutes)
+	}
+	for _, tt := range []struct {
+		in, limit, want int
+		wantClamped     bool
+	}{
+		{2000, 0, 2000, false},
+		{MaxToleranceMeters, 0, MaxToleranceMeters, false},
+		{MaxToleranceMeters + 1, 0, MaxToleranceMeters, true},
+		{50000, 0, MaxToleranceMeters, true},
+		{2000, 3000, 2000, false},
+		{3001, 3000, 3000, true},
+		{50000, MaxToleranceMeters * 2, MaxToleranceMeters, true}, // Configured above the ceiling.
+	} {
+		got, clamped := ClampTolerance(tt.in, tt.limit)
+		if got != tt.want || clamped != tt.wantClamped {
+			t.Errorf("ClampTolerance(%d, %d) = %d, %v; want %d, %v", tt.in, tt.limit, got, clamped, tt.want, tt.wantClamped)
+		}
+	}
+	for limit, wantErr := range map[int]bool{0: true, 1: false, MaxToleranceMeters: false, MaxToleranceMeters + 1: true} {
+		if err := ValidateToleranceLimit(limit); (err != nil) != wantErr {
+			t.Errorf("ValidateToleranceLimit(%d) = %v, want error %v", limit, err, wantErr)
+		}
+	}
+}
+
+func TestMatchRiders_ObservesLatency(t *testing.T) {
+	svc := NewMatchingService(&countingStore{}, DefaultMatchConfig())
+	total, fetch, score := metrics.MatchTotalMs.Count(), metrics.MatchFetchMs.Count(), metrics.MatchScoreMs.Count()
+
+	if _, err := svc.MatchRiders(context.Background(), 42); !errors.Is(err, ErrNoMatch) {
+		t.Fatalf("err = %v, want ErrNoMatch", err)
+	}
+	if metrics.MatchTotalMs.Count() != total+1 || metrics.MatchFetchMs.Count() != fetch+1 || metrics.MatchScoreMs.Count() != score+1 {
+		t.Errorf("match_latency_ms did not observe one sample per phase")
+	}
+}
+
+// downStore is a MatchStore whose database connection is gone.
+type downStore struct{ countingStore }
+
+func (downStore) GetRideRequest(context.Context, int64, bool) (*model.RideRequest, error) {
+	return nil, fmt.Errorf("get ride request: %w", io.ErrUnexpectedEOF)
+}
+
+func TestMatchRiders_ConnectionErrorIsNotNotFound(t *testing.T) {
+	svc := NewMatchingService(&downStore{}, DefaultMatchConfig())
+	_, err := svc.MatchRiders(context.Background(), 42)
+	if errors.Is(err, ErrRequestNotFound) || !repository.IsConnectionError(err) {
+		t.Errorf("err = %v, want the connection error, not ErrRequestNotFound", err)
+	}
+}
+
+// radiusStore returns its candidates only to searches at least within
+// meters wide, like trips that far from the pickup, and records the radii
+// it was asked for.
+type radiusStore struct {
+	candidateStore
+	within int
+	radii  *[]int
+}
+
+func (r radiusStore) FindNearbyCandidateTrips(ctx context.Context, origin model.Location, dir model.TripDirection, radius int, accessible bool) ([]model.CandidateTrip, error) {
+	*r.radii = append(*r.radii, radius)
+	if radius < r.within {
+		return nil, nil
+	}
+	return r.candidateStore.FindNearbyCandidateTrips(ctx, origin, dir, radius, accessible)
+}
+
+func TestFindBestTrip_ExpandsRadiusOnNoMatch(t *testing.T) {
+	var radii []int
+	cfg := DefaultMatchConfig()
+	cfg.RadiusExpansionSteps, cfg.MaxSearchRadiusM = 2, 6000
+	svc := NewMatchingService(radiusStore{candidateStore{[]model.CandidateTrip{*plannedTrip()}}, 3500, &radii}, cfg)
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.69, Lon: 77.10}, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM,
+	}
+
+	match, checked, err := svc.findBestTrip(context.Background(), req, nil)
+	if err != nil {
+		t.Fatalf("findBestTrip: %v", err)
+	}
+	if match.TripID != 7 || checked != 1 {
+		t.Errorf("matched trip %d after checking %d, want trip 7 after 1", match.TripID, checked)
+	}
+	// The 2 km search misses; the first step (4 km) finds the trip, so 6 km is never tried.
+	if len(radii) != 2 || radii[0] != 2000 || radii[1] != 4000 {
+		t.Errorf("searched radii %v, want [2000 4000]", radii)
+	}
+}
+
+func TestFindBestTrip_NoExpansionByDefault(t *testing.T) {
+	var radii []int
+	svc := NewMatchingService(radiusStore{candidateStore{[]model.CandidateTrip{*plannedTrip()}}, 3500, &radii}, DefaultMatchConfig())
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.69, Lon: 77.10}, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM,
+	}
+
+	if _, _, err := svc.findBestTrip(context.Background(), req, nil); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("err = %v, want ErrNoMatch", err)
+	}
+	if len(radii) != 1 {
+		t.Errorf("searched radii %v, want only the rider's own", radii)
+	}
+}
+
+func TestSearchRadii(t *testing.T) {
+	tests := []struct {
+		name      string
+		steps     int
+		max       int
+		tolerance int
+		want      []int
+	}{
+		{"off", 0, 6000, 2000, []int{2000}},
+		{"single step to max", 1, 6000, 2000, []int{2000, 6000}},
+		{"even steps", 3, 5000, 2000, []int{2000, 3000, 4000, 5000}},
+		{"unset tolerance starts at default", 1, 4000, 0, []int{DefaultSearchRadiusM, 4000}},
+		{"already wider than max", 2, 3000, 5000, []int{5000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultMatchConfig()
+			cfg.RadiusExpansionSteps, cfg.MaxSearchRadiusM = tt.steps, tt.max
+			got := NewMatchingService(nil, cfg).searchRadii(&model.RideRequest{ToleranceMeters: tt.tolerance})
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("radii = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculateDetour_RechecksRidersOnTheTrip(t *testing.T) {
+	airport := model.Location{Lat: 28.5562, Lon: 77.0889}
+	aboard := model.Location{Lat: 28.70, Lon: 77.10}
+	// Just off the aboard rider's line to the airport: picked up after them.
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.63, Lon: 77.11}, SeatsNeeded: 1, ToleranceMeters: MaxToleranceMeters,
+	}
+
+	for _, strategy := range []geo.InsertionStrategy{geo.InsertionMarginal, geo.InsertionCheapestTotal} {
+		cfg := DefaultMatchConfig()
+		cfg.InsertionStrategy = strategy
+		svc := NewMatchingService(nil, cfg)
+
+		for _, tt := range []struct {
+			tolerance int
+			want      bool
+		}{
+			{MaxToleranceMeters, true},
+			{1, false}, // The detour is longer than 1m for the rider already aboard.
+		} {
+			trip := &model.CandidateTrip{
+				TripID: 1, SeatCapacity: 4, LuggageCapacity: 4,
+				Route:   []model.Location{aboard, airport},
+				Pickups: []model.Pickup{{Origin: aboard, ToleranceMeters: tt.tolerance}},
+			}
+			if _, _, ok := svc.calculateDetour(context.Background(), trip, req); ok != tt.want {
+				t.Errorf("%s, aboard tolerance %dm: ok = %v, want %v", strategy, tt.tolerance, ok, tt.want)
+			}
+		}
+	}
+}
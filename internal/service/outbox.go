@@ -0,0 +1,236 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// ─── Outbox Relay ───────────────────────────────────────────
+
+// OutboxStore is the subset of the outbox repository the relay needs.
+type OutboxStore interface {
+	RelayUnsent(ctx context.Context, limit int, relay func([]model.OutboxEvent) repository.RelayOutcome) (int, error)
+}
+
+// DeadLetterStore is the subset of the outbox repository that reads and
+// replays events the publisher kept refusing.
+type DeadLetterStore interface {
+	GetDeadLetter(ctx context.Context, id int64) (*model.WebhookDeadLetter, error)
+	ClaimReplay(ctx context.Context, id int64) (*model.WebhookDeadLetter, bool, error)
+	MarkReplayed(ctx context.Context, id int64) error
+	RecordReplayFailure(ctx context.Context, id int64, lastErr string) error
+}
+
+var (
+	// ErrAlreadyReplayed is returned by Replay for a dead letter an
+	// earlier replay already delivered.
+	ErrAlreadyReplayed = errors.New("dead letter already replayed")
+
+	// ErrReplayFailed is returned by Replay when the publisher refused
+	// the event again.
+	ErrReplayFailed = errors.New("dead letter replay failed")
+)
+
+// EventPublisher delivers a single outbox event downstream.
+type EventPublisher interface {
+	Publish(ctx context.Context, event model.OutboxEvent) error
+}
+
+// OutboxRelay publishes unsent outbox rows and marks them sent.
+//
+// Delivery is at-least-once: an event is only marked sent after Publish
+// succeeds, so a crash between the two re-publishes it on the next tick.
+// Consumers should de-duplicate on the event ID. A batch is claimed, not
+// locked, while it is published (repository.OutboxRepository.RelayUnsent),
+// so relays on several replicas do not publish the same event twice and
+// no database transaction stays open across a slow webhook.
+type OutboxRelay struct {
+	store     OutboxStore
+	publisher EventPublisher
+	interval  time.Duration
+	batchSize int
+
+	// MaxAttempts > 0 parks an event that failed to publish MaxAttempts
+	// times in a row in webhook_deadletter and moves on, instead of
+	// retrying it forever and holding up every event behind it. The count
+	// is kept on the outbox row, so it survives restarts and is shared by
+	// every replica's relay. DeadLetters reads them back for Replay.
+	DeadLetters DeadLetterStore
+	MaxAttempts int
+}
+
+// NewOutboxRelay creates a relay that polls every interval for up to batchSize events.
+func NewOutboxRelay(store OutboxStore, publisher EventPublisher, interval time.Duration, batchSize int) *OutboxRelay {
+	return &OutboxRelay{
+		store:     store,
+		publisher: publisher,
+		interval:  interval,
+		batchSize: batchSize,
+	}
+}
+
+// Run relays events until ctx is cancelled.
+func (r *OutboxRelay) Run(ctx context.Context) {
+	log.Printf("[outbox] Relay started (interval=%s, batch=%d)", r.interval, r.batchSize)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[outbox] Relay stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.RelayOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[outbox] WARNING: relay failed: %v", err)
+			}
+		}
+	}
+}
+
+// RelayOnce publishes one batch of unsent events in order and marks the
+// published ones sent. It stops at the first publish failure so that
+// ordering is preserved; the failure is counted on the event's outbox row
+// and the event retried on the next call, unless that failure used up its
+// MaxAttempts and it was dead-lettered.
+// Returns the number of events marked sent.
+func (r *OutboxRelay) RelayOnce(ctx context.Context) (int, error) {
+	var out repository.RelayOutcome
+	var publishErr error
+	n, err := r.store.RelayUnsent(ctx, r.batchSize, func(events []model.OutboxEvent) repository.RelayOutcome {
+		out = repository.RelayOutcome{Sent: make([]int64, 0, len(events))}
+		for _, e := range events {
+			if err := r.publisher.Publish(ctx, e); err != nil {
+				// A failure caused by ctx ending (shutdown) is not counted.
+				if ctx.Err() == nil {
+					failed := repository.ParkedEvent{Event: e, Attempts: e.Attempts + 1, LastError: err.Error()}
+					if r.MaxAttempts > 0 && failed.Attempts >= r.MaxAttempts {
+						out.Parked = append(out.Parked, failed)
+						continue
+					}
+					out.Failed = append(out.Failed, failed)
+				}
+				publishErr = fmt.Errorf("outbox: publish event %d (%s): %w", e.ID, e.EventType, err)
+				break
+			}
+			out.Sent = append(out.Sent, e.ID)
+		}
+		return out
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range out.Parked {
+		log.Printf("[outbox] Dead-lettered event %d (%s) after %d attempts: %s", p.Event.ID, p.Event.EventType, p.Attempts, p.LastError)
+	}
+	if n > 0 {
+		log.Printf("[outbox] Relayed %d events", n)
+	}
+	return n, publishErr
+}
+
+// Replay publishes dead letter id's event again, e.g. once the webhook
+// is fixed. The dead letter is claimed (stamped replayed) first, so two
+// operators replaying it at once deliver it only once. On success it is
+// returned; on failure its attempts and last error are updated, the
+// claim is released, and the error wraps ErrReplayFailed. A dead letter
+// already replayed, or being replayed, gets ErrAlreadyReplayed.
+func (r *OutboxRelay) Replay(ctx context.Context, id int64) (*model.WebhookDeadLetter, error) {
+	if r.DeadLetters == nil {
+		return nil, fmt.Errorf("outbox: replay %d: %w", id, repository.ErrDeadLetterNotFound)
+	}
+	dl, claimed, err := r.DeadLetters.ClaimReplay(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, fmt.Errorf("outbox: replay %d: %w", id, ErrAlreadyReplayed)
+	}
+
+	// The outcome is recorded even if ctx ends: the claim must not stick
+	// to an event that was never delivered.
+	recordCtx := context.WithoutCancel(ctx)
+	if publishErr := r.publisher.Publish(ctx, dl.Event); publishErr != nil {
+		if err := r.DeadLetters.RecordReplayFailure(recordCtx, id, publishErr.Error()); err != nil {
+			log.Printf("[outbox] WARNING: record replay failure of dead letter %d: %v", id, err)
+		}
+		return nil, fmt.Errorf("outbox: replay %d (event %d): %w: %v", id, dl.Event.ID, ErrReplayFailed, publishErr)
+	}
+	if err := r.DeadLetters.MarkReplayed(recordCtx, id); err != nil {
+		return nil, err
+	}
+	log.Printf("[outbox] Replayed dead letter %d (event %d)", id, dl.Event.ID)
+	return r.DeadLetters.GetDeadLetter(ctx, id)
+}
+
+// ─── Publishers ─────────────────────────────────────────────
+
+// RedisPublisher publishes events as JSON on a Redis Pub/Sub channel.
+type RedisPublisher struct {
+	client  *redis.Client
+	channel string
+}
+
+// NewRedisPublisher creates a publisher for the given channel.
+func NewRedisPublisher(client *redis.Client, channel string) *RedisPublisher {
+	return &RedisPublisher{client: client, channel: channel}
+}
+
+// Publish sends the event to the channel.
+func (p *RedisPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return p.client.Publish(ctx, p.channel, body).Err()
+}
+
+// WebhookPublisher POSTs events as JSON to an HTTP endpoint.
+// Any non-2xx response counts as a failed delivery.
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookPublisher creates a publisher for the given URL.
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Publish POSTs the event to the webhook URL.
+func (p *WebhookPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,308 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+type fakeOutboxStore struct {
+	events []model.OutboxEvent
+	sent   map[int64]bool
+	dead   *fakeDeadLetters // Where parked events go; set by newFakeDeadLetters.
+}
+
+func (f *fakeOutboxStore) RelayUnsent(_ context.Context, limit int, relay func([]model.OutboxEvent) repository.RelayOutcome) (int, error) {
+	var batch []model.OutboxEvent
+	for _, e := range f.events {
+		if !f.sent[e.ID] && len(batch) < limit {
+			batch = append(batch, e)
+		}
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	out := relay(batch)
+	for _, id := range out.Sent {
+		f.sent[id] = true
+	}
+	for _, failed := range out.Failed {
+		for i := range f.events {
+			if f.events[i].ID == failed.Event.ID {
+				f.events[i].Attempts = failed.Attempts
+			}
+		}
+	}
+	for _, p := range out.Parked {
+		f.dead.park(p)
+		f.sent[p.Event.ID] = true
+	}
+	return len(out.Sent), nil
+}
+
+type fakePublisher struct {
+	published []int64
+	failOn    int64
+}
+
+func (f *fakePublisher) Publish(_ context.Context, e model.OutboxEvent) error {
+	if e.ID == f.failOn {
+		return errors.New("downstream unavailable")
+	}
+	f.published = append(f.published, e.ID)
+	return nil
+}
+
+func newFakeStore(ids ...int64) *fakeOutboxStore {
+	store := &fakeOutboxStore{sent: map[int64]bool{}}
+	for _, id := range ids {
+		store.events = append(store.events, model.OutboxEvent{ID: id, EventType: model.EventRideCreated})
+	}
+	return store
+}
+
+func TestOutboxRelay_MarksPublishedEventsSent(t *testing.T) {
+	store := newFakeStore(1, 2, 3)
+	pub := &fakePublisher{}
+	relay := NewOutboxRelay(store, pub, 0, 10)
+
+	n, err := relay.RelayOnce(context.Background())
+	if err != nil {
+		t.Fatalf("RelayOnce: %v", err)
+	}
+	if n != 3 || len(pub.published) != 3 {
+		t.Fatalf("relayed %d, published %d; want 3, 3", n, len(pub.published))
+	}
+	for _, id := range []int64{1, 2, 3} {
+		if !store.sent[id] {
+			t.Errorf("event %d not marked sent", id)
+		}
+	}
+
+	// Nothing left on the next pass.
+	if n, _ := relay.RelayOnce(context.Background()); n != 0 {
+		t.Errorf("second pass relayed %d, want 0", n)
+	}
+}
+
+func TestOutboxRelay_StopsAtFirstFailure(t *testing.T) {
+	store := newFakeStore(1, 2, 3)
+	pub := &fakePublisher{failOn: 2}
+	relay := NewOutboxRelay(store, pub, 0, 10)
+
+	n, err := relay.RelayOnce(context.Background())
+	if err == nil {
+		t.Fatal("RelayOnce: want error for failed publish")
+	}
+	if n != 1 {
+		t.Errorf("relayed %d, want 1", n)
+	}
+	if !store.sent[1] || store.sent[2] || store.sent[3] {
+		t.Errorf("sent = %v, want only event 1", store.sent)
+	}
+}
+
+func TestWebhookPublisher_Non2xxIsFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	pub := NewWebhookPublisher(srv.URL, 0)
+	if err := pub.Publish(context.Background(), model.OutboxEvent{ID: 1}); err == nil {
+		t.Error("Publish: want error for 502 response")
+	}
+}
+
+// fakeDeadLetters is an in-memory webhook_deadletter table that the
+// fakeOutboxStore it is attached to parks events in, marking them sent
+// as the repository does in the same transaction.
+type fakeDeadLetters struct {
+	letters map[int64]*model.WebhookDeadLetter
+}
+
+func newFakeDeadLetters(outbox *fakeOutboxStore) *fakeDeadLetters {
+	outbox.dead = &fakeDeadLetters{letters: map[int64]*model.WebhookDeadLetter{}}
+	return outbox.dead
+}
+
+func (f *fakeDeadLetters) park(p repository.ParkedEvent) {
+	id := int64(len(f.letters) + 1)
+	f.letters[id] = &model.WebhookDeadLetter{ID: id, Event: p.Event, Attempts: p.Attempts, LastError: p.LastError}
+}
+
+func (f *fakeDeadLetters) GetDeadLetter(_ context.Context, id int64) (*model.WebhookDeadLetter, error) {
+	dl, ok := f.letters[id]
+	if !ok {
+		return nil, repository.ErrDeadLetterNotFound
+	}
+	copied := *dl
+	return &copied, nil
+}
+
+func (f *fakeDeadLetters) ClaimReplay(ctx context.Context, id int64) (*model.WebhookDeadLetter, bool, error) {
+	dl, ok := f.letters[id]
+	if !ok {
+		return nil, false, repository.ErrDeadLetterNotFound
+	}
+	claimed := dl.ReplayedAt == nil
+	if claimed {
+		now := time.Now()
+		dl.ReplayedAt = &now
+	}
+	copied, _ := f.GetDeadLetter(ctx, id)
+	return copied, claimed, nil
+}
+
+func (f *fakeDeadLetters) MarkReplayed(_ context.Context, id int64) error {
+	f.letters[id].Attempts++
+	return nil
+}
+
+func (f *fakeDeadLetters) RecordReplayFailure(_ context.Context, id int64, lastErr string) error {
+	f.letters[id].Attempts++
+	f.letters[id].LastError = lastErr
+	f.letters[id].ReplayedAt = nil
+	return nil
+}
+
+// flakyWebhook answers 500 until healthy is set, and counts deliveries.
+func flakyWebhook(t *testing.T, healthy *atomic.Bool, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOutboxRelay_DeadLettersAfterMaxAttempts(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := flakyWebhook(t, &healthy, &hits)
+
+	store := newFakeStore(1)
+	dead := newFakeDeadLetters(store)
+	relay := NewOutboxRelay(store, NewWebhookPublisher(srv.URL, time.Second), 0, 10)
+	relay.DeadLetters, relay.MaxAttempts = dead, 3
+
+	for pass := 1; pass <= 2; pass++ {
+		if _, err := relay.RelayOnce(context.Background()); err == nil {
+			t.Fatalf("pass %d: want publish error", pass)
+		}
+		if len(dead.letters) != 0 || store.sent[1] {
+			t.Fatalf("pass %d: event dead-lettered before MaxAttempts", pass)
+		}
+	}
+	if _, err := relay.RelayOnce(context.Background()); err != nil {
+		t.Fatalf("pass 3: %v, want the event parked without error", err)
+	}
+	dl := dead.letters[1]
+	if dl == nil || dl.Event.ID != 1 || dl.Attempts != 3 || !strings.Contains(dl.LastError, "500") {
+		t.Fatalf("dead letter = %+v, want event 1 after 3 attempts with the 500 error", dl)
+	}
+	if !store.sent[1] {
+		t.Error("dead-lettered event still unsent; the relay would keep retrying it")
+	}
+
+	// Nothing left to relay: the endpoint is not hit again.
+	before := hits.Load()
+	if n, err := relay.RelayOnce(context.Background()); n != 0 || err != nil || hits.Load() != before {
+		t.Errorf("after dead-letter: relayed %d, err %v, %d new deliveries; want none", n, err, hits.Load()-before)
+	}
+}
+
+func TestOutboxRelay_AttemptsSurviveRestart(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := flakyWebhook(t, &healthy, &hits)
+
+	store := newFakeStore(1)
+	dead := newFakeDeadLetters(store)
+	// Each pass runs on a fresh relay, as after a restart or on another replica.
+	for pass := 1; pass <= 3; pass++ {
+		relay := NewOutboxRelay(store, NewWebhookPublisher(srv.URL, time.Second), 0, 10)
+		relay.DeadLetters, relay.MaxAttempts = dead, 3
+		relay.RelayOnce(context.Background())
+	}
+	if dl := dead.letters[1]; dl == nil || dl.Attempts != 3 {
+		t.Fatalf("dead letter = %+v, want event 1 parked after 3 attempts across relays", dl)
+	}
+}
+
+func TestOutboxRelay_ReplayOfClaimedDeadLetterIsNotDelivered(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := flakyWebhook(t, &healthy, &hits)
+	healthy.Store(true)
+
+	store := newFakeStore(1)
+	dead := newFakeDeadLetters(store)
+	dead.park(repository.ParkedEvent{Event: store.events[0], Attempts: 3, LastError: "500"})
+	relay := NewOutboxRelay(store, NewWebhookPublisher(srv.URL, time.Second), 0, 10)
+	relay.DeadLetters = dead
+
+	// Another replay holds the claim while it publishes.
+	if _, claimed, _ := dead.ClaimReplay(context.Background(), 1); !claimed {
+		t.Fatal("first claim failed")
+	}
+	if _, err := relay.Replay(context.Background(), 1); !errors.Is(err, ErrAlreadyReplayed) {
+		t.Errorf("Replay err = %v, want ErrAlreadyReplayed while claimed", err)
+	}
+	if hits.Load() != 0 {
+		t.Errorf("%d deliveries, want none from the second replay", hits.Load())
+	}
+}
+
+func TestOutboxRelay_ReplayReattemptsDeadLetter(t *testing.T) {
+	var healthy atomic.Bool
+	var hits atomic.Int32
+	srv := flakyWebhook(t, &healthy, &hits)
+
+	store := newFakeStore(1)
+	dead := newFakeDeadLetters(store)
+	relay := NewOutboxRelay(store, NewWebhookPublisher(srv.URL, time.Second), 0, 10)
+	relay.DeadLetters, relay.MaxAttempts = dead, 1
+	relay.RelayOnce(context.Background())
+	if len(dead.letters) != 1 {
+		t.Fatalf("%d dead letters, want 1", len(dead.letters))
+	}
+
+	// Still failing: the replay is attempted and recorded.
+	before := hits.Load()
+	if _, err := relay.Replay(context.Background(), 1); !errors.Is(err, ErrReplayFailed) {
+		t.Fatalf("Replay err = %v, want ErrReplayFailed", err)
+	}
+	if hits.Load() != before+1 || dead.letters[1].Attempts != 2 || dead.letters[1].ReplayedAt != nil {
+		t.Errorf("failed replay: %d deliveries, dead letter %+v; want 1 delivery, 2 attempts, not replayed",
+			hits.Load()-before, dead.letters[1])
+	}
+
+	healthy.Store(true)
+	dl, err := relay.Replay(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	if dl.ReplayedAt == nil || dl.Attempts != 3 {
+		t.Errorf("dead letter = %+v, want replayed after 3 attempts", dl)
+	}
+
+	if _, err := relay.Replay(context.Background(), 1); !errors.Is(err, ErrAlreadyReplayed) {
+		t.Errorf("second Replay err = %v, want ErrAlreadyReplayed", err)
+	}
+	if _, err := relay.Replay(context.Background(), 99); !errors.Is(err, repository.ErrDeadLetterNotFound) {
+		t.Errorf("Replay(99) err = %v, want ErrDeadLetterNotFound", err)
+	}
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// ─── Background Pooler ──────────────────────────────────────
+
+// PendingLister is the part of repository.RideRepository the pooler scans.
+type PendingLister interface {
+	ListPendingRequests(ctx context.Context, limit int) ([]model.RideRequest, error)
+}
+
+// PoolBooker books a ride request (BookingService).
+type PoolBooker interface {
+	BookRide(ctx context.Context, requestID int64, opts BookingOptions) (*repository.BookingResult, error)
+}
+
+// PoolPlanner finds the pending riders near a request and whether they
+// could share a trip it starts (MatchingService).
+type PoolPlanner interface {
+	ClusterPending(ctx context.Context, req *model.RideRequest) ([]model.RideRequest, error)
+	CanJoinSeed(ctx context.Context, seed, req *model.RideRequest) bool
+}
+
+// PoolingMatcher periodically scans the pending pool and books riders
+// waiting near each other into shared trips before any of them books on
+// their own, so fewer trips leave with a single rider.
+//
+// A rider's neighbours are the pending requests ClusterPending finds
+// (MatchConfig.ClusterRadiusM, same direction); a rider with none is left
+// pending. Riders with neighbours first try to join existing trips. Then
+// each one left, oldest first, seeds a new trip only if at least one
+// neighbour still pending passes CanJoinSeed, and those neighbours join
+// it.
+//
+// Every booking goes through BookingService.BookRide, with its locking,
+// fares and surge counters. maxBookings caps how many requests one run
+// books, bounding the booking load the pooler adds per interval.
+type PoolingMatcher struct {
+	pending     PendingLister
+	planner     PoolPlanner
+	booker      PoolBooker
+	interval    time.Duration
+	maxBookings int
+	scanLimit   int
+}
+
+// NewPoolingMatcher creates a pooler that runs every interval, reads up to
+// scanLimit pending requests (oldest first) and books at most maxBookings
+// of them per run.
+func NewPoolingMatcher(pending PendingLister, planner PoolPlanner, booker PoolBooker, interval time.Duration, maxBookings, scanLimit int) *PoolingMatcher {
+	return &PoolingMatcher{pending: pending, planner: planner, booker: booker, interval: interval, maxBookings: maxBookings, scanLimit: scanLimit}
+}
+
+// Run pools until ctx is cancelled.
+func (p *PoolingMatcher) Run(ctx context.Context) {
+	log.Printf("[pooler] Started (interval=%s, max_bookings=%d, scan_limit=%d)", p.interval, p.maxBookings, p.scanLimit)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[pooler] Stopped")
+			return
+		case <-ticker.C:
+			if _, err := p.PoolOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[pooler] WARNING: pooling run failed: %v", err)
+			}
+		}
+	}
+}
+
+// PoolOnce runs one pooling pass and returns how many requests it booked.
+func (p *PoolingMatcher) PoolOnce(ctx context.Context) (int, error) {
+	pending, err := p.pending.ListPendingRequests(ctx, p.scanLimit)
+	if err != nil {
+		return 0, fmt.Errorf("pooler: %w", err)
+	}
+
+	var grouped []model.RideRequest
+	neighbours := map[int64][]model.RideRequest{}
+	for i := range pending {
+		nearby, err := p.planner.ClusterPending(ctx, &pending[i])
+		if err != nil {
+			return 0, fmt.Errorf("pooler: %w", err)
+		}
+		if len(nearby) > 0 {
+			grouped = append(grouped, pending[i])
+			neighbours[pending[i].ID] = nearby
+		}
+	}
+
+	booked := p.pool(ctx, grouped, neighbours)
+	if booked > 0 {
+		log.Printf("[pooler] Booked %d pending requests into shared trips", booked)
+	}
+	return booked, nil
+}
+
+// pool books riders (oldest first, each with pending neighbours) into
+// shared trips, at most maxBookings of them, and returns how many it
+// booked. Riders that fit no trip stay pending.
+func (p *PoolingMatcher) pool(ctx context.Context, riders []model.RideRequest, neighbours map[int64][]model.RideRequest) int {
+	booked := 0
+	done := map[int64]bool{} // Booked, or no longer bookable, this run.
+
+	// Existing trips first: joining one costs no extra cab.
+	var left []model.RideRequest
+	for _, req := range riders {
+		if booked == p.maxBookings || ctx.Err() != nil {
+			return booked
+		}
+		switch err := p.book(ctx, req, true); {
+		case err == nil:
+			booked++
+			done[req.ID] = true
+		case errors.Is(err, ErrNoMatch):
+			left = append(left, req)
+		default:
+			done[req.ID] = true
+		}
+	}
+
+	// Then seed new trips, only while there is budget for the seed and one
+	// rider to join it, and only once a neighbour is known to fit.
+	for i := range left {
+		seed := &left[i]
+		if p.maxBookings-booked < 2 || ctx.Err() != nil {
+			break
+		}
+		if done[seed.ID] {
+			continue
+		}
+		var joiners []model.RideRequest
+		for _, req := range neighbours[seed.ID] {
+			if !done[req.ID] && p.planner.CanJoinSeed(ctx, seed, &req) {
+				joiners = append(joiners, req)
+			}
+		}
+		if len(joiners) == 0 {
+			continue
+		}
+
+		err := p.book(ctx, *seed, false)
+		if errors.Is(err, ErrNoCabNearby) {
+			continue
+		}
+		done[seed.ID] = true
+		if err != nil {
+			continue
+		}
+		booked++
+
+		joined := 0
+		for _, req := range joiners {
+			if booked == p.maxBookings {
+				break
+			}
+			if err := p.book(ctx, req, true); err == nil {
+				booked++
+				joined++
+				done[req.ID] = true
+			} else if !errors.Is(err, ErrNoMatch) {
+				done[req.ID] = true
+			}
+		}
+		if joined == 0 {
+			log.Printf("[pooler] Request #%d seeded a trip no neighbour could join", seed.ID)
+		}
+	}
+	return booked
+}
+
+// book books req, onto an existing trip only when matchOnly is set. A
+// request booked elsewhere since it was listed comes back as its earlier
+// booking; book reports that as ErrRequestNotPending, so it is neither
+// counted nor seeds a trip. It logs failures other than ErrNoMatch, the
+// rider's request having been booked or cancelled in the meantime, and a
+// missing cab.
+func (p *PoolingMatcher) book(ctx context.Context, req model.RideRequest, matchOnly bool) error {
+	res, err := p.booker.BookRide(ctx, req.ID, BookingOptions{MatchOnly: matchOnly})
+	if err == nil && res.Replayed {
+		return fmt.Errorf("pooler: request %d already booked into trip %d: %w", req.ID, res.TripID, ErrRequestNotPending)
+	}
+	if err != nil && !errors.Is(err, ErrNoMatch) && !errors.Is(err, ErrRequestNotPending) &&
+		!errors.Is(err, ErrNoCabNearby) && ctx.Err() == nil {
+		log.Printf("[pooler] WARNING: booking request #%d failed: %v", req.ID, err)
+	}
+	return err
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+// fakePendingList serves a fixed pending pool.
+type fakePendingList []model.RideRequest
+
+func (f fakePendingList) ListPendingRequests(_ context.Context, limit int) ([]model.RideRequest, error) {
+	return f[:min(limit, len(f))], nil
+}
+
+// fakePoolBooker books onto in-memory trips: a request joins the first
+// trip going its way with a pickup within 2 km and room for another rider,
+// otherwise (unless MatchOnly) it claims a cab for a trip of its own.
+// Requests in bookedElsewhere get their earlier booking back, as
+// BookingService does for a request booked since the pooler listed it.
+type fakePoolBooker struct {
+	requests        map[int64]model.RideRequest
+	trips           [][]model.RideRequest
+	perTrip         int             // riders per trip
+	cabs            int             // cabs left to claim
+	bookedElsewhere map[int64]int64 // request → trip
+}
+
+func newFakePoolBooker(pending []model.RideRequest) *fakePoolBooker {
+	f := &fakePoolBooker{requests: map[int64]model.RideRequest{}, perTrip: 4, cabs: 10}
+	for _, req := range pending {
+		f.requests[req.ID] = req
+	}
+	return f
+}
+
+func (f *fakePoolBooker) BookRide(_ context.Context, requestID int64, opts BookingOptions) (*repository.BookingResult, error) {
+	if tripID, ok := f.bookedElsewhere[requestID]; ok {
+		return &repository.BookingResult{RequestID: requestID, TripID: tripID, Replayed: true}, nil
+	}
+	req := f.requests[requestID]
+	for i, trip := range f.trips {
+		if len(trip) < f.perTrip && trip[0].Direction == req.Direction && geo.HaversineM(trip[0].Origin, req.Origin) <= 2000 {
+			f.trips[i] = append(trip, req)
+			return &repository.BookingResult{RequestID: requestID, TripID: int64(i + 1), Pooled: true}, nil
+		}
+	}
+	if opts.MatchOnly {
+		return nil, ErrNoMatch
+	}
+	if f.cabs == 0 {
+		return nil, ErrNoCabNearby
+	}
+	f.cabs--
+	f.trips = append(f.trips, []model.RideRequest{req})
+	return &repository.BookingResult{RequestID: requestID, TripID: int64(len(f.trips))}, nil
+}
+
+// newTestPooler pools pending through booker, finding neighbours within
+// the default cluster radius with a real MatchingService.
+func newTestPooler(pending fakePendingList, booker *fakePoolBooker, maxBookings int) *PoolingMatcher {
+	cfg := DefaultMatchConfig()
+	cfg.ClusterRadiusM = 2000
+	cfg.DepartureWindow = 30 * time.Minute
+	planner := NewMatchingService(nil, cfg)
+	planner.Pending = &geoPending{requests: pending}
+	return NewPoolingMatcher(pending, planner, booker, 0, maxBookings, 100)
+}
+
+func pendingAt(id int64, lat, lon float64, dir model.TripDirection) model.RideRequest {
+	return model.RideRequest{ID: id, Origin: model.Location{Lat: lat, Lon: lon}, Direction: dir, SeatsNeeded: 1, Status: model.RequestPending}
+}
+
+func TestPoolingMatcher_PoolsTwoNearbyRequests(t *testing.T) {
+	pending := fakePendingList{
+		pendingAt(1, 28.7041, 77.1025, model.DirectionToAirport),
+		pendingAt(2, 28.7050, 77.1030, model.DirectionToAirport), // ~110 m away
+	}
+	booker := newFakePoolBooker(pending)
+	p := newTestPooler(pending, booker, 10)
+
+	n, err := p.PoolOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("booked = %d, want 2", n)
+	}
+	if len(booker.trips) != 1 || len(booker.trips[0]) != 2 {
+		t.Fatalf("trips = %v, want one trip carrying both requests", booker.trips)
+	}
+	if booker.trips[0][0].ID != 1 {
+		t.Errorf("trip seeded by request %d, want the older request 1", booker.trips[0][0].ID)
+	}
+}
+
+func TestPoolingMatcher_JoinsExistingTripBeforeSeeding(t *testing.T) {
+	pending := fakePendingList{
+		pendingAt(1, 28.7041, 77.1025, model.DirectionToAirport),
+		pendingAt(2, 28.7050, 77.1030, model.DirectionToAirport),
+	}
+	booker := newFakePoolBooker(pending)
+	booker.trips = [][]model.RideRequest{{pendingAt(99, 28.7045, 77.1028, model.DirectionToAirport)}}
+	p := newTestPooler(pending, booker, 10)
+
+	if _, err := p.PoolOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(booker.trips) != 1 || len(booker.trips[0]) != 3 {
+		t.Errorf("trips = %v, want both requests on the existing trip and no new one", booker.trips)
+	}
+}
+
+func TestPoolingMatcher_LeavesLoneRequestsPending(t *testing.T) {
+	pending := fakePendingList{
+		pendingAt(1, 28.7041, 77.1025, model.DirectionToAirport),
+		pendingAt(2, 28.4595, 77.0266, model.DirectionToAirport),   // Gurgaon
+		pendingAt(3, 28.7041, 77.1025, model.DirectionFromAirport), // same spot, other way
+	}
+	booker := newFakePoolBooker(pending)
+	p := newTestPooler(pending, booker, 10)
+
+	n, err := p.PoolOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || len(booker.trips) != 0 {
+		t.Errorf("booked %d into %v, want nothing: no two requests are near each other going the same way", n, booker.trips)
+	}
+}
+
+func TestPoolingMatcher_ReplayedBookingIsNotCounted(t *testing.T) {
+	pending := fakePendingList{
+		pendingAt(1, 28.7041, 77.1025, model.DirectionToAirport), // booked elsewhere since listed
+		pendingAt(2, 28.7050, 77.1030, model.DirectionToAirport),
+		pendingAt(3, 28.7045, 77.1020, model.DirectionToAirport),
+	}
+	booker := newFakePoolBooker(pending)
+	booker.bookedElsewhere = map[int64]int64{1: 50}
+	p := newTestPooler(pending, booker, 10)
+
+	n, err := p.PoolOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("booked = %d, want 2: request 1's earlier booking is not the pooler's", n)
+	}
+	if len(booker.trips) != 1 || booker.trips[0][0].ID != 2 || len(booker.trips[0]) != 2 {
+		t.Errorf("trips = %v, want one trip seeded by request 2 and joined by 3", booker.trips)
+	}
+}
+
+func TestPoolingMatcher_CapsBookingsPerRun(t *testing.T) {
+	pending := fakePendingList{
+		pendingAt(1, 28.7041, 77.1025, model.DirectionToAirport),
+		pendingAt(2, 28.7050, 77.1030, model.DirectionToAirport),
+		pendingAt(3, 28.4595, 77.0266, model.DirectionToAirport),
+		pendingAt(4, 28.4600, 77.0270, model.DirectionToAirport),
+	}
+	booker := newFakePoolBooker(pending)
+	p := newTestPooler(pending, booker, 3)
+
+	n, err := p.PoolOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second pair would need 2 more bookings; with 1 left it waits for
+	// the next run rather than seeding a trip nobody joins.
+	if n != 2 || len(booker.trips) != 1 {
+		t.Errorf("booked %d into %d trips, want 2 into 1 under a cap of 3", n, len(booker.trips))
+	}
+}
+
+func TestPoolingMatcher_SeedsOnlyWithAFittingNeighbour(t *testing.T) {
+	pending := fakePendingList{
+		pendingAt(1, 28.7041, 77.1025, model.DirectionToAirport),
+		pendingAt(2, 28.7050, 77.1030, model.DirectionToAirport),
+	}
+	later := time.Now().Add(3 * time.Hour)
+	pending[1].ScheduledAt = &later // Leaves too long after request 1 to share its cab.
+	booker := newFakePoolBooker(pending)
+	p := newTestPooler(pending, booker, 10)
+
+	n, err := p.PoolOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 || len(booker.trips) != 0 {
+		t.Errorf("booked %d into %v, want no trip seeded for a rider no neighbour can join", n, booker.trips)
+	}
+}
+
+func TestPoolingMatcher_NoCabLeavesRidersPending(t *testing.T) {
+	pending := fakePendingList{
+		pendingAt(1, 28.7041, 77.1025, model.DirectionToAirport),
+		pendingAt(2, 28.7050, 77.1030, model.DirectionToAirport),
+	}
+	booker := newFakePoolBooker(pending)
+	booker.cabs = 0
+	p := newTestPooler(pending, booker, 10)
+
+	n, err := p.PoolOnce(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("booked = %d, want 0 with no cab to claim", n)
+	}
+}
@@ -2,12 +2,17 @@ package service
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"math"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/shiva/hintro/internal/model"
 	"github.com/shiva/hintro/internal/repository"
 	"github.com/shiva/hintro/pkg/geo"
+	"github.com/shiva/hintro/pkg/logctx"
+	"github.com/shiva/hintro/pkg/tracing"
 )
 
 // ─── Fare Configuration ─────────────────────────────────────
@@ -15,21 +20,62 @@ import (
 // FareConfig holds the pricing parameters.
 // In production, these would come from a config file or database.
 type FareConfig struct {
-	BaseFareCents    int     // Fixed base fare in cents (e.g., ₹50 = 5000 paisa).
-	PerKmRateCents   int     // Rate per kilometer in cents (e.g., ₹12/km = 1200).
-	PerMinRateCents  int     // Rate per minute in cents (e.g., ₹2/min = 200).
-	MinFareCents     int     // Minimum fare floor in cents.
-	SurgeRadiusM     int     // Radius in meters for demand/supply calculation.
+	BaseFareCents   int // Fixed base fare in cents (e.g., ₹50 = 5000 paisa).
+	PerKmRateCents  int // Rate per kilometer in cents (e.g., ₹12/km = 1200).
+	PerMinRateCents int // Rate per minute in cents (e.g., ₹2/min = 200).
+	MinFareCents    int // Minimum fare floor in cents.
+	SurgeRadiusM    int // Radius in meters for demand/supply calculation.
+
+	// PoolDiscountPercent is taken off the fare when a request joins an
+	// existing trip (not when it seeds a new one). 0 disables the discount.
+	PoolDiscountPercent int
+
+	// MinBillableKm and MinBillableMinutes floor the distance and time
+	// components, so a same-building pickup (tens of meters, well under a
+	// minute at average speed) is not priced as a near-zero ride. 0 disables
+	// a floor. The route's reported distance and minutes are unchanged.
+	MinBillableKm      float64
+	MinBillableMinutes float64
+
+	// MaxSurgeMultiplier caps the surge multiplier (e.g. 1.3 during a
+	// declared event). Estimates hit by the cap say so with surge_capped.
+	// 0 means no cap.
+	MaxSurgeMultiplier float64
+
+	// SurgeRoundingStep rounds the surge multiplier to the nearest multiple
+	// of the step (0.1, 0.25, 0.5, 1 ...), never below 1.0, before
+	// MaxSurgeMultiplier applies. 0 leaves it unrounded.
+	SurgeRoundingStep float64
+
+	// BaseFareTiers, when set, replaces BaseFareCents with a base fare
+	// chosen by trip distance (see baseFareFor). Tiers must be sorted by
+	// UpToKm; the last tier's UpToKm may be 0 to cover every longer ride.
+	// Empty keeps the single flat base fare.
+	BaseFareTiers []BaseFareTier
+}
+
+// BaseFareTier is one distance band of a tiered base fare: rides up to
+// UpToKm (inclusive) pay BaseFareCents. UpToKm ≤ 0 means unbounded.
+type BaseFareTier struct {
+	UpToKm        float64
+	BaseFareCents int
 }
 
 // DefaultFareConfig returns sensible defaults for Indian airport rides.
 func DefaultFareConfig() FareConfig {
 	return FareConfig{
-		BaseFareCents:   5000,  // ₹50 base fare
-		PerKmRateCents:  1200,  // ₹12 per km
-		PerMinRateCents: 200,   // ₹2 per minute
-		MinFareCents:    7500,  // ₹75 minimum
-		SurgeRadiusM:    5000,  // 5km surge zone
+		BaseFareCents:   5000, // ₹50 base fare
+		PerKmRateCents:  1200, // ₹12 per km
+		PerMinRateCents: 200,  // ₹2 per minute
+		MinFareCents:    7500, // ₹75 minimum
+		SurgeRadiusM:    5000, // 5km surge zone
+
+		PoolDiscountPercent: 10, // 10% off for joining a pooled trip
+
+		MinBillableKm:      0.5, // 500m
+		MinBillableMinutes: 2,   // pickup + drop-off take time at any distance
+
+		SurgeRoundingStep: 0.1,
 	}
 }
 
@@ -62,12 +108,38 @@ type FareEstimate struct {
 	TimeFareCents     int     `json:"time_fare_cents"`
 	SubtotalCents     int     `json:"subtotal_cents"`
 	SurgeMultiplier   float64 `json:"surge_multiplier"`
+	SurgeCapped       bool    `json:"surge_capped,omitempty"` // MaxSurgeMultiplier lowered the surge.
 	TotalFareCents    int     `json:"total_fare_cents"`
+	NoSurgeTotalCents int     `json:"no_surge_total_cents"` // TotalFareCents at 1.0x surge.
+	SurgeDeltaCents   int     `json:"surge_delta_cents"`    // TotalFareCents − NoSurgeTotalCents: what surge adds.
 	DistanceKm        float64 `json:"distance_km"`
 	EstimatedMinutes  float64 `json:"estimated_minutes"`
 	Demand            int     `json:"demand"`
 	Supply            int     `json:"supply"`
 	DemandSupplyRatio float64 `json:"demand_supply_ratio"`
+
+	// Set only on booking quotes for pooled riders (see QuoteBooking).
+	PoolDiscountCents int `json:"pool_discount_cents,omitempty"`
+
+	// Set only on multi-stop route estimates (see EstimateRouteFare).
+	Stops int `json:"stops,omitempty"`
+
+	// Set only when the caller passed FareOptions.MaxFareCents and the
+	// computed total exceeded it: TotalFareCents is then the cap and
+	// WouldBeCents the uncapped price.
+	Capped       bool `json:"capped,omitempty"`
+	WouldBeCents int  `json:"would_be_cents,omitempty"`
+}
+
+// FareOptions tunes a single fare calculation.
+type FareOptions struct {
+	// MaxFareCents is the rider's price ceiling. When the computed total is
+	// above it, the estimate is capped (see applyFareCap). 0 means no cap.
+	MaxFareCents int
+
+	// Direction scopes surge demand to rides going the same way ("" counts
+	// both directions).
+	Direction model.TripDirection
 }
 
 // ─── PricingService ─────────────────────────────────────────
@@ -75,17 +147,23 @@ type FareEstimate struct {
 // PricingService calculates dynamic fares with surge pricing.
 //
 // Formula:
-//   Price = (BaseFare + (Distance × PerKmRate) + (Time × PerMinRate)) × SurgeMultiplier
+//
+//	Price = (BaseFare + (Distance × PerKmRate) + (Time × PerMinRate)) × SurgeMultiplier
 //
 // Surge logic:
-//   1. Query Redis (cache) or PostGIS (fallback) for demand/supply in the area.
-//   2. Compute ratio R = Demand / Supply.
-//   3. Apply tiered multiplier based on R.
+//  1. Query Redis (cache) or PostGIS (fallback) for demand/supply in the area.
+//  2. Compute ratio R = Demand / Supply.
+//  3. Apply tiered multiplier based on R.
 type PricingService struct {
-	repo   *repository.PricingRepository
+	repo   demandSupplySource
 	config FareConfig
 }
 
+// demandSupplySource is the part of PricingRepository used for surge lookups.
+type demandSupplySource interface {
+	GetDemandSupply(ctx context.Context, location model.Location, radiusMeters int, direction model.TripDirection) (*repository.DemandSupply, error)
+}
+
 // NewPricingService creates a pricing service with the given config.
 func NewPricingService(repo *repository.PricingRepository, config FareConfig) *PricingService {
 	return &PricingService{repo: repo, config: config}
@@ -95,44 +173,125 @@ func NewPricingService(repo *repository.PricingRepository, config FareConfig) *P
 //
 // Steps:
 //  1. Calculate distance (Haversine) and estimated time.
-//  2. Query demand/supply ratio for the origin area.
+//  2. Query demand/supply ratio for the origin area (demand in
+//     opts.Direction only, when set).
 //  3. Determine surge multiplier.
 //  4. Apply the pricing formula.
+//  5. Apply the rider's max fare cap, if any.
 //
 // Complexity: O(1) math + O(1) Redis lookup (or O(log N) PostGIS on cache miss).
 func (s *PricingService) EstimateFare(
 	ctx context.Context,
 	origin model.Location,
 	destination model.Location,
+	opts FareOptions,
 ) (*FareEstimate, error) {
 
 	// ── Step 1: Distance & Time ─────────────────────────
 	distanceKm := geo.HaversineKm(origin, destination)
 	estimatedMinutes := geo.EstimateTimeMinutes(origin, destination)
 
-	log.Printf("[pricing] Route: %.2f km, ~%.1f min", distanceKm, estimatedMinutes)
+	logctx.Debugf(ctx, "[pricing] Route: %.2f km, ~%.1f min", distanceKm, estimatedMinutes)
+
+	estimate := s.estimateForRoute(ctx, origin, opts.Direction, distanceKm, estimatedMinutes)
+	applyFareCap(estimate, opts.MaxFareCents)
+	return estimate, nil
+}
+
+// EstimateRouteFare prices a multi-stop route (e.g. a proposed pooled
+// itinerary) as a single ride: total Haversine distance and time across
+// the ordered stops, with surge taken from the first stop's area.
+//
+// Complexity: O(S) for S stops + one demand/supply lookup.
+func (s *PricingService) EstimateRouteFare(
+	ctx context.Context,
+	stops []model.Location,
+) (*FareEstimate, error) {
+	if len(stops) < 2 {
+		return nil, fmt.Errorf("pricing: route needs at least 2 stops, got %d", len(stops))
+	}
+
+	distanceKm := geo.RouteDistanceKm(stops)
+	estimatedMinutes := geo.RouteTimeMinutes(stops)
+
+	logctx.Debugf(ctx, "[pricing] Route (%d stops): %.2f km, ~%.1f min", len(stops), distanceKm, estimatedMinutes)
+
+	estimate := s.estimateForRoute(ctx, stops[0], "", distanceKm, estimatedMinutes)
+	estimate.Stops = len(stops)
+	return estimate, nil
+}
+
+// estimateForRoute looks up surge around surgeOrigin, for demand in
+// direction ("" = both), and prices an already-measured route.
+func (s *PricingService) estimateForRoute(
+	ctx context.Context,
+	surgeOrigin model.Location,
+	direction model.TripDirection,
+	distanceKm float64,
+	estimatedMinutes float64,
+) *FareEstimate {
 
 	// ── Step 2: Demand/Supply for surge ─────────────────
-	ds, err := s.repo.GetDemandSupply(ctx, origin, s.config.SurgeRadiusM)
+	ds, err := s.repo.GetDemandSupply(ctx, surgeOrigin, s.config.SurgeRadiusM, direction)
 	if err != nil {
 		// On error, default to no surge (graceful degradation).
-		log.Printf("[pricing] WARNING: demand/supply query failed: %v — defaulting to no surge", err)
+		logctx.Warnf(ctx, "[pricing] WARNING: demand/supply query failed: %v — defaulting to no surge", err)
 		ds = &repository.DemandSupply{Demand: 0, Supply: 1, Ratio: 0}
 	}
 
-	log.Printf("[pricing] Demand=%d, Supply=%d, Ratio=%.2f", ds.Demand, ds.Supply, ds.Ratio)
+	logctx.Debugf(ctx, "[pricing] Demand=%d, Supply=%d, Ratio=%.2f", ds.Demand, ds.Supply, ds.Ratio)
+
+	// ── Steps 3 + 4: Surge multiplier & fare formula ────
+	estimate := s.buildEstimate(distanceKm, estimatedMinutes, ds)
 
-	// ── Step 3: Surge multiplier ────────────────────────
-	surge := calculateSurgeMultiplier(ds.Ratio)
+	logctx.Debugf(ctx, "[pricing] Fare: ₹%.2f (base=₹%.2f + dist=₹%.2f + time=₹%.2f) × %.1fx surge",
+		float64(estimate.TotalFareCents)/100, float64(estimate.BaseFareCents)/100,
+		float64(estimate.DistanceFareCents)/100, float64(estimate.TimeFareCents)/100,
+		estimate.SurgeMultiplier)
+
+	return estimate
+}
+
+// QuoteBooking prices a ride at booking time. It is EstimateFare plus the
+// pool discount: riders joining an existing trip (pooled = true) get
+// PoolDiscountPercent off, while riders seeding a new trip pay the full fare.
+// The max fare cap is checked against the discounted total.
+func (s *PricingService) QuoteBooking(
+	ctx context.Context,
+	origin model.Location,
+	destination model.Location,
+	pooled bool,
+	opts FareOptions,
+) (_ *FareEstimate, err error) {
+	ctx, span := tracing.Start(ctx, "PricingService.QuoteBooking", attribute.Bool("ride.pooled", pooled))
+	defer func() { tracing.End(span, err) }()
+
+	estimate, err := s.EstimateFare(ctx, origin, destination, FareOptions{Direction: opts.Direction})
+	if err != nil {
+		return nil, err
+	}
+	if pooled {
+		s.applyPoolDiscount(estimate)
+	}
+	applyFareCap(estimate, opts.MaxFareCents)
+	return estimate, nil
+}
 
-	log.Printf("[pricing] Surge multiplier: %.1fx", surge)
+// buildEstimate applies the surge multiplier and the fare formula to an
+// already-measured route. Pure function of its inputs and the config.
+// Distance and time are floored at MinBillableKm/MinBillableMinutes here
+// rather than in geo, whose estimates also drive detour scoring.
+//
+//	Price = (BaseFare + Distance*Rate + Time*Rate) × Surge
+func (s *PricingService) buildEstimate(distanceKm, estimatedMinutes float64, ds *repository.DemandSupply) *FareEstimate {
+	surge, surgeCapped := s.config.surgeMultiplier(ds.Ratio)
 
-	// ── Step 4: Fare formula ────────────────────────────
-	//   Price = (BaseFare + Distance*Rate + Time*Rate) × Surge
+	billableKm := math.Max(distanceKm, s.config.MinBillableKm)
+	billableMinutes := math.Max(estimatedMinutes, s.config.MinBillableMinutes)
 
-	baseFare := s.config.BaseFareCents
-	distanceFare := int(math.Round(distanceKm * float64(s.config.PerKmRateCents)))
-	timeFare := int(math.Round(estimatedMinutes * float64(s.config.PerMinRateCents)))
+	baseFare := s.config.baseFareFor(distanceKm)
+	distanceFare := int(math.Round(billableKm * float64(s.config.PerKmRateCents)))
+	timeFare := int(math.Round(billableMinutes * float64(s.config.PerMinRateCents)))
 
 	subtotal := baseFare + distanceFare + timeFare
 	total := int(math.Round(float64(subtotal) * surge))
@@ -141,30 +300,194 @@ func (s *PricingService) EstimateFare(
 	if total < s.config.MinFareCents {
 		total = s.config.MinFareCents
 	}
+	noSurge := max(subtotal, s.config.MinFareCents)
 
-	estimate := &FareEstimate{
+	return &FareEstimate{
 		BaseFareCents:     baseFare,
 		DistanceFareCents: distanceFare,
 		TimeFareCents:     timeFare,
 		SubtotalCents:     subtotal,
 		SurgeMultiplier:   surge,
+		SurgeCapped:       surgeCapped,
 		TotalFareCents:    total,
+		NoSurgeTotalCents: noSurge,
+		SurgeDeltaCents:   total - noSurge,
 		DistanceKm:        math.Round(distanceKm*100) / 100,
 		EstimatedMinutes:  math.Round(estimatedMinutes*10) / 10,
 		Demand:            ds.Demand,
 		Supply:            ds.Supply,
 		DemandSupplyRatio: math.Round(ds.Ratio*100) / 100,
 	}
+}
 
-	log.Printf("[pricing] Fare: ₹%.2f (base=₹%.2f + dist=₹%.2f + time=₹%.2f) × %.1fx surge",
-		float64(total)/100, float64(baseFare)/100,
-		float64(distanceFare)/100, float64(timeFare)/100, surge)
+// baseFareFor returns the base fare for a ride of distanceKm: the first
+// tier that covers the distance, or BaseFareCents when no tiers are
+// configured. A distance past the last bounded tier uses that last tier.
+func (c FareConfig) baseFareFor(distanceKm float64) int {
+	if len(c.BaseFareTiers) == 0 {
+		return c.BaseFareCents
+	}
+	for _, tier := range c.BaseFareTiers {
+		if tier.UpToKm <= 0 || distanceKm <= tier.UpToKm {
+			return tier.BaseFareCents
+		}
+	}
+	return c.BaseFareTiers[len(c.BaseFareTiers)-1].BaseFareCents
+}
 
-	return estimate, nil
+// applyPoolDiscount takes PoolDiscountPercent off the estimate's total,
+// and off the no-surge total it is compared with. The MinFareCents floor
+// still applies after the discount.
+func (s *PricingService) applyPoolDiscount(estimate *FareEstimate) {
+	if s.config.PoolDiscountPercent <= 0 {
+		return
+	}
+	discounted := s.poolDiscounted(estimate.TotalFareCents)
+	if discounted >= estimate.TotalFareCents {
+		return
+	}
+	estimate.PoolDiscountCents = estimate.TotalFareCents - discounted
+	estimate.TotalFareCents = discounted
+	estimate.NoSurgeTotalCents = min(estimate.NoSurgeTotalCents, s.poolDiscounted(estimate.NoSurgeTotalCents))
+	estimate.SurgeDeltaCents = estimate.TotalFareCents - estimate.NoSurgeTotalCents
+}
+
+// poolDiscounted returns cents with PoolDiscountPercent off, floored at
+// MinFareCents.
+func (s *PricingService) poolDiscounted(cents int) int {
+	discounted := int(math.Round(float64(cents) * float64(100-s.config.PoolDiscountPercent) / 100))
+	return max(discounted, s.config.MinFareCents)
+}
+
+// applyFareCap limits the estimate's total to maxFareCents, recording the
+// uncapped price in WouldBeCents. The no-surge total is capped alike, so
+// the surge delta is what surge adds to the price actually quoted. A
+// non-positive cap is ignored.
+func applyFareCap(estimate *FareEstimate, maxFareCents int) {
+	if maxFareCents <= 0 || estimate.TotalFareCents <= maxFareCents {
+		return
+	}
+	estimate.Capped = true
+	estimate.WouldBeCents = estimate.TotalFareCents
+	estimate.TotalFareCents = maxFareCents
+	estimate.NoSurgeTotalCents = min(estimate.NoSurgeTotalCents, maxFareCents)
+	estimate.SurgeDeltaCents = estimate.TotalFareCents - estimate.NoSurgeTotalCents
 }
 
 // ─── Surge Calculation ──────────────────────────────────────
 
+// Bounds for the radius accepted by CurrentSurge. Tiny areas give noisy
+// ratios; huge ones make the PostGIS count expensive and meaningless.
+const (
+	MinSurgeQueryRadiusM = 500
+	MaxSurgeQueryRadiusM = 20000
+)
+
+// SurgeInfo is the current surge around a point (see CurrentSurge).
+type SurgeInfo struct {
+	Lat               float64             `json:"lat"`
+	Lon               float64             `json:"lon"`
+	RadiusM           int                 `json:"radius_m"`
+	Direction         model.TripDirection `json:"direction,omitempty"` // Set when demand was counted one way only.
+	Demand            int                 `json:"demand"`
+	Supply            int                 `json:"supply"`
+	DemandSupplyRatio float64             `json:"demand_supply_ratio"`
+	SurgeMultiplier   float64             `json:"surge_multiplier"`
+	SurgeCapped       bool                `json:"surge_capped,omitempty"`
+}
+
+// CurrentSurge reports demand, supply and the multiplier EstimateFare would
+// apply at location for a ride in direction ("" = demand in both
+// directions). radiusM ≤ 0 uses the configured SurgeRadiusM; other
+// values are clamped to [MinSurgeQueryRadiusM, MaxSurgeQueryRadiusM].
+func (s *PricingService) CurrentSurge(ctx context.Context, location model.Location, radiusM int, direction model.TripDirection) (*SurgeInfo, error) {
+	radiusM = clampSurgeRadius(radiusM, s.config.SurgeRadiusM)
+
+	ds, err := s.repo.GetDemandSupply(ctx, location, radiusM, direction)
+	if err != nil {
+		return nil, fmt.Errorf("pricing: demand/supply: %w", err)
+	}
+
+	surge, capped := s.config.surgeMultiplier(ds.Ratio)
+	return &SurgeInfo{
+		Lat:               location.Lat,
+		Lon:               location.Lon,
+		RadiusM:           radiusM,
+		Direction:         direction,
+		Demand:            ds.Demand,
+		Supply:            ds.Supply,
+		DemandSupplyRatio: math.Round(ds.Ratio*100) / 100,
+		SurgeMultiplier:   surge,
+		SurgeCapped:       capped,
+	}, nil
+}
+
+// ErrTripHasNoPassengers is returned by TripSurge for a trip with no
+// matched or confirmed riders, which has no origin area to price.
+var ErrTripHasNoPassengers = errors.New("trip has no matched passengers")
+
+// TripSurgeInfo is the current surge around a trip's origin area (see
+// TripSurge).
+type TripSurgeInfo struct {
+	TripID     int64 `json:"trip_id"`
+	Passengers int   `json:"passengers"`
+	SurgeInfo
+}
+
+// TripSurge re-estimates surge for an existing trip, for re-pricing while
+// it is under way. It is CurrentSurge at the centroid of the riders'
+// origins, over the configured SurgeRadiusM. Returns ErrTripHasNoPassengers
+// when route has no riders.
+func (s *PricingService) TripSurge(ctx context.Context, route *repository.TripRoute) (*TripSurgeInfo, error) {
+	if len(route.Riders) == 0 {
+		return nil, fmt.Errorf("trip %d surge: %w", route.TripID, ErrTripHasNoPassengers)
+	}
+
+	var centroid model.Location
+	for _, rr := range route.Riders {
+		centroid.Lat += rr.Origin.Lat
+		centroid.Lon += rr.Origin.Lon
+	}
+	centroid.Lat /= float64(len(route.Riders))
+	centroid.Lon /= float64(len(route.Riders))
+
+	surge, err := s.CurrentSurge(ctx, centroid, 0, "")
+	if err != nil {
+		return nil, fmt.Errorf("trip %d surge: %w", route.TripID, err)
+	}
+	return &TripSurgeInfo{TripID: route.TripID, Passengers: len(route.Riders), SurgeInfo: *surge}, nil
+}
+
+// clampSurgeRadius applies CurrentSurge's radius rules.
+func clampSurgeRadius(radiusM, defaultM int) int {
+	if radiusM <= 0 {
+		radiusM = defaultM
+	}
+	return min(max(radiusM, MinSurgeQueryRadiusM), MaxSurgeQueryRadiusM)
+}
+
+// surgeMultiplier is calculateSurgeMultiplier rounded to
+// SurgeRoundingStep and limited to MaxSurgeMultiplier; capped reports
+// whether the cap lowered it. The cap is applied last, so it holds even
+// when it is not a multiple of the step.
+func (c FareConfig) surgeMultiplier(ratio float64) (surge float64, capped bool) {
+	surge = roundSurge(calculateSurgeMultiplier(ratio), c.SurgeRoundingStep)
+	if c.MaxSurgeMultiplier > 0 && surge > c.MaxSurgeMultiplier {
+		return c.MaxSurgeMultiplier, true
+	}
+	return surge, false
+}
+
+// roundSurge rounds surge to the nearest multiple of step, never below
+// SurgeMultiplierNone. step ≤ 0 leaves surge as is. The result is trimmed
+// to 1e-9 so steps like 0.1 give 1.2 rather than 1.2000000000000002.
+func roundSurge(surge, step float64) float64 {
+	if step > 0 {
+		surge = math.Round(math.Round(surge/step)*step*1e9) / 1e9
+	}
+	return math.Max(surge, SurgeMultiplierNone)
+}
+
 // calculateSurgeMultiplier returns the surge multiplier for a given
 // demand/supply ratio.
 //
@@ -0,0 +1,615 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/geo"
+)
+
+func noSurge() *repository.DemandSupply {
+	return &repository.DemandSupply{Demand: 0, Supply: 1, Ratio: 0}
+}
+
+func TestPoolDiscount_PooledVsSolo(t *testing.T) {
+	svc := NewPricingService(nil, DefaultFareConfig())
+
+	solo := svc.buildEstimate(20, 40, noSurge())
+	pooled := svc.buildEstimate(20, 40, noSurge())
+	svc.applyPoolDiscount(pooled)
+
+	// 5000 + 20*1200 + 40*200 = 37000 cents; 10% off = 33300.
+	if solo.TotalFareCents != 37000 {
+		t.Fatalf("solo total = %d, want 37000", solo.TotalFareCents)
+	}
+	if solo.PoolDiscountCents != 0 {
+		t.Errorf("solo discount = %d, want 0", solo.PoolDiscountCents)
+	}
+	if pooled.TotalFareCents != 33300 {
+		t.Errorf("pooled total = %d, want 33300", pooled.TotalFareCents)
+	}
+	if pooled.PoolDiscountCents != 3700 {
+		t.Errorf("pooled discount = %d, want 3700", pooled.PoolDiscountCents)
+	}
+}
+
+func TestPoolDiscount_MinFareFloorStillApplies(t *testing.T) {
+	cfg := DefaultFareConfig()
+	cfg.PoolDiscountPercent = 50
+	svc := NewPricingService(nil, cfg)
+
+	// 5000 + 1*1200 + 2*200 = 6600 → floored to 7500 before the discount.
+	estimate := svc.buildEstimate(1, 2, noSurge())
+	svc.applyPoolDiscount(estimate)
+
+	if estimate.TotalFareCents != cfg.MinFareCents {
+		t.Errorf("total = %d, want floor %d", estimate.TotalFareCents, cfg.MinFareCents)
+	}
+	if estimate.PoolDiscountCents != 0 {
+		t.Errorf("discount = %d, want 0 (already at floor)", estimate.PoolDiscountCents)
+	}
+}
+
+func TestPoolDiscount_PartiallyAbsorbedByFloor(t *testing.T) {
+	cfg := DefaultFareConfig()
+	cfg.PoolDiscountPercent = 50
+	svc := NewPricingService(nil, cfg)
+
+	// 5000 + 5*1200 + 10*200 = 13000; 50% off = 6500 → floored to 7500.
+	estimate := svc.buildEstimate(5, 10, noSurge())
+	svc.applyPoolDiscount(estimate)
+
+	if estimate.TotalFareCents != 7500 {
+		t.Errorf("total = %d, want 7500", estimate.TotalFareCents)
+	}
+	if estimate.PoolDiscountCents != 5500 {
+		t.Errorf("discount = %d, want 5500", estimate.PoolDiscountCents)
+	}
+}
+
+// fakeDemandSupply answers with ds, or with byDirection[direction] when
+// that is set, and records what was asked.
+type fakeDemandSupply struct {
+	ds          repository.DemandSupply
+	byDirection map[model.TripDirection]repository.DemandSupply
+	queried     []model.Location
+	directions  []model.TripDirection
+}
+
+func (f *fakeDemandSupply) GetDemandSupply(_ context.Context, loc model.Location, _ int, direction model.TripDirection) (*repository.DemandSupply, error) {
+	f.queried = append(f.queried, loc)
+	f.directions = append(f.directions, direction)
+	ds := f.ds
+	if scoped, ok := f.byDirection[direction]; ok {
+		ds = scoped
+	}
+	return &ds, nil
+}
+
+// directionalDemand has heavy to-airport demand and little from-airport
+// demand in the same area; combined, the area looks mildly surged.
+func directionalDemand() *fakeDemandSupply {
+	return &fakeDemandSupply{
+		ds: repository.DemandSupply{Demand: 7, Supply: 4, Ratio: 1.75},
+		byDirection: map[model.TripDirection]repository.DemandSupply{
+			model.DirectionToAirport:   {Demand: 6, Supply: 2, Ratio: 3},
+			model.DirectionFromAirport: {Demand: 1, Supply: 4, Ratio: 0.25},
+		},
+	}
+}
+
+func TestEstimateFare_DirectionScopedDemandDiffersFromCombined(t *testing.T) {
+	src := directionalDemand()
+	svc := &PricingService{repo: src, config: DefaultFareConfig()}
+	origin := model.Location{Lat: 28.7041, Lon: 77.1025}
+	dest := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	surge := map[model.TripDirection]float64{}
+	for _, dir := range []model.TripDirection{"", model.DirectionToAirport, model.DirectionFromAirport} {
+		got, err := svc.EstimateFare(context.Background(), origin, dest, FareOptions{Direction: dir})
+		if err != nil {
+			t.Fatalf("%q: %v", dir, err)
+		}
+		surge[dir] = got.SurgeMultiplier
+	}
+	if fmt.Sprint(src.directions) != "[ to_airport from_airport]" {
+		t.Errorf("directions queried = %q, want combined, to_airport, from_airport", src.directions)
+	}
+	if surge[model.DirectionToAirport] <= surge[""] || surge[model.DirectionFromAirport] >= surge[""] {
+		t.Errorf("surge = %v, want to_airport above combined above from_airport", surge)
+	}
+	if surge[model.DirectionFromAirport] != SurgeMultiplierNone {
+		t.Errorf("from_airport surge = %.1f, want none", surge[model.DirectionFromAirport])
+	}
+}
+
+func TestQuoteBooking_UsesRideDirection(t *testing.T) {
+	src := directionalDemand()
+	svc := &PricingService{repo: src, config: DefaultFareConfig()}
+	origin := model.Location{Lat: 28.7041, Lon: 77.1025}
+	dest := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	quote, err := svc.QuoteBooking(context.Background(), origin, dest, false, FareOptions{Direction: model.DirectionToAirport})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(src.directions) != 1 || src.directions[0] != model.DirectionToAirport {
+		t.Errorf("directions queried = %q, want [to_airport]", src.directions)
+	}
+	if quote.SurgeMultiplier != SurgeMultiplierHigh {
+		t.Errorf("surge = %.1f, want %.1f from to_airport demand", quote.SurgeMultiplier, SurgeMultiplierHigh)
+	}
+}
+
+func TestEstimateRouteFare_ThreeStops(t *testing.T) {
+	src := &fakeDemandSupply{ds: *noSurge()}
+	svc := &PricingService{repo: src, config: DefaultFareConfig()}
+
+	stops := []model.Location{
+		{Lat: 28.7041, Lon: 77.1025},
+		{Lat: 28.6500, Lon: 77.1000},
+		{Lat: 28.5562, Lon: 77.0889},
+	}
+	got, err := svc.EstimateRouteFare(context.Background(), stops)
+	if err != nil {
+		t.Fatalf("EstimateRouteFare: %v", err)
+	}
+
+	wantKm := geo.RouteDistanceKm(stops)
+	if math.Abs(got.DistanceKm-wantKm) > 0.01 {
+		t.Errorf("DistanceKm = %.2f, want %.2f", got.DistanceKm, wantKm)
+	}
+	direct := geo.HaversineKm(stops[0], stops[2])
+	if got.DistanceKm <= direct {
+		t.Errorf("DistanceKm = %.2f, want more than direct %.2f (via middle stop)", got.DistanceKm, direct)
+	}
+	if got.Stops != 3 {
+		t.Errorf("Stops = %d, want 3", got.Stops)
+	}
+	want := svc.buildEstimate(wantKm, geo.RouteTimeMinutes(stops), noSurge()).TotalFareCents
+	if got.TotalFareCents != want {
+		t.Errorf("TotalFareCents = %d, want %d", got.TotalFareCents, want)
+	}
+	if len(src.queried) != 1 || src.queried[0] != stops[0] {
+		t.Errorf("surge queried at %v, want first stop only", src.queried)
+	}
+}
+
+func TestEstimateRouteFare_RejectsSingleStop(t *testing.T) {
+	svc := &PricingService{repo: &fakeDemandSupply{}, config: DefaultFareConfig()}
+	if _, err := svc.EstimateRouteFare(context.Background(), []model.Location{{Lat: 1, Lon: 1}}); err == nil {
+		t.Error("EstimateRouteFare(1 stop): want error")
+	}
+}
+
+func TestEstimateFare_UnderCapIsUnchanged(t *testing.T) {
+	svc := &PricingService{repo: &fakeDemandSupply{ds: *noSurge()}, config: DefaultFareConfig()}
+	origin := model.Location{Lat: 28.7041, Lon: 77.1025}
+	dest := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	raw, _ := svc.EstimateFare(context.Background(), origin, dest, FareOptions{})
+	got, err := svc.EstimateFare(context.Background(), origin, dest, FareOptions{MaxFareCents: raw.TotalFareCents})
+	if err != nil {
+		t.Fatalf("EstimateFare: %v", err)
+	}
+	if got.Capped || got.WouldBeCents != 0 {
+		t.Errorf("capped=%v would_be=%d, want uncapped at exactly the cap", got.Capped, got.WouldBeCents)
+	}
+	if got.TotalFareCents != raw.TotalFareCents {
+		t.Errorf("total = %d, want %d", got.TotalFareCents, raw.TotalFareCents)
+	}
+}
+
+func TestEstimateFare_OverCapIsCapped(t *testing.T) {
+	// Ratio 3 → 1.5x surge pushes the total well above the cap.
+	src := &fakeDemandSupply{ds: repository.DemandSupply{Demand: 6, Supply: 2, Ratio: 3}}
+	svc := &PricingService{repo: src, config: DefaultFareConfig()}
+	origin := model.Location{Lat: 28.7041, Lon: 77.1025}
+	dest := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	raw, _ := svc.EstimateFare(context.Background(), origin, dest, FareOptions{})
+	got, err := svc.EstimateFare(context.Background(), origin, dest, FareOptions{MaxFareCents: 20000})
+	if err != nil {
+		t.Fatalf("EstimateFare: %v", err)
+	}
+	if !got.Capped {
+		t.Fatalf("capped = false, want true (raw total %d)", raw.TotalFareCents)
+	}
+	if got.TotalFareCents != 20000 {
+		t.Errorf("total = %d, want cap 20000", got.TotalFareCents)
+	}
+	if got.WouldBeCents != raw.TotalFareCents {
+		t.Errorf("would_be = %d, want %d", got.WouldBeCents, raw.TotalFareCents)
+	}
+}
+
+func TestQuoteBooking_CapCheckedAfterPoolDiscount(t *testing.T) {
+	svc := &PricingService{repo: &fakeDemandSupply{ds: *noSurge()}, config: DefaultFareConfig()}
+	origin := model.Location{Lat: 28.7041, Lon: 77.1025}
+	dest := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	solo, _ := svc.QuoteBooking(context.Background(), origin, dest, false, FareOptions{})
+	pooled, _ := svc.QuoteBooking(context.Background(), origin, dest, true, FareOptions{})
+	if pooled.TotalFareCents >= solo.TotalFareCents {
+		t.Fatalf("pooled %d not below solo %d", pooled.TotalFareCents, solo.TotalFareCents)
+	}
+
+	// A cap between the two fares only bites the solo rider.
+	opts := FareOptions{MaxFareCents: pooled.TotalFareCents}
+	if q, _ := svc.QuoteBooking(context.Background(), origin, dest, true, opts); q.Capped {
+		t.Error("pooled quote capped, want within cap after discount")
+	}
+	if q, _ := svc.QuoteBooking(context.Background(), origin, dest, false, opts); !q.Capped {
+		t.Error("solo quote not capped, want capped")
+	}
+}
+
+func TestCurrentSurge_NoSurge(t *testing.T) {
+	svc := &PricingService{repo: &fakeDemandSupply{ds: repository.DemandSupply{Demand: 3, Supply: 4, Ratio: 0.75}}, config: DefaultFareConfig()}
+
+	got, err := svc.CurrentSurge(context.Background(), model.Location{Lat: 28.70, Lon: 77.10}, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SurgeMultiplier != SurgeMultiplierNone {
+		t.Errorf("multiplier = %.1f, want %.1f", got.SurgeMultiplier, SurgeMultiplierNone)
+	}
+	if got.RadiusM != DefaultFareConfig().SurgeRadiusM {
+		t.Errorf("radius = %d, want configured default %d", got.RadiusM, DefaultFareConfig().SurgeRadiusM)
+	}
+	if got.Demand != 3 || got.Supply != 4 || got.DemandSupplyRatio != 0.75 {
+		t.Errorf("got %+v, want demand 3, supply 4, ratio 0.75", got)
+	}
+}
+
+func TestCurrentSurge_HighSurge(t *testing.T) {
+	svc := &PricingService{repo: &fakeDemandSupply{ds: repository.DemandSupply{Demand: 9, Supply: 3, Ratio: 3}}, config: DefaultFareConfig()}
+
+	got, err := svc.CurrentSurge(context.Background(), model.Location{Lat: 28.70, Lon: 77.10}, 3000, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SurgeMultiplier != SurgeMultiplierHigh {
+		t.Errorf("multiplier = %.1f, want %.1f", got.SurgeMultiplier, SurgeMultiplierHigh)
+	}
+	if got.RadiusM != 3000 {
+		t.Errorf("radius = %d, want 3000", got.RadiusM)
+	}
+}
+
+func TestClampSurgeRadius(t *testing.T) {
+	for _, tt := range []struct{ in, want int }{
+		{0, 5000},
+		{-1, 5000},
+		{100, MinSurgeQueryRadiusM},
+		{3000, 3000},
+		{1_000_000, MaxSurgeQueryRadiusM},
+	} {
+		if got := clampSurgeRadius(tt.in, 5000); got != tt.want {
+			t.Errorf("clampSurgeRadius(%d) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func tripRouteFrom(origins ...model.Location) *repository.TripRoute {
+	route := &repository.TripRoute{TripID: 7, Status: model.TripInProgress}
+	for i, o := range origins {
+		route.Riders = append(route.Riders, model.RideRequest{
+			ID: int64(i + 1), Origin: o, Destination: model.Location{Lat: 28.5562, Lon: 77.0889},
+		})
+	}
+	return route
+}
+
+func TestTripSurge_DemandLevels(t *testing.T) {
+	route := tripRouteFrom(
+		model.Location{Lat: 28.70, Lon: 77.10},
+		model.Location{Lat: 28.72, Lon: 77.14},
+	)
+	centroid := model.Location{Lat: 28.71, Lon: 77.12}
+
+	for _, tt := range []struct {
+		name string
+		ds   repository.DemandSupply
+		want float64
+	}{
+		{"low demand", repository.DemandSupply{Demand: 2, Supply: 5, Ratio: 0.4}, SurgeMultiplierNone},
+		{"high demand", repository.DemandSupply{Demand: 12, Supply: 4, Ratio: 3}, SurgeMultiplierHigh},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			src := &fakeDemandSupply{ds: tt.ds}
+			svc := &PricingService{repo: src, config: DefaultFareConfig()}
+
+			got, err := svc.TripSurge(context.Background(), route)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.SurgeMultiplier != tt.want {
+				t.Errorf("multiplier = %.1f, want %.1f", got.SurgeMultiplier, tt.want)
+			}
+			if got.TripID != 7 || got.Passengers != 2 || got.Demand != tt.ds.Demand || got.Supply != tt.ds.Supply {
+				t.Errorf("got %+v, want trip 7 with 2 passengers and demand/supply %d/%d", got, tt.ds.Demand, tt.ds.Supply)
+			}
+			if len(src.queried) != 1 ||
+				math.Abs(src.queried[0].Lat-centroid.Lat) > 1e-9 || math.Abs(src.queried[0].Lon-centroid.Lon) > 1e-9 {
+				t.Errorf("surge queried at %v, want origin centroid %v", src.queried, centroid)
+			}
+		})
+	}
+}
+
+func TestTripSurge_NoPassengers(t *testing.T) {
+	src := &fakeDemandSupply{ds: *noSurge()}
+	svc := &PricingService{repo: src, config: DefaultFareConfig()}
+
+	_, err := svc.TripSurge(context.Background(), tripRouteFrom())
+	if !errors.Is(err, ErrTripHasNoPassengers) {
+		t.Fatalf("err = %v, want ErrTripHasNoPassengers", err)
+	}
+	if len(src.queried) != 0 {
+		t.Errorf("surge queried at %v, want no lookup for an empty trip", src.queried)
+	}
+}
+
+func tieredFareConfig() FareConfig {
+	cfg := DefaultFareConfig()
+	cfg.BaseFareTiers = []BaseFareTier{
+		{UpToKm: 5, BaseFareCents: 3000},
+		{UpToKm: 15, BaseFareCents: 5000},
+		{BaseFareCents: 8000},
+	}
+	return cfg
+}
+
+func TestBaseFareTiers_ShortAndLongRides(t *testing.T) {
+	svc := NewPricingService(nil, tieredFareConfig())
+
+	// 3km ride → first tier: 3000 + 3*1200 + 6*200 = 7800.
+	short := svc.buildEstimate(3, 6, noSurge())
+	if short.BaseFareCents != 3000 {
+		t.Errorf("short base = %d, want 3000", short.BaseFareCents)
+	}
+	if short.TotalFareCents != 7800 {
+		t.Errorf("short total = %d, want 7800", short.TotalFareCents)
+	}
+
+	// 20km ride → open-ended tier: 8000 + 20*1200 + 40*200 = 40000.
+	long := svc.buildEstimate(20, 40, noSurge())
+	if long.BaseFareCents != 8000 {
+		t.Errorf("long base = %d, want 8000", long.BaseFareCents)
+	}
+	if long.TotalFareCents != 40000 {
+		t.Errorf("long total = %d, want 40000", long.TotalFareCents)
+	}
+}
+
+func TestBaseFareFor(t *testing.T) {
+	tiered := tieredFareConfig()
+	bounded := DefaultFareConfig()
+	bounded.BaseFareTiers = []BaseFareTier{{UpToKm: 5, BaseFareCents: 3000}, {UpToKm: 15, BaseFareCents: 5000}}
+
+	cases := []struct {
+		name string
+		cfg  FareConfig
+		km   float64
+		want int
+	}{
+		{"flat default", DefaultFareConfig(), 40, 5000},
+		{"tier boundary is inclusive", tiered, 5, 3000},
+		{"middle tier", tiered, 5.01, 5000},
+		{"open-ended tier", tiered, 15.5, 8000},
+		{"past last bounded tier", bounded, 30, 5000},
+	}
+	for _, c := range cases {
+		if got := c.cfg.baseFareFor(c.km); got != c.want {
+			t.Errorf("%s: baseFareFor(%v) = %d, want %d", c.name, c.km, got, c.want)
+		}
+	}
+}
+
+func TestEstimateFare_UsesDistanceTier(t *testing.T) {
+	svc := &PricingService{repo: &fakeDemandSupply{ds: *noSurge()}, config: tieredFareConfig()}
+	// ~16.5km apart → open-ended tier.
+	origin := model.Location{Lat: 28.7041, Lon: 77.1025}
+	dest := model.Location{Lat: 28.5562, Lon: 77.0889}
+
+	got, err := svc.EstimateFare(context.Background(), origin, dest, FareOptions{})
+	if err != nil {
+		t.Fatalf("EstimateFare: %v", err)
+	}
+	if got.BaseFareCents != 8000 {
+		t.Errorf("base = %d, want 8000 for a %.1fkm ride", got.BaseFareCents, got.DistanceKm)
+	}
+}
+
+func TestEstimateFare_VeryShortRideHasMinimumTime(t *testing.T) {
+	svc := &PricingService{repo: &fakeDemandSupply{ds: *noSurge()}, config: DefaultFareConfig()}
+	// ~50m apart: same building, different gate.
+	origin := model.Location{Lat: 28.70000, Lon: 77.10000}
+	dest := model.Location{Lat: 28.70045, Lon: 77.10000}
+
+	got, err := svc.EstimateFare(context.Background(), origin, dest, FareOptions{})
+	if err != nil {
+		t.Fatalf("EstimateFare: %v", err)
+	}
+	if got.DistanceKm != 0.05 {
+		t.Errorf("distance = %.2f km, want the real 0.05", got.DistanceKm)
+	}
+	// Floors: 0.5km × 1200 = 600, 2min × 200 = 400.
+	if got.TimeFareCents != 400 {
+		t.Errorf("time fare = %d cents, want the 2 min floor (400)", got.TimeFareCents)
+	}
+	if got.EstimatedMinutes >= 2 {
+		t.Errorf("minutes = %.1f, want the real estimate, not the 2 min floor", got.EstimatedMinutes)
+	}
+	if got.DistanceFareCents != 600 {
+		t.Errorf("distance fare = %d, want the 0.5km floor (600)", got.DistanceFareCents)
+	}
+	if got.SubtotalCents != 6000 {
+		t.Errorf("subtotal = %d, want 5000 + 600 + 400", got.SubtotalCents)
+	}
+}
+
+func TestBuildEstimate_FloorsDisabled(t *testing.T) {
+	cfg := DefaultFareConfig()
+	cfg.MinBillableKm, cfg.MinBillableMinutes = 0, 0
+	svc := NewPricingService(nil, cfg)
+
+	got := svc.buildEstimate(0.05, 0.1, noSurge())
+	if got.DistanceFareCents != 60 || got.TimeFareCents != 20 {
+		t.Errorf("distance/time fare = %d/%d, want unfloored 60/20", got.DistanceFareCents, got.TimeFareCents)
+	}
+}
+
+func TestSurgeCap_ExtremeRatioClamped(t *testing.T) {
+	cfg := DefaultFareConfig()
+	cfg.MaxSurgeMultiplier = 1.3
+	svc := NewPricingService(nil, cfg)
+
+	// Ratio 40: a stadium emptying with two cabs around.
+	extreme := &repository.DemandSupply{Demand: 80, Supply: 2, Ratio: 40}
+	got := svc.buildEstimate(20, 40, extreme)
+	if got.SurgeMultiplier != 1.3 || !got.SurgeCapped {
+		t.Fatalf("surge = %.2f capped=%v, want 1.3 capped", got.SurgeMultiplier, got.SurgeCapped)
+	}
+	// 37000 subtotal × 1.3, not × 1.5.
+	if got.TotalFareCents != 48100 {
+		t.Errorf("total = %d, want 48100", got.TotalFareCents)
+	}
+
+	// Below the cap the multiplier is untouched and not flagged.
+	moderate := svc.buildEstimate(20, 40, &repository.DemandSupply{Demand: 9, Supply: 5, Ratio: 1.8})
+	if moderate.SurgeMultiplier != SurgeMultiplierModerate || moderate.SurgeCapped {
+		t.Errorf("moderate surge = %.2f capped=%v, want %.1f uncapped", moderate.SurgeMultiplier, moderate.SurgeCapped, SurgeMultiplierModerate)
+	}
+}
+
+func TestSurgeCap_CurrentSurgeReportsCap(t *testing.T) {
+	cfg := DefaultFareConfig()
+	cfg.MaxSurgeMultiplier = 1.1
+	svc := &PricingService{repo: &fakeDemandSupply{ds: repository.DemandSupply{Demand: 90, Supply: 1, Ratio: 90}}, config: cfg}
+
+	got, err := svc.CurrentSurge(context.Background(), model.Location{Lat: 28.70, Lon: 77.10}, 0, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SurgeMultiplier != 1.1 || !got.SurgeCapped {
+		t.Errorf("surge = %.2f capped=%v, want 1.1 capped", got.SurgeMultiplier, got.SurgeCapped)
+	}
+}
+
+func TestSurgeCap_ZeroMeansUncapped(t *testing.T) {
+	svc := NewPricingService(nil, DefaultFareConfig())
+	got := svc.buildEstimate(20, 40, &repository.DemandSupply{Demand: 80, Supply: 2, Ratio: 40})
+	if got.SurgeMultiplier != SurgeMultiplierHigh || got.SurgeCapped {
+		t.Errorf("surge = %.2f capped=%v, want %.1f uncapped", got.SurgeMultiplier, got.SurgeCapped, SurgeMultiplierHigh)
+	}
+}
+
+func TestRoundSurge_Steps(t *testing.T) {
+	tests := []struct {
+		step, raw, want float64
+	}{
+		{0.1, 1.2, 1.2},
+		{0.1, 1.26, 1.3},
+		{0.1, 1.04, 1.0},
+		{0.25, 1.2, 1.25},
+		{0.25, 1.6, 1.5},
+		{0.25, 1.7, 1.75},
+		{0.5, 1.2, 1.0},
+		{0.5, 1.3, 1.5},
+		{0.5, 1.8, 2.0},
+		{1, 1.4, 1.0},
+		{0, 1.234, 1.234},
+		// Never below 1.0, whatever the step.
+		{0.5, 0.9, 1.0},
+		{0, 0.8, 1.0},
+	}
+	for _, tt := range tests {
+		if got := roundSurge(tt.raw, tt.step); got != tt.want {
+			t.Errorf("roundSurge(%v, step %v) = %v, want %v", tt.raw, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestSurgeRounding_AppliedBeforeCap(t *testing.T) {
+	cfg := DefaultFareConfig()
+	cfg.SurgeRoundingStep = 0.25
+
+	got, capped := cfg.surgeMultiplier(1.8) // moderate: raw 1.2
+	if got != 1.25 || capped {
+		t.Errorf("quarter-step moderate surge = %v capped=%v, want 1.25 uncapped", got, capped)
+	}
+
+	cfg.SurgeRoundingStep = 0.5
+	if got, _ := cfg.surgeMultiplier(1.8); got != 1.0 {
+		t.Errorf("half-step moderate surge = %v, want 1.0", got)
+	}
+	if got, _ := cfg.surgeMultiplier(40); got != SurgeMultiplierHigh {
+		t.Errorf("half-step high surge = %v, want %v", got, SurgeMultiplierHigh)
+	}
+
+	// A cap off the step still holds: 1.5 rounds to 1.5, then caps to 1.4.
+	cfg.MaxSurgeMultiplier = 1.4
+	if got, capped := cfg.surgeMultiplier(40); got != 1.4 || !capped {
+		t.Errorf("capped surge = %v capped=%v, want 1.4 capped", got, capped)
+	}
+}
+
+func TestSurgeDelta_TotalMinusNoSurgeTotal(t *testing.T) {
+	svc := NewPricingService(nil, DefaultFareConfig())
+	high := &repository.DemandSupply{Demand: 9, Supply: 3, Ratio: 3}
+
+	tests := []struct {
+		name        string
+		estimate    *FareEstimate
+		wantNoSurge int
+		wantDelta   int
+	}{
+		// 37000 subtotal × 1.5 = 55500.
+		{"surging", svc.buildEstimate(20, 40, high), 37000, 18500},
+		{"no surge", svc.buildEstimate(20, 40, noSurge()), 37000, 0},
+		// 6600 subtotal floors to 7500; 6600 × 1.5 = 9900.
+		{"surging over the floor", svc.buildEstimate(1, 2, high), 7500, 2400},
+	}
+	for _, tt := range tests {
+		e := tt.estimate
+		if e.NoSurgeTotalCents != tt.wantNoSurge || e.SurgeDeltaCents != tt.wantDelta {
+			t.Errorf("%s: no-surge %d delta %d, want %d and %d", tt.name, e.NoSurgeTotalCents, e.SurgeDeltaCents, tt.wantNoSurge, tt.wantDelta)
+		}
+		if e.SurgeDeltaCents != e.TotalFareCents-e.NoSurgeTotalCents {
+			t.Errorf("%s: delta %d != total %d − no-surge %d", tt.name, e.SurgeDeltaCents, e.TotalFareCents, e.NoSurgeTotalCents)
+		}
+	}
+}
+
+func TestSurgeDelta_HoldsAfterPoolDiscountAndCap(t *testing.T) {
+	svc := NewPricingService(nil, DefaultFareConfig())
+	high := &repository.DemandSupply{Demand: 9, Supply: 3, Ratio: 3}
+
+	// 55500 surged and 37000 plain, each 10% off: 49950 and 33300.
+	pooled := svc.buildEstimate(20, 40, high)
+	svc.applyPoolDiscount(pooled)
+	if pooled.NoSurgeTotalCents != 33300 || pooled.SurgeDeltaCents != 49950-33300 {
+		t.Errorf("pooled no-surge %d delta %d, want 33300 and %d", pooled.NoSurgeTotalCents, pooled.SurgeDeltaCents, 49950-33300)
+	}
+
+	// A cap between the two prices only trims what surge adds.
+	capped := svc.buildEstimate(20, 40, high)
+	applyFareCap(capped, 40000)
+	if capped.NoSurgeTotalCents != 37000 || capped.SurgeDeltaCents != 3000 {
+		t.Errorf("capped no-surge %d delta %d, want 37000 and 3000", capped.NoSurgeTotalCents, capped.SurgeDeltaCents)
+	}
+
+	// A cap below both leaves surge adding nothing.
+	under := svc.buildEstimate(20, 40, high)
+	applyFareCap(under, 20000)
+	if under.NoSurgeTotalCents != 20000 || under.SurgeDeltaCents != 0 {
+		t.Errorf("cap-bound no-surge %d delta %d, want 20000 and 0", under.NoSurgeTotalCents, under.SurgeDeltaCents)
+	}
+}
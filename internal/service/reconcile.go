@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// ─── Stuck Trip Reconciliation ──────────────────────────────
+
+// StuckTripStore is the subset of the booking repository the reconciler needs.
+type StuckTripStore interface {
+	ReconcileStuckTrips(ctx context.Context, olderThan time.Duration) ([]repository.TripCancelResult, error)
+}
+
+// TripReconciler periodically cancels trips left 'planned' with no
+// matched, confirmed or reserved rider for longer than olderThan — e.g. by
+// a cancel path that missed the trip — and frees their cabs, which would
+// otherwise never be offered for a new trip.
+type TripReconciler struct {
+	repo      StuckTripStore
+	olderThan time.Duration
+	interval  time.Duration
+
+	// Counters, when set, has the cabs freed added back to supply.
+	Counters SurgeCounter
+}
+
+// NewTripReconciler creates a reconciler that runs every interval.
+func NewTripReconciler(repo StuckTripStore, olderThan, interval time.Duration) *TripReconciler {
+	return &TripReconciler{repo: repo, olderThan: olderThan, interval: interval}
+}
+
+// Run reconciles until ctx is cancelled.
+func (r *TripReconciler) Run(ctx context.Context) {
+	log.Printf("[reconcile] Trip reconciler started (stuck after=%s, interval=%s)", r.olderThan, r.interval)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[reconcile] Trip reconciler stopped")
+			return
+		case <-ticker.C:
+			if _, err := r.ReconcileOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[reconcile] WARNING: reconcile failed: %v", err)
+			}
+		}
+	}
+}
+
+// ReconcileOnce cancels stuck trips, logging each, and returns how many it
+// cancelled. Trips cancelled before an error are logged and counted too.
+func (r *TripReconciler) ReconcileOnce(ctx context.Context) (int, error) {
+	fixed, err := r.repo.ReconcileStuckTrips(ctx, r.olderThan)
+	for _, t := range fixed {
+		log.Printf("[reconcile] Cancelled stuck trip #%d (planned with no riders for over %s); cab #%d freed=%t",
+			t.TripID, r.olderThan, t.CabID, t.CabFreed)
+		CountSurge(ctx, r.Counters, t.Surge)
+	}
+	return len(fixed), err
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// fakeTripFleet models planned trips by age and rider count, and which
+// cabs are busy; reconciling cancels riderless old trips and frees cabs.
+type fakeTripFleet struct {
+	ages      map[int64]time.Duration
+	riders    map[int64]int
+	cabOf     map[int64]int64
+	busy      map[int64]bool // cab → en_route
+	cancelled map[int64]bool
+	err       error
+}
+
+func (f *fakeTripFleet) ReconcileStuckTrips(_ context.Context, olderThan time.Duration) ([]repository.TripCancelResult, error) {
+	var fixed []repository.TripCancelResult
+	for id, age := range f.ages {
+		if f.cancelled[id] || f.riders[id] > 0 || age <= olderThan {
+			continue
+		}
+		f.cancelled[id] = true
+		cab := f.cabOf[id]
+		fixed = append(fixed, repository.TripCancelResult{TripID: id, CabID: cab, CabFreed: f.busy[cab]})
+		f.busy[cab] = false
+	}
+	return fixed, f.err
+}
+
+func TestTripReconciler_CancelsOnlyStuckTrips(t *testing.T) {
+	fleet := &fakeTripFleet{
+		ages:      map[int64]time.Duration{1: 3 * time.Hour, 2: 3 * time.Hour, 3: 5 * time.Minute},
+		riders:    map[int64]int{2: 1},
+		cabOf:     map[int64]int64{1: 10, 2: 20, 3: 30},
+		busy:      map[int64]bool{10: true, 20: true, 30: true},
+		cancelled: map[int64]bool{},
+	}
+	r := NewTripReconciler(fleet, time.Hour, time.Minute)
+
+	n, err := r.ReconcileOnce(context.Background())
+	if err != nil {
+		t.Fatalf("ReconcileOnce: %v", err)
+	}
+	if n != 1 || !fleet.cancelled[1] || fleet.cancelled[2] || fleet.cancelled[3] {
+		t.Errorf("cancelled = %v (n=%d), want only the old riderless trip 1", fleet.cancelled, n)
+	}
+	if fleet.busy[10] || !fleet.busy[20] || !fleet.busy[30] {
+		t.Errorf("busy cabs = %v, want only cab 10 freed", fleet.busy)
+	}
+
+	// A second pass has nothing left to fix.
+	if n, _ := r.ReconcileOnce(context.Background()); n != 0 {
+		t.Errorf("second pass cancelled %d trips, want 0", n)
+	}
+}
+
+func TestTripReconciler_CountsTripsFixedBeforeError(t *testing.T) {
+	boom := errors.New("lock timeout")
+	fleet := &fakeTripFleet{
+		ages:      map[int64]time.Duration{1: 3 * time.Hour},
+		cabOf:     map[int64]int64{1: 10},
+		busy:      map[int64]bool{10: true},
+		cancelled: map[int64]bool{},
+		err:       boom,
+	}
+	n, err := NewTripReconciler(fleet, time.Hour, time.Minute).ReconcileOnce(context.Background())
+	if !errors.Is(err, boom) || n != 1 {
+		t.Errorf("ReconcileOnce = %d, %v; want 1 and the store's error", n, err)
+	}
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// ─── Scheduled Rides ────────────────────────────────────────
+
+// InitialStatus is the status a new request starts in: 'scheduled' if its
+// scheduled_at is more than lead after now, otherwise 'pending' so it can
+// match straight away.
+func InitialStatus(scheduledAt *time.Time, now time.Time, lead time.Duration) model.RequestStatus {
+	if scheduledAt != nil && scheduledAt.Sub(now) > lead {
+		return model.RequestScheduled
+	}
+	return model.RequestPending
+}
+
+// reservable reports whether req is a SCHEDULED request, not yet holding a
+// seat, that may be booked at now to reserve capacity ahead of departure
+// (MatchConfig.ReserveScheduled), departing within ReserveHorizon.
+func (c MatchConfig) reservable(req *model.RideRequest, now time.Time) bool {
+	if !c.ReserveScheduled || req.Status != model.RequestScheduled || req.TripID != nil {
+		return false
+	}
+	return c.ReserveHorizon <= 0 || req.ScheduledAt == nil || !req.ScheduledAt.After(now.Add(c.ReserveHorizon))
+}
+
+// departsTogether reports whether req departs within
+// MatchConfig.DepartureWindow of every rider already on ct.
+func (s *MatchingService) departsTogether(ct *model.CandidateTrip, req *model.RideRequest) bool {
+	window := s.config.DepartureWindow
+	if window <= 0 {
+		return true
+	}
+	departs := departure(req.ScheduledAt, s.now())
+	return !ct.DepartsFrom.Before(departs.Add(-window)) && !ct.DepartsUntil.After(departs.Add(window))
+}
+
+// Bounds on a requested scheduled_at, relative to the server clock.
+const (
+	// ScheduleClockSkew is how far in the past scheduled_at may be and still
+	// be read as "now" rather than rejected: client clocks drift.
+	ScheduleClockSkew = 2 * time.Minute
+
+	// MaxScheduleAhead is the furthest out a ride can be scheduled.
+	MaxScheduleAhead = 30 * 24 * time.Hour
+)
+
+var (
+	// ErrScheduledInPast is returned when scheduled_at is more than
+	// ScheduleClockSkew before now.
+	ErrScheduledInPast = errors.New("scheduled_at is in the past")
+
+	// ErrScheduledTooFar is returned when scheduled_at is more than
+	// MaxScheduleAhead after now.
+	ErrScheduledTooFar = errors.New("scheduled_at is too far in the future")
+)
+
+// ValidateScheduledAt checks a requested departure time against now. A nil
+// scheduledAt (ride now) is always valid.
+func ValidateScheduledAt(scheduledAt *time.Time, now time.Time) error {
+	if scheduledAt == nil {
+		return nil
+	}
+	if scheduledAt.Before(now.Add(-ScheduleClockSkew)) {
+		return ErrScheduledInPast
+	}
+	if scheduledAt.After(now.Add(MaxScheduleAhead)) {
+		return ErrScheduledTooFar
+	}
+	return nil
+}
+
+// ScheduledActivator is the subset of the ride request repository the
+// schedule activator needs.
+type ScheduledActivator interface {
+	ActivateDueScheduled(ctx context.Context, before time.Time) (int, []repository.SurgeChange, error)
+}
+
+// ScheduleActivator periodically moves SCHEDULED requests into 'pending'
+// once departure is within the lead time, so they neither match hours
+// early nor sit in the pending pool long enough to expire.
+type ScheduleActivator struct {
+	repo     ScheduledActivator
+	lead     time.Duration
+	interval time.Duration
+	now      func() time.Time
+
+	// Counters, when set, has requests that enter 'pending' added to demand.
+	Counters SurgeCounter
+}
+
+// NewScheduleActivator creates an activator that runs every interval.
+func NewScheduleActivator(repo ScheduledActivator, lead, interval time.Duration) *ScheduleActivator {
+	return &ScheduleActivator{repo: repo, lead: lead, interval: interval, now: time.Now}
+}
+
+// Run activates due requests until ctx is cancelled.
+func (a *ScheduleActivator) Run(ctx context.Context) {
+	log.Printf("[schedule] Activator started (lead=%s, interval=%s)", a.lead, a.interval)
+
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Printf("[schedule] Activator stopped")
+			return
+		case <-ticker.C:
+			if _, err := a.ActivateOnce(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("[schedule] WARNING: activation failed: %v", err)
+			}
+		}
+	}
+}
+
+// ActivateOnce moves every scheduled request departing within the lead
+// time into 'pending' and returns how many moved.
+func (a *ScheduleActivator) ActivateOnce(ctx context.Context) (int, error) {
+	n, surge, err := a.repo.ActivateDueScheduled(ctx, a.now().Add(a.lead))
+	if err != nil {
+		return 0, err
+	}
+	CountSurge(ctx, a.Counters, surge)
+	if n > 0 {
+		log.Printf("[schedule] Activated %d scheduled requests departing within %s", n, a.lead)
+	}
+	return n, nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// fakeSchedule models ride requests by departure time and status;
+// activation moves due scheduled requests into the pending pool.
+type fakeSchedule struct {
+	departs map[int64]time.Time
+	status  map[int64]model.RequestStatus
+}
+
+func (f *fakeSchedule) ActivateDueScheduled(_ context.Context, before time.Time) (int, []repository.SurgeChange, error) {
+	var surge []repository.SurgeChange
+	for id, at := range f.departs {
+		if f.status[id] == model.RequestScheduled && !at.After(before) {
+			f.status[id] = model.RequestPending
+			surge = append(surge, repository.SurgeChange{Direction: model.DirectionToAirport, Demand: 1})
+		}
+	}
+	return len(surge), surge, nil
+}
+
+// matchable reports whether the matcher would consider request id.
+func (f *fakeSchedule) matchable(id int64) bool {
+	return f.status[id] == model.RequestPending
+}
+
+func TestInitialStatus(t *testing.T) {
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	soon, later := now.Add(20*time.Minute), now.Add(3*time.Hour)
+	tests := []struct {
+		name string
+		at   *time.Time
+		want model.RequestStatus
+	}{
+		{"immediate", nil, model.RequestPending},
+		{"within lead time", &soon, model.RequestPending},
+		{"far future", &later, model.RequestScheduled},
+	}
+	for _, tt := range tests {
+		if got := InitialStatus(tt.at, now, 30*time.Minute); got != tt.want {
+			t.Errorf("%s: status = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestValidateScheduledAt(t *testing.T) {
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time { ts := now.Add(d); return &ts }
+	tests := []struct {
+		name string
+		at   *time.Time
+		want error
+	}{
+		{"immediate", nil, nil},
+		{"an hour ago", at(-time.Hour), ErrScheduledInPast},
+		{"just past the skew allowance", at(-ScheduleClockSkew - time.Second), ErrScheduledInPast},
+		{"within clock skew", at(-30 * time.Second), nil},
+		{"near future", at(20 * time.Minute), nil},
+		{"at the horizon", at(MaxScheduleAhead), nil},
+		{"far future", at(MaxScheduleAhead + time.Hour), ErrScheduledTooFar},
+		{"next century", at(100 * 365 * 24 * time.Hour), ErrScheduledTooFar},
+	}
+	for _, tt := range tests {
+		if err := ValidateScheduledAt(tt.at, now); !errors.Is(err, tt.want) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestScheduleActivator_FarFutureRideWaitsForWindow(t *testing.T) {
+	created := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	lead := 30 * time.Minute
+	departs := created.Add(3 * time.Hour)
+	f := &fakeSchedule{
+		departs: map[int64]time.Time{1: departs},
+		status:  map[int64]model.RequestStatus{1: InitialStatus(&departs, created, lead)},
+	}
+	counters := newFakeSurgeCounter()
+	a := NewScheduleActivator(f, lead, time.Minute)
+	a.Counters = counters
+
+	for _, step := range []struct {
+		now  time.Time
+		want bool
+	}{
+		{created, false},
+		{departs.Add(-lead - time.Minute), false},
+		{departs.Add(-lead), true},
+	} {
+		a.now = func() time.Time { return step.now }
+		if _, err := a.ActivateOnce(context.Background()); err != nil {
+			t.Fatalf("ActivateOnce: %v", err)
+		}
+		if got := f.matchable(1); got != step.want {
+			t.Errorf("at %s before departure: matchable = %v, want %v",
+				departs.Sub(step.now), got, step.want)
+		}
+	}
+	if got := counters.demand[model.Location{}][model.DirectionToAirport]; got != 1 {
+		t.Errorf("counted demand = %d, want 1 once the ride is pending", got)
+	}
+}
+
+// requestStore is a MatchingStore holding one ride request and no trips.
+type requestStore struct {
+	countingStore
+	req model.RideRequest
+}
+
+func (s *requestStore) GetRideRequest(context.Context, int64, bool) (*model.RideRequest, error) {
+	req := s.req
+	return &req, nil
+}
+
+func TestBookRide_ScheduledReservation(t *testing.T) {
+	tripID := int64(7)
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	soon, tomorrow := now.Add(time.Hour), now.Add(24*time.Hour)
+	tests := []struct {
+		name    string
+		reserve bool
+		req     model.RideRequest
+		wantErr error
+	}{
+		{"reservations off", false, model.RideRequest{Status: model.RequestScheduled}, ErrRequestScheduled},
+		{"reservations on", true, model.RideRequest{Status: model.RequestScheduled, ScheduledAt: &soon}, nil},
+		{"beyond horizon", true, model.RideRequest{Status: model.RequestScheduled, ScheduledAt: &tomorrow}, ErrRequestScheduled},
+		{"already reserved", true, model.RideRequest{Status: model.RequestScheduled, TripID: &tripID}, ErrRequestNotPending},
+		{"pending unaffected", false, model.RideRequest{Status: model.RequestPending}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.req.ID, tt.req.Direction = 42, model.DirectionToAirport
+			cfg := DefaultMatchConfig()
+			cfg.ReserveScheduled = tt.reserve
+			cfg.ReserveHorizon = 2 * time.Hour
+			matching := NewMatchingService(&requestStore{req: tt.req}, cfg)
+			matching.now = func() time.Time { return now }
+			pricing := &PricingService{repo: &fakeDemandSupply{ds: repository.DemandSupply{Demand: 1, Supply: 1, Ratio: 1}}, config: DefaultFareConfig()}
+			svc := NewBookingService(fakeBookingStore{}, matching, pricing, 0)
+
+			res, err := svc.BookRide(context.Background(), 42, BookingOptions{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("BookRide err = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && (res == nil || res.TripID != 9) {
+				t.Errorf("result = %+v, want booked onto trip 9", res)
+			}
+		})
+	}
+}
+
+func TestMatch_ScheduledReservation(t *testing.T) {
+	tripID := int64(7)
+	tests := []struct {
+		name    string
+		reserve bool
+		tripID  *int64
+		wantErr error
+	}{
+		{"reservations off", false, nil, ErrRequestScheduled},
+		{"reservations on searches", true, nil, ErrNoMatch},
+		{"already reserved", true, &tripID, ErrAlreadyMatched},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultMatchConfig()
+			cfg.ReserveScheduled = tt.reserve
+			store := &requestStore{req: model.RideRequest{ID: 42, Status: model.RequestScheduled, TripID: tt.tripID}}
+			svc := NewMatchingService(store, cfg)
+			if _, err := svc.match(context.Background(), 42, nil); !errors.Is(err, tt.wantErr) {
+				t.Errorf("match err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestScoreCandidate_DepartureWindow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	tomorrow := now.Add(24 * time.Hour)
+
+	liveTrip := plannedTrip()
+	liveTrip.DepartsFrom, liveTrip.DepartsUntil = now, now
+	reservedTrip := plannedTrip()
+	reservedTrip.DepartsFrom, reservedTrip.DepartsUntil = tomorrow, tomorrow
+
+	tests := []struct {
+		name        string
+		trip        *model.CandidateTrip
+		scheduledAt *time.Time
+		want        bool
+	}{
+		{"far-future reservation skips live trip", liveTrip, &tomorrow, false},
+		{"live rider skips reserved trip", reservedTrip, nil, false},
+		{"reservation joins trip leaving with it", reservedTrip, &tomorrow, true},
+		{"live rider joins live trip", liveTrip, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultMatchConfig()
+			cfg.DepartureWindow = 30 * time.Minute
+			svc := NewMatchingService(nil, cfg)
+			svc.now = func() time.Time { return now }
+			req := &model.RideRequest{
+				Origin:          model.Location{Lat: 28.69, Lon: 77.10},
+				SeatsNeeded:     1,
+				ToleranceMeters: DefaultSearchRadiusM,
+				ScheduledAt:     tt.scheduledAt,
+			}
+			if _, _, ok := svc.scoreCandidate(context.Background(), tt.trip, req); ok != tt.want {
+				t.Errorf("scoreCandidate ok = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatch_FarFutureReservationSkipsLiveTrip(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	tomorrow := now.Add(24 * time.Hour)
+	live := plannedTrip()
+	live.DepartsFrom, live.DepartsUntil = now, now
+
+	cfg := DefaultMatchConfig()
+	cfg.DepartureWindow = 30 * time.Minute
+	svc := NewMatchingService(&candidateStore{candidates: []model.CandidateTrip{*live}}, cfg)
+	svc.now = func() time.Time { return now }
+	req := &model.RideRequest{
+		Origin:          model.Location{Lat: 28.69, Lon: 77.10},
+		SeatsNeeded:     1,
+		ToleranceMeters: DefaultSearchRadiusM,
+		ScheduledAt:     &tomorrow,
+	}
+	if _, _, err := svc.findBestTrip(context.Background(), req, nil); !errors.Is(err, ErrNoMatch) {
+		t.Errorf("findBestTrip err = %v, want ErrNoMatch", err)
+	}
+}
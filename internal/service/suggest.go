@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"math"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/pkg/logctx"
+)
+
+// ─── Booking Suggestions ────────────────────────────────────
+
+// Suggestion kinds: which of the rider's constraints was relaxed.
+const (
+	// SuggestFewerLuggage: the trip fits if the rider brings LuggageCount bags.
+	SuggestFewerLuggage = "fewer_luggage"
+	// SuggestLongerDetour: the trip fits if the rider accepts a detour of up
+	// to ToleranceMeters (more time in the cab).
+	SuggestLongerDetour = "longer_detour"
+)
+
+// Suggestion is an alternative for a rider whose booking failed for lack
+// of room: a trip they could join with one constraint relaxed.
+type Suggestion struct {
+	Kind                   string  `json:"kind"`
+	TripID                 int64   `json:"trip_id"`
+	CabID                  int64   `json:"cab_id"`
+	LuggageCount           *int    `json:"luggage_count,omitempty"`    // Set on fewer_luggage.
+	ToleranceMeters        *int    `json:"tolerance_meters,omitempty"` // Set on longer_detour.
+	EstimatedDetourMinutes float64 `json:"estimated_detour_minutes"`
+}
+
+// Suggest reruns the candidate scan for req relaxing one constraint at a
+// time, and returns at most one suggestion per kind:
+//
+//   - fewer_luggage: the fewest bags dropped that let req join a trip.
+//   - longer_detour: the best trip within the tolerance cap
+//     (MatchConfig.MaxToleranceMeters), if req's own tolerance is lower.
+//
+// Seats and accessibility are never relaxed. Read-only, like PreviewMatch.
+func (s *MatchingService) Suggest(ctx context.Context, req *model.RideRequest) ([]Suggestion, error) {
+	var suggestions []Suggestion
+
+	if req.LuggageCount > 0 {
+		candidates, _, err := s.loadCandidates(ctx, req, searchRadius(req))
+		if err != nil {
+			return nil, err
+		}
+		probe := *req
+		for probe.LuggageCount = req.LuggageCount - 1; probe.LuggageCount >= 0; probe.LuggageCount-- {
+			if best := s.pickBest(ctx, candidates, &probe); best != nil {
+				suggestions = append(suggestions, suggestionFor(SuggestFewerLuggage, best, &probe))
+				break
+			}
+		}
+	}
+
+	if limit, _ := ClampTolerance(MaxToleranceMeters, s.config.MaxToleranceMeters); searchRadius(req) < limit {
+		probe := *req
+		probe.ToleranceMeters = limit
+		candidates, _, err := s.loadCandidates(ctx, &probe, limit)
+		if err != nil {
+			return nil, err
+		}
+		if best := s.pickBest(ctx, candidates, &probe); best != nil {
+			suggestions = append(suggestions, suggestionFor(SuggestLongerDetour, best, &probe))
+		}
+	}
+
+	logctx.Debugf(ctx, "[match] %d suggestion(s) for request #%d", len(suggestions), req.ID)
+	return suggestions, nil
+}
+
+// suggestionFor describes match as a suggestion of the given kind for the
+// relaxed probe.
+func suggestionFor(kind string, match *model.MatchResult, probe *model.RideRequest) Suggestion {
+	sg := Suggestion{
+		Kind:                   kind,
+		TripID:                 match.TripID,
+		CabID:                  match.CabID,
+		EstimatedDetourMinutes: math.Round(match.AddedDetour*10) / 10,
+	}
+	switch kind {
+	case SuggestFewerLuggage:
+		sg.LuggageCount = &probe.LuggageCount
+	case SuggestLongerDetour:
+		sg.ToleranceMeters = &probe.ToleranceMeters
+	}
+	return sg
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+)
+
+func TestSuggest_DroppingLuggageFitsTrip(t *testing.T) {
+	// plannedTrip has 2 of its 3 bag slots free.
+	svc := NewMatchingService(candidateStore{[]model.CandidateTrip{*plannedTrip()}}, DefaultMatchConfig())
+	req := &model.RideRequest{
+		ID: 1, Origin: model.Location{Lat: 28.69, Lon: 77.10}, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, LuggageCount: 3, ToleranceMeters: DefaultSearchRadiusM,
+	}
+
+	got, err := svc.Suggest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Suggest: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("suggestions = %+v, want exactly one (fewer_luggage)", got)
+	}
+	sg := got[0]
+	if sg.Kind != SuggestFewerLuggage || sg.TripID != 7 || sg.CabID != 3 {
+		t.Errorf("suggestion = %+v, want fewer_luggage on trip 7 (cab 3)", sg)
+	}
+	if sg.LuggageCount == nil || *sg.LuggageCount != 2 {
+		t.Errorf("luggage_count = %v, want 2 (drop one bag)", sg.LuggageCount)
+	}
+	if sg.ToleranceMeters != nil {
+		t.Errorf("tolerance_meters = %d, want unset on a luggage suggestion", *sg.ToleranceMeters)
+	}
+	if req.LuggageCount != 3 {
+		t.Errorf("Suggest changed the request's luggage to %d", req.LuggageCount)
+	}
+}
+
+func TestSuggest_LongerDetourFitsTrip(t *testing.T) {
+	cfg := DefaultMatchConfig()
+	cfg.Airport = model.Location{Lat: 28.5562, Lon: 77.0889}
+	svc := NewMatchingService(candidateStore{[]model.CandidateTrip{*plannedTrip()}}, cfg)
+	// Off the trip's line: too far for 2km of tolerance, fine within the max.
+	req := &model.RideRequest{
+		ID: 1, Origin: model.Location{Lat: 28.72, Lon: 77.13}, Direction: model.DirectionToAirport,
+		SeatsNeeded: 1, ToleranceMeters: DefaultSearchRadiusM,
+	}
+	if _, _, err := svc.findBestTrip(context.Background(), req, nil); err == nil {
+		t.Fatal("rider matches at their own tolerance; scenario needs a longer detour")
+	}
+
+	got, err := svc.Suggest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Suggest: %v", err)
+	}
+	if len(got) != 1 || got[0].Kind != SuggestLongerDetour || got[0].TripID != 7 {
+		t.Fatalf("suggestions = %+v, want one longer_detour on trip 7", got)
+	}
+	if got[0].ToleranceMeters == nil || *got[0].ToleranceMeters != MaxToleranceMeters {
+		t.Errorf("tolerance_meters = %v, want %d", got[0].ToleranceMeters, MaxToleranceMeters)
+	}
+	if got[0].EstimatedDetourMinutes <= ToleranceMinutes(DefaultSearchRadiusM) {
+		t.Errorf("detour = %.1f min, want above the rider's own tolerance", got[0].EstimatedDetourMinutes)
+	}
+}
+
+func TestSuggest_NothingFits(t *testing.T) {
+	// Seats are never relaxed: a 4-rider group cannot join a trip with 3 free seats.
+	svc := NewMatchingService(candidateStore{[]model.CandidateTrip{*plannedTrip()}}, DefaultMatchConfig())
+	req := &model.RideRequest{
+		Origin: model.Location{Lat: 28.69, Lon: 77.10}, Direction: model.DirectionToAirport,
+		SeatsNeeded: 4, LuggageCount: 1, ToleranceMeters: DefaultSearchRadiusM,
+	}
+	got, err := svc.Suggest(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Suggest: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("suggestions = %+v, want none", got)
+	}
+}
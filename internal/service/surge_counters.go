@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+	"github.com/shiva/hintro/pkg/logctx"
+)
+
+// ─── Surge Counters ─────────────────────────────────────────
+
+// SurgeCounter is the write side of repository.SurgeCounters: the live
+// per-cell demand and supply counts surge pricing reads.
+type SurgeCounter interface {
+	AddDemand(ctx context.Context, loc model.Location, direction model.TripDirection, delta int)
+	AddSupply(ctx context.Context, loc model.Location, delta int)
+}
+
+// CountSurge reports the changes a committed transaction made to demand
+// and supply (see repository.SurgeChange) to counters, which may be nil.
+func CountSurge(ctx context.Context, counters SurgeCounter, changes []repository.SurgeChange) {
+	if counters == nil {
+		return
+	}
+	for _, ch := range changes {
+		if ch.Demand != 0 {
+			counters.AddDemand(ctx, ch.Location, ch.Direction, ch.Demand)
+		}
+		if ch.Supply != 0 {
+			counters.AddSupply(ctx, ch.Location, ch.Supply)
+		}
+	}
+}
+
+// SurgeCounterStore is the part of repository.SurgeCounters the
+// reconciler needs.
+type SurgeCounterStore interface {
+	Reconcile(ctx context.Context) (int, error)
+}
+
+// SurgeReconciler periodically rewrites the surge counters from the
+// database, correcting the drift left by changes no writer reports (cabs
+// going stale or coming back, dropped increments).
+type SurgeReconciler struct {
+	counters SurgeCounterStore
+	interval time.Duration
+}
+
+// NewSurgeReconciler creates a reconciler that runs every interval.
+func NewSurgeReconciler(counters SurgeCounterStore, interval time.Duration) *SurgeReconciler {
+	return &SurgeReconciler{counters: counters, interval: interval}
+}
+
+// Run reconciles once straight away, so surge reads can use the counters
+// without waiting a full interval, then every interval until ctx is
+// cancelled.
+func (r *SurgeReconciler) Run(ctx context.Context) {
+	log.Printf("[surge] Counter reconciler started (interval=%s)", r.interval)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if cells, err := r.counters.Reconcile(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("[surge] WARNING: counter reconcile failed: %v", err)
+		} else if err == nil {
+			logctx.Debugf(ctx, "[surge] Reconciled counters for %d cells", cells)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("[surge] Counter reconciler stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
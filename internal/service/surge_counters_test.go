@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// fakeSurgeCounter keeps demand per (origin, direction) and supply per
+// location.
+type fakeSurgeCounter struct {
+	demand map[model.Location]map[model.TripDirection]int
+	supply map[model.Location]int
+}
+
+func newFakeSurgeCounter() *fakeSurgeCounter {
+	return &fakeSurgeCounter{demand: map[model.Location]map[model.TripDirection]int{}, supply: map[model.Location]int{}}
+}
+
+func (f *fakeSurgeCounter) AddDemand(_ context.Context, loc model.Location, dir model.TripDirection, delta int) {
+	if f.demand[loc] == nil {
+		f.demand[loc] = map[model.TripDirection]int{}
+	}
+	f.demand[loc][dir] += delta
+}
+
+func (f *fakeSurgeCounter) AddSupply(_ context.Context, loc model.Location, delta int) {
+	f.supply[loc] += delta
+}
+
+// cancelResultStore answers CancelRide with a fixed result.
+type cancelResultStore struct{ result repository.CancelResult }
+
+func (s *cancelResultStore) CancelRide(_ context.Context, requestID int64) (*repository.CancelResult, error) {
+	result := s.result
+	result.RequestID = requestID
+	return &result, nil
+}
+
+func (s *cancelResultStore) CancelTrip(context.Context, int64) (*repository.TripCancelResult, error) {
+	panic("not used")
+}
+
+func TestCancelRide_PendingDecrementsDemand(t *testing.T) {
+	origin := model.Location{Lat: 28.70, Lon: 77.10}
+	counters := newFakeSurgeCounter()
+	counters.AddDemand(context.Background(), origin, model.DirectionToAirport, 1) // the created request
+	store := &cancelResultStore{result: repository.CancelResult{
+		OriginLat: origin.Lat, OriginLon: origin.Lon,
+		PreviousStatus: model.RequestPending, Direction: model.DirectionToAirport,
+	}}
+	svc := &CancelService{bookingRepo: store, pricingRepo: &fakeSurgeCache{}, Counters: counters}
+
+	if _, err := svc.CancelRide(context.Background(), 1); err != nil {
+		t.Fatalf("CancelRide: %v", err)
+	}
+	if got := counters.demand[origin][model.DirectionToAirport]; got != 0 {
+		t.Errorf("demand after cancel = %d, want 0", got)
+	}
+	if len(counters.supply) != 0 {
+		t.Errorf("supply = %v, want untouched (no cab freed)", counters.supply)
+	}
+}
+
+func TestCancelRide_MatchedFreesSupplyNotDemand(t *testing.T) {
+	origin := model.Location{Lat: 28.70, Lon: 77.10}
+	cab := model.Location{Lat: 28.71, Lon: 77.11}
+	counters := newFakeSurgeCounter()
+	store := &cancelResultStore{result: repository.CancelResult{
+		OriginLat: origin.Lat, OriginLon: origin.Lon,
+		PreviousStatus: model.RequestMatched, Direction: model.DirectionToAirport,
+		TripCancelled: true, CabFreed: true, CabLocation: &cab,
+	}}
+	svc := &CancelService{bookingRepo: store, pricingRepo: &fakeSurgeCache{}, Counters: counters}
+
+	if _, err := svc.CancelRide(context.Background(), 1); err != nil {
+		t.Fatalf("CancelRide: %v", err)
+	}
+	if len(counters.demand) != 0 {
+		t.Errorf("demand = %v, want untouched (a matched request is not demand)", counters.demand)
+	}
+	if counters.supply[cab] != 1 {
+		t.Errorf("supply at freed cab = %d, want 1", counters.supply[cab])
+	}
+}
+
+func TestCancelRide_ReplayLeavesCountersAlone(t *testing.T) {
+	counters := newFakeSurgeCounter()
+	store := &cancelResultStore{result: repository.CancelResult{AlreadyCancelled: true}}
+	svc := &CancelService{bookingRepo: store, pricingRepo: &fakeSurgeCache{}, Counters: counters}
+
+	if _, err := svc.CancelRide(context.Background(), 1); err != nil {
+		t.Fatalf("CancelRide: %v", err)
+	}
+	if len(counters.demand) != 0 || len(counters.supply) != 0 {
+		t.Errorf("counters = %v / %v, want untouched by a replayed cancel", counters.demand, counters.supply)
+	}
+}
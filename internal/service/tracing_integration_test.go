@@ -0,0 +1,59 @@
+//go:build integration
+
+package service
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// liveCandidates is a countingStore whose candidate search runs against
+// the database, so it emits the same pgx spans as production.
+type liveCandidates struct {
+	*countingStore
+	rides *repository.RideRepository
+}
+
+func (l liveCandidates) FindNearbyCandidateTrips(ctx context.Context, origin model.Location, direction model.TripDirection, radiusMeters int, requiresAccessible bool) ([]model.CandidateTrip, error) {
+	return l.rides.FindNearbyCandidateTrips(ctx, origin, direction, radiusMeters, requiresAccessible)
+}
+
+func TestBookRide_SpanHierarchyIncludesSQL(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	spans := recordSpans(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	t.Cleanup(cancel)
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		t.Fatalf("parse dsn: %v", err)
+	}
+	// As db.NewPostgresPool traces every query.
+	poolCfg.ConnConfig.Tracer = otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName())
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(pool.Close)
+
+	store := liveCandidates{countingStore: &countingStore{}, rides: repository.NewRideRepository(pool)}
+	serveTracedBooking(t, NewMatchingService(store, DefaultMatchConfig()))
+
+	assertSpanParents(t, spans, map[string]string{
+		"BookingService.BookRide":     "POST /api/v1/book/{request_id}",
+		"MatchingService.Match":       "BookingService.BookRide",
+		"PricingService.QuoteBooking": "BookingService.BookRide",
+		"SELECT":                      "MatchingService.Match",
+	})
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/shiva/hintro/internal/middleware"
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// fakeBookingStore always has a cab for a new trip and always books.
+type fakeBookingStore struct{}
+
+func (fakeBookingStore) BookRide(_ context.Context, requestID, cabID, tripID int64, _ repository.FareSnapshot) (*repository.BookingResult, error) {
+	return &repository.BookingResult{RequestID: requestID, CabID: cabID, TripID: tripID}, nil
+}
+
+func (fakeBookingStore) FindAndCreateTrip(context.Context, model.Location, int, int, int, bool, model.TripDirection) (int64, *model.Cab, error) {
+	return 9, &model.Cab{ID: 4}, nil
+}
+
+func (fakeBookingStore) DeleteEmptyTrip(context.Context, int64) (bool, error) {
+	return false, nil
+}
+
+func (fakeBookingStore) PriorBooking(context.Context, int64) (*repository.BookingResult, error) {
+	return nil, repository.ErrRequestNotPending
+}
+
+// recordSpans installs a tracer provider that keeps every ended span in
+// memory, for the duration of the test.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return exporter
+}
+
+func TestBookRide_SpanHierarchy(t *testing.T) {
+	spans := recordSpans(t)
+
+	matching := NewMatchingService(&countingStore{}, DefaultMatchConfig())
+	serveTracedBooking(t, matching)
+
+	assertSpanParents(t, spans, map[string]string{
+		"BookingService.BookRide":     "POST /api/v1/book/{request_id}",
+		"MatchingService.Match":       "BookingService.BookRide",
+		"PricingService.QuoteBooking": "BookingService.BookRide",
+	})
+}
+
+// serveTracedBooking books request 42 through the tracing middleware, as
+// POST /api/v1/book/{request_id} does, with matching's store behind it.
+func serveTracedBooking(t *testing.T, matching *MatchingService) {
+	t.Helper()
+	pricing := &PricingService{repo: &fakeDemandSupply{ds: repository.DemandSupply{Demand: 1, Supply: 1, Ratio: 1}}, config: DefaultFareConfig()}
+	svc := NewBookingService(fakeBookingStore{}, matching, pricing, 0)
+
+	router := mux.NewRouter()
+	router.Use(middleware.Tracing)
+	router.HandleFunc("/api/v1/book/{request_id}", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := svc.BookRide(r.Context(), 42, BookingOptions{}); err != nil {
+			t.Errorf("BookRide: %v", err)
+		}
+	}).Methods(http.MethodPost)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/v1/book/42", nil))
+}
+
+// assertSpanParents checks that the HTTP span is a root and that each span
+// named in wantParent is a child of the named parent, in the same trace.
+func assertSpanParents(t *testing.T, spans *tracetest.InMemoryExporter, wantParent map[string]string) {
+	t.Helper()
+	byName := map[string]tracetest.SpanStub{}
+	for _, s := range spans.GetSpans() {
+		byName[s.Name] = s
+	}
+	root, ok := byName["POST /api/v1/book/{request_id}"]
+	if !ok {
+		t.Fatalf("no HTTP root span; got %v", spanNames(spans.GetSpans()))
+	}
+	if root.Parent.IsValid() {
+		t.Errorf("HTTP span has parent %s, want a root span", root.Parent.SpanID())
+	}
+
+	for name, parent := range wantParent {
+		span, ok := byName[name]
+		if !ok {
+			t.Errorf("no %s span; got %v", name, spanNames(spans.GetSpans()))
+			continue
+		}
+		if span.Parent.SpanID() != byName[parent].SpanContext.SpanID() {
+			t.Errorf("%s parent = %s, want %s", name, span.Parent.SpanID(), parent)
+		}
+		if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Errorf("%s is in trace %s, want the request's %s", name, span.SpanContext.TraceID(), root.SpanContext.TraceID())
+		}
+	}
+}
+
+func spanNames(spans tracetest.SpanStubs) []string {
+	names := make([]string, len(spans))
+	for i, s := range spans {
+		names[i] = s.Name
+	}
+	return names
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"math"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+// ─── Trip fare breakdown (driver view) ──────────────────────
+
+// PassengerFare is one rider's part of a trip's fare: what they were
+// quoted at booking, and their share of the trip total.
+type PassengerFare struct {
+	RequestID         int64   `json:"request_id"`
+	UserID            int64   `json:"user_id"`
+	Seats             int     `json:"seats"`
+	FareCents         int     `json:"fare_cents"`
+	PoolDiscountCents int     `json:"pool_discount_cents"`
+	SurgeMultiplier   float64 `json:"surge_multiplier"`
+	SharePercent      float64 `json:"share_percent"`      // Of the trip total, to 0.1%.
+	Unpriced          bool    `json:"unpriced,omitempty"` // Booked before fares were recorded; counted as 0.
+}
+
+// TripFareBreakdown is the whole fare of a trip and how its riders make
+// it up (see TripFare).
+type TripFareBreakdown struct {
+	TripID            int64            `json:"trip_id"`
+	Status            model.TripStatus `json:"status"`
+	Passengers        int              `json:"passengers"`
+	TotalFareCents    int              `json:"total_fare_cents"`
+	PoolDiscountCents int              `json:"pool_discount_cents"`
+	SurgeMultiplier   float64          `json:"surge_multiplier"` // Fare-weighted mean of the riders' surge.
+	Shares            []PassengerFare  `json:"shares"`
+}
+
+// TripFare adds up the fares a trip's riders were booked at. The total is
+// the sum of their quoted fares (after pool discount), so it is what the
+// trip earns; SurgeMultiplier weights each rider's surge by their fare.
+// A trip with no riders, or none with a recorded fare, totals 0 at 1.0x.
+func TripFare(fares *repository.TripFares) *TripFareBreakdown {
+	b := &TripFareBreakdown{
+		TripID:          fares.TripID,
+		Status:          fares.Status,
+		Passengers:      len(fares.Riders),
+		SurgeMultiplier: SurgeMultiplierNone,
+		Shares:          make([]PassengerFare, 0, len(fares.Riders)),
+	}
+
+	var surgeWeighted float64
+	for _, rr := range fares.Riders {
+		share := PassengerFare{
+			RequestID:       rr.ID,
+			UserID:          rr.UserID,
+			Seats:           rr.SeatsNeeded,
+			SurgeMultiplier: SurgeMultiplierNone,
+			Unpriced:        rr.FareCents == nil,
+		}
+		if rr.FareCents != nil {
+			share.FareCents = *rr.FareCents
+		}
+		if rr.PoolDiscountCents != nil {
+			share.PoolDiscountCents = *rr.PoolDiscountCents
+		}
+		if rr.SurgeMultiplier != nil {
+			share.SurgeMultiplier = *rr.SurgeMultiplier
+		}
+
+		b.TotalFareCents += share.FareCents
+		b.PoolDiscountCents += share.PoolDiscountCents
+		surgeWeighted += float64(share.FareCents) * share.SurgeMultiplier
+		b.Shares = append(b.Shares, share)
+	}
+
+	if b.TotalFareCents > 0 {
+		b.SurgeMultiplier = math.Round(surgeWeighted/float64(b.TotalFareCents)*100) / 100
+		for i := range b.Shares {
+			b.Shares[i].SharePercent = math.Round(float64(b.Shares[i].FareCents)*1000/float64(b.TotalFareCents)) / 10
+		}
+	}
+	return b
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/shiva/hintro/internal/model"
+	"github.com/shiva/hintro/internal/repository"
+)
+
+func bookedRider(id int64, seats, fareCents, discountCents int, surge float64) model.RideRequest {
+	return model.RideRequest{
+		ID: id, UserID: id * 10, SeatsNeeded: seats,
+		FareCents: &fareCents, PoolDiscountCents: &discountCents, SurgeMultiplier: &surge,
+	}
+}
+
+func TestTripFare_TwoPassengersSum(t *testing.T) {
+	got := TripFare(&repository.TripFares{
+		TripID: 7,
+		Status: model.TripPlanned,
+		Riders: []model.RideRequest{
+			bookedRider(1, 1, 30000, 3000, 1.0),
+			bookedRider(2, 2, 45000, 5000, 1.5),
+		},
+	})
+
+	if got.TripID != 7 || got.Status != model.TripPlanned || got.Passengers != 2 {
+		t.Errorf("trip %d %s with %d passengers, want trip 7 planned with 2", got.TripID, got.Status, got.Passengers)
+	}
+	if got.TotalFareCents != 75000 || got.PoolDiscountCents != 8000 {
+		t.Errorf("total %d discount %d, want 75000 and 8000", got.TotalFareCents, got.PoolDiscountCents)
+	}
+	sum, percent := 0, 0.0
+	for _, s := range got.Shares {
+		sum += s.FareCents
+		percent += s.SharePercent
+	}
+	if sum != got.TotalFareCents || percent != 100 {
+		t.Errorf("shares sum to %d cents and %.1f%%, want %d and 100%%", sum, percent, got.TotalFareCents)
+	}
+	if got.Shares[0].SharePercent != 40 || got.Shares[1].SharePercent != 60 || got.Shares[1].Seats != 2 {
+		t.Errorf("shares = %+v, want 40%% then 60%% (2 seats)", got.Shares)
+	}
+	// (30000×1.0 + 45000×1.5) / 75000 = 1.3.
+	if got.SurgeMultiplier != 1.3 {
+		t.Errorf("surge = %v, want fare-weighted 1.3", got.SurgeMultiplier)
+	}
+}
+
+func TestTripFare_NoPassengers(t *testing.T) {
+	got := TripFare(&repository.TripFares{TripID: 8, Status: model.TripPlanned})
+
+	if got.Passengers != 0 || got.TotalFareCents != 0 || got.SurgeMultiplier != SurgeMultiplierNone {
+		t.Errorf("got %+v, want 0 passengers totalling 0 at 1.0x", got)
+	}
+	if got.Shares == nil || len(got.Shares) != 0 {
+		t.Errorf("shares = %#v, want empty, not nil", got.Shares)
+	}
+}
+
+func TestTripFare_UnpricedRiderCountsZero(t *testing.T) {
+	got := TripFare(&repository.TripFares{
+		TripID: 9,
+		Riders: []model.RideRequest{bookedRider(1, 1, 20000, 0, 1.2), {ID: 2, SeatsNeeded: 1}},
+	})
+
+	if got.TotalFareCents != 20000 || got.Passengers != 2 || got.SurgeMultiplier != 1.2 {
+		t.Errorf("got %+v, want 20000 from 2 passengers at 1.2x", got)
+	}
+	if s := got.Shares[1]; !s.Unpriced || s.FareCents != 0 || s.SharePercent != 0 {
+		t.Errorf("unpriced share = %+v, want flagged with 0 cents", s)
+	}
+}
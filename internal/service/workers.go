@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// ─── Background Worker Lifecycle ────────────────────────────
+
+// Worker is a background job that runs until its context is cancelled.
+// OutboxRelay, ExpirySweeper, ScheduleActivator and TripReconciler are Workers.
+type Worker interface {
+	Run(ctx context.Context)
+}
+
+// Workers runs background Workers under one context and lets shutdown
+// wait for all of them to return, so none is cut off mid-write when the
+// process exits.
+type Workers struct {
+	ctx  context.Context
+	stop context.CancelFunc
+	wg   sync.WaitGroup
+}
+
+// NewWorkers returns a Workers whose jobs stop when parent is cancelled
+// or Stop is called.
+func NewWorkers(parent context.Context) *Workers {
+	ctx, stop := context.WithCancel(parent)
+	return &Workers{ctx: ctx, stop: stop}
+}
+
+// Go starts w in its own goroutine. name is only used for logging.
+func (ws *Workers) Go(name string, w Worker) {
+	ws.wg.Add(1)
+	go func() {
+		defer ws.wg.Done()
+		w.Run(ws.ctx)
+		log.Printf("[workers] %s drained", name)
+	}()
+}
+
+// Stop cancels every worker's context and waits until all have returned
+// or ctx is done, whichever is first. It returns ctx.Err() if workers were
+// still running when ctx ended.
+func (ws *Workers) Stop(ctx context.Context) error {
+	ws.stop()
+
+	done := make(chan struct{})
+	go func() {
+		ws.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// blockingWorker runs until cancelled, then takes drain to clean up.
+type blockingWorker struct {
+	started chan struct{}
+	drain   time.Duration
+	stopped atomic.Bool
+}
+
+func (w *blockingWorker) Run(ctx context.Context) {
+	close(w.started)
+	<-ctx.Done()
+	time.Sleep(w.drain)
+	w.stopped.Store(true)
+}
+
+func TestWorkers_StopWaitsForCancelledWorkers(t *testing.T) {
+	ws := NewWorkers(context.Background())
+	a := &blockingWorker{started: make(chan struct{}), drain: 20 * time.Millisecond}
+	b := &blockingWorker{started: make(chan struct{})}
+	ws.Go("a", a)
+	ws.Go("b", b)
+	<-a.started
+	<-b.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ws.Stop(ctx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if !a.stopped.Load() || !b.stopped.Load() {
+		t.Errorf("stopped = a:%v b:%v, want both workers returned before Stop", a.stopped.Load(), b.stopped.Load())
+	}
+}
+
+func TestWorkers_ParentCancellationReachesWorkers(t *testing.T) {
+	parent, cancelParent := context.WithCancel(context.Background())
+	ws := NewWorkers(parent)
+	w := &blockingWorker{started: make(chan struct{})}
+	ws.Go("w", w)
+	<-w.started
+
+	// SIGTERM cancels the parent; the worker must observe it on its own.
+	cancelParent()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ws.Stop(ctx); err != nil || !w.stopped.Load() {
+		t.Errorf("Stop = %v, stopped = %v; want nil and the worker returned", err, w.stopped.Load())
+	}
+}
+
+func TestWorkers_StopGivesUpAtDeadline(t *testing.T) {
+	ws := NewWorkers(context.Background())
+	w := &blockingWorker{started: make(chan struct{}), drain: 200 * time.Millisecond}
+	ws.Go("slow", w)
+	<-w.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ws.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Stop = %v, want context.DeadlineExceeded while the worker drains", err)
+	}
+}
@@ -0,0 +1,10 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Transactional Outbox
+-- Migration: 002_create_outbox (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+DROP TABLE IF EXISTS outbox;
+
+COMMIT;
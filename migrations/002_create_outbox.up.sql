@@ -0,0 +1,29 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Transactional Outbox
+-- Migration: 002_create_outbox (UP)
+-- ============================================================
+
+BEGIN;
+
+-- ── Outbox ──────────────────────────────────────────────────
+-- Domain events written in the SAME transaction as the state change
+-- they describe. A background relay publishes unsent rows and stamps
+-- sent_at, so no event is lost if the process crashes mid-flight.
+CREATE TABLE outbox (
+    id                  BIGSERIAL           PRIMARY KEY,
+    event_type          VARCHAR(50)         NOT NULL,   -- ride_created, ride_booked, ...
+    aggregate_type      VARCHAR(50)         NOT NULL,   -- ride_request, trip
+    aggregate_id        BIGINT              NOT NULL,
+    payload             JSONB               NOT NULL DEFAULT '{}'::jsonb,
+    created_at          TIMESTAMPTZ         NOT NULL DEFAULT NOW(),
+    sent_at             TIMESTAMPTZ                     -- NULL until relayed.
+);
+
+-- Relay scan: "oldest unsent events first". Partial index stays small
+-- because sent rows drop out of it.
+CREATE INDEX idx_outbox_unsent ON outbox (id) WHERE sent_at IS NULL;
+
+-- Per-aggregate history lookups.
+CREATE INDEX idx_outbox_aggregate ON outbox (aggregate_type, aggregate_id, created_at);
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Request Expiry
+-- Migration: 003_add_expired_status (DOWN / Rollback)
+-- ============================================================
+-- PostgreSQL cannot drop a value from an ENUM type. We fold expired
+-- requests back into 'cancelled'; the 'expired' label stays defined
+-- but unused.
+
+BEGIN;
+
+UPDATE ride_requests SET status = 'cancelled' WHERE status = 'expired';
+
+COMMIT;
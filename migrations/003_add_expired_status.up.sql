@@ -0,0 +1,13 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Request Expiry
+-- Migration: 003_add_expired_status (UP)
+-- ============================================================
+-- PENDING requests that never match are moved to 'expired' by the
+-- expiry sweeper. Demand counts and matching queries only look at
+-- 'pending', so expired requests drop out of both automatically.
+
+BEGIN;
+
+ALTER TYPE request_status ADD VALUE IF NOT EXISTS 'expired';
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Cab Soft Delete
+-- Migration: 004_soft_delete_cabs (DOWN / Rollback)
+-- ============================================================
+-- Soft-deleted cabs stay 'offline' after rollback.
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_cabs_active_status;
+ALTER TABLE cabs DROP COLUMN IF EXISTS deleted_at;
+
+COMMIT;
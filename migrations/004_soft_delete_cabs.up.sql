@@ -0,0 +1,17 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Cab Soft Delete
+-- Migration: 004_soft_delete_cabs (UP)
+-- ============================================================
+-- Cabs are never hard-deleted: trips (and through them, ride history)
+-- reference cabs(id) ON DELETE CASCADE, so a DELETE would wipe history.
+-- DELETE /api/v1/cabs/{id} stamps deleted_at instead and takes the cab
+-- offline; nearby-cab and supply queries skip deleted rows.
+
+BEGIN;
+
+ALTER TABLE cabs ADD COLUMN deleted_at TIMESTAMPTZ;   -- NULL = active.
+
+-- Active cabs only; deleted rows drop out of the index.
+CREATE INDEX idx_cabs_active_status ON cabs (status) WHERE deleted_at IS NULL;
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Cab Location Timestamp
+-- Migration: 005_cab_location_timestamp (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE cabs DROP COLUMN IF EXISTS location_updated_at;
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Cab Location Timestamp
+-- Migration: 005_cab_location_timestamp (UP)
+-- ============================================================
+-- Telematics reports can arrive out of order. location_updated_at holds
+-- the device timestamp of the stored fix; bulk ingestion only overwrites
+-- current_location with a strictly newer report.
+
+BEGIN;
+
+ALTER TABLE cabs ADD COLUMN location_updated_at TIMESTAMPTZ;   -- NULL = never reported.
+
+COMMIT;
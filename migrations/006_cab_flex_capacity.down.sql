@@ -0,0 +1,10 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Flex Cab Capacity
+-- Migration: 006_cab_flex_capacity (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE cabs DROP COLUMN IF EXISTS flex_capacity;
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Flex Cab Capacity
+-- Migration: 006_cab_flex_capacity (UP)
+-- ============================================================
+-- Cabs with folding seats trade seat space for luggage. flex_capacity is
+-- the number of units shared between seats and bags (one each); booking
+-- and matching enforce seats + luggage <= flex_capacity on top of
+-- seat_capacity and luggage_capacity. NULL = fixed-capacity cab.
+
+BEGIN;
+
+ALTER TABLE cabs ADD COLUMN flex_capacity SMALLINT
+    CHECK (flex_capacity IS NULL OR flex_capacity BETWEEN 1 AND 18);
+
+COMMIT;
@@ -0,0 +1,13 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Fare Snapshot
+-- Migration: 007_ride_request_fare_snapshot (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE ride_requests
+    DROP COLUMN IF EXISTS fare_cents,
+    DROP COLUMN IF EXISTS pool_discount_cents,
+    DROP COLUMN IF EXISTS surge_multiplier;
+
+COMMIT;
@@ -0,0 +1,16 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Fare Snapshot
+-- Migration: 007_ride_request_fare_snapshot (UP)
+-- ============================================================
+-- The fare a rider agreed to is recorded at booking time, in the same
+-- transaction that matches the request, so disputes can be checked
+-- against the surge in effect then. All NULL until the request is booked.
+
+BEGIN;
+
+ALTER TABLE ride_requests
+    ADD COLUMN fare_cents          INTEGER       CHECK (fare_cents >= 0),
+    ADD COLUMN pool_discount_cents INTEGER       CHECK (pool_discount_cents >= 0),
+    ADD COLUMN surge_multiplier    NUMERIC(4, 2) CHECK (surge_multiplier >= 1);
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Geography Index on Request Origin
+-- Migration: 008_ride_request_origin_geography_index (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_ride_requests_origin_geog_gist;
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Geography Index on Request Origin
+-- Migration: 008_ride_request_origin_geography_index (UP)
+-- ============================================================
+-- The proximity queries compare origin::geography so radii are in meters.
+-- idx_ride_requests_origin_gist is on the geometry column and cannot serve
+-- that cast, so they fell back to sequential scans. An expression index on
+-- the cast lets ST_DWithin(origin::geography, ...) use GIST again.
+
+BEGIN;
+
+CREATE INDEX idx_ride_requests_origin_geog_gist
+    ON ride_requests USING GIST ((origin::geography));
+
+COMMIT;
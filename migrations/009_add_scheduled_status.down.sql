@@ -0,0 +1,13 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Scheduled Rides
+-- Migration: 009_add_scheduled_status (DOWN / Rollback)
+-- ============================================================
+-- PostgreSQL cannot drop a value from an ENUM type. Waiting requests are
+-- released into the live pool as 'pending'; the 'scheduled' label stays
+-- defined but unused.
+
+BEGIN;
+
+UPDATE ride_requests SET status = 'pending' WHERE status = 'scheduled';
+
+COMMIT;
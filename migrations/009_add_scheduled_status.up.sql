@@ -0,0 +1,14 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Scheduled Rides
+-- Migration: 009_add_scheduled_status (UP)
+-- ============================================================
+-- Requests with a scheduled_at beyond the matching lead time are created
+-- as 'scheduled' and moved to 'pending' by the schedule activator once
+-- their window opens. Matching and demand counts only look at 'pending',
+-- so scheduled requests stay out of the live pool until then.
+
+BEGIN;
+
+ALTER TYPE request_status ADD VALUE IF NOT EXISTS 'scheduled';
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Accessibility
+-- Migration: 010_accessibility (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE ride_requests DROP COLUMN IF EXISTS requires_accessible;
+ALTER TABLE cabs DROP COLUMN IF EXISTS accessible;
+
+COMMIT;
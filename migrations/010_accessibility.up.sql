@@ -0,0 +1,14 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Accessibility
+-- Migration: 010_accessibility (UP)
+-- ============================================================
+-- Cabs equipped for wheelchairs are flagged accessible. A ride request
+-- with requires_accessible may only be matched, booked or reassigned to a
+-- trip on such a cab; other requests may ride in either kind.
+
+BEGIN;
+
+ALTER TABLE cabs ADD COLUMN accessible BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE ride_requests ADD COLUMN requires_accessible BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
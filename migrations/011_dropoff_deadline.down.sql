@@ -0,0 +1,11 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Drop-off Deadline
+-- Migration: 011_dropoff_deadline (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE ride_requests DROP CONSTRAINT IF EXISTS ride_requests_deadline_from_airport;
+ALTER TABLE ride_requests DROP COLUMN IF EXISTS deadline_at;
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Drop-off Deadline
+-- Migration: 011_dropoff_deadline (UP)
+-- ============================================================
+-- A from_airport rider may give the latest time they must be dropped off
+-- (e.g. a meeting). Matching refuses any trip whose projected drop-off
+-- for them, or for a rider already on it, would land after the deadline.
+
+BEGIN;
+
+ALTER TABLE ride_requests ADD COLUMN deadline_at TIMESTAMPTZ;
+ALTER TABLE ride_requests ADD CONSTRAINT ride_requests_deadline_from_airport
+    CHECK (deadline_at IS NULL OR direction = 'from_airport');
+
+COMMIT;
@@ -0,0 +1,11 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Client Request ID
+-- Migration: 012_client_request_id (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+DROP INDEX IF EXISTS idx_ride_requests_user_client_request;
+ALTER TABLE ride_requests DROP COLUMN IF EXISTS client_request_id;
+
+COMMIT;
@@ -0,0 +1,17 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Client Request ID
+-- Migration: 012_client_request_id (UP)
+-- ============================================================
+-- Clients may tag a ride request with a UUID of their own so a retried
+-- POST /rides returns the request the first attempt created instead of
+-- making a duplicate. The ID is unique per user; untagged requests are
+-- left out of the index.
+
+BEGIN;
+
+ALTER TABLE ride_requests ADD COLUMN client_request_id UUID;
+CREATE UNIQUE INDEX idx_ride_requests_user_client_request
+    ON ride_requests (user_id, client_request_id)
+    WHERE client_request_id IS NOT NULL;
+
+COMMIT;
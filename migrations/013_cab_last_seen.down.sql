@@ -0,0 +1,10 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Cab Last Seen
+-- Migration: 013_cab_last_seen (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE cabs DROP COLUMN IF EXISTS last_seen_at;
+
+COMMIT;
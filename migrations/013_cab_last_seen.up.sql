@@ -0,0 +1,14 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Cab Last Seen
+-- Migration: 013_cab_last_seen (UP)
+-- ============================================================
+-- last_seen_at is when the server last heard from a cab: every location
+-- push stamps it, even one rejected as out of order. A cab silent for
+-- longer than MATCH_CAB_STALE_AFTER is treated as offline by dispatch and
+-- supply counts. Existing and newly registered cabs start as seen now.
+
+BEGIN;
+
+ALTER TABLE cabs ADD COLUMN last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+COMMIT;
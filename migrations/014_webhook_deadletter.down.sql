@@ -0,0 +1,10 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Webhook Dead Letters
+-- Migration: 014_webhook_deadletter (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+DROP TABLE IF EXISTS webhook_deadletter;
+
+COMMIT;
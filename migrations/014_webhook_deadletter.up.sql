@@ -0,0 +1,24 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Webhook Dead Letters
+-- Migration: 014_webhook_deadletter (UP)
+-- ============================================================
+-- An outbox event the webhook refused OUTBOX_WEBHOOK_MAX_ATTEMPTS times
+-- in a row is parked here with the last error, and its outbox row is
+-- stamped sent so the relay moves on. An operator replays it with
+-- POST /api/v1/admin/webhooks/replay/{id}, which stamps replayed_at.
+
+BEGIN;
+
+CREATE TABLE webhook_deadletter (
+    id                  BIGSERIAL           PRIMARY KEY,
+    event_id            BIGINT              NOT NULL REFERENCES outbox (id),
+    attempts            INT                 NOT NULL CHECK (attempts > 0),
+    last_error          TEXT                NOT NULL,
+    created_at          TIMESTAMPTZ         NOT NULL DEFAULT NOW(),
+    replayed_at         TIMESTAMPTZ                     -- NULL until a replay succeeds.
+);
+
+-- Operators look for what is still waiting to be replayed.
+CREATE INDEX idx_webhook_deadletter_pending ON webhook_deadletter (id) WHERE replayed_at IS NULL;
+
+COMMIT;
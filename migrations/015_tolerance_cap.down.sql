@@ -0,0 +1,13 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Tolerance Cap
+-- Migration: 015_tolerance_cap (DOWN / Rollback)
+-- ============================================================
+-- Clamped rows keep their 7500 m; the original values are not restored.
+
+BEGIN;
+
+ALTER TABLE ride_requests DROP CONSTRAINT IF EXISTS ride_requests_tolerance_meters_check;
+ALTER TABLE ride_requests ADD CONSTRAINT ride_requests_tolerance_meters_check
+    CHECK (tolerance_meters BETWEEN 0 AND 10000);
+
+COMMIT;
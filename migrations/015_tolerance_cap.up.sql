@@ -0,0 +1,19 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Tolerance Cap
+-- Migration: 015_tolerance_cap (UP)
+-- ============================================================
+-- tolerance_meters is the radius of every candidate search for a request.
+-- The original CHECK allowed up to 10 km, past the 7.5 km a rider can use
+-- (a 15-minute detour, service.MaxToleranceMeters). Existing rows above
+-- that are clamped before the tighter CHECK goes on; the server may lower
+-- the cap further with RIDE_MAX_TOLERANCE_METERS.
+
+BEGIN;
+
+UPDATE ride_requests SET tolerance_meters = 7500 WHERE tolerance_meters > 7500;
+
+ALTER TABLE ride_requests DROP CONSTRAINT ride_requests_tolerance_meters_check;
+ALTER TABLE ride_requests ADD CONSTRAINT ride_requests_tolerance_meters_check
+    CHECK (tolerance_meters BETWEEN 0 AND 7500);
+
+COMMIT;
@@ -0,0 +1,10 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Outbox Claims
+-- Migration: 016_outbox_claim (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE outbox DROP COLUMN IF EXISTS claimed_until;
+
+COMMIT;
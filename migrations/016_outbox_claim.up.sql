@@ -0,0 +1,15 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Outbox Claims
+-- Migration: 016_outbox_claim (UP)
+-- ============================================================
+-- The relay claims a batch of unsent events by stamping claimed_until
+-- and commits before publishing them, so no row lock is held while the
+-- webhook is called. Other relays skip claimed rows until the claim runs
+-- out; a relay that dies mid-batch leaves its events to be picked up
+-- again then.
+
+BEGIN;
+
+ALTER TABLE outbox ADD COLUMN claimed_until TIMESTAMPTZ;   -- NULL when unclaimed.
+
+COMMIT;
@@ -0,0 +1,12 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Outbox Publish Attempts
+-- Migration: 017_outbox_attempts (DOWN / Rollback)
+-- ============================================================
+
+BEGIN;
+
+ALTER TABLE outbox
+    DROP COLUMN IF EXISTS last_error,
+    DROP COLUMN IF EXISTS attempts;
+
+COMMIT;
@@ -0,0 +1,15 @@
+-- ============================================================
+-- Smart Airport Ride Pooling — Outbox Publish Attempts
+-- Migration: 017_outbox_attempts (UP)
+-- ============================================================
+-- The relay counts failed publishes on the outbox row itself, so the
+-- count towards OUTBOX_WEBHOOK_MAX_ATTEMPTS survives a restart and is
+-- shared by relays on every replica.
+
+BEGIN;
+
+ALTER TABLE outbox
+    ADD COLUMN attempts   INT  NOT NULL DEFAULT 0 CHECK (attempts >= 0),   -- Failed publishes so far.
+    ADD COLUMN last_error TEXT;                                            -- Why the latest one failed.
+
+COMMIT;
@@ -0,0 +1,62 @@
+// Package buildinfo reports which build of the server is running. The
+// values are stamped in at link time, e.g.
+//
+//	go build -ldflags "\
+//	  -X github.com/shiva/hintro/pkg/buildinfo.Version=v1.4.0 \
+//	  -X github.com/shiva/hintro/pkg/buildinfo.Commit=$(git rev-parse HEAD) \
+//	  -X github.com/shiva/hintro/pkg/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)" \
+//	  ./cmd/server
+//
+// Unstamped (dev) builds fall back to the VCS data the Go toolchain
+// embeds, then to "unknown".
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Set with -ldflags "-X". Left empty, Get falls back to embedded VCS data.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Info describes the running binary.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// Get returns the stamped build info, filling gaps from debug.ReadBuildInfo.
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildTime == "":
+				info.BuildTime = s.Value
+			}
+		}
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+	if info.Commit == "" {
+		info.Commit = "unknown"
+	}
+	if info.BuildTime == "" {
+		info.BuildTime = "unknown"
+	}
+	return info
+}
@@ -0,0 +1,364 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/pkg/metrics"
+)
+
+// ErrBreakerOpen is the error a Guarded command carries when the breaker
+// short-circuited it without touching Redis. Callers treat it like any
+// other cache miss and fall back to the database.
+var ErrBreakerOpen = errors.New("redis circuit breaker open")
+
+// ErrDeadlineTooClose is the error a Guarded command carries when its
+// context had less than MinCallBudget left, so it was not sent. Like
+// ErrBreakerOpen, callers treat it as a miss and go to the database.
+var ErrDeadlineTooClose = errors.New("redis: context deadline too close")
+
+// MinCallBudget is the least time a context must have left for a Guarded
+// command to reach Redis. A request about to run out of time spends what
+// it has on the database rather than on a cache round trip.
+const MinCallBudget = 5 * time.Millisecond
+
+// BreakerState is the state of a Breaker. Its integer value is what the
+// cache_breaker_state metric reports.
+type BreakerState int
+
+const (
+	BreakerClosed   BreakerState = iota // Calls go to Redis.
+	BreakerOpen                         // Calls are short-circuited until the cooldown ends.
+	BreakerHalfOpen                     // One probe call is in flight; the rest are short-circuited.
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// Breaker is a consecutive-failure circuit breaker. After threshold
+// failures in a row it opens and rejects calls for cooldown, then lets a
+// single probe through: success closes it, failure re-opens it.
+//
+// A Redis that is slow rather than down would otherwise cost every caller
+// the full read timeout; an open breaker costs nothing.
+type Breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+	gauge     *expvar.Int
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+// NewBreaker creates a closed breaker whose state is published under name
+// in metrics.CacheBreakerState. A threshold ≤ 0 disables it: every call is
+// allowed.
+func NewBreaker(name string, threshold int, cooldown time.Duration) *Breaker {
+	gauge := new(expvar.Int)
+	metrics.CacheBreakerState.Set(name, gauge)
+	return &Breaker{name: name, threshold: threshold, cooldown: cooldown, now: time.Now, gauge: gauge}
+}
+
+// State returns the breaker's current state.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a call may go to Redis. Once the cooldown has
+// passed, the first caller is let through as the half-open probe.
+func (b *Breaker) Allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.setState(BreakerHalfOpen)
+		return true
+	case BreakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+// Record reports the outcome of an allowed call. A miss (redis.Nil) and a
+// caller giving up (context.Canceled) say nothing about Redis health and
+// count as success.
+func (b *Breaker) Record(err error) {
+	if b.threshold <= 0 {
+		return
+	}
+	failed := err != nil && !errors.Is(err, redis.Nil) && !errors.Is(err, context.Canceled)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !failed {
+		b.failures = 0
+		if b.state != BreakerClosed {
+			log.Printf("[cache] %s breaker closed", b.name)
+			b.setState(BreakerClosed)
+		}
+		return
+	}
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		log.Printf("[cache] %s breaker open for %s after %d consecutive failures (last: %v)",
+			b.name, b.cooldown, b.failures, err)
+		b.openedAt = b.now()
+		b.setState(BreakerOpen)
+	}
+}
+
+// setState updates the state and its gauge. Callers hold b.mu.
+func (b *Breaker) setState(s BreakerState) {
+	b.state = s
+	b.gauge.Set(int64(s))
+}
+
+// ─── Guarded client ─────────────────────────────────────────
+
+// Commands is the subset of *redis.Client that Guarded wraps.
+type Commands interface {
+	Get(ctx context.Context, key string) *redis.StringCmd
+	Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd
+	Del(ctx context.Context, keys ...string) *redis.IntCmd
+	IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd
+	MGet(ctx context.Context, keys ...string) *redis.SliceCmd
+	MSet(ctx context.Context, values ...interface{}) *redis.StatusCmd
+	SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd
+	SMembers(ctx context.Context, key string) *redis.StringSliceCmd
+}
+
+// Guarded runs Commands through a Breaker. While the breaker is open each
+// command returns immediately with ErrBreakerOpen instead of waiting on
+// Redis; a command whose context is nearly out of time returns
+// ErrDeadlineTooClose the same way.
+//
+// A Del that does not reach Redis is not lost: its keys are queued and
+// deleted before the next command that does, and a command is not sent
+// until that succeeds. So once Redis is back no read can return an entry
+// an invalidation skipped while it was away. If more than
+// MaxPendingDeletes keys pile up the queue is dropped, and reads miss
+// until every entry it may have held has expired (the longest TTL Set
+// was given).
+type Guarded struct {
+	client  Commands
+	breaker *Breaker
+
+	flushing sync.Mutex // Held while the queued deletes are sent.
+
+	mu            sync.Mutex
+	pending       map[string]struct{} // Keys a skipped or failed Del left behind.
+	maxTTL        time.Duration       // Longest expiration passed to Set.
+	readsOffUntil time.Time           // After the queue overflowed: reads miss until then.
+}
+
+// MaxPendingDeletes caps the keys Guarded queues for deletion while Redis
+// is unreachable.
+const MaxPendingDeletes = 10_000
+
+// ErrDeletesPending is the error a Guarded read carries when it cannot
+// rule out returning an entry a queued delete has not removed yet.
+// Callers treat it as a miss.
+var ErrDeletesPending = errors.New("redis: invalidations pending")
+
+// Guard wraps client with breaker.
+func Guard(client Commands, breaker *Breaker) *Guarded {
+	return &Guarded{client: client, breaker: breaker}
+}
+
+// skip returns why a command must not reach Redis, or nil. The deadline is
+// checked first because Allow may hand out the half-open probe, which then
+// has to be sent.
+func (g *Guarded) skip(ctx context.Context) error {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < MinCallBudget {
+		return ErrDeadlineTooClose
+	}
+	if !g.breaker.Allow() {
+		return ErrBreakerOpen
+	}
+	return nil
+}
+
+// prepare is skip followed by sending any queued deletes; a command goes
+// to Redis only if it returns nil.
+func (g *Guarded) prepare(ctx context.Context) error {
+	if err := g.skip(ctx); err != nil {
+		return err
+	}
+	return g.flush(ctx)
+}
+
+// prepareRead is prepare for a read, which also misses while an
+// overflowed queue's entries may still be live.
+func (g *Guarded) prepareRead(ctx context.Context) error {
+	g.mu.Lock()
+	off := g.breaker.now().Before(g.readsOffUntil)
+	g.mu.Unlock()
+	if off {
+		return ErrDeletesPending
+	}
+	return g.prepare(ctx)
+}
+
+// queue adds keys to the pending deletes, dropping the queue if it grows
+// past MaxPendingDeletes.
+func (g *Guarded) queue(keys []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.pending == nil {
+		g.pending = make(map[string]struct{}, len(keys))
+	}
+	for _, k := range keys {
+		g.pending[k] = struct{}{}
+	}
+	if len(g.pending) > MaxPendingDeletes {
+		log.Printf("[cache] %s: %d deletes queued while Redis was unreachable; reads miss for %s",
+			g.breaker.name, len(g.pending), g.maxTTL)
+		g.pending = nil
+		g.readsOffUntil = g.breaker.now().Add(g.maxTTL)
+	}
+}
+
+// flush deletes the queued keys. They stay queued if Redis refuses.
+func (g *Guarded) flush(ctx context.Context) error {
+	g.mu.Lock()
+	n := len(g.pending)
+	g.mu.Unlock()
+	if n == 0 {
+		return nil
+	}
+
+	g.flushing.Lock()
+	defer g.flushing.Unlock()
+	g.mu.Lock()
+	keys := make([]string, 0, len(g.pending))
+	for k := range g.pending {
+		keys = append(keys, k)
+	}
+	g.mu.Unlock()
+	if len(keys) == 0 {
+		return nil // Another caller flushed them.
+	}
+
+	err := g.client.Del(ctx, keys...).Err()
+	g.breaker.Record(err)
+	if err != nil {
+		return err
+	}
+	g.mu.Lock()
+	for _, k := range keys {
+		delete(g.pending, k)
+	}
+	g.mu.Unlock()
+	return nil
+}
+
+// Get runs GET unless prepareRead says not to.
+func (g *Guarded) Get(ctx context.Context, key string) *redis.StringCmd {
+	if err := g.prepareRead(ctx); err != nil {
+		return redis.NewStringResult("", err)
+	}
+	cmd := g.client.Get(ctx, key)
+	g.breaker.Record(cmd.Err())
+	return cmd
+}
+
+// Set runs SET unless prepare says not to.
+func (g *Guarded) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	g.mu.Lock()
+	g.maxTTL = max(g.maxTTL, expiration)
+	g.mu.Unlock()
+	if err := g.prepare(ctx); err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	cmd := g.client.Set(ctx, key, value, expiration)
+	g.breaker.Record(cmd.Err())
+	return cmd
+}
+
+// Del runs DEL, along with any deletes queued before it. If it cannot
+// reach Redis the keys are queued for the next command that can. Its
+// count is len(keys), not how many of them existed.
+func (g *Guarded) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	g.queue(keys)
+	if err := g.prepare(ctx); err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	return redis.NewIntResult(int64(len(keys)), nil)
+}
+
+// IncrBy runs INCRBY unless prepare says not to.
+func (g *Guarded) IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd {
+	if err := g.prepare(ctx); err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	cmd := g.client.IncrBy(ctx, key, value)
+	g.breaker.Record(cmd.Err())
+	return cmd
+}
+
+// MGet runs MGET unless prepareRead says not to.
+func (g *Guarded) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	if err := g.prepareRead(ctx); err != nil {
+		return redis.NewSliceResult(nil, err)
+	}
+	cmd := g.client.MGet(ctx, keys...)
+	g.breaker.Record(cmd.Err())
+	return cmd
+}
+
+// MSet runs MSET unless prepare says not to.
+func (g *Guarded) MSet(ctx context.Context, values ...interface{}) *redis.StatusCmd {
+	if err := g.prepare(ctx); err != nil {
+		return redis.NewStatusResult("", err)
+	}
+	cmd := g.client.MSet(ctx, values...)
+	g.breaker.Record(cmd.Err())
+	return cmd
+}
+
+// SAdd runs SADD unless prepare says not to.
+func (g *Guarded) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	if err := g.prepare(ctx); err != nil {
+		return redis.NewIntResult(0, err)
+	}
+	cmd := g.client.SAdd(ctx, key, members...)
+	g.breaker.Record(cmd.Err())
+	return cmd
+}
+
+// SMembers runs SMEMBERS unless prepareRead says not to.
+func (g *Guarded) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	if err := g.prepareRead(ctx); err != nil {
+		return redis.NewStringSliceResult(nil, err)
+	}
+	cmd := g.client.SMembers(ctx, key)
+	g.breaker.Record(cmd.Err())
+	return cmd
+}
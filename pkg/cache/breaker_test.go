@@ -0,0 +1,285 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/shiva/hintro/pkg/metrics"
+)
+
+// slowRedis fails every command with a timeout while down is set, and
+// counts how many commands actually reached it.
+type slowRedis struct {
+	down  bool
+	calls int
+	ops   []string // GET and DEL commands that reached it, in order.
+}
+
+func (s *slowRedis) result() error {
+	s.calls++
+	if s.down {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+func (s *slowRedis) Get(ctx context.Context, key string) *redis.StringCmd {
+	s.ops = append(s.ops, "GET "+key)
+	if err := s.result(); err != nil {
+		return redis.NewStringResult("", err)
+	}
+	return redis.NewStringResult("", redis.Nil)
+}
+
+func (s *slowRedis) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	return redis.NewStatusResult("OK", s.result())
+}
+
+func (s *slowRedis) Del(ctx context.Context, keys ...string) *redis.IntCmd {
+	sorted := slices.Clone(keys)
+	slices.Sort(sorted)
+	s.ops = append(s.ops, "DEL "+strings.Join(sorted, " "))
+	return redis.NewIntResult(int64(len(keys)), s.result())
+}
+
+func (s *slowRedis) IncrBy(ctx context.Context, key string, value int64) *redis.IntCmd {
+	return redis.NewIntResult(value, s.result())
+}
+
+func (s *slowRedis) MGet(ctx context.Context, keys ...string) *redis.SliceCmd {
+	return redis.NewSliceResult(make([]interface{}, len(keys)), s.result())
+}
+
+func (s *slowRedis) MSet(ctx context.Context, values ...interface{}) *redis.StatusCmd {
+	return redis.NewStatusResult("OK", s.result())
+}
+
+func (s *slowRedis) SAdd(ctx context.Context, key string, members ...interface{}) *redis.IntCmd {
+	return redis.NewIntResult(int64(len(members)), s.result())
+}
+
+func (s *slowRedis) SMembers(ctx context.Context, key string) *redis.StringSliceCmd {
+	return redis.NewStringSliceResult(nil, s.result())
+}
+
+// newTestBreaker returns a breaker on a manual clock advanced by the
+// returned func.
+func newTestBreaker(name string, threshold int, cooldown time.Duration) (*Breaker, func(time.Duration)) {
+	b := NewBreaker(name, threshold, cooldown)
+	now := time.Date(2025, 1, 1, 6, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	return b, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestBreaker_OpensAfterConsecutiveFailures(t *testing.T) {
+	rdb := &slowRedis{down: true}
+	b, _ := newTestBreaker("test_open", 3, 10*time.Second)
+	g := Guard(rdb, b)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := g.Get(ctx, "k").Err(); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("call %d: err = %v, want the Redis timeout", i+1, err)
+		}
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after 3 timeouts, want open", b.State())
+	}
+
+	// Open: commands short-circuit without reaching Redis.
+	if err := g.Get(ctx, "k").Err(); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Get while open: err = %v, want ErrBreakerOpen", err)
+	}
+	if err := g.Set(ctx, "k", 1, time.Minute).Err(); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Set while open: err = %v, want ErrBreakerOpen", err)
+	}
+	if rdb.calls != 3 {
+		t.Errorf("redis saw %d calls, want 3 (none while open)", rdb.calls)
+	}
+	if got := metrics.CacheBreakerState.Get("test_open").(*expvar.Int).Value(); got != int64(BreakerOpen) {
+		t.Errorf("cache_breaker_state = %d, want %d", got, BreakerOpen)
+	}
+}
+
+func TestBreaker_MissesAndSuccessesResetTheCount(t *testing.T) {
+	rdb := &slowRedis{}
+	b, _ := newTestBreaker("test_reset", 2, 10*time.Second)
+	g := Guard(rdb, b)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		rdb.down = true
+		_ = g.Get(ctx, "k")
+		rdb.down = false
+		_ = g.Get(ctx, "k") // redis.Nil: a miss, not a failure
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("state = %s, want closed (failures never consecutive)", b.State())
+	}
+}
+
+func TestBreaker_ProbeClosesOrReopens(t *testing.T) {
+	rdb := &slowRedis{down: true}
+	b, advance := newTestBreaker("test_probe", 1, 10*time.Second)
+	g := Guard(rdb, b)
+	ctx := context.Background()
+
+	_ = g.Get(ctx, "k")
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s, want open", b.State())
+	}
+
+	// Cooldown not over yet.
+	advance(9 * time.Second)
+	if b.Allow() {
+		t.Fatal("Allow() = true before the cooldown ended")
+	}
+
+	// Failed probe re-opens for a fresh cooldown.
+	advance(time.Second)
+	if err := g.Get(ctx, "k").Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("probe err = %v, want it to reach Redis", err)
+	}
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s after failed probe, want open", b.State())
+	}
+	advance(5 * time.Second)
+	if b.Allow() {
+		t.Fatal("Allow() = true halfway through the second cooldown")
+	}
+
+	// Successful probe closes it.
+	advance(5 * time.Second)
+	rdb.down = false
+	if !b.Allow() {
+		t.Fatal("Allow() = false after the cooldown, want the probe through")
+	}
+	if b.State() != BreakerHalfOpen || b.Allow() {
+		t.Fatalf("state = %s, want half-open with a single probe in flight", b.State())
+	}
+	b.Record(nil)
+	if b.State() != BreakerClosed {
+		t.Fatalf("state = %s after successful probe, want closed", b.State())
+	}
+	if err := g.Get(ctx, "k").Err(); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get after close: err = %v, want redis.Nil from Redis", err)
+	}
+	if got := metrics.CacheBreakerState.Get("test_probe").(*expvar.Int).Value(); got != int64(BreakerClosed) {
+		t.Errorf("cache_breaker_state = %d, want %d", got, BreakerClosed)
+	}
+}
+
+func TestBreaker_DisabledAlwaysAllows(t *testing.T) {
+	rdb := &slowRedis{down: true}
+	b, _ := newTestBreaker("test_disabled", 0, time.Second)
+	g := Guard(rdb, b)
+	for i := 0; i < 10; i++ {
+		_ = g.Del(context.Background(), "k")
+	}
+	if rdb.calls != 10 || b.State() != BreakerClosed {
+		t.Errorf("calls = %d, state = %s; want every call through and closed", rdb.calls, b.State())
+	}
+}
+
+func TestGuarded_NearDeadlineSkipsRedis(t *testing.T) {
+	rdb := &slowRedis{down: true}
+	b, advance := newTestBreaker("test_deadline", 1, 10*time.Second)
+	g := Guard(rdb, b)
+
+	ctx, cancel := context.WithTimeout(context.Background(), MinCallBudget/2)
+	defer cancel()
+	for _, err := range []error{
+		g.Get(ctx, "k").Err(), g.Set(ctx, "k", 1, time.Minute).Err(), g.Del(ctx, "k").Err(),
+	} {
+		if !errors.Is(err, ErrDeadlineTooClose) {
+			t.Errorf("err = %v, want ErrDeadlineTooClose", err)
+		}
+	}
+	if rdb.calls != 0 || b.State() != BreakerClosed {
+		t.Fatalf("calls = %d, state = %s; want Redis untouched and the breaker closed", rdb.calls, b.State())
+	}
+
+	// An open breaker's probe is not used up by a call that is skipped.
+	_ = g.Get(context.Background(), "k")
+	advance(10 * time.Second)
+	_ = g.Get(ctx, "k")
+	if b.State() != BreakerOpen {
+		t.Fatalf("state = %s, want open with the probe still available", b.State())
+	}
+	rdb.down = false
+	if err := g.Get(context.Background(), "k").Err(); !errors.Is(err, redis.Nil) {
+		t.Errorf("probe err = %v, want redis.Nil from Redis", err)
+	}
+	if b.State() != BreakerClosed {
+		t.Errorf("state = %s after the probe, want closed", b.State())
+	}
+}
+
+func TestGuarded_QueuesDeletesWhileOpen(t *testing.T) {
+	rdb := &slowRedis{down: true}
+	b, advance := newTestBreaker("test_queue", 1, 10*time.Second)
+	g := Guard(rdb, b)
+	ctx := context.Background()
+
+	_ = g.Get(ctx, "a")
+	if err := g.Del(ctx, "a", "b").Err(); !errors.Is(err, ErrBreakerOpen) {
+		t.Fatalf("Del while open: err = %v, want ErrBreakerOpen", err)
+	}
+
+	// Redis still down at the probe: the read is not sent ahead of the deletes.
+	advance(10 * time.Second)
+	rdb.ops = nil
+	if err := g.Get(ctx, "a").Err(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("probe err = %v, want the failed DEL's error", err)
+	}
+	if !slices.Equal(rdb.ops, []string{"DEL a b"}) {
+		t.Fatalf("redis saw %q, want only the queued DEL", rdb.ops)
+	}
+
+	// Back up: the queued deletes go first, once.
+	advance(10 * time.Second)
+	rdb.down, rdb.ops = false, nil
+	_ = g.Get(ctx, "a")
+	_ = g.Get(ctx, "b")
+	if want := []string{"DEL a b", "GET a", "GET b"}; !slices.Equal(rdb.ops, want) {
+		t.Errorf("redis saw %q, want %q", rdb.ops, want)
+	}
+}
+
+func TestGuarded_OverflowedQueueMissesUntilEntriesExpire(t *testing.T) {
+	rdb := &slowRedis{}
+	b, advance := newTestBreaker("test_overflow", 1, 10*time.Second)
+	g := Guard(rdb, b)
+	ctx := context.Background()
+	_ = g.Set(ctx, "k", 1, time.Minute)
+
+	rdb.down = true
+	_ = g.Get(ctx, "k")
+	keys := make([]string, MaxPendingDeletes+1)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	_ = g.Del(ctx, keys...)
+
+	advance(10 * time.Second)
+	rdb.down, rdb.ops = false, nil
+	if err := g.Get(ctx, "k").Err(); !errors.Is(err, ErrDeletesPending) {
+		t.Fatalf("Get after overflow: err = %v, want ErrDeletesPending", err)
+	}
+	if len(rdb.ops) != 0 {
+		t.Errorf("redis saw %q, want nothing", rdb.ops)
+	}
+
+	advance(time.Minute)
+	if err := g.Get(ctx, "k").Err(); !errors.Is(err, redis.Nil) {
+		t.Errorf("Get once entries expired: err = %v, want redis.Nil from Redis", err)
+	}
+}
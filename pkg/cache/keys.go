@@ -0,0 +1,21 @@
+package cache
+
+import "strings"
+
+// Namespace prefixes every Redis key this process writes (REDIS_KEY_PREFIX),
+// so several environments can share one Redis instance without their keys
+// colliding. Build keys with Key rather than by hand.
+type Namespace string
+
+// Key joins parts with ':' under the namespace:
+//
+//	Namespace("staging").Key("surge", "demand", "28.70:77.10") == "staging:surge:demand:28.70:77.10"
+//
+// An empty namespace leaves the key unprefixed.
+func (n Namespace) Key(parts ...string) string {
+	key := strings.Join(parts, ":")
+	if n == "" {
+		return key
+	}
+	return string(n) + ":" + key
+}
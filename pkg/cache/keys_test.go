@@ -0,0 +1,12 @@
+package cache
+
+import "testing"
+
+func TestNamespaceKey(t *testing.T) {
+	if got := Namespace("staging").Key("surge", "demand", "28.70:77.10"); got != "staging:surge:demand:28.70:77.10" {
+		t.Errorf("namespaced key = %q", got)
+	}
+	if got := Namespace("").Key("surge", "supply", "x"); got != "surge:supply:x" {
+		t.Errorf("unnamespaced key = %q", got)
+	}
+}
@@ -13,6 +13,9 @@ import (
 // NewRedisClient creates a Redis client with connection pooling.
 //
 // Pool is sized for high concurrency (default PoolSize = 100).
+// ContextTimeoutEnabled makes each command's socket deadline the earlier of
+// ReadTimeout/WriteTimeout and its context deadline, so a request with a
+// tighter deadline does not wait out the full 2s on a slow Redis.
 func NewRedisClient(ctx context.Context, cfg config.RedisConfig) (*redis.Client, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:         cfg.Addr(),
@@ -23,6 +26,8 @@ func NewRedisClient(ctx context.Context, cfg config.RedisConfig) (*redis.Client,
 		DialTimeout:  5 * time.Second,
 		ReadTimeout:  2 * time.Second,
 		WriteTimeout: 2 * time.Second,
+
+		ContextTimeoutEnabled: true,
 	})
 
 	// Verify connectivity.
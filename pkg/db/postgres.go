@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/exaring/otelpgx"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/shiva/hintro/config"
@@ -17,6 +20,9 @@ import (
 //   - MinConns: kept warm from config (default 10)
 //   - Health-check period: 30 s
 //   - Connect timeout: 5 s
+//   - timestamptz values scan in UTC (see useUTCTimestamps)
+//   - Every query is an OpenTelemetry span (a no-op unless tracing.Setup
+//     configured an exporter), named after its SQL verb
 func NewPostgresPool(ctx context.Context, cfg config.PostgresConfig) (*pgxpool.Pool, error) {
 	poolCfg, err := pgxpool.ParseConfig(cfg.DSN())
 	if err != nil {
@@ -28,6 +34,11 @@ func NewPostgresPool(ctx context.Context, cfg config.PostgresConfig) (*pgxpool.P
 	poolCfg.HealthCheckPeriod = 30 * time.Second
 	poolCfg.MaxConnLifetime = 1 * time.Hour
 	poolCfg.MaxConnIdleTime = 15 * time.Minute
+	poolCfg.ConnConfig.Tracer = otelpgx.NewTracer(otelpgx.WithTrimSQLInSpanName())
+	poolCfg.AfterConnect = func(_ context.Context, conn *pgx.Conn) error {
+		useUTCTimestamps(conn.TypeMap())
+		return nil
+	}
 
 	// Create the pool.
 	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
@@ -47,6 +58,17 @@ func NewPostgresPool(ctx context.Context, cfg config.PostgresConfig) (*pgxpool.P
 	return pool, nil
 }
 
+// useUTCTimestamps makes timestamptz columns scan into time.Time in UTC
+// rather than the process's local zone (pgx's default). The instant is the
+// same either way, but JSON marshals the zone: this way every created_at,
+// updated_at or scheduled_at read from the database is served as RFC 3339
+// with a Z suffix, whatever TZ the server runs under.
+func useUTCTimestamps(m *pgtype.Map) {
+	tz := &pgtype.Type{Name: "timestamptz", OID: pgtype.TimestamptzOID, Codec: &pgtype.TimestamptzCodec{ScanLocation: time.UTC}}
+	m.RegisterType(tz)
+	m.RegisterType(&pgtype.Type{Name: "_timestamptz", OID: pgtype.TimestamptzArrayOID, Codec: &pgtype.ArrayCodec{ElementType: tz}})
+}
+
 // HealthCheck pings the PostgreSQL pool and returns nil if healthy.
 func HealthCheck(ctx context.Context, pool *pgxpool.Pool) error {
 	pingCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
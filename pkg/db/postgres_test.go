@@ -0,0 +1,51 @@
+package db
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func TestUseUTCTimestamps_ScansInUTC(t *testing.T) {
+	// Run as if the server's zone were IST, the case that leaked offsets.
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.FixedZone("IST", 5*3600+1800)
+
+	m := pgtype.NewMap()
+	useUTCTimestamps(m)
+
+	var got time.Time
+	if err := m.Scan(pgtype.TimestamptzOID, pgtype.TextFormatCode, []byte("2025-01-01 15:30:00+05:30"), &got); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if got.Location() != time.UTC {
+		t.Errorf("scanned in %s, want UTC", got.Location())
+	}
+	raw, err := json.Marshal(struct {
+		CreatedAt time.Time `json:"created_at"`
+	}{got})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"created_at":"2025-01-01T10:00:00Z"}`; string(raw) != want {
+		t.Errorf("json = %s, want %s", raw, want)
+	}
+}
+
+func TestUseUTCTimestamps_ArrayElementsInUTC(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.FixedZone("IST", 5*3600+1800)
+
+	m := pgtype.NewMap()
+	useUTCTimestamps(m)
+
+	var got []time.Time
+	if err := m.Scan(pgtype.TimestamptzArrayOID, pgtype.TextFormatCode, []byte(`{"2025-01-01 15:30:00+05:30"}`), &got); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if len(got) != 1 || got[0].Location() != time.UTC {
+		t.Errorf("scanned %v, want one time in UTC", got)
+	}
+}
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SchemaVersion is the number of the newest migrations/NNN_*.up.sql file
+// this build was written against. Bump it with every new migration.
+const SchemaVersion = 17
+
+// ErrSchemaMismatch is returned by CheckSchemaVersion when the database
+// is missing migrations the build expects.
+var ErrSchemaMismatch = errors.New("database schema version mismatch")
+
+// rowQuerier is the QueryRow half of *pgxpool.Pool.
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// CheckSchemaVersion reads the newest migration recorded in
+// schema_migrations (written by entrypoint.sh) and compares it with want.
+// It returns the applied version, and an error wrapping ErrSchemaMismatch
+// if the database is behind. A database ahead of the build is fine:
+// migrations are applied before new code rolls out, so old instances
+// still serving must stay ready.
+func CheckSchemaVersion(ctx context.Context, q rowQuerier, want int) (int, error) {
+	queryCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	var applied int
+	err := q.QueryRow(queryCtx, `
+		SELECT COALESCE(MAX(split_part(filename, '_', 1)::int), 0)
+		FROM schema_migrations
+		WHERE filename ~ '^[0-9]+_.*\.up\.sql$'
+	`).Scan(&applied)
+	if err != nil {
+		return 0, fmt.Errorf("schema version: %w", err)
+	}
+	if applied < want {
+		return applied, fmt.Errorf("schema version: database at %d, build expects at least %d: %w", applied, want, ErrSchemaMismatch)
+	}
+	return applied, nil
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// fakeVersion answers the schema_migrations query with a fixed version.
+type fakeVersion struct {
+	version int
+	err     error
+}
+
+func (f fakeVersion) QueryRow(context.Context, string, ...any) pgx.Row { return f }
+
+func (f fakeVersion) Scan(dest ...any) error {
+	if f.err != nil {
+		return f.err
+	}
+	*dest[0].(*int) = f.version
+	return nil
+}
+
+func TestCheckSchemaVersion_Matching(t *testing.T) {
+	applied, err := CheckSchemaVersion(context.Background(), fakeVersion{version: 12}, 12)
+	if err != nil || applied != 12 {
+		t.Errorf("got (%d, %v), want (12, nil)", applied, err)
+	}
+}
+
+// Migrations run ahead of a rollout: instances of the previous build stay
+// ready against the newer schema.
+func TestCheckSchemaVersion_DatabaseAhead(t *testing.T) {
+	applied, err := CheckSchemaVersion(context.Background(), fakeVersion{version: 13}, 12)
+	if err != nil || applied != 13 {
+		t.Errorf("got (%d, %v), want (13, nil)", applied, err)
+	}
+}
+
+func TestCheckSchemaVersion_Behind(t *testing.T) {
+	for _, version := range []int{0, 11} {
+		applied, err := CheckSchemaVersion(context.Background(), fakeVersion{version: version}, 12)
+		if !errors.Is(err, ErrSchemaMismatch) || applied != version {
+			t.Errorf("database at %d: got (%d, %v), want (%d, ErrSchemaMismatch)", version, applied, err, version)
+		}
+	}
+
+	queryErr := errors.New(`relation "schema_migrations" does not exist`)
+	if _, err := CheckSchemaVersion(context.Background(), fakeVersion{err: queryErr}, 12); !errors.Is(err, queryErr) {
+		t.Errorf("err = %v, want the query error", err)
+	}
+}
+
+// A new migration without a SchemaVersion bump would let a build that
+// needs it report ready against a database without it.
+func TestSchemaVersion_MatchesNewestMigration(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("..", "..", "migrations", "*.up.sql"))
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	newest := 0
+	for _, f := range files {
+		prefix, _, _ := strings.Cut(filepath.Base(f), "_")
+		if n, err := strconv.Atoi(prefix); err == nil {
+			newest = max(newest, n)
+		}
+	}
+	if newest != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, newest migration is %03d", SchemaVersion, newest)
+	}
+}
@@ -6,6 +6,7 @@
 package geo
 
 import (
+	"fmt"
 	"math"
 
 	"github.com/shiva/hintro/internal/model"
@@ -48,6 +49,117 @@ func HaversineM(a, b model.Location) float64 {
 	return HaversineKm(a, b) * 1000.0
 }
 
+// DistanceMatrixKm returns the symmetric matrix of Haversine distances
+// between every pair of points: m[i][j] == m[j][i] == HaversineKm(points[i],
+// points[j]), with a zero diagonal. Each pair is computed once, so callers
+// that compare many orderings of the same stops avoid recomputing them.
+//
+// Complexity: O(N²) time and space.
+func DistanceMatrixKm(points []model.Location) [][]float64 {
+	n := len(points)
+	cells := make([]float64, n*n)
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = cells[i*n : (i+1)*n]
+	}
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			d := HaversineKm(points[i], points[j])
+			m[i][j] = d
+			m[j][i] = d
+		}
+	}
+	return m
+}
+
+// ─── Bearing ────────────────────────────────────────────────
+
+// BearingDegrees returns the initial great-circle bearing from a to b in
+// degrees clockwise from north, in [0, 360).
+//
+// Complexity: O(1)
+func BearingDegrees(a, b model.Location) float64 {
+	lat1, lat2 := degToRad(a.Lat), degToRad(b.Lat)
+	dLon := degToRad(b.Lon - a.Lon)
+
+	y := math.Sin(dLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(dLon)
+
+	return math.Mod(math.Atan2(y, x)*180.0/math.Pi+360.0, 360.0)
+}
+
+// BearingDiffDegrees returns the smallest angle between two bearings,
+// in [0, 180].
+func BearingDiffDegrees(a, b float64) float64 {
+	d := math.Mod(math.Abs(a-b), 360.0)
+	return math.Min(d, 360.0-d)
+}
+
+// InsertionDivergenceDegrees returns how far (0–180°) the leg through the
+// stop at idx points away from the route's overall bearing (first stop →
+// last stop). The leg is the one arriving at the stop, or for idx 0 the
+// one leaving it. 0 = straight on, 180 = straight back.
+func InsertionDivergenceDegrees(route []model.Location, idx int) float64 {
+	if len(route) < 3 || idx < 0 || idx >= len(route)-1 {
+		return 0
+	}
+	overall := BearingDegrees(route[0], route[len(route)-1])
+	from, to := idx-1, idx
+	if idx == 0 {
+		from, to = 0, 1
+	}
+	return BearingDiffDegrees(BearingDegrees(route[from], route[to]), overall)
+}
+
+// ─── Geohash ────────────────────────────────────────────────
+
+// NoMatchGeohashPrecision is the geohash length used to bucket areas for
+// metrics: 5 characters ≈ a 4.9 km × 4.9 km cell.
+const NoMatchGeohashPrecision = 5
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash encodes loc as a geohash string of the given length (1–12).
+// Nearby points share a prefix, which makes it a cheap area key.
+//
+// Complexity: O(precision)
+func Geohash(loc model.Location, precision int) string {
+	precision = min(max(precision, 1), 12)
+	latLo, latHi := -90.0, 90.0
+	lonLo, lonHi := -180.0, 180.0
+
+	hash := make([]byte, 0, precision)
+	even := true // bits alternate lon, lat, lon, ...
+	ch, bit := 0, 0
+	for len(hash) < precision {
+		if even {
+			mid := (lonLo + lonHi) / 2
+			if loc.Lon >= mid {
+				ch = ch<<1 | 1
+				lonLo = mid
+			} else {
+				ch <<= 1
+				lonHi = mid
+			}
+		} else {
+			mid := (latLo + latHi) / 2
+			if loc.Lat >= mid {
+				ch = ch<<1 | 1
+				latLo = mid
+			} else {
+				ch <<= 1
+				latHi = mid
+			}
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			ch, bit = 0, 0
+		}
+	}
+	return string(hash)
+}
+
 // ─── Route Calculations ─────────────────────────────────────
 
 // RouteDistanceKm returns the total distance of an ordered route in kilometers.
@@ -69,6 +181,19 @@ func RouteTimeMinutes(route []model.Location) float64 {
 	return (RouteDistanceKm(route) / AverageSpeedKmph) * 60.0
 }
 
+// CumulativeTimesMinutes returns, for each stop of an ordered route, the
+// estimated travel time from route[0] to that stop in minutes, assuming
+// AverageSpeedKmph. times[0] is 0.
+//
+// Complexity: O(S)
+func CumulativeTimesMinutes(route []model.Location) []float64 {
+	times := make([]float64, len(route))
+	for i := 1; i < len(route); i++ {
+		times[i] = times[i-1] + EstimateTimeMinutes(route[i-1], route[i])
+	}
+	return times
+}
+
 // EstimateTimeMinutes returns the estimated direct travel time between two
 // points in minutes.
 //
@@ -77,6 +202,14 @@ func EstimateTimeMinutes(a, b model.Location) float64 {
 	return (HaversineKm(a, b) / AverageSpeedKmph) * 60.0
 }
 
+// DistanceForMinutesM returns how far, in meters, a cab covers in minutes
+// at AverageSpeedKmph — the inverse of the time estimates above.
+//
+// Complexity: O(1)
+func DistanceForMinutesM(minutes float64) float64 {
+	return minutes / 60.0 * AverageSpeedKmph * 1000.0
+}
+
 // ─── Route Manipulation ────────────────────────────────────
 
 // InsertStop returns a new route with the given stop inserted at the specified
@@ -121,8 +254,162 @@ func FindBestInsertionIndex(route []model.Location, stop model.Location) (int, f
 	return bestIdx, bestAdded
 }
 
+// PlanRoute orders stops into a route that ends at final: each stop is
+// placed in turn with FindBestInsertionIndex, then the whole route is
+// improved with Optimize2Opt. final stays last; stops is not modified.
+//
+// Complexity: O(S³) — S ≤ 6 in practice.
+func PlanRoute(stops []model.Location, final model.Location) []model.Location {
+	route := []model.Location{final}
+	for _, stop := range stops {
+		idx, _ := FindBestInsertionIndex(route, stop)
+		route = InsertStop(route, idx, stop)
+	}
+	return Optimize2Opt(route)
+}
+
+// ─── Insertion Strategies ───────────────────────────────────
+
+// InsertionStrategy selects how a new pickup is placed into a trip route.
+type InsertionStrategy string
+
+const (
+	// InsertionMarginal inserts the stop where it adds the least time to
+	// the route as currently ordered (FindBestInsertionIndex). Default.
+	InsertionMarginal InsertionStrategy = "marginal"
+
+	// InsertionCheapestTotal tries every position, re-plans the pickup
+	// order around the new stop (Optimize2Opt), and keeps the position
+	// whose resulting route is shortest overall (FindCheapestTotalInsertion).
+	InsertionCheapestTotal InsertionStrategy = "cheapest_total"
+)
+
+// ParseInsertionStrategy validates a strategy name (e.g. from config).
+// An empty string selects InsertionMarginal.
+func ParseInsertionStrategy(s string) (InsertionStrategy, error) {
+	switch InsertionStrategy(s) {
+	case "", InsertionMarginal:
+		return InsertionMarginal, nil
+	case InsertionCheapestTotal:
+		return InsertionCheapestTotal, nil
+	}
+	return "", fmt.Errorf("geo: unknown insertion strategy %q (want %q or %q)",
+		s, InsertionMarginal, InsertionCheapestTotal)
+}
+
+// FindInsertion dispatches to the given strategy.
+// Returns (index of the new stop in the resulting route, addedTimeMinutes).
+func FindInsertion(strategy InsertionStrategy, route []model.Location, stop model.Location) (int, float64) {
+	idx, added, _ := PlanInsertion(strategy, route, stop)
+	return idx, added
+}
+
+// PlanInsertion is FindInsertion that also returns the resulting route,
+// so callers can inspect the legs around the new stop.
+func PlanInsertion(strategy InsertionStrategy, route []model.Location, stop model.Location) (int, float64, []model.Location) {
+	if strategy == InsertionCheapestTotal {
+		return FindCheapestTotalInsertion(route, stop)
+	}
+	idx, added := FindBestInsertionIndex(route, stop)
+	return idx, added, InsertStop(route, idx, stop)
+}
+
+// FindCheapestTotalInsertion inserts the stop at every position, re-plans
+// each candidate with Optimize2Opt, and keeps the one with the lowest total
+// RouteDistanceKm. Unlike FindBestInsertionIndex it can reorder existing
+// pickups, so a route that was already zig-zagging does not make every
+// insertion look expensive.
+//
+// The added time is measured against route as given — the route the
+// trip's riders are on now — floored at zero when the re-plan comes out
+// shorter. Because existing pickups may move, callers must recheck each
+// rider's own time on the planned route. Returns (index of the new stop in
+// the planned route, addedTimeMinutes, planned route). The last stop
+// (airport) stays last.
+//
+// Complexity: O(S⁴) — S ≤ 6 in practice.
+func FindCheapestTotalInsertion(route []model.Location, stop model.Location) (int, float64, []model.Location) {
+	if len(route) < 2 {
+		return 0, 0, InsertStop(route, 0, stop)
+	}
+
+	baseline := RouteTimeMinutes(route)
+	bestKm := math.MaxFloat64
+	var bestRoute []model.Location
+
+	for i := 0; i < len(route); i++ {
+		planned := Optimize2Opt(InsertStop(route, i, stop))
+		if km := RouteDistanceKm(planned); km < bestKm {
+			bestKm = km
+			bestRoute = planned
+		}
+	}
+
+	idx := 0
+	for i, loc := range bestRoute {
+		if loc == stop {
+			idx = i
+			break
+		}
+	}
+	added := math.Max(0, RouteTimeMinutes(bestRoute)-baseline)
+	return idx, added, bestRoute
+}
+
+// Optimize2Opt improves an open route by reversing segments while that
+// shortens it (2-opt), keeping the last stop fixed. The first stop may
+// change: the cab has not been routed yet when pickups are planned.
+// The input is NOT modified.
+//
+// Distances come from one DistanceMatrixKm; each candidate reversal is
+// scored by the change in its two boundary legs.
+//
+// Complexity: O(S²) per pass; passes repeat until no improvement.
+func Optimize2Opt(route []model.Location) []model.Location {
+	if len(route) < 3 {
+		return append([]model.Location(nil), route...)
+	}
+	dist := DistanceMatrixKm(route)
+	order := make([]int, len(route))
+	for i := range order {
+		order[i] = i
+	}
+
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < len(order)-2; i++ {
+			for j := i + 1; j < len(order)-1; j++ {
+				// Reversing order[i..j] swaps the legs prev→i / j→next
+				// for prev→j / i→next; the legs inside keep their length.
+				next := order[j+1]
+				delta := dist[order[i]][next] - dist[order[j]][next]
+				if i > 0 {
+					prev := order[i-1]
+					delta += dist[prev][order[j]] - dist[prev][order[i]]
+				}
+				if delta < -1e-9 {
+					reverseInts(order[i : j+1])
+					improved = true
+				}
+			}
+		}
+	}
+
+	best := make([]model.Location, len(order))
+	for i, idx := range order {
+		best[i] = route[idx]
+	}
+	return best
+}
+
 // ─── Helpers ────────────────────────────────────────────────
 
+func reverseInts(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
 func degToRad(deg float64) float64 {
 	return deg * (math.Pi / 180.0)
 }
@@ -36,6 +36,18 @@ func TestEstimateTimeMinutes(t *testing.T) {
 	}
 }
 
+func TestDistanceForMinutesM(t *testing.T) {
+	// 2 min at 30 km/h = 1 km.
+	if got := DistanceForMinutesM(2); math.Abs(got-1000) > 1e-9 {
+		t.Errorf("DistanceForMinutesM(2) = %.3f m, want 1000", got)
+	}
+	a := model.Location{Lat: 28.7041, Lon: 77.1025}
+	b := model.Location{Lat: 28.5562, Lon: 77.0889}
+	if got, want := DistanceForMinutesM(EstimateTimeMinutes(a, b)), HaversineM(a, b); math.Abs(got-want) > 1e-6 {
+		t.Errorf("round trip = %.3f m, want %.3f m", got, want)
+	}
+}
+
 func TestRouteDistanceKm(t *testing.T) {
 	route := []model.Location{
 		{Lat: 28.7041, Lon: 77.1025},
@@ -95,3 +107,233 @@ func TestHaversineM(t *testing.T) {
 		t.Errorf("HaversineM = %v, want HaversineKm*1000 = %v", m, km*1000)
 	}
 }
+
+// zigzagRoute visits pickups out of order (north, south, north again)
+// before the airport, which is where the two insertion strategies diverge.
+func zigzagRoute() ([]model.Location, model.Location) {
+	route := []model.Location{
+		{Lat: 28.70, Lon: 77.10},
+		{Lat: 28.60, Lon: 77.10},
+		{Lat: 28.68, Lon: 77.10},
+		{Lat: 28.5562, Lon: 77.0889}, // airport
+	}
+	return route, model.Location{Lat: 28.62, Lon: 77.105}
+}
+
+func TestInsertionStrategies_PickDifferentIndices(t *testing.T) {
+	route, stop := zigzagRoute()
+
+	marginalIdx, _ := FindInsertion(InsertionMarginal, route, stop)
+	totalIdx, _, planned := FindCheapestTotalInsertion(route, stop)
+
+	if marginalIdx != 1 {
+		t.Errorf("marginal idx = %d, want 1 (between the first two pickups)", marginalIdx)
+	}
+	if totalIdx != 2 {
+		t.Errorf("cheapest_total idx = %d, want 2 (after re-planning the pickups)", totalIdx)
+	}
+	if planned[totalIdx] != stop {
+		t.Errorf("planned[%d] = %v, want the new stop %v", totalIdx, planned[totalIdx], stop)
+	}
+
+	marginalKm := RouteDistanceKm(InsertStop(route, marginalIdx, stop))
+	if totalKm := RouteDistanceKm(planned); totalKm >= marginalKm {
+		t.Errorf("cheapest_total route = %.2f km, want shorter than marginal %.2f km", totalKm, marginalKm)
+	}
+	if last := planned[len(planned)-1]; last != route[len(route)-1] {
+		t.Errorf("planned route ends at %v, want the airport %v", last, route[len(route)-1])
+	}
+}
+
+func TestFindCheapestTotalInsertion_AddedNeverNegative(t *testing.T) {
+	route, _ := zigzagRoute()
+	// A stop on top of an existing pickup adds nothing once re-planned.
+	_, added, _ := FindCheapestTotalInsertion(route, route[1])
+	if added < 0 || added > 0.01 {
+		t.Errorf("added = %v, want ~0", added)
+	}
+}
+
+func TestFindCheapestTotalInsertion_MeasuredAgainstCurrentRoute(t *testing.T) {
+	route, _ := zigzagRoute()
+	// Far enough out that the new stop costs more than re-planning saves.
+	_, added, planned := FindCheapestTotalInsertion(route, model.Location{Lat: 28.80, Lon: 77.30})
+
+	want := RouteTimeMinutes(planned) - RouteTimeMinutes(route)
+	if want <= 0 || math.Abs(added-want) > 1e-9 {
+		t.Errorf("added = %.3f min, want %.3f over the route the riders are on", added, want)
+	}
+}
+
+func TestOptimize2Opt_KeepsAirportLast(t *testing.T) {
+	route, _ := zigzagRoute()
+	got := Optimize2Opt(route)
+
+	if RouteDistanceKm(got) >= RouteDistanceKm(route) {
+		t.Errorf("Optimize2Opt did not shorten the zig-zag route")
+	}
+	if got[len(got)-1] != route[len(route)-1] {
+		t.Errorf("Optimize2Opt moved the airport: %v", got)
+	}
+	if route[1].Lat != 28.60 {
+		t.Errorf("Optimize2Opt modified its input")
+	}
+}
+
+func TestParseInsertionStrategy(t *testing.T) {
+	for in, want := range map[string]InsertionStrategy{
+		"":               InsertionMarginal,
+		"marginal":       InsertionMarginal,
+		"cheapest_total": InsertionCheapestTotal,
+	} {
+		got, err := ParseInsertionStrategy(in)
+		if err != nil || got != want {
+			t.Errorf("ParseInsertionStrategy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseInsertionStrategy("fastest"); err == nil {
+		t.Error("ParseInsertionStrategy(\"fastest\") = nil error, want error")
+	}
+}
+
+func TestDistanceMatrixKm(t *testing.T) {
+	points := []model.Location{
+		{Lat: 28.70, Lon: 77.10},
+		{Lat: 28.65, Lon: 77.09},
+		{Lat: 28.62, Lon: 77.21},
+		{Lat: 28.5562, Lon: 77.0889}, // airport
+	}
+	m := DistanceMatrixKm(points)
+
+	if len(m) != len(points) {
+		t.Fatalf("rows = %d, want %d", len(m), len(points))
+	}
+	for i := range points {
+		if len(m[i]) != len(points) {
+			t.Fatalf("row %d has %d columns, want %d", i, len(m[i]), len(points))
+		}
+		if m[i][i] != 0 {
+			t.Errorf("m[%d][%d] = %v, want 0", i, i, m[i][i])
+		}
+		for j := range points {
+			if m[i][j] != m[j][i] {
+				t.Errorf("m[%d][%d] = %v but m[%d][%d] = %v", i, j, m[i][j], j, i, m[j][i])
+			}
+			if want := HaversineKm(points[i], points[j]); math.Abs(m[i][j]-want) > 1e-9 {
+				t.Errorf("m[%d][%d] = %v, want %v", i, j, m[i][j], want)
+			}
+		}
+	}
+}
+
+func TestDistanceMatrixKm_Empty(t *testing.T) {
+	if m := DistanceMatrixKm(nil); len(m) != 0 {
+		t.Errorf("DistanceMatrixKm(nil) = %v, want empty", m)
+	}
+}
+
+func TestGeohash_KnownValues(t *testing.T) {
+	tests := []struct {
+		loc       model.Location
+		precision int
+		want      string
+	}{
+		{model.Location{Lat: 57.64911, Lon: 10.40744}, 11, "u4pruydqqvj"}, // reference point from geohash.org
+		{model.Location{Lat: 28.5562, Lon: 77.0889}, 5, "ttnf6"},          // IGI Airport
+	}
+	for _, tt := range tests {
+		if got := Geohash(tt.loc, tt.precision); got != tt.want {
+			t.Errorf("Geohash(%v, %d) = %q, want %q", tt.loc, tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestGeohash_NearbyPointsShareCell(t *testing.T) {
+	a := Geohash(model.Location{Lat: 28.5562, Lon: 77.0889}, NoMatchGeohashPrecision)
+	b := Geohash(model.Location{Lat: 28.5570, Lon: 77.0895}, NoMatchGeohashPrecision)
+	if a != b {
+		t.Errorf("points ~100 m apart got different cells %q and %q", a, b)
+	}
+}
+
+func TestBearingDegrees(t *testing.T) {
+	origin := model.Location{Lat: 28.60, Lon: 77.10}
+	tests := map[string]struct {
+		to   model.Location
+		want float64
+	}{
+		"north": {model.Location{Lat: 28.70, Lon: 77.10}, 0},
+		"east":  {model.Location{Lat: 28.60, Lon: 77.20}, 90},
+		"south": {model.Location{Lat: 28.50, Lon: 77.10}, 180},
+		"west":  {model.Location{Lat: 28.60, Lon: 77.00}, 270},
+	}
+	for name, tt := range tests {
+		// East/west are great-circle bearings, so allow a small tilt.
+		if got := BearingDegrees(origin, tt.to); math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%s: bearing = %.3f°, want %.0f°", name, got, tt.want)
+		}
+	}
+}
+
+func TestBearingDiffDegrees_WrapsAroundNorth(t *testing.T) {
+	if got := BearingDiffDegrees(350, 10); math.Abs(got-20) > 1e-9 {
+		t.Errorf("diff(350, 10) = %.3f, want 20", got)
+	}
+	if got := BearingDiffDegrees(90, 270); got != 180 {
+		t.Errorf("diff(90, 270) = %.3f, want 180", got)
+	}
+}
+
+func TestInsertionDivergenceDegrees(t *testing.T) {
+	// North → south route; the middle stop sends the cab back north.
+	route := []model.Location{
+		{Lat: 28.70, Lon: 77.10},
+		{Lat: 28.75, Lon: 77.10}, // inserted
+		{Lat: 28.55, Lon: 77.10},
+	}
+	if got := InsertionDivergenceDegrees(route, 1); math.Abs(got-180) > 0.1 {
+		t.Errorf("backtracking stop: divergence = %.2f°, want 180°", got)
+	}
+	route[1] = model.Location{Lat: 28.65, Lon: 77.10}
+	if got := InsertionDivergenceDegrees(route, 1); got > 0.1 {
+		t.Errorf("on-the-way stop: divergence = %.2f°, want 0°", got)
+	}
+}
+
+func TestCumulativeTimesMinutes(t *testing.T) {
+	a := model.Location{Lat: 28.70, Lon: 77.10}
+	b := model.Location{Lat: 28.65, Lon: 77.10}
+	c := model.Location{Lat: 28.55, Lon: 77.10}
+	times := CumulativeTimesMinutes([]model.Location{a, b, c, c})
+	want := []float64{0, EstimateTimeMinutes(a, b), EstimateTimeMinutes(a, b) + EstimateTimeMinutes(b, c)}
+	want = append(want, want[2])
+	for i := range want {
+		if math.Abs(times[i]-want[i]) > 1e-9 {
+			t.Errorf("times[%d] = %.4f, want %.4f", i, times[i], want[i])
+		}
+	}
+	if got := CumulativeTimesMinutes(nil); len(got) != 0 {
+		t.Errorf("empty route: got %v, want none", got)
+	}
+}
+
+func TestPlanRoute(t *testing.T) {
+	airport := model.Location{Lat: 28.5562, Lon: 77.0889}
+	near := model.Location{Lat: 28.60, Lon: 77.09}
+	far := model.Location{Lat: 28.70, Lon: 77.10}
+
+	got := PlanRoute([]model.Location{near, far}, airport)
+	want := []model.Location{far, near, airport}
+	if len(got) != len(want) {
+		t.Fatalf("route = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("route = %v, want %v", got, want)
+		}
+	}
+
+	if got := PlanRoute(nil, airport); len(got) != 1 || got[0] != airport {
+		t.Errorf("no stops: route = %v, want just the final stop", got)
+	}
+}
@@ -0,0 +1,122 @@
+// Package logctx carries domain identifiers (ride request, trip) through a
+// context so every log line of one booking flow can be correlated.
+//
+// Example output:
+//
+//	[booking] Matched to existing trip #7 (cab #3) request_id=42 trip_id=7
+//
+// Lines are leveled with slog levels: Debugf for per-candidate traces,
+// Printf for outcomes, Warnf and Errorf for failures. Lines below the
+// level set with SetLevel (LOG_LEVEL, default info) are dropped.
+package logctx
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// level is the minimum level written. The zero LevelVar is slog.LevelInfo.
+var level slog.LevelVar
+
+type ctxKey int
+
+const (
+	requestIDKey ctxKey = iota
+	tripIDKey
+)
+
+// WithRequestID returns a context whose log lines carry request_id=id.
+func WithRequestID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// WithTripID returns a context whose log lines carry trip_id=id.
+func WithTripID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, tripIDKey, id)
+}
+
+// RequestID returns the ride request ID stored in ctx, if any.
+func RequestID(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(requestIDKey).(int64)
+	return id, ok
+}
+
+// TripID returns the trip ID stored in ctx, if any.
+func TripID(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(tripIDKey).(int64)
+	return id, ok
+}
+
+// Fields renders the identifiers in ctx as "request_id=.. trip_id=..".
+// Returns "" when none are set.
+func Fields(ctx context.Context) string {
+	var parts []string
+	if id, ok := RequestID(ctx); ok {
+		parts = append(parts, fmt.Sprintf("request_id=%d", id))
+	}
+	if id, ok := TripID(ctx); ok {
+		parts = append(parts, fmt.Sprintf("trip_id=%d", id))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ─── Levels ─────────────────────────────────────────────────
+
+// ParseLevel parses a LOG_LEVEL value: debug, info, warn or error
+// (case-insensitive).
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("logctx: unknown log level %q (want debug, info, warn or error)", s)
+	}
+	return l, nil
+}
+
+// SetLevel sets the minimum level written. It also applies to the default
+// slog logger, so slog calls elsewhere honor the same setting.
+func SetLevel(l slog.Level) {
+	level.Set(l)
+	slog.SetLogLoggerLevel(l)
+}
+
+// Enabled reports whether lines at l are written, so callers can skip
+// building expensive debug output.
+func Enabled(l slog.Level) bool {
+	return l >= level.Level()
+}
+
+// Debugf logs a verbose trace (e.g. each candidate trip considered).
+func Debugf(ctx context.Context, format string, args ...any) {
+	output(ctx, slog.LevelDebug, format, args...)
+}
+
+// Printf logs like log.Printf at info level, appending the identifiers in ctx.
+func Printf(ctx context.Context, format string, args ...any) {
+	output(ctx, slog.LevelInfo, format, args...)
+}
+
+// Warnf logs a recoverable failure.
+func Warnf(ctx context.Context, format string, args ...any) {
+	output(ctx, slog.LevelWarn, format, args...)
+}
+
+// Errorf logs a failure that needs attention.
+func Errorf(ctx context.Context, format string, args ...any) {
+	output(ctx, slog.LevelError, format, args...)
+}
+
+// output writes one line if l is enabled. Call depth 3 attributes the line
+// to the caller of Debugf/Printf/Warnf/Errorf under log.Lshortfile.
+func output(ctx context.Context, l slog.Level, format string, args ...any) {
+	if !Enabled(l) {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	if fields := Fields(ctx); fields != "" {
+		msg += " " + fields
+	}
+	log.Output(3, msg)
+}
@@ -0,0 +1,114 @@
+package logctx
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	flags := log.Flags()
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	})
+	return &buf
+}
+
+func TestPrintf_AppendsIDs(t *testing.T) {
+	buf := captureLog(t)
+
+	ctx := WithTripID(WithRequestID(context.Background(), 42), 7)
+	Printf(ctx, "[booking] Matched to existing trip #%d", 7)
+
+	want := "[booking] Matched to existing trip #7 request_id=42 trip_id=7\n"
+	if got := buf.String(); got != want {
+		t.Errorf("log line = %q, want %q", got, want)
+	}
+}
+
+func TestPrintf_NoIDs(t *testing.T) {
+	buf := captureLog(t)
+
+	Printf(context.Background(), "[match] nothing to correlate")
+
+	if got := buf.String(); got != "[match] nothing to correlate\n" {
+		t.Errorf("log line = %q, want no trailing fields", got)
+	}
+}
+
+func TestFields_RequestOnly(t *testing.T) {
+	ctx := WithRequestID(context.Background(), 5)
+	if got := Fields(ctx); got != "request_id=5" {
+		t.Errorf("Fields = %q, want %q", got, "request_id=5")
+	}
+	if strings.Contains(Fields(ctx), "trip_id") {
+		t.Error("Fields reported a trip_id that was never set")
+	}
+}
+
+func withLevel(t *testing.T, l slog.Level) {
+	t.Helper()
+	SetLevel(l)
+	t.Cleanup(func() { SetLevel(slog.LevelInfo) })
+}
+
+func TestInfoLevel_SuppressesDebug(t *testing.T) {
+	buf := captureLog(t)
+	withLevel(t, slog.LevelInfo)
+
+	Debugf(context.Background(), "[match]   Trip #%d: SKIP capacity", 3)
+	Printf(context.Background(), "[match] ✓ Best match: trip #%d", 3)
+
+	if got := buf.String(); got != "[match] ✓ Best match: trip #3\n" {
+		t.Errorf("log output = %q, want only the info line", got)
+	}
+}
+
+func TestDebugLevel_WritesDebug(t *testing.T) {
+	buf := captureLog(t)
+	withLevel(t, slog.LevelDebug)
+
+	Debugf(WithRequestID(context.Background(), 9), "[match] Found %d candidate trips", 2)
+
+	if got := buf.String(); got != "[match] Found 2 candidate trips request_id=9\n" {
+		t.Errorf("log output = %q, want the debug line", got)
+	}
+}
+
+func TestWarnLevel_SuppressesInfo(t *testing.T) {
+	buf := captureLog(t)
+	withLevel(t, slog.LevelWarn)
+
+	Printf(context.Background(), "[booking] Created new trip")
+	Warnf(context.Background(), "[fleet] WARNING: lookup failed")
+
+	if got := buf.String(); got != "[fleet] WARNING: lookup failed\n" {
+		t.Errorf("log output = %q, want only the warning", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"info":  slog.LevelInfo,
+		"WARN":  slog.LevelWarn,
+		"Error": slog.LevelError,
+	} {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("ParseLevel(\"verbose\") succeeded, want error")
+	}
+}
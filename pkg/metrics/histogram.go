@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"expvar"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LatencyBucketsMs are the default Histogram bounds for latencies in
+// milliseconds: sub-millisecond through a quarter second.
+var LatencyBucketsMs = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250}
+
+// Histogram counts observations into fixed buckets. It is an expvar.Var
+// and renders, like a Prometheus histogram, cumulative counts per upper
+// bound ("le") plus a final "+Inf" bucket:
+//
+//	{"count": 3, "sum": 1.9, "buckets": {"0.1": 0, "0.25": 1, …, "+Inf": 3}}
+type Histogram struct {
+	bounds []float64 // Ascending upper bounds.
+
+	mu     sync.Mutex
+	counts []int64 // Per bucket, not cumulative; the last is +Inf.
+	count  int64
+	sum    float64
+}
+
+// NewHistogram creates an unpublished histogram with the given bucket
+// upper bounds. bounds is copied and sorted.
+func NewHistogram(bounds []float64) *Histogram {
+	b := append([]float64(nil), bounds...)
+	sort.Float64s(b)
+	return &Histogram{bounds: b, counts: make([]int64, len(b)+1)}
+}
+
+// Observe records one value.
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v) // First bound ≥ v; len(bounds) = +Inf.
+	h.mu.Lock()
+	h.counts[i]++
+	h.count++
+	h.sum += v
+	h.mu.Unlock()
+}
+
+// Count returns how many values have been observed.
+func (h *Histogram) Count() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+// String renders the histogram as JSON, for expvar.
+func (h *Histogram) String() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString(`{"count": `)
+	b.WriteString(strconv.FormatInt(h.count, 10))
+	b.WriteString(`, "sum": `)
+	b.WriteString(strconv.FormatFloat(h.sum, 'g', -1, 64))
+	b.WriteString(`, "buckets": {`)
+	var cumulative int64
+	for i, n := range h.counts {
+		cumulative += n
+		le := "+Inf"
+		if i < len(h.bounds) {
+			le = strconv.FormatFloat(h.bounds[i], 'g', -1, 64)
+		}
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(strconv.Quote(le))
+		b.WriteString(": ")
+		b.WriteString(strconv.FormatInt(cumulative, 10))
+	}
+	b.WriteString("}}")
+	return b.String()
+}
+
+// MatchLatencyMs is MatchRiders' latency in milliseconds, keyed by phase:
+// "fetch" (candidate query and route loading), "score" (filtering and
+// detour scoring) and "total" (the whole call).
+var MatchLatencyMs = expvar.NewMap("match_latency_ms")
+
+// Histograms for each MatchLatencyMs phase.
+var (
+	MatchFetchMs = NewHistogram(LatencyBucketsMs)
+	MatchScoreMs = NewHistogram(LatencyBucketsMs)
+	MatchTotalMs = NewHistogram(LatencyBucketsMs)
+)
+
+func init() {
+	MatchLatencyMs.Set("fetch", MatchFetchMs)
+	MatchLatencyMs.Set("score", MatchScoreMs)
+	MatchLatencyMs.Set("total", MatchTotalMs)
+}
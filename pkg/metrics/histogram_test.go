@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestHistogram_CumulativeBuckets(t *testing.T) {
+	h := NewHistogram([]float64{10, 1})
+	for _, v := range []float64{0.5, 1, 3, 50} {
+		h.Observe(v)
+	}
+
+	var got struct {
+		Count   int64            `json:"count"`
+		Sum     float64          `json:"sum"`
+		Buckets map[string]int64 `json:"buckets"`
+	}
+	if err := json.Unmarshal([]byte(h.String()), &got); err != nil {
+		t.Fatalf("String() = %s: %v", h.String(), err)
+	}
+	if got.Count != 4 || got.Sum != 54.5 {
+		t.Errorf("count = %d, sum = %v; want 4 and 54.5", got.Count, got.Sum)
+	}
+	// A value equal to a bound falls in that bound's bucket.
+	want := map[string]int64{"1": 2, "10": 3, "+Inf": 4}
+	for le, n := range want {
+		if got.Buckets[le] != n {
+			t.Errorf("bucket le=%s = %d, want %d (all: %v)", le, got.Buckets[le], n, got.Buckets)
+		}
+	}
+}
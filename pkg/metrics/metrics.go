@@ -0,0 +1,23 @@
+// Package metrics holds process-wide counters published through expvar.
+// They are served as JSON at GET /debug/vars on the SERVER_PPROF_ADDR
+// debug listener, never on the API port.
+package metrics
+
+import "expvar"
+
+// NoMatchByArea counts matching attempts that found no compatible trip,
+// keyed by the geohash (geo.NoMatchGeohashPrecision) of the pickup. Cells
+// that keep growing are areas where pooling consistently fails.
+var NoMatchByArea = expvar.NewMap("match_no_match_by_area")
+
+// Count returns the current value of key in m (0 if never incremented).
+func Count(m *expvar.Map, key string) int64 {
+	if v, ok := m.Get(key).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// CacheBreakerState is the state of each Redis circuit breaker, keyed by
+// breaker name: 0 closed, 1 open, 2 half-open (see cache.BreakerState).
+var CacheBreakerState = expvar.NewMap("cache_breaker_state")
@@ -7,6 +7,8 @@
  Tests the Go backend against the mandatory assignment constraints:
    1. Functional Sanity   — Can we book a ride end-to-end?
    2. Race Condition       — Is concurrent booking safe? (SELECT ... FOR UPDATE)
+      (and a double-submit of one request books it once, leaving no empty trips,
+       and riders starting new trips at once never share a cab)
    3. Latency @ 100 RPS   — Is P95 under 300ms?
 
  Requirements:
@@ -44,6 +46,8 @@ PG_DB = "hintro_db"
 
 # Test parameters
 RACE_THREADS = 20          # Number of concurrent booking threads
+DOUBLE_SUBMIT_THREADS = 8  # Concurrent submits of the same request
+NEW_TRIP_THREADS = 8       # Concurrent bookings that each need a new trip
 LATENCY_REQUESTS = 500     # Total requests for the latency test
 P95_THRESHOLD_MS = 300     # Maximum acceptable P95 latency
 
@@ -306,6 +310,14 @@ def test_cancel():
     assert_test("Response has previous_trip_id", "previous_trip_id" in data2)
     assert_test("Response has trip_cancelled", data2.get("trip_cancelled") is True)
 
+    # Retry the matched cancel - replays the original result
+    r3 = requests.post(f"{BASE_URL}/api/v1/cancel/1", timeout=10)
+    data3 = r3.json() if r3.status_code == 200 else {}
+    assert_test("Repeat cancel returns 200", r3.status_code == 200,
+                f"Got {r3.status_code}: {r3.text[:100]}" if r3.status_code != 200 else "")
+    assert_test("Repeat cancel has already_cancelled", data3.get("already_cancelled") is True)
+    assert_test("Repeat cancel replays trip_cancelled", data3.get("trip_cancelled") is True)
+
 
 # ─── Test 1d: Fare Estimate API ─────────────────────────────
 
@@ -327,6 +339,46 @@ def test_fare_estimate():
     assert_test("total_fare_cents > 0", data.get("total_fare_cents", 0) > 0)
 
 
+# ─── Test 1e: Cab Delete API ────────────────────────────────
+
+def test_cab_delete():
+    header("TEST 1e: CAB DELETE API")
+    print("  Setup: One cab on a planned trip, one idle cab...\n")
+
+    seed_sql("""
+        TRUNCATE ride_requests, trips, cabs, users RESTART IDENTITY CASCADE;
+
+        INSERT INTO users (name, email, phone, role) VALUES
+          ('Dave', 'dave@test.com', '+913333333333', 'driver'),
+          ('Erin', 'erin@test.com', '+914444444444', 'driver');
+
+        INSERT INTO cabs (driver_id, license_plate, seat_capacity, luggage_capacity,
+                          current_location, status) VALUES
+          (1, 'DL-01-AB', 4, 3, ST_SetSRID(ST_MakePoint(77.1000, 28.6800), 4326), 'en_route'),
+          (2, 'DL-02-CD', 4, 3, ST_SetSRID(ST_MakePoint(77.1000, 28.6800), 4326), 'available');
+
+        INSERT INTO trips (cab_id, direction, total_fare_cents, passenger_count, status) VALUES
+          (1, 'to_airport', 0, 1, 'planned');
+    """)
+
+    # Cab 1 has a planned trip → refused.
+    r = requests.delete(f"{BASE_URL}/api/v1/cabs/1", timeout=10)
+    assert_test("Delete cab with active trip returns 409", r.status_code == 409,
+                f"Got {r.status_code}: {r.text[:100]}" if r.status_code != 409 else "")
+    deleted1 = run_sql("SELECT deleted_at IS NOT NULL FROM cabs WHERE id = 1;")
+    assert_test("DB: cab 1 not deleted", deleted1 == "f", f"Got: {deleted1}")
+
+    # Cab 2 is idle → soft-deleted and taken offline.
+    r2 = requests.delete(f"{BASE_URL}/api/v1/cabs/2", timeout=10)
+    assert_test("Delete idle cab returns 200", r2.status_code == 200,
+                f"Got {r2.status_code}: {r2.text[:100]}" if r2.status_code != 200 else "")
+    row = run_sql("SELECT deleted_at IS NOT NULL, status FROM cabs WHERE id = 2;")
+    assert_test("DB: cab 2 soft-deleted and offline", row == "t|offline", f"Got: {row}")
+
+    r3 = requests.delete(f"{BASE_URL}/api/v1/cabs/2", timeout=10)
+    assert_test("Repeat delete returns 404", r3.status_code == 404, f"Got {r3.status_code}")
+
+
 # ─── Test 2: Race Condition (Concurrency Safety) ────────────
 
 def test_race_condition():
@@ -462,6 +514,173 @@ def test_race_condition():
         print(f"        FIX: Check the FOR UPDATE clause in booking_repository.go")
 
 
+# ─── Test 2b: Double-Submit of One Request ──────────────────
+
+def test_double_submit():
+    header("TEST 2b: DOUBLE-SUBMIT (Same Request Booked Concurrently)")
+    print(f"  Setup: 1 pending request, 3 free cabs, {DOUBLE_SUBMIT_THREADS} threads booking it...\n")
+
+    # No trip exists yet, so every racer that gets past matching would start
+    # a trip of its own. Only one may book; the others' trips must be undone.
+    seed_sql("""
+        TRUNCATE ride_requests, trips, cabs, users RESTART IDENTITY CASCADE;
+
+        INSERT INTO users (name, email, phone, role) VALUES
+          ('DoubleRider', 'doublerider@test.com', '+910000000001', 'passenger'),
+          ('DoubleDriver1', 'doubledriver1@test.com', '+910000000002', 'driver'),
+          ('DoubleDriver2', 'doubledriver2@test.com', '+910000000003', 'driver'),
+          ('DoubleDriver3', 'doubledriver3@test.com', '+910000000004', 'driver');
+
+        INSERT INTO cabs (driver_id, license_plate, seat_capacity, luggage_capacity,
+                          current_location, status) VALUES
+          (2, 'DOUBLE-CAB-1', 4, 4, ST_SetSRID(ST_MakePoint(77.1000, 28.7000), 4326), 'available'),
+          (3, 'DOUBLE-CAB-2', 4, 4, ST_SetSRID(ST_MakePoint(77.1010, 28.7010), 4326), 'available'),
+          (4, 'DOUBLE-CAB-3', 4, 4, ST_SetSRID(ST_MakePoint(77.1020, 28.7020), 4326), 'available');
+
+        INSERT INTO ride_requests (user_id, origin, destination, direction,
+                                   seats_needed, luggage_count, tolerance_meters, status) VALUES
+          (1,
+           ST_SetSRID(ST_MakePoint(77.1025, 28.7041), 4326),
+           ST_SetSRID(ST_MakePoint(77.0889, 28.5562), 4326),
+           'to_airport', 1, 1, 5000, 'pending');
+    """)
+
+    def book_once(_):
+        try:
+            r = requests.post(f"{BASE_URL}/api/v1/book/1", timeout=15)
+            return r.status_code, r.json()
+        except Exception as e:
+            return 0, {"error": str(e)}
+
+    with ThreadPoolExecutor(max_workers=DOUBLE_SUBMIT_THREADS) as pool:
+        outcomes = list(pool.map(book_once, range(DOUBLE_SUBMIT_THREADS)))
+
+    successes = [data for status, data in outcomes if status == 200]
+    rejections = [(status, data) for status, data in outcomes if status != 200]
+    print(f"  Successful bookings (200): {len(successes)}")
+    print(f"  Rejected bookings:         {len(rejections)}")
+
+    # Every submit that gets through is answered with the one booking that
+    # won, so the bodies are identical.
+    assert_test(
+        "Every successful submit got the same booking",
+        len(successes) >= 1 and all(data == successes[0] for data in successes),
+        f"Bookings seen: {set(data.get('trip_id') for data in successes)}",
+    )
+    # A loser that finds no cab to start its own trip on, while the other
+    # racers hold all three, is refused before the winner is known.
+    assert_test(
+        "Every other submit was refused (404 no_cab)",
+        all((status, data.get("code")) == (404, "no_cab") for status, data in rejections),
+        f"Rejections seen: {set((status, data.get('code')) for status, data in rejections)}",
+    )
+
+    trips = int(run_sql("SELECT COUNT(*) FROM trips;"))
+    assert_test(
+        "DB: exactly 1 trip (no empty trips left by losing submits)",
+        trips == 1,
+        f"Got {trips} trips",
+    )
+    row = run_sql("SELECT r.status || ',' || (r.trip_id = t.id)::text || ',' || t.passenger_count "
+                  "FROM ride_requests r, trips t WHERE r.id = 1;")
+    assert_test(
+        "DB: request matched onto that trip, counted once",
+        row == "matched,true,1",
+        f"status,on_trip,passenger_count = {row}",
+    )
+    freed = int(run_sql("SELECT COUNT(*) FROM cabs WHERE status = 'available';"))
+    assert_test(
+        "DB: the 2 cabs losing submits claimed are available again",
+        freed == 2,
+        f"Got {freed} available cabs",
+    )
+
+
+def test_new_trip_claims():
+    header("TEST 2c: NEW TRIPS (No Cab Assigned Twice)")
+    print(f"  Setup: {NEW_TRIP_THREADS} pending 4-seat requests, 3 free 4-seat cabs, booked at once...\n")
+
+    # Every request fills a cab on its own, so none can pool: each booking
+    # must claim a cab of its own, and there are only three.
+    users = ",\n".join(
+        f"('NewTripRider{i}', 'newtriprider{i}@test.com', '+9200000000{i:02d}', 'passenger')"
+        for i in range(1, NEW_TRIP_THREADS + 1)
+    )
+    requests_sql = ",\n".join(
+        f"({i}, ST_SetSRID(ST_MakePoint(77.1025, 28.7041), 4326), "
+        "ST_SetSRID(ST_MakePoint(77.0889, 28.5562), 4326), 'to_airport', 4, 0, 5000, 'pending')"
+        for i in range(1, NEW_TRIP_THREADS + 1)
+    )
+    seed_sql(f"""
+        TRUNCATE ride_requests, trips, cabs, users RESTART IDENTITY CASCADE;
+
+        INSERT INTO users (name, email, phone, role) VALUES
+          {users},
+          ('NewTripDriver1', 'newtripdriver1@test.com', '+920000000101', 'driver'),
+          ('NewTripDriver2', 'newtripdriver2@test.com', '+920000000102', 'driver'),
+          ('NewTripDriver3', 'newtripdriver3@test.com', '+920000000103', 'driver');
+
+        INSERT INTO cabs (driver_id, license_plate, seat_capacity, luggage_capacity,
+                          current_location, status) VALUES
+          ({NEW_TRIP_THREADS + 1}, 'NEWTRIP-CAB-1', 4, 4, ST_SetSRID(ST_MakePoint(77.1000, 28.7000), 4326), 'available'),
+          ({NEW_TRIP_THREADS + 2}, 'NEWTRIP-CAB-2', 4, 4, ST_SetSRID(ST_MakePoint(77.1010, 28.7010), 4326), 'available'),
+          ({NEW_TRIP_THREADS + 3}, 'NEWTRIP-CAB-3', 4, 4, ST_SetSRID(ST_MakePoint(77.1020, 28.7020), 4326), 'available');
+
+        INSERT INTO ride_requests (user_id, origin, destination, direction,
+                                   seats_needed, luggage_count, tolerance_meters, status) VALUES
+          {requests_sql};
+    """)
+
+    def book(request_id):
+        try:
+            r = requests.post(f"{BASE_URL}/api/v1/book/{request_id}", timeout=15)
+            return r.status_code, r.json()
+        except Exception as e:
+            return 0, {"error": str(e)}
+
+    with ThreadPoolExecutor(max_workers=NEW_TRIP_THREADS) as pool:
+        outcomes = list(pool.map(book, range(1, NEW_TRIP_THREADS + 1)))
+
+    successes = [data for status, data in outcomes if status == 200]
+    rejections = [(status, data) for status, data in outcomes if status != 200]
+    print(f"  Successful bookings (200): {len(successes)}")
+    print(f"  Rejected bookings:         {len(rejections)}")
+
+    assert_test(
+        "Exactly 3 bookings succeeded (one per cab)",
+        len(successes) == 3,
+        f"Got {len(successes)} successes",
+    )
+    assert_test(
+        "Every successful booking got a different cab",
+        len({data.get("cab_id") for data in successes}) == len(successes),
+        f"Cabs booked: {[data.get('cab_id') for data in successes]}",
+    )
+    assert_test(
+        "Every other booking found no cab (404 no_cab)",
+        all((status, data.get("code")) == (404, "no_cab") for status, data in rejections),
+        f"Rejections seen: {set((status, data.get('code')) for status, data in rejections)}",
+    )
+
+    shared = int(run_sql("""
+        SELECT COUNT(*) FROM (
+          SELECT cab_id FROM trips WHERE status IN ('planned', 'in_progress')
+          GROUP BY cab_id HAVING COUNT(*) > 1
+        ) s;
+    """))
+    assert_test(
+        "DB: no cab has more than one live trip",
+        shared == 0,
+        f"{shared} cabs have several live trips",
+    )
+    trips = int(run_sql("SELECT COUNT(*) FROM trips;"))
+    assert_test(
+        "DB: exactly 3 trips (no empty trips left behind)",
+        trips == 3,
+        f"Got {trips} trips",
+    )
+
+
 # ─── Test 3: Latency @ 100 RPS ──────────────────────────────
 
 def test_latency():
@@ -580,7 +799,10 @@ def main():
     test_match()
     test_cancel()
     test_fare_estimate()
+    test_cab_delete()
     test_race_condition()
+    test_double_submit()
+    test_new_trip_claims()
     test_latency()
 
     elapsed = time.time() - start